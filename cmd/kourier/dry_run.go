@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/net-kourier/pkg/local"
+	"knative.dev/net-kourier/pkg/reconciler/ingress"
+	"knative.dev/pkg/environment"
+	"knative.dev/pkg/logging"
+)
+
+// dryRun writes the Envoy resources translated from the manifests at the given path to
+// stdout. The objects missing from the manifests are fetched from the cluster of the
+// current kubeconfig if fromCluster is set.
+func dryRun(path string, fromCluster bool) int {
+	// Only warnings go to stderr, to not clutter the output in CI.
+	logger, err := zap.NewProduction(zap.IncreaseLevel(zap.WarnLevel))
+	if err != nil {
+		log.Printf("failed to create logger: %v", err)
+		return 1
+	}
+	ctx := logging.WithLogger(context.Background(), logger.Sugar())
+
+	opts := local.DryRunOptions{Path: path}
+	if fromCluster {
+		cfg, err := (&environment.ClientConfig{Kubeconfig: os.Getenv("KUBECONFIG")}).GetRESTConfig()
+		if err != nil {
+			log.Printf("failed to load the kubeconfig: %v", err)
+			return 1
+		}
+		kubeClient, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			log.Printf("failed to create the Kubernetes client: %v", err)
+			return 1
+		}
+		opts.Fallback = ingress.NewClientObjectGetter(ctx, kubeClient)
+	}
+
+	if err := local.DryRun(ctx, opts, os.Stdout); err != nil {
+		log.Printf("dry run failed: %v", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"knative.dev/net-kourier/pkg/reconciler/ingress"
+)
+
+const inventoryTimeout = 30 * time.Second
+
+// exportInventory writes the inventory of the external hosts served by the controller at
+// the given address to stdout, in the given format.
+func exportInventory(addr, format string) int {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     addr,
+		Path:     ingress.InventoryPath,
+		RawQuery: url.Values{"format": []string{format}}.Encode(),
+	}
+
+	client := http.Client{Timeout: inventoryTimeout}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		log.Printf("failed to fetch the inventory from %q: %v", addr, err)
+		return connectionFailure
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("failed to fetch the inventory (status %d): %s", resp.StatusCode, body)
+		return rpcFailure
+	}
+
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		log.Printf("failed to write the inventory: %v", err)
+		return rpcFailure
+	}
+	return 0
+}
@@ -18,11 +18,18 @@ package main
 
 import (
 	"flag"
+	"log"
 	"os"
 
+	"go.uber.org/zap"
 	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/net-kourier/pkg/local"
+	"knative.dev/net-kourier/pkg/reconciler/bootstrap"
 	"knative.dev/net-kourier/pkg/reconciler/informerfiltering"
 	kourierIngressController "knative.dev/net-kourier/pkg/reconciler/ingress"
+	"knative.dev/net-kourier/pkg/reconciler/isolation"
+	"knative.dev/net-kourier/pkg/reconciler/networkpolicy"
+	"knative.dev/pkg/logging"
 	"knative.dev/pkg/signals"
 
 	// This defines the shared main for injected controllers.
@@ -31,6 +38,20 @@ import (
 
 var (
 	probeAddr = flag.String("probe-addr", "", "run this binary as a health check against the given address")
+
+	inventoryAddr   = flag.String("inventory-addr", "", "write the inventory of the external hosts served by the controller at the given address to stdout")
+	inventoryFormat = flag.String("inventory-format", "json", "the format of the inventory, either json or csv")
+
+	retranslateAddr    = flag.String("retranslate-addr", "", "force the controller at the given address to translate the Ingress given with -retranslate-ingress anew")
+	retranslateIngress = flag.String("retranslate-ingress", "", "the Ingress to translate anew, as namespace/name")
+
+	localPath      = flag.String("local", "", "run the control plane without a cluster, reading Ingresses and the objects they reference from the given file or directory")
+	envoyBinary    = flag.String("envoy-binary", "envoy", "the Envoy binary to spawn in local mode, no Envoy is spawned if empty")
+	managementPort = flag.Uint("management-port", 18000, "the port of the xDS server in local mode")
+	localDeltaXDS  = flag.Bool("local-delta-xds", false, "make the Envoy spawned in local mode subscribe via incremental xDS")
+
+	dryRunPath        = flag.String("dry-run", "", "write the Envoy resources translated from the Ingresses and the objects they reference in the given file or directory to stdout, without serving them")
+	dryRunFromCluster = flag.Bool("dry-run-from-cluster", false, "fetch the objects referenced by the Ingresses of a dry run which aren't in its files from the cluster of the current kubeconfig")
 )
 
 func main() {
@@ -41,6 +62,46 @@ func main() {
 		os.Exit(check(*probeAddr))
 	}
 
+	// Export the inventory of the external hosts if the respective flag is given.
+	if *inventoryAddr != "" {
+		os.Exit(exportInventory(*inventoryAddr, *inventoryFormat))
+	}
+
+	// Force the re-translation of an Ingress if the respective flag is given.
+	if *retranslateAddr != "" {
+		os.Exit(retranslate(*retranslateAddr, *retranslateIngress))
+	}
+
+	// Translate the given Ingresses without serving them if the respective flag is given.
+	if *dryRunPath != "" {
+		os.Exit(dryRun(*dryRunPath, *dryRunFromCluster))
+	}
+
+	// Run the control plane locally if the respective flag is given.
+	if *localPath != "" {
+		os.Exit(runLocal(*localPath))
+	}
+
 	ctx := informerfiltering.GetContextWithFilteringLabelSelector(signals.NewContext())
-	sharedmain.MainWithContext(ctx, config.ControllerName, kourierIngressController.NewController)
+	sharedmain.MainWithContext(ctx, config.ControllerName, kourierIngressController.NewController, isolation.NewController, networkpolicy.NewController, bootstrap.NewController)
+}
+
+func runLocal(path string) int {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Printf("failed to create logger: %v", err)
+		return 1
+	}
+	ctx := logging.WithLogger(signals.NewContext(), logger.Sugar())
+
+	if err := local.Run(ctx, local.Options{
+		Path:           path,
+		EnvoyBinary:    *envoyBinary,
+		ManagementPort: *managementPort,
+		DeltaXDS:       *localDeltaXDS,
+	}); err != nil {
+		logger.Error("Failed to run locally", zap.Error(err))
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"knative.dev/net-kourier/pkg/reconciler/ingress"
+)
+
+const retranslateTimeout = 30 * time.Second
+
+// retranslate forces the controller at the given address to translate the given Ingress,
+// as namespace/name, anew and to push the result to the gateways.
+func retranslate(addr, ing string) int {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     addr,
+		Path:     ingress.RetranslatePath,
+		RawQuery: url.Values{"ingress": []string{ing}}.Encode(),
+	}
+
+	client := http.Client{Timeout: retranslateTimeout}
+	resp, err := client.Post(u.String(), "", nil)
+	if err != nil {
+		log.Printf("failed to reach the controller at %q: %v", addr, err)
+		return connectionFailure
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("failed to force the re-translation of %q (status %d): %s", ing, resp.StatusCode, body)
+		return rpcFailure
+	}
+
+	log.Printf("forced the re-translation of %q", ing)
+	return 0
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 
 	"knative.dev/pkg/kmap"
 	"knative.dev/pkg/network"
@@ -37,6 +39,10 @@ const (
 	// ExternalServiceName is the name of the external service.
 	ExternalServiceName = "kourier"
 
+	// HeaderMetadataNamespace is the namespace of the dynamic metadata extracted from the
+	// headers of the external requests, as configured with the header-metadata key.
+	HeaderMetadataNamespace = "kourier"
+
 	// HTTPPortExternal is the port for external availability.
 	HTTPPortExternal = uint32(8080)
 
@@ -49,24 +55,51 @@ const (
 	// HTTPSPortExternal is the port for external HTTPS availability.
 	HTTPSPortExternal = uint32(8443)
 
+	// HTTPSPortInternalService is the HTTPS port of the internal service, which
+	// forwards to HTTPSPortInternal.
+	HTTPSPortInternalService = uint32(443)
+
 	// HTTPPortProb is the port for prob
 	HTTPPortProb = uint32(8090)
 
 	// HTTPSPortProb is the port for prob
 	HTTPSPortProb = uint32(9443)
 
+	// HTTPPortStats is the port of the stats listener defined in the bootstrap config.
+	HTTPPortStats = uint32(9000)
+
 	// InternalKourierDomain is an internal envoy endpoint.
 	InternalKourierDomain = "internalkourier"
 
 	// GatewayNamespaceEnv is an env variable specifying where the gateway is deployed.
 	GatewayNamespaceEnv = "KOURIER_GATEWAY_NAMESPACE"
 
+	// ActivatorServiceName is the name of the service of the activator, in the namespace
+	// serving is deployed to.
+	ActivatorServiceName = "activator-service"
+
+	// GatewayNodeID is the xDS node ID of the shared gateways.
+	GatewayNodeID = "3scale-kourier-gateway"
+
+	// RuntimeLayerName is the name of the runtime layer the gateways fetch via RTDS, as
+	// declared by their bootstrap config.
+	RuntimeLayerName = "kourier-runtime"
+
+	// BootstrapConfigMapName is the name of the ConfigMap holding the bootstrap config of
+	// the shared gateways at BootstrapConfigMapKey, in the namespace of the gateways.
+	BootstrapConfigMapName = "kourier-bootstrap"
+	BootstrapConfigMapKey  = "envoy-bootstrap.yaml"
+
+	// GatewayLabelKey and GatewayLabelValue label the pods of the shared gateways.
+	GatewayLabelKey   = "app"
+	GatewayLabelValue = "3scale-kourier-gateway"
+
 	// KourierIngressClassName is the class name to reconcile.
 	KourierIngressClassName = "kourier.ingress.networking.knative.dev"
 
-	// disableHTTP2AnnotationKey is the annotation key attached to a Knative Domain Mapping
+	// DisableHTTP2AnnotationKey is the annotation key attached to a Knative Domain Mapping
 	// to indicate that http2 should not be enabled for it.
-	disableHTTP2AnnotationKey = "kourier.knative.dev/disable-http2"
+	DisableHTTP2AnnotationKey = "kourier.knative.dev/disable-http2"
 
 	// ServingNamespaceEnv is an env variable specifying where the serving is deployed.
 	// e.g. OpenShift deploys Kourier in different namespace so `system.Namespace()` does not work.
@@ -75,10 +108,521 @@ const (
 	// ListenerPortAnnotationKey is the annotation key for assigning the ingress to a particular
 	// envoy listener port. Only applicable to internal services.
 	ListenerPortAnnotationKey = "kourier.knative.dev/listener-port"
+
+	// ListenerTLSSecretAnnotationKey is the annotation key attached to a Namespace with a
+	// listener port to serve its listener over HTTPS only. The value is the name of the
+	// secret, in that namespace, holding the certificate to serve.
+	ListenerTLSSecretAnnotationKey = "kourier.knative.dev/listener-tls-secret"
+
+	// ListenerAccessLogAnnotationKey is the annotation key attached to a Namespace with a
+	// listener port to write the access logs of its listener to the given absolute path
+	// instead of stdout, or to disable them with "off".
+	ListenerAccessLogAnnotationKey = "kourier.knative.dev/listener-access-log"
+
+	// ListenerTransformationWasmModuleAnnotationKey is the annotation key attached to a
+	// Namespace with a listener port to transform the requests on its listener with the
+	// given Wasm module instead of the configured one, or not at all with "off".
+	ListenerTransformationWasmModuleAnnotationKey = "kourier.knative.dev/listener-transformation-wasm-module"
+
+	// ListenerIdleTimeoutAnnotationKey is the annotation key attached to a Namespace with a
+	// listener port to close the streams on its listener after being idle for the given
+	// duration instead of the configured one, or never with "0s".
+	ListenerIdleTimeoutAnnotationKey = "kourier.knative.dev/listener-idle-timeout"
+
+	// ListenerRequestTimeoutAnnotationKey is the annotation key attached to a Namespace with
+	// a listener port to limit the time the clients have to send their requests on its
+	// listener to the given duration instead of the configured one, or not at all with "0s".
+	ListenerRequestTimeoutAnnotationKey = "kourier.knative.dev/listener-request-timeout"
+
+	// ListenerExtAuthzAnnotationKey is the annotation key attached to a Namespace with a
+	// listener port to authorize the requests on its listener with the configured external
+	// authorization, "on", or not at all, "off".
+	ListenerExtAuthzAnnotationKey = "kourier.knative.dev/listener-ext-authz"
+
+	// HeaderMatchAnnotationKey is the annotation key attached to an Ingress to specify
+	// additional header matchers (JSON encoded) that are applied to all of its routes.
+	HeaderMatchAnnotationKey = "kourier.knative.dev/header-match"
+
+	// QueryParamMatchAnnotationKey is the annotation key attached to an Ingress to specify
+	// query parameter matchers (JSON encoded) that are applied to all of its routes.
+	QueryParamMatchAnnotationKey = "kourier.knative.dev/query-param-match"
+
+	// TransformAnnotationKey is the annotation key attached to an Ingress to specify
+	// the request transformations (JSON encoded) done by the transformation Wasm module.
+	TransformAnnotationKey = "kourier.knative.dev/transform"
+
+	// DefaultCertificateAnnotationKey is the annotation key attached to a Namespace to
+	// specify the name of the secret, in that namespace, holding the certificate to serve
+	// for external hosts of its Ingresses that are not covered by their TLS settings.
+	DefaultCertificateAnnotationKey = "kourier.knative.dev/default-certificate-secret"
+
+	// ClientCASecretAnnotationKey is the annotation key attached to an Ingress to require
+	// client certificates on its external TLS hosts. The value is the name of the secret,
+	// in the Ingress' namespace, holding the CA bundle to validate them against.
+	ClientCASecretAnnotationKey = "kourier.knative.dev/client-ca-secret"
+
+	// ClientAllowedSANsAnnotationKey is the annotation key attached to an Ingress to
+	// restrict the accepted client certificates to the given comma separated SANs.
+	ClientAllowedSANsAnnotationKey = "kourier.knative.dev/client-allowed-sans"
+
+	// ListenerPoolAnnotationKey is the annotation key attached to an Ingress to serve its
+	// external hosts on the listener of the given pool, as configured in config-kourier,
+	// instead of the shared external listeners.
+	ListenerPoolAnnotationKey = "kourier.knative.dev/listener-pool"
+
+	// StickyCanaryTTLAnnotationKey is the annotation key attached to an Ingress to pin
+	// clients to the split chosen for their first request, for the given duration.
+	StickyCanaryTTLAnnotationKey = "kourier.knative.dev/sticky-canary-ttl"
+
+	// GatewayFleetAnnotationKey is the label or annotation key attached to an Ingress to
+	// serve it by the gateways of the given fleet, as configured in config-kourier,
+	// instead of the shared gateways.
+	GatewayFleetAnnotationKey = "kourier.knative.dev/gateway-fleet"
+
+	// TLSPassthroughAnnotationKey is the annotation key attached to an Ingress to pass the
+	// TLS connections to its external hosts through to its backends as they are, if set to
+	// "true", instead of terminating TLS at the gateway.
+	TLSPassthroughAnnotationKey = "kourier.knative.dev/tls-passthrough"
+
+	// GRPCJSONTranscoderAnnotationKey is the annotation key attached to an Ingress to
+	// transcode the JSON requests to its hosts into gRPC requests of the services in the
+	// protobuf descriptor set held by the given Secret, in the Ingress' namespace.
+	GRPCJSONTranscoderAnnotationKey = "kourier.knative.dev/grpc-json-transcoder-secret"
+
+	// MaxRequestsAnnotationKey is the annotation key attached to an Ingress to cap the
+	// concurrent requests in flight to each of its backends at the gateway.
+	MaxRequestsAnnotationKey = "kourier.knative.dev/max-requests"
+
+	// UpgradesAnnotationKey is the annotation key attached to an Ingress to replace the
+	// upgrades allowed on its routes, "websocket" by default, with the given comma
+	// separated ones, or none if set to "none". "CONNECT" allows CONNECT requests.
+	UpgradesAnnotationKey = "kourier.knative.dev/upgrades"
+
+	// IdleTimeoutAnnotationKey is the annotation key attached to an Ingress to override the
+	// idle timeout of the streams of its routes, including upgraded connections.
+	IdleTimeoutAnnotationKey = "kourier.knative.dev/idle-timeout"
+
+	// MaxStreamDurationAnnotationKey is the annotation key attached to an Ingress to limit
+	// the duration of the streams of its routes, including upgraded connections.
+	MaxStreamDurationAnnotationKey = "kourier.knative.dev/max-stream-duration"
+
+	// UpstreamConnectionIdleTimeoutAnnotationKey is the annotation key attached to an
+	// Ingress to override the idle timeout of the connections to its backends.
+	UpstreamConnectionIdleTimeoutAnnotationKey = "kourier.knative.dev/upstream-connection-idle-timeout"
+
+	// UpstreamHTTP2MaxConcurrentStreamsAnnotationKey is the annotation key attached to an
+	// Ingress to override the maximum number of concurrent streams per HTTP/2 connection
+	// to its backends.
+	UpstreamHTTP2MaxConcurrentStreamsAnnotationKey = "kourier.knative.dev/upstream-http2-max-concurrent-streams"
+
+	// UpstreamHTTP2InitialStreamWindowSizeAnnotationKey is the annotation key attached to
+	// an Ingress to override the initial stream flow-control window size of the HTTP/2
+	// connections to its backends.
+	UpstreamHTTP2InitialStreamWindowSizeAnnotationKey = "kourier.knative.dev/upstream-http2-initial-stream-window-size"
+
+	// UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey is the annotation key attached
+	// to an Ingress to override the initial connection flow-control window size of the
+	// HTTP/2 connections to its backends.
+	UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey = "kourier.knative.dev/upstream-http2-initial-connection-window-size"
+
+	// UpstreamHTTP1EnableTrailersAnnotationKey is the annotation key attached to an
+	// Ingress to override whether the HTTP/1 connections to its backends carry trailers.
+	UpstreamHTTP1EnableTrailersAnnotationKey = "kourier.knative.dev/upstream-http1-enable-trailers"
+
+	// UpstreamConnectTimeoutAnnotationKey is the annotation key attached to an Ingress to
+	// override the timeout of establishing the connections to its backends.
+	UpstreamConnectTimeoutAnnotationKey = "kourier.knative.dev/upstream-connect-timeout"
+
+	// UpstreamTCPKeepaliveTimeAnnotationKey is the annotation key attached to an Ingress
+	// to override the time the connections to its backends are idle before TCP keepalive
+	// probes are sent, enabling TCP keepalive for them.
+	UpstreamTCPKeepaliveTimeAnnotationKey = "kourier.knative.dev/upstream-tcp-keepalive-time"
+
+	// MaxRequestBodyBytesAnnotationKey is the annotation key attached to an Ingress to
+	// override the maximum size of the bodies of the requests to its hosts, or to stream
+	// them without buffering if set to "off".
+	MaxRequestBodyBytesAnnotationKey = "kourier.knative.dev/max-request-body-bytes"
+
+	// ScheduleWindowsAnnotationKey is the annotation key attached to an Ingress to specify
+	// the recurring windows of time, like "Sat,Sun 02:00-04:00; Mon-Fri 22:00-23:00" in
+	// UTC, within which its requests are routed to the backends specified with
+	// ScheduleBackendsAnnotationKey.
+	ScheduleWindowsAnnotationKey = "kourier.knative.dev/schedule-windows"
+
+	// ScheduleBackendsAnnotationKey is the annotation key attached to an Ingress to specify
+	// the services of its namespace, like "maintenance:80" or "blue:80=90,green:80=10",
+	// its requests are routed to within the windows specified with
+	// ScheduleWindowsAnnotationKey, replacing the backends of all of its paths.
+	ScheduleBackendsAnnotationKey = "kourier.knative.dev/schedule-backends"
+
+	// ExternalNameHealthCheckAnnotationKey is the annotation key attached to an Ingress to
+	// actively health check the backends of its ExternalName services, either over HTTP by
+	// requesting the given path, like "/healthz", or by connecting to them if set to "tcp".
+	ExternalNameHealthCheckAnnotationKey = "kourier.knative.dev/external-name-health-check"
+
+	// ExternalNameHealthCheckIntervalAnnotationKey is the annotation key attached to an
+	// Ingress to specify the interval of the health checks of its ExternalName services.
+	ExternalNameHealthCheckIntervalAnnotationKey = "kourier.knative.dev/external-name-health-check-interval"
+
+	// RouteTTLAnnotationKey is the annotation key attached to an Ingress to specify the
+	// TTL of its routes, after which the gateways drop them unless they're refreshed.
+	RouteTTLAnnotationKey = "kourier.knative.dev/route-ttl"
+
+	// PreviewHostsAnnotationKey is the annotation key attached to an Ingress to serve
+	// every split of its paths with multiple splits on a preview host of its own, if set
+	// to "true".
+	PreviewHostsAnnotationKey = "kourier.knative.dev/preview-hosts"
+
+	// GeneratedResourcesConfigMapAnnotationKey is the annotation key attached to an
+	// Ingress to write the resources generated for it to a ConfigMap next to it, if set
+	// to "true" and the generated resources ConfigMaps are enabled.
+	GeneratedResourcesConfigMapAnnotationKey = "kourier.knative.dev/generated-resources-configmap"
+
+	// ExternalNameTLSAnnotationKey is the annotation key attached to an Ingress to reach
+	// the backends of its ExternalName services via TLS, if set to "true".
+	ExternalNameTLSAnnotationKey = "kourier.knative.dev/external-name-tls"
+
+	// HostRedirectsAnnotationKey is the annotation key attached to an Ingress to
+	// permanently redirect the requests to the given hosts to other hosts, e.g. from an
+	// apex domain to its www subdomain, as comma separated "<from>=<to>" pairs.
+	HostRedirectsAnnotationKey = "kourier.knative.dev/host-redirects"
+
+	// HedgePerTryTimeoutAnnotationKey is the annotation key attached to an Ingress to
+	// race another attempt of its requests against the ones taking longer than the given
+	// duration, rather than cancelling them.
+	HedgePerTryTimeoutAnnotationKey = "kourier.knative.dev/hedge-per-try-timeout"
+
+	// HedgeNumRetriesAnnotationKey is the annotation key attached to an Ingress to
+	// specify the number of additional attempts of its hedged requests.
+	HedgeNumRetriesAnnotationKey = "kourier.knative.dev/hedge-num-retries"
+
+	// WebSocketOnlyPathsAnnotationKey is the annotation key attached to an Ingress to
+	// reject the requests to the given comma separated paths of it which aren't WebSocket
+	// upgrades.
+	WebSocketOnlyPathsAnnotationKey = "kourier.knative.dev/websocket-only-paths"
+
+	// WebSocketIdleTimeoutAnnotationKey is the annotation key attached to an Ingress to
+	// specify the idle timeout of the WebSockets of its WebSocket-only paths.
+	WebSocketIdleTimeoutAnnotationKey = "kourier.knative.dev/websocket-idle-timeout"
+
+	// DynamicForwardProxyAnnotationKey is the annotation key attached to an Ingress to
+	// proxy the requests to its ExternalName services via the dynamic forward proxy of
+	// the gateway, resolving their hosts on demand, if set to "true".
+	DynamicForwardProxyAnnotationKey = "kourier.knative.dev/dynamic-forward-proxy"
+
+	// WasmExtensionAnnotationKey is the annotation key attached to an Ingress to run the
+	// Wasm module of the given secret, in its namespace, on the requests to its hosts.
+	WasmExtensionAnnotationKey = "kourier.knative.dev/wasm-extension-secret"
+
+	// LuaFilterAnnotationKey is the annotation key attached to an Ingress to run the
+	// given Lua snippet on the requests to its hosts.
+	LuaFilterAnnotationKey = "kourier.knative.dev/lua-filter"
+
+	// AllowedSourceCIDRsAnnotationKey is the annotation key attached to an Ingress to
+	// only allow the requests to its hosts from the given comma-separated CIDRs.
+	AllowedSourceCIDRsAnnotationKey = "kourier.knative.dev/allowed-source-cidrs"
+
+	// DeniedSourceCIDRsAnnotationKey is the annotation key attached to an Ingress to deny
+	// the requests to its hosts from the given comma-separated CIDRs.
+	DeniedSourceCIDRsAnnotationKey = "kourier.knative.dev/denied-source-cidrs"
+
+	// SourceAddressAnnotationKey is the annotation key attached to an Ingress to specify
+	// which address of the requests its source CIDRs match: the address of the peer of
+	// the connection, "direct", or the address of the client forwarded by the trusted
+	// proxies in front of the gateways, "forwarded". Defaults to "direct".
+	SourceAddressAnnotationKey = "kourier.knative.dev/source-address"
+
+	// HostRewriteAnnotationKey is the annotation key attached to an Ingress to rewrite
+	// the host of the requests to its backends, overriding the rewritten hosts of its
+	// paths: "auto" rewrites it to the DNS name of the backend of ExternalName services,
+	// and "header:<name>" to the value of the given request header.
+	HostRewriteAnnotationKey = "kourier.knative.dev/host-rewrite"
+
+	// PrefixRewriteAnnotationKey is the annotation key attached to an Ingress to rewrite
+	// the matched prefix of the path of the requests to its backends to the given one.
+	PrefixRewriteAnnotationKey = "kourier.knative.dev/prefix-rewrite"
+
+	// RegexRewriteAnnotationKey is the annotation key attached to an Ingress to rewrite
+	// the path of the requests to its backends by substituting the matches of a regex,
+	// specified as JSON: {"pattern": "...", "substitution": "..."}.
+	RegexRewriteAnnotationKey = "kourier.knative.dev/regex-rewrite"
+
+	// RedirectAnnotationKey is the annotation key attached to an Ingress to redirect the
+	// requests to its hosts rather than routing them to its backends, specified as JSON,
+	// e.g. {"responseCode": 308, "host": "www.example.com", "prefix": "/v2/"}.
+	RedirectAnnotationKey = "kourier.knative.dev/redirect"
+
+	// MaintenanceAnnotationKey is the annotation key attached to an Ingress to respond to
+	// the requests to its paths directly rather than routing them to its backends,
+	// specified as JSON, e.g. {"statusCode": 503, "body": "Under maintenance"}.
+	MaintenanceAnnotationKey = "kourier.knative.dev/maintenance"
+
+	// DropRoutesAnnotationKey is the annotation key attached to an Ingress to reject the
+	// requests to some of its paths at the gateways, specified as a JSON list, e.g.
+	// [{"path": "/metrics", "match": "exact", "methods": ["GET"], "statusCode": 404}].
+	DropRoutesAnnotationKey = "kourier.knative.dev/drop-routes"
+
+	// KMSProviderAnnotationKey is the annotation key attached to a TLS Secret whose
+	// private key is wrapped by an external KMS. The value names the provider of the
+	// KMS, whose registered decrypter unwraps the key.
+	KMSProviderAnnotationKey = "kourier.knative.dev/kms-provider"
+
+	// KMSKeyAnnotationKey is the annotation key attached to a TLS Secret with the
+	// KMSProviderAnnotationKey annotation, identifying the key of the KMS wrapping its
+	// private key.
+	KMSKeyAnnotationKey = "kourier.knative.dev/kms-key"
+
+	// RoutesStatusAnnotationKey is the annotation key of the status of an Ingress telling
+	// the number of routes generated for it across its virtual hosts.
+	RoutesStatusAnnotationKey = "kourier.knative.dev/routes"
+
+	// ClustersStatusAnnotationKey is the annotation key of the status of an Ingress telling
+	// the number of clusters generated for it.
+	ClustersStatusAnnotationKey = "kourier.knative.dev/clusters"
+
+	// SNIMatchesStatusAnnotationKey is the annotation key of the status of an Ingress
+	// telling the number of SNI matches generated for its TLS hosts.
+	SNIMatchesStatusAnnotationKey = "kourier.knative.dev/sni-matches"
+
+	// AnnotationConflictsStatusAnnotationKey is the annotation key of the status of an
+	// Ingress telling the annotations of it which are ignored in favor of the ones taking
+	// precedence.
+	AnnotationConflictsStatusAnnotationKey = "kourier.knative.dev/annotation-conflicts"
+
+	// SnapshotVersionStatusAnnotationKey is the annotation key of the status of an Ingress
+	// telling the version of the snapshot its config was pushed to the gateways in.
+	SnapshotVersionStatusAnnotationKey = "kourier.knative.dev/snapshot-version"
+
+	// TrustBundleLabelKey is the label key of ConfigMaps, in the serving namespace, holding
+	// additional CA certificates to verify upstreams with when internal encryption is enabled.
+	// Only ConfigMaps with the label set to "true" are considered.
+	TrustBundleLabelKey = "networking.knative.dev/trust-bundle"
+
+	// InternalAccessLabelKey is the label key of namespaces whose pods are allowed to reach
+	// the internal listeners of the gateways, if they're restricted by a NetworkPolicy.
+	// Only namespaces with the label set to "true" are considered.
+	InternalAccessLabelKey = "kourier.knative.dev/internal-access"
+
+	// GeneratedResourcesLabelKey is the label key of the ConfigMaps holding the resources
+	// generated for an Ingress, set to the name of the Ingress.
+	GeneratedResourcesLabelKey = "kourier.knative.dev/generated-resources"
 )
 
 var disableHTTP2Annotation = kmap.KeyPriority{
-	disableHTTP2AnnotationKey,
+	DisableHTTP2AnnotationKey,
+}
+
+var headerMatchAnnotation = kmap.KeyPriority{
+	HeaderMatchAnnotationKey,
+}
+
+var queryParamMatchAnnotation = kmap.KeyPriority{
+	QueryParamMatchAnnotationKey,
+}
+
+var transformAnnotation = kmap.KeyPriority{
+	TransformAnnotationKey,
+}
+
+var clientCASecretAnnotation = kmap.KeyPriority{
+	ClientCASecretAnnotationKey,
+}
+
+var clientAllowedSANsAnnotation = kmap.KeyPriority{
+	ClientAllowedSANsAnnotationKey,
+}
+
+var listenerPoolAnnotation = kmap.KeyPriority{
+	ListenerPoolAnnotationKey,
+}
+
+var gatewayFleetAnnotation = kmap.KeyPriority{
+	GatewayFleetAnnotationKey,
+}
+
+var stickyCanaryTTLAnnotation = kmap.KeyPriority{
+	StickyCanaryTTLAnnotationKey,
+}
+
+var tlsPassthroughAnnotation = kmap.KeyPriority{
+	TLSPassthroughAnnotationKey,
+}
+
+var grpcJSONTranscoderAnnotation = kmap.KeyPriority{
+	GRPCJSONTranscoderAnnotationKey,
+}
+
+var maxRequestsAnnotation = kmap.KeyPriority{
+	MaxRequestsAnnotationKey,
+}
+
+var upgradesAnnotation = kmap.KeyPriority{
+	UpgradesAnnotationKey,
+}
+
+var idleTimeoutAnnotation = kmap.KeyPriority{
+	IdleTimeoutAnnotationKey,
+}
+
+var maxStreamDurationAnnotation = kmap.KeyPriority{
+	MaxStreamDurationAnnotationKey,
+}
+
+var upstreamConnectionIdleTimeoutAnnotation = kmap.KeyPriority{
+	UpstreamConnectionIdleTimeoutAnnotationKey,
+}
+
+var upstreamHTTP2MaxConcurrentStreamsAnnotation = kmap.KeyPriority{
+	UpstreamHTTP2MaxConcurrentStreamsAnnotationKey,
+}
+
+var upstreamHTTP2InitialStreamWindowSizeAnnotation = kmap.KeyPriority{
+	UpstreamHTTP2InitialStreamWindowSizeAnnotationKey,
+}
+
+var upstreamHTTP2InitialConnectionWindowSizeAnnotation = kmap.KeyPriority{
+	UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey,
+}
+
+var upstreamHTTP1EnableTrailersAnnotation = kmap.KeyPriority{
+	UpstreamHTTP1EnableTrailersAnnotationKey,
+}
+
+var upstreamConnectTimeoutAnnotation = kmap.KeyPriority{
+	UpstreamConnectTimeoutAnnotationKey,
+}
+
+var upstreamTCPKeepaliveTimeAnnotation = kmap.KeyPriority{
+	UpstreamTCPKeepaliveTimeAnnotationKey,
+}
+
+var maxRequestBodyBytesAnnotation = kmap.KeyPriority{
+	MaxRequestBodyBytesAnnotationKey,
+}
+
+var scheduleWindowsAnnotation = kmap.KeyPriority{
+	ScheduleWindowsAnnotationKey,
+}
+
+var scheduleBackendsAnnotation = kmap.KeyPriority{
+	ScheduleBackendsAnnotationKey,
+}
+
+var externalNameHealthCheckAnnotation = kmap.KeyPriority{
+	ExternalNameHealthCheckAnnotationKey,
+}
+
+var externalNameHealthCheckIntervalAnnotation = kmap.KeyPriority{
+	ExternalNameHealthCheckIntervalAnnotationKey,
+}
+
+var routeTTLAnnotation = kmap.KeyPriority{
+	RouteTTLAnnotationKey,
+}
+
+var previewHostsAnnotation = kmap.KeyPriority{
+	PreviewHostsAnnotationKey,
+}
+
+var generatedResourcesConfigMapAnnotation = kmap.KeyPriority{
+	GeneratedResourcesConfigMapAnnotationKey,
+}
+
+var externalNameTLSAnnotation = kmap.KeyPriority{
+	ExternalNameTLSAnnotationKey,
+}
+
+var hostRedirectsAnnotation = kmap.KeyPriority{
+	HostRedirectsAnnotationKey,
+}
+
+var hedgePerTryTimeoutAnnotation = kmap.KeyPriority{
+	HedgePerTryTimeoutAnnotationKey,
+}
+
+var hedgeNumRetriesAnnotation = kmap.KeyPriority{
+	HedgeNumRetriesAnnotationKey,
+}
+
+var webSocketOnlyPathsAnnotation = kmap.KeyPriority{
+	WebSocketOnlyPathsAnnotationKey,
+}
+
+var webSocketIdleTimeoutAnnotation = kmap.KeyPriority{
+	WebSocketIdleTimeoutAnnotationKey,
+}
+
+var dynamicForwardProxyAnnotation = kmap.KeyPriority{
+	DynamicForwardProxyAnnotationKey,
+}
+
+var wasmExtensionAnnotation = kmap.KeyPriority{
+	WasmExtensionAnnotationKey,
+}
+
+var luaFilterAnnotation = kmap.KeyPriority{
+	LuaFilterAnnotationKey,
+}
+
+var allowedSourceCIDRsAnnotation = kmap.KeyPriority{
+	AllowedSourceCIDRsAnnotationKey,
+}
+
+var deniedSourceCIDRsAnnotation = kmap.KeyPriority{
+	DeniedSourceCIDRsAnnotationKey,
+}
+
+var sourceAddressAnnotation = kmap.KeyPriority{
+	SourceAddressAnnotationKey,
+}
+
+var hostRewriteAnnotation = kmap.KeyPriority{
+	HostRewriteAnnotationKey,
+}
+
+var prefixRewriteAnnotation = kmap.KeyPriority{
+	PrefixRewriteAnnotationKey,
+}
+
+var regexRewriteAnnotation = kmap.KeyPriority{
+	RegexRewriteAnnotationKey,
+}
+
+var redirectAnnotation = kmap.KeyPriority{
+	RedirectAnnotationKey,
+}
+
+var maintenanceAnnotation = kmap.KeyPriority{
+	MaintenanceAnnotationKey,
+}
+
+var dropRoutesAnnotation = kmap.KeyPriority{
+	DropRoutesAnnotationKey,
+}
+
+// reservedListenerPorts are the ports of the gateway's own listeners, which can't be
+// used as listener ports for traffic isolation.
+var reservedListenerPorts = map[uint32]struct{}{
+	HTTPPortExternal:  {},
+	HTTPPortInternal:  {},
+	HTTPSPortExternal: {},
+	HTTPSPortInternal: {},
+	HTTPPortProb:      {},
+	HTTPSPortProb:     {},
+	HTTPPortStats:     {},
+}
+
+// ParseListenerPort parses the value of the ListenerPortAnnotationKey annotation. It has
+// to be a valid port which is not used by the gateway's own listeners.
+func ParseListenerPort(value string) (uint32, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("%q is not a number between 1 and 65535", value)
+	}
+	if _, ok := reservedListenerPorts[uint32(port)]; ok {
+		return 0, fmt.Errorf("port %d is reserved for the gateway's own listeners", port)
+	}
+	return uint32(port), nil
 }
 
 // ServiceHostnames returns the external and internal service's respective hostname.
@@ -111,7 +655,276 @@ func ServingNamespace() string {
 	return namespace
 }
 
+// GeneratedResourcesConfigMapName returns the name of the ConfigMap holding the resources
+// generated for the Ingress of the given name.
+func GeneratedResourcesConfigMapName(ingressName string) string {
+	return ingressName + "-kourier-resources"
+}
+
 // GetDisableHTTP2 specifies whether http2 is going to be disabled
 func GetDisableHTTP2(annotations map[string]string) (val string) {
 	return disableHTTP2Annotation.Value(annotations)
 }
+
+// GetHeaderMatch returns the raw header matchers specified on the annotations.
+func GetHeaderMatch(annotations map[string]string) string {
+	return headerMatchAnnotation.Value(annotations)
+}
+
+// GetQueryParamMatch returns the raw query parameter matchers specified on the annotations.
+func GetQueryParamMatch(annotations map[string]string) string {
+	return queryParamMatchAnnotation.Value(annotations)
+}
+
+// GetTransform returns the raw request transformations specified on the annotations.
+func GetTransform(annotations map[string]string) string {
+	return transformAnnotation.Value(annotations)
+}
+
+// GetClientCASecret returns the name of the client CA secret specified on the annotations.
+func GetClientCASecret(annotations map[string]string) string {
+	return clientCASecretAnnotation.Value(annotations)
+}
+
+// GetClientAllowedSANs returns the raw allowed client SANs specified on the annotations.
+func GetClientAllowedSANs(annotations map[string]string) string {
+	return clientAllowedSANsAnnotation.Value(annotations)
+}
+
+// GetListenerPool returns the name of the listener pool specified on the annotations.
+func GetListenerPool(annotations map[string]string) string {
+	return listenerPoolAnnotation.Value(annotations)
+}
+
+// GetGatewayFleet returns the name of the gateway fleet specified on the given labels or
+// annotations.
+func GetGatewayFleet(annotations map[string]string) string {
+	return gatewayFleetAnnotation.Value(annotations)
+}
+
+// GetStickyCanaryTTL returns the raw sticky canary duration specified on the annotations.
+func GetStickyCanaryTTL(annotations map[string]string) string {
+	return stickyCanaryTTLAnnotation.Value(annotations)
+}
+
+// GetTLSPassthrough returns the raw TLS passthrough setting specified on the annotations.
+func GetTLSPassthrough(annotations map[string]string) string {
+	return tlsPassthroughAnnotation.Value(annotations)
+}
+
+// GetGRPCJSONTranscoder returns the name of the secret holding the protobuf descriptor
+// set specified on the annotations.
+func GetGRPCJSONTranscoder(annotations map[string]string) string {
+	return grpcJSONTranscoderAnnotation.Value(annotations)
+}
+
+// GetMaxRequests returns the raw concurrent requests cap specified on the annotations.
+func GetMaxRequests(annotations map[string]string) string {
+	return maxRequestsAnnotation.Value(annotations)
+}
+
+// GetUpgrades returns the raw upgrades specified on the annotations.
+func GetUpgrades(annotations map[string]string) string {
+	return upgradesAnnotation.Value(annotations)
+}
+
+// GetIdleTimeout returns the raw idle timeout specified on the annotations.
+func GetIdleTimeout(annotations map[string]string) string {
+	return idleTimeoutAnnotation.Value(annotations)
+}
+
+// GetMaxStreamDuration returns the raw maximum stream duration specified on the
+// annotations.
+func GetMaxStreamDuration(annotations map[string]string) string {
+	return maxStreamDurationAnnotation.Value(annotations)
+}
+
+// GetUpstreamConnectionIdleTimeout returns the raw idle timeout of the upstream
+// connections specified on the annotations.
+func GetUpstreamConnectionIdleTimeout(annotations map[string]string) string {
+	return upstreamConnectionIdleTimeoutAnnotation.Value(annotations)
+}
+
+// GetUpstreamHTTP2MaxConcurrentStreams returns the raw maximum number of concurrent
+// streams per upstream HTTP/2 connection specified on the annotations.
+func GetUpstreamHTTP2MaxConcurrentStreams(annotations map[string]string) string {
+	return upstreamHTTP2MaxConcurrentStreamsAnnotation.Value(annotations)
+}
+
+// GetUpstreamHTTP2InitialStreamWindowSize returns the raw initial stream window size of
+// the upstream HTTP/2 connections specified on the annotations.
+func GetUpstreamHTTP2InitialStreamWindowSize(annotations map[string]string) string {
+	return upstreamHTTP2InitialStreamWindowSizeAnnotation.Value(annotations)
+}
+
+// GetUpstreamHTTP2InitialConnectionWindowSize returns the raw initial connection window
+// size of the upstream HTTP/2 connections specified on the annotations.
+func GetUpstreamHTTP2InitialConnectionWindowSize(annotations map[string]string) string {
+	return upstreamHTTP2InitialConnectionWindowSizeAnnotation.Value(annotations)
+}
+
+// GetUpstreamHTTP1EnableTrailers returns whether the upstream HTTP/1 connections carry
+// trailers as specified on the annotations.
+func GetUpstreamHTTP1EnableTrailers(annotations map[string]string) string {
+	return upstreamHTTP1EnableTrailersAnnotation.Value(annotations)
+}
+
+// GetUpstreamConnectTimeout returns the raw connect timeout of the upstream connections
+// specified on the annotations.
+func GetUpstreamConnectTimeout(annotations map[string]string) string {
+	return upstreamConnectTimeoutAnnotation.Value(annotations)
+}
+
+// GetUpstreamTCPKeepaliveTime returns the raw TCP keepalive time of the upstream
+// connections specified on the annotations.
+func GetUpstreamTCPKeepaliveTime(annotations map[string]string) string {
+	return upstreamTCPKeepaliveTimeAnnotation.Value(annotations)
+}
+
+// GetMaxRequestBodyBytes returns the raw maximum request body size specified on the
+// annotations.
+func GetMaxRequestBodyBytes(annotations map[string]string) string {
+	return maxRequestBodyBytesAnnotation.Value(annotations)
+}
+
+// GetScheduleWindows returns the raw schedule windows specified on the annotations.
+func GetScheduleWindows(annotations map[string]string) string {
+	return scheduleWindowsAnnotation.Value(annotations)
+}
+
+// GetScheduleBackends returns the raw schedule backends specified on the annotations.
+func GetScheduleBackends(annotations map[string]string) string {
+	return scheduleBackendsAnnotation.Value(annotations)
+}
+
+// GetExternalNameHealthCheck returns the raw health check of the ExternalName services
+// specified on the annotations.
+func GetExternalNameHealthCheck(annotations map[string]string) string {
+	return externalNameHealthCheckAnnotation.Value(annotations)
+}
+
+// GetExternalNameHealthCheckInterval returns the raw interval of the health checks of the
+// ExternalName services specified on the annotations.
+func GetExternalNameHealthCheckInterval(annotations map[string]string) string {
+	return externalNameHealthCheckIntervalAnnotation.Value(annotations)
+}
+
+// GetRouteTTL returns the raw TTL of the routes of the Ingress with the given
+// annotations.
+func GetRouteTTL(annotations map[string]string) string {
+	return routeTTLAnnotation.Value(annotations)
+}
+
+// GetPreviewHosts returns the raw preview hosts setting specified on the annotations.
+func GetPreviewHosts(annotations map[string]string) string {
+	return previewHostsAnnotation.Value(annotations)
+}
+
+// GetGeneratedResourcesConfigMap returns whether the resources generated for the Ingress
+// are to be written to a ConfigMap, as specified on the annotations.
+func GetGeneratedResourcesConfigMap(annotations map[string]string) string {
+	return generatedResourcesConfigMapAnnotation.Value(annotations)
+}
+
+// GetExternalNameTLS returns the raw ExternalName TLS setting specified on the
+// annotations.
+func GetExternalNameTLS(annotations map[string]string) string {
+	return externalNameTLSAnnotation.Value(annotations)
+}
+
+// GetHostRedirects returns the raw host redirects specified on the annotations.
+func GetHostRedirects(annotations map[string]string) string {
+	return hostRedirectsAnnotation.Value(annotations)
+}
+
+// GetHedgePerTryTimeout returns the raw per try timeout of the hedged requests specified
+// on the annotations.
+func GetHedgePerTryTimeout(annotations map[string]string) string {
+	return hedgePerTryTimeoutAnnotation.Value(annotations)
+}
+
+// GetHedgeNumRetries returns the raw number of additional attempts of the hedged requests
+// specified on the annotations.
+func GetHedgeNumRetries(annotations map[string]string) string {
+	return hedgeNumRetriesAnnotation.Value(annotations)
+}
+
+// GetWebSocketOnlyPaths returns the raw WebSocket-only paths specified on the annotations.
+func GetWebSocketOnlyPaths(annotations map[string]string) string {
+	return webSocketOnlyPathsAnnotation.Value(annotations)
+}
+
+// GetWebSocketIdleTimeout returns the raw idle timeout of the WebSockets of the
+// WebSocket-only paths specified on the annotations.
+func GetWebSocketIdleTimeout(annotations map[string]string) string {
+	return webSocketIdleTimeoutAnnotation.Value(annotations)
+}
+
+// GetDynamicForwardProxy returns the raw dynamic forward proxy setting specified on the
+// annotations.
+func GetDynamicForwardProxy(annotations map[string]string) string {
+	return dynamicForwardProxyAnnotation.Value(annotations)
+}
+
+// GetWasmExtension returns the name of the secret holding the Wasm module to run on the
+// requests, specified on the annotations.
+func GetWasmExtension(annotations map[string]string) string {
+	return wasmExtensionAnnotation.Value(annotations)
+}
+
+// GetLuaFilter returns the Lua snippet to run on the requests, specified on the
+// annotations.
+func GetLuaFilter(annotations map[string]string) string {
+	return luaFilterAnnotation.Value(annotations)
+}
+
+// GetAllowedSourceCIDRs returns the comma-separated CIDRs the requests are allowed
+// from, specified on the annotations.
+func GetAllowedSourceCIDRs(annotations map[string]string) string {
+	return allowedSourceCIDRsAnnotation.Value(annotations)
+}
+
+// GetDeniedSourceCIDRs returns the comma-separated CIDRs the requests are denied from,
+// specified on the annotations.
+func GetDeniedSourceCIDRs(annotations map[string]string) string {
+	return deniedSourceCIDRsAnnotation.Value(annotations)
+}
+
+// GetSourceAddress returns which address of the requests the source CIDRs match,
+// specified on the annotations.
+func GetSourceAddress(annotations map[string]string) string {
+	return sourceAddressAnnotation.Value(annotations)
+}
+
+// GetHostRewrite returns how the host of the requests to the backends is rewritten,
+// specified on the annotations.
+func GetHostRewrite(annotations map[string]string) string {
+	return hostRewriteAnnotation.Value(annotations)
+}
+
+// GetPrefixRewrite returns the prefix the matched prefix of the path of the requests is
+// rewritten to, specified on the annotations.
+func GetPrefixRewrite(annotations map[string]string) string {
+	return prefixRewriteAnnotation.Value(annotations)
+}
+
+// GetRegexRewrite returns the regex rewrite of the path of the requests, specified on
+// the annotations.
+func GetRegexRewrite(annotations map[string]string) string {
+	return regexRewriteAnnotation.Value(annotations)
+}
+
+// GetRedirect returns the raw redirect of the requests specified on the annotations.
+func GetRedirect(annotations map[string]string) string {
+	return redirectAnnotation.Value(annotations)
+}
+
+// GetMaintenance returns the raw maintenance mode specified on the annotations.
+func GetMaintenance(annotations map[string]string) string {
+	return maintenanceAnnotation.Value(annotations)
+}
+
+// GetDropRoutes returns the raw dropped routes specified on the annotations.
+func GetDropRoutes(annotations map[string]string) string {
+	return dropRoutesAnnotation.Value(annotations)
+}
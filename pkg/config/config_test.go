@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "testing"
+
+func TestParseListenerPort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint32
+		wantErr bool
+	}{
+		{value: "1", want: 1},
+		{value: "8888", want: 8888},
+		{value: "65535", want: 65535},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "65536", wantErr: true},
+		{value: "8080", wantErr: true},
+		{value: "8443", wantErr: true},
+		{value: "9000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseListenerPort(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseListenerPort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseListenerPort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,9 +17,18 @@ limitations under the License.
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	cm "knative.dev/pkg/configmap"
 )
@@ -27,6 +36,18 @@ import (
 // TrafficIsolationType is the type for traffic isolation configuration
 type TrafficIsolationType string
 
+// UpstreamSANValidationType is the type for the validation of the SANs of upstream
+// certificates.
+type UpstreamSANValidationType string
+
+// ProfileType is the type for the deployment profiles setting the defaults of a set of
+// config map keys at once.
+type ProfileType string
+
+// ExternalNameDNSFailureType is the type for the behavior of the clusters of
+// ExternalName services while their names don't resolve.
+type ExternalNameDNSFailureType string
+
 const (
 	// ConfigName is the name of config map for Kourier.
 	ConfigName = "config-kourier"
@@ -50,8 +71,486 @@ const (
 
 	// IsolationIngressPort if the config map value enabling port-level traffic isolation
 	IsolationIngressPort TrafficIsolationType = "port"
+
+	// transformationWasmModule is the config map key for the path of the request
+	// transformation Wasm module on the gateway.
+	transformationWasmModule = "transformation-wasm-module"
+
+	// defaultCertificateSecret is the config map key for the secret holding the certificate
+	// served for external hosts which are not covered by the TLS settings of their Ingress.
+	defaultCertificateSecret = "default-certificate-secret"
+
+	// stripQueryParameters is the config map key for removing all query parameters
+	// before routes and external authorization are evaluated.
+	stripQueryParameters = "strip-query-parameters"
+
+	// maxQueryParameters is the config map key for the maximum number of query parameters
+	// kept while routes and external authorization are evaluated.
+	maxQueryParameters = "max-query-parameters"
+
+	// rewriteHostInternalEncryption is the config map key for encrypting the traffic to
+	// kourier-internal of Ingresses with RewriteHost when internal encryption is enabled.
+	rewriteHostInternalEncryption = "rewrite-host-internal-encryption"
+
+	// tlsMinProtocolVersion is the config map key for the minimum TLS version of the
+	// external HTTPS listener.
+	tlsMinProtocolVersion = "tls-min-protocol-version"
+
+	// tlsMaxProtocolVersion is the config map key for the maximum TLS version of the
+	// external HTTPS listener.
+	tlsMaxProtocolVersion = "tls-max-protocol-version"
+
+	// tlsCipherSuites is the config map key for the comma separated cipher suites
+	// allowed by the external HTTPS listener.
+	tlsCipherSuites = "tls-cipher-suites"
+
+	// tlsECDHCurves is the config map key for the comma separated ECDH curves allowed
+	// by the external HTTPS listener.
+	tlsECDHCurves = "tls-ecdh-curves"
+
+	// clientCASecret is the config map key for the secret holding the CA bundle client
+	// certificates are required and validated against on the external HTTPS listener.
+	clientCASecret = "client-ca-secret"
+
+	// clientAllowedSANs is the config map key for the comma separated SANs of the
+	// accepted client certificates.
+	clientAllowedSANs = "client-allowed-sans"
+
+	// upstreamHTTP2MaxConcurrentStreams is the config map key for the maximum number of
+	// concurrent streams per HTTP/2 connection to upstreams.
+	upstreamHTTP2MaxConcurrentStreams = "upstream-http2-max-concurrent-streams"
+
+	// upstreamHTTP2InitialStreamWindowSize is the config map key for the initial stream
+	// flow-control window size of HTTP/2 connections to upstreams.
+	upstreamHTTP2InitialStreamWindowSize = "upstream-http2-initial-stream-window-size"
+
+	// upstreamHTTP2InitialConnectionWindowSize is the config map key for the initial
+	// connection flow-control window size of HTTP/2 connections to upstreams.
+	upstreamHTTP2InitialConnectionWindowSize = "upstream-http2-initial-connection-window-size"
+
+	// upstreamHTTP1EnableTrailers is the config map key for passing the trailers of the
+	// HTTP/1 requests on to upstreams.
+	upstreamHTTP1EnableTrailers = "upstream-http1-enable-trailers"
+
+	// upstreamALPNNegotiation is the config map key for negotiating the protocol of the
+	// encrypted connections to upstreams via ALPN.
+	upstreamALPNNegotiation = "upstream-alpn-negotiation"
+
+	// spiffeWorkloadAPISocket is the config map key for the path of the SPIFFE Workload
+	// API socket to obtain the certificates for upstream TLS from.
+	spiffeWorkloadAPISocket = "spiffe-workload-api-socket"
+
+	// spiffeUpstreamSANPatterns is the config map key for the comma separated SAN
+	// patterns the upstreams' certificates are verified against when using SPIFFE.
+	spiffeUpstreamSANPatterns = "spiffe-upstream-san-patterns"
+
+	// upstreamClientCertificateSecret is the config map key for the secret holding the
+	// client certificate presented to upstreams when internal encryption is enabled.
+	upstreamClientCertificateSecret = "upstream-client-certificate-secret"
+
+	// listenerPools is the config map key for the JSON encoded listener pools, keyed by
+	// their name, Ingresses can be assigned to.
+	listenerPools = "listener-pools"
+
+	// upstreamSANValidation is the config map key for how the SANs of the certificates of
+	// upstreams are validated when internal encryption is enabled.
+	upstreamSANValidation = "upstream-san-validation"
+
+	// upstreamRequestBudget is the config map key for the time budget of the requests to
+	// upstreams, which is stamped on them along with their attempt count.
+	upstreamRequestBudget = "upstream-request-budget"
+
+	// stripHostPort is the config map key for stripping the port from the host before
+	// routes are matched, instead of matching every host with any port.
+	stripHostPort = "strip-host-port"
+
+	// snapshotDebounceWindow is the config map key for the window within which the
+	// updates of the gateways' config are coalesced into a single push.
+	snapshotDebounceWindow = "snapshot-debounce-window"
+
+	// mergeSharedHosts is the config map key for allowing ingresses to share hosts as
+	// long as their paths don't collide.
+	mergeSharedHosts = "merge-shared-hosts"
+
+	// virtualHostDiscovery is the config map key for serving the external virtual hosts
+	// on demand via VHDS instead of embedding them into the route configurations.
+	virtualHostDiscovery = "virtual-host-discovery"
+
+	// activatorFailover is the config map key for failing over from the endpoints of a
+	// revision to the activator within the gateways.
+	activatorFailover = "activator-failover"
+
+	// gatewayFleets is the config map key for the JSON encoded gateway fleets, keyed by
+	// their name, Ingresses can be assigned to.
+	gatewayFleets = "gateway-fleets"
+
+	// endpointHealthStatus is the config map key for passing the readiness of the pods
+	// behind the endpoints on to the gateways as their health status.
+	endpointHealthStatus = "endpoint-health-status"
+
+	// upstreamPreconnectRatio is the config map key for the number of connections
+	// anticipated per upstream for each request, which are established ahead of time.
+	upstreamPreconnectRatio = "upstream-preconnect-ratio"
+
+	// upstreamPredictivePreconnectRatio is the config map key for the number of
+	// connections anticipated across the upstreams of a cluster for each request, which
+	// are established ahead of time.
+	upstreamPredictivePreconnectRatio = "upstream-predictive-preconnect-ratio"
+
+	// internalTrafficPriority is the config map key for routing the traffic of the
+	// internal listeners with a higher priority than the traffic of the external ones.
+	internalTrafficPriority = "internal-traffic-priority"
+
+	// externalMaxRequests is the config map key for the maximum number of parallel
+	// requests to each upstream cluster of the traffic routed with the default priority.
+	externalMaxRequests = "external-max-requests"
+
+	// internalMaxRequests is the config map key for the maximum number of parallel
+	// requests to each upstream cluster of the internal traffic routed with a higher
+	// priority.
+	internalMaxRequests = "internal-max-requests"
+
+	// grpcWeb is the config map key for translating the gRPC-Web requests of browsers
+	// into gRPC requests on the gateways.
+	grpcWeb = "grpc-web"
+
+	// scannerMitigation is the config map key for rejecting the requests of scanners on
+	// the gateways.
+	scannerMitigation = "scanner-mitigation"
+
+	// scannerDenyPaths is the config map key for the comma separated path prefixes of the
+	// requests rejected as coming from scanners.
+	scannerDenyPaths = "scanner-deny-paths"
+
+	// scannerDenyUserAgents is the config map key for the comma separated user agent
+	// substrings of the requests rejected as coming from scanners.
+	scannerDenyUserAgents = "scanner-deny-user-agents"
+
+	// scannerRateLimit is the config map key for the maximum number of requests per second
+	// of every client connection when rejecting the requests of scanners.
+	scannerRateLimit = "scanner-rate-limit"
+
+	// grpcJSONTranscoding is the config map key for transcoding the JSON requests to the
+	// hosts of Ingresses into gRPC requests on the gateways.
+	grpcJSONTranscoding = "grpc-json-transcoding"
+
+	// gatewayErrorHeader is the config map key for adding a header telling what went
+	// wrong to the errors generated by the gateways.
+	gatewayErrorHeader = "gateway-error-header"
+
+	// maxStreamDuration is the config map key for the maximum duration of the streams of
+	// the gateways' listeners.
+	maxStreamDuration = "max-stream-duration"
+
+	// connectionIdleTimeout is the config map key for the idle timeout of the client
+	// connections of the gateways' listeners.
+	connectionIdleTimeout = "connection-idle-timeout"
+
+	// upstreamMaxStreamDuration is the config map key for the maximum duration of the
+	// streams to upstreams.
+	upstreamMaxStreamDuration = "upstream-max-stream-duration"
+
+	// upstreamConnectionIdleTimeout is the config map key for the idle timeout of the
+	// connections to upstreams.
+	upstreamConnectionIdleTimeout = "upstream-connection-idle-timeout"
+
+	// maxRequestHeadersKB is the config map key for the maximum size of the headers of
+	// the requests to the gateways, in KiB.
+	maxRequestHeadersKB = "max-request-headers-kb"
+
+	// maxRequestBodyBytes is the config map key for the maximum size of the bodies of the
+	// requests to the gateways, which are buffered up to that size.
+	maxRequestBodyBytes = "max-request-body-bytes"
+
+	// unmatchedHostStatus is the config map key for the status of the responses to the
+	// requests matching no Ingress.
+	unmatchedHostStatus = "unmatched-host-status"
+
+	// unmatchedHostBody is the config map key for the body of the responses to the
+	// requests matching no Ingress.
+	unmatchedHostBody = "unmatched-host-body"
+
+	// unmatchedHostContentType is the config map key for the content type of the body of
+	// the responses to the requests matching no Ingress.
+	unmatchedHostContentType = "unmatched-host-content-type"
+
+	// unmatchedHostRedirect is the config map key for the URL the requests matching no
+	// Ingress are redirected to.
+	unmatchedHostRedirect = "unmatched-host-redirect"
+
+	// headerMetadata is the config map key for the headers of the external requests
+	// extracted into dynamic metadata, like "x-tenant-id=tenant".
+	headerMetadata = "header-metadata"
+
+	// errorPageBody is the config map key for the body format of the 5xx responses
+	// generated by the external listeners.
+	errorPageBody = "error-page-body"
+
+	// errorPageContentType is the config map key for the content type of errorPageBody.
+	errorPageContentType = "error-page-content-type"
+
+	// internalErrorPageBody is the config map key for the body format of the 5xx
+	// responses generated by the cluster-local listeners.
+	internalErrorPageBody = "internal-error-page-body"
+
+	// internalErrorPageContentType is the config map key for the content type of
+	// internalErrorPageBody.
+	internalErrorPageContentType = "internal-error-page-content-type"
+
+	// endpointDraining is the config map key for passing the not ready addresses of the
+	// endpoints on to the gateways as draining.
+	endpointDraining = "endpoint-draining"
+
+	// snapshotWatchdogDeadline is the config map key for the deadline within which the
+	// gateways have to pick up the snapshots published for them, before they're
+	// published again.
+	snapshotWatchdogDeadline = "snapshot-watchdog-deadline"
+
+	// internalNetworkPolicy is the config map key for restricting the namespaces that can
+	// reach the internal listeners of the gateways via a NetworkPolicy.
+	internalNetworkPolicy = "internal-network-policy"
+
+	// upstreamConnectTimeout is the config map key for the timeout of establishing the
+	// connections to upstreams.
+	upstreamConnectTimeout = "upstream-connect-timeout"
+
+	// upstreamTCPKeepaliveTime is the config map key for the time the connections to
+	// upstreams are idle before TCP keepalive probes are sent.
+	upstreamTCPKeepaliveTime = "upstream-tcp-keepalive-time"
+
+	// upstreamTCPKeepaliveInterval is the config map key for the interval between the TCP
+	// keepalive probes of the connections to upstreams.
+	upstreamTCPKeepaliveInterval = "upstream-tcp-keepalive-interval"
+
+	// upstreamTCPKeepaliveProbes is the config map key for the number of unanswered TCP
+	// keepalive probes after which the connections to upstreams are dropped.
+	upstreamTCPKeepaliveProbes = "upstream-tcp-keepalive-probes"
+
+	// requestTimeout is the config map key for the time within which the clients have to
+	// send their complete requests to the gateways.
+	requestTimeout = "request-timeout"
+
+	// requestHeadersTimeout is the config map key for the time within which the clients
+	// have to send the headers of their requests to the gateways.
+	requestHeadersTimeout = "request-headers-timeout"
+
+	// internalUpgradeProtection is the config map key for restricting the upgrades
+	// accepted by the internal listeners of the gateways.
+	internalUpgradeProtection = "internal-upgrade-protection"
+
+	// internalAllowedUpgrades is the config map key for the upgrades accepted by the
+	// internal listeners of the gateways with internalUpgradeProtection enabled.
+	internalAllowedUpgrades = "internal-allowed-upgrades"
+
+	// generatedResourcesConfigMaps is the config map key for writing the resources
+	// generated for each Ingress to a ConfigMap next to it.
+	generatedResourcesConfigMaps = "generated-resources-configmaps"
+
+	// canaryOverrideHeader is the config map key for the name of the header pinning
+	// requests to one of the splits of a path.
+	canaryOverrideHeader = "canary-override-header"
+
+	// retryAfterMaxDelay is the config map key for the longest Retry-After delay the
+	// gateways wait for before retrying a request once.
+	retryAfterMaxDelay = "retry-after-max-delay"
+
+	// snapshotDiffLogging is the config map key for logging the difference of each
+	// snapshot pushed to the gateways to the previous one.
+	snapshotDiffLogging = "snapshot-diff-logging"
+
+	// ingressStatsTags is the config map key for emitting the stats of the routes of
+	// each ingress under a prefix naming it.
+	ingressStatsTags = "ingress-stats-tags"
+
+	// profile is the config map key for the deployment profile setting the defaults of
+	// the keys it's made of.
+	profile = "profile"
+
+	// ProfileBehindEdge is the config map value of the profile of gateways which are the
+	// second hop behind another proxy terminating TLS and redirecting to HTTPS.
+	ProfileBehindEdge ProfileType = "behind-edge"
+
+	// xffNumTrustedHops is the config map key for the number of proxies in front of the
+	// gateways whose X-Forwarded-For entries are trusted.
+	xffNumTrustedHops = "xff-num-trusted-hops"
+
+	// disableTLSTermination is the config map key for ignoring the TLS settings of the
+	// Ingresses, and not serving any HTTPS listener.
+	disableTLSTermination = "disable-tls-termination"
+
+	// disableHTTPRedirects is the config map key for not redirecting plain HTTP requests to
+	// HTTPS, regardless of the HTTP option of the Ingresses.
+	disableHTTPRedirects = "disable-http-redirects"
+
+	// httpsPlaintextFallback is the config map key for serving the plaintext HTTP
+	// connections to the external HTTPS listener rather than failing them.
+	httpsPlaintextFallback = "https-plaintext-fallback"
+
+	// statusProbeConcurrency is the config map key for the number of readiness probes of
+	// the Ingresses issued simultaneously.
+	statusProbeConcurrency = "status-probe-concurrency"
+
+	// statusProbeTimeout is the config map key for the timeout of a readiness probe.
+	statusProbeTimeout = "status-probe-timeout"
+
+	// statusProbeBackoffBase and statusProbeBackoffMax are the config map keys for the
+	// initial and maximum delays before retrying a failed readiness probe.
+	statusProbeBackoffBase = "status-probe-backoff-base"
+	statusProbeBackoffMax  = "status-probe-backoff-max"
+
+	// statusProbeHTTPPort and statusProbeHTTPSPort are the config map keys for the ports of
+	// the gateway pods the external hosts are probed at, over HTTP and HTTPS.
+	statusProbeHTTPPort  = "status-probe-http-port"
+	statusProbeHTTPSPort = "status-probe-https-port"
+
+	// policyRouteStats is the config map key for emitting the stats of the redirects and
+	// direct responses of the Ingresses under a prefix naming them.
+	policyRouteStats = "policy-route-stats"
+
+	// externalNameDNSRefreshRate and externalNameDNSFailureRefreshRate are the config map
+	// keys for how often the names of the ExternalName services are resolved again, once
+	// resolved and while their resolution fails.
+	externalNameDNSRefreshRate        = "external-name-dns-refresh-rate"
+	externalNameDNSFailureRefreshRate = "external-name-dns-failure-refresh-rate"
+
+	// externalNameDNSFailure is the config map key for the behavior of the clusters of
+	// ExternalName services while their names don't resolve.
+	externalNameDNSFailure = "external-name-dns-failure"
+
+	// ExternalNameDNSServeStale is the config map value keeping the requests to the
+	// ExternalName services routed to the addresses their names last resolved to,
+	// including while their clusters are updated.
+	ExternalNameDNSServeStale ExternalNameDNSFailureType = "serve-stale"
+
+	// ExternalNameDNSFailFast is the config map value putting the clusters of the
+	// ExternalName services in use as soon as they're updated, failing the requests
+	// right away until their names resolve.
+	ExternalNameDNSFailFast ExternalNameDNSFailureType = "fail-fast"
+
+	// runtimeOverrides is the config map key for the runtime values served to the
+	// gateways via RTDS, overriding the ones of their bootstrap config.
+	runtimeOverrides = "runtime-overrides"
+
+	// overloadMaxHeapSize is the config map key for the heap size of the gateways their
+	// overload manager sheds load at.
+	overloadMaxHeapSize = "overload-max-heap-size"
+
+	// overloadShrinkHeapThreshold and overloadStopAcceptingRequestsThreshold are the
+	// config map keys for the fractions of the heap size at which the gateways shrink
+	// their heap and stop accepting requests.
+	overloadShrinkHeapThreshold            = "overload-shrink-heap-threshold"
+	overloadStopAcceptingRequestsThreshold = "overload-stop-accepting-requests-threshold"
+
+	// overloadMaxDownstreamConnections is the config map key for the maximum number of
+	// downstream connections of every gateway.
+	overloadMaxDownstreamConnections = "overload-max-downstream-connections"
+
+	// loadReporting is the config map key for making the gateways report the load of
+	// their clusters to the controller via LRS.
+	loadReporting = "load-reporting"
+
+	// descriptionMetadata is the config map key for describing the Ingresses the routes
+	// and clusters were generated from in their metadata.
+	descriptionMetadata = "description-metadata"
+
+	// dynamicForwardProxy is the config map key for enabling the dynamic forward proxy
+	// of the gateways.
+	dynamicForwardProxy = "dynamic-forward-proxy"
+
+	// headerMatcherTreeMinRoutes is the config map key for the minimum number of routes
+	// matching a header a virtual host needs to look them up in a matcher tree.
+	headerMatcherTreeMinRoutes = "header-matcher-tree-min-routes"
+
+	// wasmExtensions is the config map key for running the Wasm modules of the
+	// Ingresses on the requests to their hosts.
+	wasmExtensions = "wasm-extensions"
+
+	// luaFilters is the config map key for running the Lua snippets of the Ingresses on
+	// the requests to their hosts.
+	luaFilters = "lua-filters"
+
+	// luaFilterMaxBytes is the config map key for the maximum size of the Lua snippet of
+	// an Ingress.
+	luaFilterMaxBytes = "lua-filter-max-bytes"
+
+	// controlPathTracingEndpoint is the config map key for the address of the collector
+	// the spans of the control path are exported to.
+	controlPathTracingEndpoint = "control-path-tracing-endpoint"
+
+	// sourceIPFilters is the config map key for whether the Ingresses can restrict the
+	// sources of the requests to their hosts.
+	sourceIPFilters = "source-ip-filters"
+
+	// skipXffAppend is the config map key for not appending the address of the peers of
+	// the connections to the X-Forwarded-For header.
+	skipXffAppend = "skip-xff-append"
+
+	// originalIPHeader is the config map key for the header the proxies in front of the
+	// gateways set to the address of the clients.
+	originalIPHeader = "original-ip-header"
+
+	// UpstreamSANValidationIdentity is the config map value verifying the certificates of
+	// upstreams against the identity of their destination namespace.
+	UpstreamSANValidationIdentity UpstreamSANValidationType = "identity"
+
+	// UpstreamSANValidationLegacy is the config map value verifying the certificates of
+	// upstreams against the single SAN shared by all of the data-plane.
+	UpstreamSANValidationLegacy UpstreamSANValidationType = "legacy"
+
+	// MinHTTP2WindowSize is the minimum HTTP/2 window size accepted by Envoy.
+	MinHTTP2WindowSize = 65535
+
+	// MaxHTTP2Setting is the maximum value of the HTTP/2 settings accepted by Envoy.
+	MaxHTTP2Setting = 2147483647
+
+	// minPreconnectRatio and maxPreconnectRatio are the bounds of the preconnect ratios
+	// accepted by Envoy.
+	minPreconnectRatio = 1
+	maxPreconnectRatio = 3
+
+	// maxRequestHeadersKBLimit is the maximum size of the request headers accepted by
+	// Envoy, in KiB.
+	maxRequestHeadersKBLimit = 8192
+
+	// minHTTPStatus and maxHTTPStatus are the bounds of the statuses of the responses to
+	// the requests matching no Ingress.
+	minHTTPStatus = 200
+	maxHTTPStatus = 599
+
+	// MaxStatusProbeConcurrency is the maximum number of readiness probes issued
+	// simultaneously.
+	MaxStatusProbeConcurrency = 100
+
+	// maxPort is the highest port number.
+	maxPort = 65535
+
+	// minDNSRefreshRate is the minimum DNS refresh rate accepted by Envoy.
+	minDNSRefreshRate = time.Millisecond
 )
 
+// redirectStatuses are the statuses of the redirects of the requests matching no Ingress.
+var redirectStatuses = map[uint32]struct{}{301: {}, 302: {}, 303: {}, 307: {}, 308: {}}
+
+// tlsProtocolVersions are the supported values of the TLS version keys.
+var tlsProtocolVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// defaultScannerDenyPaths are the path prefixes commonly probed by scanners, which are
+// rejected unless configured otherwise.
+var defaultScannerDenyPaths = []string{
+	"/.env", "/.git", "/.aws", "/wp-admin", "/wp-login.php", "/xmlrpc.php",
+	"/phpmyadmin", "/cgi-bin", "/boaform",
+}
+
+// defaultScannerDenyUserAgents are the user agents of common scanners, which are
+// rejected unless configured otherwise.
+var defaultScannerDenyUserAgents = []string{
+	"sqlmap", "nikto", "masscan", "zgrab", "nmap", "nuclei", "dirbuster", "gobuster", "wpscan",
+}
+
+// defaultInternalAllowedUpgrades are the upgrades accepted by the internal listeners with
+// the upgrade protection enabled, unless configured otherwise.
+var defaultInternalAllowedUpgrades = []string{"websocket"}
+
 func DefaultConfig() *Kourier {
 	return &Kourier{
 		EnableServiceAccessLogging: true, // true is the default for backwards-compat
@@ -59,6 +558,7 @@ func DefaultConfig() *Kourier {
 		ClusterCertSecret:          "",
 		IdleTimeout:                0 * time.Second, // default value
 		TrafficIsolation:           "",
+		LuaFilterMaxBytes:          4096,
 	}
 }
 
@@ -66,19 +566,501 @@ func DefaultConfig() *Kourier {
 func NewConfigFromMap(configMap map[string]string) (*Kourier, error) {
 	nc := DefaultConfig()
 
+	// The profile only sets the defaults of its keys, which can still be set one by one.
+	nc.Profile = ProfileType(configMap[profile])
+	switch nc.Profile {
+	case "":
+	case ProfileBehindEdge:
+		nc.XffNumTrustedHops = 1
+		nc.DisableTLSTermination = true
+		nc.DisableHTTPRedirects = true
+	default:
+		return nil, fmt.Errorf("%s must be %q if set, was: %q", profile, ProfileBehindEdge, nc.Profile)
+	}
+
 	if err := cm.Parse(configMap,
 		cm.AsBool(enableServiceAccessLoggingKey, &nc.EnableServiceAccessLogging),
 		cm.AsBool(enableProxyProtocol, &nc.EnableProxyProtocol),
 		cm.AsString(clusterCert, &nc.ClusterCertSecret),
 		cm.AsDuration(IdleTimeoutKey, &nc.IdleTimeout),
 		cm.AsString(trafficIsolation, (*string)(&nc.TrafficIsolation)),
+		cm.AsString(transformationWasmModule, &nc.TransformationWasmModule),
+		cm.AsNamespacedName(defaultCertificateSecret, &nc.DefaultCertificateSecret),
+		cm.AsBool(stripQueryParameters, &nc.StripQueryParameters),
+		cm.AsInt(maxQueryParameters, &nc.MaxQueryParameters),
+		cm.AsBool(rewriteHostInternalEncryption, &nc.RewriteHostInternalEncryption),
+		cm.AsString(tlsMinProtocolVersion, &nc.TLSMinProtocolVersion),
+		cm.AsString(tlsMaxProtocolVersion, &nc.TLSMaxProtocolVersion),
+		asStringList(tlsCipherSuites, &nc.TLSCipherSuites),
+		asStringList(tlsECDHCurves, &nc.TLSECDHCurves),
+		cm.AsNamespacedName(clientCASecret, &nc.ClientCASecret),
+		asStringList(clientAllowedSANs, &nc.ClientAllowedSANs),
+		cm.AsUint32(upstreamHTTP2MaxConcurrentStreams, &nc.UpstreamHTTP2MaxConcurrentStreams),
+		cm.AsUint32(upstreamHTTP2InitialStreamWindowSize, &nc.UpstreamHTTP2InitialStreamWindowSize),
+		cm.AsUint32(upstreamHTTP2InitialConnectionWindowSize, &nc.UpstreamHTTP2InitialConnectionWindowSize),
+		cm.AsBool(upstreamHTTP1EnableTrailers, &nc.UpstreamHTTP1EnableTrailers),
+		cm.AsBool(upstreamALPNNegotiation, &nc.UpstreamALPNNegotiation),
+		cm.AsString(spiffeWorkloadAPISocket, &nc.SPIFFEWorkloadAPISocket),
+		asStringList(spiffeUpstreamSANPatterns, &nc.SPIFFEUpstreamSANPatterns),
+		cm.AsNamespacedName(upstreamClientCertificateSecret, &nc.UpstreamClientCertificateSecret),
+		asListenerPools(listenerPools, &nc.ListenerPools),
+		cm.AsString(upstreamSANValidation, (*string)(&nc.UpstreamSANValidation)),
+		cm.AsDuration(upstreamRequestBudget, &nc.UpstreamRequestBudget),
+		cm.AsBool(stripHostPort, &nc.StripHostPort),
+		cm.AsDuration(snapshotDebounceWindow, &nc.SnapshotDebounceWindow),
+		cm.AsBool(mergeSharedHosts, &nc.MergeSharedHosts),
+		cm.AsBool(virtualHostDiscovery, &nc.VirtualHostDiscovery),
+		cm.AsBool(activatorFailover, &nc.ActivatorFailover),
+		asGatewayFleets(gatewayFleets, &nc.GatewayFleets),
+		cm.AsBool(endpointHealthStatus, &nc.EndpointHealthStatus),
+		cm.AsFloat64(upstreamPreconnectRatio, &nc.UpstreamPreconnectRatio),
+		cm.AsFloat64(upstreamPredictivePreconnectRatio, &nc.UpstreamPredictivePreconnectRatio),
+		cm.AsBool(internalTrafficPriority, &nc.InternalTrafficPriority),
+		cm.AsUint32(externalMaxRequests, &nc.ExternalMaxRequests),
+		cm.AsUint32(internalMaxRequests, &nc.InternalMaxRequests),
+		cm.AsBool(grpcWeb, &nc.GRPCWeb),
+		cm.AsBool(scannerMitigation, &nc.ScannerMitigation),
+		asStringList(scannerDenyPaths, &nc.ScannerDenyPaths),
+		asStringList(scannerDenyUserAgents, &nc.ScannerDenyUserAgents),
+		cm.AsUint32(scannerRateLimit, &nc.ScannerRateLimit),
+		cm.AsBool(grpcJSONTranscoding, &nc.GRPCJSONTranscoding),
+		cm.AsBool(gatewayErrorHeader, &nc.GatewayErrorHeader),
+		cm.AsDuration(maxStreamDuration, &nc.MaxStreamDuration),
+		cm.AsDuration(connectionIdleTimeout, &nc.ConnectionIdleTimeout),
+		cm.AsDuration(upstreamMaxStreamDuration, &nc.UpstreamMaxStreamDuration),
+		cm.AsDuration(upstreamConnectionIdleTimeout, &nc.UpstreamConnectionIdleTimeout),
+		cm.AsUint32(maxRequestHeadersKB, &nc.MaxRequestHeadersKB),
+		cm.AsUint32(maxRequestBodyBytes, &nc.MaxRequestBodyBytes),
+		cm.AsUint32(unmatchedHostStatus, &nc.UnmatchedHostStatus),
+		cm.AsString(unmatchedHostBody, &nc.UnmatchedHostBody),
+		cm.AsString(unmatchedHostContentType, &nc.UnmatchedHostContentType),
+		cm.AsString(unmatchedHostRedirect, &nc.UnmatchedHostRedirect),
+		asStringList(headerMetadata, &nc.HeaderMetadata),
+		cm.AsString(errorPageBody, &nc.ErrorPageBody),
+		cm.AsString(errorPageContentType, &nc.ErrorPageContentType),
+		cm.AsString(internalErrorPageBody, &nc.InternalErrorPageBody),
+		cm.AsString(internalErrorPageContentType, &nc.InternalErrorPageContentType),
+		cm.AsBool(endpointDraining, &nc.EndpointDraining),
+		cm.AsDuration(snapshotWatchdogDeadline, &nc.SnapshotWatchdogDeadline),
+		cm.AsBool(internalNetworkPolicy, &nc.InternalNetworkPolicy),
+		cm.AsDuration(upstreamConnectTimeout, &nc.UpstreamConnectTimeout),
+		cm.AsDuration(upstreamTCPKeepaliveTime, &nc.UpstreamTCPKeepaliveTime),
+		cm.AsDuration(upstreamTCPKeepaliveInterval, &nc.UpstreamTCPKeepaliveInterval),
+		cm.AsUint32(upstreamTCPKeepaliveProbes, &nc.UpstreamTCPKeepaliveProbes),
+		cm.AsDuration(requestTimeout, &nc.RequestTimeout),
+		cm.AsDuration(requestHeadersTimeout, &nc.RequestHeadersTimeout),
+		cm.AsBool(internalUpgradeProtection, &nc.InternalUpgradeProtection),
+		asStringList(internalAllowedUpgrades, &nc.InternalAllowedUpgrades),
+		cm.AsBool(generatedResourcesConfigMaps, &nc.GeneratedResourcesConfigMaps),
+		cm.AsString(canaryOverrideHeader, &nc.CanaryOverrideHeader),
+		cm.AsDuration(retryAfterMaxDelay, &nc.RetryAfterMaxDelay),
+		cm.AsBool(snapshotDiffLogging, &nc.SnapshotDiffLogging),
+		cm.AsBool(ingressStatsTags, &nc.IngressStatsTags),
+		cm.AsUint32(xffNumTrustedHops, &nc.XffNumTrustedHops),
+		cm.AsBool(disableTLSTermination, &nc.DisableTLSTermination),
+		cm.AsBool(disableHTTPRedirects, &nc.DisableHTTPRedirects),
+		cm.AsBool(httpsPlaintextFallback, &nc.HTTPSPlaintextFallback),
+		cm.AsInt(statusProbeConcurrency, &nc.StatusProbeConcurrency),
+		cm.AsDuration(statusProbeTimeout, &nc.StatusProbeTimeout),
+		cm.AsDuration(statusProbeBackoffBase, &nc.StatusProbeBackoffBase),
+		cm.AsDuration(statusProbeBackoffMax, &nc.StatusProbeBackoffMax),
+		cm.AsUint32(statusProbeHTTPPort, &nc.StatusProbeHTTPPort),
+		cm.AsUint32(statusProbeHTTPSPort, &nc.StatusProbeHTTPSPort),
+		cm.AsBool(policyRouteStats, &nc.PolicyRouteStats),
+		cm.AsDuration(externalNameDNSRefreshRate, &nc.ExternalNameDNSRefreshRate),
+		cm.AsDuration(externalNameDNSFailureRefreshRate, &nc.ExternalNameDNSFailureRefreshRate),
+		cm.AsString(externalNameDNSFailure, (*string)(&nc.ExternalNameDNSFailure)),
+		asRuntimeOverrides(runtimeOverrides, &nc.RuntimeOverrides),
+		cm.AsQuantity(overloadMaxHeapSize, &nc.OverloadMaxHeapSize),
+		cm.AsFloat64(overloadShrinkHeapThreshold, &nc.OverloadShrinkHeapThreshold),
+		cm.AsFloat64(overloadStopAcceptingRequestsThreshold, &nc.OverloadStopAcceptingRequestsThreshold),
+		cm.AsUint32(overloadMaxDownstreamConnections, &nc.OverloadMaxDownstreamConnections),
+		cm.AsBool(loadReporting, &nc.LoadReporting),
+		cm.AsBool(descriptionMetadata, &nc.DescriptionMetadata),
+		cm.AsBool(dynamicForwardProxy, &nc.DynamicForwardProxy),
+		cm.AsUint32(headerMatcherTreeMinRoutes, &nc.HeaderMatcherTreeMinRoutes),
+		cm.AsBool(wasmExtensions, &nc.WasmExtensions),
+		cm.AsBool(luaFilters, &nc.LuaFilters),
+		cm.AsUint32(luaFilterMaxBytes, &nc.LuaFilterMaxBytes),
+		cm.AsString(controlPathTracingEndpoint, &nc.ControlPathTracingEndpoint),
+		cm.AsBool(sourceIPFilters, &nc.SourceIPFilters),
+		cm.AsBool(skipXffAppend, &nc.SkipXffAppend),
+		cm.AsString(originalIPHeader, &nc.OriginalIPHeader),
 	); err != nil {
 		return nil, err
 	}
 
+	switch nc.UpstreamSANValidation {
+	case "", UpstreamSANValidationIdentity, UpstreamSANValidationLegacy:
+	default:
+		return nil, fmt.Errorf("%s must be one of %q or %q, was: %q", upstreamSANValidation,
+			UpstreamSANValidationIdentity, UpstreamSANValidationLegacy, nc.UpstreamSANValidation)
+	}
+
+	switch nc.ExternalNameDNSFailure {
+	case "", ExternalNameDNSServeStale, ExternalNameDNSFailFast:
+	default:
+		return nil, fmt.Errorf("%s must be one of %q or %q, was: %q", externalNameDNSFailure,
+			ExternalNameDNSServeStale, ExternalNameDNSFailFast, nc.ExternalNameDNSFailure)
+	}
+	for key, rate := range map[string]time.Duration{
+		externalNameDNSRefreshRate:        nc.ExternalNameDNSRefreshRate,
+		externalNameDNSFailureRefreshRate: nc.ExternalNameDNSFailureRefreshRate,
+	} {
+		if rate != 0 && rate < minDNSRefreshRate {
+			return nil, fmt.Errorf("%s must be 0 or at least %v, was: %v", key, minDNSRefreshRate, rate)
+		}
+	}
+
+	if nc.OverloadMaxHeapSize != nil && nc.OverloadMaxHeapSize.Sign() < 0 {
+		return nil, fmt.Errorf("%s must not be negative, was: %v", overloadMaxHeapSize, nc.OverloadMaxHeapSize)
+	}
+	for key, threshold := range map[string]float64{
+		overloadShrinkHeapThreshold:            nc.OverloadShrinkHeapThreshold,
+		overloadStopAcceptingRequestsThreshold: nc.OverloadStopAcceptingRequestsThreshold,
+	} {
+		if threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1, was: %v", key, threshold)
+		}
+		if threshold != 0 && (nc.OverloadMaxHeapSize == nil || nc.OverloadMaxHeapSize.IsZero()) {
+			return nil, fmt.Errorf("%s requires %s to be set", key, overloadMaxHeapSize)
+		}
+	}
+
+	if nc.SnapshotWatchdogDeadline < 0 {
+		return nil, fmt.Errorf("%s must not be negative, was: %v", snapshotWatchdogDeadline, nc.SnapshotWatchdogDeadline)
+	}
+
+	if nc.StatusProbeConcurrency < 0 || nc.StatusProbeConcurrency > MaxStatusProbeConcurrency {
+		return nil, fmt.Errorf("%s must be between 0 and %d, was: %d",
+			statusProbeConcurrency, MaxStatusProbeConcurrency, nc.StatusProbeConcurrency)
+	}
+	if nc.StatusProbeBackoffBase != 0 && nc.StatusProbeBackoffMax != 0 && nc.StatusProbeBackoffBase > nc.StatusProbeBackoffMax {
+		return nil, fmt.Errorf("%s must not be greater than %s, was: %v > %v",
+			statusProbeBackoffBase, statusProbeBackoffMax, nc.StatusProbeBackoffBase, nc.StatusProbeBackoffMax)
+	}
+	for key, port := range map[string]uint32{
+		statusProbeHTTPPort:  nc.StatusProbeHTTPPort,
+		statusProbeHTTPSPort: nc.StatusProbeHTTPSPort,
+	} {
+		if port > maxPort {
+			return nil, fmt.Errorf("%s must not be greater than %d, was: %d", key, maxPort, port)
+		}
+	}
+
+	if nc.SnapshotDebounceWindow < 0 {
+		return nil, fmt.Errorf("%s must not be negative, was: %v", snapshotDebounceWindow, nc.SnapshotDebounceWindow)
+	}
+
+	if nc.UpstreamRequestBudget < 0 {
+		return nil, fmt.Errorf("%s must not be negative, was: %v", upstreamRequestBudget, nc.UpstreamRequestBudget)
+	}
+
+	for key, timeout := range map[string]time.Duration{
+		maxStreamDuration:             nc.MaxStreamDuration,
+		connectionIdleTimeout:         nc.ConnectionIdleTimeout,
+		upstreamMaxStreamDuration:     nc.UpstreamMaxStreamDuration,
+		upstreamConnectionIdleTimeout: nc.UpstreamConnectionIdleTimeout,
+		upstreamConnectTimeout:        nc.UpstreamConnectTimeout,
+		statusProbeTimeout:            nc.StatusProbeTimeout,
+		statusProbeBackoffBase:        nc.StatusProbeBackoffBase,
+		statusProbeBackoffMax:         nc.StatusProbeBackoffMax,
+		requestTimeout:                nc.RequestTimeout,
+		requestHeadersTimeout:         nc.RequestHeadersTimeout,
+		retryAfterMaxDelay:            nc.RetryAfterMaxDelay,
+	} {
+		if timeout < 0 {
+			return nil, fmt.Errorf("%s must not be negative, was: %v", key, timeout)
+		}
+	}
+
+	// Envoy configures TCP keepalive in whole seconds.
+	for key, keepalive := range map[string]time.Duration{
+		upstreamTCPKeepaliveTime:     nc.UpstreamTCPKeepaliveTime,
+		upstreamTCPKeepaliveInterval: nc.UpstreamTCPKeepaliveInterval,
+	} {
+		if keepalive < 0 || keepalive%time.Second != 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number of whole seconds, was: %v", key, keepalive)
+		}
+	}
+
+	if nc.MaxQueryParameters < 0 {
+		return nil, fmt.Errorf("%s must not be negative, was: %d", maxQueryParameters, nc.MaxQueryParameters)
+	}
+
+	if nc.UpstreamHTTP2MaxConcurrentStreams > MaxHTTP2Setting {
+		return nil, fmt.Errorf("%s must not be greater than %d, was: %d",
+			upstreamHTTP2MaxConcurrentStreams, MaxHTTP2Setting, nc.UpstreamHTTP2MaxConcurrentStreams)
+	}
+	for key, size := range map[string]uint32{
+		upstreamHTTP2InitialStreamWindowSize:     nc.UpstreamHTTP2InitialStreamWindowSize,
+		upstreamHTTP2InitialConnectionWindowSize: nc.UpstreamHTTP2InitialConnectionWindowSize,
+	} {
+		if size != 0 && (size < MinHTTP2WindowSize || size > MaxHTTP2Setting) {
+			return nil, fmt.Errorf("%s must be between %d and %d, was: %d",
+				key, MinHTTP2WindowSize, MaxHTTP2Setting, size)
+		}
+	}
+
+	if nc.MaxRequestHeadersKB > maxRequestHeadersKBLimit {
+		return nil, fmt.Errorf("%s must not be greater than %d, was: %d",
+			maxRequestHeadersKB, maxRequestHeadersKBLimit, nc.MaxRequestHeadersKB)
+	}
+
+	if err := validateUnmatchedHost(nc); err != nil {
+		return nil, err
+	}
+
+	for _, mapping := range nc.HeaderMetadata {
+		header, key, ok := strings.Cut(mapping, "=")
+		if !ok || key == "" || len(validation.IsHTTPHeaderName(header)) != 0 {
+			return nil, fmt.Errorf("%s must only contain mappings of the form \"<header>=<key>\", was: %q", headerMetadata, mapping)
+		}
+	}
+
+	for key, page := range map[string]struct{ body, contentType string }{
+		errorPageContentType:         {nc.ErrorPageBody, nc.ErrorPageContentType},
+		internalErrorPageContentType: {nc.InternalErrorPageBody, nc.InternalErrorPageContentType},
+	} {
+		if page.contentType != "" && page.body == "" {
+			return nil, fmt.Errorf("%s requires the body of the error page to be set", key)
+		}
+	}
+
+	if nc.InternalMaxRequests != 0 && !nc.InternalTrafficPriority {
+		return nil, fmt.Errorf("%s requires %s to be enabled", internalMaxRequests, internalTrafficPriority)
+	}
+
+	for _, upgradeType := range nc.InternalAllowedUpgrades {
+		if len(validation.IsHTTPHeaderName(upgradeType)) != 0 || strings.EqualFold(upgradeType, "h2c") {
+			return nil, fmt.Errorf("%s must only contain upgrade types other than h2c, was: %q", internalAllowedUpgrades, upgradeType)
+		}
+	}
+
+	if nc.CanaryOverrideHeader != "" && len(validation.IsHTTPHeaderName(nc.CanaryOverrideHeader)) != 0 {
+		return nil, fmt.Errorf("%s must be a valid header name, was: %q", canaryOverrideHeader, nc.CanaryOverrideHeader)
+	}
+
+	if nc.OriginalIPHeader != "" {
+		if len(validation.IsHTTPHeaderName(nc.OriginalIPHeader)) != 0 {
+			return nil, fmt.Errorf("%s must be a valid header name, was: %q", originalIPHeader, nc.OriginalIPHeader)
+		}
+		// Envoy detects the address of the clients either way, but not both.
+		if nc.XffNumTrustedHops != 0 || nc.EnableProxyProtocol {
+			return nil, fmt.Errorf("%s can't be combined with %s or %s", originalIPHeader, xffNumTrustedHops, enableProxyProtocol)
+		}
+	}
+
+	for _, path := range nc.ScannerDenyPaths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("%s must only contain paths starting with \"/\", was: %q", scannerDenyPaths, path)
+		}
+	}
+
+	for key, ratio := range map[string]float64{
+		upstreamPreconnectRatio:           nc.UpstreamPreconnectRatio,
+		upstreamPredictivePreconnectRatio: nc.UpstreamPredictivePreconnectRatio,
+	} {
+		if ratio != 0 && (ratio < minPreconnectRatio || ratio > maxPreconnectRatio) {
+			return nil, fmt.Errorf("%s must be between %d and %d, was: %v",
+				key, minPreconnectRatio, maxPreconnectRatio, ratio)
+		}
+	}
+
+	minVersion, err := tlsProtocolVersionIndex(tlsMinProtocolVersion, nc.TLSMinProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	maxVersion, err := tlsProtocolVersionIndex(tlsMaxProtocolVersion, nc.TLSMaxProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	if minVersion > maxVersion {
+		return nil, fmt.Errorf("%s %q must not be greater than %s %q",
+			tlsMinProtocolVersion, nc.TLSMinProtocolVersion, tlsMaxProtocolVersion, nc.TLSMaxProtocolVersion)
+	}
+
 	return nc, nil
 }
 
+// validateUnmatchedHost returns an error if the response to the requests matching no
+// Ingress is invalid.
+func validateUnmatchedHost(nc *Kourier) error {
+	if !nc.UnmatchedHostResponse() {
+		return nil
+	}
+	if nc.VirtualHostDiscovery {
+		return fmt.Errorf("the response to unmatched hosts can't be customized with %s enabled", virtualHostDiscovery)
+	}
+
+	if nc.UnmatchedHostRedirect == "" {
+		if nc.UnmatchedHostStatus != 0 && (nc.UnmatchedHostStatus < minHTTPStatus || nc.UnmatchedHostStatus > maxHTTPStatus) {
+			return fmt.Errorf("%s must be between %d and %d, was: %d",
+				unmatchedHostStatus, minHTTPStatus, maxHTTPStatus, nc.UnmatchedHostStatus)
+		}
+		return nil
+	}
+
+	if nc.UnmatchedHostBody != "" || nc.UnmatchedHostContentType != "" {
+		return fmt.Errorf("%s can't be combined with %s or %s", unmatchedHostRedirect, unmatchedHostBody, unmatchedHostContentType)
+	}
+	if u, err := url.Parse(nc.UnmatchedHostRedirect); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute HTTP(S) URL, was: %q", unmatchedHostRedirect, nc.UnmatchedHostRedirect)
+	}
+	if _, ok := redirectStatuses[nc.UnmatchedHostStatus]; nc.UnmatchedHostStatus != 0 && !ok {
+		return fmt.Errorf("%s must be one of 301, 302, 303, 307 or 308 with %s, was: %d",
+			unmatchedHostStatus, unmatchedHostRedirect, nc.UnmatchedHostStatus)
+	}
+	return nil
+}
+
+// asStringList parses the comma separated values at key into the target, if it exists.
+func asStringList(key string, target *[]string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		*target = nil
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*target = append(*target, v)
+			}
+		}
+		return nil
+	}
+}
+
+// asListenerPools parses the JSON encoded listener pools at key into the target, if it
+// exists. The pools have to use distinct ports which are not reserved for the gateway's
+// own listeners and valid source CIDRs.
+func asListenerPools(key string, target *map[string]ListenerPool) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok || strings.TrimSpace(raw) == "" {
+			return nil
+		}
+		var pools map[string]ListenerPool
+		if err := json.Unmarshal([]byte(raw), &pools); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+
+		poolsByPort := make(map[uint32]string, len(pools))
+		for name, pool := range pools {
+			if name == "" {
+				return fmt.Errorf("%s must not contain a pool without a name", key)
+			}
+			if _, err := ParseListenerPort(fmt.Sprint(pool.Port)); err != nil {
+				return fmt.Errorf("invalid port of listener pool %q in %s: %w", name, key, err)
+			}
+			if other, ok := poolsByPort[pool.Port]; ok {
+				return fmt.Errorf("listener pools %q and %q in %s use the same port %d", other, name, key, pool.Port)
+			}
+			poolsByPort[pool.Port] = name
+			for _, cidr := range pool.AllowedSourceCIDRs {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return fmt.Errorf("invalid source CIDR of listener pool %q in %s: %w", name, key, err)
+				}
+			}
+		}
+		*target = pools
+		return nil
+	}
+}
+
+// asRuntimeOverrides parses the JSON object of runtime keys and their values at key into
+// the target, if it exists. The values must be strings, numbers or booleans, and are
+// kept in their string form.
+func asRuntimeOverrides(key string, target *map[string]string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok || strings.TrimSpace(raw) == "" {
+			return nil
+		}
+		decoder := json.NewDecoder(strings.NewReader(raw))
+		decoder.UseNumber()
+		var values map[string]interface{}
+		if err := decoder.Decode(&values); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+
+		overrides := make(map[string]string, len(values))
+		for name, value := range values {
+			if name == "" {
+				return fmt.Errorf("%s must not contain an empty runtime key", key)
+			}
+			switch v := value.(type) {
+			case string:
+				overrides[name] = v
+			case json.Number:
+				overrides[name] = v.String()
+			case bool:
+				overrides[name] = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("runtime key %q in %s must be a string, number or boolean, was: %v", name, key, value)
+			}
+		}
+		*target = overrides
+		return nil
+	}
+}
+
+// asGatewayFleets parses the JSON encoded gateway fleets at key into the target, if it
+// exists, defaulting the names of their services. Every fleet needs its own node ID.
+func asGatewayFleets(key string, target *map[string]GatewayFleet) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok || strings.TrimSpace(raw) == "" {
+			return nil
+		}
+		var fleets map[string]GatewayFleet
+		if err := json.Unmarshal([]byte(raw), &fleets); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+
+		fleetsByNodeID := map[string]string{GatewayNodeID: ""}
+		for name, fleet := range fleets {
+			if name == "" {
+				return fmt.Errorf("%s must not contain a fleet without a name", key)
+			}
+			if fleet.NodeID == "" {
+				return fmt.Errorf("gateway fleet %q in %s has no node ID", name, key)
+			}
+			if other, ok := fleetsByNodeID[fleet.NodeID]; ok {
+				if other == "" {
+					return fmt.Errorf("gateway fleet %q in %s uses the node ID of the shared gateways", name, key)
+				}
+				return fmt.Errorf("gateway fleets %q and %q in %s use the same node ID %q", other, name, key, fleet.NodeID)
+			}
+			fleetsByNodeID[fleet.NodeID] = name
+
+			if fleet.ExternalService == "" {
+				fleet.ExternalService = ExternalServiceName + "-" + name
+			}
+			if fleet.InternalService == "" {
+				fleet.InternalService = InternalServiceName + "-" + name
+			}
+			fleets[name] = fleet
+		}
+		*target = fleets
+		return nil
+	}
+}
+
+// tlsProtocolVersionIndex returns the position of the given TLS version among the
+// supported ones. An empty version, meaning no restriction, is treated as the newest.
+func tlsProtocolVersionIndex(key string, version string) (int, error) {
+	if version == "" {
+		return len(tlsProtocolVersions), nil
+	}
+	for i, v := range tlsProtocolVersions {
+		if v == version {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s must be one of %v, was: %q", key, tlsProtocolVersions, version)
+}
+
 // NewConfigFromConfigMap creates a Kourier from the supplied configMap.
 func NewConfigFromConfigMap(config *corev1.ConfigMap) (*Kourier, error) {
 	return NewConfigFromMap(config.Data)
@@ -103,4 +1085,482 @@ type Kourier struct {
 	IdleTimeout time.Duration
 	// Desire level of incoming traffic isolation
 	TrafficIsolation TrafficIsolationType
+	// TransformationWasmModule is the path of the request transformation Wasm module
+	// on the gateway. The transformation filter is disabled if empty.
+	TransformationWasmModule string
+	// DefaultCertificateSecret is the secret holding the certificate served for external
+	// hosts which are not covered by the TLS settings of their Ingress, unless the
+	// Ingress' namespace specifies its own default certificate. No fallback is done if empty.
+	DefaultCertificateSecret types.NamespacedName
+	// StripQueryParameters specifies whether all query parameters are removed while
+	// routes and external authorization are evaluated. The upstream request is unchanged.
+	StripQueryParameters bool
+	// MaxQueryParameters is the maximum number of query parameters kept while routes
+	// and external authorization are evaluated. The upstream request is unchanged.
+	// There is no limit if 0.
+	MaxQueryParameters int
+	// RewriteHostInternalEncryption specifies whether the traffic of Ingresses with
+	// RewriteHost to kourier-internal is encrypted when internal encryption is enabled.
+	// This requires kourier-internal to serve TLS, see ClusterCertSecret.
+	RewriteHostInternalEncryption bool
+	// TLSMinProtocolVersion is the minimum TLS version, e.g. "1.2", of the external
+	// HTTPS listener. It defaults to TLS 1.2 if empty.
+	TLSMinProtocolVersion string
+	// TLSMaxProtocolVersion is the maximum TLS version, e.g. "1.3", of the external
+	// HTTPS listener. Envoy's default is used if empty.
+	TLSMaxProtocolVersion string
+	// TLSCipherSuites are the cipher suites allowed by the external HTTPS listener.
+	// Envoy's defaults are used if empty.
+	TLSCipherSuites []string
+	// TLSECDHCurves are the ECDH curves allowed by the external HTTPS listener.
+	// Envoy's defaults are used if empty.
+	TLSECDHCurves []string
+	// ClientCASecret is the secret holding the CA bundle ("ca.crt") client certificates
+	// are required and validated against on the external HTTPS listener, unless an
+	// Ingress specifies its own. Client certificates are not required if empty.
+	ClientCASecret types.NamespacedName
+	// ClientAllowedSANs restricts the accepted client certificates to those having
+	// one of the given SANs. All SANs are accepted if empty.
+	ClientAllowedSANs []string
+	// UpstreamHTTP2MaxConcurrentStreams is the maximum number of concurrent streams per
+	// HTTP/2 connection to upstreams. Envoy's default is used if 0.
+	UpstreamHTTP2MaxConcurrentStreams uint32
+	// UpstreamHTTP2InitialStreamWindowSize is the initial stream flow-control window
+	// size, in bytes, of HTTP/2 connections to upstreams. Envoy's default is used if 0.
+	UpstreamHTTP2InitialStreamWindowSize uint32
+	// UpstreamHTTP2InitialConnectionWindowSize is the initial connection flow-control
+	// window size, in bytes, of HTTP/2 connections to upstreams. Envoy's default is used
+	// if 0.
+	UpstreamHTTP2InitialConnectionWindowSize uint32
+	// UpstreamHTTP1EnableTrailers specifies whether the HTTP/1 connections to upstreams
+	// carry the trailers of the requests and responses, rather than dropping them.
+	UpstreamHTTP1EnableTrailers bool
+	// UpstreamALPNNegotiation specifies whether the encrypted connections to upstreams not
+	// known to speak HTTP/2, i.e. whose ports aren't named accordingly, negotiate HTTP/2
+	// or HTTP/1.1 via ALPN, rather than always using HTTP/1.1. It only has an effect with
+	// internal encryption enabled.
+	UpstreamALPNNegotiation bool
+	// SPIFFEWorkloadAPISocket is the path of the SPIFFE Workload API socket on the
+	// gateway. If set, the certificates and trust bundle for upstream TLS are obtained
+	// from it instead of the Knative serving CA secret.
+	SPIFFEWorkloadAPISocket string
+	// SPIFFEUpstreamSANPatterns are the SAN patterns the upstreams' certificates are
+	// verified against when using SPIFFE. A trailing "*" matches any suffix.
+	SPIFFEUpstreamSANPatterns []string
+	// UpstreamClientCertificateSecret is the secret holding the client certificate
+	// ("tls.crt" and "tls.key") presented to upstreams when internal encryption is
+	// enabled, so they can enforce mutual TLS. No client certificate is presented if
+	// empty. It is ignored when using SPIFFE, which provides its own.
+	UpstreamClientCertificateSecret types.NamespacedName
+	// ListenerPools are the dedicated external listeners, keyed by their name, Ingresses
+	// can be assigned to with the ListenerPoolAnnotationKey annotation.
+	ListenerPools map[string]ListenerPool
+	// UpstreamSANValidation specifies how the SANs of the certificates of upstreams are
+	// validated when internal encryption is enabled. If empty, they are verified against
+	// the single SAN shared by all of the data-plane, like UpstreamSANValidationLegacy.
+	UpstreamSANValidation UpstreamSANValidationType
+	// UpstreamRequestBudget is the time budget of the requests to upstreams. If set, it is
+	// enforced on all routes and the requests are stamped with the remaining budget
+	// ("x-envoy-expected-rq-timeout-ms") and their attempt count ("x-envoy-attempt-count"),
+	// so that the upstreams can implement deadline-aware processing. Requests are
+	// neither limited nor stamped if 0.
+	UpstreamRequestBudget time.Duration
+	// StripHostPort specifies whether the gateways strip the port from the host before
+	// the routes are matched. Otherwise, every host is also matched with any port
+	// ("host:*"), which doubles the number of domains of the routes.
+	StripHostPort bool
+	// SnapshotDebounceWindow is the window within which the updates of the gateways'
+	// config, e.g. caused by bursts of Ingress or Endpoints events, are coalesced into a
+	// single push. Every update is pushed right away if 0.
+	SnapshotDebounceWindow time.Duration
+	// MergeSharedHosts specifies whether ingresses may share hosts as long as their routes
+	// don't match the same requests. The routes of a shared host are merged into a single
+	// virtual host, longer paths taking precedence. Otherwise, ingresses sharing a host
+	// are rejected as conflicting.
+	MergeSharedHosts bool
+	// VirtualHostDiscovery specifies whether the external virtual hosts are served on
+	// demand via VHDS, as the gateways request them, instead of being embedded into the
+	// route configurations. This keeps the route configurations small with lots of hosts
+	// but requires incremental xDS.
+	VirtualHostDiscovery bool
+	// ActivatorFailover specifies whether the gateways fail over from the endpoints of a
+	// revision to the activator on their own as soon as the endpoints disappear or fail,
+	// instead of waiting for the activator to be pushed as the revision's endpoints.
+	ActivatorFailover bool
+	// GatewayFleets are the dedicated fleets of gateways, keyed by their name, Ingresses
+	// can be assigned to with the GatewayFleetAnnotationKey label or annotation. All
+	// other Ingresses are served by the shared gateways.
+	GatewayFleets map[string]GatewayFleet
+	// EndpointHealthStatus specifies whether the endpoints are passed on to the gateways
+	// along with their health status, as reported by the kubelet on their pods, rather
+	// than only passing on the endpoints listed as ready.
+	EndpointHealthStatus bool
+	// UpstreamPreconnectRatio is the number of connections anticipated per upstream for
+	// each request, e.g. 1.5 keeps half a connection per request in flight established
+	// ahead of time. Connections are only established as needed if 0.
+	UpstreamPreconnectRatio float64
+	// UpstreamPredictivePreconnectRatio is the number of connections anticipated across
+	// the upstreams of a cluster for each request. Connections are established ahead of
+	// time to the upstreams predicted to be picked next, including newly added ones, so
+	// requests to them don't wait for the connection to be set up. Connections are only
+	// established as needed if 0.
+	UpstreamPredictivePreconnectRatio float64
+	// InternalTrafficPriority specifies whether the traffic of the internal listeners is
+	// routed with a higher priority than the traffic of the external ones. The traffic of
+	// either priority is limited by its own circuit breakers, so the cluster-internal
+	// traffic isn't starved by spikes of external traffic.
+	InternalTrafficPriority bool
+	// ExternalMaxRequests is the maximum number of parallel requests to each upstream
+	// cluster routed with the default priority, i.e. of the external traffic if
+	// InternalTrafficPriority is enabled and of all traffic otherwise. Envoy's default
+	// is used if 0.
+	ExternalMaxRequests uint32
+	// InternalMaxRequests is the maximum number of parallel requests to each upstream
+	// cluster of the internal traffic routed with a higher priority. It requires
+	// InternalTrafficPriority. Envoy's default is used if 0.
+	InternalMaxRequests uint32
+	// GRPCWeb specifies whether the gateways translate the gRPC-Web requests of browsers
+	// into gRPC requests, and their responses back, so that browsers can call gRPC
+	// services without a proxy of their own. Other requests are unaffected.
+	GRPCWeb bool
+	// ScannerMitigation specifies whether the gateways reject the requests of scanners
+	// as not found, so they neither reach the revisions nor skew their autoscaling
+	// metrics. Requests are considered coming from scanners if they match
+	// ScannerDenyPaths or ScannerDenyUserAgents.
+	ScannerMitigation bool
+	// ScannerDenyPaths are the path prefixes of the requests rejected as coming from
+	// scanners, compared case-insensitively. The paths commonly probed by scanners, like
+	// "/.env" or "/wp-admin", are rejected if empty.
+	ScannerDenyPaths []string
+	// ScannerDenyUserAgents are the substrings of the user agents of the requests
+	// rejected as coming from scanners, compared case-insensitively. The user agents of
+	// common scanners, like "sqlmap" or "nikto", are rejected if empty.
+	ScannerDenyUserAgents []string
+	// ScannerRateLimit is the maximum number of requests per second of every client
+	// connection when rejecting the requests of scanners. The requests exceeding it are
+	// rejected with 429. The requests aren't limited if 0.
+	ScannerRateLimit uint32
+	// GRPCJSONTranscoding specifies whether the gateways transcode the JSON requests to
+	// the hosts of Ingresses with the GRPCJSONTranscoderAnnotationKey annotation into
+	// gRPC requests, and their responses back, so that REST clients can call gRPC
+	// services.
+	GRPCJSONTranscoding bool
+	// GatewayErrorHeader specifies whether the errors generated by the gateways, like
+	// upstream timeouts or connection failures, carry a header telling what went wrong
+	// ("x-kourier-error"), so that they can be told apart from the errors of the
+	// applications.
+	GatewayErrorHeader bool
+	// MaxStreamDuration is the maximum duration of the streams of the gateways'
+	// listeners, including upgraded connections. Streams aren't limited if 0.
+	MaxStreamDuration time.Duration
+	// ConnectionIdleTimeout is the time after which the client connections of the
+	// gateways' listeners are closed if they have no active streams. Envoy's default is
+	// used if 0.
+	ConnectionIdleTimeout time.Duration
+	// UpstreamMaxStreamDuration is the maximum duration of the streams to upstreams.
+	// Streams aren't limited if 0.
+	UpstreamMaxStreamDuration time.Duration
+	// UpstreamConnectionIdleTimeout is the time after which the connections to upstreams
+	// are closed if they have no active streams. Envoy's default is used if 0.
+	UpstreamConnectionIdleTimeout time.Duration
+	// MaxRequestHeadersKB is the maximum size of the headers of the requests to the
+	// gateways, in KiB. Requests with larger headers are rejected with 431. Envoy's
+	// default, 60 KiB, is used if 0.
+	MaxRequestHeadersKB uint32
+	// MaxRequestBodyBytes is the maximum size of the bodies of the requests to the
+	// gateways. The bodies are buffered before the requests are sent upstream, and
+	// requests with larger bodies are rejected with 413. The bodies are streamed without
+	// any limit if 0.
+	MaxRequestBodyBytes uint32
+	// UnmatchedHostStatus is the status of the responses to the requests matching no
+	// Ingress, instead of Envoy's blank 404. It defaults to 404, or 302 with
+	// UnmatchedHostRedirect.
+	UnmatchedHostStatus uint32
+	// UnmatchedHostBody is the body of the responses to the requests matching no Ingress.
+	UnmatchedHostBody string
+	// UnmatchedHostContentType is the content type of UnmatchedHostBody, "text/plain" by
+	// default.
+	UnmatchedHostContentType string
+	// UnmatchedHostRedirect is the URL the requests matching no Ingress are redirected to,
+	// instead of being answered directly.
+	UnmatchedHostRedirect string
+	// HeaderMetadata are the headers of the external requests extracted into the dynamic
+	// metadata of the HeaderMetadataNamespace, as "<header>=<key>" mappings. The metadata
+	// is passed on to the external authorization and written to the access logs.
+	HeaderMetadata []string
+	// ErrorPageBody is the format of the body of the 5xx responses generated by the
+	// external listeners, like when the upstreams can't be connected to, replacing
+	// Envoy's. It may contain Envoy's command operators, e.g. "%RESPONSE_CODE%".
+	ErrorPageBody string
+	// ErrorPageContentType is the content type of ErrorPageBody, "text/plain" by default.
+	ErrorPageContentType string
+	// InternalErrorPageBody is the format of the body of the 5xx responses generated by
+	// the cluster-local listeners, like ErrorPageBody.
+	InternalErrorPageBody string
+	// InternalErrorPageContentType is the content type of InternalErrorPageBody,
+	// "text/plain" by default.
+	InternalErrorPageContentType string
+	// EndpointDraining specifies whether the not ready addresses of the endpoints, like
+	// the ones of terminating pods, are passed on to the gateways as draining, so that
+	// the requests in flight to them can complete, rather than dropping them right away.
+	// It has no effect with EndpointHealthStatus enabled, which passes them on as well.
+	EndpointDraining bool
+	// SnapshotWatchdogDeadline is the deadline within which the gateways have to pick up
+	// the snapshots published for them. Snapshots that aren't picked up, e.g. because the
+	// stream of the gateway is wedged, are published again. Disabled if 0.
+	SnapshotWatchdogDeadline time.Duration
+	// InternalNetworkPolicy specifies whether a NetworkPolicy restricts the namespaces
+	// that can reach the internal listeners of the gateways to the ones serving
+	// cluster-local hosts, the ones of Knative and Kourier, and the ones labeled with
+	// InternalAccessLabelKey.
+	InternalNetworkPolicy bool
+	// UpstreamConnectTimeout is the timeout of establishing the connections to upstreams.
+	// The default of 5s is used if 0.
+	UpstreamConnectTimeout time.Duration
+	// UpstreamTCPKeepaliveTime is the time the connections to upstreams are idle before
+	// TCP keepalive probes are sent. TCP keepalive is enabled for the connections to
+	// upstreams if it or any of the other TCP keepalive settings is set, using the
+	// defaults of the OS for the ones that are 0.
+	UpstreamTCPKeepaliveTime time.Duration
+	// UpstreamTCPKeepaliveInterval is the interval between the TCP keepalive probes of
+	// the connections to upstreams.
+	UpstreamTCPKeepaliveInterval time.Duration
+	// UpstreamTCPKeepaliveProbes is the number of unanswered TCP keepalive probes after
+	// which the connections to upstreams are dropped.
+	UpstreamTCPKeepaliveProbes uint32
+	// RequestTimeout is the time within which the clients have to send their complete
+	// requests, including the bodies, to the gateways' listeners, protecting them from
+	// slow clients. Requests aren't limited if 0.
+	RequestTimeout time.Duration
+	// RequestHeadersTimeout is the time within which the clients have to send the headers
+	// of their requests to the gateways' listeners, once they started sending them.
+	// Headers aren't limited if 0.
+	RequestHeadersTimeout time.Duration
+	// InternalUpgradeProtection specifies whether the internal listeners of the gateways
+	// only accept the upgrades of InternalAllowedUpgrades, regardless of the upgrades
+	// allowed on the routes, and reject h2c upgrades, so that they can't be smuggled
+	// through proxies in front of the gateways to the backends.
+	InternalUpgradeProtection bool
+	// InternalAllowedUpgrades are the upgrades accepted by the internal listeners with
+	// InternalUpgradeProtection enabled. Only WebSockets are accepted if empty.
+	InternalAllowedUpgrades []string
+
+	// GeneratedResourcesConfigMaps specifies whether the Envoy resources generated for
+	// the Ingresses opting in are written to a ConfigMap in their namespace, so that the
+	// effective config of the gateways can be inspected and diffed, e.g. by GitOps tools.
+	GeneratedResourcesConfigMaps bool
+
+	// CanaryOverrideHeader is the name of the header pinning requests to the split of a
+	// path whose service is named by its value, regardless of the weights of the splits.
+	// Requests can't be pinned if empty.
+	CanaryOverrideHeader string
+
+	// RetryAfterMaxDelay is the longest delay hinted by the Retry-After header of an
+	// upstream response the gateways wait for before retrying the idempotent request
+	// once, smoothing short overload spikes of the backends. Requests aren't retried
+	// after such hints if 0.
+	RetryAfterMaxDelay time.Duration
+
+	// SnapshotDiffLogging specifies whether the clusters, virtual hosts and SNI matches
+	// added, removed or changed by each snapshot pushed to the gateways are logged, so that
+	// the effect of a change can be audited.
+	SnapshotDiffLogging bool
+
+	// IngressStatsTags specifies whether the gateways emit the stats of the routes under a
+	// prefix naming the namespace and name of their ingress, and its Knative service and
+	// revision, which the bootstrap config extracts as tags.
+	IngressStatsTags bool
+
+	// Profile is the deployment profile the defaults of the following keys are set by.
+	Profile ProfileType
+
+	// XffNumTrustedHops is the number of proxies in front of the gateways, whose
+	// X-Forwarded-For entries are trusted to determine the address of the clients. The
+	// address of the connections is used if 0.
+	XffNumTrustedHops uint32
+
+	// DisableTLSTermination specifies whether the TLS settings of the Ingresses and the
+	// default certificates are ignored, so that the gateways only serve plain HTTP.
+	DisableTLSTermination bool
+
+	// DisableHTTPRedirects specifies whether plain HTTP requests are served rather than
+	// redirected to HTTPS, regardless of the HTTP option of the Ingresses.
+	DisableHTTPRedirects bool
+
+	// HTTPSPlaintextFallback specifies whether the external HTTPS listener serves the
+	// plaintext HTTP connections it receives like the external HTTP listener, rather than
+	// failing their TLS handshake, e.g. while the clients migrate to HTTPS.
+	HTTPSPlaintextFallback bool
+
+	// StatusProbeConcurrency is the number of readiness probes of the Ingresses issued
+	// simultaneously. The prober's default is used if 0.
+	StatusProbeConcurrency int
+
+	// StatusProbeTimeout is the timeout of a readiness probe, and StatusProbeBackoffBase
+	// and StatusProbeBackoffMax are the initial and maximum delays before retrying a
+	// failed one. The prober's defaults are used for the ones which are 0.
+	StatusProbeTimeout     time.Duration
+	StatusProbeBackoffBase time.Duration
+	StatusProbeBackoffMax  time.Duration
+
+	// StatusProbeHTTPPort and StatusProbeHTTPSPort are the ports of the gateway pods the
+	// external hosts are probed at, over HTTP and HTTPS. The probe listeners are probed if
+	// 0.
+	StatusProbeHTTPPort  uint32
+	StatusProbeHTTPSPort uint32
+
+	// PolicyRouteStats specifies whether the gateways emit the stats of the routes they
+	// respond to themselves, i.e. the redirects and direct responses of the Ingresses,
+	// under a prefix naming the Ingress and the policy, e.g. https_redirect.
+	PolicyRouteStats bool
+
+	// ExternalNameDNSRefreshRate and ExternalNameDNSFailureRefreshRate are how often the
+	// names of the ExternalName services are resolved again, once resolved and while
+	// their resolution fails. Envoy's defaults are used for the ones which are 0.
+	ExternalNameDNSRefreshRate        time.Duration
+	ExternalNameDNSFailureRefreshRate time.Duration
+
+	// ExternalNameDNSFailure is the behavior of the clusters of the ExternalName services
+	// while their names don't resolve. They serve stale addresses if empty.
+	ExternalNameDNSFailure ExternalNameDNSFailureType
+
+	// RuntimeOverrides are the runtime values served to the gateways via RTDS, keyed by
+	// their runtime key, which override the ones of their bootstrap config.
+	RuntimeOverrides map[string]string
+
+	// OverloadMaxHeapSize is the heap size of the gateways their overload manager sheds
+	// load at. The overload manager isn't configured if nil or zero.
+	OverloadMaxHeapSize *resource.Quantity
+
+	// OverloadShrinkHeapThreshold and OverloadStopAcceptingRequestsThreshold are the
+	// fractions of OverloadMaxHeapSize at which the gateways shrink their heap and stop
+	// accepting requests. The defaults of the overload manager are used if 0.
+	OverloadShrinkHeapThreshold            float64
+	OverloadStopAcceptingRequestsThreshold float64
+
+	// OverloadMaxDownstreamConnections is the maximum number of downstream connections
+	// of every gateway, served via RTDS. They're unlimited if 0.
+	OverloadMaxDownstreamConnections uint32
+
+	// LoadReporting specifies whether the gateways report the load of their clusters to
+	// the controller via LRS, by generating the load_stats_config of their bootstrap.
+	LoadReporting bool
+
+	// DescriptionMetadata specifies whether the routes and clusters describe the
+	// Ingresses, rules and services they were generated from in their metadata, so that
+	// the config dumps of the gateways are self-describing.
+	DescriptionMetadata bool
+
+	// DynamicForwardProxy specifies whether the gateways run a dynamic forward proxy,
+	// which the Ingresses can proxy the requests to their ExternalName services through,
+	// resolving the hosts on demand rather than via a cluster of their own.
+	DynamicForwardProxy bool
+
+	// HeaderMatcherTreeMinRoutes is the minimum number of routes matching distinct values
+	// of a header, e.g. Knative-Serving-Tag, a virtual host needs to look its routes up by
+	// the value of the header in a matcher tree, rather than matching them one by one.
+	// The routes are always matched one by one if 0.
+	HeaderMatcherTreeMinRoutes uint32
+
+	// WasmExtensions specifies whether the gateways run the Wasm modules of the secrets
+	// referenced by the WasmExtensionAnnotationKey annotation of the Ingresses on the
+	// requests to their hosts, letting them extend the gateways with custom logic.
+	WasmExtensions bool
+
+	// LuaFilters specifies whether the gateways run the Lua snippets of the
+	// LuaFilterAnnotationKey annotation of the Ingresses on the requests to their hosts.
+	// The Ingresses can't use the annotation otherwise.
+	LuaFilters bool
+
+	// LuaFilterMaxBytes is the maximum size of the Lua snippet of an Ingress. The
+	// snippets aren't limited if 0.
+	LuaFilterMaxBytes uint32
+
+	// ControlPathTracingEndpoint is the address of the collector the spans of the
+	// control path are exported to, tracing the reconciliation of the Ingresses until
+	// their config is acknowledged by the gateways. The control path isn't traced if
+	// empty.
+	ControlPathTracingEndpoint string
+
+	// SourceIPFilters specifies whether the gateways restrict the sources of the requests
+	// to the hosts of the Ingresses with the AllowedSourceCIDRsAnnotationKey or
+	// DeniedSourceCIDRsAnnotationKey annotations. The Ingresses can't use the annotations
+	// otherwise.
+	SourceIPFilters bool
+
+	// SkipXffAppend specifies whether the gateways don't append the address of the peers
+	// of the connections to the X-Forwarded-For header of the requests.
+	SkipXffAppend bool
+
+	// OriginalIPHeader is the header the proxies in front of the gateways set to the
+	// address of the clients, e.g. CF-Connecting-IP or True-Client-IP. The address of the
+	// connections is used if the header is missing. The X-Forwarded-For header
+	// determines the address of the clients as configured by XffNumTrustedHops if empty.
+	OriginalIPHeader string
+}
+
+// ListenerPool is a dedicated external listener with its own exposure policy. The external
+// hosts of the Ingresses assigned to it are only served by it.
+// +k8s:deepcopy-gen=true
+type ListenerPool struct {
+	// Port is the port of the pool's listener.
+	Port uint32 `json:"port"`
+	// AllowedSourceCIDRs restricts the connections to the pool's listener to the given
+	// source CIDRs. Connections from all sources are accepted if empty.
+	AllowedSourceCIDRs []string `json:"allowedSourceCIDRs,omitempty"`
+	// RequireTLS specifies whether the pool's listener serves HTTPS only. Otherwise it
+	// serves plain HTTP only.
+	RequireTLS bool `json:"requireTLS,omitempty"`
+}
+
+// GatewayFleet is a dedicated fleet of gateways with its own xDS node ID, and thus its
+// own config. Only the Ingresses assigned to it are served by it.
+// +k8s:deepcopy-gen=true
+type GatewayFleet struct {
+	// NodeID is the xDS node ID the gateways of the fleet identify with.
+	NodeID string `json:"nodeID"`
+	// ExternalService is the name of the Service exposing the fleet's external
+	// listeners, in the gateway namespace. Defaults to "kourier-<fleet>".
+	ExternalService string `json:"externalService,omitempty"`
+	// InternalService is the name of the Service exposing the fleet's internal
+	// listeners, in the gateway namespace. Defaults to "kourier-internal-<fleet>".
+	InternalService string `json:"internalService,omitempty"`
+}
+
+// QueryParametersLimit returns the number of query parameters kept while routes and
+// external authorization are evaluated, and whether there is such a limit at all.
+func (c *Kourier) QueryParametersLimit() (int, bool) {
+	if c.StripQueryParameters {
+		return 0, true
+	}
+	return c.MaxQueryParameters, c.MaxQueryParameters > 0
+}
+
+// ScannerDenyRules returns the path prefixes and the user agent substrings of the
+// requests rejected as coming from scanners, falling back to the common ones.
+func (c *Kourier) ScannerDenyRules() (paths []string, userAgents []string) {
+	paths, userAgents = c.ScannerDenyPaths, c.ScannerDenyUserAgents
+	if len(paths) == 0 {
+		paths = defaultScannerDenyPaths
+	}
+	if len(userAgents) == 0 {
+		userAgents = defaultScannerDenyUserAgents
+	}
+	return paths, userAgents
+}
+
+// InternalUpgrades returns the upgrades accepted by the internal listeners with
+// InternalUpgradeProtection enabled, falling back to the defaults.
+func (c *Kourier) InternalUpgrades() []string {
+	if len(c.InternalAllowedUpgrades) == 0 {
+		return defaultInternalAllowedUpgrades
+	}
+	return c.InternalAllowedUpgrades
+}
+
+// UnmatchedHostResponse returns whether the responses to the requests matching no Ingress
+// are customized.
+func (c *Kourier) UnmatchedHostResponse() bool {
+	return c.UnmatchedHostStatus != 0 || c.UnmatchedHostBody != "" ||
+		c.UnmatchedHostContentType != "" || c.UnmatchedHostRedirect != ""
 }
@@ -23,6 +23,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
 
 	_ "knative.dev/pkg/system/testing"
 )
@@ -42,6 +44,7 @@ func TestKourierConfig(t *testing.T) {
 		want: &Kourier{
 			EnableServiceAccessLogging: false,
 			IdleTimeout:                0 * time.Second,
+			LuaFilterMaxBytes:          4096,
 		},
 		data: map[string]string{
 			enableServiceAccessLoggingKey: "false",
@@ -59,6 +62,7 @@ func TestKourierConfig(t *testing.T) {
 			EnableProxyProtocol:        true,
 			ClusterCertSecret:          "my-cert",
 			IdleTimeout:                0 * time.Second,
+			LuaFilterMaxBytes:          4096,
 		},
 		data: map[string]string{
 			enableServiceAccessLoggingKey: "true",
@@ -72,6 +76,7 @@ func TestKourierConfig(t *testing.T) {
 			EnableProxyProtocol:        true,
 			ClusterCertSecret:          "",
 			IdleTimeout:                0 * time.Second,
+			LuaFilterMaxBytes:          4096,
 		},
 		data: map[string]string{
 			enableServiceAccessLoggingKey: "false",
@@ -91,6 +96,7 @@ func TestKourierConfig(t *testing.T) {
 			EnableProxyProtocol:        false,
 			ClusterCertSecret:          "",
 			IdleTimeout:                200 * time.Second,
+			LuaFilterMaxBytes:          4096,
 		},
 		data: map[string]string{
 			enableServiceAccessLoggingKey: "true",
@@ -106,10 +112,1046 @@ func TestKourierConfig(t *testing.T) {
 			ClusterCertSecret:          "",
 			IdleTimeout:                0 * time.Second,
 			TrafficIsolation:           "port",
+			LuaFilterMaxBytes:          4096,
 		},
 		data: map[string]string{
 			trafficIsolation: "port",
 		},
+	}, {
+		name: "set transformation wasm module",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.TransformationWasmModule = "/var/lib/kourier/transform.wasm"
+			return c
+		}(),
+		data: map[string]string{
+			transformationWasmModule: "/var/lib/kourier/transform.wasm",
+		},
+	}, {
+		name: "set default certificate secret",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.DefaultCertificateSecret = types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"}
+			return c
+		}(),
+		data: map[string]string{
+			defaultCertificateSecret: "kourier-system/wildcard-cert",
+		},
+	}, {
+		name:    "default certificate secret without namespace",
+		wantErr: true,
+		data: map[string]string{
+			defaultCertificateSecret: "wildcard-cert",
+		},
+	}, {
+		name: "strip query parameters",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.StripQueryParameters = true
+			return c
+		}(),
+		data: map[string]string{
+			stripQueryParameters: "true",
+		},
+	}, {
+		name: "cap query parameters",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.MaxQueryParameters = 10
+			return c
+		}(),
+		data: map[string]string{
+			maxQueryParameters: "10",
+		},
+	}, {
+		name:    "negative max query parameters",
+		wantErr: true,
+		data: map[string]string{
+			maxQueryParameters: "-1",
+		},
+	}, {
+		name: "enable rewrite host internal encryption",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.RewriteHostInternalEncryption = true
+			return c
+		}(),
+		data: map[string]string{
+			rewriteHostInternalEncryption: "true",
+		},
+	}, {
+		name: "set TLS parameters",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.TLSMinProtocolVersion = "1.2"
+			c.TLSMaxProtocolVersion = "1.3"
+			c.TLSCipherSuites = []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"}
+			c.TLSECDHCurves = []string{"P-256", "P-384"}
+			return c
+		}(),
+		data: map[string]string{
+			tlsMinProtocolVersion: "1.2",
+			tlsMaxProtocolVersion: "1.3",
+			tlsCipherSuites:       "ECDHE-ECDSA-AES128-GCM-SHA256, ECDHE-RSA-AES128-GCM-SHA256",
+			tlsECDHCurves:         "P-256,P-384,",
+		},
+	}, {
+		name:    "unknown TLS version",
+		wantErr: true,
+		data: map[string]string{
+			tlsMinProtocolVersion: "1.4",
+		},
+	}, {
+		name:    "TLS min version greater than max version",
+		wantErr: true,
+		data: map[string]string{
+			tlsMinProtocolVersion: "1.3",
+			tlsMaxProtocolVersion: "1.2",
+		},
+	}, {
+		name: "set client certificate validation",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ClientCASecret = types.NamespacedName{Namespace: "kourier-system", Name: "client-ca"}
+			c.ClientAllowedSANs = []string{"a.example.com", "b.example.com"}
+			return c
+		}(),
+		data: map[string]string{
+			clientCASecret:    "kourier-system/client-ca",
+			clientAllowedSANs: "a.example.com,b.example.com",
+		},
+	}, {
+		name: "set upstream client certificate",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamClientCertificateSecret = types.NamespacedName{Namespace: "kourier-system", Name: "upstream-client"}
+			return c
+		}(),
+		data: map[string]string{
+			upstreamClientCertificateSecret: "kourier-system/upstream-client",
+		},
+	}, {
+		name: "set upstream HTTP/2 options",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamHTTP2MaxConcurrentStreams = 1000
+			c.UpstreamHTTP2InitialStreamWindowSize = 1048576
+			c.UpstreamHTTP2InitialConnectionWindowSize = 16777216
+			return c
+		}(),
+		data: map[string]string{
+			upstreamHTTP2MaxConcurrentStreams:        "1000",
+			upstreamHTTP2InitialStreamWindowSize:     "1048576",
+			upstreamHTTP2InitialConnectionWindowSize: "16777216",
+		},
+	}, {
+		name:    "upstream HTTP/2 window size too small",
+		wantErr: true,
+		data: map[string]string{
+			upstreamHTTP2InitialStreamWindowSize: "1024",
+		},
+	}, {
+		name:    "upstream HTTP/2 max concurrent streams too large",
+		wantErr: true,
+		data: map[string]string{
+			upstreamHTTP2MaxConcurrentStreams: "4294967295",
+		},
+	}, {
+		name: "enable upstream HTTP/1 trailers",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamHTTP1EnableTrailers = true
+			return c
+		}(),
+		data: map[string]string{
+			upstreamHTTP1EnableTrailers: "true",
+		},
+	}, {
+		name: "enable upstream ALPN negotiation",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamALPNNegotiation = true
+			return c
+		}(),
+		data: map[string]string{
+			upstreamALPNNegotiation: "true",
+		},
+	}, {
+		name: "use SPIFFE for upstream TLS",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SPIFFEWorkloadAPISocket = "/run/spire/sockets/agent.sock"
+			c.SPIFFEUpstreamSANPatterns = []string{"spiffe://example.org/ns/*"}
+			return c
+		}(),
+		data: map[string]string{
+			spiffeWorkloadAPISocket:   "/run/spire/sockets/agent.sock",
+			spiffeUpstreamSANPatterns: "spiffe://example.org/ns/*",
+		},
+	}, {
+		name: "use legacy upstream SAN validation",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamSANValidation = UpstreamSANValidationLegacy
+			return c
+		}(),
+		data: map[string]string{
+			upstreamSANValidation: "legacy",
+		},
+	}, {
+		name: "use identity upstream SAN validation",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamSANValidation = UpstreamSANValidationIdentity
+			return c
+		}(),
+		data: map[string]string{
+			upstreamSANValidation: "identity",
+		},
+	}, {
+		name:    "unknown upstream SAN validation",
+		wantErr: true,
+		data: map[string]string{
+			upstreamSANValidation: "none",
+		},
+	}, {
+		name: "set upstream request budget",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamRequestBudget = 30 * time.Second
+			return c
+		}(),
+		data: map[string]string{
+			upstreamRequestBudget: "30s",
+		},
+	}, {
+		name:    "negative upstream request budget",
+		wantErr: true,
+		data: map[string]string{
+			upstreamRequestBudget: "-1s",
+		},
+	}, {
+		name: "strip host port",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.StripHostPort = true
+			return c
+		}(),
+		data: map[string]string{
+			stripHostPort: "true",
+		},
+	}, {
+		name: "set snapshot debounce window",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SnapshotDebounceWindow = 200 * time.Millisecond
+			return c
+		}(),
+		data: map[string]string{
+			snapshotDebounceWindow: "200ms",
+		},
+	}, {
+		name:    "negative snapshot debounce window",
+		wantErr: true,
+		data: map[string]string{
+			snapshotDebounceWindow: "-200ms",
+		},
+	}, {
+		name: "merge shared hosts",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.MergeSharedHosts = true
+			return c
+		}(),
+		data: map[string]string{
+			mergeSharedHosts: "true",
+		},
+	}, {
+		name: "virtual host discovery",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.VirtualHostDiscovery = true
+			return c
+		}(),
+		data: map[string]string{
+			virtualHostDiscovery: "true",
+		},
+	}, {
+		name: "activator failover",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ActivatorFailover = true
+			return c
+		}(),
+		data: map[string]string{
+			activatorFailover: "true",
+		},
+	}, {
+		name: "set listener pools",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ListenerPools = map[string]ListenerPool{
+				"partner": {Port: 7443, AllowedSourceCIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"}, RequireTLS: true},
+				"public":  {Port: 7080},
+			}
+			return c
+		}(),
+		data: map[string]string{
+			listenerPools: `{
+				"partner": {"port": 7443, "allowedSourceCIDRs": ["10.0.0.0/8", "192.168.1.0/24"], "requireTLS": true},
+				"public": {"port": 7080}
+			}`,
+		},
+	}, {
+		name:    "listener pools not JSON",
+		wantErr: true,
+		data: map[string]string{
+			listenerPools: "partner: 7443",
+		},
+	}, {
+		name:    "listener pool with reserved port",
+		wantErr: true,
+		data: map[string]string{
+			listenerPools: `{"partner": {"port": 8443}}`,
+		},
+	}, {
+		name:    "listener pools sharing a port",
+		wantErr: true,
+		data: map[string]string{
+			listenerPools: `{"a": {"port": 7443}, "b": {"port": 7443}}`,
+		},
+	}, {
+		name:    "listener pool with invalid CIDR",
+		wantErr: true,
+		data: map[string]string{
+			listenerPools: `{"partner": {"port": 7443, "allowedSourceCIDRs": ["10.0.0.0"]}}`,
+		},
+	}, {
+		name: "endpoint health status",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.EndpointHealthStatus = true
+			return c
+		}(),
+		data: map[string]string{
+			endpointHealthStatus: "true",
+		},
+	}, {
+		name: "set preconnect ratios",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamPreconnectRatio = 1.5
+			c.UpstreamPredictivePreconnectRatio = 3
+			return c
+		}(),
+		data: map[string]string{
+			upstreamPreconnectRatio:           "1.5",
+			upstreamPredictivePreconnectRatio: "3",
+		},
+	}, {
+		name: "set internal traffic priority",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.InternalTrafficPriority = true
+			c.ExternalMaxRequests = 100
+			c.InternalMaxRequests = 50
+			return c
+		}(),
+		data: map[string]string{
+			internalTrafficPriority: "true",
+			externalMaxRequests:     "100",
+			internalMaxRequests:     "50",
+		},
+	}, {
+		name: "enable gRPC-Web",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.GRPCWeb = true
+			return c
+		}(),
+		data: map[string]string{
+			grpcWeb: "true",
+		},
+	}, {
+		name: "enable scanner mitigation",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ScannerMitigation = true
+			c.ScannerDenyPaths = []string{"/.env", "/admin"}
+			c.ScannerDenyUserAgents = []string{"curl"}
+			c.ScannerRateLimit = 10
+			return c
+		}(),
+		data: map[string]string{
+			scannerMitigation:     "true",
+			scannerDenyPaths:      "/.env, /admin",
+			scannerDenyUserAgents: "curl",
+			scannerRateLimit:      "10",
+		},
+	}, {
+		name:    "scanner deny path without leading slash",
+		wantErr: true,
+		data: map[string]string{
+			scannerDenyPaths: "/.env,admin",
+		},
+	}, {
+		name: "enable gRPC JSON transcoding",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.GRPCJSONTranscoding = true
+			return c
+		}(),
+		data: map[string]string{
+			grpcJSONTranscoding: "true",
+		},
+	}, {
+		name: "enable gateway error header",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.GatewayErrorHeader = true
+			return c
+		}(),
+		data: map[string]string{
+			gatewayErrorHeader: "true",
+		},
+	}, {
+		name: "set timeouts",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.MaxStreamDuration = time.Hour
+			c.ConnectionIdleTimeout = 10 * time.Minute
+			c.UpstreamMaxStreamDuration = 2 * time.Hour
+			c.UpstreamConnectionIdleTimeout = time.Minute
+			return c
+		}(),
+		data: map[string]string{
+			maxStreamDuration:             "1h",
+			connectionIdleTimeout:         "10m",
+			upstreamMaxStreamDuration:     "2h",
+			upstreamConnectionIdleTimeout: "1m",
+		},
+	}, {
+		name:    "negative upstream connection idle timeout",
+		wantErr: true,
+		data: map[string]string{
+			upstreamConnectionIdleTimeout: "-1m",
+		},
+	}, {
+		name: "set upstream connect timeout and TCP keepalive",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UpstreamConnectTimeout = 30 * time.Second
+			c.UpstreamTCPKeepaliveTime = 5 * time.Minute
+			c.UpstreamTCPKeepaliveInterval = time.Minute
+			c.UpstreamTCPKeepaliveProbes = 3
+			return c
+		}(),
+		data: map[string]string{
+			upstreamConnectTimeout:       "30s",
+			upstreamTCPKeepaliveTime:     "5m",
+			upstreamTCPKeepaliveInterval: "1m",
+			upstreamTCPKeepaliveProbes:   "3",
+		},
+	}, {
+		name:    "negative upstream connect timeout",
+		wantErr: true,
+		data: map[string]string{
+			upstreamConnectTimeout: "-1s",
+		},
+	}, {
+		name:    "fractional upstream TCP keepalive interval",
+		wantErr: true,
+		data: map[string]string{
+			upstreamTCPKeepaliveInterval: "1500ms",
+		},
+	}, {
+		name: "set request timeouts",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.RequestTimeout = time.Minute
+			c.RequestHeadersTimeout = 10 * time.Second
+			return c
+		}(),
+		data: map[string]string{
+			requestTimeout:        "1m",
+			requestHeadersTimeout: "10s",
+		},
+	}, {
+		name:    "negative request headers timeout",
+		wantErr: true,
+		data: map[string]string{
+			requestHeadersTimeout: "-10s",
+		},
+	}, {
+		name: "set internal upgrade protection",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.InternalUpgradeProtection = true
+			c.InternalAllowedUpgrades = []string{"websocket", "CONNECT"}
+			return c
+		}(),
+		data: map[string]string{
+			internalUpgradeProtection: "true",
+			internalAllowedUpgrades:   "websocket, CONNECT",
+		},
+	}, {
+		name:    "h2c among the internal allowed upgrades",
+		wantErr: true,
+		data: map[string]string{
+			internalAllowedUpgrades: "websocket,H2C",
+		},
+	}, {
+		name: "set generated resources configmaps",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.GeneratedResourcesConfigMaps = true
+			return c
+		}(),
+		data: map[string]string{
+			generatedResourcesConfigMaps: "true",
+		},
+	}, {
+		name: "set canary override header",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.CanaryOverrideHeader = "X-Canary"
+			return c
+		}(),
+		data: map[string]string{
+			canaryOverrideHeader: "X-Canary",
+		},
+	}, {
+		name:    "invalid canary override header",
+		wantErr: true,
+		data: map[string]string{
+			canaryOverrideHeader: "X Canary",
+		},
+	}, {
+		name: "set retry after max delay",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.RetryAfterMaxDelay = 5 * time.Second
+			return c
+		}(),
+		data: map[string]string{
+			retryAfterMaxDelay: "5s",
+		},
+	}, {
+		name:    "negative retry after max delay",
+		wantErr: true,
+		data: map[string]string{
+			retryAfterMaxDelay: "-1s",
+		},
+	}, {
+		name: "set snapshot diff logging",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SnapshotDiffLogging = true
+			return c
+		}(),
+		data: map[string]string{
+			snapshotDiffLogging: "true",
+		},
+	}, {
+		name: "set ingress stats tags",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.IngressStatsTags = true
+			return c
+		}(),
+		data: map[string]string{
+			ingressStatsTags: "true",
+		},
+	}, {
+		name: "set behind-edge profile",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.Profile = ProfileBehindEdge
+			c.XffNumTrustedHops = 1
+			c.DisableTLSTermination = true
+			c.DisableHTTPRedirects = true
+			return c
+		}(),
+		data: map[string]string{
+			profile: "behind-edge",
+		},
+	}, {
+		name: "override keys of the behind-edge profile",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.Profile = ProfileBehindEdge
+			c.XffNumTrustedHops = 2
+			c.DisableTLSTermination = true
+			return c
+		}(),
+		data: map[string]string{
+			profile:              "behind-edge",
+			xffNumTrustedHops:    "2",
+			disableHTTPRedirects: "false",
+		},
+	}, {
+		name:    "unknown profile",
+		wantErr: true,
+		data: map[string]string{
+			profile: "edge",
+		},
+	}, {
+		name: "set status probing",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.StatusProbeConcurrency = 50
+			c.StatusProbeTimeout = 3 * time.Second
+			c.StatusProbeBackoffBase = 100 * time.Millisecond
+			c.StatusProbeBackoffMax = 10 * time.Second
+			c.StatusProbeHTTPPort = 8080
+			c.StatusProbeHTTPSPort = 8443
+			return c
+		}(),
+		data: map[string]string{
+			statusProbeConcurrency: "50",
+			statusProbeTimeout:     "3s",
+			statusProbeBackoffBase: "100ms",
+			statusProbeBackoffMax:  "10s",
+			statusProbeHTTPPort:    "8080",
+			statusProbeHTTPSPort:   "8443",
+		},
+	}, {
+		name: "set policy route stats",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.PolicyRouteStats = true
+			return c
+		}(),
+		data: map[string]string{
+			policyRouteStats: "true",
+		},
+	}, {
+		name: "set external name DNS",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ExternalNameDNSRefreshRate = 30 * time.Second
+			c.ExternalNameDNSFailureRefreshRate = time.Second
+			c.ExternalNameDNSFailure = ExternalNameDNSFailFast
+			return c
+		}(),
+		data: map[string]string{
+			externalNameDNSRefreshRate:        "30s",
+			externalNameDNSFailureRefreshRate: "1s",
+			externalNameDNSFailure:            "fail-fast",
+		},
+	}, {
+		name: "set runtime overrides",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.RuntimeOverrides = map[string]string{
+				"overload.global_downstream_max_connections": "50000",
+				"envoy.reloadable_features.example":          "false",
+				"upgrades.websocket.enabled":                 "0.5",
+				"example.string":                             "value",
+			}
+			return c
+		}(),
+		data: map[string]string{
+			runtimeOverrides: `{
+				"overload.global_downstream_max_connections": 50000,
+				"envoy.reloadable_features.example": false,
+				"upgrades.websocket.enabled": 0.5,
+				"example.string": "value"
+			}`,
+		},
+	}, {
+		name: "set overload manager",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			heap := resource.MustParse("1Gi")
+			c.OverloadMaxHeapSize = &heap
+			c.OverloadShrinkHeapThreshold = 0.9
+			c.OverloadStopAcceptingRequestsThreshold = 0.95
+			c.OverloadMaxDownstreamConnections = 50000
+			return c
+		}(),
+		data: map[string]string{
+			overloadMaxHeapSize:                    "1Gi",
+			overloadShrinkHeapThreshold:            "0.9",
+			overloadStopAcceptingRequestsThreshold: "0.95",
+			overloadMaxDownstreamConnections:       "50000",
+		},
+	}, {
+		name: "enable load reporting",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.LoadReporting = true
+			return c
+		}(),
+		data: map[string]string{
+			loadReporting: "true",
+		},
+	}, {
+		name: "enable description metadata",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.DescriptionMetadata = true
+			return c
+		}(),
+		data: map[string]string{
+			descriptionMetadata: "true",
+		},
+	}, {
+		name: "enable dynamic forward proxy",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.DynamicForwardProxy = true
+			return c
+		}(),
+		data: map[string]string{
+			dynamicForwardProxy: "true",
+		},
+	}, {
+		name: "set header matcher tree min routes",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.HeaderMatcherTreeMinRoutes = 100
+			return c
+		}(),
+		data: map[string]string{
+			headerMatcherTreeMinRoutes: "100",
+		},
+	}, {
+		name: "enable Wasm extensions",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.WasmExtensions = true
+			return c
+		}(),
+		data: map[string]string{
+			wasmExtensions: "true",
+		},
+	}, {
+		name: "enable Lua filters",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.LuaFilters = true
+			c.LuaFilterMaxBytes = 1024
+			return c
+		}(),
+		data: map[string]string{
+			luaFilters:        "true",
+			luaFilterMaxBytes: "1024",
+		},
+	}, {
+		name: "trace the control path",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ControlPathTracingEndpoint = "otel-collector.observability:55678"
+			return c
+		}(),
+		data: map[string]string{
+			controlPathTracingEndpoint: "otel-collector.observability:55678",
+		},
+	}, {
+		name: "enable source IP filters",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SourceIPFilters = true
+			return c
+		}(),
+		data: map[string]string{
+			sourceIPFilters: "true",
+		},
+	}, {
+		name: "detect the original IP by header without appending to X-Forwarded-For",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SkipXffAppend = true
+			c.OriginalIPHeader = "CF-Connecting-IP"
+			return c
+		}(),
+		data: map[string]string{
+			skipXffAppend:    "true",
+			originalIPHeader: "CF-Connecting-IP",
+		},
+	}, {
+		name:    "invalid original IP header",
+		wantErr: true,
+		data: map[string]string{
+			originalIPHeader: "CF Connecting IP",
+		},
+	}, {
+		name:    "original IP header with trusted hops",
+		wantErr: true,
+		data: map[string]string{
+			originalIPHeader:  "CF-Connecting-IP",
+			xffNumTrustedHops: "1",
+		},
+	}, {
+		name:    "overload threshold above 1",
+		wantErr: true,
+		data: map[string]string{
+			overloadMaxHeapSize:         "1Gi",
+			overloadShrinkHeapThreshold: "1.5",
+		},
+	}, {
+		name:    "overload threshold without heap size",
+		wantErr: true,
+		data: map[string]string{
+			overloadStopAcceptingRequestsThreshold: "0.95",
+		},
+	}, {
+		name:    "negative overload heap size",
+		wantErr: true,
+		data: map[string]string{
+			overloadMaxHeapSize: "-1Gi",
+		},
+	}, {
+		name:    "runtime override with a nested value",
+		wantErr: true,
+		data: map[string]string{
+			runtimeOverrides: `{"example": {"numerator": 1}}`,
+		},
+	}, {
+		name:    "invalid runtime overrides",
+		wantErr: true,
+		data: map[string]string{
+			runtimeOverrides: `["example"]`,
+		},
+	}, {
+		name:    "unknown external name DNS failure behavior",
+		wantErr: true,
+		data: map[string]string{
+			externalNameDNSFailure: "retry",
+		},
+	}, {
+		name:    "external name DNS failure refresh rate too low",
+		wantErr: true,
+		data: map[string]string{
+			externalNameDNSFailureRefreshRate: "100us",
+		},
+	}, {
+		name:    "status probe concurrency too high",
+		wantErr: true,
+		data: map[string]string{
+			statusProbeConcurrency: "101",
+		},
+	}, {
+		name:    "status probe backoff base greater than max",
+		wantErr: true,
+		data: map[string]string{
+			statusProbeBackoffBase: "1m",
+			statusProbeBackoffMax:  "10s",
+		},
+	}, {
+		name:    "status probe port too high",
+		wantErr: true,
+		data: map[string]string{
+			statusProbeHTTPSPort: "65536",
+		},
+	}, {
+		name: "set request limits",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.MaxRequestHeadersKB = 96
+			c.MaxRequestBodyBytes = 10485760
+			return c
+		}(),
+		data: map[string]string{
+			maxRequestHeadersKB: "96",
+			maxRequestBodyBytes: "10485760",
+		},
+	}, {
+		name:    "max request headers too large",
+		wantErr: true,
+		data: map[string]string{
+			maxRequestHeadersKB: "8193",
+		},
+	}, {
+		name: "set unmatched host response",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UnmatchedHostStatus = 404
+			c.UnmatchedHostBody = "<h1>Not found</h1>"
+			c.UnmatchedHostContentType = "text/html"
+			return c
+		}(),
+		data: map[string]string{
+			unmatchedHostStatus:      "404",
+			unmatchedHostBody:        "<h1>Not found</h1>",
+			unmatchedHostContentType: "text/html",
+		},
+	}, {
+		name: "set unmatched host redirect",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.UnmatchedHostStatus = 301
+			c.UnmatchedHostRedirect = "https://www.example.com/"
+			return c
+		}(),
+		data: map[string]string{
+			unmatchedHostStatus:   "301",
+			unmatchedHostRedirect: "https://www.example.com/",
+		},
+	}, {
+		name:    "invalid unmatched host status",
+		wantErr: true,
+		data: map[string]string{
+			unmatchedHostStatus: "99",
+		},
+	}, {
+		name:    "unmatched host redirect with a body",
+		wantErr: true,
+		data: map[string]string{
+			unmatchedHostRedirect: "https://www.example.com/",
+			unmatchedHostBody:     "Not found",
+		},
+	}, {
+		name:    "relative unmatched host redirect",
+		wantErr: true,
+		data: map[string]string{
+			unmatchedHostRedirect: "/not-found",
+		},
+	}, {
+		name:    "unmatched host redirect with a non-redirect status",
+		wantErr: true,
+		data: map[string]string{
+			unmatchedHostRedirect: "https://www.example.com/",
+			unmatchedHostStatus:   "404",
+		},
+	}, {
+		name:    "unmatched host response with virtual host discovery",
+		wantErr: true,
+		data: map[string]string{
+			unmatchedHostBody:    "Not found",
+			virtualHostDiscovery: "true",
+		},
+	}, {
+		name: "set header metadata",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.HeaderMetadata = []string{"x-tenant-id=tenant", "x-plan=plan"}
+			return c
+		}(),
+		data: map[string]string{
+			headerMetadata: "x-tenant-id=tenant, x-plan=plan",
+		},
+	}, {
+		name:    "header metadata without key",
+		wantErr: true,
+		data: map[string]string{
+			headerMetadata: "x-tenant-id",
+		},
+	}, {
+		name:    "header metadata with invalid header",
+		wantErr: true,
+		data: map[string]string{
+			headerMetadata: "x tenant=tenant",
+		},
+	}, {
+		name: "set error pages",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.ErrorPageBody = "<h1>%RESPONSE_CODE%</h1>"
+			c.ErrorPageContentType = "text/html"
+			c.InternalErrorPageBody = `{"code": %RESPONSE_CODE%}`
+			return c
+		}(),
+		data: map[string]string{
+			errorPageBody:         "<h1>%RESPONSE_CODE%</h1>",
+			errorPageContentType:  "text/html",
+			internalErrorPageBody: `{"code": %RESPONSE_CODE%}`,
+		},
+	}, {
+		name:    "error page content type without body",
+		wantErr: true,
+		data: map[string]string{
+			internalErrorPageContentType: "application/json",
+		},
+	}, {
+		name: "enable endpoint draining",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.EndpointDraining = true
+			return c
+		}(),
+		data: map[string]string{
+			endpointDraining: "true",
+		},
+	}, {
+		name: "set snapshot watchdog deadline",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.SnapshotWatchdogDeadline = time.Minute
+			return c
+		}(),
+		data: map[string]string{
+			snapshotWatchdogDeadline: "1m",
+		},
+	}, {
+		name:    "negative snapshot watchdog deadline",
+		wantErr: true,
+		data: map[string]string{
+			snapshotWatchdogDeadline: "-1m",
+		},
+	}, {
+		name: "enable internal network policy",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.InternalNetworkPolicy = true
+			return c
+		}(),
+		data: map[string]string{
+			internalNetworkPolicy: "true",
+		},
+	}, {
+		name:    "internal max requests without internal traffic priority",
+		wantErr: true,
+		data: map[string]string{
+			internalMaxRequests: "50",
+		},
+	}, {
+		name:    "preconnect ratio too small",
+		wantErr: true,
+		data: map[string]string{
+			upstreamPreconnectRatio: "0.5",
+		},
+	}, {
+		name:    "predictive preconnect ratio too large",
+		wantErr: true,
+		data: map[string]string{
+			upstreamPredictivePreconnectRatio: "3.5",
+		},
+	}, {
+		name: "set gateway fleets",
+		want: func() *Kourier {
+			c := DefaultConfig()
+			c.GatewayFleets = map[string]GatewayFleet{
+				"tenant-a": {NodeID: "kourier-tenant-a", ExternalService: "kourier-tenant-a", InternalService: "kourier-internal-tenant-a"},
+				"tenant-b": {NodeID: "kourier-tenant-b", ExternalService: "tenant-b", InternalService: "tenant-b-internal"},
+			}
+			return c
+		}(),
+		data: map[string]string{
+			gatewayFleets: `{
+				"tenant-a": {"nodeID": "kourier-tenant-a"},
+				"tenant-b": {"nodeID": "kourier-tenant-b", "externalService": "tenant-b", "internalService": "tenant-b-internal"}
+			}`,
+		},
+	}, {
+		name:    "gateway fleet without node ID",
+		wantErr: true,
+		data: map[string]string{
+			gatewayFleets: `{"tenant-a": {}}`,
+		},
+	}, {
+		name:    "gateway fleets sharing a node ID",
+		wantErr: true,
+		data: map[string]string{
+			gatewayFleets: `{"a": {"nodeID": "kourier-tenant"}, "b": {"nodeID": "kourier-tenant"}}`,
+		},
+	}, {
+		name:    "gateway fleet with the node ID of the shared gateways",
+		wantErr: true,
+		data: map[string]string{
+			gatewayFleets: `{"tenant-a": {"nodeID": "3scale-kourier-gateway"}}`,
+		},
 	}}
 
 	for _, tt := range configTests {
@@ -134,3 +1176,60 @@ func TestKourierConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestScannerDenyRules(t *testing.T) {
+	paths, userAgents := (&Kourier{}).ScannerDenyRules()
+	if diff := cmp.Diff(paths, defaultScannerDenyPaths); diff != "" {
+		t.Errorf("Paths mismatch: diff(-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff(userAgents, defaultScannerDenyUserAgents); diff != "" {
+		t.Errorf("User agents mismatch: diff(-want,+got):\n%s", diff)
+	}
+
+	paths, userAgents = (&Kourier{ScannerDenyPaths: []string{"/admin"}, ScannerDenyUserAgents: []string{"curl"}}).ScannerDenyRules()
+	if diff := cmp.Diff(paths, []string{"/admin"}); diff != "" {
+		t.Errorf("Paths mismatch: diff(-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff(userAgents, []string{"curl"}); diff != "" {
+		t.Errorf("User agents mismatch: diff(-want,+got):\n%s", diff)
+	}
+}
+
+func TestInternalUpgrades(t *testing.T) {
+	if diff := cmp.Diff((&Kourier{}).InternalUpgrades(), []string{"websocket"}); diff != "" {
+		t.Errorf("Upgrades mismatch: diff(-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff((&Kourier{InternalAllowedUpgrades: []string{"CONNECT"}}).InternalUpgrades(), []string{"CONNECT"}); diff != "" {
+		t.Errorf("Upgrades mismatch: diff(-want,+got):\n%s", diff)
+	}
+}
+
+func TestQueryParametersLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Kourier
+		wantLimit int
+		wantOK    bool
+	}{{
+		name: "no limit",
+	}, {
+		name:      "capped",
+		config:    Kourier{MaxQueryParameters: 5},
+		wantLimit: 5,
+		wantOK:    true,
+	}, {
+		name:      "stripped",
+		config:    Kourier{StripQueryParameters: true, MaxQueryParameters: 5},
+		wantLimit: 0,
+		wantOK:    true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, ok := tt.config.QueryParametersLimit()
+			if limit != tt.wantLimit || ok != tt.wantOK {
+				t.Errorf("QueryParametersLimit() = (%d, %t), want (%d, %t)", limit, ok, tt.wantLimit, tt.wantOK)
+			}
+		})
+	}
+}
@@ -185,6 +185,8 @@ func externalAuthZFilter(conf *config) *hcm.HttpFilter {
 			AllowPartialMessage: true,
 		},
 		ClearRouteCache: false,
+		// Pass the metadata extracted from the headers on, e.g. the tenant.
+		MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 	}
 
 	headers := []*core.HeaderValue{{
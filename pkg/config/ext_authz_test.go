@@ -141,6 +141,7 @@ func Test_externalAuthZFilter_extAuthz(t *testing.T) {
 				MaxRequestBytes:     8192,
 				AllowPartialMessage: true,
 			},
+			MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 			Services: &extAuthService.ExtAuthz_GrpcService{
 				GrpcService: &core.GrpcService{
 					TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
@@ -170,6 +171,7 @@ func Test_externalAuthZFilter_extAuthz(t *testing.T) {
 				MaxRequestBytes:     8192,
 				AllowPartialMessage: true,
 			},
+			MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 			Services: &extAuthService.ExtAuthz_HttpService{
 				HttpService: &extAuthService.HttpService{
 					ServerUri: &core.HttpUri{
@@ -203,6 +205,7 @@ func Test_externalAuthZFilter_extAuthz(t *testing.T) {
 				MaxRequestBytes:     8192,
 				AllowPartialMessage: true,
 			},
+			MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 			Services: &extAuthService.ExtAuthz_HttpService{
 				HttpService: &extAuthService.HttpService{
 					ServerUri: &core.HttpUri{
@@ -236,6 +239,7 @@ func Test_externalAuthZFilter_extAuthz(t *testing.T) {
 				MaxRequestBytes:     8192,
 				AllowPartialMessage: true,
 			},
+			MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 			Services: &extAuthService.ExtAuthz_HttpService{
 				HttpService: &extAuthService.HttpService{
 					ServerUri: &core.HttpUri{
@@ -269,6 +273,7 @@ func Test_externalAuthZFilter_extAuthz(t *testing.T) {
 				MaxRequestBytes:     8192,
 				AllowPartialMessage: true,
 			},
+			MetadataContextNamespaces: []string{HeaderMetadataNamespace},
 			Services: &extAuthService.ExtAuthz_HttpService{
 				HttpService: &extAuthService.HttpService{
 					ServerUri: &core.HttpUri{
@@ -21,9 +21,86 @@ limitations under the License.
 
 package config
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayFleet) DeepCopyInto(out *GatewayFleet) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayFleet.
+func (in *GatewayFleet) DeepCopy() *GatewayFleet {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayFleet)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Kourier) DeepCopyInto(out *Kourier) {
 	*out = *in
+	if in.TLSCipherSuites != nil {
+		in, out := &in.TLSCipherSuites, &out.TLSCipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TLSECDHCurves != nil {
+		in, out := &in.TLSECDHCurves, &out.TLSECDHCurves
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientAllowedSANs != nil {
+		in, out := &in.ClientAllowedSANs, &out.ClientAllowedSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SPIFFEUpstreamSANPatterns != nil {
+		in, out := &in.SPIFFEUpstreamSANPatterns, &out.SPIFFEUpstreamSANPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ListenerPools != nil {
+		in, out := &in.ListenerPools, &out.ListenerPools
+		*out = make(map[string]ListenerPool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.GatewayFleets != nil {
+		in, out := &in.GatewayFleets, &out.GatewayFleets
+		*out = make(map[string]GatewayFleet, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ScannerDenyPaths != nil {
+		in, out := &in.ScannerDenyPaths, &out.ScannerDenyPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScannerDenyUserAgents != nil {
+		in, out := &in.ScannerDenyUserAgents, &out.ScannerDenyUserAgents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HeaderMetadata != nil {
+		in, out := &in.HeaderMetadata, &out.HeaderMetadata
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RuntimeOverrides != nil {
+		in, out := &in.RuntimeOverrides, &out.RuntimeOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OverloadMaxHeapSize != nil {
+		in, out := &in.OverloadMaxHeapSize, &out.OverloadMaxHeapSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 	return
 }
 
@@ -36,3 +113,24 @@ func (in *Kourier) DeepCopy() *Kourier {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenerPool) DeepCopyInto(out *ListenerPool) {
+	*out = *in
+	if in.AllowedSourceCIDRs != nil {
+		in, out := &in.AllowedSourceCIDRs, &out.AllowedSourceCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenerPool.
+func (in *ListenerPool) DeepCopy() *ListenerPool {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenerPool)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"time"
+
+	envoyclusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	aggregate "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/aggregate/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const aggregateClusterTypeName = "envoy.clusters.aggregate"
+
+// NewAggregateCluster creates a cluster sending the requests to the first of the given
+// clusters with healthy endpoints, failing over to the next ones as the endpoints of
+// the previous ones become unhealthy or disappear.
+func NewAggregateCluster(name string, clusters []string) *envoyclusterv3.Cluster {
+	clusterConfig, _ := anypb.New(&aggregate.ClusterConfig{
+		Clusters: clusters,
+	})
+
+	return &envoyclusterv3.Cluster{
+		Name: name,
+		ClusterDiscoveryType: &envoyclusterv3.Cluster_ClusterType{
+			ClusterType: &envoyclusterv3.Cluster_CustomClusterType{
+				Name:        aggregateClusterTypeName,
+				TypedConfig: clusterConfig,
+			},
+		},
+		// Aggregate clusters delegate the load balancing to the clusters they aggregate.
+		LbPolicy: envoyclusterv3.Cluster_CLUSTER_PROVIDED,
+	}
+}
+
+// SetFailoverOutlierDetection ejects the endpoints of the cluster failing to serve
+// requests, i.e. refusing connections or responding with a 502, 503 or 504, so that an
+// aggregate cluster fails over to its next cluster right away. Errors of the
+// application don't eject its endpoints.
+func SetFailoverOutlierDetection(cluster *envoyclusterv3.Cluster) {
+	cluster.OutlierDetection = &envoyclusterv3.OutlierDetection{
+		ConsecutiveGatewayFailure:          wrapperspb.UInt32(1),
+		EnforcingConsecutiveGatewayFailure: wrapperspb.UInt32(100),
+		EnforcingConsecutive_5Xx:           wrapperspb.UInt32(0),
+		EnforcingSuccessRate:               wrapperspb.UInt32(0),
+		Interval:                           durationpb.New(time.Second),
+		BaseEjectionTime:                   durationpb.New(5 * time.Second),
+		MaxEjectionPercent:                 wrapperspb.UInt32(100),
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	v3Cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	aggregate "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/aggregate/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewAggregateCluster(t *testing.T) {
+	c := NewAggregateCluster("ns/name/failover", []string{"ns/name", "ns/name/activator"})
+
+	assert.Equal(t, c.GetName(), "ns/name/failover")
+	assert.Equal(t, c.GetLbPolicy(), v3Cluster.Cluster_CLUSTER_PROVIDED)
+	assert.Equal(t, c.GetClusterType().GetName(), "envoy.clusters.aggregate")
+
+	got := &aggregate.ClusterConfig{}
+	err := anypb.UnmarshalTo(c.GetClusterType().GetTypedConfig(), got, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got.Clusters, []string{"ns/name", "ns/name/activator"})
+}
+
+func TestSetFailoverOutlierDetection(t *testing.T) {
+	c := NewCluster("ns/name", 0, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetFailoverOutlierDetection(c)
+
+	// Only failures to serve requests eject the endpoints.
+	assert.Equal(t, c.OutlierDetection.GetEnforcingConsecutiveGatewayFailure().GetValue(), uint32(100))
+	assert.Equal(t, c.OutlierDetection.GetEnforcingConsecutive_5Xx().GetValue(), uint32(0))
+	assert.Equal(t, c.OutlierDetection.GetEnforcingSuccessRate().GetValue(), uint32(0))
+	assert.Equal(t, c.OutlierDetection.GetMaxEjectionPercent().GetValue(), uint32(100))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NewBufferFilter creates the filter buffering the bodies of the requests before they are
+// sent upstream, rejecting the requests whose bodies exceed the given size with 413.
+func NewBufferFilter(maxRequestBytes uint32) *hcm.HttpFilter {
+	filter, _ := anypb.New(&buffer.Buffer{
+		MaxRequestBytes: wrapperspb.UInt32(maxRequestBytes),
+	})
+
+	return &hcm.HttpFilter{
+		Name:       wellknown.Buffer,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// SetMaxRequestBytes overrides the maximum size of the bodies of the requests to the
+// VirtualHost, buffered by the filter created with NewBufferFilter. The bodies are
+// streamed without buffering if maxRequestBytes is 0.
+func SetMaxRequestBytes(vh *route.VirtualHost, maxRequestBytes uint32) {
+	perRoute := &buffer.BufferPerRoute{
+		Override: &buffer.BufferPerRoute_Disabled{Disabled: true},
+	}
+	if maxRequestBytes != 0 {
+		perRoute.Override = &buffer.BufferPerRoute_Buffer{
+			Buffer: &buffer.Buffer{MaxRequestBytes: wrapperspb.UInt32(maxRequestBytes)},
+		}
+	}
+
+	filter, _ := anypb.New(perRoute)
+	if vh.TypedPerFilterConfig == nil {
+		vh.TypedPerFilterConfig = make(map[string]*anypb.Any, 1)
+	}
+	vh.TypedPerFilterConfig[wellknown.Buffer] = filter
+}
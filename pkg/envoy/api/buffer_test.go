@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewBufferFilter(t *testing.T) {
+	filter := NewBufferFilter(1024)
+	assert.Equal(t, filter.Name, wellknown.Buffer)
+
+	got := &buffer.Buffer{}
+	assert.NilError(t, filter.GetTypedConfig().UnmarshalTo(got))
+	assert.Equal(t, got.MaxRequestBytes.GetValue(), uint32(1024))
+}
+
+func TestSetMaxRequestBytes(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{NewRoute("route", nil, "/", nil, 0, nil, "")})
+
+	SetMaxRequestBytes(vh, 2048)
+	got := &buffer.BufferPerRoute{}
+	assert.NilError(t, vh.TypedPerFilterConfig[wellknown.Buffer].UnmarshalTo(got))
+	assert.Equal(t, got.GetBuffer().MaxRequestBytes.GetValue(), uint32(2048))
+
+	SetMaxRequestBytes(vh, 0)
+	got = &buffer.BufferPerRoute{}
+	assert.NilError(t, vh.TypedPerFilterConfig[wellknown.Buffer].UnmarshalTo(got))
+	assert.Assert(t, got.GetDisabled())
+}
@@ -24,10 +24,16 @@ import (
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	httpOptions "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	envoytypev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"knative.dev/net-kourier/pkg/config"
 )
 
+const httpProtocolOptionsKey = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
+
 // NewCluster generates a new v3.Cluster with the given settings.
 func NewCluster(
 	name string,
@@ -52,18 +58,292 @@ func NewCluster(
 	}
 
 	if isHTTP2 {
-		opts, _ := anypb.New(&httpOptions.HttpProtocolOptions{
-			UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_{
-				ExplicitHttpConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig{
-					ProtocolConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{},
+		cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+			httpProtocolOptionsKey: newHTTP2ProtocolOptions(nil),
+		}
+	}
+
+	return cluster
+}
+
+// SetHTTP2ProtocolOptions sets the given HTTP/2 protocol options on a cluster created
+// with NewCluster. It's a no-op if the cluster doesn't use HTTP/2 or options is nil.
+func SetHTTP2ProtocolOptions(cluster *envoyclusterv3.Cluster, options *envoycorev3.Http2ProtocolOptions) {
+	if options == nil || cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] == nil {
+		return
+	}
+	cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] = newHTTP2ProtocolOptions(options)
+}
+
+// SetHTTP1ProtocolOptions sets the given HTTP/1 protocol options on a cluster created
+// with NewCluster, so it has to be called before SetCommonHTTPProtocolOptions. It's a
+// no-op if the cluster uses HTTP/2 or options is nil.
+func SetHTTP1ProtocolOptions(cluster *envoyclusterv3.Cluster, options *envoycorev3.Http1ProtocolOptions) {
+	if options == nil || cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] != nil {
+		return
+	}
+
+	opts, _ := anypb.New(&httpOptions.HttpProtocolOptions{
+		UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{
+					HttpProtocolOptions: options,
 				},
 			},
+		},
+	})
+	if cluster.TypedExtensionProtocolOptions == nil {
+		cluster.TypedExtensionProtocolOptions = make(map[string]*anypb.Any, 1)
+	}
+	cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] = opts
+}
+
+// SetAutoHTTPProtocolOptions makes a cluster created with NewCluster negotiate HTTP/2 or
+// HTTP/1.1 with its upstreams via ALPN, using the given protocol options of either, which
+// may be nil. The transport socket of the cluster has to offer both protocols, so it has
+// to be called instead of SetHTTP2ProtocolOptions and SetHTTP1ProtocolOptions, and before
+// SetCommonHTTPProtocolOptions.
+func SetAutoHTTPProtocolOptions(cluster *envoyclusterv3.Cluster, http1Options *envoycorev3.Http1ProtocolOptions, http2Options *envoycorev3.Http2ProtocolOptions) {
+	opts, _ := anypb.New(&httpOptions.HttpProtocolOptions{
+		UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_AutoConfig{
+			AutoConfig: &httpOptions.HttpProtocolOptions_AutoHttpConfig{
+				HttpProtocolOptions:  http1Options,
+				Http2ProtocolOptions: http2Options,
+			},
+		},
+	})
+	if cluster.TypedExtensionProtocolOptions == nil {
+		cluster.TypedExtensionProtocolOptions = make(map[string]*anypb.Any, 1)
+	}
+	cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] = opts
+}
+
+// SetCommonHTTPProtocolOptions sets the given common HTTP protocol options, like the idle
+// timeout of the connections, on a cluster created with NewCluster, keeping its HTTP/2
+// protocol options, so it has to be called after SetHTTP2ProtocolOptions. It's a no-op if
+// options is nil.
+func SetCommonHTTPProtocolOptions(cluster *envoyclusterv3.Cluster, options *envoycorev3.HttpProtocolOptions) {
+	if options == nil {
+		return
+	}
+
+	protocolOptions := &httpOptions.HttpProtocolOptions{
+		UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{
+					HttpProtocolOptions: &envoycorev3.Http1ProtocolOptions{},
+				},
+			},
+		},
+	}
+	if existing := cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey]; existing != nil {
+		_ = existing.UnmarshalTo(protocolOptions)
+	}
+	protocolOptions.CommonHttpProtocolOptions = options
+
+	opts, _ := anypb.New(protocolOptions)
+	if cluster.TypedExtensionProtocolOptions == nil {
+		cluster.TypedExtensionProtocolOptions = make(map[string]*anypb.Any, 1)
+	}
+	cluster.TypedExtensionProtocolOptions[httpProtocolOptionsKey] = opts
+}
+
+// DisablePanicMode makes the cluster never send requests to unhealthy endpoints. By
+// default, Envoy sends requests to all endpoints once less than half of them are
+// healthy, which would defeat passing on the health status of the endpoints.
+func DisablePanicMode(cluster *envoyclusterv3.Cluster) {
+	cluster.CommonLbConfig = &envoyclusterv3.Cluster_CommonLbConfig{
+		HealthyPanicThreshold: &envoytypev3.Percent{Value: 0},
+	}
+}
+
+// SetPreconnectPolicy makes the cluster establish connections to its upstreams ahead of
+// the requests, as configured in the given config. It's a no-op if neither of the
+// preconnect ratios is configured.
+func SetPreconnectPolicy(cluster *envoyclusterv3.Cluster, kourierConfig *config.Kourier) {
+	if kourierConfig.UpstreamPreconnectRatio == 0 && kourierConfig.UpstreamPredictivePreconnectRatio == 0 {
+		return
+	}
+
+	policy := &envoyclusterv3.Cluster_PreconnectPolicy{}
+	if kourierConfig.UpstreamPreconnectRatio != 0 {
+		policy.PerUpstreamPreconnectRatio = wrapperspb.Double(kourierConfig.UpstreamPreconnectRatio)
+	}
+	if kourierConfig.UpstreamPredictivePreconnectRatio != 0 {
+		policy.PredictivePreconnectRatio = wrapperspb.Double(kourierConfig.UpstreamPredictivePreconnectRatio)
+	}
+	cluster.PreconnectPolicy = policy
+}
+
+// SetCircuitBreakers limits the parallel requests to the cluster of either routing
+// priority, as configured in the given config. It's a no-op if neither of the limits is
+// configured.
+func SetCircuitBreakers(cluster *envoyclusterv3.Cluster, kourierConfig *config.Kourier) {
+	var thresholds []*envoyclusterv3.CircuitBreakers_Thresholds
+	if kourierConfig.ExternalMaxRequests != 0 {
+		thresholds = append(thresholds, &envoyclusterv3.CircuitBreakers_Thresholds{
+			Priority:    envoycorev3.RoutingPriority_DEFAULT,
+			MaxRequests: wrapperspb.UInt32(kourierConfig.ExternalMaxRequests),
+		})
+	}
+	if kourierConfig.InternalMaxRequests != 0 {
+		thresholds = append(thresholds, &envoyclusterv3.CircuitBreakers_Thresholds{
+			Priority:    envoycorev3.RoutingPriority_HIGH,
+			MaxRequests: wrapperspb.UInt32(kourierConfig.InternalMaxRequests),
 		})
+	}
+	if len(thresholds) == 0 {
+		return
+	}
+	cluster.CircuitBreakers = &envoyclusterv3.CircuitBreakers{Thresholds: thresholds}
+}
 
-		cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
-			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": opts,
+// CapMaxRequests caps the parallel requests to the cluster of either routing priority
+// at maxRequests, keeping any lower limits set by SetCircuitBreakers.
+func CapMaxRequests(cluster *envoyclusterv3.Cluster, maxRequests uint32) {
+	if cluster.CircuitBreakers == nil {
+		cluster.CircuitBreakers = &envoyclusterv3.CircuitBreakers{}
+	}
+
+	for _, priority := range []envoycorev3.RoutingPriority{envoycorev3.RoutingPriority_DEFAULT, envoycorev3.RoutingPriority_HIGH} {
+		var threshold *envoyclusterv3.CircuitBreakers_Thresholds
+		for _, t := range cluster.CircuitBreakers.Thresholds {
+			if t.Priority == priority {
+				threshold = t
+			}
+		}
+		if threshold == nil {
+			threshold = &envoyclusterv3.CircuitBreakers_Thresholds{Priority: priority}
+			cluster.CircuitBreakers.Thresholds = append(cluster.CircuitBreakers.Thresholds, threshold)
+		}
+		if threshold.MaxRequests == nil || threshold.MaxRequests.Value > maxRequests {
+			threshold.MaxRequests = wrapperspb.UInt32(maxRequests)
 		}
 	}
+}
 
-	return cluster
+// SetDNSResolution sets how the names of the DNS cluster are resolved again, and the
+// behavior of the cluster while they don't resolve, as configured in the given config for
+// the ExternalName services.
+func SetDNSResolution(cluster *envoyclusterv3.Cluster, kourierConfig *config.Kourier) {
+	if kourierConfig.ExternalNameDNSRefreshRate != 0 {
+		cluster.DnsRefreshRate = durationpb.New(kourierConfig.ExternalNameDNSRefreshRate)
+	}
+	if kourierConfig.ExternalNameDNSFailureRefreshRate != 0 {
+		// The failed resolutions are retried with a backoff, up to 10 times the base.
+		cluster.DnsFailureRefreshRate = &envoyclusterv3.Cluster_RefreshRate{
+			BaseInterval: durationpb.New(kourierConfig.ExternalNameDNSFailureRefreshRate),
+		}
+	}
+	if kourierConfig.ExternalNameDNSFailure == config.ExternalNameDNSFailFast {
+		// Rather than keeping the previous cluster in use until the name resolves.
+		cluster.WaitForWarmOnInit = wrapperspb.Bool(false)
+	}
+}
+
+// NewUpstreamCommonHTTPProtocolOptions creates the common HTTP protocol options for
+// upstream clusters configured in the given config, with the given idle timeout of the
+// connections overriding the configured one, if set. It returns nil if all are left to
+// Envoy's defaults.
+func NewUpstreamCommonHTTPProtocolOptions(kourierConfig *config.Kourier, connectionIdleTimeout time.Duration) *envoycorev3.HttpProtocolOptions {
+	if connectionIdleTimeout == 0 {
+		connectionIdleTimeout = kourierConfig.UpstreamConnectionIdleTimeout
+	}
+	return newCommonHTTPProtocolOptions(connectionIdleTimeout, kourierConfig.UpstreamMaxStreamDuration)
+}
+
+// newCommonHTTPProtocolOptions creates the common HTTP protocol options with the given
+// timeouts. It returns nil if both are 0, leaving them to Envoy's defaults.
+func newCommonHTTPProtocolOptions(idleTimeout, maxStreamDuration time.Duration) *envoycorev3.HttpProtocolOptions {
+	if idleTimeout == 0 && maxStreamDuration == 0 {
+		return nil
+	}
+
+	options := &envoycorev3.HttpProtocolOptions{}
+	if idleTimeout != 0 {
+		options.IdleTimeout = durationpb.New(idleTimeout)
+	}
+	if maxStreamDuration != 0 {
+		options.MaxStreamDuration = durationpb.New(maxStreamDuration)
+	}
+	return options
+}
+
+// NewUpstreamTCPKeepalive creates the TCP keepalive settings of the connections to
+// upstream clusters configured in the given config, with the given keepalive time
+// overriding the configured one, if set. It returns nil if TCP keepalive isn't enabled.
+func NewUpstreamTCPKeepalive(kourierConfig *config.Kourier, keepaliveTime time.Duration) *envoycorev3.TcpKeepalive {
+	if keepaliveTime == 0 {
+		keepaliveTime = kourierConfig.UpstreamTCPKeepaliveTime
+	}
+	if keepaliveTime == 0 && kourierConfig.UpstreamTCPKeepaliveInterval == 0 && kourierConfig.UpstreamTCPKeepaliveProbes == 0 {
+		return nil
+	}
+
+	keepalive := &envoycorev3.TcpKeepalive{}
+	if keepaliveTime != 0 {
+		keepalive.KeepaliveTime = wrapperspb.UInt32(uint32(keepaliveTime.Seconds()))
+	}
+	if kourierConfig.UpstreamTCPKeepaliveInterval != 0 {
+		keepalive.KeepaliveInterval = wrapperspb.UInt32(uint32(kourierConfig.UpstreamTCPKeepaliveInterval.Seconds()))
+	}
+	if kourierConfig.UpstreamTCPKeepaliveProbes != 0 {
+		keepalive.KeepaliveProbes = wrapperspb.UInt32(kourierConfig.UpstreamTCPKeepaliveProbes)
+	}
+	return keepalive
+}
+
+// SetTCPKeepalive enables TCP keepalive with the given settings on the connections of the
+// cluster. It's a no-op if keepalive is nil.
+func SetTCPKeepalive(cluster *envoyclusterv3.Cluster, keepalive *envoycorev3.TcpKeepalive) {
+	if keepalive == nil {
+		return
+	}
+	cluster.UpstreamConnectionOptions = &envoyclusterv3.UpstreamConnectionOptions{TcpKeepalive: keepalive}
+}
+
+// NewUpstreamHTTP2ProtocolOptions creates the HTTP/2 protocol options for upstream
+// clusters configured in the given config. It returns nil if all are left to Envoy's
+// defaults.
+func NewUpstreamHTTP2ProtocolOptions(kourierConfig *config.Kourier) *envoycorev3.Http2ProtocolOptions {
+	if kourierConfig.UpstreamHTTP2MaxConcurrentStreams == 0 &&
+		kourierConfig.UpstreamHTTP2InitialStreamWindowSize == 0 &&
+		kourierConfig.UpstreamHTTP2InitialConnectionWindowSize == 0 {
+		return nil
+	}
+
+	options := &envoycorev3.Http2ProtocolOptions{}
+	if kourierConfig.UpstreamHTTP2MaxConcurrentStreams != 0 {
+		options.MaxConcurrentStreams = wrapperspb.UInt32(kourierConfig.UpstreamHTTP2MaxConcurrentStreams)
+	}
+	if kourierConfig.UpstreamHTTP2InitialStreamWindowSize != 0 {
+		options.InitialStreamWindowSize = wrapperspb.UInt32(kourierConfig.UpstreamHTTP2InitialStreamWindowSize)
+	}
+	if kourierConfig.UpstreamHTTP2InitialConnectionWindowSize != 0 {
+		options.InitialConnectionWindowSize = wrapperspb.UInt32(kourierConfig.UpstreamHTTP2InitialConnectionWindowSize)
+	}
+	return options
+}
+
+// NewUpstreamHTTP1ProtocolOptions creates the HTTP/1 protocol options for upstream
+// clusters configured in the given config. It returns nil if all are left to Envoy's
+// defaults.
+func NewUpstreamHTTP1ProtocolOptions(kourierConfig *config.Kourier) *envoycorev3.Http1ProtocolOptions {
+	if !kourierConfig.UpstreamHTTP1EnableTrailers {
+		return nil
+	}
+	return &envoycorev3.Http1ProtocolOptions{EnableTrailers: true}
+}
+
+func newHTTP2ProtocolOptions(options *envoycorev3.Http2ProtocolOptions) *anypb.Any {
+	opts, _ := anypb.New(&httpOptions.HttpProtocolOptions{
+		UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: options,
+				},
+			},
+		},
+	})
+	return opts
 }
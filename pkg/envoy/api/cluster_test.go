@@ -21,9 +21,17 @@ import (
 	"time"
 
 	v3Cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoycorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	httpOptions "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	"gotest.tools/v3/assert"
+
+	"knative.dev/net-kourier/pkg/config"
 )
 
 func TestNewCluster(t *testing.T) {
@@ -45,3 +53,223 @@ func TestNewCluster(t *testing.T) {
 	c = NewCluster(name, connectTimeout, endpoints, false, nil, v3Cluster.Cluster_STATIC)
 	assert.Assert(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"] == nil)
 }
+
+func TestSetHTTP2ProtocolOptions(t *testing.T) {
+	endpoints := []*endpoint.LbEndpoint{NewLBEndpoint("127.0.0.1", 1234)}
+	options := NewUpstreamHTTP2ProtocolOptions(&config.Kourier{
+		UpstreamHTTP2MaxConcurrentStreams:    100,
+		UpstreamHTTP2InitialStreamWindowSize: 1048576,
+	})
+	assert.DeepEqual(t, options, &envoycorev3.Http2ProtocolOptions{
+		MaxConcurrentStreams:    wrapperspb.UInt32(100),
+		InitialStreamWindowSize: wrapperspb.UInt32(1048576),
+	}, protocmp.Transform())
+
+	// With HTTP2
+	c := NewCluster("test", 5*time.Second, endpoints, true, nil, v3Cluster.Cluster_STATIC)
+	SetHTTP2ProtocolOptions(c, options)
+	got := &httpOptions.HttpProtocolOptions{}
+	err := anypb.UnmarshalTo(c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"], got, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got.GetExplicitHttpConfig().GetHttp2ProtocolOptions(), options, protocmp.Transform())
+
+	// Without HTTP2
+	c = NewCluster("test", 5*time.Second, endpoints, false, nil, v3Cluster.Cluster_STATIC)
+	SetHTTP2ProtocolOptions(c, options)
+	assert.Assert(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"] == nil)
+
+	// Envoy's defaults
+	assert.Assert(t, NewUpstreamHTTP2ProtocolOptions(&config.Kourier{}) == nil)
+}
+
+func TestSetHTTP1ProtocolOptions(t *testing.T) {
+	endpoints := []*endpoint.LbEndpoint{NewLBEndpoint("127.0.0.1", 1234)}
+	options := NewUpstreamHTTP1ProtocolOptions(&config.Kourier{UpstreamHTTP1EnableTrailers: true})
+	assert.DeepEqual(t, options, &envoycorev3.Http1ProtocolOptions{EnableTrailers: true}, protocmp.Transform())
+
+	// Without HTTP2, keeping the options with the common ones.
+	c := NewCluster("test", 5*time.Second, endpoints, false, nil, v3Cluster.Cluster_STATIC)
+	SetHTTP1ProtocolOptions(c, options)
+	SetCommonHTTPProtocolOptions(c, &envoycorev3.HttpProtocolOptions{IdleTimeout: durationpb.New(time.Minute)})
+	got := &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(got))
+	assert.DeepEqual(t, got.GetExplicitHttpConfig().GetHttpProtocolOptions(), options, protocmp.Transform())
+	assert.Equal(t, got.CommonHttpProtocolOptions.IdleTimeout.AsDuration(), time.Minute)
+
+	// With HTTP2
+	c = NewCluster("test", 5*time.Second, endpoints, true, nil, v3Cluster.Cluster_STATIC)
+	SetHTTP1ProtocolOptions(c, options)
+	got = &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(got))
+	assert.Assert(t, got.GetExplicitHttpConfig().GetHttpProtocolOptions() == nil)
+
+	// Envoy's defaults
+	assert.Assert(t, NewUpstreamHTTP1ProtocolOptions(&config.Kourier{}) == nil)
+}
+
+func TestSetAutoHTTPProtocolOptions(t *testing.T) {
+	http2Options := &envoycorev3.Http2ProtocolOptions{MaxConcurrentStreams: wrapperspb.UInt32(100)}
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetAutoHTTPProtocolOptions(c, nil, http2Options)
+
+	// The negotiation is kept along with the common options.
+	SetCommonHTTPProtocolOptions(c, &envoycorev3.HttpProtocolOptions{IdleTimeout: durationpb.New(time.Minute)})
+	got := &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(got))
+	assert.Assert(t, got.GetAutoConfig() != nil)
+	assert.Assert(t, got.GetAutoConfig().HttpProtocolOptions == nil)
+	assert.DeepEqual(t, got.GetAutoConfig().Http2ProtocolOptions, http2Options, protocmp.Transform())
+	assert.Equal(t, got.CommonHttpProtocolOptions.IdleTimeout.AsDuration(), time.Minute)
+}
+
+func TestSetCommonHTTPProtocolOptions(t *testing.T) {
+	endpoints := []*endpoint.LbEndpoint{NewLBEndpoint("127.0.0.1", 1234)}
+	options := NewUpstreamCommonHTTPProtocolOptions(&config.Kourier{
+		UpstreamConnectionIdleTimeout: time.Minute,
+		UpstreamMaxStreamDuration:     time.Hour,
+	}, 0)
+	assert.DeepEqual(t, options, &envoycorev3.HttpProtocolOptions{
+		IdleTimeout:       durationpb.New(time.Minute),
+		MaxStreamDuration: durationpb.New(time.Hour),
+	}, protocmp.Transform())
+
+	// The HTTP2 protocol options are kept.
+	http2Options := &envoycorev3.Http2ProtocolOptions{MaxConcurrentStreams: wrapperspb.UInt32(100)}
+	c := NewCluster("test", 5*time.Second, endpoints, true, nil, v3Cluster.Cluster_STATIC)
+	SetHTTP2ProtocolOptions(c, http2Options)
+	SetCommonHTTPProtocolOptions(c, options)
+	got := &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(got))
+	assert.DeepEqual(t, got.GetExplicitHttpConfig().GetHttp2ProtocolOptions(), http2Options, protocmp.Transform())
+	assert.DeepEqual(t, got.CommonHttpProtocolOptions, options, protocmp.Transform())
+
+	// Without HTTP2
+	c = NewCluster("test", 5*time.Second, endpoints, false, nil, v3Cluster.Cluster_STATIC)
+	SetCommonHTTPProtocolOptions(c, options)
+	got = &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(got))
+	assert.Assert(t, got.GetExplicitHttpConfig().GetHttpProtocolOptions() != nil)
+	assert.DeepEqual(t, got.CommonHttpProtocolOptions, options, protocmp.Transform())
+
+	// The idle timeout of the connections is overridden.
+	assert.Equal(t, NewUpstreamCommonHTTPProtocolOptions(&config.Kourier{UpstreamConnectionIdleTimeout: time.Minute}, time.Second).IdleTimeout.AsDuration(), time.Second)
+
+	// Envoy's defaults
+	assert.Assert(t, NewUpstreamCommonHTTPProtocolOptions(&config.Kourier{}, 0) == nil)
+	c = NewCluster("test", 5*time.Second, endpoints, false, nil, v3Cluster.Cluster_STATIC)
+	SetCommonHTTPProtocolOptions(c, nil)
+	assert.Assert(t, c.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"] == nil)
+}
+
+func TestDisablePanicMode(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	DisablePanicMode(c)
+
+	assert.Equal(t, c.GetCommonLbConfig().GetHealthyPanicThreshold().GetValue(), float64(0))
+	assert.Assert(t, c.GetCommonLbConfig().GetHealthyPanicThreshold() != nil)
+}
+
+func TestSetPreconnectPolicy(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetPreconnectPolicy(c, config.DefaultConfig())
+	assert.Assert(t, c.PreconnectPolicy == nil)
+
+	cfg := config.DefaultConfig()
+	cfg.UpstreamPredictivePreconnectRatio = 2
+	SetPreconnectPolicy(c, cfg)
+	assert.Assert(t, c.PreconnectPolicy.PerUpstreamPreconnectRatio == nil)
+	assert.Equal(t, c.PreconnectPolicy.PredictivePreconnectRatio.GetValue(), float64(2))
+
+	cfg.UpstreamPreconnectRatio = 1.5
+	SetPreconnectPolicy(c, cfg)
+	assert.Equal(t, c.PreconnectPolicy.PerUpstreamPreconnectRatio.GetValue(), 1.5)
+	assert.Equal(t, c.PreconnectPolicy.PredictivePreconnectRatio.GetValue(), float64(2))
+}
+
+func TestSetDNSResolution(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_LOGICAL_DNS)
+	SetDNSResolution(c, config.DefaultConfig())
+	assert.Assert(t, c.DnsRefreshRate == nil)
+	assert.Assert(t, c.DnsFailureRefreshRate == nil)
+	assert.Assert(t, c.WaitForWarmOnInit == nil)
+
+	cfg := config.DefaultConfig()
+	cfg.ExternalNameDNSRefreshRate = 30 * time.Second
+	cfg.ExternalNameDNSFailureRefreshRate = time.Second
+	cfg.ExternalNameDNSFailure = config.ExternalNameDNSFailFast
+	SetDNSResolution(c, cfg)
+	assert.Equal(t, c.DnsRefreshRate.AsDuration(), 30*time.Second)
+	assert.Equal(t, c.DnsFailureRefreshRate.BaseInterval.AsDuration(), time.Second)
+	assert.Assert(t, c.DnsFailureRefreshRate.MaxInterval == nil)
+	assert.Equal(t, c.WaitForWarmOnInit.GetValue(), false)
+}
+
+func TestSetTCPKeepalive(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetTCPKeepalive(c, NewUpstreamTCPKeepalive(config.DefaultConfig(), 0))
+	assert.Assert(t, c.UpstreamConnectionOptions == nil)
+
+	cfg := config.DefaultConfig()
+	cfg.UpstreamTCPKeepaliveTime = 5 * time.Minute
+	cfg.UpstreamTCPKeepaliveProbes = 3
+	SetTCPKeepalive(c, NewUpstreamTCPKeepalive(cfg, 0))
+	assert.DeepEqual(t, c.UpstreamConnectionOptions.TcpKeepalive, &envoycorev3.TcpKeepalive{
+		KeepaliveTime:   wrapperspb.UInt32(300),
+		KeepaliveProbes: wrapperspb.UInt32(3),
+	}, protocmp.Transform())
+
+	// The keepalive time of the Ingress overrides the configured one, enabling keepalive
+	// on its own.
+	SetTCPKeepalive(c, NewUpstreamTCPKeepalive(config.DefaultConfig(), time.Minute))
+	assert.DeepEqual(t, c.UpstreamConnectionOptions.TcpKeepalive, &envoycorev3.TcpKeepalive{
+		KeepaliveTime: wrapperspb.UInt32(60),
+	}, protocmp.Transform())
+}
+
+func TestSetCircuitBreakers(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetCircuitBreakers(c, config.DefaultConfig())
+	assert.Assert(t, c.CircuitBreakers == nil)
+
+	cfg := config.DefaultConfig()
+	cfg.ExternalMaxRequests = 100
+	cfg.InternalMaxRequests = 50
+	SetCircuitBreakers(c, cfg)
+	assert.DeepEqual(t, c.CircuitBreakers, &v3Cluster.CircuitBreakers{
+		Thresholds: []*v3Cluster.CircuitBreakers_Thresholds{{
+			Priority:    envoycorev3.RoutingPriority_DEFAULT,
+			MaxRequests: wrapperspb.UInt32(100),
+		}, {
+			Priority:    envoycorev3.RoutingPriority_HIGH,
+			MaxRequests: wrapperspb.UInt32(50),
+		}},
+	}, protocmp.Transform())
+}
+
+func TestCapMaxRequests(t *testing.T) {
+	c := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	CapMaxRequests(c, 10)
+	assert.DeepEqual(t, c.CircuitBreakers, &v3Cluster.CircuitBreakers{
+		Thresholds: []*v3Cluster.CircuitBreakers_Thresholds{{
+			Priority:    envoycorev3.RoutingPriority_DEFAULT,
+			MaxRequests: wrapperspb.UInt32(10),
+		}, {
+			Priority:    envoycorev3.RoutingPriority_HIGH,
+			MaxRequests: wrapperspb.UInt32(10),
+		}},
+	}, protocmp.Transform())
+
+	// Lower limits are kept.
+	c = NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_STATIC)
+	SetCircuitBreakers(c, &config.Kourier{ExternalMaxRequests: 100, InternalMaxRequests: 5})
+	CapMaxRequests(c, 10)
+	assert.DeepEqual(t, c.CircuitBreakers, &v3Cluster.CircuitBreakers{
+		Thresholds: []*v3Cluster.CircuitBreakers_Thresholds{{
+			Priority:    envoycorev3.RoutingPriority_DEFAULT,
+			MaxRequests: wrapperspb.UInt32(10),
+		}, {
+			Priority:    envoycorev3.RoutingPriority_HIGH,
+			MaxRequests: wrapperspb.UInt32(5),
+		}},
+	}, protocmp.Transform())
+}
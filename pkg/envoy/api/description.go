@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DescriptionMetadataNamespace is the metadata namespace describing the Kubernetes
+// objects a route or cluster was generated from, as shown by the config dump of the
+// gateways.
+const DescriptionMetadataNamespace = "kourier.description"
+
+// SetRouteDescription attaches the given description to the route's metadata.
+func SetRouteDescription(r *route.Route, description map[string]string) {
+	r.Metadata = withDescription(r.Metadata, description)
+}
+
+// SetClusterDescription attaches the given description to the cluster's metadata.
+func SetClusterDescription(c *cluster.Cluster, description map[string]string) {
+	c.Metadata = withDescription(c.Metadata, description)
+}
+
+// withDescription returns the given metadata, or new metadata if nil, holding the given
+// description in its DescriptionMetadataNamespace.
+func withDescription(metadata *core.Metadata, description map[string]string) *core.Metadata {
+	if metadata == nil {
+		metadata = &core.Metadata{}
+	}
+	if metadata.FilterMetadata == nil {
+		metadata.FilterMetadata = make(map[string]*structpb.Struct, 1)
+	}
+	fields := make(map[string]*structpb.Value, len(description))
+	for key, value := range description {
+		fields[key] = structpb.NewStringValue(value)
+	}
+	metadata.FilterMetadata[DescriptionMetadataNamespace] = &structpb.Struct{Fields: fields}
+	return metadata
+}
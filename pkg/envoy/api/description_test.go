@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetDescription(t *testing.T) {
+	r := &route.Route{}
+	SetTransform(r, &structpb.Struct{})
+	SetRouteDescription(r, map[string]string{"ingress_name": "foo", "rule": "0"})
+
+	// The description doesn't replace the other metadata of the route.
+	assert.Equal(t, len(r.Metadata.FilterMetadata), 2)
+	description := r.Metadata.FilterMetadata[DescriptionMetadataNamespace].AsMap()
+	assert.DeepEqual(t, description, map[string]interface{}{"ingress_name": "foo", "rule": "0"})
+
+	c := &cluster.Cluster{}
+	SetClusterDescription(c, map[string]string{"service_name": "bar"})
+	description = c.Metadata.FilterMetadata[DescriptionMetadataNamespace].AsMap()
+	assert.DeepEqual(t, description, map[string]interface{}{"service_name": "bar"})
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	envoycorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// SetDrainingListener makes the HTTP connection managers of the listener close their
+// connections after a single request, so that the clients of a draining gateway
+// reconnect to other ones.
+func SetDrainingListener(l *listener.Listener) error {
+	for _, filterChain := range l.FilterChains {
+		for _, filter := range filterChain.Filters {
+			if filter.Name != wellknown.HTTPConnectionManager {
+				continue
+			}
+			manager := &hcm.HttpConnectionManager{}
+			if err := filter.GetTypedConfig().UnmarshalTo(manager); err != nil {
+				return err
+			}
+			if manager.CommonHttpProtocolOptions == nil {
+				manager.CommonHttpProtocolOptions = &envoycorev3.HttpProtocolOptions{}
+			}
+			manager.CommonHttpProtocolOptions.MaxRequestsPerConnection = wrapperspb.UInt32(1)
+
+			config, err := anypb.New(manager)
+			if err != nil {
+				return err
+			}
+			filter.ConfigType = &listener.Filter_TypedConfig{TypedConfig: config}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestSetDrainingListener(t *testing.T) {
+	l, err := NewHTTPListener(NewHTTPConnectionManager("routes", &config.Kourier{}), 8080, false)
+	assert.NilError(t, err)
+	// Filter chains proxying TCP connections are left as they are.
+	passthrough := &listener.FilterChain{Filters: []*listener.Filter{{Name: "envoy.filters.network.tcp_proxy"}}}
+	l.FilterChains = append(l.FilterChains, passthrough)
+
+	assert.NilError(t, SetDrainingListener(l))
+
+	manager := &hcm.HttpConnectionManager{}
+	assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+	assert.Equal(t, manager.CommonHttpProtocolOptions.MaxRequestsPerConnection.GetValue(), uint32(1))
+	assert.Equal(t, manager.GetRds().RouteConfigName, "routes")
+	assert.Assert(t, l.FilterChains[1].Filters[0].ConfigType == nil)
+}
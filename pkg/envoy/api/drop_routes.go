@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extAuthService "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// DropRoute represents the requests to a path which the gateways reject themselves,
+// rather than routing them upstream.
+type DropRoute struct {
+	Path string
+	// Exact matches the path exactly rather than as a prefix.
+	Exact bool
+	// Methods are the methods of the rejected requests, all methods if empty.
+	Methods []string
+	// Status is the status of the rejections.
+	Status uint32
+}
+
+// AddDropRoutes adds a route rejecting the requests matching each of the given drop
+// routes to the VirtualHost, ahead of its other routes.
+func AddDropRoutes(vh *route.VirtualHost, drops []DropRoute) {
+	// The rejected requests aren't authorized, as there's no backend to authorize them for.
+	extAuthzDisabled, _ := anypb.New(&extAuthService.ExtAuthzPerRoute{
+		Override: &extAuthService.ExtAuthzPerRoute_Disabled{Disabled: true},
+	})
+
+	routes := make([]*route.Route, 0, len(drops)+len(vh.Routes))
+	for i, drop := range drops {
+		match := &route.RouteMatch{}
+		if drop.Exact {
+			match.PathSpecifier = &route.RouteMatch_Path{Path: drop.Path}
+		} else {
+			match.PathSpecifier = &route.RouteMatch_Prefix{Prefix: drop.Path}
+		}
+		if len(drop.Methods) != 0 {
+			match.Headers = []*route.HeaderMatcher{newMethodsMatcher(drop.Methods)}
+		}
+
+		routes = append(routes, &route.Route{
+			Name:  fmt.Sprintf("%s/drop[%d]", vh.Name, i),
+			Match: match,
+			Action: &route.Route_DirectResponse{
+				DirectResponse: &route.DirectResponseAction{Status: drop.Status},
+			},
+			TypedPerFilterConfig: map[string]*anypb.Any{
+				wellknown.HTTPExternalAuthorization: extAuthzDisabled,
+			},
+		})
+	}
+	vh.Routes = append(routes, vh.Routes...)
+}
+
+// newMethodsMatcher creates a header matcher matching the requests with any of the given
+// methods.
+func newMethodsMatcher(methods []string) *route.HeaderMatcher {
+	quoted := make([]string, 0, len(methods))
+	for _, method := range methods {
+		quoted = append(quoted, regexp.QuoteMeta(method))
+	}
+	return &route.HeaderMatcher{
+		Name: ":method",
+		HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+			StringMatch: &envoymatcherv3.StringMatcher{
+				MatchPattern: &envoymatcherv3.StringMatcher_SafeRegex{
+					SafeRegex: &envoymatcherv3.RegexMatcher{
+						EngineType: &envoymatcherv3.RegexMatcher_GoogleRe2{GoogleRe2: &envoymatcherv3.RegexMatcher_GoogleRE2{}},
+						Regex:      "^(" + strings.Join(quoted, "|") + ")$",
+					},
+				},
+			},
+		},
+	}
+}
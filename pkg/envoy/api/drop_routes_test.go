@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"gotest.tools/v3/assert"
+)
+
+func TestAddDropRoutes(t *testing.T) {
+	upstream := NewRoute("upstream", nil, "/", nil, 0, nil, "")
+	vh := NewVirtualHost("hello", []string{"hello.example.com"}, []*route.Route{upstream})
+
+	AddDropRoutes(vh, []DropRoute{{
+		Path:    "/metrics",
+		Exact:   true,
+		Methods: []string{"GET", "HEAD"},
+		Status:  404,
+	}, {
+		Path:   "/internal/",
+		Status: 403,
+	}})
+
+	// The drop routes precede the others.
+	assert.Equal(t, len(vh.Routes), 3)
+	assert.Assert(t, vh.Routes[2] == upstream)
+
+	metrics := vh.Routes[0]
+	assert.Equal(t, metrics.Name, "hello/drop[0]")
+	assert.Equal(t, metrics.Match.GetPath(), "/metrics")
+	assert.Equal(t, len(metrics.Match.Headers), 1)
+	assert.Equal(t, metrics.Match.Headers[0].Name, ":method")
+	assert.Equal(t, metrics.Match.Headers[0].GetStringMatch().GetSafeRegex().Regex, "^(GET|HEAD)$")
+	assert.Equal(t, metrics.GetDirectResponse().Status, uint32(404))
+	assert.Assert(t, metrics.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+
+	internal := vh.Routes[1]
+	assert.Equal(t, internal.Match.GetPrefix(), "/internal/")
+	assert.Equal(t, len(internal.Match.Headers), 0)
+	assert.Equal(t, internal.GetDirectResponse().Status, uint32(403))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"time"
+
+	envoyclusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoycorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	dfpcluster "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/dynamic_forward_proxy/v3"
+	dfpcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/dynamic_forward_proxy/v3"
+	dfpfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/dynamic_forward_proxy/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	httpOptions "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// DynamicForwardProxyClusterName and DynamicForwardProxyTLSClusterName are the names
+	// of the clusters proxying the requests to the host they're rewritten to, resolved on
+	// demand, via plaintext and TLS.
+	DynamicForwardProxyClusterName    = "dynamic_forward_proxy"
+	DynamicForwardProxyTLSClusterName = "dynamic_forward_proxy_tls"
+
+	dynamicForwardProxyFilterName      = "envoy.filters.http.dynamic_forward_proxy"
+	dynamicForwardProxyClusterTypeName = "envoy.clusters.dynamic_forward_proxy"
+
+	// dynamicForwardProxyDNSCacheName is the name of the DNS cache shared by the filter
+	// and the clusters of the dynamic forward proxy, whose configs have to be the same.
+	dynamicForwardProxyDNSCacheName = "kourier_dynamic_forward_proxy"
+)
+
+// newDynamicForwardProxyDNSCacheConfig creates the config of the DNS cache of the
+// dynamic forward proxy.
+func newDynamicForwardProxyDNSCacheConfig() *dfpcommon.DnsCacheConfig {
+	return &dfpcommon.DnsCacheConfig{
+		Name:            dynamicForwardProxyDNSCacheName,
+		DnsLookupFamily: envoyclusterv3.Cluster_V4_ONLY,
+	}
+}
+
+// NewDynamicForwardProxyFilter creates the filter resolving the hosts of the requests
+// routed to the clusters of the dynamic forward proxy.
+func NewDynamicForwardProxyFilter() *hcm.HttpFilter {
+	filter, _ := anypb.New(&dfpfilter.FilterConfig{
+		DnsCacheConfig: newDynamicForwardProxyDNSCacheConfig(),
+	})
+	return &hcm.HttpFilter{
+		Name:       dynamicForwardProxyFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// NewDynamicForwardProxyCluster creates the cluster of the dynamic forward proxy, which
+// connects to the host of the requests via HTTP/1.1. Via TLS, the host is sent as SNI,
+// and the certificate of the backend has to be issued for it by one of the system's CAs.
+func NewDynamicForwardProxyCluster(tls bool, connectTimeout time.Duration) (*envoyclusterv3.Cluster, error) {
+	clusterConfig, err := anypb.New(&dfpcluster.ClusterConfig{
+		DnsCacheConfig: newDynamicForwardProxyDNSCacheConfig(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &envoyclusterv3.Cluster{
+		Name: DynamicForwardProxyClusterName,
+		ClusterDiscoveryType: &envoyclusterv3.Cluster_ClusterType{
+			ClusterType: &envoyclusterv3.Cluster_CustomClusterType{
+				Name:        dynamicForwardProxyClusterTypeName,
+				TypedConfig: clusterConfig,
+			},
+		},
+		ConnectTimeout: durationpb.New(connectTimeout),
+		LbPolicy:       envoyclusterv3.Cluster_CLUSTER_PROVIDED,
+	}
+	if !tls {
+		return cluster, nil
+	}
+
+	// The SNI and the subject alternative name to verify depend on the host of each
+	// request.
+	tlsContext := NewExternalUpstreamTLSContext("")
+	tlsContext.CommonTlsContext.GetValidationContext().MatchSubjectAltNames = nil
+	tlsAny, err := anypb.New(tlsContext)
+	if err != nil {
+		return nil, err
+	}
+	protocolOptions, err := anypb.New(&httpOptions.HttpProtocolOptions{
+		UpstreamHttpProtocolOptions: &envoycorev3.UpstreamHttpProtocolOptions{
+			AutoSni:           true,
+			AutoSanValidation: true,
+		},
+		UpstreamProtocolOptions: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &httpOptions.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{
+					HttpProtocolOptions: &envoycorev3.Http1ProtocolOptions{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cluster.Name = DynamicForwardProxyTLSClusterName
+	cluster.TransportSocket = &envoycorev3.TransportSocket{
+		Name:       wellknown.TransportSocketTls,
+		ConfigType: &envoycorev3.TransportSocket_TypedConfig{TypedConfig: tlsAny},
+	}
+	cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+		httpProtocolOptionsKey: protocolOptions,
+	}
+	return cluster, nil
+}
+
+// SetDynamicForwardProxyHost makes the dynamic forward proxy resolve and connect to the
+// given host, with its port unless the default one, for the requests of the route.
+func SetDynamicForwardProxyHost(r *route.Route, host string) {
+	filter, _ := anypb.New(&dfpfilter.PerRouteConfig{
+		HostRewriteSpecifier: &dfpfilter.PerRouteConfig_HostRewriteLiteral{HostRewriteLiteral: host},
+	})
+	if r.TypedPerFilterConfig == nil {
+		r.TypedPerFilterConfig = make(map[string]*anypb.Any, 1)
+	}
+	r.TypedPerFilterConfig[dynamicForwardProxyFilterName] = filter
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoyclusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	dfpfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/dynamic_forward_proxy/v3"
+	httpOptions "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewDynamicForwardProxyCluster(t *testing.T) {
+	plain, err := NewDynamicForwardProxyCluster(false, 5*time.Second)
+	assert.NilError(t, err)
+	assert.Equal(t, plain.Name, DynamicForwardProxyClusterName)
+	assert.Equal(t, plain.GetClusterType().Name, dynamicForwardProxyClusterTypeName)
+	assert.Equal(t, plain.LbPolicy, envoyclusterv3.Cluster_CLUSTER_PROVIDED)
+	assert.Assert(t, plain.TransportSocket == nil)
+
+	tls, err := NewDynamicForwardProxyCluster(true, 5*time.Second)
+	assert.NilError(t, err)
+	assert.Equal(t, tls.Name, DynamicForwardProxyTLSClusterName)
+	assert.Equal(t, tls.TransportSocket.Name, wellknown.TransportSocketTls)
+
+	// The SNI and the verified subject alternative name follow the host of the requests.
+	options := &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, tls.TypedExtensionProtocolOptions[httpProtocolOptionsKey].UnmarshalTo(options))
+	assert.Assert(t, options.UpstreamHttpProtocolOptions.AutoSni)
+	assert.Assert(t, options.UpstreamHttpProtocolOptions.AutoSanValidation)
+}
+
+func TestSetDynamicForwardProxyHost(t *testing.T) {
+	r := &route.Route{}
+	SetDynamicForwardProxyHost(r, "api.example.com:8443")
+
+	got := &dfpfilter.PerRouteConfig{}
+	assert.NilError(t, r.TypedPerFilterConfig[dynamicForwardProxyFilterName].UnmarshalTo(got))
+	assert.Equal(t, got.GetHostRewriteLiteral(), "api.example.com:8443")
+}
+
+func TestNewHTTPConnectionManagerWithDynamicForwardProxy(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{
+		DynamicForwardProxy:      true,
+		TransformationWasmModule: "/var/lib/kourier/transform.wasm",
+	})
+
+	// The hosts are resolved right before the requests are routed.
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[1].Name, wellknown.HTTPWasm)
+	assert.Equal(t, connManager.HttpFilters[2].Name, dynamicForwardProxyFilterName)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.Router)
+}
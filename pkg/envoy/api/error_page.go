@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// errorPageStatusRuntimeKey is the runtime key of the minimum status of the local replies
+// given the error page, 500 unless overridden.
+const errorPageStatusRuntimeKey = "kourier.error_page.min_status"
+
+// SetErrorPage replaces the body of the 5xx responses generated by the given manager,
+// like when no upstream is available or can be connected to, with the given format of
+// the given content type. The responses of the upstreams aren't changed. It is a no-op
+// if the format is empty.
+func SetErrorPage(mgr *hcm.HttpConnectionManager, format, contentType string) {
+	if format == "" {
+		return
+	}
+
+	bodyFormat := &core.SubstitutionFormatString{
+		Format: &core.SubstitutionFormatString_TextFormatSource{
+			TextFormatSource: &core.DataSource{
+				Specifier: &core.DataSource_InlineString{InlineString: format},
+			},
+		},
+		ContentType: contentType,
+	}
+	serverErrors := &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_StatusCodeFilter{
+			StatusCodeFilter: &accesslog.StatusCodeFilter{
+				Comparison: &accesslog.ComparisonFilter{
+					Op: accesslog.ComparisonFilter_GE,
+					Value: &core.RuntimeUInt32{
+						DefaultValue: 500,
+						RuntimeKey:   errorPageStatusRuntimeKey,
+					},
+				},
+			},
+		},
+	}
+
+	if mgr.LocalReplyConfig == nil {
+		mgr.LocalReplyConfig = &hcm.LocalReplyConfig{}
+	}
+
+	// Only the first matching mapper is applied, so each existing mapper is preceded by
+	// a copy of it which also replaces the body of the server errors.
+	mappers := make([]*hcm.ResponseMapper, 0, 2*len(mgr.LocalReplyConfig.Mappers)+1)
+	for _, mapper := range mgr.LocalReplyConfig.Mappers {
+		withBody := proto.Clone(mapper).(*hcm.ResponseMapper)
+		withBody.Filter = &accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_AndFilter{
+				AndFilter: &accesslog.AndFilter{Filters: []*accesslog.AccessLogFilter{mapper.Filter, serverErrors}},
+			},
+		}
+		withBody.BodyFormatOverride = bodyFormat
+		mappers = append(mappers, withBody, mapper)
+	}
+	mappers = append(mappers, &hcm.ResponseMapper{
+		Filter:             serverErrors,
+		BodyFormatOverride: bodyFormat,
+	})
+	mgr.LocalReplyConfig.Mappers = mappers
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetErrorPage(t *testing.T) {
+	mgr := &hcm.HttpConnectionManager{}
+	SetErrorPage(mgr, "", "")
+	assert.Assert(t, mgr.LocalReplyConfig == nil)
+
+	SetErrorPage(mgr, `{"code": %RESPONSE_CODE%}`, "application/json")
+	assert.NilError(t, mgr.LocalReplyConfig.Validate())
+	assert.Equal(t, len(mgr.LocalReplyConfig.Mappers), 1)
+
+	mapper := mgr.LocalReplyConfig.Mappers[0]
+	assert.Equal(t, mapper.Filter.GetStatusCodeFilter().Comparison.Value.DefaultValue, uint32(500))
+	assert.Equal(t, mapper.BodyFormatOverride.GetTextFormatSource().GetInlineString(), `{"code": %RESPONSE_CODE%}`)
+	assert.Equal(t, mapper.BodyFormatOverride.ContentType, "application/json")
+}
+
+func TestSetErrorPageWithGatewayErrorHeader(t *testing.T) {
+	mgr := &hcm.HttpConnectionManager{LocalReplyConfig: NewGatewayErrorLocalReplyConfig()}
+	SetErrorPage(mgr, "<h1>Oops</h1>", "text/html")
+	assert.NilError(t, mgr.LocalReplyConfig.Validate())
+
+	mappers := mgr.LocalReplyConfig.Mappers
+	assert.Equal(t, len(mappers), 2*(len(gatewayErrors)+1)+1)
+
+	// The server errors get both the header and the error page.
+	filters := mappers[0].Filter.GetAndFilter().Filters
+	assert.DeepEqual(t, filters[0].GetResponseFlagFilter().Flags, []string{"NR"})
+	assert.Assert(t, filters[1].GetStatusCodeFilter() != nil)
+	assert.Equal(t, mappers[0].HeadersToAdd[0].Header.Value, "no-route")
+	assert.Equal(t, mappers[0].BodyFormatOverride.GetTextFormatSource().GetInlineString(), "<h1>Oops</h1>")
+
+	// The other errors get the header alone.
+	assert.DeepEqual(t, mappers[1].Filter.GetResponseFlagFilter().Flags, []string{"NR"})
+	assert.Equal(t, mappers[1].HeadersToAdd[0].Header.Value, "no-route")
+	assert.Assert(t, mappers[1].BodyFormatOverride == nil)
+
+	// The server errors without response flags get the error page alone.
+	last := mappers[len(mappers)-1]
+	assert.Assert(t, last.Filter.GetStatusCodeFilter() != nil)
+	assert.Equal(t, len(last.HeadersToAdd), 0)
+}
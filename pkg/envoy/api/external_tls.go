@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+)
+
+// SystemCABundlePath is the path of the bundle of the system's CA certificates in the
+// gateway image.
+const SystemCABundlePath = "/etc/ssl/certs/ca-certificates.crt"
+
+// NewExternalUpstreamTLSContext creates an upstream TLS context for a backend outside of
+// the cluster, reached at the given host. The host is sent as SNI, and the backend's
+// certificate has to be issued for it by one of the system's CAs.
+func NewExternalUpstreamTLSContext(host string, alpnProtocols ...string) *auth.UpstreamTlsContext {
+	return &auth.UpstreamTlsContext{
+		Sni: host,
+		CommonTlsContext: &auth.CommonTlsContext{
+			AlpnProtocols: alpnProtocols,
+			TlsParams: &auth.TlsParameters{
+				TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+			},
+			ValidationContextType: &auth.CommonTlsContext_ValidationContext{
+				ValidationContext: &auth.CertificateValidationContext{
+					TrustedCa: &core.DataSource{
+						Specifier: &core.DataSource_Filename{Filename: SystemCABundlePath},
+					},
+					MatchSubjectAltNames: []*envoymatcherv3.StringMatcher{{
+						MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: host},
+					}},
+				},
+			},
+		},
+	}
+}
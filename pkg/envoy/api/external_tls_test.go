@@ -0,0 +1,38 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewExternalUpstreamTLSContext(t *testing.T) {
+	tlsContext := NewExternalUpstreamTLSContext("api.example.com", "h2")
+
+	assert.Equal(t, tlsContext.Sni, "api.example.com")
+	assert.DeepEqual(t, tlsContext.CommonTlsContext.AlpnProtocols, []string{"h2"})
+
+	validationContext := tlsContext.CommonTlsContext.GetValidationContext()
+	assert.Equal(t, validationContext.TrustedCa.GetFilename(), SystemCABundlePath)
+	assert.DeepEqual(t, validationContext.MatchSubjectAltNames, []*envoymatcherv3.StringMatcher{{
+		MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: "api.example.com"},
+	}}, protocmp.Transform())
+}
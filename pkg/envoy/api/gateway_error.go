@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+)
+
+// GatewayErrorHeader is the header added to the errors generated by the gateway, telling
+// what went wrong, so they can be told apart from the errors of the applications.
+const GatewayErrorHeader = "x-kourier-error"
+
+// gatewayErrors are the values of GatewayErrorHeader for the Envoy response flags of the
+// errors, in the order they are matched. The response flags of other errors are passed on
+// as they are.
+var gatewayErrors = []struct {
+	flag  string
+	value string
+}{
+	{"NR", "no-route"},
+	{"UAEX", "unauthorized"},
+	{"RL", "rate-limited"},
+	{"UH", "no-healthy-upstream"},
+	{"UO", "upstream-overflow"},
+	{"UF", "upstream-connection-failure"},
+	{"UT", "upstream-timeout"},
+	{"UMSDR", "upstream-max-duration-exceeded"},
+	{"URX", "upstream-retry-limit-exceeded"},
+	{"UC", "upstream-connection-termination"},
+	{"UR", "upstream-reset"},
+	{"SI", "stream-idle-timeout"},
+	{"DT", "max-duration-exceeded"},
+	{"DC", "downstream-connection-termination"},
+	{"LR", "local-reset"},
+}
+
+// NewGatewayErrorLocalReplyConfig creates the local reply config adding the
+// GatewayErrorHeader to the errors generated by the gateway, derived from their Envoy
+// response flags.
+func NewGatewayErrorLocalReplyConfig() *hcm.LocalReplyConfig {
+	mappers := make([]*hcm.ResponseMapper, 0, len(gatewayErrors)+1)
+	for _, gatewayError := range gatewayErrors {
+		mappers = append(mappers, newGatewayErrorMapper([]string{gatewayError.flag}, gatewayError.value))
+	}
+	// Any other error with response flags.
+	mappers = append(mappers, newGatewayErrorMapper(nil, "%RESPONSE_FLAGS%"))
+
+	return &hcm.LocalReplyConfig{Mappers: mappers}
+}
+
+// newGatewayErrorMapper creates a mapper setting GatewayErrorHeader to the given value
+// on the local replies with any of the given response flags, or with any response flags
+// at all if none are given.
+func newGatewayErrorMapper(flags []string, value string) *hcm.ResponseMapper {
+	return &hcm.ResponseMapper{
+		Filter: &accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_ResponseFlagFilter{
+				ResponseFlagFilter: &accesslog.ResponseFlagFilter{Flags: flags},
+			},
+		},
+		HeadersToAdd: headersToAdd(map[string]string{GatewayErrorHeader: value}),
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewGatewayErrorLocalReplyConfig(t *testing.T) {
+	config := NewGatewayErrorLocalReplyConfig()
+	assert.NilError(t, config.Validate())
+
+	mappers := config.Mappers
+	assert.Equal(t, len(mappers), len(gatewayErrors)+1)
+
+	// The mappers match single response flags, with readable values.
+	assert.DeepEqual(t, mappers[0].Filter.GetResponseFlagFilter().Flags, []string{"NR"})
+	assert.Equal(t, mappers[0].HeadersToAdd[0].Header.Key, GatewayErrorHeader)
+	assert.Equal(t, mappers[0].HeadersToAdd[0].Header.Value, "no-route")
+
+	// Any other response flags are passed on as they are.
+	last := mappers[len(mappers)-1]
+	assert.Equal(t, len(last.Filter.GetResponseFlagFilter().Flags), 0)
+	assert.Equal(t, last.HeadersToAdd[0].Header.Value, "%RESPONSE_FLAGS%")
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// NewGRPCJSONTranscoderFilter creates the filter transcoding JSON requests into gRPC
+// requests, and their responses back. It doesn't transcode any requests on its own, but
+// only those of the virtual hosts configured with SetGRPCJSONTranscoder.
+func NewGRPCJSONTranscoderFilter() *hcm.HttpFilter {
+	filter, _ := anypb.New(&transcoder.GrpcJsonTranscoder{
+		// An empty descriptor set without any services.
+		DescriptorSet: &transcoder.GrpcJsonTranscoder_ProtoDescriptorBin{ProtoDescriptorBin: []byte{}},
+	})
+
+	return &hcm.HttpFilter{
+		Name:       wellknown.GRPCJSONTranscoder,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// SetGRPCJSONTranscoder makes the VirtualHost transcode the JSON requests into gRPC
+// requests of the given services, described by the given serialized protobuf descriptor
+// set. The transcoded requests are sent along the routes the JSON requests matched.
+func SetGRPCJSONTranscoder(vh *route.VirtualHost, descriptorSet []byte, services []string) {
+	filter, _ := anypb.New(&transcoder.GrpcJsonTranscoder{
+		DescriptorSet:             &transcoder.GrpcJsonTranscoder_ProtoDescriptorBin{ProtoDescriptorBin: descriptorSet},
+		Services:                  services,
+		MatchIncomingRequestRoute: true,
+		ConvertGrpcStatus:         true,
+	})
+
+	if vh.TypedPerFilterConfig == nil {
+		vh.TypedPerFilterConfig = make(map[string]*anypb.Any, 1)
+	}
+	vh.TypedPerFilterConfig[wellknown.GRPCJSONTranscoder] = filter
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewGRPCJSONTranscoderFilter(t *testing.T) {
+	filter := NewGRPCJSONTranscoderFilter()
+
+	assert.Equal(t, filter.Name, wellknown.GRPCJSONTranscoder)
+	config := &transcoder.GrpcJsonTranscoder{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), config, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, config.Validate())
+	assert.Equal(t, len(config.Services), 0)
+}
+
+func TestSetGRPCJSONTranscoder(t *testing.T) {
+	vh := NewVirtualHostWithExtAuthz("test", nil, []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+	})
+
+	SetGRPCJSONTranscoder(vh, []byte("descriptor"), []string{"helloworld.Greeter"})
+
+	// The other filters' configs are kept.
+	assert.Assert(t, vh.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+
+	config := &transcoder.GrpcJsonTranscoder{}
+	err := anypb.UnmarshalTo(vh.TypedPerFilterConfig[wellknown.GRPCJSONTranscoder], config, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, config.GetProtoDescriptorBin(), []byte("descriptor"))
+	assert.DeepEqual(t, config.Services, []string{"helloworld.Greeter"})
+	assert.Assert(t, config.MatchIncomingRequestRoute)
+	assert.Assert(t, config.ConvertGrpcStatus)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	xdscore "github.com/cncf/xds/go/xds/core/v3"
+	xdsmatcher "github.com/cncf/xds/go/xds/type/matcher/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// WithHeaderMatcherTree returns a copy of the virtual host looking its routes up by the
+// value of a header in a matcher tree, rather than matching them one by one, if it has
+// at least minRoutes routes matching distinct values of the header. This is the case of
+// the hosts of Knative services with many tags, whose requests are routed by their
+// Knative-Serving-Tag header.
+//
+// The routes can only be looked up this way if all of them match all paths, and all but
+// the last one match a value of the same header, exactly. The last one serves the
+// requests without a matching header. The virtual host is returned as it is otherwise.
+func WithHeaderMatcherTree(vh *route.VirtualHost, minRoutes int) *route.VirtualHost {
+	if minRoutes <= 0 || len(vh.Routes) <= minRoutes {
+		return vh
+	}
+
+	fallback := vh.Routes[len(vh.Routes)-1]
+	if !proto.Equal(fallback.Match, matchAllPaths()) {
+		return vh
+	}
+
+	var name string
+	onMatch := make(map[string]*xdsmatcher.Matcher_OnMatch, len(vh.Routes)-1)
+	for _, r := range vh.Routes[:len(vh.Routes)-1] {
+		header, value, ok := exactHeaderMatch(r.Match)
+		if !ok || (name != "" && header != name) {
+			return vh
+		}
+		name = header
+		// The first route matching a value takes precedence, as in the list.
+		if _, ok := onMatch[value]; !ok {
+			onMatch[value] = routeOnMatch(r)
+		}
+	}
+	if len(onMatch) < minRoutes {
+		return vh
+	}
+
+	input, _ := anypb.New(&envoymatcherv3.HttpRequestHeaderMatchInput{HeaderName: name})
+	tree := proto.Clone(vh).(*route.VirtualHost)
+	tree.Routes = nil
+	tree.Matcher = &xdsmatcher.Matcher{
+		MatcherType: &xdsmatcher.Matcher_MatcherTree_{
+			MatcherTree: &xdsmatcher.Matcher_MatcherTree{
+				Input: &xdscore.TypedExtensionConfig{Name: name, TypedConfig: input},
+				TreeType: &xdsmatcher.Matcher_MatcherTree_ExactMatchMap{
+					ExactMatchMap: &xdsmatcher.Matcher_MatcherTree_MatchMap{Map: onMatch},
+				},
+			},
+		},
+		OnNoMatch: routeOnMatch(fallback),
+	}
+	return tree
+}
+
+// matchAllPaths returns the match of the routes matching all paths.
+func matchAllPaths() *route.RouteMatch {
+	return &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}
+}
+
+// exactHeaderMatch returns the header and the value the given match matches exactly,
+// if it matches all paths and nothing but the value of that single header.
+func exactHeaderMatch(match *route.RouteMatch) (string, string, bool) {
+	if len(match.Headers) != 1 {
+		return "", "", false
+	}
+	header := match.Headers[0]
+	value := header.GetExactMatch()
+	if value == "" {
+		value = header.GetStringMatch().GetExact()
+	}
+	exact := &route.HeaderMatcher{
+		Name:                 header.Name,
+		HeaderMatchSpecifier: header.HeaderMatchSpecifier,
+	}
+	if value == "" || !proto.Equal(header, exact) || header.GetStringMatch().GetIgnoreCase() {
+		return "", "", false
+	}
+
+	rest := proto.Clone(match).(*route.RouteMatch)
+	rest.Headers = nil
+	if !proto.Equal(rest, matchAllPaths()) {
+		return "", "", false
+	}
+	return header.Name, value, true
+}
+
+// routeOnMatch returns the action of the matcher tree serving the given route.
+func routeOnMatch(r *route.Route) *xdsmatcher.Matcher_OnMatch {
+	action, _ := anypb.New(r)
+	return &xdsmatcher.Matcher_OnMatch{
+		OnMatch: &xdsmatcher.Matcher_OnMatch_Action{
+			Action: &xdscore.TypedExtensionConfig{Name: r.Name, TypedConfig: action},
+		},
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+	"gotest.tools/v3/assert"
+)
+
+func TestWithHeaderMatcherTree(t *testing.T) {
+	tagRoute := func(header, tag string) *route.Route {
+		return NewRoute("tag-"+tag, []*route.HeaderMatcher{{
+			Name:                 header,
+			HeaderMatchSpecifier: &route.HeaderMatcher_ExactMatch{ExactMatch: tag},
+		}}, "/", nil, 0, nil, "")
+	}
+	vhost := func(routes ...*route.Route) *route.VirtualHost {
+		return NewVirtualHost("test", []string{"hello.example.com"}, routes)
+	}
+	fallback := NewRoute("default", nil, "/", nil, 0, nil, "")
+
+	var routes []*route.Route
+	for i := 0; i < 3; i++ {
+		routes = append(routes, tagRoute("Knative-Serving-Tag", fmt.Sprintf("v%d", i)))
+	}
+	// The first route matching a value takes precedence.
+	routes = append(routes, tagRoute("Knative-Serving-Tag", "v0"), fallback)
+	in := vhost(routes...)
+	original := proto.Clone(in)
+
+	got := WithHeaderMatcherTree(in, 3)
+	assert.Assert(t, proto.Equal(in, original), "the virtual host was modified")
+	assert.Equal(t, len(got.Routes), 0)
+	assert.DeepEqual(t, got.Domains, in.Domains)
+
+	tree := got.Matcher.GetMatcherTree()
+	assert.Equal(t, tree.Input.Name, "Knative-Serving-Tag")
+	actions := tree.GetExactMatchMap().Map
+	assert.Equal(t, len(actions), 3)
+	for i, tag := range []string{"v0", "v1", "v2"} {
+		r := &route.Route{}
+		assert.NilError(t, actions[tag].GetAction().TypedConfig.UnmarshalTo(r))
+		assert.Assert(t, proto.Equal(r, routes[i]))
+	}
+	r := &route.Route{}
+	assert.NilError(t, got.Matcher.OnNoMatch.GetAction().TypedConfig.UnmarshalTo(r))
+	assert.Assert(t, proto.Equal(r, fallback))
+
+	// The routes which can't be looked up by a header are kept as they are.
+	for name, in := range map[string]*route.VirtualHost{
+		"too few routes":  vhost(routes...),
+		"no fallback":     vhost(routes[:3]...),
+		"other header":    vhost(routes[0], routes[1], tagRoute("Other", "v2"), fallback),
+		"other path":      vhost(routes[0], routes[1], routes[2], NewRoute("default", nil, "/api", nil, 0, nil, "")),
+		"presence match":  vhost(routes[0], routes[1], NewRoute("any", []*route.HeaderMatcher{{Name: "Knative-Serving-Tag"}}, "/", nil, 0, nil, ""), fallback),
+		"path and header": vhost(routes[0], routes[1], NewRoute("v2", routes[2].Match.Headers, "/api", nil, 0, nil, ""), fallback),
+	} {
+		minRoutes := 3
+		if name == "too few routes" {
+			minRoutes = 4
+		}
+		assert.Equal(t, WithHeaderMatcherTree(in, minRoutes), in, name)
+	}
+	assert.Equal(t, WithHeaderMatcherTree(in, 0), in)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+	"strings"
+
+	accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslog_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	headermetadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+const headerToMetadataFilterName = "envoy.filters.http.header_to_metadata"
+
+// defaultAccessLogFormat is Envoy's default format of the access logs, without the
+// trailing newline.
+const defaultAccessLogFormat = `[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" ` +
+	`%RESPONSE_CODE% %RESPONSE_FLAGS% %BYTES_RECEIVED% %BYTES_SENT% %DURATION% ` +
+	`%RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% "%REQ(X-FORWARDED-FOR)%" "%REQ(USER-AGENT)%" ` +
+	`"%REQ(X-REQUEST-ID)%" "%REQ(:AUTHORITY)%" "%UPSTREAM_HOST%"`
+
+// NewHeaderToMetadataFilter creates the filter extracting the headers of the given
+// "<header>=<key>" mappings into the dynamic metadata of config.HeaderMetadataNamespace.
+func NewHeaderToMetadataFilter(mappings []string) *hcm.HttpFilter {
+	rules := make([]*headermetadata.Config_Rule, 0, len(mappings))
+	for _, mapping := range mappings {
+		// The mappings are validated along with the config.
+		header, key, _ := strings.Cut(mapping, "=")
+		rules = append(rules, &headermetadata.Config_Rule{
+			Header: header,
+			OnHeaderPresent: &headermetadata.Config_KeyValuePair{
+				MetadataNamespace: config.HeaderMetadataNamespace,
+				Key:               key,
+				Type:              headermetadata.Config_STRING,
+			},
+		})
+	}
+	filter, _ := anypb.New(&headermetadata.Config{RequestRules: rules})
+
+	return &hcm.HttpFilter{
+		Name:       headerToMetadataFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// SetHeaderMetadata makes the connection manager extract the headers of the given
+// "<header>=<key>" mappings into dynamic metadata before any other filter sees the
+// requests, and write the metadata to its access logs. It's a no-op without mappings.
+func SetHeaderMetadata(mgr *hcm.HttpConnectionManager, mappings []string) {
+	if len(mappings) == 0 {
+		return
+	}
+
+	mgr.HttpFilters = append([]*hcm.HttpFilter{NewHeaderToMetadataFilter(mappings)}, mgr.HttpFilters...)
+
+	format := defaultAccessLogFormat
+	for _, mapping := range mappings {
+		_, key, _ := strings.Cut(mapping, "=")
+		format += fmt.Sprintf(` "%%DYN_META(%s:%s)%%"`, config.HeaderMetadataNamespace, key)
+	}
+	for _, accessLog := range mgr.AccessLog {
+		fileAccessLog := &accesslog_file_v3.FileAccessLog{}
+		if err := accessLog.GetTypedConfig().UnmarshalTo(fileAccessLog); err != nil {
+			continue
+		}
+		fileAccessLog.AccessLogFormat = &accesslog_file_v3.FileAccessLog_LogFormat{
+			LogFormat: &core.SubstitutionFormatString{
+				Format: &core.SubstitutionFormatString_TextFormatSource{
+					TextFormatSource: &core.DataSource{
+						Specifier: &core.DataSource_InlineString{InlineString: format + "\n"},
+					},
+				},
+			},
+		}
+		typedConfig, _ := anypb.New(fileAccessLog)
+		accessLog.ConfigType = &accesslog_v3.AccessLog_TypedConfig{TypedConfig: typedConfig}
+	}
+}
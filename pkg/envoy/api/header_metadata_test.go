@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	accesslog_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	headermetadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	"gotest.tools/v3/assert"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewHeaderToMetadataFilter(t *testing.T) {
+	filter := NewHeaderToMetadataFilter([]string{"x-tenant-id=tenant", "x-plan=plan"})
+	assert.Equal(t, filter.Name, "envoy.filters.http.header_to_metadata")
+
+	got := &headermetadata.Config{}
+	assert.NilError(t, filter.GetTypedConfig().UnmarshalTo(got))
+	assert.Equal(t, len(got.RequestRules), 2)
+	assert.Equal(t, got.RequestRules[0].Header, "x-tenant-id")
+	assert.Equal(t, got.RequestRules[0].OnHeaderPresent.MetadataNamespace, config.HeaderMetadataNamespace)
+	assert.Equal(t, got.RequestRules[0].OnHeaderPresent.Key, "tenant")
+	assert.Equal(t, got.RequestRules[1].Header, "x-plan")
+	assert.Equal(t, got.RequestRules[1].OnHeaderPresent.Key, "plan")
+}
+
+func TestSetHeaderMetadata(t *testing.T) {
+	mgr := NewHTTPConnectionManager("test", &config.Kourier{EnableServiceAccessLogging: true})
+	filters := len(mgr.HttpFilters)
+	SetHeaderMetadata(mgr, nil)
+	assert.Equal(t, len(mgr.HttpFilters), filters)
+
+	SetHeaderMetadata(mgr, []string{"x-tenant-id=tenant"})
+
+	// The metadata is extracted before any other filter sees the requests.
+	assert.Equal(t, len(mgr.HttpFilters), filters+1)
+	assert.Equal(t, mgr.HttpFilters[0].Name, "envoy.filters.http.header_to_metadata")
+
+	// The metadata is written to the access logs.
+	accessLog := &accesslog_file_v3.FileAccessLog{}
+	assert.NilError(t, mgr.AccessLog[0].GetTypedConfig().UnmarshalTo(accessLog))
+	assert.Equal(t, accessLog.Path, "/dev/stdout")
+	assert.Equal(t, accessLog.GetLogFormat().GetTextFormatSource().GetInlineString(),
+		defaultAccessLogFormat+` "%DYN_META(kourier:tenant)%"`+"\n")
+}
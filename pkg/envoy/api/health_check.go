@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"time"
+
+	envoyclusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoycorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoytypev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// maxHealthCheckTimeout is the maximum time to wait for the response to a health check.
+	maxHealthCheckTimeout = 5 * time.Second
+
+	// healthCheckUnhealthyThreshold is the number of failed health checks after which an
+	// endpoint is unhealthy.
+	healthCheckUnhealthyThreshold = 2
+
+	// healthCheckHealthyThreshold is the number of successful health checks after which
+	// an unhealthy endpoint is healthy again.
+	healthCheckHealthyThreshold = 1
+)
+
+// SetActiveHealthCheck makes the cluster health check its endpoints at the given interval,
+// either over HTTP by requesting the given path of the given host, or by connecting to
+// them if the path is empty. The unhealthy endpoints don't get any requests, even if none
+// is healthy.
+func SetActiveHealthCheck(cluster *envoyclusterv3.Cluster, path, host string, http2 bool, interval time.Duration) {
+	timeout := interval
+	if timeout > maxHealthCheckTimeout {
+		timeout = maxHealthCheckTimeout
+	}
+
+	healthCheck := &envoycorev3.HealthCheck{
+		Timeout:            durationpb.New(timeout),
+		Interval:           durationpb.New(interval),
+		UnhealthyThreshold: wrapperspb.UInt32(healthCheckUnhealthyThreshold),
+		HealthyThreshold:   wrapperspb.UInt32(healthCheckHealthyThreshold),
+	}
+	if path != "" {
+		httpHealthCheck := &envoycorev3.HealthCheck_HttpHealthCheck{
+			Host: host,
+			Path: path,
+		}
+		if http2 {
+			httpHealthCheck.CodecClientType = envoytypev3.CodecClientType_HTTP2
+		}
+		healthCheck.HealthChecker = &envoycorev3.HealthCheck_HttpHealthCheck_{HttpHealthCheck: httpHealthCheck}
+	} else {
+		healthCheck.HealthChecker = &envoycorev3.HealthCheck_TcpHealthCheck_{TcpHealthCheck: &envoycorev3.HealthCheck_TcpHealthCheck{}}
+	}
+
+	cluster.HealthChecks = []*envoycorev3.HealthCheck{healthCheck}
+	// A single unhealthy endpoint would put the cluster in panic mode otherwise.
+	DisablePanicMode(cluster)
+}
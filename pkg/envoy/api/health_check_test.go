@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	v3Cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoytypev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetActiveHealthCheck(t *testing.T) {
+	t.Run("HTTP", func(t *testing.T) {
+		cluster := NewCluster("test", 5*time.Second, nil, true, nil, v3Cluster.Cluster_LOGICAL_DNS)
+		SetActiveHealthCheck(cluster, "/healthz", "example.com", true, 10*time.Second)
+		assert.NilError(t, cluster.Validate())
+
+		assert.Equal(t, len(cluster.HealthChecks), 1)
+		healthCheck := cluster.HealthChecks[0]
+		assert.Equal(t, healthCheck.Interval.AsDuration(), 10*time.Second)
+		assert.Equal(t, healthCheck.Timeout.AsDuration(), maxHealthCheckTimeout)
+		assert.Equal(t, healthCheck.GetHttpHealthCheck().Path, "/healthz")
+		assert.Equal(t, healthCheck.GetHttpHealthCheck().Host, "example.com")
+		assert.Equal(t, healthCheck.GetHttpHealthCheck().CodecClientType, envoytypev3.CodecClientType_HTTP2)
+
+		// The single endpoint doesn't get requests while unhealthy.
+		assert.Equal(t, cluster.CommonLbConfig.HealthyPanicThreshold.Value, float64(0))
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		cluster := NewCluster("test", 5*time.Second, nil, false, nil, v3Cluster.Cluster_LOGICAL_DNS)
+		SetActiveHealthCheck(cluster, "", "example.com", false, time.Second)
+		assert.NilError(t, cluster.Validate())
+
+		healthCheck := cluster.HealthChecks[0]
+		assert.Assert(t, healthCheck.GetTcpHealthCheck() != nil)
+		// The checks time out before the next one.
+		assert.Equal(t, healthCheck.Timeout.AsDuration(), time.Second)
+	})
+}
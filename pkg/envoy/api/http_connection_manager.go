@@ -33,15 +33,84 @@ import (
 	"knative.dev/net-kourier/pkg/config"
 )
 
+// UpgradeH2C is the upgrade type switching an HTTP/1.1 connection to cleartext HTTP/2.
+const UpgradeH2C = "h2c"
+
 // NewHTTPConnectionManager creates a new HttpConnectionManager that points to the given
 // RouteConfig for further configuration.
 func NewHTTPConnectionManager(routeConfigName string, kourierConfig *config.Kourier) *hcm.HttpConnectionManager {
-	filters := make([]*hcm.HttpFilter, 0, 1)
+	filters := make([]*hcm.HttpFilter, 0, 2)
+
+	if kourierConfig.ScannerMitigation {
+		// Reject the requests of scanners before they cost anything.
+		filters = append(filters, NewDenyScannersFilter(kourierConfig.ScannerDenyRules()))
+		if kourierConfig.ScannerRateLimit != 0 {
+			filters = append(filters, NewConnectionRateLimitFilter(kourierConfig.ScannerRateLimit))
+		}
+	}
+
+	// Pass the cookie of the sticky canary routes on before any filter selects the route.
+	filters = append(filters, NewStickyCanaryFilter())
+
+	if kourierConfig.VirtualHostDiscovery {
+		// Request the virtual hosts unknown to the gateway before selecting the route.
+		filters = append(filters, NewOnDemandFilter())
+	}
+
+	if kourierConfig.GRPCWeb {
+		// Translate gRPC-Web before any filter inspects the requests as gRPC.
+		filters = append(filters, NewGRPCWebFilter())
+	}
+
+	if kourierConfig.SourceIPFilters {
+		// Deny the requests from the sources the ingresses don't allow before authorizing
+		// them, once their virtual host is known.
+		filters = append(filters, NewSourceIPFilter())
+	}
+
+	// Limit the query parameters seen by the routes and the external authorization,
+	// while still sending the original path upstream.
+	var restorePathFilter *hcm.HttpFilter
+	if limit, ok := kourierConfig.QueryParametersLimit(); ok {
+		var limitFilter *hcm.HttpFilter
+		limitFilter, restorePathFilter = NewQueryParametersFilters(limit)
+		filters = append(filters, limitFilter)
+	}
 
 	if config.ExternalAuthz.Enabled {
 		filters = append(filters, config.ExternalAuthz.HTTPFilter)
 	}
 
+	if restorePathFilter != nil {
+		filters = append(filters, restorePathFilter)
+	}
+
+	if kourierConfig.MaxRequestBodyBytes != 0 {
+		// Buffer the bodies of the authorized requests only.
+		filters = append(filters, NewBufferFilter(kourierConfig.MaxRequestBodyBytes))
+	}
+
+	if kourierConfig.LuaFilters {
+		// Run the snippets of the ingresses on the authorized requests.
+		filters = append(filters, NewLuaSnippetFilter())
+	}
+
+	if kourierConfig.TransformationWasmModule != "" {
+		filters = append(filters, NewTransformFilter(kourierConfig.TransformationWasmModule))
+	}
+
+	if kourierConfig.GRPCJSONTranscoding {
+		// Transcode the requests as they are sent upstream, after the original path is
+		// restored.
+		filters = append(filters, NewGRPCJSONTranscoderFilter())
+	}
+
+	if kourierConfig.DynamicForwardProxy {
+		// Resolve the hosts of the requests routed to the dynamic forward proxy right
+		// before routing them.
+		filters = append(filters, NewDynamicForwardProxyFilter())
+	}
+
 	// Append the Router filter at the end.
 	filters = append(filters, &hcm.HttpFilter{
 		Name: wellknown.Router,
@@ -72,28 +141,90 @@ func NewHTTPConnectionManager(routeConfigName string, kourierConfig *config.Kour
 		StreamIdleTimeout: durationpb.New(idleTimeout),
 	}
 
+	// Limit the idle time of the connections and the duration of the streams.
+	mgr.CommonHttpProtocolOptions = newCommonHTTPProtocolOptions(kourierConfig.ConnectionIdleTimeout, kourierConfig.MaxStreamDuration)
+
+	// Don't let slow clients hold on to the connections by trickling their requests.
+	if kourierConfig.RequestTimeout != 0 {
+		mgr.RequestTimeout = durationpb.New(kourierConfig.RequestTimeout)
+	}
+	if kourierConfig.RequestHeadersTimeout != 0 {
+		mgr.RequestHeadersTimeout = durationpb.New(kourierConfig.RequestHeadersTimeout)
+	}
+
+	if kourierConfig.MaxRequestHeadersKB != 0 {
+		mgr.MaxRequestHeadersKb = wrapperspb.UInt32(kourierConfig.MaxRequestHeadersKB)
+	}
+
+	if kourierConfig.StripHostPort {
+		// Match the routes regardless of the port of the host.
+		mgr.StripPortMode = &hcm.HttpConnectionManager_StripAnyHostPort{StripAnyHostPort: true}
+	}
+
+	if kourierConfig.GatewayErrorHeader {
+		// Tell the errors generated by the gateway apart from the applications' ones.
+		mgr.LocalReplyConfig = NewGatewayErrorLocalReplyConfig()
+	}
+
 	if enableProxyProtocol {
 		//Force the connection manager to use the real remote address of the client connection.
 		mgr.UseRemoteAddress = &wrapperspb.BoolValue{Value: true}
 	}
 
+	if kourierConfig.XffNumTrustedHops != 0 {
+		// Behind other proxies, the client address is the one they appended to the
+		// X-Forwarded-For header, and the headers they set are trusted.
+		mgr.UseRemoteAddress = &wrapperspb.BoolValue{Value: true}
+		mgr.XffNumTrustedHops = kourierConfig.XffNumTrustedHops
+	}
+
+	if kourierConfig.OriginalIPHeader != "" {
+		// Behind proxies telling the client address in a header of their own, that
+		// header is the client address instead.
+		mgr.OriginalIpDetectionExtensions = []*envoy_api_v3_core.TypedExtensionConfig{
+			NewCustomHeaderOriginalIPDetection(kourierConfig.OriginalIPHeader),
+		}
+	}
+
+	if kourierConfig.SkipXffAppend {
+		mgr.SkipXffAppend = true
+	}
+
 	if enableAccessLog {
 		// Write access logs to stdout by default.
-		accessLog, _ := anypb.New(&accesslog_file_v3.FileAccessLog{
-			Path: "/dev/stdout",
-		})
-
-		mgr.AccessLog = []*accesslog_v3.AccessLog{{
-			Name: "envoy.file_access_log",
-			ConfigType: &accesslog_v3.AccessLog_TypedConfig{
-				TypedConfig: accessLog,
-			},
-		}}
+		SetAccessLogPath(mgr, "/dev/stdout")
 	}
 
 	return mgr
 }
 
+// SetAccessLogPath makes the connection manager write its access logs to the file at
+// the given path, replacing any other access logs.
+func SetAccessLogPath(mgr *hcm.HttpConnectionManager, path string) {
+	accessLog, _ := anypb.New(&accesslog_file_v3.FileAccessLog{
+		Path: path,
+	})
+
+	mgr.AccessLog = []*accesslog_v3.AccessLog{{
+		Name: "envoy.file_access_log",
+		ConfigType: &accesslog_v3.AccessLog_TypedConfig{
+			TypedConfig: accessLog,
+		},
+	}}
+}
+
+// RemoveHTTPFilter removes the HTTP filters with the given name from the connection
+// manager.
+func RemoveHTTPFilter(mgr *hcm.HttpConnectionManager, name string) {
+	filters := make([]*hcm.HttpFilter, 0, len(mgr.HttpFilters))
+	for _, filter := range mgr.HttpFilters {
+		if filter.Name != name {
+			filters = append(filters, filter)
+		}
+	}
+	mgr.HttpFilters = filters
+}
+
 // NewRouteConfig create a new RouteConfiguration with the given name and hosts.
 func NewRouteConfig(name string, virtualHosts []*route.VirtualHost) *route.RouteConfiguration {
 	return &route.RouteConfiguration{
@@ -106,3 +237,20 @@ func NewRouteConfig(name string, virtualHosts []*route.VirtualHost) *route.Route
 		ValidateClusters: wrapperspb.Bool(true),
 	}
 }
+
+// SetAllowedUpgrades makes the connection manager accept the given upgrades and reject
+// h2c upgrades explicitly. The routes may still allow other upgrades, unless restricted
+// with RestrictUpgrades.
+func SetAllowedUpgrades(mgr *hcm.HttpConnectionManager, upgradeTypes []string) {
+	mgr.UpgradeConfigs = make([]*hcm.HttpConnectionManager_UpgradeConfig, 0, len(upgradeTypes)+1)
+	for _, upgradeType := range upgradeTypes {
+		mgr.UpgradeConfigs = append(mgr.UpgradeConfigs, &hcm.HttpConnectionManager_UpgradeConfig{
+			UpgradeType: upgradeType,
+			Enabled:     wrapperspb.Bool(true),
+		})
+	}
+	mgr.UpgradeConfigs = append(mgr.UpgradeConfigs, &hcm.HttpConnectionManager_UpgradeConfig{
+		UpgradeType: UpgradeH2C,
+		Enabled:     wrapperspb.Bool(false),
+	})
+}
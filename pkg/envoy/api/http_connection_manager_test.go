@@ -23,6 +23,10 @@ import (
 	envoy_config_filter_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	wasmfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	customheader "github.com/envoyproxy/go-control-plane/envoy/extensions/http/original_ip_detection/custom_header/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/anypb"
@@ -95,6 +99,212 @@ func TestNewHTTPConnectionManagerWithAccessLogWithProxyProtocol(t *testing.T) {
 	assert.Equal(t, "/dev/stdout", fileAccesLog.Path)
 }
 
+func TestNewHTTPConnectionManagerWithTransformation(t *testing.T) {
+	kourierConfig := config.Kourier{
+		TransformationWasmModule: "/var/lib/kourier/transform.wasm",
+	}
+	connManager := NewHTTPConnectionManager("test", &kourierConfig)
+
+	// The transformation filter has to run before the router.
+	assert.Equal(t, len(connManager.HttpFilters), 3)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, wellknown.HTTPWasm)
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.Router)
+
+	filter := &wasmfilter.Wasm{}
+	err := anypb.UnmarshalTo(connManager.HttpFilters[1].GetTypedConfig(), filter, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, filter.Config.GetVmConfig().Code.GetLocal().GetFilename(), "/var/lib/kourier/transform.wasm")
+}
+
+func TestNewHTTPConnectionManagerWithQueryParametersLimit(t *testing.T) {
+	kourierConfig := config.Kourier{
+		MaxQueryParameters: 3,
+	}
+	connManager := NewHTTPConnectionManager("test", &kourierConfig)
+
+	// The query parameters are limited first and the path is restored before the router.
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, limitQueryParametersFilterName)
+	assert.Equal(t, connManager.HttpFilters[2].Name, restorePathFilterName)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.Router)
+}
+
+func TestNewHTTPConnectionManagerWithStripHostPort(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.StripPortMode == nil)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{StripHostPort: true})
+	assert.Check(t, connManager.GetStripAnyHostPort())
+}
+
+func TestNewHTTPConnectionManagerWithXffNumTrustedHops(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Equal(t, connManager.XffNumTrustedHops, uint32(0))
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{XffNumTrustedHops: 1})
+	assert.Check(t, connManager.GetUseRemoteAddress().GetValue())
+	assert.Equal(t, connManager.XffNumTrustedHops, uint32(1))
+}
+
+func TestNewHTTPConnectionManagerWithOriginalIPHeader(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Equal(t, len(connManager.OriginalIpDetectionExtensions), 0)
+	assert.Check(t, !connManager.SkipXffAppend)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{
+		OriginalIPHeader: "CF-Connecting-IP",
+		SkipXffAppend:    true,
+	})
+	assert.Check(t, connManager.SkipXffAppend)
+	// The extensions can't be used along with the remote address.
+	assert.Check(t, connManager.UseRemoteAddress == nil)
+	assert.Equal(t, len(connManager.OriginalIpDetectionExtensions), 1)
+
+	detection := &customheader.CustomHeaderConfig{}
+	assert.NilError(t, connManager.OriginalIpDetectionExtensions[0].TypedConfig.UnmarshalTo(detection))
+	assert.NilError(t, detection.Validate())
+	assert.Equal(t, detection.HeaderName, "CF-Connecting-IP")
+}
+
+func TestNewHTTPConnectionManagerWithVirtualHostDiscovery(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{VirtualHostDiscovery: true})
+
+	// The virtual hosts are requested right after the sticky canary cookie is passed on.
+	assert.Equal(t, len(connManager.HttpFilters), 3)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, onDemandFilterName)
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.Router)
+}
+
+func TestNewHTTPConnectionManagerWithGRPCWeb(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{
+		GRPCWeb:                  true,
+		TransformationWasmModule: "/var/lib/kourier/transform.wasm",
+	})
+
+	// gRPC-Web is translated before the requests are transformed.
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, wellknown.GRPCWeb)
+	assert.Equal(t, connManager.HttpFilters[1].GetTypedConfig().GetTypeUrl(),
+		"type.googleapis.com/envoy.extensions.filters.http.grpc_web.v3.GrpcWeb")
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.HTTPWasm)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.Router)
+}
+
+func TestNewHTTPConnectionManagerWithScannerMitigation(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{ScannerMitigation: true})
+
+	// The requests of scanners are rejected first.
+	assert.Equal(t, len(connManager.HttpFilters), 3)
+	assert.Equal(t, connManager.HttpFilters[0].Name, denyScannersFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.Router)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{ScannerMitigation: true, ScannerRateLimit: 10})
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[0].Name, denyScannersFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, localRateLimitFilter)
+
+	// The rate limit is part of the scanner mitigation.
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{ScannerRateLimit: 10})
+	assert.Equal(t, len(connManager.HttpFilters), 2)
+}
+
+func TestNewHTTPConnectionManagerWithGRPCJSONTranscoding(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{
+		GRPCJSONTranscoding:      true,
+		TransformationWasmModule: "/var/lib/kourier/transform.wasm",
+		MaxQueryParameters:       3,
+	})
+
+	// The requests are transcoded right before the router, with their original path.
+	assert.Equal(t, len(connManager.HttpFilters), 6)
+	assert.Equal(t, connManager.HttpFilters[2].Name, restorePathFilterName)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.HTTPWasm)
+	assert.Equal(t, connManager.HttpFilters[4].Name, wellknown.GRPCJSONTranscoder)
+	assert.Equal(t, connManager.HttpFilters[5].Name, wellknown.Router)
+}
+
+func TestNewHTTPConnectionManagerWithGatewayErrorHeader(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.LocalReplyConfig == nil)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{GatewayErrorHeader: true})
+	assert.Check(t, connManager.LocalReplyConfig != nil)
+}
+
+func TestNewHTTPConnectionManagerWithTimeouts(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.CommonHttpProtocolOptions == nil)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{
+		ConnectionIdleTimeout: time.Minute,
+		MaxStreamDuration:     time.Hour,
+	})
+	assert.Equal(t, connManager.CommonHttpProtocolOptions.IdleTimeout.AsDuration(), time.Minute)
+	assert.Equal(t, connManager.CommonHttpProtocolOptions.MaxStreamDuration.AsDuration(), time.Hour)
+}
+
+func TestNewHTTPConnectionManagerWithRequestTimeouts(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.RequestTimeout == nil)
+	assert.Check(t, connManager.RequestHeadersTimeout == nil)
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{
+		RequestTimeout:        time.Minute,
+		RequestHeadersTimeout: 10 * time.Second,
+	})
+	assert.Equal(t, connManager.RequestTimeout.AsDuration(), time.Minute)
+	assert.Equal(t, connManager.RequestHeadersTimeout.AsDuration(), 10*time.Second)
+}
+
+func TestSetAllowedUpgrades(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.UpgradeConfigs == nil)
+
+	SetAllowedUpgrades(connManager, []string{"websocket"})
+	assert.DeepEqual(t, connManager.UpgradeConfigs, []*hcm.HttpConnectionManager_UpgradeConfig{{
+		UpgradeType: "websocket",
+		Enabled:     wrapperspb.Bool(true),
+	}, {
+		UpgradeType: UpgradeH2C,
+		Enabled:     wrapperspb.Bool(false),
+	}}, protocmp.Transform())
+}
+
+func TestNewHTTPConnectionManagerWithRequestLimits(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{})
+	assert.Check(t, connManager.MaxRequestHeadersKb == nil)
+	for _, filter := range connManager.HttpFilters {
+		assert.Check(t, filter.Name != wellknown.Buffer)
+	}
+
+	connManager = NewHTTPConnectionManager("test", &config.Kourier{
+		MaxRequestHeadersKB: 96,
+		MaxRequestBodyBytes: 1048576,
+	})
+	assert.Equal(t, connManager.MaxRequestHeadersKb.GetValue(), uint32(96))
+	assert.Equal(t, len(connManager.HttpFilters), 3)
+	assert.Equal(t, connManager.HttpFilters[1].Name, wellknown.Buffer)
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.Router)
+}
+
+func TestRemoveHTTPFilter(t *testing.T) {
+	kourierConfig := config.Kourier{MaxRequestBodyBytes: 1024}
+	connManager := NewHTTPConnectionManager("test", &kourierConfig)
+
+	RemoveHTTPFilter(connManager, wellknown.Buffer)
+
+	assert.Equal(t, len(connManager.HttpFilters), 2)
+	for _, filter := range connManager.HttpFilters {
+		assert.Assert(t, filter.Name != wellknown.Buffer)
+	}
+	assert.Equal(t, connManager.HttpFilters[1].Name, wellknown.Router)
+}
+
 func TestNewRouteConfig(t *testing.T) {
 	vhost := NewVirtualHost(
 		"test",
@@ -42,3 +42,10 @@ func NewLBEndpoint(ip string, port uint32) *endpoint.LbEndpoint {
 		},
 	}
 }
+
+// NewLBEndpointWithHealthStatus creates a new LbEndpoint with the given health status.
+func NewLBEndpointWithHealthStatus(ip string, port uint32, status core.HealthStatus) *endpoint.LbEndpoint {
+	lbEndpoint := NewLBEndpoint(ip, port)
+	lbEndpoint.HealthStatus = status
+	return lbEndpoint
+}
@@ -35,3 +35,10 @@ func TestNewLBEndpoint(t *testing.T) {
 	assert.Equal(t, ip, socketAddress.Address)
 	assert.Equal(t, port, socketAddress.PortSpecifier.(*core.SocketAddress_PortValue).PortValue)
 }
+
+func TestNewLBEndpointWithHealthStatus(t *testing.T) {
+	endpoint := NewLBEndpointWithHealthStatus("127.0.0.1", 8080, core.HealthStatus_DEGRADED)
+
+	assert.Equal(t, endpoint.GetHealthStatus(), core.HealthStatus_DEGRADED)
+	assert.Equal(t, endpoint.GetEndpoint().GetAddress().GetSocketAddress().GetAddress(), "127.0.0.1")
+}
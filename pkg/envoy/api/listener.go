@@ -18,14 +18,17 @@ package envoy
 
 import (
 	"fmt"
+	"net"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	prx "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -36,6 +39,18 @@ type SNIMatch struct {
 	CertSource       types.NamespacedName
 	CertificateChain []byte
 	PrivateKey       []byte
+	// ClientValidation, if set, requires clients to present a valid certificate.
+	ClientValidation *ClientValidation
+}
+
+// ClientValidation represents the validation of client certificates, including the
+// CAs to validate against and the source where we got them from.
+type ClientValidation struct {
+	CASource       types.NamespacedName
+	CACertificates []byte
+	// AllowedSANs restricts the accepted client certificates to those having one of
+	// the given subject alternative names. All SANs are accepted if empty.
+	AllowedSANs []string
 }
 
 // NewHTTPListener creates a new Listener at the given port, backed by the given manager.
@@ -112,9 +127,15 @@ func CreateFilterChainFromCertificateAndPrivateKey(
 // NewHTTPSListenerWithSNI creates a new Listener at the given port, backed by the given
 // manager and applies a FilterChain with the given sniMatches.
 //
+// The certificates of the sniMatches are not inlined but referenced via SDS. The
+// respective secrets have to be created with NewSecret and SecretName.
+//
+// The given tlsParams apply to all of the sniMatches. If nil, TLS 1.2 is the minimum
+// version and Envoy's defaults are used otherwise.
+//
 // Ref: https://www.envoyproxy.io/docs/envoy/latest/faq/configuration/sni.html
-func NewHTTPSListenerWithSNI(manager *hcm.HttpConnectionManager, port uint32, sniMatches []*SNIMatch, enableProxyProtocol bool) (*listener.Listener, error) {
-	filterChains, err := createFilterChainsForTLS(manager, sniMatches)
+func NewHTTPSListenerWithSNI(manager *hcm.HttpConnectionManager, port uint32, sniMatches []*SNIMatch, enableProxyProtocol bool, tlsParams *auth.TlsParameters) (*listener.Listener, error) {
+	filterChains, err := createFilterChainsForTLS(manager, sniMatches, tlsParams)
 	if err != nil {
 		return nil, err
 	}
@@ -134,17 +155,7 @@ func NewHTTPSListenerWithSNI(manager *hcm.HttpConnectionManager, port uint32, sn
 		listenerFilter = append(listenerFilter, proxyProtocolListenerFilter)
 	}
 
-	listenerFilterForTLS := &listener.ListenerFilter{
-		// TLS Inspector listener filter must be configured in order to
-		// detect requested SNI.
-		// Ref: https://www.envoyproxy.io/docs/envoy/latest/faq/configuration/sni.html
-		Name: wellknown.TlsInspector,
-		ConfigType: &listener.ListenerFilter_TypedConfig{TypedConfig: &anypb.Any{
-			TypeUrl: "type.googleapis.com/envoy.extensions.filters.listener.tls_inspector.v3.TlsInspector",
-		}},
-	}
-
-	listenerFilter = append(listenerFilter, listenerFilterForTLS)
+	listenerFilter = append(listenerFilter, newTLSInspectorListenerFilter())
 
 	return &listener.Listener{
 		Name:            CreateListenerName(port),
@@ -154,6 +165,45 @@ func NewHTTPSListenerWithSNI(manager *hcm.HttpConnectionManager, port uint32, sn
 	}, nil
 }
 
+// SetSourcePrefixRanges restricts all filter chains of the listener to connections from
+// the given source CIDRs. Connections from other sources match no filter chain and are
+// closed. The listener is not restricted if cidrs is empty.
+func SetSourcePrefixRanges(l *listener.Listener, cidrs []string) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	ranges := make([]*core.CidrRange, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidrRange, err := newCidrRange(cidr)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, cidrRange)
+	}
+
+	for _, filterChain := range l.FilterChains {
+		if filterChain.FilterChainMatch == nil {
+			filterChain.FilterChainMatch = &listener.FilterChainMatch{}
+		}
+		filterChain.FilterChainMatch.SourcePrefixRanges = ranges
+	}
+	return nil
+}
+
+// newCidrRange returns the CidrRange of the given CIDR.
+func newCidrRange(cidr string) (*core.CidrRange, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	prefixLen, _ := ipNet.Mask.Size()
+	return &core.CidrRange{
+		AddressPrefix: ipNet.IP.String(),
+		PrefixLen:     wrapperspb.UInt32(uint32(prefixLen)),
+	}, nil
+}
+
 // CreateListenerName returns a listener name based on port
 func CreateListenerName(port uint32) string {
 	return fmt.Sprintf("listener_%d", port)
@@ -185,7 +235,7 @@ func createFilters(manager *hcm.HttpConnectionManager) ([]*listener.Filter, erro
 	}}, nil
 }
 
-func createFilterChainsForTLS(manager *hcm.HttpConnectionManager, sniMatches []*SNIMatch) ([]*listener.FilterChain, error) {
+func createFilterChainsForTLS(manager *hcm.HttpConnectionManager, sniMatches []*SNIMatch, tlsParams *auth.TlsParameters) ([]*listener.FilterChain, error) {
 	res := make([]*listener.FilterChain, 0, len(sniMatches))
 	for _, sniMatch := range sniMatches {
 		filters, err := createFilters(manager)
@@ -193,7 +243,10 @@ func createFilterChainsForTLS(manager *hcm.HttpConnectionManager, sniMatches []*
 			return nil, err
 		}
 
-		tlsContext := createTLSContext(sniMatch.CertificateChain, sniMatch.PrivateKey)
+		tlsContext := createSDSTLSContext(SecretName(sniMatch.CertSource), tlsParams)
+		if sniMatch.ClientValidation != nil {
+			setClientValidation(tlsContext, sniMatch.ClientValidation)
+		}
 		tlsAny, err := anypb.New(tlsContext)
 		if err != nil {
 			return nil, err
@@ -217,26 +270,72 @@ func createFilterChainsForTLS(manager *hcm.HttpConnectionManager, sniMatches []*
 }
 
 func createTLSContext(certificate []byte, privateKey []byte) *auth.DownstreamTlsContext {
+	tlsContext := newDownstreamTLSContext(nil)
+	tlsContext.CommonTlsContext.TlsCertificates = []*auth.TlsCertificate{
+		newTLSCertificate(certificate, privateKey),
+	}
+	return tlsContext
+}
+
+// createSDSTLSContext creates a TLS context that fetches its certificate via SDS, so
+// that certificates can be rotated without pushing the listeners again.
+func createSDSTLSContext(secretName string, tlsParams *auth.TlsParameters) *auth.DownstreamTlsContext {
+	tlsContext := newDownstreamTLSContext(tlsParams)
+	tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*auth.SdsSecretConfig{
+		sdsSecretConfig(secretName),
+	}
+	return tlsContext
+}
+
+// setClientValidation requires clients to present a certificate that is valid according
+// to the given validation.
+func setClientValidation(tlsContext *auth.DownstreamTlsContext, validation *ClientValidation) {
+	sans := make([]*envoymatcherv3.StringMatcher, 0, len(validation.AllowedSANs))
+	for _, san := range validation.AllowedSANs {
+		sans = append(sans, &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: san},
+		})
+	}
+
+	tlsContext.RequireClientCertificate = wrapperspb.Bool(true)
+	tlsContext.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_ValidationContext{
+		ValidationContext: &auth.CertificateValidationContext{
+			TrustedCa: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: validation.CACertificates},
+			},
+			MatchSubjectAltNames: sans,
+		},
+	}
+}
+
+func newDownstreamTLSContext(tlsParams *auth.TlsParameters) *auth.DownstreamTlsContext {
+	if tlsParams == nil {
+		// Temporary fix until we start using envoyproxy image newer than v1.23.0 (envoyproxy has adopted TLS v1.2 as the default minimum version in https://github.com/envoyproxy/envoy/commit/f8baa480ec9c6cbaa7a9d5433102efb04145cfc8)
+		tlsParams = &auth.TlsParameters{
+			TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+		}
+	}
 	return &auth.DownstreamTlsContext{
 		CommonTlsContext: &auth.CommonTlsContext{
 			AlpnProtocols: []string{"h2", "http/1.1"},
-			// Temporary fix until we start using envoyproxy image newer than v1.23.0 (envoyproxy has adopted TLS v1.2 as the default minimum version in https://github.com/envoyproxy/envoy/commit/f8baa480ec9c6cbaa7a9d5433102efb04145cfc8)
-			TlsParams: &auth.TlsParameters{
-				TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
-			},
-			TlsCertificates: []*auth.TlsCertificate{{
-				CertificateChain: &core.DataSource{
-					Specifier: &core.DataSource_InlineBytes{InlineBytes: certificate},
-				},
-				PrivateKey: &core.DataSource{
-					Specifier: &core.DataSource_InlineBytes{InlineBytes: privateKey},
-				},
-			}},
+			TlsParams:     tlsParams,
 		},
 	}
 }
 
 // Ref: https://www.envoyproxy.io/docs/envoy/latest/configuration/listeners/listener_filters/proxy_protocol
+// newTLSInspectorListenerFilter creates the TLS Inspector listener filter, which must be
+// configured in order to detect the requested SNI.
+// Ref: https://www.envoyproxy.io/docs/envoy/latest/faq/configuration/sni.html
+func newTLSInspectorListenerFilter() *listener.ListenerFilter {
+	return &listener.ListenerFilter{
+		Name: wellknown.TlsInspector,
+		ConfigType: &listener.ListenerFilter_TypedConfig{TypedConfig: &anypb.Any{
+			TypeUrl: "type.googleapis.com/envoy.extensions.filters.listener.tls_inspector.v3.TlsInspector",
+		}},
+	}
+}
+
 func createProxyProtocolListenerFilter() (listenerFilter *listener.ListenerFilter, err error) {
 	listenerFilterConfig, err := anypb.New(&prx.ProxyProtocol{})
 	if err != nil {
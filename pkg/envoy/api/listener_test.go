@@ -31,6 +31,7 @@ import (
 	"google.golang.org/protobuf/types/known/anypb"
 	"gotest.tools/v3/assert"
 	is "gotest.tools/v3/assert/cmp"
+	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/net-kourier/pkg/config"
 )
 
@@ -143,10 +144,12 @@ func TestNewHTTPSListenerWithProxyProtocol(t *testing.T) {
 func TestNewHTTPSListenerWithSNI(t *testing.T) {
 	sniMatches := []*SNIMatch{{
 		Hosts:            []string{"some_host.com"},
+		CertSource:       types.NamespacedName{Namespace: "secretns", Name: "secret1"},
 		CertificateChain: []byte("cert1"),
 		PrivateKey:       []byte("key1"),
 	}, {
 		Hosts:            []string{"another_host.com"},
+		CertSource:       types.NamespacedName{Namespace: "secretns", Name: "secret2"},
 		CertificateChain: []byte("cert2"),
 		PrivateKey:       []byte("key2"),
 	}}
@@ -157,7 +160,7 @@ func TestNewHTTPSListenerWithSNI(t *testing.T) {
 		IdleTimeout:                0 * time.Second,
 	}
 	manager := NewHTTPConnectionManager("test", &kourierConfig)
-	listener, err := NewHTTPSListenerWithSNI(manager, 8443, sniMatches, false)
+	listener, err := NewHTTPSListenerWithSNI(manager, 8443, sniMatches, false, nil)
 	assert.NilError(t, err)
 
 	assert.Equal(t, core.SocketAddress_TCP, listener.Address.GetSocketAddress().Protocol)
@@ -176,10 +179,12 @@ func TestNewHTTPSListenerWithSNI(t *testing.T) {
 func TestNewHTTPSListenerWithSNIWithProxyProtocol(t *testing.T) {
 	sniMatches := []*SNIMatch{{
 		Hosts:            []string{"some_host.com"},
+		CertSource:       types.NamespacedName{Namespace: "secretns", Name: "secret1"},
 		CertificateChain: []byte("cert1"),
 		PrivateKey:       []byte("key1"),
 	}, {
 		Hosts:            []string{"another_host.com"},
+		CertSource:       types.NamespacedName{Namespace: "secretns", Name: "secret2"},
 		CertificateChain: []byte("cert2"),
 		PrivateKey:       []byte("key2"),
 	}}
@@ -189,7 +194,7 @@ func TestNewHTTPSListenerWithSNIWithProxyProtocol(t *testing.T) {
 		IdleTimeout:                0 * time.Second,
 	}
 	manager := NewHTTPConnectionManager("test", &kourierConfig)
-	listener, err := NewHTTPSListenerWithSNI(manager, 8443, sniMatches, true)
+	listener, err := NewHTTPSListenerWithSNI(manager, 8443, sniMatches, true, nil)
 	assert.NilError(t, err)
 
 	assert.Equal(t, core.SocketAddress_TCP, listener.Address.GetSocketAddress().Protocol)
@@ -204,14 +209,89 @@ func TestNewHTTPSListenerWithSNIWithProxyProtocol(t *testing.T) {
 	assertListenerHasSNIMatchConfigured(t, listener, sniMatches[1])
 }
 
+func TestNewHTTPSListenerWithSNIWithClientValidation(t *testing.T) {
+	sniMatches := []*SNIMatch{{
+		Hosts:      []string{"some_host.com"},
+		CertSource: types.NamespacedName{Namespace: "secretns", Name: "secret1"},
+		ClientValidation: &ClientValidation{
+			CASource:       types.NamespacedName{Namespace: "secretns", Name: "ca"},
+			CACertificates: []byte("ca"),
+			AllowedSANs:    []string{"client.example.com"},
+		},
+	}, {
+		Hosts:      []string{"another_host.com"},
+		CertSource: types.NamespacedName{Namespace: "secretns", Name: "secret2"},
+	}}
+
+	manager := NewHTTPConnectionManager("test", &config.Kourier{})
+	listener, err := NewHTTPSListenerWithSNI(manager, 8443, sniMatches, false, nil)
+	assert.NilError(t, err)
+
+	downstreamTLSContext := &auth.DownstreamTlsContext{}
+	err = anypb.UnmarshalTo(getFilterChainByServerName(listener, sniMatches[0].Hosts).GetTransportSocket().GetTypedConfig(), downstreamTLSContext, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, downstreamTLSContext.RequireClientCertificate.GetValue())
+	validationContext := downstreamTLSContext.CommonTlsContext.GetValidationContext()
+	assert.DeepEqual(t, validationContext.TrustedCa.GetInlineBytes(), []byte("ca"))
+	assert.Equal(t, len(validationContext.MatchSubjectAltNames), 1)
+	assert.Equal(t, validationContext.MatchSubjectAltNames[0].GetExact(), "client.example.com")
+
+	// Client certificates are only required for the matches with client validation.
+	downstreamTLSContext = &auth.DownstreamTlsContext{}
+	err = anypb.UnmarshalTo(getFilterChainByServerName(listener, sniMatches[1].Hosts).GetTransportSocket().GetTypedConfig(), downstreamTLSContext, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, !downstreamTLSContext.RequireClientCertificate.GetValue())
+	assert.Assert(t, downstreamTLSContext.CommonTlsContext.GetValidationContext() == nil)
+}
+
+func TestSetSourcePrefixRanges(t *testing.T) {
+	sniMatches := []*SNIMatch{{
+		Hosts:      []string{"some_host.com"},
+		CertSource: types.NamespacedName{Namespace: "secretns", Name: "secret1"},
+	}, {
+		Hosts:      []string{"another_host.com"},
+		CertSource: types.NamespacedName{Namespace: "secretns", Name: "secret2"},
+	}}
+
+	manager := NewHTTPConnectionManager("test", &config.Kourier{})
+	listener, err := NewHTTPSListenerWithSNI(manager, 7443, sniMatches, false, nil)
+	assert.NilError(t, err)
+
+	assert.NilError(t, SetSourcePrefixRanges(listener, []string{"10.1.2.3/8", "2001:db8::/32"}))
+	for _, filterChain := range listener.FilterChains {
+		ranges := filterChain.FilterChainMatch.SourcePrefixRanges
+		assert.Equal(t, len(ranges), 2)
+		assert.Equal(t, ranges[0].AddressPrefix, "10.0.0.0")
+		assert.Equal(t, ranges[0].PrefixLen.GetValue(), uint32(8))
+		assert.Equal(t, ranges[1].AddressPrefix, "2001:db8::")
+		assert.Equal(t, ranges[1].PrefixLen.GetValue(), uint32(32))
+	}
+	// The SNI matching is retained.
+	assertListenerHasSNIMatchConfigured(t, listener, sniMatches[0])
+
+	// Listeners without a filter chain match get one.
+	listener, err = NewHTTPListener(manager, 7080, false)
+	assert.NilError(t, err)
+	assert.NilError(t, SetSourcePrefixRanges(listener, []string{"192.168.0.0/16"}))
+	assert.Equal(t, listener.FilterChains[0].FilterChainMatch.SourcePrefixRanges[0].AddressPrefix, "192.168.0.0")
+
+	assert.ErrorContains(t, SetSourcePrefixRanges(listener, []string{"192.168.0.0"}), "invalid CIDR")
+}
+
 func assertListenerHasSNIMatchConfigured(t *testing.T, listener *envoy_api_v3.Listener, match *SNIMatch) {
 	filterChainFirstSNIMatch := getFilterChainByServerName(listener, match.Hosts)
 	assert.Assert(t, filterChainFirstSNIMatch != nil)
 
-	certChain, privateKey, err := getTLSCreds(filterChainFirstSNIMatch)
+	downstreamTLSContext := &auth.DownstreamTlsContext{}
+	err := anypb.UnmarshalTo(filterChainFirstSNIMatch.GetTransportSocket().GetTypedConfig(), downstreamTLSContext, proto.UnmarshalOptions{})
 	assert.NilError(t, err)
-	assert.DeepEqual(t, match.CertificateChain, certChain)
-	assert.DeepEqual(t, match.PrivateKey, privateKey)
+
+	// Certificates must be fetched via SDS rather than being inlined.
+	assert.Check(t, len(downstreamTLSContext.CommonTlsContext.TlsCertificates) == 0)
+	sdsConfigs := downstreamTLSContext.CommonTlsContext.TlsCertificateSdsSecretConfigs
+	assert.Assert(t, len(sdsConfigs) == 1)
+	assert.Equal(t, sdsConfigs[0].Name, SecretName(match.CertSource))
+	assert.Assert(t, sdsConfigs[0].SdsConfig.GetAds() != nil)
 }
 
 func assertListenerHasProxyProtocolConfigured(t *testing.T, listenerFilter *envoy_api_v3.ListenerFilter) {
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const luaSnippetFilterName = "kourier.lua_snippet"
+
+// luaSnippetCode is the code of the filter running the Lua snippets, which does nothing
+// on its own.
+const luaSnippetCode = "-- Runs the Lua snippets of the virtual hosts."
+
+// NewLuaSnippetFilter creates the filter running Lua snippets. It doesn't run any code
+// on its own, but only the snippets of the virtual hosts configured with SetLuaSnippet.
+func NewLuaSnippetFilter() *hcm.HttpFilter {
+	return newLuaFilter(luaSnippetFilterName, luaSnippetCode)
+}
+
+// SetLuaSnippet makes the VirtualHost run the given Lua code on its requests.
+func SetLuaSnippet(vh *route.VirtualHost, code string) {
+	filter, _ := anypb.New(&lua.LuaPerRoute{
+		Override: &lua.LuaPerRoute_SourceCode{
+			SourceCode: &core.DataSource{Specifier: &core.DataSource_InlineString{InlineString: code}},
+		},
+	})
+
+	if vh.TypedPerFilterConfig == nil {
+		vh.TypedPerFilterConfig = make(map[string]*anypb.Any, 1)
+	}
+	vh.TypedPerFilterConfig[luaSnippetFilterName] = filter
+}
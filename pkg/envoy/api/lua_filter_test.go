@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewLuaSnippetFilter(t *testing.T) {
+	filter := NewLuaSnippetFilter()
+
+	assert.Equal(t, filter.Name, luaSnippetFilterName)
+	luaConfig := &lua.Lua{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), luaConfig, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, luaConfig.Validate())
+}
+
+func TestSetLuaSnippet(t *testing.T) {
+	vh := NewVirtualHostWithExtAuthz("test", nil, []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+	})
+
+	SetLuaSnippet(vh, `function envoy_on_request(handle) handle:headers():remove("x-debug") end`)
+
+	// The other filters' configs are kept.
+	assert.Assert(t, vh.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+
+	perRoute := &lua.LuaPerRoute{}
+	err := anypb.UnmarshalTo(vh.TypedPerFilterConfig[luaSnippetFilterName], perRoute, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, perRoute.Validate())
+	assert.Equal(t, perRoute.GetSourceCode().GetInlineString(),
+		`function envoy_on_request(handle) handle:headers():remove("x-debug") end`)
+}
+
+func TestNewHTTPConnectionManagerWithLuaFilters(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{
+		LuaFilters:               true,
+		TransformationWasmModule: "/var/lib/kourier/transform.wasm",
+	})
+
+	// The snippets run before the requests are transformed.
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, luaSnippetFilterName)
+	assert.Equal(t, connManager.HttpFilters[2].Name, wellknown.HTTPWasm)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.Router)
+}
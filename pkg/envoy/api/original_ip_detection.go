@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	customheader "github.com/envoyproxy/go-control-plane/envoy/extensions/http/original_ip_detection/custom_header/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const customHeaderOriginalIPDetectionName = "envoy.http.original_ip_detection.custom_header"
+
+// NewCustomHeaderOriginalIPDetection creates the extension detecting the address of the
+// clients from the given header. The address of the connection is used if the header is
+// missing or invalid.
+func NewCustomHeaderOriginalIPDetection(header string) *core.TypedExtensionConfig {
+	detection, _ := anypb.New(&customheader.CustomHeaderConfig{HeaderName: header})
+	return &core.TypedExtensionConfig{
+		Name:        customHeaderOriginalIPDetectionName,
+		TypedConfig: detection,
+	}
+}
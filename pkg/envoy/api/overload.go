@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"time"
+
+	overload "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	fixedheap "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// fixedHeapMonitor is the resource monitor of the heap usage of the gateways.
+	fixedHeapMonitor = "envoy.resource_monitors.fixed_heap"
+
+	// DefaultShrinkHeapThreshold and DefaultStopAcceptingRequestsThreshold are the
+	// fractions of the heap size the gateways shrink their heap and stop accepting
+	// requests at by default, as recommended by Envoy.
+	DefaultShrinkHeapThreshold            = 0.95
+	DefaultStopAcceptingRequestsThreshold = 0.98
+
+	// overloadRefreshInterval is how often the resource monitors are sampled.
+	overloadRefreshInterval = 250 * time.Millisecond
+)
+
+// NewOverloadManager creates the overload manager of the gateways, shedding load once
+// their heap reaches the given fractions of the given size: first by returning freed
+// memory to the system, then by rejecting new requests with a 503. The default
+// thresholds are used for the ones which are 0.
+func NewOverloadManager(maxHeapSizeBytes uint64, shrinkHeapThreshold, stopAcceptingRequestsThreshold float64) (*overload.OverloadManager, error) {
+	if shrinkHeapThreshold == 0 {
+		shrinkHeapThreshold = DefaultShrinkHeapThreshold
+	}
+	if stopAcceptingRequestsThreshold == 0 {
+		stopAcceptingRequestsThreshold = DefaultStopAcceptingRequestsThreshold
+	}
+
+	heapConfig, err := anypb.New(&fixedheap.FixedHeapConfig{MaxHeapSizeBytes: maxHeapSizeBytes})
+	if err != nil {
+		return nil, err
+	}
+
+	return &overload.OverloadManager{
+		RefreshInterval: durationpb.New(overloadRefreshInterval),
+		ResourceMonitors: []*overload.ResourceMonitor{{
+			Name:       fixedHeapMonitor,
+			ConfigType: &overload.ResourceMonitor_TypedConfig{TypedConfig: heapConfig},
+		}},
+		Actions: []*overload.OverloadAction{
+			newHeapOverloadAction("envoy.overload_actions.shrink_heap", shrinkHeapThreshold),
+			newHeapOverloadAction("envoy.overload_actions.stop_accepting_requests", stopAcceptingRequestsThreshold),
+		},
+	}, nil
+}
+
+// newHeapOverloadAction creates the overload action with the given name, triggered once
+// the heap usage reaches the given threshold.
+func newHeapOverloadAction(name string, threshold float64) *overload.OverloadAction {
+	return &overload.OverloadAction{
+		Name: name,
+		Triggers: []*overload.Trigger{{
+			Name: fixedHeapMonitor,
+			TriggerOneof: &overload.Trigger_Threshold{
+				Threshold: &overload.ThresholdTrigger{Value: threshold},
+			},
+		}},
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	fixedheap "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewOverloadManager(t *testing.T) {
+	manager, err := NewOverloadManager(1<<30, 0.9, 0)
+	assert.NilError(t, err)
+	assert.NilError(t, manager.Validate())
+
+	heapConfig := &fixedheap.FixedHeapConfig{}
+	assert.NilError(t, manager.ResourceMonitors[0].GetTypedConfig().UnmarshalTo(heapConfig))
+	assert.Equal(t, heapConfig.MaxHeapSizeBytes, uint64(1<<30))
+
+	thresholds := make(map[string]float64, len(manager.Actions))
+	for _, action := range manager.Actions {
+		assert.Equal(t, action.Triggers[0].Name, manager.ResourceMonitors[0].Name)
+		thresholds[action.Name] = action.Triggers[0].GetThreshold().Value
+	}
+	assert.DeepEqual(t, thresholds, map[string]float64{
+		"envoy.overload_actions.shrink_heap": 0.9,
+		// The default is used if 0.
+		"envoy.overload_actions.stop_accepting_requests": DefaultStopAcceptingRequestsThreshold,
+	})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	httpinspector "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/http_inspector/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// plaintextTransportProtocol is the transport protocol the TLS inspector detects
+	// for the connections which don't start with a TLS handshake.
+	plaintextTransportProtocol = "raw_buffer"
+)
+
+// plaintextApplicationProtocols are the application protocols the HTTP inspector
+// detects for plaintext HTTP connections.
+var plaintextApplicationProtocols = []string{"http/1.1", "h2c"}
+
+// AddPlaintextFallbackFilterChain makes the TLS listener serve the plaintext HTTP
+// connections it receives with the given manager, rather than failing their TLS
+// handshake. The TLS inspector tells the plaintext connections apart, and the HTTP
+// inspector makes sure they're HTTP, so that other connections are still closed.
+func AddPlaintextFallbackFilterChain(l *listener.Listener, manager *hcm.HttpConnectionManager) error {
+	filters, err := createFilters(manager)
+	if err != nil {
+		return err
+	}
+	l.FilterChains = append(l.FilterChains, &listener.FilterChain{
+		FilterChainMatch: &listener.FilterChainMatch{
+			TransportProtocol:    plaintextTransportProtocol,
+			ApplicationProtocols: plaintextApplicationProtocols,
+		},
+		Filters: filters,
+	})
+
+	var hasTLSInspector, hasHTTPInspector bool
+	for _, filter := range l.ListenerFilters {
+		hasTLSInspector = hasTLSInspector || filter.Name == wellknown.TlsInspector
+		hasHTTPInspector = hasHTTPInspector || filter.Name == wellknown.HttpInspector
+	}
+	if !hasTLSInspector {
+		l.ListenerFilters = append(l.ListenerFilters, newTLSInspectorListenerFilter())
+	}
+	if !hasHTTPInspector {
+		httpInspector, err := newHTTPInspectorListenerFilter()
+		if err != nil {
+			return err
+		}
+		l.ListenerFilters = append(l.ListenerFilters, httpInspector)
+	}
+	return nil
+}
+
+// newHTTPInspectorListenerFilter creates the HTTP Inspector listener filter, which
+// detects the application protocol of plaintext connections.
+func newHTTPInspectorListenerFilter() (*listener.ListenerFilter, error) {
+	config, err := anypb.New(&httpinspector.HttpInspector{})
+	if err != nil {
+		return nil, err
+	}
+	return &listener.ListenerFilter{
+		Name:       wellknown.HttpInspector,
+		ConfigType: &listener.ListenerFilter_TypedConfig{TypedConfig: config},
+	}, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestAddPlaintextFallbackFilterChain(t *testing.T) {
+	manager := NewHTTPConnectionManager("external_services", &config.Kourier{})
+
+	l, err := NewHTTPSListener(8443, []*listener.FilterChain{{}}, true)
+	assert.NilError(t, err)
+	assert.NilError(t, AddPlaintextFallbackFilterChain(l, manager))
+
+	// The plaintext HTTP connections are matched after the TLS ones.
+	assert.Equal(t, len(l.FilterChains), 2)
+	fallback := l.FilterChains[1]
+	assert.Equal(t, fallback.FilterChainMatch.TransportProtocol, "raw_buffer")
+	assert.DeepEqual(t, fallback.FilterChainMatch.ApplicationProtocols, []string{"http/1.1", "h2c"})
+	assert.Equal(t, fallback.Filters[0].Name, wellknown.HTTPConnectionManager)
+
+	// The inspectors run after the proxy protocol filter.
+	assert.Equal(t, len(l.ListenerFilters), 3)
+	assert.Equal(t, l.ListenerFilters[0].Name, wellknown.ProxyProtocol)
+	assert.Equal(t, l.ListenerFilters[1].Name, wellknown.TlsInspector)
+	assert.Equal(t, l.ListenerFilters[2].Name, wellknown.HttpInspector)
+
+	// Listeners inspecting the connections already don't inspect them twice.
+	sni, err := NewHTTPSListenerWithSNI(manager, 8443, []*SNIMatch{{Hosts: []string{"foo.example.com"}}}, false, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, AddPlaintextFallbackFilterChain(sni, manager))
+	assert.Equal(t, len(sni.ListenerFilters), 2)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// OriginalPathHeader is the header carrying the original request path while
+	// the query parameters are stripped or capped.
+	OriginalPathHeader = "x-kourier-original-path"
+
+	limitQueryParametersFilterName = "kourier.limit_query_parameters"
+	restorePathFilterName          = "kourier.restore_path"
+)
+
+// limitQueryParametersCode keeps at most the given number of query parameters in
+// the request path and saves the original path in OriginalPathHeader. A client
+// provided OriginalPathHeader is always dropped so it can't be used to alter the
+// path sent upstream.
+const limitQueryParametersCode = `
+function envoy_on_request(handle)
+  local headers = handle:headers()
+  headers:remove("%[1]s")
+
+  local path = headers:get(":path")
+  local query_start = path and string.find(path, "?", 1, true)
+  if query_start == nil then
+    return
+  end
+
+  local kept = {}
+  for param in string.gmatch(string.sub(path, query_start + 1), "[^&]+") do
+    if #kept >= %[2]d then
+      break
+    end
+    table.insert(kept, param)
+  end
+
+  local limited = string.sub(path, 1, query_start - 1)
+  if #kept > 0 then
+    limited = limited .. "?" .. table.concat(kept, "&")
+  end
+  if limited ~= path then
+    headers:add("%[1]s", path)
+    headers:replace(":path", limited)
+  end
+end
+`
+
+// restorePathCode restores the path saved in OriginalPathHeader.
+const restorePathCode = `
+function envoy_on_request(handle)
+  local headers = handle:headers()
+  local path = headers:get("%[1]s")
+  if path ~= nil then
+    headers:replace(":path", path)
+    headers:remove("%[1]s")
+  end
+end
+`
+
+// NewQueryParametersFilters creates the filters keeping at most maxQueryParameters
+// query parameters in the request path while the route and the external authorization
+// are evaluated. The limit filter has to be placed before the filters that shouldn't
+// see the query parameters, the restore filter after them and before the router, to
+// send the original path upstream.
+//
+// Envoy selects and caches the route, at the latest when the restore filter looks
+// up its per-route configuration, before the path is restored. Restoring the path
+// thus doesn't change the selected route.
+func NewQueryParametersFilters(maxQueryParameters int) (limit *hcm.HttpFilter, restore *hcm.HttpFilter) {
+	return newLuaFilter(limitQueryParametersFilterName, fmt.Sprintf(limitQueryParametersCode, OriginalPathHeader, maxQueryParameters)),
+		newLuaFilter(restorePathFilterName, fmt.Sprintf(restorePathCode, OriginalPathHeader))
+}
+
+func newLuaFilter(name string, code string) *hcm.HttpFilter {
+	filter, _ := anypb.New(&lua.Lua{
+		InlineCode: code,
+	})
+
+	return &hcm.HttpFilter{
+		Name:       name,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewQueryParametersFilters(t *testing.T) {
+	limit, restore := NewQueryParametersFilters(2)
+
+	assert.Equal(t, limit.Name, limitQueryParametersFilterName)
+	limitCode := &lua.Lua{}
+	err := anypb.UnmarshalTo(limit.GetTypedConfig(), limitCode, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(limitCode.InlineCode, "if #kept >= 2 then"))
+	assert.Assert(t, strings.Contains(limitCode.InlineCode, `headers:add("x-kourier-original-path", path)`))
+
+	assert.Equal(t, restore.Name, restorePathFilterName)
+	restoreCode := &lua.Lua{}
+	err = anypb.UnmarshalTo(restore.GetTypedConfig(), restoreCode, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(restoreCode.InlineCode, `headers:get("x-kourier-original-path")`))
+}
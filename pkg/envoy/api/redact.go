@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// redactedSecret replaces the secrets redacted from the resources.
+const redactedSecret = "[redacted]"
+
+// RedactSecrets returns a copy of the given resource whose TLS private keys, their
+// passwords and the TLS session ticket keys are redacted, so that it can be dumped. The
+// typed configs, e.g. the TLS contexts of the transport sockets, are redacted as well.
+func RedactSecrets(resource proto.Message) (proto.Message, error) {
+	redacted := proto.Clone(resource)
+	if err := redactMessage(redacted.ProtoReflect()); err != nil {
+		return nil, err
+	}
+	return redacted, nil
+}
+
+// redactMessage redacts the secrets of the given message and of the messages it holds.
+func redactMessage(m protoreflect.Message) error {
+	switch message := m.Interface().(type) {
+	case *auth.TlsCertificate:
+		message.PrivateKey = redactDataSource(message.PrivateKey)
+		message.Password = redactDataSource(message.Password)
+	case *auth.TlsSessionTicketKeys:
+		for i, key := range message.Keys {
+			message.Keys[i] = redactDataSource(key)
+		}
+	case *anypb.Any:
+		inner, err := message.UnmarshalNew()
+		if err != nil {
+			return err
+		}
+		if err := redactMessage(inner.ProtoReflect()); err != nil {
+			return err
+		}
+		return message.MarshalFrom(inner)
+	}
+
+	var err error
+	m.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = redactMessage(list.Get(i).Message())
+			}
+		case field.IsMap() && field.MapValue().Message() != nil:
+			value.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				err = redactMessage(value.Message())
+				return err == nil
+			})
+		case !field.IsList() && !field.IsMap() && field.Message() != nil:
+			err = redactMessage(value.Message())
+		}
+		return err == nil
+	})
+	return err
+}
+
+// redactDataSource returns the redacted version of the given data source, or nil if
+// it's unset.
+func redactDataSource(source *core.DataSource) *core.DataSource {
+	if source == nil {
+		return nil
+	}
+	return &core.DataSource{Specifier: &core.DataSource_InlineString{InlineString: redactedSecret}}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	manager := NewHTTPConnectionManager("test", &config.Kourier{})
+	filterChain, err := CreateFilterChainFromCertificateAndPrivateKey(manager, []byte("cert-chain"), []byte("private-key"))
+	assert.NilError(t, err)
+	l, err := NewHTTPSListener(8443, []*listener.FilterChain{filterChain}, false)
+	assert.NilError(t, err)
+
+	redacted, err := RedactSecrets(l)
+	assert.NilError(t, err)
+
+	dump, err := protojson.Marshal(redacted)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(dump), base64.StdEncoding.EncodeToString([]byte("private-key"))))
+	assert.Assert(t, strings.Contains(string(dump), base64.StdEncoding.EncodeToString([]byte("cert-chain"))))
+
+	tlsContext := &auth.DownstreamTlsContext{}
+	assert.NilError(t, redacted.(*listener.Listener).FilterChains[0].TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+	assert.Equal(t, tlsContext.CommonTlsContext.TlsCertificates[0].PrivateKey.GetInlineString(), redactedSecret)
+
+	// The resource itself keeps its key.
+	assert.NilError(t, l.FilterChains[0].TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+	assert.DeepEqual(t, tlsContext.CommonTlsContext.TlsCertificates[0].PrivateKey.GetInlineBytes(), []byte("private-key"))
+}
@@ -19,8 +19,10 @@ package envoy
 import (
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	extAuthService "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/golang/protobuf/ptypes/any"
 	"google.golang.org/protobuf/types/known/anypb"
@@ -71,6 +73,52 @@ func NewRoute(name string,
 	}
 }
 
+// SetAutoHostRewrite rewrites the host of the requests routed by the Route to the DNS
+// name of the upstream host they're sent to. It only applies to the clusters resolving
+// DNS names, other hosts are sent as they are.
+func SetAutoHostRewrite(r *route.Route) {
+	if action := r.GetRoute(); action != nil {
+		action.HostRewriteSpecifier = &route.RouteAction_AutoHostRewrite{
+			AutoHostRewrite: wrapperspb.Bool(true),
+		}
+	}
+}
+
+// SetHostRewriteHeader rewrites the host of the requests routed by the Route to the
+// value of the given header. The requests without the header are sent as they are.
+func SetHostRewriteHeader(r *route.Route, header string) {
+	if action := r.GetRoute(); action != nil {
+		action.HostRewriteSpecifier = &route.RouteAction_HostRewriteHeader{
+			HostRewriteHeader: header,
+		}
+	}
+}
+
+// SetPrefixRewrite rewrites the matched prefix of the path of the requests routed by the
+// Route to the given prefix.
+func SetPrefixRewrite(r *route.Route, prefix string) {
+	if action := r.GetRoute(); action != nil {
+		action.RegexRewrite = nil
+		action.PrefixRewrite = prefix
+	}
+}
+
+// SetRegexRewrite rewrites the path of the requests routed by the Route, substituting
+// the matches of the given RE2 pattern. The substitution may refer to the capture
+// groups of the pattern as \1, \2 and so on.
+func SetRegexRewrite(r *route.Route, pattern, substitution string) {
+	if action := r.GetRoute(); action != nil {
+		action.PrefixRewrite = ""
+		action.RegexRewrite = &envoymatcherv3.RegexMatchAndSubstitute{
+			Pattern: &envoymatcherv3.RegexMatcher{
+				EngineType: &envoymatcherv3.RegexMatcher_GoogleRe2{GoogleRe2: &envoymatcherv3.RegexMatcher_GoogleRE2{}},
+				Regex:      pattern,
+			},
+			Substitution: substitution,
+		}
+	}
+}
+
 func NewRedirectRoute(name string,
 	headersMatch []*route.HeaderMatcher,
 	path string,
@@ -93,6 +141,125 @@ func NewRedirectRoute(name string,
 	}
 }
 
+// Redirect is how the requests are redirected. The parts of the requests whose field is
+// unset are kept as they are.
+type Redirect struct {
+	// ResponseCode defaults to 301 Moved Permanently.
+	ResponseCode route.RedirectAction_RedirectResponseCode
+	Scheme       string
+	Host         string
+	Port         uint32
+	// Path replaces the whole path of the requests, and Prefix the prefix matched by the
+	// route. Only one of them can be set.
+	Path       string
+	Prefix     string
+	StripQuery bool
+}
+
+// NewCustomRedirectRoute creates a route redirecting the requests matching the given
+// path prefix and headers as specified by the given redirect.
+func NewCustomRedirectRoute(name string,
+	headersMatch []*route.HeaderMatcher,
+	path string,
+	redirect *Redirect,
+) *route.Route {
+	action := &route.RedirectAction{
+		HostRedirect: redirect.Host,
+		PortRedirect: redirect.Port,
+		ResponseCode: redirect.ResponseCode,
+		StripQuery:   redirect.StripQuery,
+	}
+	if redirect.Scheme != "" {
+		action.SchemeRewriteSpecifier = &route.RedirectAction_SchemeRedirect{
+			SchemeRedirect: redirect.Scheme,
+		}
+	}
+	if redirect.Path != "" {
+		action.PathRewriteSpecifier = &route.RedirectAction_PathRedirect{
+			PathRedirect: redirect.Path,
+		}
+	} else if redirect.Prefix != "" {
+		action.PathRewriteSpecifier = &route.RedirectAction_PrefixRewrite{
+			PrefixRewrite: redirect.Prefix,
+		}
+	}
+
+	return &route.Route{
+		Name: name,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{
+				Prefix: path,
+			},
+			Headers: headersMatch,
+		},
+		Action: &route.Route_Redirect{
+			Redirect: action,
+		},
+	}
+}
+
+// NewDirectResponseRoute creates a route responding to the requests matching the given
+// path prefix and headers itself, with the given status and body, rather than routing
+// them upstream. The content type of the body is set unless empty.
+func NewDirectResponseRoute(name string,
+	headersMatch []*route.HeaderMatcher,
+	path string,
+	status uint32,
+	body string,
+	contentType string,
+) *route.Route {
+	action := &route.DirectResponseAction{Status: status}
+	if body != "" {
+		action.Body = &core.DataSource{
+			Specifier: &core.DataSource_InlineString{InlineString: body},
+		}
+	}
+
+	r := &route.Route{
+		Name: name,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{
+				Prefix: path,
+			},
+			Headers: headersMatch,
+		},
+		Action: &route.Route_DirectResponse{
+			DirectResponse: action,
+		},
+	}
+	if contentType != "" {
+		r.ResponseHeadersToAdd = headersToAdd(map[string]string{"content-type": contentType})
+	}
+	return r
+}
+
+// NewHostRedirectRoute creates a route permanently redirecting all requests to the given
+// host, keeping their path. The requests are redirected to HTTPS as well, if httpsRedirect
+// is set.
+func NewHostRedirectRoute(name string, host string, httpsRedirect bool) *route.Route {
+	redirect := &route.RedirectAction{
+		HostRedirect: host,
+		ResponseCode: route.RedirectAction_MOVED_PERMANENTLY,
+	}
+	if httpsRedirect {
+		redirect.SchemeRewriteSpecifier = &route.RedirectAction_HttpsRedirect{
+			HttpsRedirect: true,
+		}
+	}
+
+	return &route.Route{
+		Name: name,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{
+				Prefix: "/",
+			},
+		},
+		Action: &route.Route_Redirect{
+			Redirect: redirect,
+		},
+	}
+}
+
 func NewRouteExtAuthzDisabled(name string,
 	headersMatch []*route.HeaderMatcher,
 	path string,
@@ -48,6 +48,93 @@ func TestNewRouteHostRewrite(t *testing.T) {
 	assert.Equal(t, r.Action.(*route.Route_Route).Route.GetHostRewriteLiteral(), "test.host")
 }
 
+func TestSetHostRewrite(t *testing.T) {
+	r := NewRoute("testRoute_12345", nil, "/my_route", nil, 0, nil, "test.host")
+	SetAutoHostRewrite(r)
+	assert.Assert(t, r.GetRoute().GetAutoHostRewrite().GetValue())
+	assert.Equal(t, r.GetRoute().GetHostRewriteLiteral(), "")
+
+	r = NewRoute("testRoute_12345", nil, "/my_route", nil, 0, nil, "test.host")
+	SetHostRewriteHeader(r, "x-backend-host")
+	assert.Equal(t, r.GetRoute().GetHostRewriteHeader(), "x-backend-host")
+
+	// Redirects are left as they are.
+	redirect := NewHostRedirectRoute("redirect", "www.example.com", false)
+	SetAutoHostRewrite(redirect)
+	assert.Assert(t, redirect.GetRoute() == nil)
+}
+
+func TestSetPathRewrite(t *testing.T) {
+	r := NewRoute("testRoute_12345", nil, "/api/v1/", nil, 0, nil, "")
+	SetPrefixRewrite(r, "/")
+	assert.Equal(t, r.GetRoute().PrefixRewrite, "/")
+
+	// The regex rewrite replaces the prefix rewrite.
+	SetRegexRewrite(r, "^/api/v1/(.*)$", "/\\1")
+	assert.Equal(t, r.GetRoute().PrefixRewrite, "")
+	assert.Equal(t, r.GetRoute().RegexRewrite.Pattern.Regex, "^/api/v1/(.*)$")
+	assert.Assert(t, r.GetRoute().RegexRewrite.Pattern.GetGoogleRe2() != nil)
+	assert.Equal(t, r.GetRoute().RegexRewrite.Substitution, "/\\1")
+
+	// Redirects are left as they are.
+	redirect := NewHostRedirectRoute("redirect", "www.example.com", false)
+	SetPrefixRewrite(redirect, "/")
+	assert.Assert(t, redirect.GetRoute() == nil)
+}
+
+func TestNewCustomRedirectRoute(t *testing.T) {
+	r := NewCustomRedirectRoute("redirect", nil, "/old/", &Redirect{
+		ResponseCode: route.RedirectAction_PERMANENT_REDIRECT,
+		Scheme:       "https",
+		Host:         "www.example.com",
+		Port:         8443,
+		Prefix:       "/new/",
+		StripQuery:   true,
+	})
+	assert.Equal(t, r.Match.GetPrefix(), "/old/")
+	redirect := r.GetRedirect()
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_PERMANENT_REDIRECT)
+	assert.Equal(t, redirect.GetSchemeRedirect(), "https")
+	assert.Equal(t, redirect.HostRedirect, "www.example.com")
+	assert.Equal(t, redirect.PortRedirect, uint32(8443))
+	assert.Equal(t, redirect.GetPrefixRewrite(), "/new/")
+	assert.Assert(t, redirect.StripQuery)
+
+	// The unset parts of the requests are kept.
+	redirect = NewCustomRedirectRoute("redirect", nil, "/", &Redirect{Path: "/moved"}).GetRedirect()
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_MOVED_PERMANENTLY)
+	assert.Assert(t, redirect.SchemeRewriteSpecifier == nil)
+	assert.Equal(t, redirect.HostRedirect, "")
+	assert.Equal(t, redirect.GetPathRedirect(), "/moved")
+	assert.Assert(t, !redirect.StripQuery)
+}
+
+func TestNewDirectResponseRoute(t *testing.T) {
+	r := NewDirectResponseRoute("maintenance", nil, "/api", 503, "under maintenance", "text/plain")
+	assert.Equal(t, r.Match.GetPrefix(), "/api")
+	assert.Equal(t, r.GetDirectResponse().Status, uint32(503))
+	assert.Equal(t, r.GetDirectResponse().Body.GetInlineString(), "under maintenance")
+	assert.Equal(t, len(r.ResponseHeadersToAdd), 1)
+	assert.Equal(t, r.ResponseHeadersToAdd[0].Header.Key, "content-type")
+	assert.Equal(t, r.ResponseHeadersToAdd[0].Header.Value, "text/plain")
+
+	r = NewDirectResponseRoute("maintenance", nil, "/", 503, "", "")
+	assert.Assert(t, r.GetDirectResponse().Body == nil)
+	assert.Assert(t, r.ResponseHeadersToAdd == nil)
+}
+
+func TestNewHostRedirectRoute(t *testing.T) {
+	r := NewHostRedirectRoute("redirect", "www.example.com", false)
+	assert.Equal(t, r.Match.GetPrefix(), "/")
+	redirect := r.GetRedirect()
+	assert.Equal(t, redirect.HostRedirect, "www.example.com")
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_MOVED_PERMANENTLY)
+	assert.Assert(t, !redirect.GetHttpsRedirect())
+
+	r = NewHostRedirectRoute("redirect", "www.example.com", true)
+	assert.Assert(t, r.GetRedirect().GetHttpsRedirect())
+}
+
 func TestNewRouteExtAuthzDisabled(t *testing.T) {
 	name := "testRoute_HTTP01_challenge"
 	path := "/.well-known/acme-challenge/-VwB1vAXWaN6mVl3-6JVFTEvf7acguaFDUxsP9UzRkE"
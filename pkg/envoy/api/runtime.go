@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"strconv"
+
+	runtime "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NewRuntime creates the runtime layer with the given name, holding the given values
+// keyed by their runtime key. The values parsing as booleans or numbers are passed as
+// such, so that Envoy reads them as feature flags or numeric knobs.
+func NewRuntime(name string, values map[string]string) *runtime.Runtime {
+	fields := make(map[string]*structpb.Value, len(values))
+	for key, value := range values {
+		fields[key] = runtimeValue(value)
+	}
+	return &runtime.Runtime{
+		Name:  name,
+		Layer: &structpb.Struct{Fields: fields},
+	}
+}
+
+// runtimeValue returns the typed value of the given runtime value.
+func runtimeValue(value string) *structpb.Value {
+	if value == "true" || value == "false" {
+		return structpb.NewBoolValue(value == "true")
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return structpb.NewNumberValue(f)
+	}
+	return structpb.NewStringValue(value)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewRuntime(t *testing.T) {
+	got := NewRuntime("layer", map[string]string{
+		"envoy.reloadable_features.example":          "false",
+		"overload.global_downstream_max_connections": "50000",
+		"example.fraction":                           "0.5",
+		"example.string":                             "value",
+		"example.flag":                               "1",
+	})
+
+	assert.Equal(t, got.Name, "layer")
+	assert.DeepEqual(t, got.Layer, &structpb.Struct{Fields: map[string]*structpb.Value{
+		"envoy.reloadable_features.example":          structpb.NewBoolValue(false),
+		"overload.global_downstream_max_connections": structpb.NewNumberValue(50000),
+		"example.fraction":                           structpb.NewNumberValue(0.5),
+		"example.string":                             structpb.NewStringValue("value"),
+		// Only true and false are booleans.
+		"example.flag": structpb.NewNumberValue(1),
+	}}, protocmp.Transform())
+
+	// The layer is served empty without values.
+	assert.Equal(t, len(NewRuntime("layer", nil).Layer.Fields), 0)
+}
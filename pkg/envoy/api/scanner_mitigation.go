@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoytypev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	denyScannersFilterName = "kourier.deny_scanners"
+	localRateLimitFilter   = "envoy.filters.http.local_ratelimit"
+)
+
+// denyScannersCode rejects the requests whose path starts with one of the given
+// prefixes or whose user agent contains one of the given substrings, both compared
+// case-insensitively, as not found. The lists are Lua table constructors.
+const denyScannersCode = `
+local deny_paths = %s
+local deny_user_agents = %s
+
+local function deny(handle)
+  handle:respond({[":status"] = "404"}, "")
+end
+
+function envoy_on_request(handle)
+  local headers = handle:headers()
+
+  local path = string.lower(headers:get(":path") or "")
+  for _, prefix in ipairs(deny_paths) do
+    if string.sub(path, 1, #prefix) == prefix then
+      return deny(handle)
+    end
+  end
+
+  local user_agent = string.lower(headers:get("user-agent") or "")
+  for _, substring in ipairs(deny_user_agents) do
+    if string.find(user_agent, substring, 1, true) then
+      return deny(handle)
+    end
+  end
+end
+`
+
+// NewDenyScannersFilter creates the filter rejecting the requests of scanners: those
+// whose path starts with one of denyPaths or whose user agent contains one of
+// denyUserAgents, both compared case-insensitively.
+func NewDenyScannersFilter(denyPaths []string, denyUserAgents []string) *hcm.HttpFilter {
+	return newLuaFilter(denyScannersFilterName,
+		fmt.Sprintf(denyScannersCode, luaStringTable(denyPaths), luaStringTable(denyUserAgents)))
+}
+
+// NewConnectionRateLimitFilter creates the filter limiting the requests of every
+// downstream connection to requestsPerSecond. The requests exceeding the limit are
+// rejected with 429.
+func NewConnectionRateLimitFilter(requestsPerSecond uint32) *hcm.HttpFilter {
+	enabled := &core.RuntimeFractionalPercent{
+		DefaultValue: &envoytypev3.FractionalPercent{
+			Numerator:   100,
+			Denominator: envoytypev3.FractionalPercent_HUNDRED,
+		},
+	}
+	filter, _ := anypb.New(&ratelimit.LocalRateLimit{
+		StatPrefix: "connection_rate_limit",
+		TokenBucket: &envoytypev3.TokenBucket{
+			MaxTokens:     requestsPerSecond,
+			TokensPerFill: wrapperspb.UInt32(requestsPerSecond),
+			FillInterval:  durationpb.New(time.Second),
+		},
+		FilterEnabled:                         enabled,
+		FilterEnforced:                        enabled,
+		LocalRateLimitPerDownstreamConnection: true,
+	})
+
+	return &hcm.HttpFilter{
+		Name:       localRateLimitFilter,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// luaStringTable returns a Lua table constructor of the given strings, in lower case.
+// All bytes but printable ASCII are written as decimal escapes, which Lua understands.
+func luaStringTable(values []string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('"')
+		for _, c := range []byte(strings.ToLower(v)) {
+			if c < ' ' || c > '~' || c == '"' || c == '\\' {
+				fmt.Fprintf(&b, "\\%03d", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewDenyScannersFilter(t *testing.T) {
+	filter := NewDenyScannersFilter([]string{"/.env", "/WP-Admin"}, []string{"sqlmap"})
+
+	assert.Equal(t, filter.Name, denyScannersFilterName)
+	code := &lua.Lua{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), code, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(code.InlineCode, `local deny_paths = {"/.env", "/wp-admin"}`))
+	assert.Assert(t, strings.Contains(code.InlineCode, `local deny_user_agents = {"sqlmap"}`))
+}
+
+func TestNewConnectionRateLimitFilter(t *testing.T) {
+	filter := NewConnectionRateLimitFilter(10)
+
+	assert.Equal(t, filter.Name, localRateLimitFilter)
+	config := &ratelimit.LocalRateLimit{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), config, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, config.LocalRateLimitPerDownstreamConnection)
+	assert.Equal(t, config.TokenBucket.MaxTokens, uint32(10))
+	assert.Equal(t, config.TokenBucket.TokensPerFill.GetValue(), uint32(10))
+	assert.Equal(t, config.TokenBucket.FillInterval.AsDuration(), time.Second)
+
+	// The filter is disabled unless explicitly enabled and enforced.
+	assert.Equal(t, config.FilterEnabled.DefaultValue.Numerator, uint32(100))
+	assert.Equal(t, config.FilterEnforced.DefaultValue.Numerator, uint32(100))
+}
+
+func TestLuaStringTable(t *testing.T) {
+	assert.Equal(t, luaStringTable(nil), "{}")
+	assert.Equal(t, luaStringTable([]string{"a", "B"}), `{"a", "b"}`)
+	assert.Equal(t, luaStringTable([]string{`"\`, "é\n"}), `{"\034\092", "\195\169\010"}`)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// SecretName returns the name of the SDS secret that carries the certificate of
+// the given source.
+func SecretName(source types.NamespacedName) string {
+	return source.Namespace + "/" + source.Name
+}
+
+// NewSecret creates a new SDS Secret with the given certificate chain and private key.
+func NewSecret(name string, certificateChain []byte, privateKey []byte) *auth.Secret {
+	return &auth.Secret{
+		Name: name,
+		Type: &auth.Secret_TlsCertificate{
+			TlsCertificate: newTLSCertificate(certificateChain, privateKey),
+		},
+	}
+}
+
+func newTLSCertificate(certificateChain []byte, privateKey []byte) *auth.TlsCertificate {
+	return &auth.TlsCertificate{
+		CertificateChain: &core.DataSource{
+			Specifier: &core.DataSource_InlineBytes{InlineBytes: certificateChain},
+		},
+		PrivateKey: &core.DataSource{
+			Specifier: &core.DataSource_InlineBytes{InlineBytes: privateKey},
+		},
+	}
+}
+
+// SetClientCertificate makes the upstream TLS context present the certificate of the SDS
+// secret with the given name.
+func SetClientCertificate(tlsContext *auth.UpstreamTlsContext, secretName string) {
+	tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*auth.SdsSecretConfig{
+		sdsSecretConfig(secretName),
+	}
+}
+
+// sdsSecretConfig references the secret with the given name, delivered over ADS.
+func sdsSecretConfig(name string) *auth.SdsSecretConfig {
+	return &auth.SdsSecretConfig{
+		Name: name,
+		SdsConfig: &core.ConfigSource{
+			ResourceApiVersion: resource.DefaultAPIVersion,
+			ConfigSourceSpecifier: &core.ConfigSource_Ads{
+				Ads: &core.AggregatedConfigSource{},
+			},
+		},
+	}
+}
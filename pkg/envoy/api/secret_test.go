@@ -0,0 +1,34 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNewSecret(t *testing.T) {
+	name := SecretName(types.NamespacedName{Namespace: "secretns", Name: "secretname"})
+	assert.Equal(t, name, "secretns/secretname")
+
+	secret := NewSecret(name, []byte("cert"), []byte("key"))
+	assert.Equal(t, secret.Name, name)
+	assert.DeepEqual(t, secret.GetTlsCertificate().CertificateChain.GetInlineBytes(), []byte("cert"))
+	assert.DeepEqual(t, secret.GetTlsCertificate().PrivateKey.GetInlineBytes(), []byte("key"))
+}
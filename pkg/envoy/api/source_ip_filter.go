@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	rbacconfig "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	sourceIPFilterName = "kourier.source_ip_filter"
+
+	// sourceIPPolicyName is the name of the policy allowing the requests from the
+	// allowed sources of a VirtualHost.
+	sourceIPPolicyName = "allowed-sources"
+)
+
+// SourceIPFilter specifies the sources of the requests a VirtualHost allows.
+type SourceIPFilter struct {
+	// Allowed are the CIDRs the requests are allowed from. The requests from all
+	// sources are allowed if empty.
+	Allowed []string
+	// Denied are the CIDRs the requests are denied from, even if allowed otherwise.
+	Denied []string
+	// Forwarded matches the address of the client in the X-Forwarded-For header, past
+	// the trusted hops, rather than the address of the peer of the connection.
+	Forwarded bool
+}
+
+// NewSourceIPFilter creates the filter restricting the sources of the requests. It
+// doesn't restrict any on its own, but only the ones of the virtual hosts configured
+// with SetSourceIPFilter.
+func NewSourceIPFilter() *hcm.HttpFilter {
+	filter, _ := anypb.New(&rbac.RBAC{})
+	return &hcm.HttpFilter{
+		Name:       sourceIPFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// SetSourceIPFilter makes the VirtualHost deny the requests from the sources the given
+// filter doesn't allow, with a 403.
+func SetSourceIPFilter(vh *route.VirtualHost, filter *SourceIPFilter) error {
+	var principals []*rbacconfig.Principal
+	if len(filter.Allowed) != 0 {
+		allowed, err := sourceIPPrincipal(filter.Allowed, filter.Forwarded)
+		if err != nil {
+			return err
+		}
+		principals = append(principals, allowed)
+	}
+	if len(filter.Denied) != 0 {
+		denied, err := sourceIPPrincipal(filter.Denied, filter.Forwarded)
+		if err != nil {
+			return err
+		}
+		principals = append(principals, &rbacconfig.Principal{
+			Identifier: &rbacconfig.Principal_NotId{NotId: denied},
+		})
+	}
+	if len(principals) == 0 {
+		return nil
+	}
+
+	perRoute, err := anypb.New(&rbac.RBACPerRoute{
+		Rbac: &rbac.RBAC{
+			Rules: &rbacconfig.RBAC{
+				Action: rbacconfig.RBAC_ALLOW,
+				Policies: map[string]*rbacconfig.Policy{
+					sourceIPPolicyName: {
+						Permissions: []*rbacconfig.Permission{{
+							Rule: &rbacconfig.Permission_Any{Any: true},
+						}},
+						Principals: []*rbacconfig.Principal{{
+							Identifier: &rbacconfig.Principal_AndIds{
+								AndIds: &rbacconfig.Principal_Set{Ids: principals},
+							},
+						}},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if vh.TypedPerFilterConfig == nil {
+		vh.TypedPerFilterConfig = make(map[string]*anypb.Any, 1)
+	}
+	vh.TypedPerFilterConfig[sourceIPFilterName] = perRoute
+	return nil
+}
+
+// sourceIPPrincipal returns the principal matching the requests from any of the given
+// CIDRs, either by the address of the peer of the connection or the forwarded one.
+func sourceIPPrincipal(cidrs []string, forwarded bool) (*rbacconfig.Principal, error) {
+	ids := make([]*rbacconfig.Principal, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidrRange, err := newCidrRange(cidr)
+		if err != nil {
+			return nil, err
+		}
+		if forwarded {
+			ids = append(ids, &rbacconfig.Principal{
+				Identifier: &rbacconfig.Principal_RemoteIp{RemoteIp: cidrRange},
+			})
+		} else {
+			ids = append(ids, &rbacconfig.Principal{
+				Identifier: &rbacconfig.Principal_DirectRemoteIp{DirectRemoteIp: cidrRange},
+			})
+		}
+	}
+	return &rbacconfig.Principal{
+		Identifier: &rbacconfig.Principal_OrIds{OrIds: &rbacconfig.Principal_Set{Ids: ids}},
+	}, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	rbacconfig "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewSourceIPFilter(t *testing.T) {
+	filter := NewSourceIPFilter()
+
+	assert.Equal(t, filter.Name, sourceIPFilterName)
+	rbacConfig := &rbac.RBAC{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), rbacConfig, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, rbacConfig.Validate())
+	// No requests are denied without the configs of the virtual hosts.
+	assert.Assert(t, rbacConfig.Rules == nil)
+}
+
+func TestSetSourceIPFilter(t *testing.T) {
+	newVirtualHost := func() *route.VirtualHost {
+		return NewVirtualHostWithExtAuthz("test", nil, []string{"foo"}, []*route.Route{
+			NewRoute("route", nil, "/", nil, 0, nil, ""),
+		})
+	}
+	policy := func(vh *route.VirtualHost) *rbacconfig.Policy {
+		perRoute := &rbac.RBACPerRoute{}
+		err := anypb.UnmarshalTo(vh.TypedPerFilterConfig[sourceIPFilterName], perRoute, proto.UnmarshalOptions{})
+		assert.NilError(t, err)
+		assert.NilError(t, perRoute.Validate())
+		assert.Equal(t, perRoute.Rbac.Rules.Action, rbacconfig.RBAC_ALLOW)
+		return perRoute.Rbac.Rules.Policies[sourceIPPolicyName]
+	}
+
+	vh := newVirtualHost()
+	assert.NilError(t, SetSourceIPFilter(vh, &SourceIPFilter{
+		Allowed: []string{"10.0.0.0/8", "192.168.1.1/32"},
+		Denied:  []string{"10.0.1.0/24"},
+	}))
+
+	// The other filters' configs are kept.
+	assert.Assert(t, vh.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+
+	// The requests are allowed from the allowed CIDRs, except the denied ones.
+	ids := policy(vh).Principals[0].GetAndIds().Ids
+	assert.Equal(t, len(ids), 2)
+	allowed := ids[0].GetOrIds().Ids
+	assert.Equal(t, len(allowed), 2)
+	assert.Equal(t, allowed[0].GetDirectRemoteIp().AddressPrefix, "10.0.0.0")
+	assert.Equal(t, allowed[0].GetDirectRemoteIp().PrefixLen.GetValue(), uint32(8))
+	assert.Equal(t, allowed[1].GetDirectRemoteIp().AddressPrefix, "192.168.1.1")
+	denied := ids[1].GetNotId().GetOrIds().Ids
+	assert.Equal(t, len(denied), 1)
+	assert.Equal(t, denied[0].GetDirectRemoteIp().AddressPrefix, "10.0.1.0")
+
+	// The forwarded addresses are matched instead of the peers' ones.
+	vh = newVirtualHost()
+	assert.NilError(t, SetSourceIPFilter(vh, &SourceIPFilter{
+		Denied:    []string{"2001:db8::/32"},
+		Forwarded: true,
+	}))
+	ids = policy(vh).Principals[0].GetAndIds().Ids
+	assert.Equal(t, len(ids), 1)
+	assert.Equal(t, ids[0].GetNotId().GetOrIds().Ids[0].GetRemoteIp().AddressPrefix, "2001:db8::")
+
+	// Invalid CIDRs are rejected.
+	assert.Assert(t, SetSourceIPFilter(newVirtualHost(), &SourceIPFilter{Allowed: []string{"10.0.0.0"}}) != nil)
+}
+
+func TestNewHTTPConnectionManagerWithSourceIPFilters(t *testing.T) {
+	connManager := NewHTTPConnectionManager("test", &config.Kourier{
+		SourceIPFilters:      true,
+		VirtualHostDiscovery: true,
+	})
+
+	// The sources are filtered once the virtual hosts are discovered.
+	assert.Equal(t, len(connManager.HttpFilters), 4)
+	assert.Equal(t, connManager.HttpFilters[0].Name, stickyCanaryFilterName)
+	assert.Equal(t, connManager.HttpFilters[1].Name, onDemandFilterName)
+	assert.Equal(t, connManager.HttpFilters[2].Name, sourceIPFilterName)
+	assert.Equal(t, connManager.HttpFilters[3].Name, wellknown.Router)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+const (
+	// SPIFFECertificateSecretName is the name of the SDS secret the SPIFFE Workload API
+	// serves the workload's own X.509 SVID under.
+	SPIFFECertificateSecretName = "default"
+
+	// SPIFFETrustBundleSecretName is the name of the SDS secret the SPIFFE Workload API
+	// serves the trust bundle of the trust domain under.
+	SPIFFETrustBundleSecretName = "ROOTCA"
+)
+
+// NewSPIFFEUpstreamTLSContext creates an upstream TLS context that obtains its client
+// certificate and trust bundle via SDS from the SPIFFE Workload API at the given unix
+// socket. The upstream's certificate has to match one of the sanPatterns, where a
+// trailing "*" matches any suffix. All SVIDs of the trust domain are accepted if empty.
+func NewSPIFFEUpstreamTLSContext(socketPath string, sanPatterns []string, alpnProtocols ...string) *auth.UpstreamTlsContext {
+	sdsConfig := spiffeSDSConfig(socketPath)
+
+	sans := make([]*envoymatcherv3.StringMatcher, 0, len(sanPatterns))
+	for _, pattern := range sanPatterns {
+		sans = append(sans, sanMatcher(pattern))
+	}
+
+	return &auth.UpstreamTlsContext{
+		CommonTlsContext: &auth.CommonTlsContext{
+			AlpnProtocols: alpnProtocols,
+			TlsParams: &auth.TlsParameters{
+				TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+			},
+			TlsCertificateSdsSecretConfigs: []*auth.SdsSecretConfig{{
+				Name:      SPIFFECertificateSecretName,
+				SdsConfig: sdsConfig,
+			}},
+			ValidationContextType: &auth.CommonTlsContext_CombinedValidationContext{
+				CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
+					DefaultValidationContext: &auth.CertificateValidationContext{
+						MatchSubjectAltNames: sans,
+					},
+					ValidationContextSdsSecretConfig: &auth.SdsSecretConfig{
+						Name:      SPIFFETrustBundleSecretName,
+						SdsConfig: sdsConfig,
+					},
+				},
+			},
+		},
+	}
+}
+
+// spiffeSDSConfig references the SDS API of the SPIFFE Workload API at the given unix
+// socket. A Google gRPC client is used so that no cluster has to be defined for it.
+func spiffeSDSConfig(socketPath string) *core.ConfigSource {
+	return &core.ConfigSource{
+		ResourceApiVersion: resource.DefaultAPIVersion,
+		ConfigSourceSpecifier: &core.ConfigSource_ApiConfigSource{
+			ApiConfigSource: &core.ApiConfigSource{
+				ApiType:             core.ApiConfigSource_GRPC,
+				TransportApiVersion: resource.DefaultAPIVersion,
+				GrpcServices: []*core.GrpcService{{
+					TargetSpecifier: &core.GrpcService_GoogleGrpc_{
+						GoogleGrpc: &core.GrpcService_GoogleGrpc{
+							TargetUri:  "unix:" + socketPath,
+							StatPrefix: "spiffe_workload_api",
+						},
+					},
+				}},
+			},
+		},
+	}
+}
+
+func sanMatcher(pattern string) *envoymatcherv3.StringMatcher {
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Prefix{Prefix: prefix},
+		}
+	}
+	return &envoymatcherv3.StringMatcher{
+		MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: pattern},
+	}
+}
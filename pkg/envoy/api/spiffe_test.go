@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewSPIFFEUpstreamTLSContext(t *testing.T) {
+	tlsContext := NewSPIFFEUpstreamTLSContext("/run/spire/sockets/agent.sock",
+		[]string{"spiffe://example.org/ns/knative-serving/sa/activator", "spiffe://example.org/ns/*"}, "h2")
+
+	assert.DeepEqual(t, tlsContext.CommonTlsContext.AlpnProtocols, []string{"h2"})
+
+	certConfigs := tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs
+	assert.Equal(t, len(certConfigs), 1)
+	assert.Equal(t, certConfigs[0].Name, SPIFFECertificateSecretName)
+	googleGrpc := certConfigs[0].SdsConfig.GetApiConfigSource().GrpcServices[0].GetGoogleGrpc()
+	assert.Equal(t, googleGrpc.TargetUri, "unix:/run/spire/sockets/agent.sock")
+
+	validationContext := tlsContext.CommonTlsContext.GetCombinedValidationContext()
+	assert.Equal(t, validationContext.ValidationContextSdsSecretConfig.Name, SPIFFETrustBundleSecretName)
+	assert.DeepEqual(t, validationContext.DefaultValidationContext.MatchSubjectAltNames, []*envoymatcherv3.StringMatcher{{
+		MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: "spiffe://example.org/ns/knative-serving/sa/activator"},
+	}, {
+		MatchPattern: &envoymatcherv3.StringMatcher_Prefix{Prefix: "spiffe://example.org/ns/"},
+	}}, protocmp.Transform())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// StickyCanaryCookie is the cookie pinning a client to the split of a sticky
+	// canary route chosen for its first request.
+	StickyCanaryCookie = "kourier-canary"
+
+	// StickyCanaryHeader is the header the split of sticky canary routes is chosen by.
+	// It carries the value of StickyCanaryCookie.
+	StickyCanaryHeader = "x-kourier-canary"
+
+	stickyCanaryFilterName = "kourier.sticky_canary"
+)
+
+// stickyCanaryCode copies the value of StickyCanaryCookie to StickyCanaryHeader. A
+// client provided StickyCanaryHeader is always dropped, so the split can only be
+// pinned through the cookie issued by the gateway.
+const stickyCanaryCode = `
+function envoy_on_request(handle)
+  local headers = handle:headers()
+  headers:remove("%[1]s")
+
+  local cookies = headers:get("cookie")
+  if cookies == nil then
+    return
+  end
+
+  local value = string.match(";" .. cookies, ";%%s*%[2]s=(%%d+)")
+  if value ~= nil then
+    headers:add("%[1]s", value)
+  end
+end
+`
+
+// NewStickyCanaryFilter creates the filter passing the cookie of the sticky canary
+// routes on as the header their split is chosen by. It has to be placed before any
+// filter looking up the route, as Envoy chooses the split when selecting the route.
+func NewStickyCanaryFilter() *hcm.HttpFilter {
+	return newLuaFilter(stickyCanaryFilterName,
+		fmt.Sprintf(stickyCanaryCode, StickyCanaryHeader, luaPatternEscape(StickyCanaryCookie)))
+}
+
+// SetStickyCanary makes the route choose its split by StickyCanaryHeader and issue a
+// StickyCanaryCookie, valid for the given ttl, pinning the client to the chosen split.
+// Requests without the cookie are split by weight as usual.
+//
+// The cookie carries the offset of the split within the total weight, which Envoy
+// maps back to the same split as long as the weights don't change.
+func SetStickyCanary(r *route.Route, ttl time.Duration) {
+	weightedClusters := r.GetRoute().GetWeightedClusters()
+	if weightedClusters == nil {
+		return
+	}
+	weightedClusters.RandomValueSpecifier = &route.WeightedCluster_HeaderName{
+		HeaderName: StickyCanaryHeader,
+	}
+
+	offset := uint32(0)
+	for _, cluster := range weightedClusters.Clusters {
+		cluster.ResponseHeadersToAdd = []*core.HeaderValueOption{{
+			Header: &core.HeaderValue{
+				Key:   "set-cookie",
+				Value: fmt.Sprintf("%s=%d; Max-Age=%d; Path=/; HttpOnly", StickyCanaryCookie, offset, int64(ttl.Seconds())),
+			},
+			// Keep the cookies set by the upstream.
+			Append: wrapperspb.Bool(true),
+		}}
+		offset += cluster.GetWeight().GetValue()
+	}
+}
+
+// luaPatternEscape escapes the magic characters of Lua patterns in s.
+func luaPatternEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune("^$()%.[]*+-?", c) {
+			b.WriteByte('%')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewStickyCanaryFilter(t *testing.T) {
+	filter := NewStickyCanaryFilter()
+
+	assert.Equal(t, filter.Name, stickyCanaryFilterName)
+	code := &lua.Lua{}
+	err := anypb.UnmarshalTo(filter.GetTypedConfig(), code, proto.UnmarshalOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(code.InlineCode, `headers:remove("x-kourier-canary")`))
+	assert.Assert(t, strings.Contains(code.InlineCode, `";%s*kourier%-canary=(%d+)"`))
+}
+
+func TestSetStickyCanary(t *testing.T) {
+	r := NewRoute("test", nil, "/", []*route.WeightedCluster_ClusterWeight{
+		NewWeightedCluster("ns/v1", 90, nil),
+		NewWeightedCluster("ns/v2", 10, nil),
+	}, 0, nil, "")
+
+	SetStickyCanary(r, 10*time.Minute)
+
+	weightedClusters := r.GetRoute().GetWeightedClusters()
+	assert.Equal(t, weightedClusters.GetHeaderName(), StickyCanaryHeader)
+	cookies := make([]string, 0, len(weightedClusters.Clusters))
+	for _, cluster := range weightedClusters.Clusters {
+		assert.Equal(t, len(cluster.ResponseHeadersToAdd), 1)
+		assert.Equal(t, cluster.ResponseHeadersToAdd[0].Header.Key, "set-cookie")
+		assert.Assert(t, cluster.ResponseHeadersToAdd[0].Append.Value)
+		cookies = append(cookies, cluster.ResponseHeadersToAdd[0].Header.Value)
+	}
+	assert.DeepEqual(t, cookies, []string{
+		"kourier-canary=0; Max-Age=600; Path=/; HttpOnly",
+		"kourier-canary=90; Max-Age=600; Path=/; HttpOnly",
+	})
+
+	// Routes without splits are left alone.
+	redirect := NewRedirectRoute("test", nil, "/")
+	SetStickyCanary(redirect, 10*time.Minute)
+	assert.DeepEqual(t, redirect, NewRedirectRoute("test", nil, "/"), protocmp.Transform())
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// PassthroughMatch represents the hosts whose TLS connections are passed through to the
+// given clusters as they are, without terminating TLS.
+type PassthroughMatch struct {
+	// Name identifies the match in the stats of the TCP proxy.
+	Name  string
+	Hosts []string
+	// Clusters are the clusters the connections are split across by weight.
+	Clusters []*tcp.TcpProxy_WeightedCluster_ClusterWeight
+}
+
+// NewPassthroughFilterChain creates a filter chain matching the SNI of the hosts of the
+// given match, which proxies the connections to its clusters.
+func NewPassthroughFilterChain(match *PassthroughMatch) (*listener.FilterChain, error) {
+	tcpProxy := &tcp.TcpProxy{StatPrefix: match.Name}
+	if len(match.Clusters) == 1 {
+		tcpProxy.ClusterSpecifier = &tcp.TcpProxy_Cluster{Cluster: match.Clusters[0].Name}
+	} else {
+		tcpProxy.ClusterSpecifier = &tcp.TcpProxy_WeightedClusters{
+			WeightedClusters: &tcp.TcpProxy_WeightedCluster{Clusters: match.Clusters},
+		}
+	}
+
+	tcpProxyAny, err := anypb.New(tcpProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listener.FilterChain{
+		FilterChainMatch: &listener.FilterChainMatch{
+			ServerNames: match.Hosts,
+		},
+		Filters: []*listener.Filter{{
+			Name:       wellknown.TCPProxy,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: tcpProxyAny},
+		}},
+	}, nil
+}
+
+// AddPassthroughFilterChains adds a filter chain for each of the given matches to the
+// listener, in front of its other filter chains. The listener inspects the TLS
+// connections to detect their SNI if it doesn't already.
+func AddPassthroughFilterChains(l *listener.Listener, matches []*PassthroughMatch) error {
+	filterChains := make([]*listener.FilterChain, 0, len(matches)+len(l.FilterChains))
+	for _, match := range matches {
+		filterChain, err := NewPassthroughFilterChain(match)
+		if err != nil {
+			return err
+		}
+		filterChains = append(filterChains, filterChain)
+	}
+	l.FilterChains = append(filterChains, l.FilterChains...)
+
+	for _, filter := range l.ListenerFilters {
+		if filter.Name == wellknown.TlsInspector {
+			return nil
+		}
+	}
+	l.ListenerFilters = append(l.ListenerFilters, newTLSInspectorListenerFilter())
+	return nil
+}
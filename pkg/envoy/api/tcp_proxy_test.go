@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewPassthroughFilterChain(t *testing.T) {
+	tcpProxy := func(filterChain *listener.FilterChain) *tcp.TcpProxy {
+		assert.Equal(t, len(filterChain.Filters), 1)
+		assert.Equal(t, filterChain.Filters[0].Name, wellknown.TCPProxy)
+		got := &tcp.TcpProxy{}
+		assert.NilError(t, filterChain.Filters[0].GetTypedConfig().UnmarshalTo(got))
+		return got
+	}
+
+	single, err := NewPassthroughFilterChain(&PassthroughMatch{
+		Name:     "db",
+		Hosts:    []string{"db.example.com"},
+		Clusters: []*tcp.TcpProxy_WeightedCluster_ClusterWeight{{Name: "ns/db", Weight: 100}},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, single.FilterChainMatch.ServerNames, []string{"db.example.com"})
+	assert.Assert(t, single.TransportSocket == nil)
+	assert.Equal(t, tcpProxy(single).GetCluster(), "ns/db")
+	assert.Equal(t, tcpProxy(single).StatPrefix, "db")
+
+	clusters := []*tcp.TcpProxy_WeightedCluster_ClusterWeight{{Name: "ns/v1", Weight: 90}, {Name: "ns/v2", Weight: 10}}
+	split, err := NewPassthroughFilterChain(&PassthroughMatch{
+		Name:     "db",
+		Hosts:    []string{"db.example.com"},
+		Clusters: clusters,
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tcpProxy(split).GetWeightedClusters().Clusters, clusters, protocmp.Transform())
+}
+
+func TestAddPassthroughFilterChains(t *testing.T) {
+	match := &PassthroughMatch{
+		Name:     "db",
+		Hosts:    []string{"db.example.com"},
+		Clusters: []*tcp.TcpProxy_WeightedCluster_ClusterWeight{{Name: "ns/db", Weight: 100}},
+	}
+
+	l, err := NewHTTPSListener(8443, []*listener.FilterChain{{}}, true)
+	assert.NilError(t, err)
+	assert.NilError(t, AddPassthroughFilterChains(l, []*PassthroughMatch{match}))
+
+	// The passthrough filter chains precede the existing ones.
+	assert.Equal(t, len(l.FilterChains), 2)
+	assert.DeepEqual(t, l.FilterChains[0].FilterChainMatch.ServerNames, []string{"db.example.com"})
+
+	// The TLS inspector runs after the proxy protocol filter.
+	assert.Equal(t, len(l.ListenerFilters), 2)
+	assert.Equal(t, l.ListenerFilters[0].Name, wellknown.ProxyProtocol)
+	assert.Equal(t, l.ListenerFilters[1].Name, wellknown.TlsInspector)
+
+	// Listeners inspecting the TLS connections already don't inspect them twice.
+	assert.NilError(t, AddPassthroughFilterChains(l, []*PassthroughMatch{match}))
+	assert.Equal(t, len(l.ListenerFilters), 2)
+}
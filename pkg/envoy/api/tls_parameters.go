@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+var tlsProtocolVersions = map[string]auth.TlsParameters_TlsProtocol{
+	"1.0": auth.TlsParameters_TLSv1_0,
+	"1.1": auth.TlsParameters_TLSv1_1,
+	"1.2": auth.TlsParameters_TLSv1_2,
+	"1.3": auth.TlsParameters_TLSv1_3,
+}
+
+// NewTLSParameters creates the downstream TLS parameters configured in the given
+// config. The minimum version defaults to TLS 1.2, everything else to Envoy's defaults.
+func NewTLSParameters(kourierConfig *config.Kourier) *auth.TlsParameters {
+	minVersion, ok := tlsProtocolVersions[kourierConfig.TLSMinProtocolVersion]
+	if !ok {
+		minVersion = auth.TlsParameters_TLSv1_2
+	}
+
+	return &auth.TlsParameters{
+		TlsMinimumProtocolVersion: minVersion,
+		// Unknown versions map to TLS_AUTO.
+		TlsMaximumProtocolVersion: tlsProtocolVersions[kourierConfig.TLSMaxProtocolVersion],
+		CipherSuites:              kourierConfig.TLSCipherSuites,
+		EcdhCurves:                kourierConfig.TLSECDHCurves,
+	}
+}
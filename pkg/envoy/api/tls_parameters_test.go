@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewTLSParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Kourier
+		want   *auth.TlsParameters
+	}{{
+		name: "defaults",
+		want: &auth.TlsParameters{
+			TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+		},
+	}, {
+		name: "TLS 1.3 only",
+		config: config.Kourier{
+			TLSMinProtocolVersion: "1.3",
+			TLSMaxProtocolVersion: "1.3",
+		},
+		want: &auth.TlsParameters{
+			TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_3,
+			TlsMaximumProtocolVersion: auth.TlsParameters_TLSv1_3,
+		},
+	}, {
+		name: "cipher suites and curves",
+		config: config.Kourier{
+			TLSCipherSuites: []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"},
+			TLSECDHCurves:   []string{"P-256"},
+		},
+		want: &auth.TlsParameters{
+			TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+			CipherSuites:              []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"},
+			EcdhCurves:                []string{"P-256"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NewTLSParameters(&test.config)
+			assert.DeepEqual(t, got, test.want, protocmp.Transform())
+		})
+	}
+}
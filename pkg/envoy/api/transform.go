@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	wasmfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// TransformMetadataNamespace is the route metadata namespace the transformation
+	// Wasm module reads the transformations to apply from.
+	TransformMetadataNamespace = "kourier.transform"
+
+	transformPluginName = "kourier-transform"
+)
+
+// NewTransformFilter creates a Wasm filter running the request transformation module
+// at the given path.
+func NewTransformFilter(modulePath string) *hcm.HttpFilter {
+	filter, _ := anypb.New(&wasmfilter.Wasm{
+		Config: &wasm.PluginConfig{
+			Name:   transformPluginName,
+			RootId: transformPluginName,
+			Vm: &wasm.PluginConfig_VmConfig{
+				VmConfig: &wasm.VmConfig{
+					VmId:    transformPluginName,
+					Runtime: "envoy.wasm.runtime.v8",
+					Code: &core.AsyncDataSource{
+						Specifier: &core.AsyncDataSource_Local{
+							Local: &core.DataSource{
+								Specifier: &core.DataSource_Filename{Filename: modulePath},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	return &hcm.HttpFilter{
+		Name:       wellknown.HTTPWasm,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// SetTransform attaches the given transformations to the route's metadata.
+func SetTransform(r *route.Route, transform *structpb.Struct) {
+	if r.Metadata == nil {
+		r.Metadata = &core.Metadata{}
+	}
+	if r.Metadata.FilterMetadata == nil {
+		r.Metadata.FilterMetadata = make(map[string]*structpb.Struct, 1)
+	}
+	r.Metadata.FilterMetadata[TransformMetadataNamespace] = transform
+}
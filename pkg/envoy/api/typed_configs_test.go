@@ -0,0 +1,38 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestTypedConfigsMarshalJSON(t *testing.T) {
+	for _, message := range []proto.Message{
+		NewHTTPConnectionManager("test", config.DefaultConfig()),
+		NewGRPCWebFilter(),
+		NewOnDemandFilter(),
+		newTLSInspectorListenerFilter(),
+	} {
+		_, err := protojson.Marshal(message)
+		assert.NilError(t, err)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"net/url"
+	"strconv"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extAuthService "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+const (
+	// unmatchedHostName is the name of the catch-all virtual host and of its route.
+	unmatchedHostName = "unmatched_host"
+
+	// defaultUnmatchedHostStatus is the status of the direct responses to the requests
+	// matching no virtual host.
+	defaultUnmatchedHostStatus = 404
+)
+
+// redirectResponseCodes are Envoy's response codes of the redirects, by status.
+var redirectResponseCodes = map[uint32]route.RedirectAction_RedirectResponseCode{
+	301: route.RedirectAction_MOVED_PERMANENTLY,
+	302: route.RedirectAction_FOUND,
+	303: route.RedirectAction_SEE_OTHER,
+	307: route.RedirectAction_TEMPORARY_REDIRECT,
+	308: route.RedirectAction_PERMANENT_REDIRECT,
+}
+
+// NewUnmatchedHostVirtualHost creates the catch-all virtual host answering the requests
+// matching no other virtual host with the response configured in the given config,
+// either directly or by redirecting them. It returns nil if the response isn't
+// customized, leaving Envoy's blank 404.
+func NewUnmatchedHostVirtualHost(kourierConfig *config.Kourier) *route.VirtualHost {
+	if !kourierConfig.UnmatchedHostResponse() {
+		return nil
+	}
+
+	r := &route.Route{
+		Name: unmatchedHostName,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+		},
+	}
+	if kourierConfig.UnmatchedHostRedirect != "" {
+		r.Action = &route.Route_Redirect{Redirect: newUnmatchedHostRedirect(kourierConfig)}
+	} else {
+		status := kourierConfig.UnmatchedHostStatus
+		if status == 0 {
+			status = defaultUnmatchedHostStatus
+		}
+		action := &route.DirectResponseAction{Status: status}
+		if kourierConfig.UnmatchedHostBody != "" {
+			action.Body = &core.DataSource{
+				Specifier: &core.DataSource_InlineString{InlineString: kourierConfig.UnmatchedHostBody},
+			}
+		}
+		r.Action = &route.Route_DirectResponse{DirectResponse: action}
+		if kourierConfig.UnmatchedHostContentType != "" {
+			r.ResponseHeadersToAdd = headersToAdd(map[string]string{"content-type": kourierConfig.UnmatchedHostContentType})
+		}
+	}
+
+	// There's no backend to authorize the requests for.
+	extAuthzDisabled, _ := anypb.New(&extAuthService.ExtAuthzPerRoute{
+		Override: &extAuthService.ExtAuthzPerRoute_Disabled{Disabled: true},
+	})
+	r.TypedPerFilterConfig = map[string]*anypb.Any{
+		wellknown.HTTPExternalAuthorization: extAuthzDisabled,
+	}
+
+	return &route.VirtualHost{
+		Name:    unmatchedHostName,
+		Domains: []string{"*"},
+		Routes:  []*route.Route{r},
+	}
+}
+
+// newUnmatchedHostRedirect creates the redirect of the requests matching no virtual host
+// to the URL configured in the given config.
+func newUnmatchedHostRedirect(kourierConfig *config.Kourier) *route.RedirectAction {
+	// The URL is validated along with the config.
+	target, _ := url.Parse(kourierConfig.UnmatchedHostRedirect)
+
+	redirect := &route.RedirectAction{
+		SchemeRewriteSpecifier: &route.RedirectAction_SchemeRedirect{SchemeRedirect: target.Scheme},
+		HostRedirect:           target.Hostname(),
+		PathRewriteSpecifier:   &route.RedirectAction_PathRedirect{PathRedirect: target.RequestURI()},
+		ResponseCode:           route.RedirectAction_FOUND,
+	}
+	if port, err := strconv.ParseUint(target.Port(), 10, 32); err == nil {
+		redirect.PortRedirect = uint32(port)
+	}
+	if code, ok := redirectResponseCodes[kourierConfig.UnmatchedHostStatus]; ok {
+		redirect.ResponseCode = code
+	}
+	return redirect
+}
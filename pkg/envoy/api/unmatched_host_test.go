@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewUnmatchedHostVirtualHost(t *testing.T) {
+	assert.Assert(t, NewUnmatchedHostVirtualHost(&config.Kourier{}) == nil)
+
+	vh := NewUnmatchedHostVirtualHost(&config.Kourier{
+		UnmatchedHostBody:        "<h1>Not found</h1>",
+		UnmatchedHostContentType: "text/html",
+	})
+	assert.DeepEqual(t, vh.Domains, []string{"*"})
+	assert.Equal(t, len(vh.Routes), 1)
+	r := vh.Routes[0]
+	assert.Equal(t, r.Match.GetPrefix(), "/")
+	assert.Equal(t, r.GetDirectResponse().Status, uint32(404))
+	assert.Equal(t, r.GetDirectResponse().Body.GetInlineString(), "<h1>Not found</h1>")
+	assert.Equal(t, r.ResponseHeadersToAdd[0].Header.Key, "content-type")
+	assert.Equal(t, r.ResponseHeadersToAdd[0].Header.Value, "text/html")
+	assert.Assert(t, r.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+
+	vh = NewUnmatchedHostVirtualHost(&config.Kourier{UnmatchedHostStatus: 503})
+	assert.Equal(t, vh.Routes[0].GetDirectResponse().Status, uint32(503))
+	assert.Assert(t, vh.Routes[0].GetDirectResponse().Body == nil)
+	assert.Equal(t, len(vh.Routes[0].ResponseHeadersToAdd), 0)
+
+	vh = NewUnmatchedHostVirtualHost(&config.Kourier{UnmatchedHostRedirect: "https://www.example.com:8443/missing?from=kourier"})
+	assert.DeepEqual(t, vh.Routes[0].GetRedirect(), &route.RedirectAction{
+		SchemeRewriteSpecifier: &route.RedirectAction_SchemeRedirect{SchemeRedirect: "https"},
+		HostRedirect:           "www.example.com",
+		PortRedirect:           8443,
+		PathRewriteSpecifier:   &route.RedirectAction_PathRedirect{PathRedirect: "/missing?from=kourier"},
+		ResponseCode:           route.RedirectAction_FOUND,
+	}, protocmp.Transform())
+
+	vh = NewUnmatchedHostVirtualHost(&config.Kourier{UnmatchedHostRedirect: "http://www.example.com", UnmatchedHostStatus: 308})
+	redirect := vh.Routes[0].GetRedirect()
+	assert.Equal(t, redirect.PortRedirect, uint32(0))
+	assert.Equal(t, redirect.GetPathRedirect(), "/")
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_PERMANENT_REDIRECT)
+}
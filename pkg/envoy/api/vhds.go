@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	envoy_api_v3_core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const onDemandFilterName = "envoy.filters.http.on_demand"
+
+// NewOnDemandFilter creates the filter requesting the virtual host of a request's host
+// via VHDS if the gateway doesn't know it yet.
+func NewOnDemandFilter() *hcm.HttpFilter {
+	return &hcm.HttpFilter{
+		Name: onDemandFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: &anypb.Any{
+			TypeUrl: "type.googleapis.com/envoy.extensions.filters.http.on_demand.v3.OnDemand",
+		}},
+	}
+}
+
+// NewVHDSRouteConfig creates a RouteConfiguration with the given name, whose virtual
+// hosts are requested on demand via VHDS. See VirtualHostResourceName for how the
+// virtual hosts have to be named.
+func NewVHDSRouteConfig(name string) *route.RouteConfiguration {
+	return &route.RouteConfiguration{
+		Name: name,
+		Vhds: &route.Vhds{
+			ConfigSource: &envoy_api_v3_core.ConfigSource{
+				ResourceApiVersion: resource.DefaultAPIVersion,
+				ConfigSourceSpecifier: &envoy_api_v3_core.ConfigSource_Ads{
+					Ads: &envoy_api_v3_core.AggregatedConfigSource{},
+				},
+			},
+		},
+		// See NewRouteConfig.
+		ValidateClusters: wrapperspb.Bool(true),
+	}
+}
+
+// VirtualHostResourceName returns the name of the VHDS resource serving the given host
+// of the RouteConfiguration with the given name, as requested by the gateways.
+func VirtualHostResourceName(routeConfigName, host string) string {
+	return routeConfigName + "/" + host
+}
@@ -17,10 +17,19 @@ limitations under the License.
 package envoy
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	extAuthService "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // NewVirtualHost creates a new VirtualHost.
@@ -57,3 +66,224 @@ func NewVirtualHostWithExtAuthz(
 	}
 
 }
+
+// SetRequestBudget limits the requests of all routes of the VirtualHost to the given
+// budget. Envoy stamps the requests to upstreams with the remaining budget
+// ("x-envoy-expected-rq-timeout-ms") and their attempt count ("x-envoy-attempt-count").
+func SetRequestBudget(vh *route.VirtualHost, budget time.Duration) {
+	vh.IncludeRequestAttemptCount = true
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.Timeout = durationpb.New(budget)
+		}
+	}
+}
+
+// SetRoutingPriority routes the requests of all routes of the VirtualHost with the given
+// priority, which makes them subject to the circuit breakers of that priority.
+func SetRoutingPriority(vh *route.VirtualHost, priority core.RoutingPriority) {
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.Priority = priority
+		}
+	}
+}
+
+// SetUpgrades replaces the upgrades, like "websocket", allowed on all routes of the
+// VirtualHost with the given ones. No upgrades are allowed if upgradeTypes is empty.
+func SetUpgrades(vh *route.VirtualHost, upgradeTypes []string) {
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.UpgradeConfigs = make([]*route.RouteAction_UpgradeConfig, 0, len(upgradeTypes))
+			for _, upgradeType := range upgradeTypes {
+				action.UpgradeConfigs = append(action.UpgradeConfigs, &route.RouteAction_UpgradeConfig{
+					UpgradeType: upgradeType,
+					Enabled:     wrapperspb.Bool(true),
+				})
+			}
+		}
+	}
+}
+
+// RestrictUpgrades disables the upgrades allowed on the routes of the VirtualHost which
+// aren't among the given ones, as well as h2c upgrades. The routes' configs of the
+// upgrades take precedence over the ones of the connection manager.
+func RestrictUpgrades(vh *route.VirtualHost, upgradeTypes []string) {
+	for _, r := range vh.Routes {
+		action := r.GetRoute()
+		if action == nil {
+			continue
+		}
+
+		h2c := false
+		for _, upgrade := range action.UpgradeConfigs {
+			allowed := false
+			for _, upgradeType := range upgradeTypes {
+				allowed = allowed || strings.EqualFold(upgrade.UpgradeType, upgradeType)
+			}
+			if isH2C := strings.EqualFold(upgrade.UpgradeType, UpgradeH2C); isH2C || !allowed {
+				upgrade.Enabled = wrapperspb.Bool(false)
+				h2c = h2c || isH2C
+			}
+		}
+		if !h2c {
+			action.UpgradeConfigs = append(action.UpgradeConfigs, &route.RouteAction_UpgradeConfig{
+				UpgradeType: UpgradeH2C,
+				Enabled:     wrapperspb.Bool(false),
+			})
+		}
+	}
+}
+
+// AddConnectRoutes adds a route matching CONNECT requests for every route of the
+// VirtualHost matching all paths. CONNECT requests carry no path, so they're only
+// matched by such routes. The CONNECT requests are proxied as they are, so the upgrade
+// still has to be allowed via SetUpgrades.
+func AddConnectRoutes(vh *route.VirtualHost) {
+	var connectRoutes []*route.Route
+	for _, r := range vh.Routes {
+		if r.GetRoute() == nil || r.Match.GetPrefix() != "/" {
+			continue
+		}
+		connectRoute := proto.Clone(r).(*route.Route)
+		connectRoute.Name += "/connect"
+		connectRoute.Match.PathSpecifier = &route.RouteMatch_ConnectMatcher_{
+			ConnectMatcher: &route.RouteMatch_ConnectMatcher{},
+		}
+		connectRoute.Match.QueryParameters = nil
+		connectRoutes = append(connectRoutes, connectRoute)
+	}
+	vh.Routes = append(vh.Routes, connectRoutes...)
+}
+
+// RestrictToWebSockets restricts the routes of the VirtualHost matching the given path
+// prefixes to WebSocket upgrades, with the given idle timeout. The other requests to
+// these paths are rejected with a 426 Upgrade Required by a route following each of them.
+func RestrictToWebSockets(vh *route.VirtualHost, prefixes []string, idleTimeout time.Duration) {
+	restricted := make(map[string]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		restricted[prefix] = true
+	}
+
+	routes := make([]*route.Route, 0, len(vh.Routes))
+	for _, r := range vh.Routes {
+		routes = append(routes, r)
+		action := r.GetRoute()
+		if action == nil || !restricted[r.Match.GetPrefix()] {
+			continue
+		}
+
+		rejectRoute := &route.Route{
+			Name:  r.Name + "/upgrade-required",
+			Match: proto.Clone(r.Match).(*route.RouteMatch),
+			Action: &route.Route_DirectResponse{
+				DirectResponse: &route.DirectResponseAction{Status: http.StatusUpgradeRequired},
+			},
+			ResponseHeadersToAdd: []*core.HeaderValueOption{{
+				Header: &core.HeaderValue{Key: "upgrade", Value: "websocket"},
+			}},
+		}
+
+		r.Match.Headers = append(r.Match.Headers, &route.HeaderMatcher{
+			Name: "upgrade",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &envoymatcherv3.StringMatcher{
+					MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: "websocket"},
+					IgnoreCase:   true,
+				},
+			},
+		})
+		action.IdleTimeout = durationpb.New(idleTimeout)
+		routes = append(routes, rejectRoute)
+	}
+	vh.Routes = routes
+}
+
+// SetIdleTimeout sets the idle timeout of the streams of all routes of the VirtualHost,
+// including upgraded connections like WebSockets, overriding the stream idle timeout of
+// the connection manager.
+func SetIdleTimeout(vh *route.VirtualHost, timeout time.Duration) {
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.IdleTimeout = durationpb.New(timeout)
+		}
+	}
+}
+
+// SetMaxStreamDuration limits the duration of the streams of all routes of the
+// VirtualHost, including upgraded connections, to the given duration.
+func SetMaxStreamDuration(vh *route.VirtualHost, maxStreamDuration time.Duration) {
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.MaxStreamDuration = &route.RouteAction_MaxStreamDuration{
+				MaxStreamDuration: durationpb.New(maxStreamDuration),
+			}
+		}
+	}
+}
+
+// retryAfterMethods are the idempotent methods whose requests are retried after the
+// delay hinted by the Retry-After header of the upstream response.
+var retryAfterMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+
+// SetRetryAfterBackoff retries the idempotent requests to the VirtualHost once if the
+// upstream response carries a Retry-After header, after the delay in seconds it hints.
+// Hints longer than the given max delay, which Envoy ignores, are replaced by a jittered
+// delay up to it. The routes with their own retry policy keep it.
+func SetRetryAfterBackoff(vh *route.VirtualHost, maxDelay time.Duration) {
+	methods := make([]*route.HeaderMatcher, 0, len(retryAfterMethods))
+	for _, method := range retryAfterMethods {
+		methods = append(methods, &route.HeaderMatcher{
+			Name: ":method",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &envoymatcherv3.StringMatcher{
+					MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: method},
+				},
+			},
+		})
+	}
+
+	vh.RetryPolicy = &route.RetryPolicy{
+		RetryOn:    "retriable-headers",
+		NumRetries: wrapperspb.UInt32(1),
+		RetriableHeaders: []*route.HeaderMatcher{{
+			Name:                 "retry-after",
+			HeaderMatchSpecifier: &route.HeaderMatcher_PresentMatch{PresentMatch: true},
+		}},
+		RetriableRequestHeaders: methods,
+		RateLimitedRetryBackOff: &route.RetryPolicy_RateLimitedRetryBackOff{
+			ResetHeaders: []*route.RetryPolicy_ResetHeader{{
+				Name:   "retry-after",
+				Format: route.RetryPolicy_SECONDS,
+			}},
+			MaxInterval: durationpb.New(maxDelay),
+		},
+		RetryBackOff: &route.RetryPolicy_RetryBackOff{
+			BaseInterval: durationpb.New(maxDelay),
+			MaxInterval:  durationpb.New(maxDelay),
+		},
+	}
+}
+
+// hedgeRetryOn are the conditions the attempts of the hedged requests are retried on
+// before their per try timeout.
+const hedgeRetryOn = "5xx,reset,connect-failure"
+
+// SetHedging races another attempt of the requests of all routes of the VirtualHost
+// against the ones taking longer than the given per try timeout, up to the given number of
+// additional attempts. The first successful response is returned. The attempts failing
+// with a 5xx, a reset or a connect failure before their timeout are retried as well.
+func SetHedging(vh *route.VirtualHost, perTryTimeout time.Duration, numRetries uint32) {
+	for _, r := range vh.Routes {
+		if action := r.GetRoute(); action != nil {
+			action.RetryPolicy = &route.RetryPolicy{
+				RetryOn:       hedgeRetryOn,
+				NumRetries:    wrapperspb.UInt32(numRetries),
+				PerTryTimeout: durationpb.New(perTryTimeout),
+			}
+			action.HedgePolicy = &route.HedgePolicy{
+				HedgeOnPerTryTimeout: true,
+			}
+		}
+	}
+}
@@ -18,10 +18,13 @@ package envoy
 
 import (
 	"testing"
+	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	"gotest.tools/v3/assert"
 )
 
@@ -57,3 +60,201 @@ func TestVirtualHostWithExtAuthz(t *testing.T) {
 	assert.DeepEqual(t, got.Routes, want.Routes, protocmp.Transform())
 	assert.Assert(t, got.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
 }
+
+func TestSetRequestBudget(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetRequestBudget(vh, 30*time.Second)
+
+	assert.Assert(t, vh.IncludeRequestAttemptCount)
+	assert.Equal(t, vh.Routes[0].GetRoute().Timeout.AsDuration(), 30*time.Second)
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+}
+
+func TestSetRoutingPriority(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetRoutingPriority(vh, core.RoutingPriority_HIGH)
+
+	assert.Equal(t, vh.Routes[0].GetRoute().Priority, core.RoutingPriority_HIGH)
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+}
+
+func TestSetUpgrades(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetUpgrades(vh, []string{"websocket", "CONNECT"})
+	assert.DeepEqual(t, vh.Routes[0].GetRoute().UpgradeConfigs, []*route.RouteAction_UpgradeConfig{{
+		UpgradeType: "websocket",
+		Enabled:     wrapperspb.Bool(true),
+	}, {
+		UpgradeType: "CONNECT",
+		Enabled:     wrapperspb.Bool(true),
+	}}, protocmp.Transform())
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+
+	SetUpgrades(vh, nil)
+	assert.Equal(t, len(vh.Routes[0].GetRoute().UpgradeConfigs), 0)
+}
+
+func TestRestrictUpgrades(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+	SetUpgrades(vh, []string{"websocket", "CONNECT", "H2C"})
+
+	RestrictUpgrades(vh, []string{"WebSocket"})
+	assert.DeepEqual(t, vh.Routes[0].GetRoute().UpgradeConfigs, []*route.RouteAction_UpgradeConfig{{
+		UpgradeType: "websocket",
+		Enabled:     wrapperspb.Bool(true),
+	}, {
+		UpgradeType: "CONNECT",
+		Enabled:     wrapperspb.Bool(false),
+	}, {
+		UpgradeType: "H2C",
+		Enabled:     wrapperspb.Bool(false),
+	}}, protocmp.Transform())
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+
+	// h2c upgrades are disabled explicitly, even if the routes don't allow them.
+	SetUpgrades(vh, nil)
+	RestrictUpgrades(vh, []string{"websocket"})
+	assert.DeepEqual(t, vh.Routes[0].GetRoute().UpgradeConfigs, []*route.RouteAction_UpgradeConfig{{
+		UpgradeType: UpgradeH2C,
+		Enabled:     wrapperspb.Bool(false),
+	}}, protocmp.Transform())
+}
+
+func TestAddConnectRoutes(t *testing.T) {
+	all := NewRoute("all", nil, "/", nil, 0, nil, "")
+	all.Match.QueryParameters = []*route.QueryParameterMatcher{{Name: "foo"}}
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("api", nil, "/api", nil, 0, nil, ""),
+		all,
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	AddConnectRoutes(vh)
+
+	// Only the routes matching all paths are matched by CONNECT requests as well.
+	assert.Equal(t, len(vh.Routes), 4)
+	connect := vh.Routes[3]
+	assert.Equal(t, connect.Name, "all/connect")
+	assert.Assert(t, connect.Match.GetConnectMatcher() != nil)
+	assert.Equal(t, len(connect.Match.QueryParameters), 0)
+	assert.Assert(t, connect.GetRoute() != nil)
+
+	// The original route is unchanged.
+	assert.Equal(t, all.Match.GetPrefix(), "/")
+}
+
+func TestRestrictToWebSockets(t *testing.T) {
+	ws := NewRoute("ws", []*route.HeaderMatcher{{Name: "foo"}}, "/ws", nil, 0, nil, "")
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		ws,
+		NewRoute("all", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/ws"),
+	})
+
+	RestrictToWebSockets(vh, []string{"/ws"}, time.Hour)
+
+	// The restricted route only matches WebSocket upgrades, and is followed by the route
+	// rejecting the other requests to its path.
+	assert.Equal(t, len(vh.Routes), 4)
+	assert.Equal(t, vh.Routes[0], ws)
+	assert.Equal(t, len(ws.Match.Headers), 2)
+	assert.Equal(t, ws.Match.Headers[1].Name, "upgrade")
+	assert.Equal(t, ws.Match.Headers[1].GetStringMatch().GetExact(), "websocket")
+	assert.Assert(t, ws.Match.Headers[1].GetStringMatch().IgnoreCase)
+	assert.Equal(t, ws.GetRoute().IdleTimeout.AsDuration(), time.Hour)
+
+	reject := vh.Routes[1]
+	assert.Equal(t, reject.Name, "ws/upgrade-required")
+	assert.Equal(t, reject.Match.GetPrefix(), "/ws")
+	assert.DeepEqual(t, reject.Match.Headers, []*route.HeaderMatcher{{Name: "foo"}}, protocmp.Transform())
+	assert.Equal(t, reject.GetDirectResponse().Status, uint32(426))
+	assert.Equal(t, reject.ResponseHeadersToAdd[0].Header.Value, "websocket")
+
+	// The other routes are left alone.
+	assert.Equal(t, vh.Routes[2].Name, "all")
+	assert.Equal(t, len(vh.Routes[2].Match.Headers), 0)
+	assert.Equal(t, vh.Routes[3].Name, "redirect")
+}
+
+func TestSetIdleTimeout(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetIdleTimeout(vh, time.Hour)
+
+	assert.Equal(t, vh.Routes[0].GetRoute().IdleTimeout.AsDuration(), time.Hour)
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+}
+
+func TestSetMaxStreamDuration(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetMaxStreamDuration(vh, time.Hour)
+
+	assert.Equal(t, vh.Routes[0].GetRoute().MaxStreamDuration.GetMaxStreamDuration().AsDuration(), time.Hour)
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+}
+
+func TestSetHedging(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+		NewRedirectRoute("redirect", nil, "/"),
+	})
+
+	SetHedging(vh, 200*time.Millisecond, 2)
+
+	action := vh.Routes[0].GetRoute()
+	assert.Equal(t, action.RetryPolicy.RetryOn, "5xx,reset,connect-failure")
+	assert.Equal(t, action.RetryPolicy.NumRetries.GetValue(), uint32(2))
+	assert.Equal(t, action.RetryPolicy.PerTryTimeout.AsDuration(), 200*time.Millisecond)
+	assert.Assert(t, action.HedgePolicy.HedgeOnPerTryTimeout)
+	assert.Assert(t, vh.Routes[1].GetRedirect() != nil)
+}
+
+func TestSetRetryAfterBackoff(t *testing.T) {
+	vh := NewVirtualHost("test", []string{"foo"}, []*route.Route{
+		NewRoute("route", nil, "/", nil, 0, nil, ""),
+	})
+
+	SetRetryAfterBackoff(vh, 5*time.Second)
+
+	policy := vh.RetryPolicy
+	assert.Equal(t, policy.RetryOn, "retriable-headers")
+	assert.Equal(t, policy.NumRetries.GetValue(), uint32(1))
+	assert.Equal(t, policy.RetriableHeaders[0].Name, "retry-after")
+	assert.Assert(t, policy.RetriableHeaders[0].GetPresentMatch())
+	assert.Equal(t, policy.RateLimitedRetryBackOff.ResetHeaders[0].Format, route.RetryPolicy_SECONDS)
+	assert.Equal(t, policy.RateLimitedRetryBackOff.MaxInterval.AsDuration(), 5*time.Second)
+	assert.Equal(t, policy.RetryBackOff.BaseInterval.AsDuration(), 5*time.Second)
+
+	// Only the requests of idempotent methods are retried.
+	var methods []string
+	for _, header := range policy.RetriableRequestHeaders {
+		assert.Equal(t, header.Name, ":method")
+		methods = append(methods, header.GetStringMatch().GetExact())
+	}
+	assert.DeepEqual(t, methods, []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"})
+
+	// The routes are left alone, so that their own retry policies take precedence.
+	assert.Assert(t, vh.Routes[0].GetRoute().RetryPolicy == nil)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"regexp"
+	"strings"
+
+	xdscore "github.com/cncf/xds/go/xds/core/v3"
+	xdsmatcher "github.com/cncf/xds/go/xds/type/matcher/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	composite "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/composite/v3"
+	wasmfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// compositeFilterName is the name of the filter running the filter of the action its
+// matcher selects for each request.
+const compositeFilterName = "envoy.filters.http.composite"
+
+// WasmExtension represents a Wasm module running on the requests to the given domains.
+type WasmExtension struct {
+	// Name identifies the extension, and names its filter.
+	Name string
+	// Domains are the domains of the virtual hosts whose requests the module runs on.
+	Domains []string
+	// Module is the code of the module, or ModulePath the path of its file on the
+	// gateway if empty.
+	Module     []byte
+	ModulePath string
+	// Configuration is handed to the module as its plugin configuration, unless empty.
+	Configuration string
+}
+
+// NewWasmExtensionFilter creates a filter running the Wasm module of the given extension
+// on the requests to its domains, as told by their authority. The other requests skip it.
+func NewWasmExtensionFilter(extension *WasmExtension) *hcm.HttpFilter {
+	code := &core.DataSource{Specifier: &core.DataSource_Filename{Filename: extension.ModulePath}}
+	if len(extension.Module) != 0 {
+		code = &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: extension.Module}}
+	}
+	plugin := &wasm.PluginConfig{
+		Name:   extension.Name,
+		RootId: extension.Name,
+		Vm: &wasm.PluginConfig_VmConfig{
+			VmConfig: &wasm.VmConfig{
+				VmId:    extension.Name,
+				Runtime: "envoy.wasm.runtime.v8",
+				Code: &core.AsyncDataSource{
+					Specifier: &core.AsyncDataSource_Local{Local: code},
+				},
+			},
+		},
+	}
+	if extension.Configuration != "" {
+		plugin.Configuration, _ = anypb.New(wrapperspb.String(extension.Configuration))
+	}
+	wasmFilter, _ := anypb.New(&wasmfilter.Wasm{Config: plugin})
+	action, _ := anypb.New(&composite.ExecuteFilterAction{
+		TypedConfig: &core.TypedExtensionConfig{Name: extension.Name, TypedConfig: wasmFilter},
+	})
+
+	input, _ := anypb.New(&envoymatcherv3.HttpRequestHeaderMatchInput{HeaderName: ":authority"})
+	compositeFilter, _ := anypb.New(&composite.Composite{})
+	filter, _ := anypb.New(&matching.ExtensionWithMatcher{
+		XdsMatcher: &xdsmatcher.Matcher{
+			MatcherType: &xdsmatcher.Matcher_MatcherList_{
+				MatcherList: &xdsmatcher.Matcher_MatcherList{
+					Matchers: []*xdsmatcher.Matcher_MatcherList_FieldMatcher{{
+						Predicate: &xdsmatcher.Matcher_MatcherList_Predicate{
+							MatchType: &xdsmatcher.Matcher_MatcherList_Predicate_SinglePredicate_{
+								SinglePredicate: &xdsmatcher.Matcher_MatcherList_Predicate_SinglePredicate{
+									Input: &xdscore.TypedExtensionConfig{Name: "authority", TypedConfig: input},
+									Matcher: &xdsmatcher.Matcher_MatcherList_Predicate_SinglePredicate_ValueMatch{
+										ValueMatch: &xdsmatcher.StringMatcher{
+											MatchPattern: &xdsmatcher.StringMatcher_SafeRegex{
+												SafeRegex: &xdsmatcher.RegexMatcher{
+													EngineType: &xdsmatcher.RegexMatcher_GoogleRe2{GoogleRe2: &xdsmatcher.RegexMatcher_GoogleRE2{}},
+													Regex:      domainsRegex(extension.Domains),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						OnMatch: &xdsmatcher.Matcher_OnMatch{
+							OnMatch: &xdsmatcher.Matcher_OnMatch_Action{
+								Action: &xdscore.TypedExtensionConfig{Name: extension.Name, TypedConfig: action},
+							},
+						},
+					}},
+				},
+			},
+		},
+		ExtensionConfig: &core.TypedExtensionConfig{Name: compositeFilterName, TypedConfig: compositeFilter},
+	})
+
+	return &hcm.HttpFilter{
+		Name:       extension.Name,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: filter},
+	}
+}
+
+// domainsRegex returns a regex matching the authorities of the given virtual host
+// domains, following their wildcards. Domains are matched case-insensitively.
+func domainsRegex(domains []string) string {
+	patterns := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		patterns = append(patterns, strings.ReplaceAll(regexp.QuoteMeta(domain), `\*`, ".+"))
+	}
+	return "(?i)^(?:" + strings.Join(patterns, "|") + ")$"
+}
+
+// AddWasmExtensions adds a filter running the Wasm module of each of the given
+// extensions to the HTTP connection managers of the listener, in front of their router.
+func AddWasmExtensions(l *listener.Listener, extensions []*WasmExtension) error {
+	if len(extensions) == 0 {
+		return nil
+	}
+
+	for _, filterChain := range l.FilterChains {
+		for _, filter := range filterChain.Filters {
+			if filter.Name != wellknown.HTTPConnectionManager {
+				continue
+			}
+			manager := &hcm.HttpConnectionManager{}
+			if err := filter.GetTypedConfig().UnmarshalTo(manager); err != nil {
+				return err
+			}
+
+			filters := make([]*hcm.HttpFilter, 0, len(manager.HttpFilters)+len(extensions))
+			for _, httpFilter := range manager.HttpFilters {
+				if httpFilter.Name == wellknown.Router {
+					for _, extension := range extensions {
+						filters = append(filters, NewWasmExtensionFilter(extension))
+					}
+				}
+				filters = append(filters, httpFilter)
+			}
+			manager.HttpFilters = filters
+
+			config, err := anypb.New(manager)
+			if err != nil {
+				return err
+			}
+			filter.ConfigType = &listener.Filter_TypedConfig{TypedConfig: config}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envoy
+
+import (
+	"regexp"
+	"testing"
+
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	composite "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/composite/v3"
+	wasmfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+)
+
+func TestNewWasmExtensionFilter(t *testing.T) {
+	filter := NewWasmExtensionFilter(&WasmExtension{
+		Name:          "kourier.wasm.ns.hello",
+		Domains:       []string{"hello.example.com", "hello.example.com:*"},
+		Module:        []byte("\x00asm"),
+		Configuration: `{"greeting":"hi"}`,
+	})
+	assert.Equal(t, filter.Name, "kourier.wasm.ns.hello")
+
+	withMatcher := &matching.ExtensionWithMatcher{}
+	assert.NilError(t, filter.GetTypedConfig().UnmarshalTo(withMatcher))
+	assert.NilError(t, withMatcher.Validate())
+	assert.Equal(t, withMatcher.ExtensionConfig.Name, compositeFilterName)
+
+	fieldMatchers := withMatcher.XdsMatcher.GetMatcherList().GetMatchers()
+	assert.Equal(t, len(fieldMatchers), 1)
+	action := &composite.ExecuteFilterAction{}
+	assert.NilError(t, fieldMatchers[0].OnMatch.GetAction().GetTypedConfig().UnmarshalTo(action))
+	wasm := &wasmfilter.Wasm{}
+	assert.NilError(t, action.TypedConfig.TypedConfig.UnmarshalTo(wasm))
+	assert.DeepEqual(t, wasm.Config.GetVmConfig().Code.GetLocal().GetInlineBytes(), []byte("\x00asm"))
+	configuration := &wrapperspb.StringValue{}
+	assert.NilError(t, wasm.Config.Configuration.UnmarshalTo(configuration))
+	assert.Equal(t, configuration.Value, `{"greeting":"hi"}`)
+
+	// The module is read from the gateway without its code.
+	filter = NewWasmExtensionFilter(&WasmExtension{Name: "path", Domains: []string{"a"}, ModulePath: "/wasm/module.wasm"})
+	assert.NilError(t, filter.GetTypedConfig().UnmarshalTo(withMatcher))
+	fieldMatchers = withMatcher.XdsMatcher.GetMatcherList().GetMatchers()
+	assert.NilError(t, fieldMatchers[0].OnMatch.GetAction().GetTypedConfig().UnmarshalTo(action))
+	assert.NilError(t, action.TypedConfig.TypedConfig.UnmarshalTo(wasm))
+	assert.Equal(t, wasm.Config.GetVmConfig().Code.GetLocal().GetFilename(), "/wasm/module.wasm")
+	assert.Assert(t, wasm.Config.Configuration == nil)
+}
+
+func TestDomainsRegex(t *testing.T) {
+	re := regexp.MustCompile(domainsRegex([]string{"hello.example.com", "hello.example.com:*", "*.wild.com"}))
+
+	for _, authority := range []string{"hello.example.com", "Hello.Example.com", "hello.example.com:8080", "a.wild.com"} {
+		assert.Assert(t, re.MatchString(authority), authority)
+	}
+	for _, authority := range []string{"helloxexample.com", "other.example.com", "hello.example.com.evil", "wild.com"} {
+		assert.Assert(t, !re.MatchString(authority), authority)
+	}
+}
+
+func TestAddWasmExtensions(t *testing.T) {
+	manager := NewHTTPConnectionManager("test", &config.Kourier{})
+	l, err := NewHTTPListener(manager, 8080, false)
+	assert.NilError(t, err)
+
+	assert.NilError(t, AddWasmExtensions(l, []*WasmExtension{
+		{Name: "first", Domains: []string{"a"}, ModulePath: "/a.wasm"},
+		{Name: "second", Domains: []string{"b"}, ModulePath: "/b.wasm"},
+	}))
+
+	got := &hcm.HttpConnectionManager{}
+	assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(got))
+	// The extensions run right before the router.
+	filters := got.HttpFilters
+	assert.Equal(t, len(filters), len(manager.HttpFilters)+2)
+	assert.Equal(t, filters[len(filters)-3].Name, "first")
+	assert.Equal(t, filters[len(filters)-2].Name, "second")
+	assert.Equal(t, filters[len(filters)-1].Name, wellknown.Router)
+}
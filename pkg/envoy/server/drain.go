@@ -0,0 +1,352 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"google.golang.org/grpc/peer"
+)
+
+// drainingNodeIDSuffix is appended to the node ID of the gateways which are draining.
+const drainingNodeIDSuffix = "/draining"
+
+// errStreamReset is returned by the streams of the gateways which start draining, so
+// that they reconnect and fetch the draining snapshot.
+var errStreamReset = errors.New("stream reset to drain the gateway")
+
+// DrainingNodeID returns the node ID the draining gateways of the given node ID fetch
+// their snapshot with.
+func DrainingNodeID(nodeID string) string {
+	return nodeID + drainingNodeIDSuffix
+}
+
+// IsDrainingNodeID returns whether the given node ID is the one of draining gateways.
+func IsDrainingNodeID(nodeID string) bool {
+	return strings.HasSuffix(nodeID, drainingNodeIDSuffix)
+}
+
+// drainingPods tracks the gateway pods which are draining, by IP, along with the
+// streams of the other ones, so that they can be reset once their pod starts draining.
+type drainingPods struct {
+	mu       sync.Mutex
+	draining map[string]bool
+	// streams are the reset channels of the streams which don't serve the draining
+	// snapshot, keyed by the IP of their pod.
+	streams map[string]map[chan struct{}]bool
+}
+
+func newDrainingPods() *drainingPods {
+	return &drainingPods{
+		draining: make(map[string]bool),
+		streams:  make(map[string]map[chan struct{}]bool),
+	}
+}
+
+// openStream registers a stream of the pod with the given IP. It returns whether the
+// pod is draining already, and otherwise the channel closed once it starts draining.
+// The returned func must be called once the stream is closed.
+func (p *drainingPods) openStream(ip string) (bool, <-chan struct{}, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.draining[ip] {
+		return true, nil, func() {}
+	}
+	reset := make(chan struct{})
+	if p.streams[ip] == nil {
+		p.streams[ip] = make(map[chan struct{}]bool)
+	}
+	p.streams[ip][reset] = true
+	return false, reset, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.streams[ip], reset)
+		if len(p.streams[ip]) == 0 {
+			delete(p.streams, ip)
+		}
+	}
+}
+
+// drain marks the pod with the given IP as draining, so that its new streams serve the
+// draining snapshot. It returns false if the pod was draining already.
+func (p *drainingPods) drain(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.draining[ip] {
+		return false
+	}
+	p.draining[ip] = true
+	return true
+}
+
+// resetStreams resets the streams of the pod with the given IP opened before it started
+// draining.
+func (p *drainingPods) resetStreams(ip string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for reset := range p.streams[ip] {
+		close(reset)
+	}
+	delete(p.streams, ip)
+}
+
+// undrain forgets the pod with the given IP, e.g. because it's gone. It returns whether
+// any pod is still draining.
+func (p *drainingPods) undrain(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.draining, ip)
+	return len(p.draining) != 0
+}
+
+// any returns whether any pod is draining.
+func (p *drainingPods) any() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.draining) != 0
+}
+
+// drainableServer serves the aggregated streams of the gateways, making the ones of
+// draining pods fetch the snapshots of the draining node IDs.
+type drainableServer struct {
+	discovery.AggregatedDiscoveryServiceServer
+	pods *drainingPods
+}
+
+func (s drainableServer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	draining, reset, closeStream := s.pods.openStream(peerIP(stream))
+	defer closeStream()
+	receiver := newResettableReceiver(stream.Context(), reset, func() (interface{}, error) {
+		return stream.Recv()
+	})
+	defer receiver.close()
+	return s.AggregatedDiscoveryServiceServer.StreamAggregatedResources(&drainableStream{
+		AggregatedDiscoveryService_StreamAggregatedResourcesServer: stream,
+		draining: draining,
+		receiver: receiver,
+	})
+}
+
+func (s drainableServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	draining, reset, closeStream := s.pods.openStream(peerIP(stream))
+	defer closeStream()
+	receiver := newResettableReceiver(stream.Context(), reset, func() (interface{}, error) {
+		return stream.Recv()
+	})
+	defer receiver.close()
+	return s.AggregatedDiscoveryServiceServer.DeltaAggregatedResources(&drainableDeltaStream{
+		AggregatedDiscoveryService_DeltaAggregatedResourcesServer: stream,
+		draining: draining,
+		receiver: receiver,
+	})
+}
+
+// drainableStream is a state of the world stream, whose requests are made for the
+// draining node ID if its pod is draining. Otherwise, it's reset once its pod starts
+// draining.
+type drainableStream struct {
+	discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	draining bool
+	receiver *resettableReceiver
+}
+
+func (s *drainableStream) Context() context.Context {
+	return s.receiver.ctx
+}
+
+func (s *drainableStream) Recv() (*discovery.DiscoveryRequest, error) {
+	req, err := s.receiver.recv()
+	if err != nil {
+		return nil, err
+	}
+	if s.draining {
+		drainNode(req.(*discovery.DiscoveryRequest).GetNode())
+	}
+	return req.(*discovery.DiscoveryRequest), nil
+}
+
+// drainableDeltaStream is the incremental counterpart of drainableStream.
+type drainableDeltaStream struct {
+	discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer
+	draining bool
+	receiver *resettableReceiver
+}
+
+func (s *drainableDeltaStream) Context() context.Context {
+	return s.receiver.ctx
+}
+
+func (s *drainableDeltaStream) Recv() (*discovery.DeltaDiscoveryRequest, error) {
+	req, err := s.receiver.recv()
+	if err != nil {
+		return nil, err
+	}
+	if s.draining {
+		drainNode(req.(*discovery.DeltaDiscoveryRequest).GetNode())
+	}
+	return req.(*discovery.DeltaDiscoveryRequest), nil
+}
+
+// resettableReceiver receives the requests of a stream until the stream is reset. A
+// single goroutine receives the requests, ahead of their consumer. Once the stream is
+// reset, its context is cancelled, which ends both the goroutine and the processing of
+// the stream.
+type resettableReceiver struct {
+	ctx      context.Context
+	close    context.CancelFunc
+	reset    <-chan struct{}
+	received chan receivedRequest
+	start    sync.Once
+	receive  func() (interface{}, error)
+}
+
+// receivedRequest is a request received from a stream, or the error receiving it.
+type receivedRequest struct {
+	req interface{}
+	err error
+}
+
+// newResettableReceiver creates a receiver of the requests received with the given
+// func, until the given channel is closed, if any. It must be closed once the stream
+// ends.
+func newResettableReceiver(ctx context.Context, reset <-chan struct{}, receive func() (interface{}, error)) *resettableReceiver {
+	ctx, cancel := context.WithCancel(ctx)
+	return &resettableReceiver{
+		ctx:      ctx,
+		close:    cancel,
+		reset:    reset,
+		received: make(chan receivedRequest),
+		receive:  receive,
+	}
+}
+
+// recv returns the next request, or errStreamReset once the stream is reset.
+func (r *resettableReceiver) recv() (interface{}, error) {
+	r.start.Do(func() {
+		go r.run()
+	})
+
+	select {
+	case received := <-r.received:
+		return received.req, received.err
+	case <-r.reset:
+		r.close()
+		return nil, errStreamReset
+	case <-r.ctx.Done():
+		return nil, r.ctx.Err()
+	}
+}
+
+// run receives the requests of the stream until it fails or the context is done.
+func (r *resettableReceiver) run() {
+	for {
+		req, err := r.receive()
+		select {
+		case r.received <- receivedRequest{req: req, err: err}:
+		case <-r.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// drainNode makes the requests of the given node fetch the draining node ID.
+func drainNode(node *core.Node) {
+	if node != nil && node.Id != "" && !IsDrainingNodeID(node.Id) {
+		node.Id = DrainingNodeID(node.Id)
+	}
+}
+
+// peerIP returns the IP of the pod the given stream is opened by, or "" if unknown.
+func peerIP(stream interface{ Context() context.Context }) string {
+	p, ok := peer.FromContext(stream.Context())
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// EnableDraining lets the gateway pods drain, serving them the snapshots derived with
+// the given func from the snapshots of their node ID once they do.
+func (envoyXdsServer *XdsServer) EnableDraining(drainingSnapshot func(cache.ResourceSnapshot) (cache.ResourceSnapshot, error)) {
+	envoyXdsServer.drainingSnapshot = drainingSnapshot
+}
+
+// DrainPod makes the gateway pod with the given IP drain: its streams are reset, so
+// that it reconnects and fetches the draining snapshot of its node ID from then on.
+func (envoyXdsServer *XdsServer) DrainPod(ip string) error {
+	if envoyXdsServer.drainingSnapshot == nil {
+		return errors.New("draining is not enabled")
+	}
+	if !envoyXdsServer.pods.drain(ip) {
+		return nil
+	}
+
+	// The snapshots set from now on are derived as well.
+	for nodeID, snapshot := range envoyXdsServer.watchdog.snapshots() {
+		if IsDrainingNodeID(nodeID) {
+			continue
+		}
+		if err := envoyXdsServer.setDrainingSnapshot(nodeID, snapshot); err != nil {
+			return err
+		}
+	}
+	envoyXdsServer.pods.resetStreams(ip)
+	return nil
+}
+
+// UndrainPod forgets the gateway pod with the given IP, e.g. because it's gone. The
+// draining snapshots are dropped once no pod drains anymore.
+func (envoyXdsServer *XdsServer) UndrainPod(ip string) {
+	if envoyXdsServer.pods.undrain(ip) {
+		return
+	}
+	for nodeID := range envoyXdsServer.watchdog.snapshots() {
+		if IsDrainingNodeID(nodeID) {
+			envoyXdsServer.snapshotCache.ClearSnapshot(nodeID)
+			envoyXdsServer.watchdog.onSnapshot(nodeID, nil)
+		}
+	}
+}
+
+// setDrainingSnapshot sets the snapshot of the draining gateways of the given node ID,
+// derived from its given snapshot.
+func (envoyXdsServer *XdsServer) setDrainingSnapshot(nodeID string, snapshot cache.ResourceSnapshot) error {
+	drainingSnapshot, err := envoyXdsServer.drainingSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	return envoyXdsServer.setSnapshot(DrainingNodeID(nodeID), drainingSnapshot)
+}
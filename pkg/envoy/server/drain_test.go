@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestDrainNode(t *testing.T) {
+	node := &core.Node{Id: "gateway"}
+	drainNode(node)
+	assert.Equal(t, node.Id, "gateway/draining")
+	assert.Assert(t, IsDrainingNodeID(node.Id))
+
+	// The node ID isn't suffixed twice.
+	drainNode(node)
+	assert.Equal(t, node.Id, "gateway/draining")
+
+	// Requests without node keep the node of the stream.
+	drainNode(nil)
+	empty := &core.Node{}
+	drainNode(empty)
+	assert.Equal(t, empty.Id, "")
+}
+
+func TestDrainingPodsStreams(t *testing.T) {
+	pods := newDrainingPods()
+
+	draining, reset, closeStream := pods.openStream("10.0.0.1")
+	assert.Assert(t, !draining)
+	_, other, _ := pods.openStream("10.0.0.2")
+
+	assert.Assert(t, pods.drain("10.0.0.1"))
+	assert.Assert(t, !pods.drain("10.0.0.1"))
+	assert.Assert(t, pods.any())
+	pods.resetStreams("10.0.0.1")
+
+	// Only the streams of the draining pod are reset.
+	_, ok := <-reset
+	assert.Assert(t, !ok)
+	select {
+	case <-other:
+		t.Fatal("The stream of another pod was reset")
+	default:
+	}
+	closeStream()
+
+	// The new streams of the draining pod serve the draining snapshot.
+	draining, _, _ = pods.openStream("10.0.0.1")
+	assert.Assert(t, draining)
+
+	assert.Assert(t, !pods.undrain("10.0.0.1"))
+	assert.Assert(t, !pods.any())
+}
+
+func TestResettableReceiver(t *testing.T) {
+	requests := make(chan interface{})
+	receive := func() (interface{}, error) {
+		req, ok := <-requests
+		if !ok {
+			return nil, io.EOF
+		}
+		return req, nil
+	}
+
+	t.Run("receives until the stream ends", func(t *testing.T) {
+		requests = make(chan interface{})
+		receiver := newResettableReceiver(context.Background(), nil, receive)
+		defer receiver.close()
+
+		go func() {
+			requests <- "request"
+			close(requests)
+		}()
+		req, err := receiver.recv()
+		assert.NilError(t, err)
+		assert.Equal(t, req, "request")
+		_, err = receiver.recv()
+		assert.Equal(t, err, io.EOF)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		requests = make(chan interface{})
+		reset := make(chan struct{})
+		receiver := newResettableReceiver(context.Background(), reset, receive)
+
+		close(reset)
+		_, err := receiver.recv()
+		assert.Equal(t, err, errStreamReset)
+		// The context of the stream is cancelled, which ends its processing.
+		<-receiver.ctx.Done()
+
+		// The pending receive of the goroutine ends with the stream, after which it
+		// exits rather than waiting for the request to be consumed.
+		close(requests)
+		_, err = receiver.recv()
+		assert.Assert(t, err == errStreamReset || err == context.Canceled, err)
+	})
+}
+
+func TestDrainPod(t *testing.T) {
+	server := NewXdsServer(0, nil)
+	snapshot, err := cache.NewSnapshot("1", map[resource.Type][]types.Resource{
+		resource.RouteType: {&route.RouteConfiguration{Name: "routes"}},
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, server.SetSnapshot("gateway", snapshot))
+
+	// Draining must be enabled.
+	assert.Assert(t, server.DrainPod("10.0.0.1") != nil)
+
+	server.EnableDraining(func(snapshot cache.ResourceSnapshot) (cache.ResourceSnapshot, error) {
+		return cache.NewSnapshot("draining-"+snapshot.GetVersion(resource.RouteType), map[resource.Type][]types.Resource{
+			resource.RouteType: {&route.RouteConfiguration{Name: "routes"}},
+		})
+	})
+	assert.NilError(t, server.DrainPod("10.0.0.1"))
+	assert.Equal(t, server.Snapshots()[DrainingNodeID("gateway")].GetVersion(resource.RouteType), "draining-1")
+
+	// The snapshots set while the pod drains are derived as well.
+	snapshot, err = cache.NewSnapshot("2", map[resource.Type][]types.Resource{
+		resource.RouteType: {&route.RouteConfiguration{Name: "routes"}},
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, server.SetSnapshot("gateway", snapshot))
+	assert.Equal(t, server.Snapshots()[DrainingNodeID("gateway")].GetVersion(resource.RouteType), "draining-2")
+
+	// The draining snapshots are dropped once the pod is gone.
+	server.UndrainPod("10.0.0.1")
+	_, ok := server.Snapshots()[DrainingNodeID("gateway")]
+	assert.Assert(t, !ok)
+}
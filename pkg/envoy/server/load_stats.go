@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// LoadReportingInterval is the interval the gateways report the load of their clusters at.
+const LoadReportingInterval = 10 * time.Second
+
+// ClusterLoad is the load of a cluster, i.e. of the revision it routes to, reported by
+// the gateways.
+type ClusterLoad struct {
+	Cluster string `json:"cluster"`
+
+	// SuccessfulRequests is the number of requests completed successfully since the
+	// controller started.
+	SuccessfulRequests uint64 `json:"successfulRequests"`
+
+	// ErrorRequests is the number of requests completed with an error since the
+	// controller started.
+	ErrorRequests uint64 `json:"errorRequests"`
+
+	// RequestsInProgress is the number of requests in progress as of the latest reports.
+	RequestsInProgress uint64 `json:"requestsInProgress"`
+
+	// RequestsPerSecond is the rate of requests issued as of the latest reports.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+}
+
+// streamLoad is the load of a cluster as of the latest report on a stream.
+type streamLoad struct {
+	inProgress        uint64
+	requestsPerSecond float64
+}
+
+// LoadStats implements the load reporting service (LRS) and aggregates the load of the
+// clusters reported by all gateways.
+type LoadStats struct {
+	mu sync.Mutex
+
+	// nextStream identifies the streams, as the gateways share their node ID.
+	nextStream int64
+
+	totals  map[string]*ClusterLoad
+	streams map[int64]map[string]streamLoad
+}
+
+var _ lrs.LoadReportingServiceServer = (*LoadStats)(nil)
+
+// NewLoadStats creates an empty LoadStats.
+func NewLoadStats() *LoadStats {
+	return &LoadStats{
+		totals:  make(map[string]*ClusterLoad),
+		streams: make(map[int64]map[string]streamLoad),
+	}
+}
+
+// StreamLoadStats implements LoadReportingServiceServer. It asks the gateway to report
+// the load of all its clusters every LoadReportingInterval.
+func (s *LoadStats) StreamLoadStats(stream lrs.LoadReportingService_StreamLoadStatsServer) error {
+	s.mu.Lock()
+	id := s.nextStream
+	s.nextStream++
+	s.mu.Unlock()
+
+	// The load in progress of a gateway is gone along with its stream.
+	defer s.forgetStream(id)
+
+	first := true
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if first {
+			first = false
+			if err := stream.Send(&lrs.LoadStatsResponse{
+				SendAllClusters:       true,
+				LoadReportingInterval: durationpb.New(LoadReportingInterval),
+			}); err != nil {
+				return err
+			}
+		}
+		s.record(id, req.ClusterStats)
+	}
+}
+
+// Loads returns the load of all clusters reported so far, sorted by cluster.
+func (s *LoadStats) Loads() []ClusterLoad {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loads := make([]ClusterLoad, 0, len(s.totals))
+	for cluster, total := range s.totals {
+		load := *total
+		for _, streamLoads := range s.streams {
+			load.RequestsInProgress += streamLoads[cluster].inProgress
+			load.RequestsPerSecond += streamLoads[cluster].requestsPerSecond
+		}
+		loads = append(loads, load)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		return loads[i].Cluster < loads[j].Cluster
+	})
+	return loads
+}
+
+func (s *LoadStats) record(id int64, stats []*endpoint.ClusterStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	streamLoads := make(map[string]streamLoad, len(stats))
+	for _, clusterStats := range stats {
+		name := clusterStats.ClusterName
+		total := s.totals[name]
+		if total == nil {
+			total = &ClusterLoad{Cluster: name}
+			s.totals[name] = total
+		}
+
+		// The reports only cover the requests since the previous report.
+		var load streamLoad
+		var issued uint64
+		for _, locality := range clusterStats.UpstreamLocalityStats {
+			total.SuccessfulRequests += locality.TotalSuccessfulRequests
+			total.ErrorRequests += locality.TotalErrorRequests
+			load.inProgress += locality.TotalRequestsInProgress
+			issued += locality.TotalIssuedRequests
+		}
+		if interval := clusterStats.LoadReportInterval.AsDuration(); interval > 0 {
+			load.requestsPerSecond = float64(issued) / interval.Seconds()
+		}
+		streamLoads[name] = load
+	}
+	s.streams[id] = streamLoads
+}
+
+func (s *LoadStats) forgetStream(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.streams, id)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"gotest.tools/v3/assert"
+)
+
+type fakeLoadStatsStream struct {
+	grpc.ServerStream
+
+	requests  chan *lrs.LoadStatsRequest
+	responses []*lrs.LoadStatsResponse
+}
+
+func (s *fakeLoadStatsStream) Recv() (*lrs.LoadStatsRequest, error) {
+	req, ok := <-s.requests
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func (s *fakeLoadStatsStream) Send(resp *lrs.LoadStatsResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func clusterStats(name string, successful, errors, inProgress, issued uint64) *endpoint.ClusterStats {
+	return &endpoint.ClusterStats{
+		ClusterName: name,
+		UpstreamLocalityStats: []*endpoint.UpstreamLocalityStats{{
+			TotalSuccessfulRequests: successful,
+			TotalErrorRequests:      errors,
+			TotalRequestsInProgress: inProgress,
+			TotalIssuedRequests:     issued,
+		}},
+		LoadReportInterval: durationpb.New(10 * time.Second),
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	loadStats := NewLoadStats()
+
+	stream := &fakeLoadStatsStream{requests: make(chan *lrs.LoadStatsRequest)}
+	done := make(chan error)
+	go func() {
+		done <- loadStats.StreamLoadStats(stream)
+	}()
+
+	// The first request only announces the gateway.
+	stream.requests <- &lrs.LoadStatsRequest{}
+	stream.requests <- &lrs.LoadStatsRequest{ClusterStats: []*endpoint.ClusterStats{
+		clusterStats("ns/v1", 90, 10, 5, 100),
+	}}
+	stream.requests <- &lrs.LoadStatsRequest{ClusterStats: []*endpoint.ClusterStats{
+		clusterStats("ns/v1", 40, 0, 2, 50),
+		clusterStats("ns/v2", 20, 0, 1, 20),
+	}}
+
+	// Another gateway reports the same cluster.
+	other := &fakeLoadStatsStream{requests: make(chan *lrs.LoadStatsRequest)}
+	otherDone := make(chan error)
+	go func() {
+		otherDone <- loadStats.StreamLoadStats(other)
+	}()
+	other.requests <- &lrs.LoadStatsRequest{}
+	other.requests <- &lrs.LoadStatsRequest{ClusterStats: []*endpoint.ClusterStats{
+		clusterStats("ns/v1", 10, 0, 3, 10),
+	}}
+	close(other.requests)
+	assert.NilError(t, <-otherDone)
+
+	close(stream.requests)
+	assert.NilError(t, <-done)
+
+	// Each gateway is asked for the load of all of its clusters once.
+	assert.Equal(t, len(stream.responses), 1)
+	assert.Assert(t, stream.responses[0].SendAllClusters)
+	assert.Equal(t, stream.responses[0].LoadReportingInterval.AsDuration(), LoadReportingInterval)
+
+	// The requests in progress and their rate are gone along with the streams.
+	assert.DeepEqual(t, loadStats.Loads(), []ClusterLoad{{
+		Cluster:            "ns/v1",
+		SuccessfulRequests: 140,
+		ErrorRequests:      10,
+	}, {
+		Cluster:            "ns/v2",
+		SuccessfulRequests: 20,
+	}})
+}
+
+func TestLoadStatsInProgress(t *testing.T) {
+	loadStats := NewLoadStats()
+	loadStats.record(0, []*endpoint.ClusterStats{clusterStats("ns/v1", 90, 10, 5, 100)})
+	loadStats.record(1, []*endpoint.ClusterStats{clusterStats("ns/v1", 10, 0, 3, 50)})
+
+	// The latest report of each gateway is summed up.
+	loadStats.record(0, []*endpoint.ClusterStats{clusterStats("ns/v1", 40, 0, 2, 50)})
+
+	assert.DeepEqual(t, loadStats.Loads(), []ClusterLoad{{
+		Cluster:            "ns/v1",
+		SuccessfulRequests: 140,
+		ErrorRequests:      10,
+		RequestsInProgress: 5,
+		RequestsPerSecond:  10,
+	}})
+}
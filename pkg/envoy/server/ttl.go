@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ttlCallbacks sets the TTLs of the resources of the snapshots on the incremental
+// (delta) responses, in addition to the given callbacks. The snapshot cache only sets
+// them on state of the world responses.
+type ttlCallbacks struct {
+	xds.Callbacks
+	snapshotCache cache.SnapshotCache
+}
+
+func (c ttlCallbacks) OnStreamDeltaResponse(streamID int64, req *discovery.DeltaDiscoveryRequest, resp *discovery.DeltaDiscoveryResponse) {
+	if snapshot, err := c.snapshotCache.GetSnapshot(req.GetNode().GetId()); err == nil {
+		setDeltaTTLs(resp, snapshot)
+	}
+	c.Callbacks.OnStreamDeltaResponse(streamID, req, resp)
+}
+
+// setDeltaTTLs sets the TTLs of the resources of the given snapshot on the resources of
+// the response. The response is sent after the callbacks, so the gateways drop the
+// resources once their TTL elapses, unless they're sent again.
+func setDeltaTTLs(resp *discovery.DeltaDiscoveryResponse, snapshot cache.ResourceSnapshot) {
+	resources := snapshot.GetResourcesAndTTL(resp.TypeUrl)
+	for _, res := range resp.Resources {
+		if ttl := resources[res.Name].TTL; ttl != nil {
+			res.Ttl = durationpb.New(*ttl)
+		}
+	}
+}
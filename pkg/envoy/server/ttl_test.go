@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetDeltaTTLs(t *testing.T) {
+	ttl := time.Hour
+	snapshot, err := cache.NewSnapshotWithTTLs("1", map[resource.Type][]types.ResourceWithTTL{
+		resource.VirtualHostType: {
+			{Resource: &route.VirtualHost{Name: "ephemeral"}, TTL: &ttl},
+			{Resource: &route.VirtualHost{Name: "permanent"}},
+		},
+	})
+	assert.NilError(t, err)
+
+	resp := &discovery.DeltaDiscoveryResponse{
+		TypeUrl:   resource.VirtualHostType,
+		Resources: []*discovery.Resource{{Name: "ephemeral"}, {Name: "permanent"}},
+	}
+	setDeltaTTLs(resp, snapshot)
+
+	assert.Equal(t, resp.Resources[0].Ttl.AsDuration(), time.Hour)
+	assert.Assert(t, resp.Resources[1].Ttl == nil)
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+)
+
+// WatchdogInterval is the interval the snapshot watchdog checks the gateways at.
+const WatchdogInterval = 5 * time.Second
+
+// snapshotWatchdog tracks the versions acknowledged by the gateways on their streams,
+// to detect the ones that don't pick up the snapshot published for them, e.g. because
+// the cache or their stream is wedged.
+type snapshotWatchdog struct {
+	mu sync.Mutex
+	// streams and deltaStreams are the open state of the world and incremental (delta)
+	// streams, keyed by their ID. Their IDs are counted separately.
+	streams      map[int64]*watchedStream
+	deltaStreams map[int64]*watchedStream
+	// published are the snapshots last published, keyed by node ID.
+	published map[string]*publishedSnapshot
+
+	now func() time.Time
+}
+
+// watchedStream is the state of a stream of a gateway.
+type watchedStream struct {
+	nodeID string
+	// versions are the versions last acknowledged, keyed by type URL.
+	versions map[string]string
+	// rejected are the type URLs whose last response was rejected. Publishing the same
+	// snapshot again doesn't help these.
+	rejected map[string]bool
+	// requested are the times of the last requests, keyed by type URL.
+	requested map[string]time.Time
+	// pending are the versions of the responses of incremental streams which weren't
+	// acknowledged yet, keyed by their nonce. Incremental requests only acknowledge the
+	// nonce of a response.
+	pending map[string]string
+}
+
+func newWatchedStream() *watchedStream {
+	return &watchedStream{
+		versions:  make(map[string]string),
+		rejected:  make(map[string]bool),
+		requested: make(map[string]time.Time),
+		pending:   make(map[string]string),
+	}
+}
+
+// publishedSnapshot is a snapshot published for a node ID.
+type publishedSnapshot struct {
+	snapshot cache.ResourceSnapshot
+	// since are the times the versions of the snapshot last changed, or the snapshot was
+	// last published again, keyed by type URL.
+	since map[string]time.Time
+}
+
+func newSnapshotWatchdog() *snapshotWatchdog {
+	return &snapshotWatchdog{
+		streams:      make(map[int64]*watchedStream),
+		deltaStreams: make(map[int64]*watchedStream),
+		published:    make(map[string]*publishedSnapshot),
+		now:          time.Now,
+	}
+}
+
+// onStreamRequest records the version acknowledged, or rejected, by the request.
+func (w *snapshotWatchdog) onStreamRequest(streamID int64, req *discovery.DiscoveryRequest) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stream, ok := w.streams[streamID]
+	if !ok {
+		stream = newWatchedStream()
+		w.streams[streamID] = stream
+	}
+	// The node is only guaranteed to be sent with the first request.
+	if id := req.GetNode().GetId(); id != "" {
+		stream.nodeID = id
+	}
+	stream.versions[req.TypeUrl] = req.VersionInfo
+	stream.rejected[req.TypeUrl] = req.ErrorDetail != nil
+	stream.requested[req.TypeUrl] = w.now()
+}
+
+// onStreamClosed forgets the closed stream.
+func (w *snapshotWatchdog) onStreamClosed(streamID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.streams, streamID)
+}
+
+// onDeltaRequest records the version of the response acknowledged, or rejected, by the
+// incremental request.
+func (w *snapshotWatchdog) onDeltaRequest(streamID int64, req *discovery.DeltaDiscoveryRequest) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stream, ok := w.deltaStreams[streamID]
+	if !ok {
+		stream = newWatchedStream()
+		w.deltaStreams[streamID] = stream
+	}
+	if id := req.GetNode().GetId(); id != "" {
+		stream.nodeID = id
+	}
+	stream.requested[req.TypeUrl] = w.now()
+	if version, ok := stream.pending[req.ResponseNonce]; ok {
+		delete(stream.pending, req.ResponseNonce)
+		stream.versions[req.TypeUrl] = version
+		stream.rejected[req.TypeUrl] = req.ErrorDetail != nil
+	}
+}
+
+// onDeltaResponse records the version of the incremental response, until it's
+// acknowledged.
+func (w *snapshotWatchdog) onDeltaResponse(streamID int64, resp *discovery.DeltaDiscoveryResponse) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if stream, ok := w.deltaStreams[streamID]; ok {
+		stream.pending[resp.Nonce] = resp.SystemVersionInfo
+	}
+}
+
+// onDeltaStreamClosed forgets the closed incremental stream.
+func (w *snapshotWatchdog) onDeltaStreamClosed(streamID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.deltaStreams, streamID)
+}
+
+// onSnapshot records the snapshot published for the node ID, or its removal if nil.
+func (w *snapshotWatchdog) onSnapshot(nodeID string, snapshot cache.ResourceSnapshot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if snapshot == nil {
+		delete(w.published, nodeID)
+		return
+	}
+
+	now := w.now()
+	previous := w.published[nodeID]
+	published := &publishedSnapshot{snapshot: snapshot, since: make(map[string]time.Time, types.UnknownType)}
+	for i := types.ResponseType(0); i < types.UnknownType; i++ {
+		typeURL, err := cache.GetResponseTypeURL(i)
+		if err != nil {
+			continue
+		}
+		published.since[typeURL] = now
+		if previous != nil && previous.snapshot.GetVersion(typeURL) == snapshot.GetVersion(typeURL) {
+			published.since[typeURL] = previous.since[typeURL]
+		}
+	}
+	w.published[nodeID] = published
+}
+
+// snapshots returns the snapshots last published, keyed by node ID.
+func (w *snapshotWatchdog) snapshots() map[string]cache.ResourceSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	snapshots := make(map[string]cache.ResourceSnapshot, len(w.published))
+	for nodeID, published := range w.published {
+		snapshots[nodeID] = published.snapshot
+	}
+	return snapshots
+}
+
+// stale returns the snapshots of the node IDs with a stream that didn't acknowledge the
+// versions of the snapshot published for it within the given deadline, unless it
+// rejected them. The deadline starts when the snapshot is published, or when the stream
+// last requested the type if later, and starts over for the returned snapshots.
+func (w *snapshotWatchdog) stale(deadline time.Duration) map[string]cache.ResourceSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	stale := make(map[string]cache.ResourceSnapshot)
+	for _, streams := range []map[int64]*watchedStream{w.streams, w.deltaStreams} {
+		for _, stream := range streams {
+			published, ok := w.published[stream.nodeID]
+			if !ok {
+				continue
+			}
+			for typeURL, version := range stream.versions {
+				if !stream.rejected[typeURL] && version != published.snapshot.GetVersion(typeURL) &&
+					now.Sub(published.since[typeURL]) > deadline && now.Sub(stream.requested[typeURL]) > deadline {
+					stale[stream.nodeID] = published.snapshot
+					published.since[typeURL] = now
+				}
+			}
+		}
+	}
+	return stale
+}
+
+// watchdogCallbacks passes the requests, the incremental responses and the closing of
+// the streams on to the watchdog, in addition to the given callbacks.
+type watchdogCallbacks struct {
+	xds.Callbacks
+	watchdog *snapshotWatchdog
+}
+
+func (c watchdogCallbacks) OnStreamRequest(streamID int64, req *discovery.DiscoveryRequest) error {
+	c.watchdog.onStreamRequest(streamID, req)
+	return c.Callbacks.OnStreamRequest(streamID, req)
+}
+
+func (c watchdogCallbacks) OnStreamClosed(streamID int64) {
+	c.watchdog.onStreamClosed(streamID)
+	c.Callbacks.OnStreamClosed(streamID)
+}
+
+func (c watchdogCallbacks) OnStreamDeltaRequest(streamID int64, req *discovery.DeltaDiscoveryRequest) error {
+	c.watchdog.onDeltaRequest(streamID, req)
+	return c.Callbacks.OnStreamDeltaRequest(streamID, req)
+}
+
+func (c watchdogCallbacks) OnStreamDeltaResponse(streamID int64, req *discovery.DeltaDiscoveryRequest, resp *discovery.DeltaDiscoveryResponse) {
+	c.watchdog.onDeltaResponse(streamID, resp)
+	c.Callbacks.OnStreamDeltaResponse(streamID, req, resp)
+}
+
+func (c watchdogCallbacks) OnDeltaStreamClosed(streamID int64) {
+	c.watchdog.onDeltaStreamClosed(streamID)
+	c.Callbacks.OnDeltaStreamClosed(streamID)
+}
+
+// RunSnapshotWatchdog publishes the snapshots again which the gateways didn't pick up
+// within the deadline returned by the given function, every WatchdogInterval until the
+// context is done. The watchdog is disabled while the deadline is 0. The given function
+// is called for every snapshot published again.
+func (envoyXdsServer *XdsServer) RunSnapshotWatchdog(ctx context.Context, deadline func() time.Duration, onRepublished func(nodeID string, err error)) {
+	ticker := time.NewTicker(WatchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		d := deadline()
+		if d <= 0 {
+			continue
+		}
+		for nodeID, snapshot := range envoyXdsServer.watchdog.stale(d) {
+			// Open watches are only responded to once a snapshot is set.
+			onRepublished(nodeID, envoyXdsServer.snapshotCache.SetSnapshot(ctx, nodeID, snapshot))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"gotest.tools/v3/assert"
+)
+
+func TestSnapshotWatchdog(t *testing.T) {
+	snapshot := func(version string) *cache.Snapshot {
+		s, err := cache.NewSnapshot(version, map[resource.Type][]types.Resource{resource.ClusterType: {}})
+		assert.NilError(t, err)
+		return s
+	}
+
+	now := time.Unix(0, 0)
+	w := newSnapshotWatchdog()
+	w.now = func() time.Time { return now }
+
+	w.onSnapshot("node", snapshot("1"))
+	assert.Equal(t, w.snapshots()["node"].GetVersion(resource.ClusterType), "1")
+	w.onStreamRequest(1, &discovery.DiscoveryRequest{Node: &core.Node{Id: "node"}, TypeUrl: resource.ClusterType})
+	w.onStreamRequest(1, &discovery.DiscoveryRequest{TypeUrl: resource.ClusterType, VersionInfo: "1"})
+
+	// The acknowledged snapshot isn't stale.
+	now = now.Add(time.Minute)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+
+	// A snapshot which isn't acknowledged is stale once the deadline passed.
+	w.onSnapshot("node", snapshot("2"))
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+	now = now.Add(10 * time.Second)
+	stale := w.stale(10 * time.Second)
+	assert.Equal(t, len(stale), 1)
+	assert.Equal(t, stale["node"].GetVersion(resource.ClusterType), "2")
+
+	// The deadline starts over once the snapshot is published again.
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+	now = now.Add(15 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 1)
+
+	// Publishing the same snapshot again doesn't reset the deadline.
+	now = now.Add(15 * time.Second)
+	w.onSnapshot("node", snapshot("2"))
+	assert.Equal(t, len(w.stale(10*time.Second)), 1)
+
+	// Rejected snapshots aren't published again.
+	now = now.Add(15 * time.Second)
+	w.onStreamRequest(1, &discovery.DiscoveryRequest{TypeUrl: resource.ClusterType, VersionInfo: "1", ErrorDetail: &status.Status{}})
+	now = now.Add(15 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+
+	// Closed streams and cleared snapshots are forgotten.
+	w.onStreamRequest(1, &discovery.DiscoveryRequest{TypeUrl: resource.ClusterType, VersionInfo: "1"})
+	now = now.Add(15 * time.Second)
+	w.onStreamClosed(1)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+
+	w.onStreamRequest(2, &discovery.DiscoveryRequest{Node: &core.Node{Id: "node"}, TypeUrl: resource.ClusterType, VersionInfo: "1"})
+	w.onSnapshot("node", nil)
+	now = now.Add(15 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+}
+
+func TestSnapshotWatchdogDelta(t *testing.T) {
+	snapshot := func(version string) *cache.Snapshot {
+		s, err := cache.NewSnapshot(version, map[resource.Type][]types.Resource{resource.ClusterType: {}})
+		assert.NilError(t, err)
+		return s
+	}
+
+	now := time.Unix(0, 0)
+	w := newSnapshotWatchdog()
+	w.now = func() time.Time { return now }
+
+	w.onSnapshot("node", snapshot("1"))
+	w.onDeltaRequest(1, &discovery.DeltaDiscoveryRequest{Node: &core.Node{Id: "node"}, TypeUrl: resource.ClusterType})
+	w.onDeltaResponse(1, &discovery.DeltaDiscoveryResponse{TypeUrl: resource.ClusterType, Nonce: "1", SystemVersionInfo: "1"})
+	w.onDeltaRequest(1, &discovery.DeltaDiscoveryRequest{TypeUrl: resource.ClusterType, ResponseNonce: "1"})
+	assert.Equal(t, w.deltaStreams[1].versions[resource.ClusterType], "1")
+
+	// The response which isn't acknowledged is stale once the deadline passed.
+	w.onSnapshot("node", snapshot("2"))
+	w.onDeltaResponse(1, &discovery.DeltaDiscoveryResponse{TypeUrl: resource.ClusterType, Nonce: "2", SystemVersionInfo: "2"})
+	now = now.Add(15 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 1)
+
+	// Acknowledging it catches the stream up.
+	w.onDeltaRequest(1, &discovery.DeltaDiscoveryRequest{TypeUrl: resource.ClusterType, ResponseNonce: "2"})
+	now = now.Add(15 * time.Second)
+	assert.Equal(t, len(w.stale(10*time.Second)), 0)
+
+	w.onDeltaStreamClosed(1)
+	assert.Equal(t, len(w.deltaStreams), 0)
+}
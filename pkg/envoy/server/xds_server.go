@@ -24,7 +24,10 @@ import (
 	cluster "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	runtime "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	secret "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"google.golang.org/grpc"
@@ -40,18 +43,30 @@ type XdsServer struct {
 	ctx            context.Context
 	server         xds.Server
 	snapshotCache  cache.SnapshotCache
+	loadStats      *LoadStats
+	watchdog       *snapshotWatchdog
+
+	// pods are the gateway pods which are draining, which are served the snapshots
+	// derived by drainingSnapshot if set.
+	pods             *drainingPods
+	drainingSnapshot func(cache.ResourceSnapshot) (cache.ResourceSnapshot, error)
 }
 
 func NewXdsServer(managementPort uint, callbacks xds.Callbacks) *XdsServer {
 	ctx := context.Background()
 	snapshotCache := cache.NewSnapshotCache(true, cache.IDHash{}, nil)
-	srv := xds.NewServer(ctx, snapshotCache, callbacks)
+	watchdog := newSnapshotWatchdog()
+	callbacks = ttlCallbacks{Callbacks: callbacks, snapshotCache: snapshotCache}
+	srv := xds.NewServer(ctx, snapshotCache, watchdogCallbacks{Callbacks: callbacks, watchdog: watchdog})
 
 	return &XdsServer{
 		managementPort: managementPort,
 		ctx:            ctx,
 		server:         srv,
 		snapshotCache:  snapshotCache,
+		loadStats:      NewLoadStats(),
+		watchdog:       watchdog,
+		pods:           newDrainingPods(),
 	}
 }
 
@@ -76,11 +91,17 @@ func (envoyXdsServer *XdsServer) RunManagementServer() error {
 	}
 
 	// register services
-	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, drainableServer{
+		AggregatedDiscoveryServiceServer: server,
+		pods:                             envoyXdsServer.pods,
+	})
 	health.RegisterHealthServer(grpcServer, healthServer{})
 	cluster.RegisterClusterDiscoveryServiceServer(grpcServer, server)
 	listener.RegisterListenerDiscoveryServiceServer(grpcServer, server)
 	route.RegisterRouteDiscoveryServiceServer(grpcServer, server)
+	secret.RegisterSecretDiscoveryServiceServer(grpcServer, server)
+	runtime.RegisterRuntimeDiscoveryServiceServer(grpcServer, server)
+	lrs.RegisterLoadReportingServiceServer(grpcServer, envoyXdsServer.loadStats)
 
 	errCh := make(chan error)
 	go func() {
@@ -99,5 +120,38 @@ func (envoyXdsServer *XdsServer) RunManagementServer() error {
 }
 
 func (envoyXdsServer *XdsServer) SetSnapshot(nodeID string, snapshot cache.ResourceSnapshot) error {
-	return envoyXdsServer.snapshotCache.SetSnapshot(context.Background(), nodeID, snapshot)
+	if err := envoyXdsServer.setSnapshot(nodeID, snapshot); err != nil {
+		return err
+	}
+	if envoyXdsServer.drainingSnapshot != nil && envoyXdsServer.pods.any() {
+		return envoyXdsServer.setDrainingSnapshot(nodeID, snapshot)
+	}
+	return nil
+}
+
+func (envoyXdsServer *XdsServer) setSnapshot(nodeID string, snapshot cache.ResourceSnapshot) error {
+	if err := envoyXdsServer.snapshotCache.SetSnapshot(context.Background(), nodeID, snapshot); err != nil {
+		return err
+	}
+	envoyXdsServer.watchdog.onSnapshot(nodeID, snapshot)
+	return nil
+}
+
+// ClearSnapshot drops the snapshot of the given node ID, e.g. because its gateways are
+// no longer served, along with the one of its draining gateways.
+func (envoyXdsServer *XdsServer) ClearSnapshot(nodeID string) {
+	for _, id := range []string{nodeID, DrainingNodeID(nodeID)} {
+		envoyXdsServer.snapshotCache.ClearSnapshot(id)
+		envoyXdsServer.watchdog.onSnapshot(id, nil)
+	}
+}
+
+// LoadStats returns the load of the clusters reported by the gateways.
+func (envoyXdsServer *XdsServer) LoadStats() *LoadStats {
+	return envoyXdsServer.loadStats
+}
+
+// Snapshots returns the snapshots last set, keyed by node ID.
+func (envoyXdsServer *XdsServer) Snapshots() map[string]cache.ResourceSnapshot {
+	return envoyXdsServer.watchdog.snapshots()
 }
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// revisionHeaderName is the header the activator tells the revision of a request
+	// by. Knative Serving adds it to the splits targeting revisions.
+	revisionHeaderName = "Knative-Serving-Revision"
+
+	// activatorClusterSuffix is appended to the name of the cluster of a split to name
+	// the cluster sending its requests to the activator.
+	activatorClusterSuffix = "/activator"
+
+	// failoverClusterSuffix is appended to the name of the cluster of a split to name
+	// the aggregate cluster failing over from it to the activator.
+	failoverClusterSuffix = "/failover"
+)
+
+// isRevisionSplit returns whether the split targets a revision, i.e. whether the
+// activator is able to serve its requests.
+func isRevisionSplit(split v1alpha1.IngressBackendSplit) bool {
+	for name := range split.AppendHeaders {
+		if strings.EqualFold(name, revisionHeaderName) {
+			return true
+		}
+	}
+	return false
+}
+
+// activatorFailoverClusters returns the clusters failing over from the given cluster of
+// a split to the activator: the cluster of the activator, using the same settings and
+// target port as the given one, and the aggregate cluster of both to route to, last.
+// Outlier detection is enabled on the given cluster to fail over as soon as its
+// endpoints fail. It returns no clusters if the activator doesn't exist.
+func (translator *IngressTranslator) activatorFailoverClusters(ingress *v1alpha1.Ingress, direct *v3.Cluster, targetPort int32) ([]*v3.Cluster, error) {
+	namespace := pkgconfig.ServingNamespace()
+	if err := trackService(translator.tracker, namespace, pkgconfig.ActivatorServiceName, ingress); err != nil {
+		return nil, err
+	}
+
+	// The activator's endpoints aren't updated in place, so the ingresses are translated
+	// again once they change.
+	endpoints, err := translator.endpointsGetter(namespace, pkgconfig.ActivatorServiceName)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch activator endpoints: %w", err)
+	}
+
+	activator := proto.Clone(direct).(*v3.Cluster)
+	activator.Name = direct.Name + activatorClusterSuffix
+	activator.LoadAssignment = &endpoint.ClusterLoadAssignment{
+		ClusterName: activator.Name,
+		Endpoints: []*endpoint.LocalityLbEndpoints{{
+			LbEndpoints: lbEndpointsForKubeEndpoints(endpoints, targetPort, false),
+		}},
+	}
+
+	envoy.SetFailoverOutlierDetection(direct)
+	failover := envoy.NewAggregateCluster(direct.Name+failoverClusterSuffix, []string{direct.Name, activator.Name})
+	return []*v3.Cluster{activator, failover}, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorActivatorFailover(t *testing.T) {
+	t.Setenv(pkgconfig.ServingNamespaceEnv, "knative-serving")
+
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.ActivatorFailover = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	revisionIngress := func(withRevisionHeader bool) *v1alpha1.Ingress {
+		return ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+			if withRevisionHeader {
+				ing.Spec.Rules[0].HTTP.Paths[0].Splits[0].AppendHeaders["Knative-Serving-Revision"] = "servicename"
+			}
+		})
+	}
+
+	tests := []struct {
+		name          string
+		ingress       *v1alpha1.Ingress
+		withActivator bool
+		wantFailover  bool
+	}{{
+		name:          "revision split",
+		ingress:       revisionIngress(true),
+		withActivator: true,
+		wantFailover:  true,
+	}, {
+		name:          "split not targeting a revision",
+		ingress:       revisionIngress(false),
+		withActivator: true,
+	}, {
+		name:    "no activator",
+		ingress: revisionIngress(true),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename")}
+			if test.withActivator {
+				objects = append(objects, eps("knative-serving", pkgconfig.ActivatorServiceName, func(eps *corev1.Endpoints) {
+					eps.Subsets = []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "9.9.9.9"}}}}
+				}))
+			}
+			kubeclient := fake.NewSimpleClientset(objects...)
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.ingress, false)
+			assert.NilError(t, err)
+
+			weightedClusters := got.internalVirtualHosts[0].Routes[0].GetRoute().GetWeightedClusters().GetClusters()
+			if !test.wantFailover {
+				assert.Equal(t, len(got.clusters), 1)
+				assert.Assert(t, got.clusters[0].OutlierDetection == nil)
+				assert.Equal(t, weightedClusters[0].Name, "servicens/servicename")
+				return
+			}
+
+			assert.Equal(t, len(got.clusters), 3)
+			direct, activator, failover := got.clusters[0], got.clusters[1], got.clusters[2]
+			assert.Equal(t, weightedClusters[0].Name, "servicens/servicename/failover")
+
+			assert.Equal(t, direct.Name, "servicens/servicename")
+			assert.Assert(t, direct.OutlierDetection != nil)
+
+			// The activator is reached on the same target port.
+			assert.Equal(t, activator.Name, "servicens/servicename/activator")
+			assert.DeepEqual(t, activator.LoadAssignment.Endpoints[0].LbEndpoints,
+				[]*endpoint.LbEndpoint{envoy.NewLBEndpoint("9.9.9.9", 8080)}, protocmp.Transform())
+			assert.Assert(t, activator.OutlierDetection == nil)
+
+			assert.DeepEqual(t, failover,
+				envoy.NewAggregateCluster("servicens/servicename/failover", []string{direct.Name, activator.Name}), protocmp.Transform())
+			assert.Equal(t, failover.LbPolicy, v3.Cluster_CLUSTER_PROVIDED)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// annotationPrecedence resolves the conflict between an annotation of an Ingress and
+// the annotations whose instructions can't be followed while it is in effect.
+type annotationPrecedence struct {
+	// winner is the annotation taking precedence.
+	winner string
+	// inEffect returns whether the winner is in effect on the given Ingress.
+	inEffect func(ingress *v1alpha1.Ingress) bool
+	// ignored are the annotations ignored while the winner is in effect.
+	ignored []string
+}
+
+// annotationPrecedences are the precedences between the annotations of an Ingress, as
+// documented in the README. They're applied in order.
+var annotationPrecedences = []annotationPrecedence{{
+	// The passed through connections aren't served via HTTP.
+	winner:   pkgconfig.TLSPassthroughAnnotationKey,
+	inEffect: IsTLSPassthrough,
+	ignored: []string{
+		pkgconfig.HeaderMatchAnnotationKey,
+		pkgconfig.QueryParamMatchAnnotationKey,
+		pkgconfig.TransformAnnotationKey,
+		pkgconfig.UpgradesAnnotationKey,
+		pkgconfig.IdleTimeoutAnnotationKey,
+		pkgconfig.MaxStreamDurationAnnotationKey,
+		pkgconfig.MaxRequestBodyBytesAnnotationKey,
+		pkgconfig.StickyCanaryTTLAnnotationKey,
+		pkgconfig.GRPCJSONTranscoderAnnotationKey,
+		pkgconfig.PreviewHostsAnnotationKey,
+		pkgconfig.RouteTTLAnnotationKey,
+		pkgconfig.HostRedirectsAnnotationKey,
+		pkgconfig.HedgePerTryTimeoutAnnotationKey,
+		pkgconfig.HedgeNumRetriesAnnotationKey,
+		pkgconfig.WebSocketOnlyPathsAnnotationKey,
+		pkgconfig.WebSocketIdleTimeoutAnnotationKey,
+		pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey,
+		pkgconfig.UpstreamHTTP2InitialStreamWindowSizeAnnotationKey,
+		pkgconfig.UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey,
+		pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey,
+		pkgconfig.DynamicForwardProxyAnnotationKey,
+		pkgconfig.WasmExtensionAnnotationKey,
+		pkgconfig.LuaFilterAnnotationKey,
+		pkgconfig.HostRewriteAnnotationKey,
+		pkgconfig.PrefixRewriteAnnotationKey,
+		pkgconfig.RegexRewriteAnnotationKey,
+		pkgconfig.RedirectAnnotationKey,
+		pkgconfig.MaintenanceAnnotationKey,
+		pkgconfig.DropRoutesAnnotationKey,
+	},
+}, {
+	// The backends are reached via HTTP/1.1 only.
+	winner:   pkgconfig.DisableHTTP2AnnotationKey,
+	inEffect: isHTTP2Disabled,
+	ignored: []string{
+		pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey,
+		pkgconfig.UpstreamHTTP2InitialStreamWindowSizeAnnotationKey,
+		pkgconfig.UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey,
+	},
+}}
+
+// resolveAnnotationConflicts returns the Ingress without the annotations ignored in favor
+// of the ones taking precedence, along with a warning for each of them. The Ingress is
+// returned as it is if there are no conflicts.
+func resolveAnnotationConflicts(ingress *v1alpha1.Ingress) (*v1alpha1.Ingress, []string) {
+	var conflicts []string
+	for _, precedence := range annotationPrecedences {
+		if !precedence.inEffect(ingress) {
+			continue
+		}
+		for _, ignored := range precedence.ignored {
+			if _, ok := ingress.Annotations[ignored]; !ok {
+				continue
+			}
+			if conflicts == nil {
+				ingress = ingress.DeepCopy()
+			}
+			delete(ingress.Annotations, ignored)
+			conflicts = append(conflicts, fmt.Sprintf("%s is ignored in favor of %s", ignored, precedence.winner))
+		}
+	}
+	return ingress, conflicts
+}
+
+// isHTTP2Disabled returns whether the backends of the Ingress are reached via HTTP/1.1,
+// regardless of their ports.
+func isHTTP2Disabled(ingress *v1alpha1.Ingress) bool {
+	return strings.EqualFold(pkgconfig.GetDisableHTTP2(ingress.Annotations), "true")
+}
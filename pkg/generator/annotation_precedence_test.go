@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestResolveAnnotationConflicts(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		wantAnnotations map[string]string
+		wantConflicts   []string
+	}{{
+		name: "no conflicts",
+		annotations: map[string]string{
+			pkgconfig.DisableHTTP2AnnotationKey:                      "false",
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "100",
+		},
+		wantAnnotations: map[string]string{
+			pkgconfig.DisableHTTP2AnnotationKey:                      "false",
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "100",
+		},
+	}, {
+		name: "HTTP/2 disabled",
+		annotations: map[string]string{
+			pkgconfig.DisableHTTP2AnnotationKey:                      "true",
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "100",
+			pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey:       "true",
+		},
+		wantAnnotations: map[string]string{
+			pkgconfig.DisableHTTP2AnnotationKey:                "true",
+			pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey: "true",
+		},
+		wantConflicts: []string{
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey + " is ignored in favor of " + pkgconfig.DisableHTTP2AnnotationKey,
+		},
+	}, {
+		name: "TLS passthrough",
+		annotations: map[string]string{
+			pkgconfig.TLSPassthroughAnnotationKey:                    "true",
+			pkgconfig.DisableHTTP2AnnotationKey:                      "true",
+			pkgconfig.IdleTimeoutAnnotationKey:                       "1h",
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "100",
+		},
+		wantAnnotations: map[string]string{
+			pkgconfig.TLSPassthroughAnnotationKey: "true",
+			pkgconfig.DisableHTTP2AnnotationKey:   "true",
+		},
+		wantConflicts: []string{
+			pkgconfig.IdleTimeoutAnnotationKey + " is ignored in favor of " + pkgconfig.TLSPassthroughAnnotationKey,
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey + " is ignored in favor of " + pkgconfig.TLSPassthroughAnnotationKey,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+				ing.Annotations = test.annotations
+			})
+			original := in.DeepCopy()
+
+			got, conflicts := resolveAnnotationConflicts(in)
+			assert.DeepEqual(t, got.Annotations, test.wantAnnotations)
+			assert.DeepEqual(t, conflicts, test.wantConflicts)
+
+			// The given Ingress is left as it is.
+			assert.DeepEqual(t, in, original)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Well-known appProtocol values of Service ports.
+// Ref: https://kubernetes.io/docs/concepts/services-networking/service/#application-protocol
+const (
+	appProtocolH2C  = "kubernetes.io/h2c"
+	appProtocolWS   = "kubernetes.io/ws"
+	appProtocolWSS  = "kubernetes.io/wss"
+	appProtocolGRPC = "grpc"
+)
+
+// isHTTP2AppProtocol returns whether the given appProtocol indicates that the port
+// serves HTTP/2 and whether the appProtocol is known at all. WebSockets require
+// HTTP/1.1 to upgrade the connection.
+func isHTTP2AppProtocol(appProtocol *string) (http2 bool, known bool) {
+	if appProtocol == nil {
+		return false, false
+	}
+	switch strings.ToLower(*appProtocol) {
+	case appProtocolH2C, appProtocolGRPC, "h2c", "http2":
+		return true, true
+	case appProtocolWS, appProtocolWSS, "http", "ws", "wss":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// isHTTP2PortName returns whether the given port name indicates that the port serves
+// HTTP/2. This is the legacy detection, used if the port doesn't specify a known
+// appProtocol.
+func isHTTP2PortName(name string) bool {
+	return name == "http2" || name == "h2c"
+}
+
+// isHTTP2Service returns whether the target port of the service serves HTTP/2. The
+// appProtocol of the target port is the primary signal. Port names, of any port, are
+// used as a fallback.
+func isHTTP2Service(ports []corev1.ServicePort, targetPort *corev1.ServicePort) bool {
+	if targetPort != nil {
+		if http2, known := isHTTP2AppProtocol(targetPort.AppProtocol); known {
+			return http2
+		}
+	}
+	for _, port := range ports {
+		if isHTTP2PortName(port.Name) {
+			return true
+		}
+	}
+	return false
+}
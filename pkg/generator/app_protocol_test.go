@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/ptr"
+)
+
+func TestIsHTTP2Service(t *testing.T) {
+	tests := []struct {
+		name       string
+		ports      []corev1.ServicePort
+		targetPort int
+		want       bool
+	}{{
+		name:  "plain port",
+		ports: []corev1.ServicePort{{Name: "http"}},
+	}, {
+		name:  "h2c app protocol",
+		ports: []corev1.ServicePort{{Name: "http", AppProtocol: ptr.String("kubernetes.io/h2c")}},
+		want:  true,
+	}, {
+		name:  "grpc app protocol",
+		ports: []corev1.ServicePort{{Name: "web", AppProtocol: ptr.String("grpc")}},
+		want:  true,
+	}, {
+		name:  "websocket app protocol takes precedence over the port name",
+		ports: []corev1.ServicePort{{Name: "http2", AppProtocol: ptr.String("kubernetes.io/ws")}},
+	}, {
+		name:  "http2 port name",
+		ports: []corev1.ServicePort{{Name: "http2"}},
+		want:  true,
+	}, {
+		name:  "h2c port name with unknown app protocol",
+		ports: []corev1.ServicePort{{Name: "h2c", AppProtocol: ptr.String("example.com/custom")}},
+		want:  true,
+	}, {
+		name:       "port name of another port",
+		ports:      []corev1.ServicePort{{Name: "http"}, {Name: "http2"}},
+		targetPort: 0,
+		want:       true,
+	}, {
+		name:       "no target port",
+		ports:      []corev1.ServicePort{{Name: "web", AppProtocol: ptr.String("kubernetes.io/h2c")}},
+		targetPort: -1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var targetPort *corev1.ServicePort
+			if test.targetPort >= 0 {
+				targetPort = &test.ports[test.targetPort]
+			}
+			if got := isHTTP2Service(test.ports, targetPort); got != test.want {
+				t.Errorf("isHTTP2Service() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
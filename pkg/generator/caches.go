@@ -19,17 +19,20 @@ package generator
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	httpconnmanagerv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -45,22 +48,46 @@ const (
 	envCertsSecretName         = "CERTS_SECRET_NAME"
 	certFieldInSecret          = "tls.crt"
 	keyFieldInSecret           = "tls.key"
+	caFieldInSecret            = "ca.crt"
 	externalRouteConfigName    = "external_services"
 	externalTLSRouteConfigName = "external_tls_services"
 	internalRouteConfigName    = "internal_services"
 	isolationRouteConfigName   = "isolation_services"
 	internalTLSRouteConfigName = "internal_tls_services"
+	poolRouteConfigName        = "pool_services"
+	probeRouteConfigName       = "probe_services"
 )
 
 // ErrDomainConflict is an error produces when two ingresses have conflicting domains.
 var ErrDomainConflict = errors.New("ingress has a conflicting domain with another ingress")
 
+// ErrInvalidListenerPort is an error produced when the namespace of an ingress assigns
+// an invalid listener port to it.
+var ErrInvalidListenerPort = errors.New("invalid listener port")
+
+// ErrInvalidTLSPassthrough is an error produced when an ingress passing its TLS
+// connections through can't be routed by their SNI alone.
+var ErrInvalidTLSPassthrough = errors.New("invalid TLS passthrough")
+
+// ErrInvalidListenerPool is an error produced when an ingress is assigned to a listener
+// pool which doesn't exist or whose requirements it doesn't meet.
+var ErrInvalidListenerPool = errors.New("invalid listener pool")
+
 type Caches struct {
 	mu                  sync.Mutex
 	translatedIngresses map[types.NamespacedName]*translatedIngress
 	clusters            *ClustersCache
-	domainsInUse        sets.String
-	statusVirtualHost   *route.VirtualHost
+	// domainsInUse counts the ingresses using each domain.
+	domainsInUse      map[string]int
+	statusVirtualHost *route.VirtualHost
+	// endpoints are the latest Endpoints the clusters were updated with in place, keyed
+	// by the name of the clusters.
+	endpoints map[string]*corev1.Endpoints
+	// podGetter gets the pods behind the Endpoints. It's optional.
+	podGetter func(ns, name string) (*corev1.Pod, error)
+	// staleSince are the times since which the ingresses failing to be translated due to
+	// transient errors are served from their last successful translation.
+	staleSince map[types.NamespacedName]time.Time
 
 	kubeClient kubeclient.Interface
 }
@@ -68,14 +95,26 @@ type Caches struct {
 type portVHost struct {
 	port  string
 	vhost []*route.VirtualHost
+	// listener is the dedicated listener of the namespace using the port, if any.
+	listener *dedicatedListener
+}
+
+// poolVHosts are the external virtual hosts and SNI matches of the ingresses assigned
+// to a listener pool.
+type poolVHosts struct {
+	vhosts    []*route.VirtualHost
+	tlsVHosts []*route.VirtualHost
+	snis      sniMatches
 }
 
 func NewCaches(ctx context.Context, kubernetesClient kubeclient.Interface, extAuthz bool) (*Caches, error) {
 	c := &Caches{
 		translatedIngresses: make(map[types.NamespacedName]*translatedIngress),
 		clusters:            newClustersCache(),
-		domainsInUse:        sets.NewString(),
+		domainsInUse:        make(map[string]int),
 		statusVirtualHost:   statusVHost(),
+		endpoints:           make(map[string]*corev1.Endpoints),
+		staleSince:          make(map[types.NamespacedName]time.Time),
 		kubeClient:          kubernetesClient,
 	}
 
@@ -92,29 +131,63 @@ func (caches *Caches) UpdateIngress(ctx context.Context, ingressTranslation *tra
 	defer caches.mu.Unlock()
 
 	caches.deleteTranslatedIngress(ingressTranslation.name.Name, ingressTranslation.name.Namespace)
-	return caches.addTranslatedIngress(ingressTranslation)
+	return caches.addTranslatedIngress(ingressTranslation, rconfig.FromContextOrDefaults(ctx).Kourier.MergeSharedHosts)
 }
 
-func (caches *Caches) validateIngress(translatedIngress *translatedIngress) error {
+// validateIngress checks that the hosts of the ingress are not in use by another one.
+// If mergeSharedHosts is set, hosts may be shared as long as the routes of the ingresses
+// don't match the same requests.
+func (caches *Caches) validateIngress(translatedIngress *translatedIngress, mergeSharedHosts bool) error {
 	for _, vhost := range translatedIngress.internalVirtualHosts {
-		if caches.domainsInUse.HasAny(vhost.Domains...) {
+		if !caches.anyDomainInUse(vhost.Domains) {
+			continue
+		}
+		if !mergeSharedHosts || caches.hasConflictingRoutes(translatedIngress.name, vhost) ||
+			caches.anyPassthroughHost(translatedIngress.name, vhost.Domains) ||
+			caches.anyRedirectedDomain(translatedIngress.name, vhost.Domains) {
 			return ErrDomainConflict
 		}
 	}
+	// The SNI of the passed through connections can only be matched by one filter chain.
+	if caches.anyDomainInUse(passthroughHosts(translatedIngress.passthroughMatches)) {
+		return ErrDomainConflict
+	}
+	// The redirected hosts are redirected as a whole, so they can't be shared either.
+	if caches.anyDomainInUse(translatedIngress.redirectedDomains) {
+		return ErrDomainConflict
+	}
 
-	return nil
+	return caches.listenerPortConflict(translatedIngress)
+}
+
+func (caches *Caches) anyDomainInUse(domains []string) bool {
+	for _, domain := range domains {
+		if caches.domainsInUse[domain] > 0 {
+			return true
+		}
+	}
+	return false
 }
 
-func (caches *Caches) addTranslatedIngress(translatedIngress *translatedIngress) error {
-	if err := caches.validateIngress(translatedIngress); err != nil {
+func (caches *Caches) addTranslatedIngress(translatedIngress *translatedIngress, mergeSharedHosts bool) error {
+	if err := caches.validateIngress(translatedIngress, mergeSharedHosts); err != nil {
 		return err
 	}
 
 	for _, vhost := range translatedIngress.internalVirtualHosts {
-		caches.domainsInUse.Insert(vhost.Domains...)
+		for _, domain := range vhost.Domains {
+			caches.domainsInUse[domain]++
+		}
+	}
+	for _, host := range passthroughHosts(translatedIngress.passthroughMatches) {
+		caches.domainsInUse[host]++
+	}
+	for _, domain := range translatedIngress.redirectedDomains {
+		caches.domainsInUse[domain]++
 	}
 
 	caches.translatedIngresses[translatedIngress.name] = translatedIngress
+	caches.applyLatestEndpoints(translatedIngress)
 
 	for _, cluster := range translatedIngress.clusters {
 		caches.clusters.set(cluster, translatedIngress.name.Name, translatedIngress.name.Namespace)
@@ -134,59 +207,176 @@ func (caches *Caches) SetOnEvicted(f func(types.NamespacedName, interface{})) {
 	})
 }
 
+// ToEnvoySnapshot creates the snapshot of the shared gateways.
 func (caches *Caches) ToEnvoySnapshot(ctx context.Context) (*cache.Snapshot, error) {
 	caches.mu.Lock()
 	defer caches.mu.Unlock()
 
+	return caches.envoySnapshot(ctx, "")
+}
+
+// ToEnvoySnapshots creates the snapshots of the shared gateways and of all configured
+// gateway fleets, keyed by their xDS node ID.
+func (caches *Caches) ToEnvoySnapshots(ctx context.Context) (map[string]*cache.Snapshot, error) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	fleets := rconfig.FromContextOrDefaults(ctx).Kourier.GatewayFleets
+	snapshots := make(map[string]*cache.Snapshot, len(fleets)+1)
+
+	snapshot, err := caches.envoySnapshot(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	snapshots[config.GatewayNodeID] = snapshot
+
+	for name, fleet := range fleets {
+		snapshot, err := caches.envoySnapshot(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the snapshot of gateway fleet %q: %w", name, err)
+		}
+		snapshots[fleet.NodeID] = snapshot
+	}
+	return snapshots, nil
+}
+
+// envoySnapshot creates the snapshot of the given gateway fleet, serving only the
+// ingresses assigned to it. The empty fleet is the one of the shared gateways.
+func (caches *Caches) envoySnapshot(ctx context.Context, fleet string) (*cache.Snapshot, error) {
 	localVHosts := make([]*route.VirtualHost, 0, len(caches.translatedIngresses)+1)
 	externalVHosts := make([]*route.VirtualHost, 0, len(caches.translatedIngresses))
 	externalTLSVHosts := make([]*route.VirtualHost, 0, len(caches.translatedIngresses))
 	snis := sniMatches{}
+	upstreamClientSecrets := make(map[string]*tls.Secret)
+	var passthroughMatches []*envoy.PassthroughMatch
+	var wasmExtensions []*envoy.WasmExtension
 
 	localVHostsPerListener := make(map[string]portVHost)
+	externalVHostsPerPool := make(map[string]*poolVHosts)
 
 	for _, translatedIngress := range caches.translatedIngresses {
+		if translatedIngress.gatewayFleet != fleet {
+			continue
+		}
+
 		if translatedIngress.listenerPort != "" {
-			localVHostsPerListener[translatedIngress.listenerPort] = portVHost{
-				port:  translatedIngress.listenerPort,
-				vhost: append(localVHostsPerListener[translatedIngress.listenerPort].vhost, translatedIngress.internalVirtualHosts...),
+			portVHosts := localVHostsPerListener[translatedIngress.listenerPort]
+			portVHosts.port = translatedIngress.listenerPort
+			portVHosts.vhost = append(portVHosts.vhost, translatedIngress.internalVirtualHosts...)
+			if translatedIngress.dedicatedListener != nil {
+				// A dedicated listener's port is only used by its namespace, whose
+				// annotations all its ingresses are translated from.
+				portVHosts.listener = translatedIngress.dedicatedListener
 			}
+			localVHostsPerListener[translatedIngress.listenerPort] = portVHosts
 		} else {
 			localVHosts = append(localVHosts, translatedIngress.internalVirtualHosts...)
 		}
 
-		externalVHosts = append(externalVHosts, translatedIngress.externalVirtualHosts...)
-		externalTLSVHosts = append(externalTLSVHosts, translatedIngress.externalTLSVirtualHosts...)
+		if pool := translatedIngress.listenerPool; pool != "" {
+			// The external hosts of ingresses assigned to a pool are only served by the
+			// pool's listener.
+			if externalVHostsPerPool[pool] == nil {
+				externalVHostsPerPool[pool] = &poolVHosts{snis: sniMatches{}}
+			}
+			poolHosts := externalVHostsPerPool[pool]
+			poolHosts.vhosts = append(poolHosts.vhosts, translatedIngress.externalVirtualHosts...)
+			poolHosts.tlsVHosts = append(poolHosts.tlsVHosts, translatedIngress.externalTLSVirtualHosts...)
+			for _, match := range translatedIngress.sniMatches {
+				poolHosts.snis.consume(match)
+			}
+		} else {
+			externalVHosts = append(externalVHosts, translatedIngress.externalVirtualHosts...)
+			externalTLSVHosts = append(externalTLSVHosts, translatedIngress.externalTLSVirtualHosts...)
 
-		for _, match := range translatedIngress.sniMatches {
-			snis.consume(match)
+			for _, match := range translatedIngress.sniMatches {
+				snis.consume(match)
+			}
+		}
+		passthroughMatches = append(passthroughMatches, translatedIngress.passthroughMatches...)
+		if extension := translatedIngress.wasmExtension; extension != nil && len(extension.Domains) != 0 {
+			wasmExtensions = append(wasmExtensions, extension)
+		}
+		if secret := translatedIngress.upstreamClientSecret; secret != nil {
+			upstreamClientSecrets[secret.Name] = secret
 		}
 	}
 
 	// Append the statusHost too.
 	localVHosts = append(localVHosts, caches.statusVirtualHost)
 
+	sniMatches := snis.list()
 	listeners, routes, err := generateListenersAndRouteConfigs(
 		ctx,
 		externalVHosts,
 		externalTLSVHosts,
 		localVHosts,
 		localVHostsPerListener,
-		snis.list(),
+		sniMatches,
+		passthroughMatches,
+		externalVHostsPerPool,
 		caches.kubeClient,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err := addWasmExtensions(listeners, wasmExtensions); err != nil {
+		return nil, err
+	}
 
-	return cache.NewSnapshot(
-		uuid.NewString(),
-		map[resource.Type][]cachetypes.Resource{
-			resource.ClusterType:  caches.clusters.list(),
-			resource.RouteType:    routes,
-			resource.ListenerType: listeners,
-		},
-	)
+	// The SNI matches of the pools need their secrets just as well.
+	allSNIMatches := append([]*envoy.SNIMatch{}, sniMatches...)
+	for _, pool := range sets.StringKeySet(externalVHostsPerPool).List() {
+		allSNIMatches = append(allSNIMatches, externalVHostsPerPool[pool].snis.list()...)
+	}
+
+	// The certificates of the SNI matches and the upstream client certificates are
+	// delivered via SDS.
+	secrets := make([]cachetypes.Resource, 0, len(allSNIMatches)+len(upstreamClientSecrets))
+	secretNames := sets.NewString()
+	for _, match := range allSNIMatches {
+		name := envoy.SecretName(match.CertSource)
+		if secretNames.Has(name) {
+			// Matches only differing in their client validation share the secret.
+			continue
+		}
+		secretNames.Insert(name)
+		secrets = append(secrets, envoy.NewSecret(name, match.CertificateChain, match.PrivateKey))
+	}
+	for _, name := range sets.StringKeySet(upstreamClientSecrets).List() {
+		if secretNames.Has(name) {
+			// The certificate is served for SNI already.
+			continue
+		}
+		secretNames.Insert(name)
+		secrets = append(secrets, upstreamClientSecrets[name])
+	}
+
+	resources := map[resource.Type][]cachetypes.Resource{
+		resource.ClusterType:  caches.fleetClusters(fleet),
+		resource.RouteType:    routes,
+		resource.ListenerType: listeners,
+		resource.SecretType:   secrets,
+		// The runtime layer is served even without overrides, as the gateways wait for
+		// it on startup.
+		resource.RuntimeType: {envoy.NewRuntime(config.RuntimeLayerName, runtimeValues(rconfig.FromContextOrDefaults(ctx).Kourier))},
+	}
+	if rconfig.FromContextOrDefaults(ctx).Kourier.VirtualHostDiscovery {
+		var ttls map[string]time.Duration
+		resources[resource.RouteType], resources[resource.VirtualHostType], ttls = withVirtualHostDiscovery(routes, caches.hostTTLs(fleet))
+		return newSnapshotWithTTLs(resources, ttls)
+	}
+	return newSnapshot(resources)
+}
+
+// fleetClusters lists the clusters of the ingresses assigned to the given gateway fleet.
+// The clusters of deleted ingresses, kept for a while, and the ones not belonging to
+// any ingress are listed for all fleets.
+func (caches *Caches) fleetClusters(fleet string) []cachetypes.Resource {
+	return caches.clusters.listFor(func(ingress types.NamespacedName) bool {
+		translated, ok := caches.translatedIngresses[ingress]
+		return !ok || translated.gatewayFleet == fleet
+	})
 }
 
 // DeleteIngressInfo removes an ingress from the caches.
@@ -214,11 +404,26 @@ func (caches *Caches) deleteTranslatedIngress(ingressName, ingressNamespace stri
 		}
 
 		for _, vhost := range translated.internalVirtualHosts {
-			caches.domainsInUse.Delete(vhost.Domains...)
+			for _, domain := range vhost.Domains {
+				if caches.domainsInUse[domain]--; caches.domainsInUse[domain] <= 0 {
+					delete(caches.domainsInUse, domain)
+				}
+			}
+		}
+		for _, host := range passthroughHosts(translated.passthroughMatches) {
+			if caches.domainsInUse[host]--; caches.domainsInUse[host] <= 0 {
+				delete(caches.domainsInUse, host)
+			}
+		}
+		for _, domain := range translated.redirectedDomains {
+			if caches.domainsInUse[domain]--; caches.domainsInUse[domain] <= 0 {
+				delete(caches.domainsInUse, domain)
+			}
 		}
 
 		delete(caches.translatedIngresses, key)
 	}
+	delete(caches.staleSince, key)
 }
 
 func generateListenersAndRouteConfigs(
@@ -228,6 +433,8 @@ func generateListenersAndRouteConfigs(
 	clusterLocalVirtualHosts []*route.VirtualHost,
 	clusterLocalVirtualHostsPerListener map[string]portVHost,
 	sniMatches []*envoy.SNIMatch,
+	passthroughMatches []*envoy.PassthroughMatch,
+	externalVirtualHostsPerPool map[string]*poolVHosts,
 	kubeclient kubeclient.Interface) ([]cachetypes.Resource, []cachetypes.Resource, error) {
 
 	// This has to be "OrDefaults" because this path is called before the informers are
@@ -236,24 +443,34 @@ func generateListenersAndRouteConfigs(
 	cfg := rconfig.FromContextOrDefaults(ctx)
 
 	// First, we save the RouteConfigs with the proper name and all the virtualhosts etc. into the cache.
-	externalRouteConfig := envoy.NewRouteConfig(externalRouteConfigName, externalVirtualHosts)
-	externalTLSRouteConfig := envoy.NewRouteConfig(externalTLSRouteConfigName, externalTLSVirtualHosts)
-	internalRouteConfig := envoy.NewRouteConfig(internalRouteConfigName, clusterLocalVirtualHosts)
+	externalRouteConfig := newRouteConfig(externalRouteConfigName, withUnmatchedHost(externalVirtualHosts, cfg.Kourier), cfg.Kourier)
+	externalTLSRouteConfig := newRouteConfig(externalTLSRouteConfigName, withUnmatchedHost(externalTLSVirtualHosts, cfg.Kourier), cfg.Kourier)
+	internalRouteConfig := newRouteConfig(internalRouteConfigName, clusterLocalVirtualHosts, cfg.Kourier)
 
 	internalListenersRouteConfig := make(map[string]*route.RouteConfiguration, len(clusterLocalVirtualHostsPerListener))
 	for listenerPort, portVhosts := range clusterLocalVirtualHostsPerListener {
 		routeName := isolationRouteConfigName + "_" + listenerPort
-		internalListenersRouteConfig[listenerPort] = envoy.NewRouteConfig(routeName, portVhosts.vhost)
+		internalListenersRouteConfig[listenerPort] = newRouteConfig(routeName, portVhosts.vhost, cfg.Kourier)
 	}
 
 	// Now we setup connection managers, that reference the routeconfigs via RDS.
 	externalManager := envoy.NewHTTPConnectionManager(externalRouteConfig.Name, cfg.Kourier)
 	externalTLSManager := envoy.NewHTTPConnectionManager(externalTLSRouteConfig.Name, cfg.Kourier)
+	envoy.SetHeaderMetadata(externalManager, cfg.Kourier.HeaderMetadata)
+	envoy.SetHeaderMetadata(externalTLSManager, cfg.Kourier.HeaderMetadata)
+	envoy.SetErrorPage(externalManager, cfg.Kourier.ErrorPageBody, cfg.Kourier.ErrorPageContentType)
+	envoy.SetErrorPage(externalTLSManager, cfg.Kourier.ErrorPageBody, cfg.Kourier.ErrorPageContentType)
 	internalManager := envoy.NewHTTPConnectionManager(internalRouteConfig.Name, cfg.Kourier)
+	envoy.SetErrorPage(internalManager, cfg.Kourier.InternalErrorPageBody, cfg.Kourier.InternalErrorPageContentType)
+	restrictInternalUpgrades(internalManager, cfg.Kourier)
 
 	internalListenerManagers := make(map[string]*httpconnmanagerv3.HttpConnectionManager, len(internalListenersRouteConfig))
 	for listenerPort, internalListenerRouteConfig := range internalListenersRouteConfig {
-		internalListenerManagers[listenerPort] = envoy.NewHTTPConnectionManager(internalListenerRouteConfig.Name, cfg.Kourier)
+		listener := clusterLocalVirtualHostsPerListener[listenerPort].listener
+		manager := listener.connectionManager(internalListenerRouteConfig.Name, cfg.Kourier)
+		envoy.SetErrorPage(manager, cfg.Kourier.InternalErrorPageBody, cfg.Kourier.InternalErrorPageContentType)
+		restrictInternalUpgrades(manager, cfg.Kourier)
+		internalListenerManagers[listenerPort] = manager
 	}
 
 	externalHTTPEnvoyListener, err := envoy.NewHTTPListener(externalManager, config.HTTPPortExternal, cfg.Kourier.EnableProxyProtocol)
@@ -274,7 +491,7 @@ func generateListenersAndRouteConfigs(
 			return nil, nil, err
 		}
 
-		envoyListener, err := envoy.NewHTTPListener(internalListenerManagers[listenerPort], uint32(port), false)
+		envoyListener, err := portVhosts.listener.listener(internalListenerManagers[listenerPort], uint32(port))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -282,8 +499,30 @@ func generateListenersAndRouteConfigs(
 		routes = append(routes, internalListenersRouteConfig[listenerPort])
 	}
 
+	poolListeners, poolRoutes, err := generatePoolListenersAndRouteConfigs(externalVirtualHostsPerPool, cfg.Kourier)
+	if err != nil {
+		return nil, nil, err
+	}
+	listeners = append(listeners, poolListeners...)
+	routes = append(routes, poolRoutes...)
+
+	// The probe listeners serve the hosts of the pools too, as the prober is not
+	// necessarily allowed to connect to the pools' listeners.
+	probeManager := externalManager
+	probeSNIMatches := append([]*envoy.SNIMatch{}, sniMatches...)
+	if len(externalVirtualHostsPerPool) != 0 {
+		probeVirtualHosts := append([]*route.VirtualHost{}, externalVirtualHosts...)
+		for _, pool := range sets.StringKeySet(externalVirtualHostsPerPool).List() {
+			probeVirtualHosts = append(probeVirtualHosts, externalVirtualHostsPerPool[pool].vhosts...)
+			probeSNIMatches = append(probeSNIMatches, externalVirtualHostsPerPool[pool].snis.list()...)
+		}
+		probeRouteConfig := newRouteConfig(probeRouteConfigName, probeVirtualHosts, cfg.Kourier)
+		probeManager = envoy.NewHTTPConnectionManager(probeRouteConfig.Name, cfg.Kourier)
+		routes = append(routes, probeRouteConfig)
+	}
+
 	// create probe listeners
-	probHTTPListener, err := envoy.NewHTTPListener(externalManager, config.HTTPPortProb, false)
+	probHTTPListener, err := envoy.NewHTTPListener(probeManager, config.HTTPPortProb, false)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -291,8 +530,10 @@ func generateListenersAndRouteConfigs(
 
 	// Add internal listeners and routes when internal cert secret is specified.
 	if cfg.Kourier.ClusterCertSecret != "" {
-		internalTLSRouteConfig := envoy.NewRouteConfig(internalTLSRouteConfigName, clusterLocalVirtualHosts)
+		internalTLSRouteConfig := newRouteConfig(internalTLSRouteConfigName, clusterLocalVirtualHosts, cfg.Kourier)
 		internalTLSManager := envoy.NewHTTPConnectionManager(internalTLSRouteConfig.Name, cfg.Kourier)
+		envoy.SetErrorPage(internalTLSManager, cfg.Kourier.InternalErrorPageBody, cfg.Kourier.InternalErrorPageContentType)
+		restrictInternalUpgrades(internalTLSManager, cfg.Kourier)
 
 		internalHTTPSEnvoyListener, err := newInternalEnvoyListenerWithOneCert(
 			ctx, internalTLSManager, kubeclient,
@@ -312,25 +553,26 @@ func generateListenersAndRouteConfigs(
 	// TLS field, that takes precedence. If there is not, TLS will be configured
 	// using a single cert for all the services if the creds are given via ENV.
 	if len(sniMatches) > 0 {
+		tlsParams := envoy.NewTLSParameters(cfg.Kourier)
 		externalHTTPSEnvoyListener, err := envoy.NewHTTPSListenerWithSNI(
 			externalTLSManager, config.HTTPSPortExternal,
-			sniMatches, cfg.Kourier.EnableProxyProtocol,
+			sniMatches, cfg.Kourier.EnableProxyProtocol, tlsParams,
 		)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		// create https prob listener with SNI
+		// create https prob listener with SNI. The prober doesn't present client certificates.
 		probHTTPSListener, err := envoy.NewHTTPSListenerWithSNI(
-			externalManager, config.HTTPSPortProb,
-			sniMatches, false,
+			probeManager, config.HTTPSPortProb,
+			withoutClientValidation(probeSNIMatches), false, tlsParams,
 		)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		// if a certificate is configured, add a new filter chain to TLS listener
-		if useHTTPSListenerWithOneCert() {
+		if useHTTPSListenerWithOneCert(ctx) {
 			externalHTTPSEnvoyListenerWithOneCertFilterChain, err := newExternalEnvoyListenerWithOneCertFilterChain(
 				ctx, externalTLSManager, kubeclient,
 			)
@@ -344,9 +586,15 @@ func generateListenersAndRouteConfigs(
 				externalHTTPSEnvoyListenerWithOneCertFilterChain)
 		}
 
+		if cfg.Kourier.HTTPSPlaintextFallback {
+			// Serve the plaintext requests sent to the HTTPS port like the HTTP ones.
+			if err := envoy.AddPlaintextFallbackFilterChain(externalHTTPSEnvoyListener, externalManager); err != nil {
+				return nil, nil, err
+			}
+		}
 		listeners = append(listeners, externalHTTPSEnvoyListener, probHTTPSListener)
 		routes = append(routes, externalTLSRouteConfig)
-	} else if useHTTPSListenerWithOneCert() {
+	} else if useHTTPSListenerWithOneCert(ctx) {
 		externalHTTPSEnvoyListener, err := newExternalEnvoyListenerWithOneCert(
 			ctx, externalTLSManager, kubeclient,
 			cfg.Kourier.EnableProxyProtocol,
@@ -361,17 +609,86 @@ func generateListenersAndRouteConfigs(
 			return nil, nil, err
 		}
 
+		if cfg.Kourier.HTTPSPlaintextFallback {
+			// Serve the plaintext requests sent to the HTTPS port like the HTTP ones.
+			if err := envoy.AddPlaintextFallbackFilterChain(externalHTTPSEnvoyListener, externalManager); err != nil {
+				return nil, nil, err
+			}
+		}
 		listeners = append(listeners, externalHTTPSEnvoyListener, probHTTPSListener)
 		routes = append(routes, externalTLSRouteConfig)
+	} else if len(probeSNIMatches) > 0 {
+		// Only the pools have TLS hosts, which still have to be probed.
+		probHTTPSListener, err := envoy.NewHTTPSListenerWithSNI(
+			probeManager, config.HTTPSPortProb,
+			withoutClientValidation(probeSNIMatches), false, envoy.NewTLSParameters(cfg.Kourier),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		listeners = append(listeners, probHTTPSListener)
+	}
+
+	listeners, err = addPassthroughFilterChains(listeners, passthroughMatches, cfg.Kourier.EnableProxyProtocol)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return listeners, routes, nil
 }
 
+// generatePoolListenersAndRouteConfigs generates a listener and route config for every
+// listener pool with assigned ingresses. The listeners only accept connections from the
+// pool's allowed source CIDRs and serve either HTTPS or plain HTTP.
+func generatePoolListenersAndRouteConfigs(externalVirtualHostsPerPool map[string]*poolVHosts, cfg *config.Kourier) ([]cachetypes.Resource, []cachetypes.Resource, error) {
+	listeners := make([]cachetypes.Resource, 0, len(externalVirtualHostsPerPool))
+	routes := make([]cachetypes.Resource, 0, len(externalVirtualHostsPerPool))
+	for _, name := range sets.StringKeySet(externalVirtualHostsPerPool).List() {
+		pool, ok := cfg.ListenerPools[name]
+		if !ok {
+			// The pool has been removed, its ingresses are rejected once re-translated.
+			continue
+		}
+		poolHosts := externalVirtualHostsPerPool[name]
+
+		var (
+			poolListener *v3.Listener
+			routeConfig  *route.RouteConfiguration
+			err          error
+		)
+		if pool.RequireTLS {
+			routeConfig = newRouteConfig(poolRouteConfigName+"_"+name, withUnmatchedHost(poolHosts.tlsVHosts, cfg), cfg)
+			manager := envoy.NewHTTPConnectionManager(routeConfig.Name, cfg)
+			envoy.SetHeaderMetadata(manager, cfg.HeaderMetadata)
+			envoy.SetErrorPage(manager, cfg.ErrorPageBody, cfg.ErrorPageContentType)
+			poolListener, err = envoy.NewHTTPSListenerWithSNI(
+				manager, pool.Port, poolHosts.snis.list(), cfg.EnableProxyProtocol, envoy.NewTLSParameters(cfg),
+			)
+		} else {
+			routeConfig = newRouteConfig(poolRouteConfigName+"_"+name, withUnmatchedHost(poolHosts.vhosts, cfg), cfg)
+			manager := envoy.NewHTTPConnectionManager(routeConfig.Name, cfg)
+			envoy.SetHeaderMetadata(manager, cfg.HeaderMetadata)
+			envoy.SetErrorPage(manager, cfg.ErrorPageBody, cfg.ErrorPageContentType)
+			poolListener, err = envoy.NewHTTPListener(manager, pool.Port, cfg.EnableProxyProtocol)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := envoy.SetSourcePrefixRanges(poolListener, pool.AllowedSourceCIDRs); err != nil {
+			return nil, nil, err
+		}
+
+		listeners = append(listeners, poolListener)
+		routes = append(routes, routeConfig)
+	}
+	return listeners, routes, nil
+}
+
 // Returns true if we need to modify the HTTPS listener with just one cert
 // instead of one per ingress
-func useHTTPSListenerWithOneCert() bool {
-	return os.Getenv(envCertsSecretNamespace) != "" &&
+func useHTTPSListenerWithOneCert(ctx context.Context) bool {
+	return !rconfig.FromContextOrDefaults(ctx).Kourier.DisableTLSTermination &&
+		os.Getenv(envCertsSecretNamespace) != "" &&
 		os.Getenv(envCertsSecretName) != ""
 }
 
@@ -415,3 +732,11 @@ func newInternalEnvoyListenerWithOneCert(ctx context.Context, manager *httpconnm
 	}
 	return envoy.NewHTTPSListener(config.HTTPSPortInternal, []*v3.FilterChain{filterChain}, enableProxyProtocol)
 }
+
+// restrictInternalUpgrades makes the connection manager of an internal listener only
+// accept the configured upgrades, if the upgrade protection is enabled.
+func restrictInternalUpgrades(manager *httpconnmanagerv3.HttpConnectionManager, kourierConfig *config.Kourier) {
+	if kourierConfig.InternalUpgradeProtection {
+		envoy.SetAllowedUpgrades(manager, kourierConfig.InternalUpgrades())
+	}
+}
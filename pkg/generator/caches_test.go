@@ -24,6 +24,8 @@ import (
 	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	runtime "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -185,7 +187,7 @@ func TestTLSListenerWithEnvCertsSecret(t *testing.T) {
 		translatedIngress := &translatedIngress{
 			sniMatches: nil,
 		}
-		err := caches.addTranslatedIngress(translatedIngress)
+		err := caches.addTranslatedIngress(translatedIngress, false)
 		assert.NilError(t, err)
 
 		snapshot, err := caches.ToEnvoySnapshot(ctx)
@@ -200,7 +202,7 @@ func TestTLSListenerWithEnvCertsSecret(t *testing.T) {
 		translatedIngress := &translatedIngress{
 			sniMatches: []*envoy.SNIMatch{fooSNIMatch},
 		}
-		err := caches.addTranslatedIngress(translatedIngress)
+		err := caches.addTranslatedIngress(translatedIngress, false)
 		assert.NilError(t, err)
 
 		snapshot, err := caches.ToEnvoySnapshot(ctx)
@@ -227,7 +229,7 @@ func TestTLSListenerWithEnvCertsSecret(t *testing.T) {
 		translatedIngress := &translatedIngress{
 			sniMatches: []*envoy.SNIMatch{fooSNIMatch, barSNIMatch},
 		}
-		err := caches.addTranslatedIngress(translatedIngress)
+		err := caches.addTranslatedIngress(translatedIngress, false)
 		assert.NilError(t, err)
 
 		snapshot, err := caches.ToEnvoySnapshot(ctx)
@@ -249,6 +251,12 @@ func TestTLSListenerWithEnvCertsSecret(t *testing.T) {
 		assert.Check(t, filterChainsByServerName["foo.example.com"] != nil)
 		assert.Check(t, filterChainsByServerName["bar.example.com"] != nil)
 		assert.Check(t, filterChainsByServerName[""] != nil) // filter chain without server name, "default" one
+
+		// The certificates of the SNI matches are delivered via SDS.
+		secrets := snapshot.GetResources(resource.SecretType)
+		assert.Equal(t, len(secrets), 2)
+		assert.Check(t, secrets["secretns/secretname1"] != nil)
+		assert.Check(t, secrets["secretns/secretname2"] != nil)
 	})
 }
 
@@ -286,7 +294,7 @@ func TestTLSListenerWithInternalCertSecret(t *testing.T) {
 		translatedIngress := &translatedIngress{
 			sniMatches: nil,
 		}
-		err := caches.addTranslatedIngress(translatedIngress)
+		err := caches.addTranslatedIngress(translatedIngress, false)
 		assert.NilError(t, err)
 
 		snapshot, err := caches.ToEnvoySnapshot(ctx)
@@ -325,7 +333,7 @@ func createTestDataForIngress(
 			PrivateKey:       privateKey}},
 	}
 
-	caches.addTranslatedIngress(translatedIngress)
+	caches.addTranslatedIngress(translatedIngress, false)
 }
 
 func TestValidateIngress(t *testing.T) {
@@ -362,7 +370,7 @@ func TestValidateIngress(t *testing.T) {
 			PrivateKey:       privateKey}},
 	}
 
-	err = caches.validateIngress(&translatedIngress)
+	err = caches.validateIngress(&translatedIngress, false)
 	assert.Error(t, err, ErrDomainConflict.Error())
 }
 
@@ -394,7 +402,7 @@ func TestAddIsolatedIngress(t *testing.T) {
 		internalVirtualHosts: []*route.VirtualHost{{Name: "internal_host_for_ingress_2", Domains: []string{"internal_host_for_ingress_1"}}},
 	}
 
-	err = caches.addTranslatedIngress(&translatedIngress)
+	err = caches.addTranslatedIngress(&translatedIngress, false)
 	assert.NilError(t, err)
 
 	snapshot, err := caches.ToEnvoySnapshot(ctx)
@@ -407,3 +415,310 @@ func TestAddIsolatedIngress(t *testing.T) {
 	assert.Assert(t, ok)
 	assert.Equal(t, l.GetAddress().GetSocketAddress().GetPortValue(), uint32(12158))
 }
+
+func TestAddIngressWithListenerPool(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			ListenerPools: map[string]config.ListenerPool{
+				"partner": {Port: 7443, AllowedSourceCIDRs: []string{"10.0.0.0/8"}, RequireTLS: true},
+				"public":  {Port: 7080},
+			},
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	partnerHost := &route.VirtualHost{Name: "partner", Domains: []string{"partner.example.com"}}
+	partnerTLSHost := &route.VirtualHost{Name: "partner_tls", Domains: []string{"partner.example.com"}}
+	publicHost := &route.VirtualHost{Name: "public", Domains: []string{"public.example.com"}}
+	sharedHost := &route.VirtualHost{Name: "shared", Domains: []string{"shared.example.com"}}
+
+	for _, translated := range []*translatedIngress{{
+		name:                    types.NamespacedName{Namespace: "ns", Name: "partner"},
+		listenerPool:            "partner",
+		externalVirtualHosts:    []*route.VirtualHost{partnerHost},
+		externalTLSVirtualHosts: []*route.VirtualHost{partnerTLSHost},
+		sniMatches: []*envoy.SNIMatch{{
+			Hosts:            []string{"partner.example.com"},
+			CertSource:       types.NamespacedName{Namespace: "secretns", Name: "partner"},
+			CertificateChain: []byte("cert"),
+			PrivateKey:       []byte("key"),
+		}},
+	}, {
+		name:                 types.NamespacedName{Namespace: "ns", Name: "public"},
+		listenerPool:         "public",
+		externalVirtualHosts: []*route.VirtualHost{publicHost},
+	}, {
+		name:                 types.NamespacedName{Namespace: "ns", Name: "shared"},
+		externalVirtualHosts: []*route.VirtualHost{sharedHost},
+	}} {
+		assert.NilError(t, caches.addTranslatedIngress(translated, false))
+	}
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	listeners := snapshot.GetResources(resource.ListenerType)
+	routes := snapshot.GetResources(resource.RouteType)
+
+	// The pools' hosts are only served by the pools' listeners.
+	assert.DeepEqual(t, routes[externalRouteConfigName].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{sharedHost}, protocmp.Transform())
+	assert.DeepEqual(t, routes[poolRouteConfigName+"_partner"].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{partnerTLSHost}, protocmp.Transform())
+	assert.DeepEqual(t, routes[poolRouteConfigName+"_public"].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{publicHost}, protocmp.Transform())
+	assert.Check(t, listeners[envoy.CreateListenerName(config.HTTPSPortExternal)] == nil)
+
+	partnerListener := listeners[envoy.CreateListenerName(7443)].(*listener.Listener)
+	assert.Equal(t, len(partnerListener.FilterChains), 1)
+	filterChainMatch := partnerListener.FilterChains[0].FilterChainMatch
+	assert.DeepEqual(t, filterChainMatch.ServerNames, []string{"partner.example.com"})
+	assert.Equal(t, filterChainMatch.SourcePrefixRanges[0].AddressPrefix, "10.0.0.0")
+
+	publicListener := listeners[envoy.CreateListenerName(7080)].(*listener.Listener)
+	assert.Check(t, publicListener.FilterChains[0].FilterChainMatch == nil)
+
+	// The pools' hosts are probed via the probe listeners.
+	probeRoutes := routes[probeRouteConfigName].(*route.RouteConfiguration)
+	assert.DeepEqual(t, probeRoutes.VirtualHosts, []*route.VirtualHost{sharedHost, partnerHost, publicHost}, protocmp.Transform())
+	probeTLSListener := listeners[envoy.CreateListenerName(config.HTTPSPortProb)].(*listener.Listener)
+	assert.DeepEqual(t, probeTLSListener.FilterChains[0].FilterChainMatch.ServerNames, []string{"partner.example.com"})
+
+	secrets := snapshot.GetResources(resource.SecretType)
+	assert.Check(t, secrets["secretns/partner"] != nil)
+}
+
+func TestToEnvoySnapshotWithUnmatchedHost(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			ListenerPools:     map[string]config.ListenerPool{"public": {Port: 7080}},
+			UnmatchedHostBody: "Not here",
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	publicHost := &route.VirtualHost{Name: "public", Domains: []string{"public.example.com"}}
+	sharedHost := &route.VirtualHost{Name: "shared", Domains: []string{"shared.example.com"}}
+	for _, translated := range []*translatedIngress{{
+		name:                 types.NamespacedName{Namespace: "ns", Name: "public"},
+		listenerPool:         "public",
+		externalVirtualHosts: []*route.VirtualHost{publicHost},
+	}, {
+		name:                 types.NamespacedName{Namespace: "ns", Name: "shared"},
+		externalVirtualHosts: []*route.VirtualHost{sharedHost},
+		internalVirtualHosts: []*route.VirtualHost{sharedHost},
+	}} {
+		assert.NilError(t, caches.addTranslatedIngress(translated, false))
+	}
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+	routes := snapshot.GetResources(resource.RouteType)
+
+	// The external route configurations answer the requests matching no host.
+	unmatched := envoy.NewUnmatchedHostVirtualHost(testConfig.Kourier)
+	assert.DeepEqual(t, routes[externalRouteConfigName].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{sharedHost, unmatched}, protocmp.Transform())
+	assert.DeepEqual(t, routes[poolRouteConfigName+"_public"].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{publicHost, unmatched}, protocmp.Transform())
+
+	// The internal and probe route configurations are unchanged.
+	for _, vhost := range routes[internalRouteConfigName].(*route.RouteConfiguration).VirtualHosts {
+		assert.Check(t, vhost.Name != unmatched.Name)
+	}
+	assert.DeepEqual(t, routes[probeRouteConfigName].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{sharedHost, publicHost}, protocmp.Transform())
+}
+
+func TestToEnvoySnapshotWithHeaderMetadata(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			HeaderMetadata: []string{"x-tenant-id=tenant"},
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	// Only the external listeners extract the metadata.
+	listeners := snapshot.GetResources(resource.ListenerType)
+	for port, want := range map[uint32]bool{config.HTTPPortExternal: true, config.HTTPPortInternal: false} {
+		l := listeners[envoy.CreateListenerName(port)].(*listener.Listener)
+		manager := &hcm.HttpConnectionManager{}
+		assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+		assert.Equal(t, manager.HttpFilters[0].Name == "envoy.filters.http.header_to_metadata", want, "port %d", port)
+	}
+}
+
+func TestToEnvoySnapshotWithRuntimeValues(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			RuntimeOverrides:                 map[string]string{"overload.global_downstream_max_connections": "50000"},
+			OverloadMaxDownstreamConnections: 10000,
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	layer := snapshot.GetResources(resource.RuntimeType)[config.RuntimeLayerName].(*runtime.Runtime).Layer
+	// The overrides take precedence over the keys backing other config keys.
+	assert.Equal(t, layer.Fields["overload.global_downstream_max_connections"].GetNumberValue(), float64(50000))
+
+	testConfig.Kourier.RuntimeOverrides = nil
+	snapshot, err = caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+	layer = snapshot.GetResources(resource.RuntimeType)[config.RuntimeLayerName].(*runtime.Runtime).Layer
+	assert.Equal(t, layer.Fields["overload.global_downstream_max_connections"].GetNumberValue(), float64(10000))
+}
+
+func TestToEnvoySnapshotWithHTTPSPlaintextFallback(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			HTTPSPlaintextFallback: true,
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+	assert.NilError(t, caches.addTranslatedIngress(&translatedIngress{
+		name: types.NamespacedName{Namespace: "ns", Name: "foo"},
+		sniMatches: []*envoy.SNIMatch{{
+			Hosts:      []string{"foo.example.com"},
+			CertSource: types.NamespacedName{Namespace: "secretns", Name: "secretname"},
+		}},
+	}, false))
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	// The plaintext connections are served with the routes of the external HTTP listener.
+	tlsListener := snapshot.GetResources(resource.ListenerType)[envoy.CreateListenerName(config.HTTPSPortExternal)].(*listener.Listener)
+	fallback := tlsListener.FilterChains[len(tlsListener.FilterChains)-1]
+	assert.Equal(t, fallback.FilterChainMatch.TransportProtocol, "raw_buffer")
+	manager := &hcm.HttpConnectionManager{}
+	assert.NilError(t, fallback.Filters[0].GetTypedConfig().UnmarshalTo(manager))
+	assert.Equal(t, manager.GetRds().RouteConfigName, externalRouteConfigName)
+
+	// The probe listener isn't affected.
+	probeListener := snapshot.GetResources(resource.ListenerType)[envoy.CreateListenerName(config.HTTPSPortProb)].(*listener.Listener)
+	assert.Equal(t, len(probeListener.FilterChains), 1)
+}
+
+func TestToEnvoySnapshotWithErrorPages(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			ErrorPageBody:         "external",
+			InternalErrorPageBody: "internal",
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	listeners := snapshot.GetResources(resource.ListenerType)
+	for port, want := range map[uint32]string{config.HTTPPortExternal: "external", config.HTTPPortInternal: "internal"} {
+		l := listeners[envoy.CreateListenerName(port)].(*listener.Listener)
+		manager := &hcm.HttpConnectionManager{}
+		assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+		mappers := manager.LocalReplyConfig.Mappers
+		assert.Equal(t, mappers[len(mappers)-1].BodyFormatOverride.GetTextFormatSource().GetInlineString(), want, "port %d", port)
+	}
+}
+
+func TestToEnvoySnapshotWithInternalUpgradeProtection(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{InternalUpgradeProtection: true},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	listeners := snapshot.GetResources(resource.ListenerType)
+	for port, want := range map[uint32]int{config.HTTPPortExternal: 0, config.HTTPPortInternal: 2} {
+		l := listeners[envoy.CreateListenerName(port)].(*listener.Listener)
+		manager := &hcm.HttpConnectionManager{}
+		assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+		assert.Equal(t, len(manager.UpgradeConfigs), want, "port %d", port)
+	}
+}
+
+func TestToEnvoySnapshotsWithGatewayFleets(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			GatewayFleets: map[string]config.GatewayFleet{
+				"tenant": {NodeID: "kourier-tenant"},
+				"idle":   {NodeID: "kourier-idle"},
+			},
+		},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	sharedHost := &route.VirtualHost{Name: "shared", Domains: []string{"shared.example.com"}}
+	tenantHost := &route.VirtualHost{Name: "tenant", Domains: []string{"tenant.example.com"}}
+	for _, translated := range []*translatedIngress{{
+		name:                 types.NamespacedName{Namespace: "ns", Name: "shared"},
+		clusters:             []*v3.Cluster{envoy.NewCluster("ns/shared", 0, nil, false, nil, v3.Cluster_STATIC)},
+		externalVirtualHosts: []*route.VirtualHost{sharedHost},
+		internalVirtualHosts: []*route.VirtualHost{sharedHost},
+	}, {
+		name:                 types.NamespacedName{Namespace: "ns", Name: "tenant"},
+		gatewayFleet:         "tenant",
+		clusters:             []*v3.Cluster{envoy.NewCluster("ns/tenant", 0, nil, false, nil, v3.Cluster_STATIC)},
+		externalVirtualHosts: []*route.VirtualHost{tenantHost},
+		internalVirtualHosts: []*route.VirtualHost{tenantHost},
+	}} {
+		assert.NilError(t, caches.addTranslatedIngress(translated, false))
+	}
+
+	snapshots, err := caches.ToEnvoySnapshots(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snapshots), 3)
+
+	// Every fleet is only served its own ingresses.
+	for nodeID, want := range map[string]*route.VirtualHost{
+		config.GatewayNodeID: sharedHost,
+		"kourier-tenant":     tenantHost,
+	} {
+		routes := snapshots[nodeID].GetResources(resource.RouteType)
+		assert.DeepEqual(t, routes[externalRouteConfigName].(*route.RouteConfiguration).VirtualHosts, []*route.VirtualHost{want}, protocmp.Transform())
+
+		clusters := snapshots[nodeID].GetResources(resource.ClusterType)
+		assert.Equal(t, len(clusters), 1)
+		assert.Assert(t, clusters["ns/"+want.Name] != nil)
+	}
+
+	// Fleets without ingresses get their listeners anyway.
+	idle := snapshots["kourier-idle"]
+	assert.Equal(t, len(idle.GetResources(resource.ClusterType)), 0)
+	assert.Assert(t, len(idle.GetResources(resource.ListenerType)) != 0)
+
+	// The clusters of deleted ingresses are kept around for all fleets.
+	caches.deleteTranslatedIngress("tenant", "ns")
+	snapshots, err = caches.ToEnvoySnapshots(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, snapshots[config.GatewayNodeID].GetResources(resource.ClusterType)["ns/tenant"] != nil)
+}
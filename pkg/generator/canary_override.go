@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// canaryOverrideTags returns the tags of the splits of the path requests can be pinned to
+// via the canary override header. These are the names of the services of the splits, if
+// the path has multiple ones, i.e. of the revisions.
+func canaryOverrideTags(httpPath v1alpha1.HTTPIngressPath) []string {
+	if len(httpPath.Splits) < 2 {
+		return nil
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, split := range httpPath.Splits {
+		if !seen[split.ServiceName] {
+			seen[split.ServiceName] = true
+			tags = append(tags, split.ServiceName)
+		}
+	}
+	return tags
+}
+
+// canaryOverrideHeadersMatch returns the given header matchers of a path, additionally
+// matching the requests pinned to the split with the given tag via the given header.
+func canaryOverrideHeadersMatch(headersMatch []*route.HeaderMatcher, header, tag string) []*route.HeaderMatcher {
+	matchers := make([]*route.HeaderMatcher, 0, len(headersMatch)+1)
+	matchers = append(matchers, headersMatch...)
+	return append(matchers, &route.HeaderMatcher{
+		Name:                 header,
+		HeaderMatchSpecifier: &route.HeaderMatcher_ExactMatch{ExactMatch: tag},
+	})
+}
+
+// canaryOverrideRouteName returns the name of the route pinning the requests of the path
+// to the split with the given tag.
+func canaryOverrideRouteName(pathName, tag string) string {
+	return fmt.Sprintf("%s.Override[%s]", pathName, tag)
+}
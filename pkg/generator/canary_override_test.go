@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestCanaryOverrideTags(t *testing.T) {
+	split := func(name string) v1alpha1.IngressBackendSplit {
+		return v1alpha1.IngressBackendSplit{IngressBackend: v1alpha1.IngressBackend{ServiceName: name}}
+	}
+
+	assert.Assert(t, canaryOverrideTags(v1alpha1.HTTPIngressPath{
+		Splits: []v1alpha1.IngressBackendSplit{split("v1")},
+	}) == nil)
+	assert.DeepEqual(t, canaryOverrideTags(v1alpha1.HTTPIngressPath{
+		Splits: []v1alpha1.IngressBackendSplit{split("v1"), split("v2"), split("v1")},
+	}), []string{"v1", "v2"})
+}
+
+func TestIngressTranslatorCanaryOverride(t *testing.T) {
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.StickyCanaryTTLAnnotationKey: "1h"}
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+		ing.Spec.Rules[0].HTTP.Paths[0].Headers = map[string]v1alpha1.HeaderMatch{"tenant": {Exact: "a"}}
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits = []v1alpha1.IngressBackendSplit{{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "servicename",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: 90,
+		}, {
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "canary",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent:       10,
+			AppendHeaders: map[string]string{"K-Revision": "canary"},
+		}}
+	})
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.CanaryOverrideHeader = "X-Canary"
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(
+		svc("servicens", "servicename"), eps("servicens", "servicename"),
+		svc("servicens", "canary"), eps("servicens", "canary"),
+	)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+
+	// The requests pinned to a split are matched ahead of the ones split by weight.
+	routes := got.internalVirtualHosts[0].Routes
+	assert.Equal(t, len(routes), 3)
+	pinned := func(r *route.Route, tag string, cluster *route.WeightedCluster_ClusterWeight) {
+		t.Helper()
+		assert.Equal(t, r.Name, "(testspace/testname).Rules[0].Paths[/test].Override["+tag+"]")
+		assert.DeepEqual(t, r.Match.Headers, []*route.HeaderMatcher{{
+			Name:                 "tenant",
+			HeaderMatchSpecifier: &route.HeaderMatcher_ExactMatch{ExactMatch: "a"},
+		}, {
+			Name:                 "X-Canary",
+			HeaderMatchSpecifier: &route.HeaderMatcher_ExactMatch{ExactMatch: tag},
+		}}, protocmp.Transform())
+		weightedClusters := r.GetRoute().GetWeightedClusters()
+		assert.DeepEqual(t, weightedClusters.Clusters, []*route.WeightedCluster_ClusterWeight{cluster}, protocmp.Transform())
+		// The pinned requests aren't made sticky.
+		assert.Assert(t, weightedClusters.RandomValueSpecifier == nil)
+	}
+	pinned(routes[0], "servicename", envoy.NewWeightedCluster("servicens/servicename", 100, nil))
+	pinned(routes[1], "canary", envoy.NewWeightedCluster("servicens/canary", 100, map[string]string{"K-Revision": "canary"}))
+
+	assert.Equal(t, routes[2].Name, "(testspace/testname).Rules[0].Paths[/test]")
+	assert.Equal(t, len(routes[2].Match.Headers), 1)
+	assert.Equal(t, len(routes[2].GetRoute().GetWeightedClusters().Clusters), 2)
+	assert.Assert(t, routes[2].GetRoute().GetWeightedClusters().RandomValueSpecifier != nil)
+
+	// Without the header configured, the requests are only split by weight.
+	cfg.Kourier.CanaryOverrideHeader = ""
+	got, err = translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.internalVirtualHosts[0].Routes), 1)
+}
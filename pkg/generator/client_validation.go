@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// clientValidation returns the validation of client certificates for the external TLS
+// hosts of the ingress. The CA secret specified on the ingress takes precedence over the
+// global one. It returns nil if client certificates are not required.
+func (translator *IngressTranslator) clientValidation(ctx context.Context, ingress *v1alpha1.Ingress) (*envoy.ClientValidation, error) {
+	cfg := config.FromContextOrDefaults(ctx).Kourier
+
+	caSecret := cfg.ClientCASecret
+	allowedSANs := cfg.ClientAllowedSANs
+	if name := pkgconfig.GetClientCASecret(ingress.Annotations); name != "" {
+		// Only secrets of the ingress' own namespace can be referenced.
+		caSecret = types.NamespacedName{Namespace: ingress.Namespace, Name: name}
+	}
+	if sans := pkgconfig.GetClientAllowedSANs(ingress.Annotations); sans != "" {
+		allowedSANs = splitList(sans)
+	}
+	if caSecret.Name == "" {
+		return nil, nil
+	}
+
+	if err := trackSecret(translator.tracker, caSecret.Namespace, caSecret.Name, ingress); err != nil {
+		return nil, err
+	}
+
+	secret, err := translator.secretGetter(caSecret.Namespace, caSecret.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch client CA secret: %w", err)
+	}
+	caCertificates := secret.Data[caFieldInSecret]
+	if len(caCertificates) == 0 {
+		return nil, fmt.Errorf("client CA secret '%s' has no %q field", caSecret, caFieldInSecret)
+	}
+
+	return &envoy.ClientValidation{
+		CASource:       caSecret,
+		CACertificates: caCertificates,
+		AllowedSANs:    allowedSANs,
+	}, nil
+}
+
+// splitList splits the given comma separated list, ignoring empty values.
+func splitList(list string) []string {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -43,6 +43,7 @@ import (
 	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	gocache "github.com/patrickmn/go-cache"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -77,9 +78,17 @@ func (cc *ClustersCache) setExpiration(clusterName string, ingressName string, i
 }
 
 func (cc *ClustersCache) list() []cachetypes.Resource {
+	return cc.listFor(func(types.NamespacedName) bool { return true })
+}
+
+// listFor lists the clusters whose ingress is accepted by include.
+func (cc *ClustersCache) listFor(include func(ingress types.NamespacedName) bool) []cachetypes.Resource {
 	res := make([]cachetypes.Resource, 0, cc.clusters.ItemCount())
-	for _, cluster := range cc.clusters.Items() {
-		res = append(res, cluster.Object.(cachetypes.Resource))
+	for key, cluster := range cc.clusters.Items() {
+		_, name, namespace := explodeKey(key)
+		if include(types.NamespacedName{Namespace: namespace, Name: name}) {
+			res = append(res, cluster.Object.(cachetypes.Resource))
+		}
 	}
 
 	return res
@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// listenerSettingOff disables the access logs, the transformation or the external
+	// authorization of a dedicated listener.
+	listenerSettingOff = "off"
+
+	// listenerSettingOn enables the external authorization of a dedicated listener.
+	listenerSettingOn = "on"
+)
+
+// dedicatedListener is the config of the listener of a namespace with a listener port
+// which has its own TLS, access logs or transformation. Its port can't be shared with
+// other namespaces.
+type dedicatedListener struct {
+	// tlsSecret is the secret holding the certificate the listener serves. The listener
+	// serves plain HTTP if empty.
+	tlsSecret        types.NamespacedName
+	certificateChain []byte
+	privateKey       []byte
+
+	// accessLog is the path of the access logs, or listenerSettingOff. The configured
+	// access logs are written if empty.
+	accessLog string
+
+	// transformationWasmModule is the Wasm module transforming the requests, or
+	// listenerSettingOff. The configured module is used if empty.
+	transformationWasmModule string
+
+	// idleTimeout and requestTimeout override the configured stream idle and request
+	// timeouts unless nil. Zero disables them.
+	idleTimeout    *time.Duration
+	requestTimeout *time.Duration
+
+	// extAuthz is listenerSettingOn or listenerSettingOff. The requests are authorized as
+	// configured if empty.
+	extAuthz string
+}
+
+// dedicatedListener returns the config of the dedicated listener requested by the
+// annotations of the given namespace, or nil if it doesn't request one.
+func (translator *IngressTranslator) dedicatedListener(ctx context.Context, ns *corev1.Namespace, ingress *v1alpha1.Ingress) (*dedicatedListener, error) {
+	tlsSecret := ns.Annotations[config.ListenerTLSSecretAnnotationKey]
+	accessLog := ns.Annotations[config.ListenerAccessLogAnnotationKey]
+	wasmModule := ns.Annotations[config.ListenerTransformationWasmModuleAnnotationKey]
+	idleTimeout := ns.Annotations[config.ListenerIdleTimeoutAnnotationKey]
+	requestTimeout := ns.Annotations[config.ListenerRequestTimeoutAnnotationKey]
+	extAuthz := ns.Annotations[config.ListenerExtAuthzAnnotationKey]
+	if tlsSecret == "" && accessLog == "" && wasmModule == "" && idleTimeout == "" && requestTimeout == "" && extAuthz == "" {
+		return nil, nil
+	}
+
+	if accessLog != "" && accessLog != listenerSettingOff && (!filepath.IsAbs(accessLog) || filepath.Clean(accessLog) != accessLog) {
+		return nil, fmt.Errorf("%w in annotation %q of namespace %q: %q is neither %q nor a clean absolute path",
+			ErrInvalidListenerPort, config.ListenerAccessLogAnnotationKey, ns.Name, accessLog, listenerSettingOff)
+	}
+
+	switch extAuthz {
+	case "", listenerSettingOff:
+	case listenerSettingOn:
+		if !config.ExternalAuthz.Enabled {
+			return nil, fmt.Errorf("%w in annotation %q of namespace %q: no external authorization is configured",
+				ErrInvalidListenerPort, config.ListenerExtAuthzAnnotationKey, ns.Name)
+		}
+	default:
+		return nil, fmt.Errorf("%w in annotation %q of namespace %q: %q is neither %q nor %q",
+			ErrInvalidListenerPort, config.ListenerExtAuthzAnnotationKey, ns.Name, extAuthz, listenerSettingOn, listenerSettingOff)
+	}
+
+	listener := &dedicatedListener{
+		accessLog:                accessLog,
+		transformationWasmModule: wasmModule,
+		extAuthz:                 extAuthz,
+	}
+	var err error
+	if listener.idleTimeout, err = listenerTimeout(ns, config.ListenerIdleTimeoutAnnotationKey); err != nil {
+		return nil, err
+	}
+	if listener.requestTimeout, err = listenerTimeout(ns, config.ListenerRequestTimeoutAnnotationKey); err != nil {
+		return nil, err
+	}
+	if tlsSecret != "" {
+		if err := trackSecret(translator.tracker, ns.Name, tlsSecret, ingress); err != nil {
+			return nil, err
+		}
+
+		secret, err := translator.secretGetter(ns.Name, tlsSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch listener TLS secret: %w", err)
+		}
+		listener.tlsSecret = types.NamespacedName{Namespace: ns.Name, Name: tlsSecret}
+		if listener.certificateChain, listener.privateKey, err = tlsSecretData(ctx, secret); err != nil {
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// listenerTimeout returns the timeout of the dedicated listener specified by the given
+// annotation of the namespace, or nil if it isn't specified.
+func listenerTimeout(ns *corev1.Namespace, key string) (*time.Duration, error) {
+	raw := ns.Annotations[key]
+	if raw == "" {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout < 0 {
+		return nil, fmt.Errorf("%w in annotation %q of namespace %q: %q is not a non-negative duration",
+			ErrInvalidListenerPort, key, ns.Name, raw)
+	}
+	return &timeout, nil
+}
+
+// connectionManager creates the connection manager of the listener, pointing to the
+// given RouteConfig. Without a dedicated listener, the configured one is created.
+func (l *dedicatedListener) connectionManager(routeConfigName string, kourierConfig *config.Kourier) *hcm.HttpConnectionManager {
+	if l == nil {
+		return envoy.NewHTTPConnectionManager(routeConfigName, kourierConfig)
+	}
+
+	listenerConfig := *kourierConfig
+	switch l.transformationWasmModule {
+	case "":
+	case listenerSettingOff:
+		listenerConfig.TransformationWasmModule = ""
+	default:
+		listenerConfig.TransformationWasmModule = l.transformationWasmModule
+	}
+	switch l.accessLog {
+	case "":
+	case listenerSettingOff:
+		listenerConfig.EnableServiceAccessLogging = false
+	default:
+		listenerConfig.EnableServiceAccessLogging = true
+	}
+
+	if l.idleTimeout != nil {
+		listenerConfig.IdleTimeout = *l.idleTimeout
+	}
+	if l.requestTimeout != nil {
+		listenerConfig.RequestTimeout = *l.requestTimeout
+	}
+
+	manager := envoy.NewHTTPConnectionManager(routeConfigName, &listenerConfig)
+	if listenerConfig.EnableServiceAccessLogging && l.accessLog != "" {
+		envoy.SetAccessLogPath(manager, l.accessLog)
+	}
+	if l.extAuthz == listenerSettingOff && config.ExternalAuthz.Enabled {
+		envoy.RemoveHTTPFilter(manager, config.ExternalAuthz.HTTPFilter.Name)
+	}
+	return manager
+}
+
+// listener creates the listener at the given port, backed by the given manager. Without
+// a dedicated listener, or without TLS, it serves plain HTTP.
+func (l *dedicatedListener) listener(manager *hcm.HttpConnectionManager, port uint32) (*v3.Listener, error) {
+	if l == nil || l.tlsSecret.Name == "" {
+		return envoy.NewHTTPListener(manager, port, false)
+	}
+
+	filterChain, err := envoy.CreateFilterChainFromCertificateAndPrivateKey(manager, l.certificateChain, l.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return envoy.NewHTTPSListener(port, []*v3.FilterChain{filterChain}, false)
+}
+
+// listenerPortConflict returns an error if the listener port of the given ingress is
+// used by an ingress of another namespace, while either of them has a dedicated
+// listener.
+func (caches *Caches) listenerPortConflict(translated *translatedIngress) error {
+	if translated.listenerPort == "" {
+		return nil
+	}
+
+	for name, other := range caches.translatedIngresses {
+		if name.Namespace == translated.name.Namespace || other.listenerPort != translated.listenerPort ||
+			other.gatewayFleet != translated.gatewayFleet {
+			continue
+		}
+		if translated.dedicatedListener != nil || other.dedicatedListener != nil {
+			return fmt.Errorf("%w: port %s is used by namespace %q, but can't be shared with a dedicated listener",
+				ErrInvalidListenerPort, translated.listenerPort, name.Namespace)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	accesslog_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorDedicatedListener(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *dedicatedListener
+		wantErr     bool
+	}{{
+		name: "no dedicated listener",
+	}, {
+		name: "all settings",
+		annotations: map[string]string{
+			pkgconfig.ListenerTLSSecretAnnotationKey:                "listener-cert",
+			pkgconfig.ListenerAccessLogAnnotationKey:                "/var/log/simplens.log",
+			pkgconfig.ListenerTransformationWasmModuleAnnotationKey: "/etc/kourier/simplens.wasm",
+		},
+		want: &dedicatedListener{
+			tlsSecret:                types.NamespacedName{Namespace: "simplens", Name: "listener-cert"},
+			certificateChain:         cert,
+			privateKey:               privateKey,
+			accessLog:                "/var/log/simplens.log",
+			transformationWasmModule: "/etc/kourier/simplens.wasm",
+		},
+	}, {
+		name: "settings turned off",
+		annotations: map[string]string{
+			pkgconfig.ListenerAccessLogAnnotationKey:                "off",
+			pkgconfig.ListenerTransformationWasmModuleAnnotationKey: "off",
+		},
+		want: &dedicatedListener{
+			accessLog:                "off",
+			transformationWasmModule: "off",
+		},
+	}, {
+		name: "timeouts",
+		annotations: map[string]string{
+			pkgconfig.ListenerIdleTimeoutAnnotationKey:    "10m",
+			pkgconfig.ListenerRequestTimeoutAnnotationKey: "0s",
+		},
+		want: &dedicatedListener{
+			idleTimeout:    durationPtr(10 * time.Minute),
+			requestTimeout: durationPtr(0),
+		},
+	}, {
+		name:        "external authorization turned off",
+		annotations: map[string]string{pkgconfig.ListenerExtAuthzAnnotationKey: "off"},
+		want:        &dedicatedListener{extAuthz: "off"},
+	}, {
+		name:        "external authorization turned on without being configured",
+		annotations: map[string]string{pkgconfig.ListenerExtAuthzAnnotationKey: "on"},
+		wantErr:     true,
+	}, {
+		name:        "invalid external authorization setting",
+		annotations: map[string]string{pkgconfig.ListenerExtAuthzAnnotationKey: "disabled"},
+		wantErr:     true,
+	}, {
+		name:        "negative idle timeout",
+		annotations: map[string]string{pkgconfig.ListenerIdleTimeoutAnnotationKey: "-1s"},
+		wantErr:     true,
+	}, {
+		name:        "invalid request timeout",
+		annotations: map[string]string{pkgconfig.ListenerRequestTimeoutAnnotationKey: "soon"},
+		wantErr:     true,
+	}, {
+		name:        "relative access log path",
+		annotations: map[string]string{pkgconfig.ListenerAccessLogAnnotationKey: "simplens.log"},
+		wantErr:     true,
+	}, {
+		name:        "unclean access log path",
+		annotations: map[string]string{pkgconfig.ListenerAccessLogAnnotationKey: "/var/log/../simplens.log"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.TrafficIsolation = pkgconfig.IsolationIngressPort
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			namespace := ns("simplens")
+			namespace.Annotations = map[string]string{pkgconfig.ListenerPortAnnotationKey: "8888"}
+			for k, v := range test.annotations {
+				namespace.Annotations[k] = v
+			}
+			listenerCert := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "simplens", Name: "listener-cert"},
+				Data:       map[string][]byte{certFieldInSecret: cert, keyFieldInSecret: privateKey},
+			}
+			kubeclient := fake.NewSimpleClientset(namespace, listenerCert, svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+			tracker := &pkgtest.FakeTracker{}
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				tracker,
+			)
+
+			got, err := translator.translateIngress(ctx, ing("simplens", "simplename"), false)
+			if test.wantErr {
+				assert.Assert(t, errors.Is(err, ErrInvalidListenerPort), "got error %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got.listenerPort, "8888")
+			assert.DeepEqual(t, got.dedicatedListener, test.want, cmp.AllowUnexported(dedicatedListener{}))
+
+			if test.want != nil && test.want.tlsSecret.Name != "" {
+				// Changes to the certificate update the listener.
+				tracked := false
+				for _, ref := range tracker.References() {
+					tracked = tracked || (ref.Kind == "Secret" && ref.Namespace == "simplens" && ref.Name == "listener-cert")
+				}
+				assert.Assert(t, tracked)
+			}
+		})
+	}
+}
+
+func TestDedicatedListenerPortConflict(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	translated := func(namespace, name string, listener *dedicatedListener) *translatedIngress {
+		return &translatedIngress{
+			name:              types.NamespacedName{Namespace: namespace, Name: name},
+			listenerPort:      "8888",
+			dedicatedListener: listener,
+			internalVirtualHosts: []*route.VirtualHost{{
+				Name:    name,
+				Domains: []string{name + "." + namespace + ".svc.cluster.local"},
+			}},
+		}
+	}
+	dedicated := &dedicatedListener{accessLog: "off"}
+
+	assert.NilError(t, caches.addTranslatedIngress(translated("dedicated", "foo", dedicated), false))
+	// The ingresses of the same namespace share the dedicated listener.
+	assert.NilError(t, caches.addTranslatedIngress(translated("dedicated", "bar", dedicated), false))
+
+	// The dedicated listener's port can't be used by other namespaces, with or without
+	// a dedicated listener of their own.
+	err = caches.addTranslatedIngress(translated("shared", "foo", nil), false)
+	assert.Assert(t, errors.Is(err, ErrInvalidListenerPort), "got error %v", err)
+	err = caches.addTranslatedIngress(translated("other", "foo", &dedicatedListener{accessLog: "off"}), false)
+	assert.Assert(t, errors.Is(err, ErrInvalidListenerPort), "got error %v", err)
+
+	// Other gateway fleets have listeners of their own.
+	other := translated("other", "foo", &dedicatedListener{accessLog: "off"})
+	other.gatewayFleet = "tenant-a"
+	assert.NilError(t, caches.addTranslatedIngress(other, false))
+}
+
+func TestSnapshotWithDedicatedListener(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	for _, translated := range []*translatedIngress{{
+		name:         types.NamespacedName{Namespace: "dedicated", Name: "foo"},
+		listenerPort: "8888",
+		dedicatedListener: &dedicatedListener{
+			tlsSecret:        types.NamespacedName{Namespace: "dedicated", Name: "listener-cert"},
+			certificateChain: cert,
+			privateKey:       privateKey,
+			accessLog:        "/var/log/dedicated.log",
+		},
+		internalVirtualHosts: []*route.VirtualHost{{Name: "foo", Domains: []string{"foo.dedicated.svc.cluster.local"}}},
+	}, {
+		name:                 types.NamespacedName{Namespace: "isolated", Name: "foo"},
+		listenerPort:         "9999",
+		internalVirtualHosts: []*route.VirtualHost{{Name: "foo", Domains: []string{"foo.isolated.svc.cluster.local"}}},
+	}} {
+		assert.NilError(t, caches.addTranslatedIngress(translated, false))
+	}
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+	listeners := snapshot.GetResources(resource.ListenerType)
+
+	connectionManager := func(l *listener.Listener) *hcm.HttpConnectionManager {
+		manager := &hcm.HttpConnectionManager{}
+		assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+		return manager
+	}
+
+	dedicated := listeners[envoy.CreateListenerName(8888)].(*listener.Listener)
+	assert.Assert(t, dedicated.FilterChains[0].TransportSocket != nil)
+	accessLog := &accesslog_file_v3.FileAccessLog{}
+	assert.NilError(t, connectionManager(dedicated).AccessLog[0].GetTypedConfig().UnmarshalTo(accessLog))
+	assert.Equal(t, accessLog.Path, "/var/log/dedicated.log")
+
+	// Listener ports without a dedicated listener keep the shared settings.
+	isolated := listeners[envoy.CreateListenerName(9999)].(*listener.Listener)
+	assert.Assert(t, isolated.FilterChains[0].TransportSocket == nil)
+	assert.NilError(t, connectionManager(isolated).AccessLog[0].GetTypedConfig().UnmarshalTo(accessLog))
+	assert.Equal(t, accessLog.Path, "/dev/stdout")
+}
+
+func TestDedicatedListenerConnectionManager(t *testing.T) {
+	kourierConfig := &pkgconfig.Kourier{
+		EnableServiceAccessLogging: true,
+		TransformationWasmModule:   "/etc/kourier/shared.wasm",
+	}
+
+	var none *dedicatedListener
+	manager := none.connectionManager("routes", kourierConfig)
+	assert.Equal(t, len(manager.AccessLog), 1)
+
+	off := &dedicatedListener{accessLog: listenerSettingOff, transformationWasmModule: listenerSettingOff}
+	manager = off.connectionManager("routes", kourierConfig)
+	assert.Equal(t, len(manager.AccessLog), 0)
+	for _, filter := range manager.HttpFilters {
+		assert.Assert(t, filter.Name != envoy.NewTransformFilter("/etc/kourier/shared.wasm").Name)
+	}
+
+	// The timeouts override the configured ones.
+	kourierConfig.IdleTimeout = time.Hour
+	kourierConfig.RequestTimeout = time.Minute
+	timeouts := &dedicatedListener{idleTimeout: durationPtr(10 * time.Minute), requestTimeout: durationPtr(0)}
+	manager = timeouts.connectionManager("routes", kourierConfig)
+	assert.Equal(t, manager.StreamIdleTimeout.AsDuration(), 10*time.Minute)
+	assert.Assert(t, manager.RequestTimeout == nil)
+
+	// The configured settings are left alone.
+	assert.Equal(t, kourierConfig.TransformationWasmModule, "/etc/kourier/shared.wasm")
+	assert.Assert(t, kourierConfig.EnableServiceAccessLogging)
+	assert.Equal(t, kourierConfig.IdleTimeout, time.Hour)
+	assert.Equal(t, kourierConfig.RequestTimeout, time.Minute)
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strconv"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	corev1 "k8s.io/api/core/v1"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// revisionLabelKey is the label of the services of Knative revisions naming them.
+const revisionLabelKey = "serving.knative.dev/revision"
+
+// setRouteDescriptions describes the given rule of the ingress in the metadata of the
+// given routes. The rule is left out if negative.
+func setRouteDescriptions(ingress *v1alpha1.Ingress, rule int, routes ...[]*route.Route) {
+	description := ingressDescription(ingress)
+	if rule >= 0 {
+		description["rule"] = strconv.Itoa(rule)
+	}
+	for _, rs := range routes {
+		for _, r := range rs {
+			envoy.SetRouteDescription(r, description)
+		}
+	}
+}
+
+// ingressDescription returns the description of the given ingress, naming its Knative
+// service if any.
+func ingressDescription(ingress *v1alpha1.Ingress) map[string]string {
+	description := map[string]string{
+		"ingress_namespace": ingress.Namespace,
+		"ingress_name":      ingress.Name,
+		"ingress_uid":       string(ingress.UID),
+	}
+	if service := ingress.Labels[serviceLabelKey]; service != "" {
+		description["kservice"] = service
+	}
+	return description
+}
+
+// clusterDescription returns the description of a cluster of the given service, naming
+// its Knative service and revision if any. It only depends on the service, as the
+// clusters are shared by the ingresses routing to it.
+func clusterDescription(service *corev1.Service) map[string]string {
+	description := map[string]string{
+		"service_namespace": service.Namespace,
+		"service_name":      service.Name,
+	}
+	if kservice := service.Labels[serviceLabelKey]; kservice != "" {
+		description["kservice"] = kservice
+	}
+	if revision := service.Labels[revisionLabelKey]; revision != "" {
+		description["krevision"] = revision
+	}
+	return description
+}
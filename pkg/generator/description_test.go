@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorDescriptionMetadata(t *testing.T) {
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.UID = "8a3c1f2e"
+		ing.Labels = map[string]string{serviceLabelKey: "hello"}
+		ing.Spec.Rules = append(ing.Spec.Rules, ing.Spec.Rules[0])
+		ing.Spec.Rules[1].Hosts = []string{"bar.example.com"}
+	})
+	service := svc("servicens", "servicename", func(svc *corev1.Service) {
+		svc.Labels = map[string]string{serviceLabelKey: "hello", revisionLabelKey: "hello-00001"}
+	})
+
+	for _, enabled := range []bool{true, false} {
+		cfg := defaultConfig.DeepCopy()
+		cfg.Kourier.DescriptionMetadata = enabled
+		ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+		kubeclient := fake.NewSimpleClientset(service, eps("servicens", "servicename"))
+		translator := NewIngressTranslator(
+			func(ns, name string) (*corev1.Secret, error) {
+				return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(ns, name string) (*corev1.Endpoints, error) {
+				return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(ns, name string) (*corev1.Service, error) {
+				return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(name string) (*corev1.Namespace, error) {
+				return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			},
+			configMapsGetter(ctx, kubeclient),
+			&pkgtest.FakeTracker{},
+		)
+
+		got, err := translator.translateIngress(ctx, in, false)
+		assert.NilError(t, err)
+		assert.Equal(t, len(got.externalVirtualHosts), 2)
+
+		if !enabled {
+			assert.Assert(t, got.externalVirtualHosts[0].Routes[0].Metadata == nil)
+			assert.Assert(t, got.clusters[0].Metadata == nil)
+			continue
+		}
+
+		// The routes describe the rule they were generated from.
+		for rule, vhost := range got.externalVirtualHosts {
+			description := vhost.Routes[0].Metadata.FilterMetadata[envoy.DescriptionMetadataNamespace].AsMap()
+			assert.DeepEqual(t, description, map[string]interface{}{
+				"ingress_namespace": "testspace",
+				"ingress_name":      "testname",
+				"ingress_uid":       "8a3c1f2e",
+				"kservice":          "hello",
+				"rule":              []string{"0", "1"}[rule],
+			})
+		}
+
+		// The clusters describe the service they route to.
+		description := got.clusters[0].Metadata.FilterMetadata[envoy.DescriptionMetadataNamespace].AsMap()
+		assert.DeepEqual(t, description, map[string]interface{}{
+			"service_namespace": "servicens",
+			"service_name":      "servicename",
+			"kservice":          "hello",
+			"krevision":         "hello-00001",
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"net/http"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// DrainingSnapshot derives the snapshot of the draining gateways from the given one.
+// Their readiness probe fails, so that they're removed from the endpoints of the gateway
+// services, and their connections are closed after their next request, so that the
+// clients reconnect to other gateways. The connections opened before are drained as
+// the listeners are updated.
+func DrainingSnapshot(snapshot cache.ResourceSnapshot) (cache.ResourceSnapshot, error) {
+	resources := make(map[resource.Type][]cachetypes.Resource)
+	ttls := make(map[string]time.Duration)
+	for typ := cachetypes.ResponseType(0); typ < cachetypes.UnknownType; typ++ {
+		typeURL, err := cache.GetResponseTypeURL(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, item := range snapshot.GetResourcesAndTTL(typeURL) {
+			res := item.Resource
+			switch r := res.(type) {
+			case *v3.Listener:
+				l := proto.Clone(r).(*v3.Listener)
+				if err := envoy.SetDrainingListener(l); err != nil {
+					return nil, err
+				}
+				res = l
+			case *route.RouteConfiguration:
+				routeConfig := proto.Clone(r).(*route.RouteConfiguration)
+				failReadyRoute(routeConfig)
+				res = routeConfig
+			}
+
+			resources[typeURL] = append(resources[typeURL], res)
+			if item.TTL != nil {
+				ttls[name] = *item.TTL
+			}
+		}
+	}
+	drainingSnapshot, err := newSnapshotWithTTLs(resources, ttls)
+	if err != nil {
+		return nil, err
+	}
+	return drainingSnapshot, nil
+}
+
+// failReadyRoute makes the route of the readiness probe of the gateways in the given
+// RouteConfig, if any, fail the probe.
+func failReadyRoute(routeConfig *route.RouteConfiguration) {
+	for _, vhost := range routeConfig.VirtualHosts {
+		for _, r := range vhost.Routes {
+			if r.Name == readyRouteName {
+				r.Action = &route.Route_DirectResponse{
+					DirectResponse: &route.DirectResponseAction{Status: http.StatusServiceUnavailable},
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"net/http"
+	"testing"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestDrainingSnapshot(t *testing.T) {
+	l, err := envoy.NewHTTPListener(envoy.NewHTTPConnectionManager("routes", &config.Kourier{}), 8080, false)
+	assert.NilError(t, err)
+	routeConfig := envoy.NewRouteConfig("routes", []*route.VirtualHost{statusVHost()})
+	snapshot, err := newSnapshotWithTTLs(map[resource.Type][]cachetypes.Resource{
+		resource.ListenerType: {l},
+		resource.RouteType:    {routeConfig},
+	}, nil)
+	assert.NilError(t, err)
+
+	got, err := DrainingSnapshot(snapshot)
+	assert.NilError(t, err)
+
+	// The readiness probe fails.
+	drainingRoutes := got.GetResources(resource.RouteType)["routes"].(*route.RouteConfiguration)
+	var ready *route.Route
+	for _, r := range drainingRoutes.VirtualHosts[0].Routes {
+		if r.Name == readyRouteName {
+			ready = r
+		}
+	}
+	assert.Assert(t, ready != nil)
+	assert.Equal(t, ready.GetDirectResponse().GetStatus(), uint32(http.StatusServiceUnavailable))
+
+	// The connections are closed after each request.
+	drainingListener := got.GetResources(resource.ListenerType)[l.Name].(*v3.Listener)
+	manager := &hcm.HttpConnectionManager{}
+	assert.NilError(t, drainingListener.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+	assert.Equal(t, manager.CommonHttpProtocolOptions.MaxRequestsPerConnection.GetValue(), uint32(1))
+
+	// The given snapshot is left as it is.
+	assert.Assert(t, routeConfig.VirtualHosts[0].Routes[0].GetDirectResponse() == nil)
+	assert.Assert(t, got.GetVersion(resource.RouteType) != snapshot.GetVersion(resource.RouteType))
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+const (
+	// dropMatchPrefix matches the path of the dropped route as a prefix.
+	dropMatchPrefix = "prefix"
+
+	// dropMatchExact matches the path of the dropped route exactly.
+	dropMatchExact = "exact"
+)
+
+// dropStatuses are the statuses the requests to dropped routes can be rejected with.
+var dropStatuses = map[uint32]bool{
+	http.StatusForbidden: true,
+	http.StatusNotFound:  true,
+	http.StatusGone:      true,
+}
+
+// dropRouteSpec is a dropped route as specified in the drop routes annotation.
+//
+// Example: {"path": "/metrics", "match": "exact", "methods": ["GET"], "statusCode": 404}
+type dropRouteSpec struct {
+	// Path is the path of the rejected requests.
+	Path string `json:"path"`
+	// Match is how the path is matched, "prefix" by default.
+	Match string `json:"match,omitempty"`
+	// Methods are the methods of the rejected requests, all methods if empty.
+	Methods []string `json:"methods,omitempty"`
+	// StatusCode is the status of the rejections, 403 by default.
+	StatusCode uint32 `json:"statusCode,omitempty"`
+}
+
+func (d dropRouteSpec) validate() error {
+	if !strings.HasPrefix(d.Path, "/") {
+		return fmt.Errorf(`path %q must start with "/"`, d.Path)
+	}
+	if d.Match != "" && d.Match != dropMatchPrefix && d.Match != dropMatchExact {
+		return fmt.Errorf("match must be %q or %q, got %q", dropMatchPrefix, dropMatchExact, d.Match)
+	}
+	for _, method := range d.Methods {
+		if method == "" || strings.ToUpper(method) != method || strings.ContainsAny(method, " \t") {
+			return fmt.Errorf("method %q is not an uppercase HTTP method", method)
+		}
+	}
+	if d.StatusCode != 0 && !dropStatuses[d.StatusCode] {
+		return fmt.Errorf("statusCode must be 403, 404 or 410, got %d", d.StatusCode)
+	}
+	return nil
+}
+
+// dropRoutesFromAnnotations returns the routes of the Ingress whose requests are
+// rejected by the gateways, as specified via annotations on it.
+func dropRoutesFromAnnotations(annotations map[string]string) ([]envoy.DropRoute, error) {
+	raw := pkgconfig.GetDropRoutes(annotations)
+	if raw == "" {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	var specs []dropRouteSpec
+	if err := decoder.Decode(&specs); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.DropRoutesAnnotationKey, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.DropRoutesAnnotationKey, errors.New("no route is dropped"))
+	}
+
+	drops := make([]envoy.DropRoute, 0, len(specs))
+	for _, spec := range specs {
+		if err := spec.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.DropRoutesAnnotationKey, err)
+		}
+		status := spec.StatusCode
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+		drops = append(drops, envoy.DropRoute{
+			Path:    spec.Path,
+			Exact:   spec.Match == dropMatchExact,
+			Methods: spec.Methods,
+			Status:  status,
+		})
+	}
+	return drops, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestDropRoutesFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []envoy.DropRoute
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "defaults",
+		annotation: `[{"path": "/internal/"}]`,
+		want:       []envoy.DropRoute{{Path: "/internal/", Status: 403}},
+	}, {
+		name:       "all fields",
+		annotation: `[{"path": "/metrics", "match": "exact", "methods": ["GET", "HEAD"], "statusCode": 404}, {"path": "/old", "match": "prefix", "statusCode": 410}]`,
+		want: []envoy.DropRoute{
+			{Path: "/metrics", Exact: true, Methods: []string{"GET", "HEAD"}, Status: 404},
+			{Path: "/old", Status: 410},
+		},
+	}, {
+		name:       "no routes",
+		annotation: `[]`,
+		wantErr:    true,
+	}, {
+		name:       "relative path",
+		annotation: `[{"path": "metrics"}]`,
+		wantErr:    true,
+	}, {
+		name:       "invalid match",
+		annotation: `[{"path": "/metrics", "match": "regex"}]`,
+		wantErr:    true,
+	}, {
+		name:       "lowercase method",
+		annotation: `[{"path": "/metrics", "methods": ["get"]}]`,
+		wantErr:    true,
+	}, {
+		name:       "invalid status code",
+		annotation: `[{"path": "/metrics", "statusCode": 500}]`,
+		wantErr:    true,
+	}, {
+		name:       "unknown field",
+		annotation: `[{"path": "/metrics", "status": 404}]`,
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.DropRoutesAnnotationKey] = test.annotation
+			}
+
+			got, err := dropRoutesFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorDropRoutes(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{
+			pkgconfig.DropRoutesAnnotationKey: `[{"path": "/test/metrics", "match": "exact", "methods": ["GET"], "statusCode": 404}]`,
+		}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+	// The dropped route precedes the route to the backends on every virtual host.
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		assert.Equal(t, len(vh.Routes), 2)
+		assert.Equal(t, vh.Routes[0].Match.GetPath(), "/test/metrics")
+		assert.Equal(t, vh.Routes[0].GetDirectResponse().Status, uint32(404))
+		assert.Assert(t, vh.Routes[1].GetRoute() != nil)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev1 "k8s.io/api/core/v1"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// dynamicForwardProxyFromAnnotations returns whether the requests to the ExternalName
+// services of the ingress are proxied via the dynamic forward proxy of the gateway, as
+// specified via annotations on it.
+func dynamicForwardProxyFromAnnotations(ingress *v1alpha1.Ingress, kourierConfig *pkgconfig.Kourier) (bool, error) {
+	if !strings.EqualFold(pkgconfig.GetDynamicForwardProxy(ingress.Annotations), "true") {
+		return false, nil
+	}
+	if !kourierConfig.DynamicForwardProxy {
+		return false, fmt.Errorf("invalid %s annotation: the dynamic forward proxy is not enabled",
+			pkgconfig.DynamicForwardProxyAnnotationKey)
+	}
+	return true, nil
+}
+
+// isDynamicForwardProxyPath returns whether the requests to the given path, routed to
+// the given service, are proxied via the dynamic forward proxy. Only the paths routing
+// all of their requests to an ExternalName service are, as the proxy connects to a
+// single host per route.
+func isDynamicForwardProxyPath(httpPath v1alpha1.HTTPIngressPath, service *corev1.Service) bool {
+	return len(httpPath.Splits) == 1 && service.Spec.Type == corev1.ServiceTypeExternalName
+}
+
+// dynamicForwardProxyHost returns the host the dynamic forward proxy connects to for
+// the given path, routed to the given port of the ExternalName service: the host the
+// path rewrites the requests to if any, or else the external name of the service. The
+// port is left out if it's the default one of the protocol.
+func dynamicForwardProxyHost(httpPath v1alpha1.HTTPIngressPath, service *corev1.Service, port int32, tls bool) string {
+	host := httpPath.RewriteHost
+	if host == "" {
+		host = service.Spec.ExternalName
+	}
+	if (tls && port == 443) || (!tls && port == 80) {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// dynamicForwardProxyCluster returns the cluster of the dynamic forward proxy, via TLS
+// or plaintext. It's shared by the ingresses, so only the configured connect timeout
+// applies to it.
+func dynamicForwardProxyCluster(tls bool, kourierConfig *pkgconfig.Kourier) (*v3.Cluster, error) {
+	return envoy.NewDynamicForwardProxyCluster(tls, (&timeouts{}).connectTimeout(kourierConfig))
+}
+
+// hasCluster returns whether the given clusters include one of the given name.
+func hasCluster(clusters []*v3.Cluster, name string) bool {
+	for _, cluster := range clusters {
+		if cluster.Name == name {
+			return true
+		}
+	}
+	return false
+}
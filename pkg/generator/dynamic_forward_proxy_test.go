@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	dfpfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/dynamic_forward_proxy/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorDynamicForwardProxy(t *testing.T) {
+	proxied := func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.DynamicForwardProxyAnnotationKey: "true"}
+	}
+	externalName := func(svc *corev1.Service) {
+		svc.Spec.Type = corev1.ServiceTypeExternalName
+		svc.Spec.ExternalName = "api.example.com"
+	}
+
+	tests := []struct {
+		name        string
+		in          *v1alpha1.Ingress
+		service     *corev1.Service
+		disabled    bool
+		wantCluster string
+		wantHost    string
+		wantErr     bool
+	}{{
+		name:        "proxied to the rewritten host",
+		in:          ing("testspace", "testname", proxied),
+		service:     svc("servicens", "servicename", externalName),
+		wantCluster: envoy.DynamicForwardProxyClusterName,
+		wantHost:    "rewritten.example.com",
+	}, {
+		name: "proxied to the external name via TLS",
+		in: ing("testspace", "testname", proxied, func(ing *v1alpha1.Ingress) {
+			ing.Annotations[pkgconfig.ExternalNameTLSAnnotationKey] = "true"
+			ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+		}),
+		service:     svc("servicens", "servicename", externalName),
+		wantCluster: envoy.DynamicForwardProxyTLSClusterName,
+		wantHost:    "api.example.com:80",
+	}, {
+		name:        "not an ExternalName service",
+		in:          ing("testspace", "testname", proxied),
+		service:     svc("servicens", "servicename"),
+		wantCluster: "servicens/servicename",
+	}, {
+		name:     "not enabled",
+		in:       ing("testspace", "testname", proxied),
+		service:  svc("servicens", "servicename", externalName),
+		disabled: true,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.DynamicForwardProxy = !test.disabled
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(test.service, eps("servicens", "servicename"))
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErr {
+				assert.ErrorContains(t, err, pkgconfig.DynamicForwardProxyAnnotationKey)
+				return
+			}
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(got.clusters), 1)
+			assert.Equal(t, got.clusters[0].Name, test.wantCluster)
+
+			r := got.externalVirtualHosts[0].Routes[0]
+			assert.Equal(t, r.GetRoute().GetWeightedClusters().Clusters[0].Name, test.wantCluster)
+			perRouteConfig := r.TypedPerFilterConfig["envoy.filters.http.dynamic_forward_proxy"]
+			if test.wantHost == "" {
+				assert.Assert(t, perRouteConfig == nil)
+				return
+			}
+			host := &dfpfilter.PerRouteConfig{}
+			assert.NilError(t, perRouteConfig.UnmarshalTo(host))
+			assert.Equal(t, host.GetHostRewriteLiteral(), test.wantHost)
+		})
+	}
+}
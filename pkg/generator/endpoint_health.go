@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	corev1 "k8s.io/api/core/v1"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// PodGetter gives access to the pods behind Endpoints. Sources implementing it allow
+// passing the readiness of the pods on to the gateways as the health of the endpoints.
+type PodGetter interface {
+	Pod(ns, name string) (*corev1.Pod, error)
+}
+
+// lbEndpointsWithHealthStatus returns all addresses of the Endpoints, ready or not,
+// along with their health status. The status of their pod is preferred over whether
+// they're listed as ready, as the pod is updated by the kubelet before the Endpoints.
+func lbEndpointsWithHealthStatus(kubeEndpoints *corev1.Endpoints, targetPort int32, podGetter func(ns, name string) (*corev1.Pod, error)) []*endpoint.LbEndpoint {
+	var eps []*endpoint.LbEndpoint
+	for _, subset := range kubeEndpoints.Subsets {
+		for _, address := range subset.Addresses {
+			eps = append(eps, envoy.NewLBEndpointWithHealthStatus(address.IP, uint32(targetPort),
+				addressHealthStatus(address, core.HealthStatus_HEALTHY, podGetter)))
+		}
+		for _, address := range subset.NotReadyAddresses {
+			eps = append(eps, envoy.NewLBEndpointWithHealthStatus(address.IP, uint32(targetPort),
+				addressHealthStatus(address, core.HealthStatus_UNHEALTHY, podGetter)))
+		}
+	}
+	return eps
+}
+
+// addressHealthStatus returns the health status of the pod behind the address, or the
+// given fallback if it's unknown.
+func addressHealthStatus(address corev1.EndpointAddress, fallback core.HealthStatus, podGetter func(ns, name string) (*corev1.Pod, error)) core.HealthStatus {
+	ref := address.TargetRef
+	if podGetter == nil || ref == nil || ref.Kind != "Pod" {
+		return fallback
+	}
+
+	pod, err := podGetter(ref.Namespace, ref.Name)
+	if err != nil || (ref.UID != "" && pod.UID != ref.UID) {
+		// The pod is gone or was replaced by another one of the same name.
+		return fallback
+	}
+	return PodHealthStatus(pod)
+}
+
+// PodHealthStatus returns the health status of the pod: unhealthy unless ready, and
+// degraded while terminating, so that it's only used if no healthy pod is left.
+func PodHealthStatus(pod *corev1.Pod) core.HealthStatus {
+	if !isPodReady(pod) {
+		return core.HealthStatus_UNHEALTHY
+	}
+	if pod.DeletionTimestamp != nil {
+		return core.HealthStatus_DEGRADED
+	}
+	return core.HealthStatus_HEALTHY
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func pod(name string, ready bool, terminating bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "servicens", Name: name},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+	if terminating {
+		p.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	}
+	return p
+}
+
+func podGetterFor(pods ...*corev1.Pod) func(ns, name string) (*corev1.Pod, error) {
+	return func(ns, name string) (*corev1.Pod, error) {
+		for _, p := range pods {
+			if p.Namespace == ns && p.Name == name {
+				return p, nil
+			}
+		}
+		return nil, apierrors.NewNotFound(corev1.Resource("pods"), name)
+	}
+}
+
+func TestPodHealthStatus(t *testing.T) {
+	assert.Equal(t, PodHealthStatus(pod("ready", true, false)), core.HealthStatus_HEALTHY)
+	assert.Equal(t, PodHealthStatus(pod("unready", false, false)), core.HealthStatus_UNHEALTHY)
+	assert.Equal(t, PodHealthStatus(pod("terminating", true, true)), core.HealthStatus_DEGRADED)
+	assert.Equal(t, PodHealthStatus(pod("unready-terminating", false, true)), core.HealthStatus_UNHEALTHY)
+	assert.Equal(t, PodHealthStatus(&corev1.Pod{}), core.HealthStatus_UNHEALTHY)
+}
+
+func TestLBEndpointsWithHealthStatus(t *testing.T) {
+	podRef := func(name string) *corev1.ObjectReference {
+		return &corev1.ObjectReference{Kind: "Pod", Namespace: "servicens", Name: name}
+	}
+	endpoints := eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{
+				{IP: "1.1.1.1", TargetRef: podRef("ready")},
+				{IP: "2.2.2.2", TargetRef: podRef("became-unready")},
+				{IP: "3.3.3.3", TargetRef: podRef("terminating")},
+				{IP: "4.4.4.4", TargetRef: podRef("gone")},
+				{IP: "5.5.5.5"},
+			},
+			NotReadyAddresses: []corev1.EndpointAddress{
+				{IP: "6.6.6.6", TargetRef: podRef("became-ready")},
+				{IP: "7.7.7.7"},
+			},
+		}}
+	})
+	podGetter := podGetterFor(pod("ready", true, false), pod("became-unready", false, false),
+		pod("terminating", true, true), pod("became-ready", true, false))
+
+	want := []*endpoint.LbEndpoint{
+		envoy.NewLBEndpointWithHealthStatus("1.1.1.1", 8080, core.HealthStatus_HEALTHY),
+		envoy.NewLBEndpointWithHealthStatus("2.2.2.2", 8080, core.HealthStatus_UNHEALTHY),
+		envoy.NewLBEndpointWithHealthStatus("3.3.3.3", 8080, core.HealthStatus_DEGRADED),
+		// Without a pod, whether the address is listed as ready is used.
+		envoy.NewLBEndpointWithHealthStatus("4.4.4.4", 8080, core.HealthStatus_HEALTHY),
+		envoy.NewLBEndpointWithHealthStatus("5.5.5.5", 8080, core.HealthStatus_HEALTHY),
+		envoy.NewLBEndpointWithHealthStatus("6.6.6.6", 8080, core.HealthStatus_HEALTHY),
+		envoy.NewLBEndpointWithHealthStatus("7.7.7.7", 8080, core.HealthStatus_UNHEALTHY),
+	}
+	assert.DeepEqual(t, lbEndpointsWithHealthStatus(endpoints, 8080, podGetter), want, protocmp.Transform())
+
+	// Without a pod getter, only the Endpoints are used.
+	got := lbEndpointsWithHealthStatus(endpoints, 8080, nil)
+	assert.Equal(t, got[1].HealthStatus, core.HealthStatus_HEALTHY)
+	assert.Equal(t, got[5].HealthStatus, core.HealthStatus_UNHEALTHY)
+
+	assert.Assert(t, lbEndpointsWithHealthStatus(&corev1.Endpoints{}, 8080, podGetter) == nil)
+}
+
+func TestUpdateEndpointsWithHealthStatus(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	readyPod := pod("pod", true, false)
+	caches.SetPodGetter(podGetterFor(readyPod))
+
+	endpoints := eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{
+				IP:        "1.1.1.1",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "servicens", Name: "pod"},
+			}},
+		}}
+	})
+	translated := &translatedIngress{
+		name: types.NamespacedName{Namespace: "ingressns", Name: "ingress"},
+		clusters: []*v3.Cluster{
+			envoy.NewCluster("servicens/servicename", 5*time.Second, nil, false, nil, v3.Cluster_STATIC),
+		},
+		endpointsTargetPorts:  map[string]int32{"servicens/servicename": 8080},
+		endpointsHealthStatus: true,
+	}
+	assert.NilError(t, caches.UpdateIngress(context.Background(), translated))
+
+	// The pod starts terminating before its Endpoints are updated.
+	readyPod.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	assert.Assert(t, caches.UpdateEndpoints(endpoints))
+	assert.DeepEqual(t, caches.translatedIngresses[translated.name].clusters[0].LoadAssignment.Endpoints[0].LbEndpoints,
+		[]*endpoint.LbEndpoint{envoy.NewLBEndpointWithHealthStatus("1.1.1.1", 8080, core.HealthStatus_DEGRADED)},
+		protocmp.Transform())
+}
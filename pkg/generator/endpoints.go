@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// UpdateEndpoints updates the endpoints of the clusters built from the given Endpoints
+// in place, without translating the ingresses referencing their service again. It
+// returns whether any cluster was updated. If not, the ingresses referencing the
+// service have to be translated again to pick up the Endpoints.
+func (caches *Caches) UpdateEndpoints(endpoints *corev1.Endpoints) bool {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	// The clusters are named after the key of their service.
+	clusterName := types.NamespacedName{Namespace: endpoints.Namespace, Name: endpoints.Name}.String()
+	updated := false
+	for _, translated := range caches.translatedIngresses {
+		if caches.setClusterEndpoints(translated, clusterName, endpoints) {
+			updated = true
+		}
+	}
+
+	// Ingresses might be translated concurrently from outdated Endpoints. Remember the
+	// latest ones to apply them once such an ingress is added.
+	if updated {
+		caches.endpoints[clusterName] = endpoints
+	} else {
+		delete(caches.endpoints, clusterName)
+	}
+	return updated
+}
+
+// SetPodGetter sets the getter of the pods behind the Endpoints, whose status is passed
+// on as the health of the endpoints if enabled.
+func (caches *Caches) SetPodGetter(podGetter func(ns, name string) (*corev1.Pod, error)) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	caches.podGetter = podGetter
+}
+
+// ForgetEndpoints drops the Endpoints of the given service remembered by
+// UpdateEndpoints, e.g. because they were deleted or recreated.
+func (caches *Caches) ForgetEndpoints(key types.NamespacedName) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	delete(caches.endpoints, key.String())
+}
+
+// applyLatestEndpoints updates the clusters of the given ingress with the latest
+// Endpoints seen by UpdateEndpoints.
+func (caches *Caches) applyLatestEndpoints(translated *translatedIngress) {
+	for clusterName := range translated.endpointsTargetPorts {
+		if endpoints, ok := caches.endpoints[clusterName]; ok {
+			caches.setClusterEndpoints(translated, clusterName, endpoints)
+		}
+	}
+}
+
+// setClusterEndpoints replaces the endpoints of the ingress' cluster with the given name
+// and returns whether it has such a cluster. The translation of the ingress is replaced
+// by an updated copy rather than modified, as it is shared with the translation cache,
+// whose entries must keep matching the objects they were translated from.
+func (caches *Caches) setClusterEndpoints(translated *translatedIngress, clusterName string, endpoints *corev1.Endpoints) bool {
+	targetPort, ok := translated.endpointsTargetPorts[clusterName]
+	if !ok {
+		return false
+	}
+
+	var lbEndpoints []*endpoint.LbEndpoint
+	if translated.endpointsHealthStatus {
+		lbEndpoints = lbEndpointsWithHealthStatus(endpoints, targetPort, caches.podGetter)
+	} else {
+		lbEndpoints = lbEndpointsForKubeEndpoints(endpoints, targetPort, translated.endpointsDraining)
+	}
+	updated := *translated
+	updated.clusters = make([]*v3.Cluster, len(translated.clusters))
+	copy(updated.clusters, translated.clusters)
+	for i, cluster := range updated.clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+
+		// Snapshots handed out before still reference the cluster, so it must not be
+		// modified.
+		cluster = proto.Clone(cluster).(*v3.Cluster)
+		cluster.LoadAssignment.Endpoints = []*endpoint.LocalityLbEndpoints{{
+			LbEndpoints: lbEndpoints,
+		}}
+		updated.clusters[i] = cluster
+		caches.clusters.set(cluster, translated.name.Name, translated.name.Namespace)
+	}
+	caches.translatedIngresses[translated.name] = &updated
+	return true
+}
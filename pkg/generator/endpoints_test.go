@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestUpdateEndpoints(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	oldEndpoints := eps("servicens", "servicename")
+	newEndpoints := eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "6.6.6.6"}},
+		}}
+	})
+
+	translate := func(name string, endpoints *corev1.Endpoints) *translatedIngress {
+		return &translatedIngress{
+			name: types.NamespacedName{Namespace: "ingressns", Name: name},
+			clusters: []*v3.Cluster{
+				envoy.NewCluster("servicens/servicename", 5*time.Second,
+					lbEndpointsForKubeEndpoints(endpoints, 8080, false), false, nil, v3.Cluster_STATIC),
+			},
+			endpointsTargetPorts: map[string]int32{"servicens/servicename": 8080},
+		}
+	}
+	wantLbEndpoints := []*endpoint.LbEndpoint{envoy.NewLBEndpoint("6.6.6.6", 8080)}
+
+	translated := translate("ingress", oldEndpoints)
+	oldCluster := translated.clusters[0]
+	assert.NilError(t, caches.UpdateIngress(context.Background(), translated))
+
+	t.Run("updates the clusters of the service", func(t *testing.T) {
+		assert.Assert(t, caches.UpdateEndpoints(newEndpoints))
+
+		assert.DeepEqual(t, caches.translatedIngresses[translated.name].clusters[0].LoadAssignment.Endpoints[0].LbEndpoints, wantLbEndpoints, protocmp.Transform())
+		assert.DeepEqual(t, caches.clusters.list()[0].(*v3.Cluster).LoadAssignment.Endpoints[0].LbEndpoints, wantLbEndpoints, protocmp.Transform())
+
+		// The cluster of the previous snapshots is left alone.
+		assert.DeepEqual(t, oldCluster.LoadAssignment.Endpoints[0].LbEndpoints,
+			lbEndpointsForKubeEndpoints(oldEndpoints, 8080, false), protocmp.Transform())
+
+		// So is the translation, which the translation cache might still hand out.
+		assert.Assert(t, translated.clusters[0] == oldCluster)
+	})
+
+	t.Run("applies the latest endpoints to outdated translations", func(t *testing.T) {
+		outdated := translate("ingress", oldEndpoints)
+		assert.NilError(t, caches.UpdateIngress(context.Background(), outdated))
+
+		assert.DeepEqual(t, caches.translatedIngresses[outdated.name].clusters[0].LoadAssignment.Endpoints[0].LbEndpoints, wantLbEndpoints, protocmp.Transform())
+	})
+
+	t.Run("forgets the latest endpoints", func(t *testing.T) {
+		caches.ForgetEndpoints(types.NamespacedName{Namespace: "servicens", Name: "servicename"})
+
+		translated := translate("ingress", oldEndpoints)
+		assert.NilError(t, caches.UpdateIngress(context.Background(), translated))
+
+		assert.DeepEqual(t, translated.clusters[0].LoadAssignment.Endpoints[0].LbEndpoints,
+			lbEndpointsForKubeEndpoints(oldEndpoints, 8080, false), protocmp.Transform())
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		assert.Assert(t, !caches.UpdateEndpoints(eps("servicens", "other")))
+	})
+}
+
+func TestLBEndpointsForKubeEndpoints(t *testing.T) {
+	endpoints := eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "1.1.1.1"}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "2.2.2.2"}},
+		}}
+	})
+
+	assert.DeepEqual(t, lbEndpointsForKubeEndpoints(endpoints, 8080, false), []*endpoint.LbEndpoint{
+		envoy.NewLBEndpoint("1.1.1.1", 8080),
+	}, protocmp.Transform())
+
+	// The not ready addresses are kept to complete the requests in flight.
+	assert.DeepEqual(t, lbEndpointsForKubeEndpoints(endpoints, 8080, true), []*endpoint.LbEndpoint{
+		envoy.NewLBEndpoint("1.1.1.1", 8080),
+		envoy.NewLBEndpointWithHealthStatus("2.2.2.2", 8080, core.HealthStatus_DRAINING),
+	}, protocmp.Transform())
+
+	// The last addresses are kept while draining as well.
+	draining := eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "2.2.2.2"}},
+		}}
+	})
+	assert.Assert(t, lbEndpointsForKubeEndpoints(draining, 8080, false) == nil)
+	assert.Equal(t, len(lbEndpointsForKubeEndpoints(draining, 8080, true)), 1)
+
+	assert.Assert(t, lbEndpointsForKubeEndpoints(&corev1.Endpoints{}, 8080, true) == nil)
+}
+
+func TestUpdateEndpointsDraining(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	translated := &translatedIngress{
+		name: types.NamespacedName{Namespace: "ingressns", Name: "ingress"},
+		clusters: []*v3.Cluster{
+			envoy.NewCluster("servicens/servicename", 5*time.Second, nil, false, nil, v3.Cluster_STATIC),
+		},
+		endpointsTargetPorts: map[string]int32{"servicens/servicename": 8080},
+		endpointsDraining:    true,
+	}
+	assert.NilError(t, caches.UpdateIngress(context.Background(), translated))
+
+	// The pod starts terminating.
+	assert.Assert(t, caches.UpdateEndpoints(eps("servicens", "servicename", func(eps *corev1.Endpoints) {
+		eps.Subsets = []corev1.EndpointSubset{{
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "1.1.1.1"}},
+		}}
+	})))
+	assert.DeepEqual(t, caches.translatedIngresses[translated.name].clusters[0].LoadAssignment.Endpoints[0].LbEndpoints, []*endpoint.LbEndpoint{
+		envoy.NewLBEndpointWithHealthStatus("1.1.1.1", 8080, core.HealthStatus_DRAINING),
+	}, protocmp.Transform())
+}
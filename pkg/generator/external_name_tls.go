@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strings"
+
+	envoycorev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// isExternalNameTLS returns whether the backends of the ExternalName services of the
+// ingress are reached via TLS, rather than plaintext.
+func isExternalNameTLS(ingress *v1alpha1.Ingress) bool {
+	return strings.EqualFold(pkgconfig.GetExternalNameTLS(ingress.Annotations), "true")
+}
+
+// externalNameTransportSocket creates the transport socket reaching the backend of an
+// ExternalName service at the given host via TLS.
+func externalNameTransportSocket(host string, http2 bool) (*envoycorev3.TransportSocket, error) {
+	var alpnProtocols []string
+	if http2 {
+		alpnProtocols = []string{"h2"}
+	}
+
+	tlsAny, err := anypb.New(envoy.NewExternalUpstreamTLSContext(host, alpnProtocols...))
+	if err != nil {
+		return nil, err
+	}
+	return &envoycorev3.TransportSocket{
+		Name: wellknown.TransportSocketTls,
+		ConfigType: &envoycorev3.TransportSocket_TypedConfig{
+			TypedConfig: tlsAny,
+		},
+	}, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The keys of the resources generated for an ingress, as returned by
+// GeneratedResourcesJSON.
+const (
+	GeneratedClustersKey                = "clusters.json"
+	GeneratedExternalVirtualHostsKey    = "external-virtual-hosts.json"
+	GeneratedExternalTLSVirtualHostsKey = "external-tls-virtual-hosts.json"
+	GeneratedInternalVirtualHostsKey    = "internal-virtual-hosts.json"
+)
+
+// GeneratedResources counts the resources generated for an ingress.
+type GeneratedResources struct {
+	// Routes is the number of routes across the virtual hosts of the ingress.
+	Routes int
+	// Clusters is the number of clusters of the ingress.
+	Clusters int
+	// SNIMatches is the number of SNI matches of the TLS hosts of the ingress.
+	SNIMatches int
+	// AnnotationConflicts are the warnings about the annotations of the ingress ignored in
+	// favor of the ones taking precedence.
+	AnnotationConflicts []string
+}
+
+// GeneratedResources returns the counts of the resources generated for the given ingress.
+// It returns false if the ingress isn't in the caches.
+func (caches *Caches) GeneratedResources(name types.NamespacedName) (GeneratedResources, bool) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	translated, ok := caches.translatedIngresses[name]
+	if !ok {
+		return GeneratedResources{}, false
+	}
+
+	resources := GeneratedResources{
+		Clusters:            len(translated.clusters),
+		SNIMatches:          len(translated.sniMatches),
+		AnnotationConflicts: translated.annotationConflicts,
+	}
+	for _, vhosts := range [][]*route.VirtualHost{
+		translated.externalVirtualHosts,
+		translated.externalTLSVirtualHosts,
+		translated.internalVirtualHosts,
+	} {
+		for _, vhost := range vhosts {
+			resources.Routes += len(vhost.Routes)
+		}
+	}
+	return resources, true
+}
+
+// ExternalHosts returns the hosts of the external virtual hosts generated for the given
+// ingress, sorted. It returns false if the ingress isn't in the caches.
+func (caches *Caches) ExternalHosts(name types.NamespacedName) ([]string, bool) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	translated, ok := caches.translatedIngresses[name]
+	if !ok {
+		return nil, false
+	}
+
+	hosts := sets.NewString()
+	for _, vhosts := range [][]*route.VirtualHost{
+		translated.externalVirtualHosts,
+		translated.externalTLSVirtualHosts,
+	} {
+		for _, vhost := range vhosts {
+			for _, host := range vhost.Domains {
+				// Every host is also matched with any port.
+				if !strings.HasSuffix(host, ":*") {
+					hosts.Insert(host)
+				}
+			}
+		}
+	}
+	return hosts.List(), true
+}
+
+// GeneratedResourcesJSON returns the clusters and virtual hosts generated for the given
+// ingress, each kind as an indented JSON array keyed by its Generated*Key. The output is
+// stable for the same resources, so that it can be diffed. It returns false if the
+// ingress isn't in the caches.
+func (caches *Caches) GeneratedResourcesJSON(name types.NamespacedName) (map[string]string, bool, error) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	translated, ok := caches.translatedIngresses[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	resources := map[string][]proto.Message{
+		GeneratedClustersKey:                make([]proto.Message, 0, len(translated.clusters)),
+		GeneratedExternalVirtualHostsKey:    make([]proto.Message, 0, len(translated.externalVirtualHosts)),
+		GeneratedExternalTLSVirtualHostsKey: make([]proto.Message, 0, len(translated.externalTLSVirtualHosts)),
+		GeneratedInternalVirtualHostsKey:    make([]proto.Message, 0, len(translated.internalVirtualHosts)),
+	}
+	for _, cluster := range translated.clusters {
+		resources[GeneratedClustersKey] = append(resources[GeneratedClustersKey], cluster)
+	}
+	for key, vhosts := range map[string][]*route.VirtualHost{
+		GeneratedExternalVirtualHostsKey:    translated.externalVirtualHosts,
+		GeneratedExternalTLSVirtualHostsKey: translated.externalTLSVirtualHosts,
+		GeneratedInternalVirtualHostsKey:    translated.internalVirtualHosts,
+	} {
+		for _, vhost := range vhosts {
+			resources[key] = append(resources[key], vhost)
+		}
+	}
+
+	data := make(map[string]string, len(resources))
+	for key, messages := range resources {
+		marshaled, err := marshalResourcesJSON(messages)
+		if err != nil {
+			return nil, false, err
+		}
+		data[key] = marshaled
+	}
+	return data, true, nil
+}
+
+// marshalResourcesJSON marshals the given resources to an indented JSON array. protojson
+// randomizes its whitespace, so it's normalized by re-indenting the output.
+func marshalResourcesJSON(messages []proto.Message) (string, error) {
+	raw := make([]json.RawMessage, 0, len(messages))
+	for _, message := range messages {
+		marshaled, err := protojson.Marshal(message)
+		if err != nil {
+			return "", err
+		}
+		raw = append(raw, marshaled)
+	}
+
+	compact, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact, "", "  "); err != nil {
+		return "", err
+	}
+	return indented.String(), nil
+}
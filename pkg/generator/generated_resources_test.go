@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestGeneratedResources(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	name := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	_, ok := caches.GeneratedResources(name)
+	assert.Assert(t, !ok)
+
+	routes := []*route.Route{
+		envoy.NewRoute("ing-a", nil, "/a", nil, 0, nil, ""),
+		envoy.NewRoute("ing-b", nil, "/b", nil, 0, nil, ""),
+	}
+	vhost := envoy.NewVirtualHost("ing", []string{"ing.example.com"}, routes)
+	assert.NilError(t, caches.addTranslatedIngress(&translatedIngress{
+		name:                    name,
+		sniMatches:              []*envoy.SNIMatch{{Hosts: []string{"ing.example.com"}}},
+		clusters:                []*v3.Cluster{envoy.NewCluster("ing", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)},
+		externalVirtualHosts:    []*route.VirtualHost{vhost},
+		externalTLSVirtualHosts: []*route.VirtualHost{vhost},
+		internalVirtualHosts:    []*route.VirtualHost{vhost},
+		annotationConflicts:     []string{"a is ignored in favor of b"},
+	}, false))
+
+	got, ok := caches.GeneratedResources(name)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, got, GeneratedResources{
+		Routes:              6,
+		Clusters:            1,
+		SNIMatches:          1,
+		AnnotationConflicts: []string{"a is ignored in favor of b"},
+	})
+}
+
+func TestExternalHosts(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	name := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	_, ok := caches.ExternalHosts(name)
+	assert.Assert(t, !ok)
+
+	routes := []*route.Route{envoy.NewRoute("ing-a", nil, "/a", nil, 0, nil, "")}
+	assert.NilError(t, caches.addTranslatedIngress(&translatedIngress{
+		name: name,
+		externalVirtualHosts: []*route.VirtualHost{
+			envoy.NewVirtualHost("ing", []string{"ing.example.com", "ing.example.com:*"}, routes),
+			envoy.NewVirtualHost("preview", []string{"preview.example.com"}, routes),
+		},
+		externalTLSVirtualHosts: []*route.VirtualHost{
+			envoy.NewVirtualHost("ing", []string{"ing.example.com", "ing.example.com:*"}, routes),
+		},
+		internalVirtualHosts: []*route.VirtualHost{
+			envoy.NewVirtualHost("ing", []string{"ing.ns.svc.cluster.local"}, routes),
+		},
+	}, false))
+
+	got, ok := caches.ExternalHosts(name)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, got, []string{"ing.example.com", "preview.example.com"})
+}
+
+func TestGeneratedResourcesJSON(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	name := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	_, ok, err := caches.GeneratedResourcesJSON(name)
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	vhost := envoy.NewVirtualHost("ing", []string{"ing.example.com"},
+		[]*route.Route{envoy.NewRoute("ing-a", nil, "/a", nil, 0, nil, "")})
+	assert.NilError(t, caches.addTranslatedIngress(&translatedIngress{
+		name:                 name,
+		clusters:             []*v3.Cluster{envoy.NewCluster("ing", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)},
+		externalVirtualHosts: []*route.VirtualHost{vhost},
+	}, false))
+
+	got, ok, err := caches.GeneratedResourcesJSON(name)
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, got[GeneratedExternalTLSVirtualHostsKey], "[]")
+	assert.Equal(t, got[GeneratedInternalVirtualHostsKey], "[]")
+
+	var clusters []map[string]interface{}
+	assert.NilError(t, json.Unmarshal([]byte(got[GeneratedClustersKey]), &clusters))
+	assert.Equal(t, len(clusters), 1)
+	assert.Equal(t, clusters[0]["name"], "ing")
+	assert.Equal(t, clusters[0]["connectTimeout"], "5s")
+
+	var vhosts []map[string]interface{}
+	assert.NilError(t, json.Unmarshal([]byte(got[GeneratedExternalVirtualHostsKey]), &vhosts))
+	assert.Equal(t, len(vhosts), 1)
+	assert.Equal(t, vhosts[0]["name"], "ing")
+
+	// The output is stable, so that it can be diffed.
+	again, _, err := caches.GeneratedResourcesJSON(name)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, again, got)
+}
@@ -19,7 +19,9 @@ package generator
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/networking/pkg/ingress"
 )
@@ -32,7 +34,16 @@ func UpdateInfoForIngress(ctx context.Context, caches *Caches, ing *v1alpha1.Ing
 		return fmt.Errorf("failed to add knative probe header: %w", err)
 	}
 
-	ingressTranslation, err := translator.translateIngress(ctx, ing, extAuthzEnabled)
+	ingressTranslation, err := translator.translate(ctx, ing, extAuthzEnabled)
+	if err != nil && isTransientError(err) {
+		// Dropping the ingress from the snapshots would make its hosts unreachable until
+		// the error goes away, so its last successful translation is served meanwhile.
+		name := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+		if since, ok := caches.keepStaleIngress(name, translator.now()); ok {
+			return fmt.Errorf("%w since %s: failed to translate ingress: %v",
+				ErrStaleTranslation, since.Format(time.RFC3339), err)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to translate ingress: %w", err)
 	}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// descriptorSetFieldInSecret is the field of the secret referenced by the
+// GRPCJSONTranscoderAnnotationKey annotation holding the protobuf descriptor set.
+const descriptorSetFieldInSecret = "descriptor.pb"
+
+// grpcJSONTranscoder is the descriptor set and the services the JSON requests to the hosts
+// of an ingress are transcoded into gRPC requests of.
+type grpcJSONTranscoder struct {
+	descriptorSet []byte
+	services      []string
+}
+
+// grpcJSONTranscoder returns the transcoder requested by the annotations of the given
+// ingress, or nil if it doesn't request one or transcoding isn't enabled. The JSON
+// requests are transcoded into gRPC requests of all services in the descriptor set.
+func (translator *IngressTranslator) grpcJSONTranscoder(ctx context.Context, ingress *v1alpha1.Ingress) (*grpcJSONTranscoder, error) {
+	secretName := pkgconfig.GetGRPCJSONTranscoder(ingress.Annotations)
+	if secretName == "" || !config.FromContextOrDefaults(ctx).Kourier.GRPCJSONTranscoding {
+		return nil, nil
+	}
+
+	if err := trackSecret(translator.tracker, ingress.Namespace, secretName, ingress); err != nil {
+		return nil, err
+	}
+	secret, err := translator.secretGetter(ingress.Namespace, secretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gRPC JSON transcoder secret: %w", err)
+	}
+
+	descriptorSet := secret.Data[descriptorSetFieldInSecret]
+	files := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(descriptorSet, files); err != nil {
+		return nil, fmt.Errorf("gRPC JSON transcoder secret '%s/%s' has an invalid %q field: %w",
+			ingress.Namespace, secretName, descriptorSetFieldInSecret, err)
+	}
+
+	var services []string
+	for _, file := range files.File {
+		for _, service := range file.Service {
+			if file.GetPackage() != "" {
+				services = append(services, file.GetPackage()+"."+service.GetName())
+			} else {
+				services = append(services, service.GetName())
+			}
+		}
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("gRPC JSON transcoder secret '%s/%s' describes no services in its %q field",
+			ingress.Namespace, secretName, descriptorSetFieldInSecret)
+	}
+
+	return &grpcJSONTranscoder{
+		descriptorSet: descriptorSet,
+		services:      services,
+	}, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorGRPCJSONTranscoder(t *testing.T) {
+	descriptorSet := func(files ...*descriptorpb.FileDescriptorProto) []byte {
+		b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: files})
+		assert.NilError(t, err)
+		return b
+	}
+	greeter := descriptorSet(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("helloworld.proto"),
+		Package: proto.String("helloworld"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Greeter")}},
+	}, &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("health.proto"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Health")}},
+	})
+
+	transcoded := func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.GRPCJSONTranscoderAnnotationKey: "descriptors"}
+	}
+
+	tests := []struct {
+		name         string
+		in           *v1alpha1.Ingress
+		disabled     bool
+		data         []byte
+		wantServices []string
+		wantErr      bool
+	}{{
+		name:         "transcoded",
+		in:           ing("testspace", "testname", transcoded),
+		data:         greeter,
+		wantServices: []string{"helloworld.Greeter", "Health"},
+	}, {
+		name: "not requested",
+		in:   ing("testspace", "testname"),
+		data: greeter,
+	}, {
+		name:     "transcoding disabled",
+		in:       ing("testspace", "testname", transcoded),
+		disabled: true,
+		data:     greeter,
+	}, {
+		name:    "invalid descriptor set",
+		in:      ing("testspace", "testname", transcoded),
+		data:    []byte("invalid"),
+		wantErr: true,
+	}, {
+		name:    "no services",
+		in:      ing("testspace", "testname", transcoded),
+		data:    descriptorSet(&descriptorpb.FileDescriptorProto{Name: proto.String("empty.proto")}),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.GRPCJSONTranscoding = !test.disabled
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(
+				ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "testspace", Name: "descriptors"},
+					Data:       map[string][]byte{descriptorSetFieldInSecret: test.data},
+				},
+			)
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Assert(t, len(got.internalVirtualHosts) != 0)
+			assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+			for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+				filter := vh.TypedPerFilterConfig[wellknown.GRPCJSONTranscoder]
+				if test.wantServices == nil {
+					assert.Assert(t, filter == nil)
+					continue
+				}
+				transcoderConfig := &transcoder.GrpcJsonTranscoder{}
+				assert.NilError(t, filter.UnmarshalTo(transcoderConfig))
+				assert.DeepEqual(t, transcoderConfig.GetProtoDescriptorBin(), test.data)
+				assert.DeepEqual(t, transcoderConfig.Services, test.wantServices)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+const (
+	// healthCheckTCP is the value of the health check annotation for checking the
+	// backends by connecting to them.
+	healthCheckTCP = "tcp"
+
+	// defaultHealthCheckInterval is the interval of the health checks unless specified.
+	defaultHealthCheckInterval = 10 * time.Second
+)
+
+// externalNameHealthCheck is the active health check of the backends of the ExternalName
+// services of an Ingress.
+type externalNameHealthCheck struct {
+	// path is the path requested by the HTTP health checks, empty for TCP health checks.
+	path     string
+	interval time.Duration
+}
+
+// externalNameHealthCheckFromAnnotations returns the health check of the ExternalName
+// services of the Ingress, as specified via annotations on it, or nil if there's none.
+func externalNameHealthCheckFromAnnotations(annotations map[string]string) (*externalNameHealthCheck, error) {
+	check := pkgconfig.GetExternalNameHealthCheck(annotations)
+	rawInterval := pkgconfig.GetExternalNameHealthCheckInterval(annotations)
+	if check == "" {
+		if rawInterval != "" {
+			return nil, fmt.Errorf("invalid %s annotation: requires the %s annotation",
+				pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey, pkgconfig.ExternalNameHealthCheckAnnotationKey)
+		}
+		return nil, nil
+	}
+
+	healthCheck := &externalNameHealthCheck{interval: defaultHealthCheckInterval}
+	switch {
+	case strings.EqualFold(check, healthCheckTCP):
+	case strings.HasPrefix(check, "/"):
+		healthCheck.path = check
+	default:
+		return nil, fmt.Errorf("invalid %s annotation: must be %q or a path starting with \"/\", was: %q",
+			pkgconfig.ExternalNameHealthCheckAnnotationKey, healthCheckTCP, check)
+	}
+
+	if rawInterval != "" {
+		interval, err := time.ParseDuration(rawInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation: must be positive, was: %v",
+				pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey, interval)
+		}
+		healthCheck.interval = interval
+	}
+	return healthCheck, nil
+}
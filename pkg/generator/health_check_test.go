@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestExternalNameHealthCheckFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *externalNameHealthCheck
+		wantErr     bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "HTTP",
+		annotations: map[string]string{pkgconfig.ExternalNameHealthCheckAnnotationKey: "/healthz"},
+		want:        &externalNameHealthCheck{path: "/healthz", interval: defaultHealthCheckInterval},
+	}, {
+		name: "TCP with interval",
+		annotations: map[string]string{
+			pkgconfig.ExternalNameHealthCheckAnnotationKey:         "TCP",
+			pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey: "30s",
+		},
+		want: &externalNameHealthCheck{interval: 30 * time.Second},
+	}, {
+		name:        "invalid check",
+		annotations: map[string]string{pkgconfig.ExternalNameHealthCheckAnnotationKey: "healthz"},
+		wantErr:     true,
+	}, {
+		name: "invalid interval",
+		annotations: map[string]string{
+			pkgconfig.ExternalNameHealthCheckAnnotationKey:         "/healthz",
+			pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey: "0s",
+		},
+		wantErr: true,
+	}, {
+		name:        "interval without check",
+		annotations: map[string]string{pkgconfig.ExternalNameHealthCheckIntervalAnnotationKey: "30s"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := externalNameHealthCheckFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(externalNameHealthCheck{}))
+		})
+	}
+}
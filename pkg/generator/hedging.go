@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+const (
+	// defaultHedgeNumRetries is the number of additional attempts of the hedged requests
+	// if none is specified.
+	defaultHedgeNumRetries = 1
+
+	// maxHedgeNumRetries caps the number of additional attempts of the hedged requests,
+	// as each of them multiplies the load on the backends.
+	maxHedgeNumRetries = 5
+)
+
+// hedging races additional attempts of the requests to an Ingress against the ones
+// taking longer than the per try timeout.
+type hedging struct {
+	perTryTimeout time.Duration
+	numRetries    uint32
+}
+
+// hedgingFromAnnotations returns the hedging of the requests to the Ingress, as specified
+// via annotations on it. Returns nil if the requests aren't hedged.
+func hedgingFromAnnotations(annotations map[string]string) (*hedging, error) {
+	rawTimeout := pkgconfig.GetHedgePerTryTimeout(annotations)
+	rawRetries := pkgconfig.GetHedgeNumRetries(annotations)
+	if rawTimeout == "" {
+		if rawRetries != "" {
+			return nil, fmt.Errorf("invalid %s annotation: requires the %s annotation",
+				pkgconfig.HedgeNumRetriesAnnotationKey, pkgconfig.HedgePerTryTimeoutAnnotationKey)
+		}
+		return nil, nil
+	}
+
+	perTryTimeout, err := time.ParseDuration(rawTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.HedgePerTryTimeoutAnnotationKey, err)
+	}
+	if perTryTimeout <= 0 {
+		return nil, fmt.Errorf("invalid %s annotation: must be positive, was: %v", pkgconfig.HedgePerTryTimeoutAnnotationKey, perTryTimeout)
+	}
+
+	h := &hedging{perTryTimeout: perTryTimeout, numRetries: defaultHedgeNumRetries}
+	if rawRetries != "" {
+		numRetries, err := strconv.ParseUint(rawRetries, 10, 32)
+		if err != nil || numRetries == 0 || numRetries > maxHedgeNumRetries {
+			return nil, fmt.Errorf("invalid %s annotation: must be a number between 1 and %d, was: %q",
+				pkgconfig.HedgeNumRetriesAnnotationKey, maxHedgeNumRetries, rawRetries)
+		}
+		h.numRetries = uint32(numRetries)
+	}
+	return h, nil
+}
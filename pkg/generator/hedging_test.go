@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestHedgingFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *hedging
+		wantErr     bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "per try timeout",
+		annotations: map[string]string{pkgconfig.HedgePerTryTimeoutAnnotationKey: "200ms"},
+		want:        &hedging{perTryTimeout: 200 * time.Millisecond, numRetries: 1},
+	}, {
+		name: "retries",
+		annotations: map[string]string{
+			pkgconfig.HedgePerTryTimeoutAnnotationKey: "1s",
+			pkgconfig.HedgeNumRetriesAnnotationKey:    "3",
+		},
+		want: &hedging{perTryTimeout: time.Second, numRetries: 3},
+	}, {
+		name:        "retries without timeout",
+		annotations: map[string]string{pkgconfig.HedgeNumRetriesAnnotationKey: "3"},
+		wantErr:     true,
+	}, {
+		name:        "invalid timeout",
+		annotations: map[string]string{pkgconfig.HedgePerTryTimeoutAnnotationKey: "soon"},
+		wantErr:     true,
+	}, {
+		name:        "zero timeout",
+		annotations: map[string]string{pkgconfig.HedgePerTryTimeoutAnnotationKey: "0s"},
+		wantErr:     true,
+	}, {
+		name: "too many retries",
+		annotations: map[string]string{
+			pkgconfig.HedgePerTryTimeoutAnnotationKey: "1s",
+			pkgconfig.HedgeNumRetriesAnnotationKey:    "10",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := hedgingFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(hedging{}))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// hostRedirect permanently redirects the requests to a host to another one.
+type hostRedirect struct {
+	from string
+	to   string
+}
+
+// hostRedirectsFromAnnotations returns the host redirects of the Ingress, as specified
+// via annotations on it. The redirected hosts can't be hosts of the Ingress itself.
+func hostRedirectsFromAnnotations(ingress *v1alpha1.Ingress) ([]hostRedirect, error) {
+	raw := pkgconfig.GetHostRedirects(ingress.Annotations)
+	if raw == "" {
+		return nil, nil
+	}
+
+	hosts := sets.NewString()
+	for _, rule := range ingress.Spec.Rules {
+		hosts.Insert(rule.Hosts...)
+	}
+
+	var redirects []hostRedirect
+	seen := sets.NewString()
+	for _, pair := range strings.Split(raw, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || len(validation.IsDNS1123Subdomain(from)) != 0 || len(validation.IsDNS1123Subdomain(to)) != 0 || from == to {
+			return nil, fmt.Errorf("invalid %s annotation: %q is not of the form \"<from>=<to>\" with distinct hosts",
+				pkgconfig.HostRedirectsAnnotationKey, pair)
+		}
+		if hosts.Has(from) {
+			return nil, fmt.Errorf("invalid %s annotation: %q is served by the Ingress", pkgconfig.HostRedirectsAnnotationKey, from)
+		}
+		if seen.Has(from) {
+			return nil, fmt.Errorf("invalid %s annotation: %q is redirected twice", pkgconfig.HostRedirectsAnnotationKey, from)
+		}
+		seen.Insert(from)
+		redirects = append(redirects, hostRedirect{from: from, to: to})
+	}
+	return redirects, nil
+}
+
+// virtualHost creates the virtual host redirecting the requests of the ingress with the
+// given name. They're redirected to HTTPS as well, if httpsRedirect is set.
+func (r hostRedirect) virtualHost(ingress *v1alpha1.Ingress, stripHostPort, httpsRedirect bool) *route.VirtualHost {
+	name := fmt.Sprintf("(%s/%s).HostRedirects[%s]", ingress.Namespace, ingress.Name, r.from)
+	domains := domainsForRule(v1alpha1.IngressRule{Hosts: []string{r.from}}, stripHostPort)
+	return envoy.NewVirtualHost(name, domains, []*route.Route{envoy.NewHostRedirectRoute(name, r.to, httpsRedirect)})
+}
+
+// anyRedirectedDomain returns whether any of the given domains is redirected by an ingress
+// other than the given one.
+func (caches *Caches) anyRedirectedDomain(name types.NamespacedName, domains []string) bool {
+	for otherName, other := range caches.translatedIngresses {
+		if otherName != name && sets.NewString(other.redirectedDomains...).HasAny(domains...) {
+			return true
+		}
+	}
+	return false
+}
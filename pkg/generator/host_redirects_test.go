@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestHostRedirectsFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []hostRedirect
+		wantErr bool
+	}{{
+		name: "none",
+	}, {
+		name:  "apex to www and between domains",
+		value: "example.com=www.example.com, old.example.com = new.example.com",
+		want: []hostRedirect{
+			{from: "example.com", to: "www.example.com"},
+			{from: "old.example.com", to: "new.example.com"},
+		},
+	}, {
+		name:    "no target",
+		value:   "example.com",
+		wantErr: true,
+	}, {
+		name:    "invalid host",
+		value:   "example.com=www.example.com/path",
+		wantErr: true,
+	}, {
+		name:    "to itself",
+		value:   "example.com=example.com",
+		wantErr: true,
+	}, {
+		name:    "redirected twice",
+		value:   "example.com=www.example.com,example.com=other.example.com",
+		wantErr: true,
+	}, {
+		name:    "served by the ingress",
+		value:   "foo.example.com=www.example.com",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+				ing.Annotations = map[string]string{config.HostRedirectsAnnotationKey: test.value}
+			})
+			got, err := hostRedirectsFromAnnotations(in)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(hostRedirect{}))
+		})
+	}
+}
+
+func TestIngressTranslatorHostRedirects(t *testing.T) {
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.HostRedirectsAnnotationKey: "example.com=foo.example.com"}
+		ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+		ing.Spec.HTTPOption = v1alpha1.HTTPOptionRedirected
+	})
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+
+	// The redirected hosts are only served externally.
+	assert.Equal(t, len(got.internalVirtualHosts), 1)
+	assert.Equal(t, len(got.externalVirtualHosts), 2)
+	vhost := got.externalVirtualHosts[1]
+	assert.Equal(t, vhost.Name, "(testspace/testname).HostRedirects[example.com]")
+	assert.DeepEqual(t, vhost.Domains, []string{"example.com", "example.com:*"})
+	assert.DeepEqual(t, got.redirectedDomains, []string{"example.com", "example.com:*"})
+
+	// Plain HTTP requests are redirected to HTTPS right away, like the ones of the
+	// ingress.
+	redirect := vhost.Routes[0].GetRedirect()
+	assert.Equal(t, redirect.HostRedirect, "foo.example.com")
+	assert.Assert(t, redirect.GetHttpsRedirect())
+}
+
+func TestHostRedirectsDomainConflict(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	redirecting := &translatedIngress{
+		name:              types.NamespacedName{Namespace: "ns", Name: "redirecting"},
+		redirectedDomains: []string{"example.com", "example.com:*"},
+	}
+	serving := &translatedIngress{
+		name: types.NamespacedName{Namespace: "ns", Name: "serving"},
+		internalVirtualHosts: []*route.VirtualHost{
+			envoy.NewVirtualHost("serving", []string{"example.com", "example.com:*"},
+				[]*route.Route{envoy.NewRoute("serving", nil, "/", nil, 0, nil, "")}),
+		},
+	}
+
+	// The redirected hosts can't be served by other ingresses, even if shared hosts are
+	// merged, and vice versa.
+	assert.NilError(t, caches.addTranslatedIngress(redirecting, true))
+	assert.Error(t, caches.addTranslatedIngress(serving, true), ErrDomainConflict.Error())
+
+	caches.deleteTranslatedIngress("redirecting", "ns")
+	assert.NilError(t, caches.addTranslatedIngress(serving, true))
+	assert.Error(t, caches.addTranslatedIngress(redirecting, true), ErrDomainConflict.Error())
+}
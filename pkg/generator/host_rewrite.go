@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+const (
+	// hostRewriteAuto rewrites the host of the requests to the DNS name of their backend.
+	hostRewriteAuto = "auto"
+
+	// hostRewriteHeaderPrefix prefixes the header whose value the host of the requests is
+	// rewritten to.
+	hostRewriteHeaderPrefix = "header:"
+)
+
+// hostRewrite is how the host of the requests to the backends of an Ingress is rewritten,
+// rather than to the literal RewriteHost of its paths.
+type hostRewrite struct {
+	// auto rewrites the host to the DNS name of the backend.
+	auto bool
+	// header is the header whose value the host is rewritten to, unless auto.
+	header string
+}
+
+// hostRewriteFromAnnotations returns how the host of the requests to the backends of the
+// Ingress is rewritten, as specified via annotations on it. Returns nil if it isn't
+// specified, which keeps the RewriteHost of the paths.
+func hostRewriteFromAnnotations(annotations map[string]string) (*hostRewrite, error) {
+	raw := strings.TrimSpace(pkgconfig.GetHostRewrite(annotations))
+	switch {
+	case raw == "":
+		return nil, nil
+	case raw == hostRewriteAuto:
+		return &hostRewrite{auto: true}, nil
+	case strings.HasPrefix(raw, hostRewriteHeaderPrefix):
+		header := strings.TrimSpace(strings.TrimPrefix(raw, hostRewriteHeaderPrefix))
+		if errs := validation.IsHTTPHeaderName(header); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s annotation: %q is not a header name", pkgconfig.HostRewriteAnnotationKey, header)
+		}
+		return &hostRewrite{header: header}, nil
+	default:
+		return nil, fmt.Errorf("invalid %s annotation: %q is neither %q nor %q followed by a header name",
+			pkgconfig.HostRewriteAnnotationKey, raw, hostRewriteAuto, hostRewriteHeaderPrefix)
+	}
+}
+
+// apply rewrites the host of the requests routed by the Route.
+func (h *hostRewrite) apply(r *route.Route) {
+	if h.auto {
+		envoy.SetAutoHostRewrite(r)
+	} else {
+		envoy.SetHostRewriteHeader(r, h.header)
+	}
+}
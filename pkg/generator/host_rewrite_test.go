@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestHostRewriteFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       *hostRewrite
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "auto",
+		annotation: "auto",
+		want:       &hostRewrite{auto: true},
+	}, {
+		name:       "header",
+		annotation: "header: x-backend-host",
+		want:       &hostRewrite{header: "x-backend-host"},
+	}, {
+		name:       "invalid header",
+		annotation: "header:x backend host",
+		wantErr:    true,
+	}, {
+		name:       "missing header",
+		annotation: "header:",
+		wantErr:    true,
+	}, {
+		name:       "literal host",
+		annotation: "backend.example.com",
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.HostRewriteAnnotationKey] = test.annotation
+			}
+
+			got, err := hostRewriteFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(hostRewrite{}))
+		})
+	}
+}
+
+func TestIngressTranslatorHostRewrite(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.HostRewriteAnnotationKey: "auto"}
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = "backend.example.com"
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+	// The host is rewritten to the DNS name of the backends rather than the literal one.
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		for _, r := range vh.Routes {
+			assert.Assert(t, r.GetRoute().GetAutoHostRewrite().GetValue())
+			assert.Equal(t, r.GetRoute().GetHostRewriteLiteral(), "")
+		}
+	}
+}
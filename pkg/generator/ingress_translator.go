@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,16 +31,18 @@ import (
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/control-protocol/pkg/certificates"
 	pkgconfig "knative.dev/net-kourier/pkg/config"
 	envoy "knative.dev/net-kourier/pkg/envoy/api"
 	"knative.dev/net-kourier/pkg/reconciler/ingress/config"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
-	netconfig "knative.dev/networking/pkg/config"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/tracker"
@@ -48,19 +51,60 @@ import (
 type translatedIngress struct {
 	name                    types.NamespacedName
 	listenerPort            string
+	dedicatedListener       *dedicatedListener
+	listenerPool            string
+	gatewayFleet            string
 	sniMatches              []*envoy.SNIMatch
+	passthroughMatches      []*envoy.PassthroughMatch
+	upstreamClientSecret    *tls.Secret
 	clusters                []*v3.Cluster
 	externalVirtualHosts    []*route.VirtualHost
 	externalTLSVirtualHosts []*route.VirtualHost
 	internalVirtualHosts    []*route.VirtualHost
+
+	// endpointsTargetPorts are the target ports of the clusters built from the endpoints
+	// of their service, keyed by the cluster's name.
+	endpointsTargetPorts map[string]int32
+	// endpointsHealthStatus specifies whether the endpoints of these clusters carry
+	// their health status.
+	endpointsHealthStatus bool
+	// endpointsDraining specifies whether the not ready endpoints of these clusters are
+	// kept as draining.
+	endpointsDraining bool
+
+	// routeTTL is the TTL of the virtual hosts of the external hosts, after which the
+	// gateways drop them unless they're pushed again. No TTL if 0.
+	routeTTL time.Duration
+
+	// redirectedDomains are the domains of the hosts redirected to other hosts, which
+	// are served by the external virtual hosts only.
+	redirectedDomains []string
+
+	// annotationConflicts are the warnings about the annotations of the ingress ignored
+	// in favor of the ones taking precedence.
+	annotationConflicts []string
+
+	// wasmExtension is the Wasm module run on the requests to the hosts of the ingress,
+	// if any.
+	wasmExtension *envoy.WasmExtension
 }
 
 type IngressTranslator struct {
-	secretGetter    func(ns, name string) (*corev1.Secret, error)
-	endpointsGetter func(ns, name string) (*corev1.Endpoints, error)
-	serviceGetter   func(ns, name string) (*corev1.Service, error)
-	namespaceGetter func(name string) (*corev1.Namespace, error)
-	tracker         tracker.Interface
+	secretGetter     func(ns, name string) (*corev1.Secret, error)
+	endpointsGetter  func(ns, name string) (*corev1.Endpoints, error)
+	serviceGetter    func(ns, name string) (*corev1.Service, error)
+	namespaceGetter  func(name string) (*corev1.Namespace, error)
+	configMapsGetter func(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error)
+	tracker          tracker.Interface
+	cache            *translationCache
+
+	// podGetter is optional. Without it, the health status of the endpoints is derived
+	// from the Endpoints alone.
+	podGetter func(ns, name string) (*corev1.Pod, error)
+
+	// now returns the current time, at which the schedules of the Ingresses are
+	// evaluated.
+	now func() time.Time
 }
 
 func NewIngressTranslator(
@@ -68,21 +112,47 @@ func NewIngressTranslator(
 	endpointsGetter func(ns, name string) (*corev1.Endpoints, error),
 	serviceGetter func(ns, name string) (*corev1.Service, error),
 	namespaceGetter func(name string) (*corev1.Namespace, error),
+	configMapsGetter func(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error),
 	tracker tracker.Interface) IngressTranslator {
 	return IngressTranslator{
-		secretGetter:    secretGetter,
-		endpointsGetter: endpointsGetter,
-		serviceGetter:   serviceGetter,
-		namespaceGetter: namespaceGetter,
-		tracker:         tracker,
+		secretGetter:     secretGetter,
+		endpointsGetter:  endpointsGetter,
+		serviceGetter:    serviceGetter,
+		namespaceGetter:  namespaceGetter,
+		configMapsGetter: configMapsGetter,
+		tracker:          tracker,
+		cache:            newTranslationCache(),
+		now:              time.Now,
 	}
 }
 
 func (translator *IngressTranslator) translateIngress(ctx context.Context, ingress *v1alpha1.Ingress, extAuthzEnabled bool) (*translatedIngress, error) {
 	logger := logging.FromContext(ctx)
 
-	sniMatches := make([]*envoy.SNIMatch, 0, len(ingress.Spec.TLS))
-	for _, ingressTLS := range ingress.Spec.TLS {
+	ingress, err := scheduledIngress(ingress, translator.now())
+	if err != nil {
+		return nil, err
+	}
+
+	ingress, annotationConflicts := resolveAnnotationConflicts(ingress)
+	for _, conflict := range annotationConflicts {
+		logger.Infof("Ingress %s/%s: %s", ingress.Namespace, ingress.Name, conflict)
+	}
+
+	passthrough := IsTLSPassthrough(ingress)
+	if passthrough {
+		if err := validateTLSPassthrough(ingress); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without TLS termination, the TLS settings are left to the proxy in front.
+	ingressTLSs := ingress.Spec.TLS
+	if config.FromContextOrDefaults(ctx).Kourier.DisableTLSTermination {
+		ingressTLSs = nil
+	}
+	sniMatches := make([]*envoy.SNIMatch, 0, len(ingressTLSs))
+	for _, ingressTLS := range ingressTLSs {
 		if err := trackSecret(translator.tracker, ingressTLS.SecretNamespace, ingressTLS.SecretName, ingress); err != nil {
 			return nil, err
 		}
@@ -91,6 +161,10 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch secret: %w", err)
 		}
+		certificateChain, privateKey, err := tlsSecretData(ctx, secret)
+		if err != nil {
+			return nil, err
+		}
 
 		secretRef := types.NamespacedName{
 			Namespace: ingressTLS.SecretNamespace,
@@ -99,20 +173,176 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 		sniMatches = append(sniMatches, &envoy.SNIMatch{
 			Hosts:            ingressTLS.Hosts,
 			CertSource:       secretRef,
-			CertificateChain: secret.Data[certFieldInSecret],
-			PrivateKey:       secret.Data[keyFieldInSecret]})
+			CertificateChain: certificateChain,
+			PrivateKey:       privateKey})
+	}
+
+	defaultCertMatch, err := translator.defaultCertificateSNIMatch(ctx, ingress)
+	if err != nil {
+		return nil, err
+	}
+	if defaultCertMatch != nil {
+		sniMatches = append(sniMatches, defaultCertMatch)
+	}
+
+	if len(sniMatches) != 0 {
+		clientValidation, err := translator.clientValidation(ctx, ingress)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range sniMatches {
+			match.ClientValidation = clientValidation
+		}
+	}
+
+	annotationHeadersMatch, queryParamsMatch, err := matchersFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	transform, err := transformFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	stickyCanaryTTL, err := stickyCanaryTTLFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRequests, err := maxRequestsFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrades, err := upgradesFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	webSocketOnly, err := webSocketOnlyFromAnnotations(ingress, upgrades)
+	if err != nil {
+		return nil, err
+	}
+
+	hostRewrite, err := hostRewriteFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	pathRewrite, err := pathRewriteFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	redirect, err := redirectFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	maintenance, err := maintenanceFromAnnotations(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	dropRoutes, err := dropRoutesFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts, err := timeoutsFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	requestBuffering, err := requestBufferingFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	hedging, err := hedgingFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	externalNameHealthCheck, err := externalNameHealthCheckFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	routeTTL, err := routeTTLFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamProtocol, err := upstreamProtocolFromAnnotations(ingress.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	externalNameTLS := isExternalNameTLS(ingress)
+
+	dynamicForwardProxy, err := dynamicForwardProxyFromAnnotations(ingress, config.FromContextOrDefaults(ctx).Kourier)
+	if err != nil {
+		return nil, err
+	}
+
+	hostRedirects, err := hostRedirectsFromAnnotations(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	transcoder, err := translator.grpcJSONTranscoder(ctx, ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	wasmExtension, err := translator.wasmExtension(ctx, ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	luaSnippet, err := luaFilterFromAnnotations(ingress, config.FromContextOrDefaults(ctx).Kourier)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceIPFilter, err := sourceIPFilterFromAnnotations(ingress, config.FromContextOrDefaults(ctx).Kourier)
+	if err != nil {
+		return nil, err
+	}
+
+	listenerPool := pkgconfig.GetListenerPool(ingress.Annotations)
+	if listenerPool != "" {
+		pool, ok := config.FromContextOrDefaults(ctx).Kourier.ListenerPools[listenerPool]
+		if !ok {
+			return nil, fmt.Errorf("%w: pool %q is not configured", ErrInvalidListenerPool, listenerPool)
+		}
+		if pool.RequireTLS && len(sniMatches) == 0 {
+			return nil, fmt.Errorf("%w: pool %q requires TLS, but the ingress has no TLS settings", ErrInvalidListenerPool, listenerPool)
+		}
 	}
 
 	internalHosts := make([]*route.VirtualHost, 0, len(ingress.Spec.Rules))
 	externalHosts := make([]*route.VirtualHost, 0, len(ingress.Spec.Rules))
 	externalTLSHosts := make([]*route.VirtualHost, 0, len(ingress.Spec.Rules))
 	clusters := make([]*v3.Cluster, 0, len(ingress.Spec.Rules))
+	endpointsTargetPorts := make(map[string]int32, len(ingress.Spec.Rules))
+	var upstreamClientSecret *tls.Secret
+	var passthroughMatches []*envoy.PassthroughMatch
 
 	for i, rule := range ingress.Spec.Rules {
 		ruleName := fmt.Sprintf("(%s/%s).Rules[%d]", ingress.Namespace, ingress.Name, i)
 
 		routes := make([]*route.Route, 0, len(rule.HTTP.Paths))
 		tlsRoutes := make([]*route.Route, 0, len(rule.HTTP.Paths))
+		var passthroughClusters []*route.WeightedCluster_ClusterWeight
+
+		var tags []string
+		if !passthrough {
+			tags = previewTags(ingress, rule)
+		}
+		previewRoutes := make(map[string][]*route.Route, len(tags))
+		previewTLSRoutes := make(map[string][]*route.Route, len(tags))
 		for _, httpPath := range rule.HTTP.Paths {
 			// Default the path to "/" if none is passed.
 			path := httpPath.Path
@@ -122,6 +352,10 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 
 			pathName := fmt.Sprintf("%s.Paths[%s]", ruleName, path)
 
+			// proxiedHost is the host the dynamic forward proxy connects to for the
+			// path, if it's proxied via it.
+			var proxiedHost string
+
 			wrs := make([]*route.WeightedCluster_ClusterWeight, 0, len(httpPath.Splits))
 			for _, split := range httpPath.Splits {
 				// The FQN of the service is sufficient here, as clusters towards the
@@ -146,32 +380,61 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 				var (
 					externalPort = int32(80)
 					targetPort   = int32(80)
-					http2        = false
+					servicePort  *corev1.ServicePort
 				)
-				for _, port := range service.Spec.Ports {
+				for i, port := range service.Spec.Ports {
 					if port.Port == split.ServicePort.IntVal || port.Name == split.ServicePort.StrVal {
 						externalPort = port.Port
 						targetPort = port.TargetPort.IntVal
-					}
-					if port.Name == "http2" || port.Name == "h2c" {
-						http2 = true
+						servicePort = &service.Spec.Ports[i]
 					}
 				}
+				http2 := isHTTP2Service(service.Spec.Ports, servicePort)
 
 				// Disable HTTP2 if the annotation is specified.
-				if strings.EqualFold(pkgconfig.GetDisableHTTP2(ingress.Annotations), "true") {
+				if isHTTP2Disabled(ingress) || passthrough {
 					http2 = false
 				}
 
+				// This has to be "OrDefaults" because this path is called before the informers are
+				// running when booting the controller up and prefilling the config before making it
+				// ready.
+				cfg := config.FromContextOrDefaults(ctx)
+
+				if dynamicForwardProxy && !passthrough && isDynamicForwardProxyPath(httpPath, service) {
+					// The gateway resolves the host on demand, rather than via a cluster
+					// of the service.
+					cluster, err := dynamicForwardProxyCluster(externalNameTLS, cfg.Kourier)
+					if err != nil {
+						return nil, err
+					}
+					if !hasCluster(clusters, cluster.Name) {
+						clusters = append(clusters, cluster)
+					}
+					proxiedHost = dynamicForwardProxyHost(httpPath, service, externalPort, externalNameTLS)
+					wrs = append(wrs, envoy.NewWeightedCluster(cluster.Name, uint32(split.Percent), split.AppendHeaders))
+					continue
+				}
+
+				// Ingress with RewriteHost points to ExternalService(kourier-internal). That hop is
+				// only encrypted if explicitly enabled, as kourier-internal has to serve TLS for it.
+				encryptRewriteHost := cfg.Network.InternalEncryption && httpPath.RewriteHost != "" &&
+					cfg.Kourier.RewriteHostInternalEncryption && service.Spec.Type == corev1.ServiceTypeExternalName
+
 				var (
 					publicLbEndpoints []*endpoint.LbEndpoint
 					typ               v3.Cluster_DiscoveryType
 				)
 				if service.Spec.Type == corev1.ServiceTypeExternalName {
+					port := uint32(externalPort)
+					if encryptRewriteHost {
+						port = pkgconfig.HTTPSPortInternalService
+					}
+
 					// If the service is of type ExternalName, we add a single endpoint.
 					typ = v3.Cluster_LOGICAL_DNS
 					publicLbEndpoints = []*endpoint.LbEndpoint{
-						envoy.NewLBEndpoint(service.Spec.ExternalName, uint32(externalPort)),
+						envoy.NewLBEndpoint(service.Spec.ExternalName, port),
 					}
 				} else {
 					// For all other types, fetch the endpoints object.
@@ -185,50 +448,224 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 					}
 
 					typ = v3.Cluster_STATIC
-					publicLbEndpoints = lbEndpointsForKubeEndpoints(endpoints, targetPort)
+					if cfg.Kourier.EndpointHealthStatus {
+						publicLbEndpoints = lbEndpointsWithHealthStatus(endpoints, targetPort, translator.podGetter)
+					} else {
+						publicLbEndpoints = lbEndpointsForKubeEndpoints(endpoints, targetPort, cfg.Kourier.EndpointDraining)
+					}
+					endpointsTargetPorts[splitName] = targetPort
 				}
 
-				connectTimeout := 5 * time.Second
-
-				var transportSocket *envoycorev3.TransportSocket
-
-				// This has to be "OrDefaults" because this path is called before the informers are
-				// running when booting the controller up and prefilling the config before making it
-				// ready.
-				cfg := config.FromContextOrDefaults(ctx)
+				connectTimeout := timeouts.connectTimeout(cfg.Kourier)
 
-				// As Ingress with RewriteHost points to ExternalService(kourier-internal), we don't enable TLS.
-				if cfg.Network.InternalEncryption && httpPath.RewriteHost == "" {
+				var (
+					transportSocket *envoycorev3.TransportSocket
+					clientSecret    *tls.Secret
+					// autoHTTP negotiates the protocol with the backends via ALPN.
+					autoHTTP bool
+				)
+				if passthrough {
+					// The backends terminate the passed through TLS connections themselves.
+				} else if externalNameTLS && service.Spec.Type == corev1.ServiceTypeExternalName && httpPath.RewriteHost == "" {
+					// The backends outside of the cluster are verified against the system's CAs.
+					var err error
+					transportSocket, err = externalNameTransportSocket(service.Spec.ExternalName, http2)
+					if err != nil {
+						return nil, err
+					}
+				} else if cfg.Network.InternalEncryption && httpPath.RewriteHost == "" {
+					autoHTTP = cfg.Kourier.UpstreamALPNNegotiation && !http2 && !isHTTP2Disabled(ingress)
 					var err error
-					transportSocket, err = translator.createUpstreamTransportSocket(http2)
+					transportSocket, clientSecret, err = translator.createUpstreamTransportSocket(ctx, ingress, upstreamALPN(http2, autoHTTP), split.ServiceNamespace, "")
 					if err != nil {
 						return nil, err
 					}
+				} else if encryptRewriteHost {
+					// kourier-internal serves the rewritten host, so use it for SNI and to
+					// verify its certificate.
+					var err error
+					transportSocket, clientSecret, err = translator.createUpstreamTransportSocket(ctx, ingress, upstreamALPN(http2, false), split.ServiceNamespace, httpPath.RewriteHost)
+					if err != nil {
+						return nil, err
+					}
+				}
+				if clientSecret != nil {
+					upstreamClientSecret = clientSecret
 				}
 				cluster := envoy.NewCluster(splitName, connectTimeout, publicLbEndpoints, http2, transportSocket, typ)
+				protocolConfig := upstreamProtocol.apply(cfg.Kourier)
+				if autoHTTP {
+					envoy.SetAutoHTTPProtocolOptions(cluster,
+						envoy.NewUpstreamHTTP1ProtocolOptions(protocolConfig), envoy.NewUpstreamHTTP2ProtocolOptions(protocolConfig))
+				} else {
+					envoy.SetHTTP2ProtocolOptions(cluster, envoy.NewUpstreamHTTP2ProtocolOptions(protocolConfig))
+					envoy.SetHTTP1ProtocolOptions(cluster, envoy.NewUpstreamHTTP1ProtocolOptions(protocolConfig))
+				}
+				envoy.SetCommonHTTPProtocolOptions(cluster, envoy.NewUpstreamCommonHTTPProtocolOptions(cfg.Kourier, timeouts.upstreamConnectionIdleTimeout))
+				envoy.SetTCPKeepalive(cluster, envoy.NewUpstreamTCPKeepalive(cfg.Kourier, timeouts.upstreamTCPKeepaliveTime))
+				envoy.SetPreconnectPolicy(cluster, cfg.Kourier)
+				envoy.SetCircuitBreakers(cluster, cfg.Kourier)
+				if cfg.Kourier.DescriptionMetadata {
+					envoy.SetClusterDescription(cluster, clusterDescription(service))
+				}
+				if maxRequests != 0 {
+					envoy.CapMaxRequests(cluster, maxRequests)
+				}
+				if (cfg.Kourier.EndpointHealthStatus || cfg.Kourier.EndpointDraining) && typ == v3.Cluster_STATIC {
+					envoy.DisablePanicMode(cluster)
+				}
+				if typ == v3.Cluster_LOGICAL_DNS {
+					envoy.SetDNSResolution(cluster, cfg.Kourier)
+				}
+				if externalNameHealthCheck != nil && typ == v3.Cluster_LOGICAL_DNS {
+					// The backends may be down while their names still resolve.
+					host := service.Spec.ExternalName
+					if httpPath.RewriteHost != "" {
+						host = httpPath.RewriteHost
+					}
+					envoy.SetActiveHealthCheck(cluster, externalNameHealthCheck.path, host, http2, externalNameHealthCheck.interval)
+				}
+
+				// Route to the aggregate cluster failing over to the activator, if any.
+				clusterName := splitName
+				var failoverClusters []*v3.Cluster
+				if cfg.Kourier.ActivatorFailover && !passthrough && service.Spec.Type != corev1.ServiceTypeExternalName && isRevisionSplit(split) {
+					var err error
+					failoverClusters, err = translator.activatorFailoverClusters(ingress, cluster, targetPort)
+					if err != nil {
+						return nil, err
+					}
+					if len(failoverClusters) != 0 {
+						clusterName = failoverClusters[len(failoverClusters)-1].Name
+					}
+				}
 				clusters = append(clusters, cluster)
+				clusters = append(clusters, failoverClusters...)
 
-				weightedCluster := envoy.NewWeightedCluster(splitName, uint32(split.Percent), split.AppendHeaders)
+				weightedCluster := envoy.NewWeightedCluster(clusterName, uint32(split.Percent), split.AppendHeaders)
 				wrs = append(wrs, weightedCluster)
 			}
 
+			passthroughClusters = wrs
 			if len(wrs) != 0 {
-				// disable ext_authz filter for HTTP01 challenge when the feature is enabled
-				if extAuthzEnabled && strings.HasPrefix(path, "/.well-known/acme-challenge/") {
-					routes = append(routes, envoy.NewRouteExtAuthzDisabled(
-						pathName, matchHeadersFromHTTPPath(httpPath), path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost))
-				} else if _, ok := os.LookupEnv("KOURIER_HTTPOPTION_DISABLED"); !ok && ingress.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected && rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
-					// Do not create redirect route when KOURIER_HTTPOPTION_DISABLED is set. This option is useful when front end proxy handles the redirection.
-					// e.g. Kourier on OpenShift handles HTTPOption by OpenShift Route so KOURIER_HTTPOPTION_DISABLED should be set.
-					routes = append(routes, envoy.NewRedirectRoute(
-						pathName, matchHeadersFromHTTPPath(httpPath), path))
-				} else {
-					routes = append(routes, envoy.NewRoute(
-						pathName, matchHeadersFromHTTPPath(httpPath), path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost))
+				headersMatch := append(matchHeadersFromHTTPPath(httpPath), annotationHeadersMatch...)
+
+				// newRoutes creates the route of the path, and the one of the HTTPS
+				// listener if any, splitting the requests matching the given headers across
+				// the given clusters.
+				newRoutes := func(pathName string, headersMatch []*route.HeaderMatcher, wrs []*route.WeightedCluster_ClusterWeight, stickyCanaryTTL time.Duration) (*route.Route, *route.Route) {
+					var r *route.Route
+					// diverged is whether the route of the HTTPS listener differs from the
+					// one of the HTTP listener.
+					diverged := true
+					// disable ext_authz filter for HTTP01 challenge when the feature is enabled
+					httpsRedirect := !httpRedirectsDisabled(ctx) && ingress.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected && rule.Visibility == v1alpha1.IngressVisibilityExternalIP
+					if extAuthzEnabled && strings.HasPrefix(path, "/.well-known/acme-challenge/") {
+						r = envoy.NewRouteExtAuthzDisabled(
+							pathName, headersMatch, path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost)
+					} else if maintenance != nil && maintenance.covers(path) {
+						r = maintenance.route(pathName, headersMatch, path)
+						diverged = false
+					} else if redirect != nil {
+						// The plain HTTP requests are still redirected to HTTPS, unless
+						// the redirect sets the scheme itself.
+						httpRedirect := *redirect
+						if httpsRedirect && httpRedirect.Scheme == "" {
+							httpRedirect.Scheme = "https"
+						}
+						r = envoy.NewCustomRedirectRoute(pathName, headersMatch, path, &httpRedirect)
+						diverged = httpRedirect != *redirect
+					} else if httpsRedirect {
+						// Do not create redirect route when KOURIER_HTTPOPTION_DISABLED is set. This option is useful when front end proxy handles the redirection.
+						// e.g. Kourier on OpenShift handles HTTPOption by OpenShift Route so KOURIER_HTTPOPTION_DISABLED should be set.
+						r = envoy.NewRedirectRoute(
+							pathName, headersMatch, path)
+					} else {
+						r = envoy.NewRoute(
+							pathName, headersMatch, path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost)
+						diverged = false
+					}
+					r.Match.QueryParameters = queryParamsMatch
+					if config.FromContextOrDefaults(ctx).Kourier.IngressStatsTags {
+						r.StatPrefix = routeStatPrefix(ingress, wrs)
+					}
+					if transform != nil {
+						envoy.SetTransform(r, transform)
+					}
+					if stickyCanaryTTL > 0 {
+						envoy.SetStickyCanary(r, stickyCanaryTTL)
+					}
+					if proxiedHost != "" {
+						envoy.SetDynamicForwardProxyHost(r, proxiedHost)
+					}
+					if hostRewrite != nil {
+						hostRewrite.apply(r)
+					}
+					if pathRewrite != nil {
+						pathRewrite.apply(r)
+					}
+
+					if len(sniMatches) == 0 && !useHTTPSListenerWithOneCert(ctx) {
+						return r, nil
+					}
+					if !diverged {
+						// The HTTPS listener serves the same route, so it's shared rather
+						// than duplicated.
+						return r, r
+					}
+					var tlsRoute *route.Route
+					if redirect != nil {
+						tlsRoute = envoy.NewCustomRedirectRoute(pathName, headersMatch, path, redirect)
+					} else {
+						tlsRoute = envoy.NewRoute(
+							pathName, headersMatch, path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost)
+					}
+					tlsRoute.Match.QueryParameters = queryParamsMatch
+					tlsRoute.StatPrefix = r.StatPrefix
+					if transform != nil {
+						envoy.SetTransform(tlsRoute, transform)
+					}
+					if stickyCanaryTTL > 0 {
+						envoy.SetStickyCanary(tlsRoute, stickyCanaryTTL)
+					}
+					if proxiedHost != "" {
+						envoy.SetDynamicForwardProxyHost(tlsRoute, proxiedHost)
+					}
+					if hostRewrite != nil {
+						hostRewrite.apply(tlsRoute)
+					}
+					if pathRewrite != nil {
+						pathRewrite.apply(tlsRoute)
+					}
+					return r, tlsRoute
+				}
+
+				// The requests pinned to a split via the override header are matched ahead
+				// of the ones split by weight. They aren't made sticky, as they're pinned
+				// already.
+				if header := config.FromContextOrDefaults(ctx).Kourier.CanaryOverrideHeader; header != "" {
+					for _, tag := range canaryOverrideTags(httpPath) {
+						r, tlsRoute := newRoutes(canaryOverrideRouteName(pathName, tag),
+							canaryOverrideHeadersMatch(headersMatch, header, tag), previewWeightedClusters(wrs, httpPath.Splits, tag), 0)
+						routes = append(routes, r)
+						if tlsRoute != nil {
+							tlsRoutes = append(tlsRoutes, tlsRoute)
+						}
+					}
+				}
+
+				r, tlsRoute := newRoutes(pathName, headersMatch, wrs, stickyCanaryTTL)
+				routes = append(routes, r)
+				if tlsRoute != nil {
+					tlsRoutes = append(tlsRoutes, tlsRoute)
 				}
-				if len(ingress.Spec.TLS) != 0 || useHTTPSListenerWithOneCert() {
-					tlsRoutes = append(tlsRoutes, envoy.NewRoute(
-						pathName, matchHeadersFromHTTPPath(httpPath), path, wrs, 0, httpPath.AppendHeaders, httpPath.RewriteHost))
+
+				for _, tag := range tags {
+					r, tlsRoute := newRoutes(pathName, headersMatch, previewWeightedClusters(wrs, httpPath.Splits, tag), stickyCanaryTTL)
+					previewRoutes[tag] = append(previewRoutes[tag], r)
+					if tlsRoute != nil {
+						previewTLSRoutes[tag] = append(previewTLSRoutes[tag], tlsRoute)
+					}
 				}
 			}
 		}
@@ -238,32 +675,177 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 			return nil, nil
 		}
 
-		var virtualHost, virtualTLSHost *route.VirtualHost
-		if extAuthzEnabled {
-			contextExtensions := kmeta.UnionMaps(map[string]string{
-				"client":     "kourier",
-				"visibility": string(rule.Visibility),
-			}, ingress.GetLabels())
-			virtualHost = envoy.NewVirtualHostWithExtAuthz(ruleName, contextExtensions, domainsForRule(rule), routes)
-			if len(tlsRoutes) != 0 {
-				virtualTLSHost = envoy.NewVirtualHostWithExtAuthz(ruleName, contextExtensions, domainsForRule(rule), tlsRoutes)
-			}
-		} else {
-			virtualHost = envoy.NewVirtualHost(ruleName, domainsForRule(rule), routes)
-			if len(tlsRoutes) != 0 {
-				virtualTLSHost = envoy.NewVirtualHost(ruleName, domainsForRule(rule), tlsRoutes)
+		if passthrough {
+			// Only the external hosts are served, by passing their connections through.
+			if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
+				passthroughMatches = append(passthroughMatches, newPassthroughMatch(ruleName, rule.Hosts, passthroughClusters))
 			}
+			continue
+		}
+
+		// The preview hosts of the splits are served just like the hosts of the rule.
+		stripHostPort := config.FromContextOrDefaults(ctx).Kourier.StripHostPort
+		ruleHosts := []ruleVirtualHost{{
+			name:      ruleName,
+			domains:   domainsForRule(rule, stripHostPort),
+			routes:    routes,
+			tlsRoutes: tlsRoutes,
+		}}
+		for _, tag := range tags {
+			ruleHosts = append(ruleHosts, ruleVirtualHost{
+				name:      fmt.Sprintf("%s.Preview[%s]", ruleName, tag),
+				domains:   domainsForRule(previewRule(rule, tag), stripHostPort),
+				routes:    previewRoutes[tag],
+				tlsRoutes: previewTLSRoutes[tag],
+			})
 		}
 
-		internalHosts = append(internalHosts, virtualHost)
-		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
-			externalHosts = append(externalHosts, virtualHost)
+		for _, ruleHost := range ruleHosts {
+			if config.FromContextOrDefaults(ctx).Kourier.DescriptionMetadata {
+				setRouteDescriptions(ingress, i, ruleHost.routes, ruleHost.tlsRoutes)
+			}
+
+			// The HTTPS listener shares the virtual host of the HTTP listener if it serves the
+			// same routes. Otherwise, the virtual hosts are adjusted one after the other, so
+			// they can't share any route.
+			sharedTLSHost := sharesRoutes(ruleHost.routes, ruleHost.tlsRoutes)
+			tlsRoutes := ruleHost.tlsRoutes
+			if !sharedTLSHost {
+				tlsRoutes = unshareRoutes(ruleHost.routes, ruleHost.tlsRoutes)
+			}
+
+			var virtualHost, virtualTLSHost *route.VirtualHost
+			if extAuthzEnabled {
+				contextExtensions := kmeta.UnionMaps(map[string]string{
+					"client":     "kourier",
+					"visibility": string(rule.Visibility),
+				}, ingress.GetLabels())
+				virtualHost = envoy.NewVirtualHostWithExtAuthz(ruleHost.name, contextExtensions, ruleHost.domains, ruleHost.routes)
+				if len(tlsRoutes) != 0 && !sharedTLSHost {
+					virtualTLSHost = envoy.NewVirtualHostWithExtAuthz(ruleHost.name, contextExtensions, ruleHost.domains, tlsRoutes)
+				}
+			} else {
+				virtualHost = envoy.NewVirtualHost(ruleHost.name, ruleHost.domains, ruleHost.routes)
+				if len(tlsRoutes) != 0 && !sharedTLSHost {
+					virtualTLSHost = envoy.NewVirtualHost(ruleHost.name, ruleHost.domains, tlsRoutes)
+				}
+			}
+
+			// hosts are the distinct virtual hosts to adjust.
+			hosts := []*route.VirtualHost{virtualHost}
 			if virtualTLSHost != nil {
-				externalTLSHosts = append(externalTLSHosts, virtualTLSHost)
+				hosts = append(hosts, virtualTLSHost)
+			}
+			if sharedTLSHost {
+				virtualTLSHost = virtualHost
+			}
+
+			if budget := config.FromContextOrDefaults(ctx).Kourier.UpstreamRequestBudget; budget > 0 {
+				for _, vh := range hosts {
+					envoy.SetRequestBudget(vh, budget)
+				}
 			}
+
+			for _, vh := range hosts {
+				if upgrades != nil {
+					upgrades.apply(vh)
+				}
+				if timeouts.idleTimeout > 0 {
+					envoy.SetIdleTimeout(vh, timeouts.idleTimeout)
+				}
+				if timeouts.maxStreamDuration > 0 {
+					envoy.SetMaxStreamDuration(vh, timeouts.maxStreamDuration)
+				}
+				// The bodies are only buffered if the buffering is enabled on the gateway.
+				if requestBuffering != nil && config.FromContextOrDefaults(ctx).Kourier.MaxRequestBodyBytes != 0 {
+					envoy.SetMaxRequestBytes(vh, requestBuffering.maxRequestBytes)
+				}
+				if maxDelay := config.FromContextOrDefaults(ctx).Kourier.RetryAfterMaxDelay; maxDelay > 0 {
+					envoy.SetRetryAfterBackoff(vh, maxDelay)
+				}
+				if hedging != nil {
+					envoy.SetHedging(vh, hedging.perTryTimeout, hedging.numRetries)
+				}
+				if webSocketOnly != nil {
+					webSocketOnly.apply(vh)
+				}
+				if len(dropRoutes) != 0 {
+					envoy.AddDropRoutes(vh, dropRoutes)
+				}
+			}
+
+			if transcoder != nil {
+				for _, vh := range hosts {
+					envoy.SetGRPCJSONTranscoder(vh, transcoder.descriptorSet, transcoder.services)
+				}
+			}
+
+			if luaSnippet != "" {
+				for _, vh := range hosts {
+					envoy.SetLuaSnippet(vh, luaSnippet)
+				}
+			}
+
+			if sourceIPFilter != nil {
+				for _, vh := range hosts {
+					if err := envoy.SetSourceIPFilter(vh, sourceIPFilter); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			internalHost := virtualHost
+			if kourierConfig := config.FromContextOrDefaults(ctx).Kourier; kourierConfig.InternalTrafficPriority || kourierConfig.InternalUpgradeProtection {
+				// The external hosts share the routes, so adjust a copy of them.
+				internalHost = proto.Clone(virtualHost).(*route.VirtualHost)
+				if kourierConfig.InternalTrafficPriority {
+					envoy.SetRoutingPriority(internalHost, envoycorev3.RoutingPriority_HIGH)
+				}
+				if kourierConfig.InternalUpgradeProtection {
+					envoy.RestrictUpgrades(internalHost, kourierConfig.InternalUpgrades())
+				}
+			}
+
+			internalHosts = append(internalHosts, internalHost)
+			if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
+				externalHosts = append(externalHosts, virtualHost)
+				if virtualTLSHost != nil {
+					externalTLSHosts = append(externalTLSHosts, virtualTLSHost)
+				}
+			}
+		}
+	}
+
+	// The redirected hosts are served externally, over HTTPS as well if the ingress is.
+	// Plain HTTP requests are redirected to HTTPS right away if the ingress redirects them.
+	httpsRedirect := !httpRedirectsDisabled(ctx) && ingress.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected
+	var redirectedDomains []string
+	for _, redirect := range hostRedirects {
+		stripHostPort := config.FromContextOrDefaults(ctx).Kourier.StripHostPort
+		virtualHost := redirect.virtualHost(ingress, stripHostPort, httpsRedirect)
+		redirectedDomains = append(redirectedDomains, virtualHost.Domains...)
+		externalHosts = append(externalHosts, virtualHost)
+		var virtualTLSHost *route.VirtualHost
+		if len(externalTLSHosts) != 0 {
+			virtualTLSHost = redirect.virtualHost(ingress, stripHostPort, false)
+			externalTLSHosts = append(externalTLSHosts, virtualTLSHost)
+		}
+		if config.FromContextOrDefaults(ctx).Kourier.DescriptionMetadata {
+			// The redirected hosts don't belong to a rule.
+			setRouteDescriptions(ingress, -1, virtualHost.Routes, virtualTLSHost.GetRoutes())
 		}
 	}
+
+	if config.FromContextOrDefaults(ctx).Kourier.PolicyRouteStats {
+		setPolicyStatPrefixes(ingress, externalHosts, externalTLSHosts, internalHosts)
+	}
+
+	if wasmExtension != nil {
+		wasmExtension.Domains = virtualHostDomains(externalHosts, externalTLSHosts, internalHosts)
+	}
+
 	listenerPort := ""
+	var listener *dedicatedListener
 
 	if config.FromContextOrDefaults(ctx).Kourier.TrafficIsolation == pkgconfig.IsolationIngressPort {
 		ns, err := translator.namespaceGetter(ingress.Namespace)
@@ -273,7 +855,17 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 
 		if ns.Annotations != nil {
 			if value, ok := ns.Annotations[pkgconfig.ListenerPortAnnotationKey]; ok {
-				listenerPort = value
+				port, err := pkgconfig.ParseListenerPort(value)
+				if err != nil {
+					return nil, fmt.Errorf("%w in annotation %q of namespace %q: %v",
+						ErrInvalidListenerPort, pkgconfig.ListenerPortAnnotationKey, ns.Name, err)
+				}
+				listenerPort = strconv.FormatUint(uint64(port), 10)
+
+				listener, err = translator.dedicatedListener(ctx, ns, ingress)
+				if err != nil {
+					return nil, err
+				}
 
 				logger.Infof("mapping ingress %s/%s to port %v", ingress.Namespace, ingress.Name, listenerPort)
 			}
@@ -288,36 +880,196 @@ func (translator *IngressTranslator) translateIngress(ctx context.Context, ingre
 			Name:      ingress.Name,
 		},
 		listenerPort:            listenerPort,
+		dedicatedListener:       listener,
+		listenerPool:            listenerPool,
+		gatewayFleet:            pkgconfig.GetGatewayFleet(kmeta.UnionMaps(ingress.Labels, ingress.Annotations)),
 		sniMatches:              sniMatches,
+		passthroughMatches:      passthroughMatches,
+		upstreamClientSecret:    upstreamClientSecret,
 		clusters:                clusters,
+		endpointsTargetPorts:    endpointsTargetPorts,
+		endpointsHealthStatus:   config.FromContextOrDefaults(ctx).Kourier.EndpointHealthStatus,
+		endpointsDraining:       config.FromContextOrDefaults(ctx).Kourier.EndpointDraining,
 		externalVirtualHosts:    externalHosts,
 		externalTLSVirtualHosts: externalTLSHosts,
 		internalVirtualHosts:    internalHosts,
+		routeTTL:                routeTTL,
+		redirectedDomains:       redirectedDomains,
+		annotationConflicts:     annotationConflicts,
+		wasmExtension:           wasmExtension,
 	}, nil
 }
 
-func (translator *IngressTranslator) createUpstreamTransportSocket(http2 bool) (*envoycorev3.TransportSocket, error) {
-	caSecret, err := translator.secretGetter(pkgconfig.ServingNamespace(), netconfig.ServingInternalCertName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch activator CA secret: %w", err)
+// httpRedirectsDisabled returns whether the plain HTTP requests are served rather than
+// redirected to HTTPS, e.g. because a proxy in front of the gateways redirects them.
+func httpRedirectsDisabled(ctx context.Context) bool {
+	_, httpOptionDisabled := os.LookupEnv("KOURIER_HTTPOPTION_DISABLED")
+	return httpOptionDisabled || config.FromContextOrDefaults(ctx).Kourier.DisableHTTPRedirects
+}
+
+// defaultCertificateSNIMatch returns an SNIMatch serving the default certificate for
+// all external hosts of the ingress that are not covered by its TLS settings. The
+// default certificate of the ingress' namespace takes precedence over the global one.
+// It returns nil if all hosts are covered or no default certificate is configured.
+func (translator *IngressTranslator) defaultCertificateSNIMatch(ctx context.Context, ingress *v1alpha1.Ingress) (*envoy.SNIMatch, error) {
+	if useHTTPSListenerWithOneCert(ctx) || config.FromContextOrDefaults(ctx).Kourier.DisableTLSTermination {
+		return nil, nil
+	}
+
+	uncoveredHosts := uncoveredExternalHosts(ingress)
+	if len(uncoveredHosts) == 0 {
+		return nil, nil
+	}
+
+	secretRef := config.FromContextOrDefaults(ctx).Kourier.DefaultCertificateSecret
+	ns, err := translator.namespaceGetter(ingress.Namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to fetch namespace '%s': %w", ingress.Namespace, err)
+	}
+	if err == nil {
+		if name := ns.Annotations[pkgconfig.DefaultCertificateAnnotationKey]; name != "" {
+			secretRef = types.NamespacedName{Namespace: ns.Name, Name: name}
+		}
 	}
-	var alpnProtocols string
-	if http2 {
-		alpnProtocols = "h2"
+	if secretRef.Name == "" {
+		return nil, nil
 	}
-	tlsAny, err := anypb.New(createUpstreamTLSContext(caSecret.Data[certificates.SecretCaCertKey], alpnProtocols))
+
+	if err := trackSecret(translator.tracker, secretRef.Namespace, secretRef.Name, ingress); err != nil {
+		return nil, err
+	}
+
+	secret, err := translator.secretGetter(secretRef.Namespace, secretRef.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch default certificate secret: %w", err)
+	}
+	certificateChain, privateKey, err := tlsSecretData(ctx, secret)
 	if err != nil {
 		return nil, err
 	}
+
+	return &envoy.SNIMatch{
+		Hosts:            uncoveredHosts,
+		CertSource:       secretRef,
+		CertificateChain: certificateChain,
+		PrivateKey:       privateKey,
+	}, nil
+}
+
+// uncoveredExternalHosts returns the external hosts of the ingress which are not
+// listed in any of its TLS settings.
+func uncoveredExternalHosts(ingress *v1alpha1.Ingress) []string {
+	covered := sets.NewString()
+	for _, ingressTLS := range ingress.Spec.TLS {
+		covered.Insert(ingressTLS.Hosts...)
+	}
+
+	uncovered := sets.NewString()
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Visibility != v1alpha1.IngressVisibilityExternalIP {
+			continue
+		}
+		for _, host := range rule.Hosts {
+			if !covered.Has(host) {
+				uncovered.Insert(host)
+			}
+		}
+	}
+	return uncovered.List()
+}
+
+// createUpstreamTransportSocket creates a transport socket encrypting the traffic with
+// the internal CA and trust bundles. If serverName is empty, the upstream is expected to serve the
+// certificate of the data-plane of the given namespace, see upstreamSANs, otherwise serverName
+// is used for SNI and to verify the upstream's certificate.
+//
+// If an upstream client certificate secret is configured, its certificate is presented
+// to the upstream. The returned SDS secret carrying it has to be part of the snapshot.
+//
+// If a SPIFFE Workload API socket is configured, the certificates and trust bundle are
+// obtained from it instead and the upstream's certificate is verified against the
+// configured SAN patterns.
+func (translator *IngressTranslator) createUpstreamTransportSocket(ctx context.Context, ingress *v1alpha1.Ingress, alpnProtocols []string, namespace, serverName string) (*envoycorev3.TransportSocket, *tls.Secret, error) {
+	var (
+		tlsContext   *tls.UpstreamTlsContext
+		clientSecret *tls.Secret
+	)
+	if cfg := config.FromContextOrDefaults(ctx).Kourier; cfg.SPIFFEWorkloadAPISocket != "" {
+		tlsContext = envoy.NewSPIFFEUpstreamTLSContext(cfg.SPIFFEWorkloadAPISocket, cfg.SPIFFEUpstreamSANPatterns, alpnProtocols...)
+		tlsContext.Sni = serverName
+	} else {
+		caCertificates, err := translator.upstreamCACertificates(ingress)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientSecret, err = translator.upstreamClientCertificate(ingress, cfg.UpstreamClientCertificateSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		sans := upstreamSANs(cfg.UpstreamSANValidation, namespace)
+		if serverName != "" {
+			sans = []string{serverName}
+		}
+		tlsContext = createUpstreamTLSContext(caCertificates, sans, alpnProtocols...)
+		tlsContext.Sni = serverName
+		if clientSecret != nil {
+			envoy.SetClientCertificate(tlsContext, clientSecret.Name)
+		}
+	}
+
+	tlsAny, err := anypb.New(tlsContext)
+	if err != nil {
+		return nil, nil, err
+	}
 	return &envoycorev3.TransportSocket{
 		Name: wellknown.TransportSocketTls,
 		ConfigType: &envoycorev3.TransportSocket_TypedConfig{
 			TypedConfig: tlsAny,
 		},
-	}, nil
+	}, clientSecret, nil
+}
+
+// upstreamALPN returns the ALPN protocols offered to the backends reached via TLS. Both
+// HTTP/2 and HTTP/1.1 are offered with autoHTTP, letting the backends pick.
+func upstreamALPN(http2, autoHTTP bool) []string {
+	switch {
+	case http2:
+		return []string{"h2"}
+	case autoHTTP:
+		return []string{"h2", "http/1.1"}
+	default:
+		return []string{""}
+	}
+}
+
+const (
+	// dataPlaneRoutingSAN is the SAN of the certificates of the routing data-plane.
+	dataPlaneRoutingSAN = "kn-routing"
+	// dataPlaneUserSANPrefix is the prefix of the SAN of the certificates of the user
+	// data-plane, followed by the namespace.
+	dataPlaneUserSANPrefix = "kn-user-"
+)
+
+// upstreamSANs returns the SANs the certificate of a data-plane upstream in the given
+// namespace is verified against. By default, this is the single SAN shared by all of the
+// data-plane. In identity mode, these are the identities of the routing data-plane, i.e.
+// the activator, and of the user data-plane of the namespace instead, so that the
+// certificate of one namespace can't be used to impersonate another.
+func upstreamSANs(validation pkgconfig.UpstreamSANValidationType, namespace string) []string {
+	if validation != pkgconfig.UpstreamSANValidationIdentity {
+		return []string{certificates.FakeDnsName}
+	}
+	return []string{dataPlaneRoutingSAN, dataPlaneUserSANPrefix + namespace}
 }
 
-func createUpstreamTLSContext(caCertificate []byte, alpnProtocols ...string) *tls.UpstreamTlsContext {
+func createUpstreamTLSContext(caCertificate []byte, sans []string, alpnProtocols ...string) *tls.UpstreamTlsContext {
+	sanMatchers := make([]*envoymatcherv3.StringMatcher, 0, len(sans))
+	for _, san := range sans {
+		sanMatchers = append(sanMatchers, &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: san},
+		})
+	}
+
 	return &tls.UpstreamTlsContext{
 		CommonTlsContext: &tls.CommonTlsContext{
 			AlpnProtocols: alpnProtocols,
@@ -331,11 +1083,7 @@ func createUpstreamTLSContext(caCertificate []byte, alpnProtocols ...string) *tl
 							InlineBytes: caCertificate,
 						},
 					},
-					MatchSubjectAltNames: []*envoymatcherv3.StringMatcher{{
-						MatchPattern: &envoymatcherv3.StringMatcher_Exact{
-							Exact: certificates.FakeDnsName,
-						}},
-					},
+					MatchSubjectAltNames: sanMatchers,
 				},
 			},
 		},
@@ -372,21 +1120,31 @@ func trackService(t tracker.Interface, svcNs, svcName string, ingress *v1alpha1.
 	return nil
 }
 
-func lbEndpointsForKubeEndpoints(kubeEndpoints *corev1.Endpoints, targetPort int32) []*endpoint.LbEndpoint {
-	var readyAddressCount int
+// lbEndpointsForKubeEndpoints returns the ready addresses of the Endpoints and, if
+// draining, their not ready ones marked as draining, so that they get no new requests.
+func lbEndpointsForKubeEndpoints(kubeEndpoints *corev1.Endpoints, targetPort int32, draining bool) []*endpoint.LbEndpoint {
+	var addressCount int
 	for _, subset := range kubeEndpoints.Subsets {
-		readyAddressCount += len(subset.Addresses)
+		addressCount += len(subset.Addresses)
+		if draining {
+			addressCount += len(subset.NotReadyAddresses)
+		}
 	}
 
-	if readyAddressCount == 0 {
+	if addressCount == 0 {
 		return nil
 	}
 
-	eps := make([]*endpoint.LbEndpoint, 0, readyAddressCount)
+	eps := make([]*endpoint.LbEndpoint, 0, addressCount)
 	for _, subset := range kubeEndpoints.Subsets {
 		for _, address := range subset.Addresses {
 			eps = append(eps, envoy.NewLBEndpoint(address.IP, uint32(targetPort)))
 		}
+		if draining {
+			for _, address := range subset.NotReadyAddresses {
+				eps = append(eps, envoy.NewLBEndpointWithHealthStatus(address.IP, uint32(targetPort), envoycorev3.HealthStatus_DRAINING))
+			}
+		}
 	}
 
 	return eps
@@ -412,14 +1170,20 @@ func matchHeadersFromHTTPPath(httpPath v1alpha1.HTTPIngressPath) []*route.Header
 // domainsForRule returns all domains for the given rule.
 //
 // For example, external domains returns domains with the following formats:
-// 	- sub-route_host.namespace.example.com
-// 	- sub-route_host.namespace.example.com:*
+//   - sub-route_host.namespace.example.com
+//   - sub-route_host.namespace.example.com:*
 //
 // Somehow envoy doesn't match properly gRPC authorities with ports.
 // The fix is to include ":*" in the domains.
 // This applies both for internal and external domains.
 // More info https://github.com/envoyproxy/envoy/issues/886
-func domainsForRule(rule v1alpha1.IngressRule) []string {
+//
+// The ":*" domains are omitted if the gateways strip the port from the host anyway.
+func domainsForRule(rule v1alpha1.IngressRule, stripHostPort bool) []string {
+	if stripHostPort {
+		return append([]string(nil), rule.Hosts...)
+	}
+
 	domains := make([]string, 0, 2*len(rule.Hosts))
 	for _, host := range rule.Hosts {
 		domains = append(domains, host, host+":*")
@@ -18,6 +18,7 @@ package generator
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	httpOptions "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/google/go-cmp/cmp"
@@ -34,9 +36,11 @@ import (
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"knative.dev/control-protocol/pkg/certificates"
 	pkgconfig "knative.dev/net-kourier/pkg/config"
@@ -45,6 +49,7 @@ import (
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	netconfig "knative.dev/networking/pkg/config"
 	pkgtest "knative.dev/pkg/reconciler/testing"
+	"knative.dev/pkg/tracker"
 )
 
 func TestIngressTranslator(t *testing.T) {
@@ -104,6 +109,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -172,6 +178,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: vHosts,
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -257,6 +264,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHostsRedirect,
 				externalTLSVirtualHosts: vHosts,
 				internalVirtualHosts:    vHostsRedirect,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -328,6 +336,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    []*route.VirtualHost{},
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -423,6 +432,10 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts: map[string]int32{
+					"servicens/servicename":   8080,
+					"servicens2/servicename2": 8080,
+				},
 			}
 		}(),
 	}, {
@@ -478,6 +491,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -533,6 +547,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{},
 			}
 		}(),
 	}, {
@@ -589,6 +604,7 @@ func TestIngressTranslator(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{},
 			}
 		}(),
 	}, {
@@ -620,6 +636,7 @@ func TestIngressTranslator(t *testing.T) {
 				func(name string) (*corev1.Namespace, error) {
 					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 				},
+				configMapsGetter(ctx, kubeclient),
 				&pkgtest.FakeTracker{},
 			)
 
@@ -730,6 +747,7 @@ func TestIngressTranslatorWithHTTPOptionDisabled(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: vHosts,
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -801,6 +819,7 @@ func TestIngressTranslatorWithHTTPOptionDisabled(t *testing.T) {
 				externalVirtualHosts:    []*route.VirtualHost{},
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}}
@@ -825,6 +844,7 @@ func TestIngressTranslatorWithHTTPOptionDisabled(t *testing.T) {
 				func(name string) (*corev1.Namespace, error) {
 					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 				},
+				configMapsGetter(ctx, kubeclient),
 				&pkgtest.FakeTracker{},
 			)
 
@@ -901,6 +921,7 @@ func TestIngressTranslatorWithUpstreamTLS(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -965,6 +986,7 @@ func TestIngressTranslatorWithUpstreamTLS(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 8080},
 			}
 		}(),
 	}, {
@@ -1035,6 +1057,7 @@ func TestIngressTranslatorWithUpstreamTLS(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 443},
 			}
 		}(),
 	}, {
@@ -1105,6 +1128,7 @@ func TestIngressTranslatorWithUpstreamTLS(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"servicens/servicename": 443},
 			}
 		}(),
 	}}
@@ -1129,6 +1153,7 @@ func TestIngressTranslatorWithUpstreamTLS(t *testing.T) {
 				func(name string) (*corev1.Namespace, error) {
 					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 				},
+				configMapsGetter(ctx, kubeclient),
 				&pkgtest.FakeTracker{},
 			)
 
@@ -1206,6 +1231,7 @@ func TestIngressTranslatorHTTP01Challenge(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{"simplens/cm-acme-http-solver": 8089},
 			}
 		}(),
 	}
@@ -1230,6 +1256,7 @@ func TestIngressTranslatorHTTP01Challenge(t *testing.T) {
 			func(name string) (*corev1.Namespace, error) {
 				return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 			},
+			configMapsGetter(ctx, kubeclient),
 			&pkgtest.FakeTracker{},
 		)
 
@@ -1315,6 +1342,7 @@ func TestIngressTranslatorDomainMappingDisableHTTP2(t *testing.T) {
 				externalVirtualHosts:    vHosts,
 				externalTLSVirtualHosts: []*route.VirtualHost{},
 				internalVirtualHosts:    vHosts,
+				endpointsTargetPorts:    map[string]int32{},
 			}
 		}(),
 	}
@@ -1338,6 +1366,7 @@ func TestIngressTranslatorDomainMappingDisableHTTP2(t *testing.T) {
 			func(name string) (*corev1.Namespace, error) {
 				return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 			},
+			configMapsGetter(ctx, kubeclient),
 			&pkgtest.FakeTracker{},
 		)
 
@@ -1350,6 +1379,1146 @@ func TestIngressTranslatorDomainMappingDisableHTTP2(t *testing.T) {
 	})
 }
 
+func TestIngressTranslatorRewriteHostInternalEncryption(t *testing.T) {
+	in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = "bar.default.svc.cluster.local"
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits[0].ServicePort = intstr.FromInt(80)
+	})
+	state := []runtime.Object{
+		ns("simplens"),
+		svc("servicens", "servicename", func(service *corev1.Service) {
+			service.Spec.Type = corev1.ServiceTypeExternalName
+			service.Spec.ExternalName = "kourier-internal.kourier-system.svc.cluster.local"
+			service.Spec.Ports = []corev1.ServicePort{{
+				Name:       "http2",
+				Port:       int32(80),
+				TargetPort: intstr.FromInt(80),
+			}}
+		}),
+		caSecret,
+	}
+
+	tlsAny, _ := anypb.New(&auth.UpstreamTlsContext{
+		Sni: "bar.default.svc.cluster.local",
+		CommonTlsContext: &auth.CommonTlsContext{
+			AlpnProtocols: []string{"h2"},
+			TlsParams: &auth.TlsParameters{
+				TlsMinimumProtocolVersion: auth.TlsParameters_TLSv1_2,
+			},
+			ValidationContextType: &auth.CommonTlsContext_ValidationContext{
+				ValidationContext: &auth.CertificateValidationContext{
+					TrustedCa: &envoycorev3.DataSource{
+						Specifier: &envoycorev3.DataSource_InlineBytes{
+							InlineBytes: cert,
+						},
+					},
+					MatchSubjectAltNames: []*envoymatcherv3.StringMatcher{{
+						MatchPattern: &envoymatcherv3.StringMatcher_Exact{
+							Exact: "bar.default.svc.cluster.local",
+						}},
+					},
+				},
+			},
+		},
+	})
+	want := []*v3.Cluster{
+		envoy.NewCluster(
+			"servicens/servicename",
+			5*time.Second,
+			[]*endpoint.LbEndpoint{
+				envoy.NewLBEndpoint("kourier-internal.kourier-system.svc.cluster.local", 443),
+			},
+			true, /* http2 */
+			&envoycorev3.TransportSocket{
+				Name: wellknown.TransportSocketTls,
+				ConfigType: &envoycorev3.TransportSocket_TypedConfig{
+					TypedConfig: tlsAny,
+				},
+			},
+			v3.Cluster_LOGICAL_DNS,
+		),
+	}
+
+	cfg := upstreamTLSConfig.DeepCopy()
+	cfg.Kourier.RewriteHostInternalEncryption = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(state...)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got.clusters, want, protocmp.Transform())
+}
+
+func TestIngressTranslatorWithSPIFFE(t *testing.T) {
+	cfg := upstreamTLSConfig.DeepCopy()
+	cfg.Kourier.SPIFFEWorkloadAPISocket = "/run/spire/sockets/agent.sock"
+	cfg.Kourier.SPIFFEUpstreamSANPatterns = []string{"spiffe://example.org/ns/*"}
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	// The Knative serving CA secret is not needed.
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+	})
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+
+	tlsAny, _ := anypb.New(envoy.NewSPIFFEUpstreamTLSContext(
+		"/run/spire/sockets/agent.sock", []string{"spiffe://example.org/ns/*"}, ""))
+	assert.DeepEqual(t, got.clusters[0].TransportSocket, &envoycorev3.TransportSocket{
+		Name: wellknown.TransportSocketTls,
+		ConfigType: &envoycorev3.TransportSocket_TypedConfig{
+			TypedConfig: tlsAny,
+		},
+	}, protocmp.Transform())
+}
+
+func TestIngressTranslatorUpstreamALPNNegotiation(t *testing.T) {
+	cfg := upstreamTLSConfig.DeepCopy()
+	cfg.Kourier.SPIFFEWorkloadAPISocket = "/run/spire/sockets/agent.sock"
+	cfg.Kourier.UpstreamALPNNegotiation = true
+	cfg.Kourier.UpstreamHTTP2MaxConcurrentStreams = 100
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+	})
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.clusters), 1)
+
+	// Both protocols are offered to the backend, whose port isn't named after HTTP/2.
+	tlsContext := &auth.UpstreamTlsContext{}
+	assert.NilError(t, got.clusters[0].TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+	assert.DeepEqual(t, tlsContext.CommonTlsContext.AlpnProtocols, []string{"h2", "http/1.1"})
+
+	options := &httpOptions.HttpProtocolOptions{}
+	assert.NilError(t, got.clusters[0].TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(options))
+	assert.Equal(t, options.GetAutoConfig().GetHttp2ProtocolOptions().GetMaxConcurrentStreams().GetValue(), uint32(100))
+}
+
+func TestIngressTranslatorUpstreamClientCertificate(t *testing.T) {
+	cfg := upstreamTLSConfig.DeepCopy()
+	cfg.Kourier.UpstreamClientCertificateSecret = types.NamespacedName{Namespace: "secretns", Name: "secretname"}
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"), caSecret, secret)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+	})
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+
+	tlsContext := createUpstreamTLSContext(cert, []string{certificates.FakeDnsName}, "")
+	envoy.SetClientCertificate(tlsContext, "secretns/secretname")
+	tlsAny, _ := anypb.New(tlsContext)
+	assert.DeepEqual(t, got.clusters[0].TransportSocket, &envoycorev3.TransportSocket{
+		Name: wellknown.TransportSocketTls,
+		ConfigType: &envoycorev3.TransportSocket_TypedConfig{
+			TypedConfig: tlsAny,
+		},
+	}, protocmp.Transform())
+	assert.DeepEqual(t, got.upstreamClientSecret, envoy.NewSecret("secretns/secretname", cert, privateKey), protocmp.Transform())
+
+	// A secret without a private key is rejected.
+	kubeclient = fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"), caSecret,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "secretns", Name: "secretname"},
+			Data:       map[string][]byte{"tls.crt": cert},
+		})
+	_, err = translator.translateIngress(ctx, in, false)
+	assert.ErrorContains(t, err, "upstream client certificate secret")
+}
+
+func TestIngressTranslatorUpstreamRequestBudget(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.UpstreamRequestBudget = 30 * time.Second
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename"), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		assert.Assert(t, vh.IncludeRequestAttemptCount)
+		for _, r := range vh.Routes {
+			assert.Equal(t, r.GetRoute().Timeout.AsDuration(), 30*time.Second)
+		}
+	}
+}
+
+func TestIngressTranslatorInternalTrafficPriority(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.InternalTrafficPriority = true
+	cfg.Kourier.ExternalMaxRequests = 100
+	cfg.Kourier.InternalMaxRequests = 50
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename"), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+	assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+	for _, vh := range got.internalVirtualHosts {
+		for _, r := range vh.Routes {
+			assert.Equal(t, r.GetRoute().Priority, envoycorev3.RoutingPriority_HIGH)
+		}
+	}
+	for _, vh := range got.externalVirtualHosts {
+		for _, r := range vh.Routes {
+			assert.Equal(t, r.GetRoute().Priority, envoycorev3.RoutingPriority_DEFAULT)
+		}
+	}
+
+	for _, cluster := range got.clusters {
+		assert.Equal(t, len(cluster.CircuitBreakers.GetThresholds()), 2)
+	}
+}
+
+func TestIngressTranslatorInternalUpgradeProtection(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.InternalUpgradeProtection = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.UpgradesAnnotationKey: "websocket,CONNECT"}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+	assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+	enabled := func(r *route.Route) map[string]bool {
+		upgrades := make(map[string]bool, len(r.GetRoute().UpgradeConfigs))
+		for _, upgrade := range r.GetRoute().UpgradeConfigs {
+			upgrades[upgrade.UpgradeType] = upgrade.Enabled.GetValue()
+		}
+		return upgrades
+	}
+
+	// Only WebSockets are accepted internally, while the external hosts keep the upgrades
+	// of the Ingress.
+	for _, vh := range got.internalVirtualHosts {
+		for _, r := range vh.Routes {
+			assert.DeepEqual(t, enabled(r), map[string]bool{"websocket": true, "CONNECT": false, envoy.UpgradeH2C: false})
+		}
+	}
+	for _, vh := range got.externalVirtualHosts {
+		for _, r := range vh.Routes {
+			assert.DeepEqual(t, enabled(r), map[string]bool{"websocket": true, "CONNECT": true})
+		}
+	}
+}
+
+func TestIngressTranslatorMaxRequests(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.MaxRequestsAnnotationKey: "10"}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.clusters) != 0)
+
+	for _, cluster := range got.clusters {
+		for _, threshold := range cluster.CircuitBreakers.GetThresholds() {
+			assert.Equal(t, threshold.MaxRequests.GetValue(), uint32(10))
+		}
+	}
+
+	_, err = translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.MaxRequestsAnnotationKey: "0"}
+	}), false)
+	assert.Assert(t, err != nil)
+}
+
+func TestIngressTranslatorUpgrades(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{
+			pkgconfig.UpgradesAnnotationKey:    "none",
+			pkgconfig.IdleTimeoutAnnotationKey: "1h",
+		}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		for _, r := range vh.Routes {
+			assert.Equal(t, len(r.GetRoute().UpgradeConfigs), 0)
+			assert.Equal(t, r.GetRoute().IdleTimeout.AsDuration(), time.Hour)
+		}
+	}
+}
+
+func TestIngressTranslatorTimeouts(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.UpstreamConnectionIdleTimeout = time.Minute
+	cfg.Kourier.UpstreamMaxStreamDuration = time.Hour
+	cfg.Kourier.UpstreamConnectTimeout = 10 * time.Second
+	cfg.Kourier.UpstreamTCPKeepaliveProbes = 3
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{
+			pkgconfig.MaxStreamDurationAnnotationKey:             "2h",
+			pkgconfig.UpstreamConnectionIdleTimeoutAnnotationKey: "10s",
+			pkgconfig.UpstreamConnectTimeoutAnnotationKey:        "30s",
+			pkgconfig.UpstreamTCPKeepaliveTimeAnnotationKey:      "1m",
+		}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+	assert.Assert(t, len(got.clusters) != 0)
+
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		for _, r := range vh.Routes {
+			assert.Equal(t, r.GetRoute().MaxStreamDuration.GetMaxStreamDuration().AsDuration(), 2*time.Hour)
+		}
+	}
+
+	for _, cluster := range got.clusters {
+		options := &httpOptions.HttpProtocolOptions{}
+		assert.NilError(t, cluster.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(options))
+		assert.Equal(t, options.CommonHttpProtocolOptions.IdleTimeout.AsDuration(), 10*time.Second)
+		assert.Equal(t, options.CommonHttpProtocolOptions.MaxStreamDuration.AsDuration(), time.Hour)
+
+		assert.Equal(t, cluster.ConnectTimeout.AsDuration(), 30*time.Second)
+		assert.Equal(t, cluster.UpstreamConnectionOptions.TcpKeepalive.KeepaliveTime.GetValue(), uint32(60))
+		assert.Equal(t, cluster.UpstreamConnectionOptions.TcpKeepalive.KeepaliveProbes.GetValue(), uint32(3))
+	}
+}
+
+func TestIngressTranslatorExternalNameHealthCheck(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(
+		ns("simplens"),
+		svc("servicens", "servicename", func(service *corev1.Service) {
+			service.Spec.Type = corev1.ServiceTypeExternalName
+			service.Spec.ExternalName = "example.com"
+		}),
+		svc("servicens", "internal"), eps("servicens", "internal"),
+	)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.ExternalNameHealthCheckAnnotationKey: "/healthz"}
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+		internal := ing.Spec.Rules[0].HTTP.Paths[0].DeepCopy()
+		internal.Path = "/internal"
+		internal.Splits[0].ServiceName = "internal"
+		ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, *internal)
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.clusters), 2)
+
+	// Only the ExternalName service is health checked.
+	for _, cluster := range got.clusters {
+		if cluster.Name == "servicens/servicename" {
+			assert.Equal(t, len(cluster.HealthChecks), 1)
+			assert.Equal(t, cluster.HealthChecks[0].GetHttpHealthCheck().Path, "/healthz")
+			assert.Equal(t, cluster.HealthChecks[0].GetHttpHealthCheck().Host, "example.com")
+		} else {
+			assert.Equal(t, len(cluster.HealthChecks), 0)
+		}
+	}
+
+	_, err = translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.ExternalNameHealthCheckAnnotationKey: "healthz"}
+	}), false)
+	assert.ErrorContains(t, err, pkgconfig.ExternalNameHealthCheckAnnotationKey)
+}
+
+func TestIngressTranslatorExternalNameTLS(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Network.InternalEncryption = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(
+		ns("simplens"),
+		svc("servicens", "servicename", func(service *corev1.Service) {
+			service.Spec.Type = corev1.ServiceTypeExternalName
+			service.Spec.ExternalName = "example.com"
+		}),
+	)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.ExternalNameTLSAnnotationKey: "true"}
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.clusters), 1)
+
+	// The backend is verified against the system's CAs, rather than the CA of the
+	// internal encryption.
+	tlsContext := &auth.UpstreamTlsContext{}
+	assert.NilError(t, got.clusters[0].TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+	assert.DeepEqual(t, tlsContext, envoy.NewExternalUpstreamTLSContext("example.com"), protocmp.Transform())
+}
+
+func TestIngressTranslatorRequestBuffering(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.MaxRequestBodyBytesAnnotationKey: "off"}
+	})
+
+	// The bodies aren't buffered without buffering enabled on the gateway.
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		assert.Assert(t, vh.TypedPerFilterConfig[wellknown.Buffer] == nil)
+	}
+
+	cfg.Kourier.MaxRequestBodyBytes = 1024
+	got, err = translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.internalVirtualHosts) != 0)
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		assert.Assert(t, vh.TypedPerFilterConfig[wellknown.Buffer] != nil)
+	}
+}
+
+func TestDomainsForRule(t *testing.T) {
+	rule := v1alpha1.IngressRule{Hosts: []string{"foo.example.com", "foo.ns.svc.cluster.local"}}
+
+	assert.DeepEqual(t, domainsForRule(rule, false),
+		[]string{"foo.example.com", "foo.example.com:*", "foo.ns.svc.cluster.local", "foo.ns.svc.cluster.local:*"})
+	assert.DeepEqual(t, domainsForRule(rule, true), []string{"foo.example.com", "foo.ns.svc.cluster.local"})
+}
+
+func TestUpstreamSANs(t *testing.T) {
+	assert.DeepEqual(t, upstreamSANs("", "servicens"), []string{certificates.FakeDnsName})
+	assert.DeepEqual(t, upstreamSANs(pkgconfig.UpstreamSANValidationIdentity, "other"), []string{"kn-routing", "kn-user-other"})
+	assert.DeepEqual(t, upstreamSANs(pkgconfig.UpstreamSANValidationLegacy, "servicens"), []string{certificates.FakeDnsName})
+}
+
+func TestIngressTranslatorListenerPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    string
+		want    string
+		wantErr bool
+	}{{
+		name: "valid port",
+		port: "8888",
+		want: "8888",
+	}, {
+		name: "leading zeros",
+		port: "08888",
+		want: "8888",
+	}, {
+		name:    "not a number",
+		port:    "foo",
+		wantErr: true,
+	}, {
+		name:    "out of range",
+		port:    "70000",
+		wantErr: true,
+	}, {
+		name:    "system port",
+		port:    "8081",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.TrafficIsolation = pkgconfig.IsolationIngressPort
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			namespace := ns("simplens")
+			namespace.Annotations = map[string]string{pkgconfig.ListenerPortAnnotationKey: test.port}
+			kubeclient := fake.NewSimpleClientset(namespace, svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, ing("simplens", "simplename"), false)
+			if test.wantErr {
+				assert.Assert(t, errors.Is(err, ErrInvalidListenerPort), "got error %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got.listenerPort, test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorListenerPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    string
+		tls     bool
+		wantErr bool
+	}{{
+		name: "plain HTTP pool",
+		pool: "public",
+	}, {
+		name: "TLS pool with TLS settings",
+		pool: "partner",
+		tls:  true,
+	}, {
+		name:    "TLS pool without TLS settings",
+		pool:    "partner",
+		wantErr: true,
+	}, {
+		name:    "unknown pool",
+		pool:    "unknown",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.ListenerPools = map[string]pkgconfig.ListenerPool{
+				"partner": {Port: 7443, RequireTLS: true},
+				"public":  {Port: 7080},
+			}
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"), secret)
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+				ing.Annotations = map[string]string{pkgconfig.ListenerPoolAnnotationKey: test.pool}
+				if test.tls {
+					ing.Spec.TLS = []v1alpha1.IngressTLS{{
+						Hosts:           []string{"foo.example.com"},
+						SecretNamespace: "secretns",
+						SecretName:      "secretname",
+					}}
+				}
+			})
+			got, err := translator.translateIngress(ctx, in, false)
+			if test.wantErr {
+				assert.Assert(t, errors.Is(err, ErrInvalidListenerPool), "got error %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got.listenerPool, test.pool)
+		})
+	}
+}
+
+func TestIngressTranslatorTrustBundles(t *testing.T) {
+	trustBundle := func(name string, labels map[string]string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "knative-testing",
+				Name:      name,
+				Labels:    labels,
+			},
+			Data: data,
+		}
+	}
+	trustBundleLabels := map[string]string{pkgconfig.TrustBundleLabelKey: "true"}
+
+	tests := []struct {
+		name  string
+		state []runtime.Object
+		want  string
+	}{{
+		name:  "only the internal CA",
+		state: []runtime.Object{caSecret},
+		want:  "cert",
+	}, {
+		name: "internal CA and trust bundles",
+		state: []runtime.Object{
+			caSecret,
+			trustBundle("b-bundle", trustBundleLabels, map[string]string{"ca.pem": "ca-b\n"}),
+			trustBundle("a-bundle", trustBundleLabels, map[string]string{"2.pem": "ca-a2", "1.pem": "ca-a1"}),
+			trustBundle("unlabeled", nil, map[string]string{"ca.pem": "unlabeled"}),
+		},
+		want: "cert\nca-a1\nca-a2\nca-b\n",
+	}, {
+		name: "only trust bundles",
+		state: []runtime.Object{
+			trustBundle("bundle", trustBundleLabels, map[string]string{"ca.pem": "ca"}),
+		},
+		want: "ca",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := (&testConfigStore{config: upstreamTLSConfig}).ToContext(context.Background())
+
+			state := append(test.state, ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+			kubeclient := fake.NewSimpleClientset(state...)
+			var observers []types.NamespacedName
+			objectTracker := tracker.New(func(key types.NamespacedName) {
+				observers = append(observers, key)
+			}, time.Minute)
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				objectTracker,
+			)
+
+			in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+				ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+			})
+			got, err := translator.translateIngress(ctx, in, false)
+			assert.NilError(t, err)
+
+			tlsContext := &auth.UpstreamTlsContext{}
+			assert.NilError(t, got.clusters[0].TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+			assert.Equal(t, string(tlsContext.CommonTlsContext.GetValidationContext().TrustedCa.GetInlineBytes()), test.want)
+
+			// Changes to new trust bundles have to trigger a reconciliation.
+			observers = nil
+			objectTracker.OnChanged(trustBundle("new-bundle", trustBundleLabels, nil))
+			assert.DeepEqual(t, observers, []types.NamespacedName{{Namespace: "simplens", Name: "simplename"}})
+		})
+	}
+
+	t.Run("no CA certificates", func(t *testing.T) {
+		ctx := (&testConfigStore{config: upstreamTLSConfig}).ToContext(context.Background())
+		kubeclient := fake.NewSimpleClientset(ns("simplens"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+		translator := NewIngressTranslator(
+			func(ns, name string) (*corev1.Secret, error) {
+				return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(ns, name string) (*corev1.Endpoints, error) {
+				return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(ns, name string) (*corev1.Service, error) {
+				return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+			},
+			func(name string) (*corev1.Namespace, error) {
+				return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			},
+			configMapsGetter(ctx, kubeclient),
+			&pkgtest.FakeTracker{},
+		)
+
+		in := ing("simplens", "simplename", func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+		})
+		_, err := translator.translateIngress(ctx, in, false)
+		assert.ErrorContains(t, err, "no CA certificates found")
+	})
+}
+
+func TestIngressTranslatorDefaultCertificate(t *testing.T) {
+	globalSecret := secret.DeepCopy()
+	globalSecret.Namespace = "kourier-system"
+	globalSecret.Name = "wildcard-cert"
+
+	namespaceSecret := secret.DeepCopy()
+	namespaceSecret.Namespace = "testspace"
+	namespaceSecret.Name = "namespace-cert"
+
+	nsWithDefaultCert := ns("testspace")
+	nsWithDefaultCert.Annotations = map[string]string{
+		pkgconfig.DefaultCertificateAnnotationKey: "namespace-cert",
+	}
+
+	tests := []struct {
+		name          string
+		in            *v1alpha1.Ingress
+		globalDefault types.NamespacedName
+		state         []runtime.Object
+		want          []*envoy.SNIMatch
+	}{{
+		name:  "no default certificate",
+		in:    ing("testspace", "testname"),
+		state: []runtime.Object{ns("testspace")},
+		want:  []*envoy.SNIMatch{},
+	}, {
+		name:          "global default certificate",
+		in:            ing("testspace", "testname"),
+		globalDefault: types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"},
+		state:         []runtime.Object{ns("testspace"), globalSecret},
+		want: []*envoy.SNIMatch{{
+			Hosts:            []string{"foo.example.com"},
+			CertSource:       types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"},
+			CertificateChain: cert,
+			PrivateKey:       privateKey,
+		}},
+	}, {
+		name:          "namespace default certificate takes precedence",
+		in:            ing("testspace", "testname"),
+		globalDefault: types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"},
+		state:         []runtime.Object{nsWithDefaultCert, globalSecret, namespaceSecret},
+		want: []*envoy.SNIMatch{{
+			Hosts:            []string{"foo.example.com"},
+			CertSource:       types.NamespacedName{Namespace: "testspace", Name: "namespace-cert"},
+			CertificateChain: cert,
+			PrivateKey:       privateKey,
+		}},
+	}, {
+		name: "hosts covered by the ingress' TLS settings",
+		in: ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+			ing.Spec.TLS = []v1alpha1.IngressTLS{{
+				Hosts:           []string{"foo.example.com"},
+				SecretNamespace: "secretns",
+				SecretName:      "secretname",
+			}}
+		}),
+		globalDefault: types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"},
+		state:         []runtime.Object{ns("testspace"), secret, globalSecret},
+		want: []*envoy.SNIMatch{{
+			Hosts:            []string{"foo.example.com"},
+			CertSource:       types.NamespacedName{Namespace: "secretns", Name: "secretname"},
+			CertificateChain: cert,
+			PrivateKey:       privateKey,
+		}},
+	}, {
+		name: "cluster local hosts",
+		in: ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityClusterLocal
+		}),
+		globalDefault: types.NamespacedName{Namespace: "kourier-system", Name: "wildcard-cert"},
+		state:         []runtime.Object{ns("testspace"), globalSecret},
+		want:          []*envoy.SNIMatch{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.DefaultCertificateSecret = test.globalDefault
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			state := append(test.state, svc("servicens", "servicename"), eps("servicens", "servicename"))
+			kubeclient := fake.NewSimpleClientset(state...)
+			tracker := &pkgtest.FakeTracker{}
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				tracker,
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got.sniMatches, test.want)
+
+			wantTLSHosts := 0
+			if len(test.want) != 0 && test.in.Spec.Rules[0].Visibility == v1alpha1.IngressVisibilityExternalIP {
+				wantTLSHosts = 1
+			}
+			assert.Equal(t, len(got.externalTLSVirtualHosts), wantTLSHosts)
+
+			for _, match := range test.want {
+				trackedSecret := &corev1.Secret{
+					TypeMeta: metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: match.CertSource.Namespace,
+						Name:      match.CertSource.Name,
+					},
+				}
+				assert.Equal(t, len(tracker.GetObservers(trackedSecret)), 1)
+			}
+		})
+	}
+}
+
+func TestIngressTranslatorClientValidation(t *testing.T) {
+	caSecretIn := func(ns, name string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Data:       map[string][]byte{"ca.crt": []byte("ca")},
+		}
+	}
+	withTLS := func(ing *v1alpha1.Ingress) {
+		ing.Spec.TLS = []v1alpha1.IngressTLS{{
+			Hosts:           []string{"foo.example.com"},
+			SecretNamespace: "secretns",
+			SecretName:      "secretname",
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		in            *v1alpha1.Ingress
+		globalCA      types.NamespacedName
+		globalSANs    []string
+		state         []runtime.Object
+		want          *envoy.ClientValidation
+		wantErrString string
+	}{{
+		name:  "no client validation",
+		in:    ing("testspace", "testname", withTLS),
+		state: []runtime.Object{secret},
+	}, {
+		name:       "global client validation",
+		in:         ing("testspace", "testname", withTLS),
+		globalCA:   types.NamespacedName{Namespace: "kourier-system", Name: "client-ca"},
+		globalSANs: []string{"client.example.com"},
+		state:      []runtime.Object{secret, caSecretIn("kourier-system", "client-ca")},
+		want: &envoy.ClientValidation{
+			CASource:       types.NamespacedName{Namespace: "kourier-system", Name: "client-ca"},
+			CACertificates: []byte("ca"),
+			AllowedSANs:    []string{"client.example.com"},
+		},
+	}, {
+		name: "ingress client validation takes precedence",
+		in: ing("testspace", "testname", withTLS, func(ing *v1alpha1.Ingress) {
+			ing.Annotations = map[string]string{
+				pkgconfig.ClientCASecretAnnotationKey:    "ingress-ca",
+				pkgconfig.ClientAllowedSANsAnnotationKey: "a.example.com, b.example.com",
+			}
+		}),
+		globalCA:   types.NamespacedName{Namespace: "kourier-system", Name: "client-ca"},
+		globalSANs: []string{"client.example.com"},
+		state:      []runtime.Object{secret, caSecretIn("testspace", "ingress-ca")},
+		want: &envoy.ClientValidation{
+			CASource:       types.NamespacedName{Namespace: "testspace", Name: "ingress-ca"},
+			CACertificates: []byte("ca"),
+			AllowedSANs:    []string{"a.example.com", "b.example.com"},
+		},
+	}, {
+		name: "no TLS",
+		in: ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+			ing.Annotations = map[string]string{
+				pkgconfig.ClientCASecretAnnotationKey: "ingress-ca",
+			}
+		}),
+		state: []runtime.Object{caSecretIn("testspace", "ingress-ca")},
+	}, {
+		name:          "CA secret without CA",
+		in:            ing("testspace", "testname", withTLS),
+		globalCA:      types.NamespacedName{Namespace: "secretns", Name: "secretname"},
+		state:         []runtime.Object{secret},
+		wantErrString: `client CA secret 'secretns/secretname' has no "ca.crt" field`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.ClientCASecret = test.globalCA
+			cfg.Kourier.ClientAllowedSANs = test.globalSANs
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			state := append(test.state, ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+			kubeclient := fake.NewSimpleClientset(state...)
+
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErrString != "" {
+				assert.ErrorContains(t, err, test.wantErrString)
+				return
+			}
+			assert.NilError(t, err)
+			for _, match := range got.sniMatches {
+				assert.DeepEqual(t, match.ClientValidation, test.want)
+			}
+		})
+	}
+}
+
 func ing(ns, name string, opts ...func(*v1alpha1.Ingress)) *v1alpha1.Ingress {
 	ingress := &v1alpha1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1450,6 +2619,20 @@ func eps(ns, name string, opts ...func(endpoint *corev1.Endpoints)) *corev1.Endp
 	return serviceEndpoint
 }
 
+func configMapsGetter(ctx context.Context, kubeclient kubernetes.Interface) func(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	return func(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+		list, err := kubeclient.CoreV1().ConfigMaps(ns).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		cms := make([]*corev1.ConfigMap, 0, len(list.Items))
+		for i := range list.Items {
+			cms = append(cms, &list.Items[i])
+		}
+		return cms, nil
+	}
+}
+
 func ns(name string) *corev1.Namespace {
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1567,3 +2750,45 @@ func typedConfig(http2 bool) *envoycorev3.TransportSocket_TypedConfig {
 		TypedConfig: tlsAny,
 	}
 }
+
+func TestIngressTranslatorBehindEdge(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.DisableTLSTermination = true
+	cfg.Kourier.DisableHTTPRedirects = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	// The secret of the TLS settings doesn't even have to exist.
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Spec.HTTPOption = v1alpha1.HTTPOptionRedirected
+		ing.Spec.TLS = []v1alpha1.IngressTLS{{
+			Hosts:           []string{"foo.example.com"},
+			SecretNamespace: "secretns",
+			SecretName:      "secretname",
+		}}
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.sniMatches), 0)
+	assert.Equal(t, len(got.externalTLSVirtualHosts), 0)
+
+	// The plain HTTP requests are routed rather than redirected.
+	assert.Equal(t, len(got.externalVirtualHosts), 1)
+	assert.Assert(t, got.externalVirtualHosts[0].Routes[0].GetRoute() != nil)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// InventoryEntry is a path of an external host served by the gateways.
+type InventoryEntry struct {
+	// Host is the external host.
+	Host string `json:"host"`
+	// Path is the path prefix of the host.
+	Path string `json:"path"`
+	// Services are the target services of the path, as "namespace/name".
+	Services []string `json:"services"`
+	// TLS specifies whether the path is served over HTTPS.
+	TLS bool `json:"tls"`
+	// Ingress is the ingress the path belongs to, as "namespace/name".
+	Ingress string `json:"ingress"`
+}
+
+// Inventory returns the paths of all external hosts served by the gateways, sorted by
+// host and path.
+func (caches *Caches) Inventory() []InventoryEntry {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	type entryKey struct {
+		ingress, host, path string
+	}
+	entries := make(map[entryKey]*InventoryEntry)
+	services := make(map[entryKey]sets.String)
+
+	add := func(ingress string, vhosts []*route.VirtualHost, tls bool) {
+		for _, vhost := range vhosts {
+			for _, host := range vhost.Domains {
+				// Every host is also matched with any port.
+				if strings.HasSuffix(host, ":*") {
+					continue
+				}
+				for _, r := range vhost.Routes {
+					key := entryKey{ingress: ingress, host: host, path: r.GetMatch().GetPrefix()}
+					if entries[key] == nil {
+						entries[key] = &InventoryEntry{Host: key.host, Path: key.path, Ingress: ingress}
+						services[key] = sets.NewString()
+					}
+					entries[key].TLS = entries[key].TLS || tls
+					for _, cluster := range r.GetRoute().GetWeightedClusters().GetClusters() {
+						// Clusters failing over to the activator still target the split's service.
+						services[key].Insert(strings.TrimSuffix(cluster.Name, failoverClusterSuffix))
+					}
+				}
+			}
+		}
+	}
+	for name, translated := range caches.translatedIngresses {
+		add(name.String(), translated.externalVirtualHosts, false)
+		add(name.String(), translated.externalTLSVirtualHosts, true)
+	}
+
+	inventory := make([]InventoryEntry, 0, len(entries))
+	for key, entry := range entries {
+		entry.Services = services[key].List()
+		inventory = append(inventory, *entry)
+	}
+	sort.Slice(inventory, func(i, j int) bool {
+		if inventory[i].Host != inventory[j].Host {
+			return inventory[i].Host < inventory[j].Host
+		}
+		if inventory[i].Path != inventory[j].Path {
+			return inventory[i].Path < inventory[j].Path
+		}
+		return inventory[i].Ingress < inventory[j].Ingress
+	})
+	return inventory
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestInventory(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	wrs := func(services ...string) []*route.WeightedCluster_ClusterWeight {
+		res := make([]*route.WeightedCluster_ClusterWeight, 0, len(services))
+		for _, service := range services {
+			res = append(res, envoy.NewWeightedCluster(service, 50, nil))
+		}
+		return res
+	}
+
+	routes := []*route.Route{
+		envoy.NewRoute("split", nil, "/", wrs("ns/v1", "ns/v2"), 0, nil, ""),
+		envoy.NewRoute("api", nil, "/api", wrs("ns/api"), 0, nil, ""),
+	}
+	vhosts := []*route.VirtualHost{envoy.NewVirtualHost("foo", []string{"foo.example.com", "foo.example.com:*"}, routes)}
+	assert.NilError(t, caches.UpdateIngress(context.Background(), &translatedIngress{
+		name:                    types.NamespacedName{Namespace: "ns", Name: "foo"},
+		externalVirtualHosts:    []*route.VirtualHost{envoy.NewVirtualHost("foo", vhosts[0].Domains, []*route.Route{envoy.NewRedirectRoute("split", nil, "/")})},
+		externalTLSVirtualHosts: vhosts,
+		internalVirtualHosts:    vhosts,
+	}))
+
+	local := []*route.VirtualHost{envoy.NewVirtualHost("bar", []string{"bar.ns.svc.cluster.local"}, routes)}
+	assert.NilError(t, caches.UpdateIngress(context.Background(), &translatedIngress{
+		name:                 types.NamespacedName{Namespace: "ns", Name: "bar"},
+		internalVirtualHosts: local,
+	}))
+
+	plain := []*route.VirtualHost{envoy.NewVirtualHost("baz", []string{"baz.example.com"}, routes[:1])}
+	assert.NilError(t, caches.UpdateIngress(context.Background(), &translatedIngress{
+		name:                 types.NamespacedName{Namespace: "ns", Name: "baz"},
+		externalVirtualHosts: plain,
+		internalVirtualHosts: plain,
+	}))
+
+	assert.DeepEqual(t, caches.Inventory(), []InventoryEntry{{
+		Host:     "baz.example.com",
+		Path:     "/",
+		Services: []string{"ns/v1", "ns/v2"},
+		Ingress:  "ns/baz",
+	}, {
+		Host:     "foo.example.com",
+		Path:     "/",
+		Services: []string{"ns/v1", "ns/v2"},
+		TLS:      true,
+		Ingress:  "ns/foo",
+	}, {
+		Host:     "foo.example.com",
+		Path:     "/api",
+		Services: []string{"ns/api"},
+		TLS:      true,
+		Ingress:  "ns/foo",
+	}})
+}
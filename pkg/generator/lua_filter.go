@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// luaFilterFromAnnotations returns the Lua snippet run on the requests to the hosts of
+// the ingress, as specified via annotations on it, or "" if there's none. The snippet
+// is rejected unless Lua filters are enabled, and if it exceeds their maximum size.
+func luaFilterFromAnnotations(ingress *v1alpha1.Ingress, kourierConfig *pkgconfig.Kourier) (string, error) {
+	snippet := pkgconfig.GetLuaFilter(ingress.Annotations)
+	if snippet == "" {
+		return "", nil
+	}
+	if !kourierConfig.LuaFilters {
+		return "", fmt.Errorf("invalid %s annotation: Lua filters are not enabled",
+			pkgconfig.LuaFilterAnnotationKey)
+	}
+	if maxBytes := kourierConfig.LuaFilterMaxBytes; maxBytes != 0 && len(snippet) > int(maxBytes) {
+		return "", fmt.Errorf("invalid %s annotation: the snippet has %d bytes, exceeding the maximum of %d",
+			pkgconfig.LuaFilterAnnotationKey, len(snippet), maxBytes)
+	}
+	return snippet, nil
+}
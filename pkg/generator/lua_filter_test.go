@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorLuaFilter(t *testing.T) {
+	const snippet = `function envoy_on_request(handle) handle:headers():remove("x-debug") end`
+	withSnippet := func(snippet string) func(*v1alpha1.Ingress) {
+		return func(ing *v1alpha1.Ingress) {
+			ing.Annotations = map[string]string{pkgconfig.LuaFilterAnnotationKey: snippet}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		in       *v1alpha1.Ingress
+		disabled bool
+		want     string
+		wantErr  bool
+	}{{
+		name: "snippet",
+		in:   ing("testspace", "testname", withSnippet(snippet)),
+		want: snippet,
+	}, {
+		name: "no snippet",
+		in:   ing("testspace", "testname"),
+	}, {
+		name:     "not enabled",
+		in:       ing("testspace", "testname", withSnippet(snippet)),
+		disabled: true,
+		wantErr:  true,
+	}, {
+		name:    "too large",
+		in:      ing("testspace", "testname", withSnippet(strings.Repeat("-", 4097))),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.LuaFilters = !test.disabled
+			cfg.Kourier.LuaFilterMaxBytes = 4096
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+			for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+				filter := vh.TypedPerFilterConfig["kourier.lua_snippet"]
+				if test.want == "" {
+					assert.Assert(t, filter == nil)
+					continue
+				}
+				perRoute := &lua.LuaPerRoute{}
+				assert.NilError(t, filter.UnmarshalTo(perRoute))
+				assert.Equal(t, perRoute.GetSourceCode().GetInlineString(), test.want)
+			}
+		})
+	}
+}
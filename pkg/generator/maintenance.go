@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// maxMaintenanceBodyBytes is the size limit of the bodies of the direct responses in
+// Envoy's route configs.
+const maxMaintenanceBodyBytes = 4096
+
+// maintenance is the maintenance mode of an Ingress as specified in the maintenance
+// annotation. The gateways respond to the requests to the paths under maintenance
+// themselves, rather than routing them to the backends.
+//
+// Example: {"statusCode": 503, "body": "Under maintenance", "paths": ["/api"]}
+type maintenance struct {
+	// StatusCode is the status of the responses, 503 by default.
+	StatusCode uint32 `json:"statusCode,omitempty"`
+	// Body is the body of the responses, empty by default.
+	Body string `json:"body,omitempty"`
+	// ContentType is the content type of the body.
+	ContentType string `json:"contentType,omitempty"`
+	// Paths are the paths of the Ingress under maintenance, all of them if empty.
+	Paths []string `json:"paths,omitempty"`
+}
+
+func (m maintenance) validate(ingress *v1alpha1.Ingress) error {
+	if m.StatusCode < 200 || m.StatusCode > 599 {
+		return fmt.Errorf("statusCode must be between 200 and 599, got %d", m.StatusCode)
+	}
+	if len(m.Body) > maxMaintenanceBodyBytes {
+		return fmt.Errorf("body must be at most %d bytes, got %d", maxMaintenanceBodyBytes, len(m.Body))
+	}
+
+	paths := sets.NewString()
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, httpPath := range rule.HTTP.Paths {
+			paths.Insert(normalizedPath(httpPath.Path))
+		}
+	}
+	for _, path := range m.Paths {
+		if !paths.Has(normalizedPath(path)) {
+			return fmt.Errorf("%q is not a path of the Ingress", path)
+		}
+	}
+	return nil
+}
+
+// maintenanceFromAnnotations returns the maintenance mode of the Ingress, as specified
+// via annotations on it. Returns nil if it isn't under maintenance.
+func maintenanceFromAnnotations(ingress *v1alpha1.Ingress) (*maintenance, error) {
+	raw := pkgconfig.GetMaintenance(ingress.Annotations)
+	if raw == "" {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	m := maintenance{StatusCode: http.StatusServiceUnavailable}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.MaintenanceAnnotationKey, err)
+	}
+	if err := m.validate(ingress); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.MaintenanceAnnotationKey, err)
+	}
+	return &m, nil
+}
+
+// covers returns whether the given path of the Ingress is under maintenance.
+func (m *maintenance) covers(path string) bool {
+	if len(m.Paths) == 0 {
+		return true
+	}
+	for _, p := range m.Paths {
+		if normalizedPath(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// route creates the route responding to the requests matching the given path prefix and
+// headers.
+func (m *maintenance) route(name string, headersMatch []*route.HeaderMatcher, path string) *route.Route {
+	return envoy.NewDirectResponseRoute(name, headersMatch, path, m.StatusCode, m.Body, m.ContentType)
+}
+
+// normalizedPath returns the path prefix matched by the given path of an Ingress.
+func normalizedPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestMaintenanceFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       *maintenance
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "defaults",
+		annotation: `{}`,
+		want:       &maintenance{StatusCode: 503},
+	}, {
+		name:       "all fields",
+		annotation: `{"statusCode": 200, "body": "<h1>Back soon</h1>", "contentType": "text/html", "paths": ["/test"]}`,
+		want:       &maintenance{StatusCode: 200, Body: "<h1>Back soon</h1>", ContentType: "text/html", Paths: []string{"/test"}},
+	}, {
+		name:       "invalid status code",
+		annotation: `{"statusCode": 600}`,
+		wantErr:    true,
+	}, {
+		name:       "unknown path",
+		annotation: `{"paths": ["/other"]}`,
+		wantErr:    true,
+	}, {
+		name:       "unknown field",
+		annotation: `{"status": 503}`,
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := ing("testspace", "testname")
+			if test.annotation != "" {
+				in.Annotations = map[string]string{pkgconfig.MaintenanceAnnotationKey: test.annotation}
+			}
+
+			got, err := maintenanceFromAnnotations(in)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorMaintenance(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.MaintenanceAnnotationKey: `{"body": "Under maintenance", "paths": ["/test"]}`}
+		ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, v1alpha1.HTTPIngressPath{
+			Path: "/",
+			Splits: []v1alpha1.IngressBackendSplit{{
+				Percent: 100,
+				IngressBackend: v1alpha1.IngressBackend{
+					ServiceNamespace: "servicens",
+					ServiceName:      "servicename",
+					ServicePort:      intstr.FromString("http"),
+				},
+			}},
+		})
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.externalVirtualHosts), 1)
+
+	routes := got.externalVirtualHosts[0].Routes
+	assert.Equal(t, len(routes), 2)
+
+	// The path under maintenance is answered by the gateways.
+	assert.Equal(t, routes[0].Match.GetPrefix(), "/test")
+	assert.Equal(t, routes[0].GetDirectResponse().Status, uint32(503))
+	assert.Equal(t, routes[0].GetDirectResponse().Body.GetInlineString(), "Under maintenance")
+
+	// The other paths are still routed to the backends.
+	assert.Equal(t, routes[1].Match.GetPrefix(), "/")
+	assert.Assert(t, routes[1].GetRoute() != nil)
+
+	// The backends are kept, so that the Ingress resumes as soon as the annotation is removed.
+	assert.Assert(t, len(got.clusters) != 0)
+}
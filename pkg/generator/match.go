@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// matchSpec is a single matcher as specified in the header-match and
+// query-param-match annotations. Exactly one of Exact, Prefix, Suffix, Regex
+// and Present has to be set.
+//
+// Example: [{"name": "x-version", "regex": "^v[0-9]+$"}, {"name": "x-debug", "present": true, "invert": true}]
+type matchSpec struct {
+	Name    string `json:"name"`
+	Exact   string `json:"exact,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Suffix  string `json:"suffix,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Present bool   `json:"present,omitempty"`
+	// Invert inverts the result of the match. Only supported for headers.
+	Invert bool `json:"invert,omitempty"`
+}
+
+func (m matchSpec) validate() error {
+	if m.Name == "" {
+		return errors.New("name must be set")
+	}
+
+	set := 0
+	for _, isSet := range []bool{m.Exact != "", m.Prefix != "", m.Suffix != "", m.Regex != "", m.Present} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of exact, prefix, suffix, regex or present must be set for %q", m.Name)
+	}
+
+	if m.Regex != "" {
+		// Go's regexp package implements RE2, which is what Envoy uses as well.
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("invalid regex for %q: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m matchSpec) stringMatcher() *envoymatcherv3.StringMatcher {
+	switch {
+	case m.Exact != "":
+		return &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: m.Exact},
+		}
+	case m.Prefix != "":
+		return &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Prefix{Prefix: m.Prefix},
+		}
+	case m.Suffix != "":
+		return &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_Suffix{Suffix: m.Suffix},
+		}
+	case m.Regex != "":
+		return &envoymatcherv3.StringMatcher{
+			MatchPattern: &envoymatcherv3.StringMatcher_SafeRegex{SafeRegex: safeRegex(m.Regex)},
+		}
+	}
+	return nil
+}
+
+func (m matchSpec) headerMatcher() *route.HeaderMatcher {
+	matcher := &route.HeaderMatcher{
+		Name:        m.Name,
+		InvertMatch: m.Invert,
+	}
+	if m.Present {
+		matcher.HeaderMatchSpecifier = &route.HeaderMatcher_PresentMatch{PresentMatch: true}
+	} else {
+		matcher.HeaderMatchSpecifier = &route.HeaderMatcher_StringMatch{StringMatch: m.stringMatcher()}
+	}
+	return matcher
+}
+
+func (m matchSpec) queryParameterMatcher() *route.QueryParameterMatcher {
+	matcher := &route.QueryParameterMatcher{
+		Name: m.Name,
+	}
+	if m.Present {
+		matcher.QueryParameterMatchSpecifier = &route.QueryParameterMatcher_PresentMatch{PresentMatch: true}
+	} else {
+		matcher.QueryParameterMatchSpecifier = &route.QueryParameterMatcher_StringMatch{StringMatch: m.stringMatcher()}
+	}
+	return matcher
+}
+
+func safeRegex(regex string) *envoymatcherv3.RegexMatcher {
+	return &envoymatcherv3.RegexMatcher{
+		EngineType: &envoymatcherv3.RegexMatcher_GoogleRe2{GoogleRe2: &envoymatcherv3.RegexMatcher_GoogleRE2{}},
+		Regex:      regex,
+	}
+}
+
+func parseMatchSpecs(raw string) ([]matchSpec, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var specs []matchSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, err
+	}
+	for _, spec := range specs {
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return specs, nil
+}
+
+// matchersFromAnnotations returns the additional header and query parameter matchers
+// specified via annotations on the Ingress.
+func matchersFromAnnotations(annotations map[string]string) ([]*route.HeaderMatcher, []*route.QueryParameterMatcher, error) {
+	headerSpecs, err := parseMatchSpecs(pkgconfig.GetHeaderMatch(annotations))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.HeaderMatchAnnotationKey, err)
+	}
+	querySpecs, err := parseMatchSpecs(pkgconfig.GetQueryParamMatch(annotations))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.QueryParamMatchAnnotationKey, err)
+	}
+
+	headers := make([]*route.HeaderMatcher, 0, len(headerSpecs))
+	for _, spec := range headerSpecs {
+		headers = append(headers, spec.headerMatcher())
+	}
+
+	queryParams := make([]*route.QueryParameterMatcher, 0, len(querySpecs))
+	for _, spec := range querySpecs {
+		if spec.Invert {
+			return nil, nil, fmt.Errorf("invalid %s annotation: invert is not supported for query parameters", pkgconfig.QueryParamMatchAnnotationKey)
+		}
+		queryParams = append(queryParams, spec.queryParameterMatcher())
+	}
+
+	return headers, queryParams, nil
+}
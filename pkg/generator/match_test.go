@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoymatcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestMatchersFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantHeaders []*route.HeaderMatcher
+		wantQuery   []*route.QueryParameterMatcher
+		wantErr     bool
+	}{{
+		name:        "no annotations",
+		wantHeaders: []*route.HeaderMatcher{},
+		wantQuery:   []*route.QueryParameterMatcher{},
+	}, {
+		name: "header matchers",
+		annotations: map[string]string{
+			pkgconfig.HeaderMatchAnnotationKey: `[
+				{"name": "x-regex", "regex": "^v[0-9]+$"},
+				{"name": "x-prefix", "prefix": "foo"},
+				{"name": "x-suffix", "suffix": "bar"},
+				{"name": "x-debug", "present": true, "invert": true}
+			]`,
+		},
+		wantHeaders: []*route.HeaderMatcher{{
+			Name: "x-regex",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: &envoymatcherv3.StringMatcher{
+				MatchPattern: &envoymatcherv3.StringMatcher_SafeRegex{SafeRegex: safeRegex("^v[0-9]+$")},
+			}},
+		}, {
+			Name: "x-prefix",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: &envoymatcherv3.StringMatcher{
+				MatchPattern: &envoymatcherv3.StringMatcher_Prefix{Prefix: "foo"},
+			}},
+		}, {
+			Name: "x-suffix",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: &envoymatcherv3.StringMatcher{
+				MatchPattern: &envoymatcherv3.StringMatcher_Suffix{Suffix: "bar"},
+			}},
+		}, {
+			Name:                 "x-debug",
+			InvertMatch:          true,
+			HeaderMatchSpecifier: &route.HeaderMatcher_PresentMatch{PresentMatch: true},
+		}},
+		wantQuery: []*route.QueryParameterMatcher{},
+	}, {
+		name: "query parameter matchers",
+		annotations: map[string]string{
+			pkgconfig.QueryParamMatchAnnotationKey: `[{"name": "version", "exact": "2"}, {"name": "canary", "present": true}]`,
+		},
+		wantHeaders: []*route.HeaderMatcher{},
+		wantQuery: []*route.QueryParameterMatcher{{
+			Name: "version",
+			QueryParameterMatchSpecifier: &route.QueryParameterMatcher_StringMatch{StringMatch: &envoymatcherv3.StringMatcher{
+				MatchPattern: &envoymatcherv3.StringMatcher_Exact{Exact: "2"},
+			}},
+		}, {
+			Name:                         "canary",
+			QueryParameterMatchSpecifier: &route.QueryParameterMatcher_PresentMatch{PresentMatch: true},
+		}},
+	}, {
+		name: "invalid json",
+		annotations: map[string]string{
+			pkgconfig.HeaderMatchAnnotationKey: `{"name": "foo"`,
+		},
+		wantErr: true,
+	}, {
+		name: "missing name",
+		annotations: map[string]string{
+			pkgconfig.HeaderMatchAnnotationKey: `[{"exact": "foo"}]`,
+		},
+		wantErr: true,
+	}, {
+		name: "multiple match types",
+		annotations: map[string]string{
+			pkgconfig.HeaderMatchAnnotationKey: `[{"name": "foo", "exact": "foo", "prefix": "f"}]`,
+		},
+		wantErr: true,
+	}, {
+		name: "invalid regex",
+		annotations: map[string]string{
+			pkgconfig.HeaderMatchAnnotationKey: `[{"name": "foo", "regex": "(foo"}]`,
+		},
+		wantErr: true,
+	}, {
+		name: "inverted query parameter",
+		annotations: map[string]string{
+			pkgconfig.QueryParamMatchAnnotationKey: `[{"name": "foo", "present": true, "invert": true}]`,
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers, query, err := matchersFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, headers, test.wantHeaders, protocmp.Transform())
+			assert.DeepEqual(t, query, test.wantQuery, protocmp.Transform())
+		})
+	}
+}
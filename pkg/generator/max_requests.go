@@ -0,0 +1,40 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// maxRequestsFromAnnotations returns the cap of the concurrent requests in flight to each
+// backend of the Ingress, as specified via annotations on it. Returns 0 if none is
+// specified.
+func maxRequestsFromAnnotations(annotations map[string]string) (uint32, error) {
+	raw := pkgconfig.GetMaxRequests(annotations)
+	if raw == "" {
+		return 0, nil
+	}
+
+	maxRequests, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || maxRequests == 0 {
+		return 0, fmt.Errorf("invalid %s annotation: %q is not a positive number", pkgconfig.MaxRequestsAnnotationKey, raw)
+	}
+	return uint32(maxRequests), nil
+}
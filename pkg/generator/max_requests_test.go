@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestMaxRequestsFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       uint32
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "cap",
+		annotation: "100",
+		want:       100,
+	}, {
+		name:       "zero",
+		annotation: "0",
+		wantErr:    true,
+	}, {
+		name:       "negative",
+		annotation: "-1",
+		wantErr:    true,
+	}, {
+		name:       "not a number",
+		annotation: "many",
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.MaxRequestsAnnotationKey] = test.annotation
+			}
+
+			got, err := maxRequestsFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got, test.want)
+		})
+	}
+}
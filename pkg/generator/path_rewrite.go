@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// regexRewriteSpec is the regex rewrite of the path as specified in the regex rewrite
+// annotation.
+//
+// Example: {"pattern": "^/api/v1/(.*)$", "substitution": "/\\1"}
+type regexRewriteSpec struct {
+	// Pattern is the RE2 regex whose matches in the path are substituted.
+	Pattern string `json:"pattern"`
+	// Substitution replaces the matches, referring to the capture groups as \1, \2 and
+	// so on.
+	Substitution string `json:"substitution"`
+}
+
+func (r regexRewriteSpec) validate() error {
+	if r.Pattern == "" {
+		return errors.New("pattern must be set")
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("pattern is not a valid RE2 regex: %w", err)
+	}
+	return nil
+}
+
+// pathRewrite is how the path of the requests to the backends of an Ingress is
+// rewritten: either its matched prefix is replaced, or the matches of a regex in it.
+type pathRewrite struct {
+	prefix string
+	regex  *regexRewriteSpec
+}
+
+// pathRewriteFromAnnotations returns how the path of the requests to the backends of
+// the Ingress is rewritten, as specified via annotations on it. Returns nil if it isn't
+// specified, which keeps the path as it is.
+func pathRewriteFromAnnotations(annotations map[string]string) (*pathRewrite, error) {
+	prefix := pkgconfig.GetPrefixRewrite(annotations)
+	raw := pkgconfig.GetRegexRewrite(annotations)
+	switch {
+	case prefix == "" && raw == "":
+		return nil, nil
+	case prefix != "" && raw != "":
+		return nil, fmt.Errorf("only one of the %s and %s annotations can be set",
+			pkgconfig.PrefixRewriteAnnotationKey, pkgconfig.RegexRewriteAnnotationKey)
+	case prefix != "":
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid %s annotation: %q doesn't start with \"/\"", pkgconfig.PrefixRewriteAnnotationKey, prefix)
+		}
+		return &pathRewrite{prefix: prefix}, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	var spec regexRewriteSpec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.RegexRewriteAnnotationKey, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.RegexRewriteAnnotationKey, err)
+	}
+	return &pathRewrite{regex: &spec}, nil
+}
+
+// apply rewrites the path of the requests routed by the Route.
+func (p *pathRewrite) apply(r *route.Route) {
+	if p.regex != nil {
+		envoy.SetRegexRewrite(r, p.regex.Pattern, p.regex.Substitution)
+	} else {
+		envoy.SetPrefixRewrite(r, p.prefix)
+	}
+}
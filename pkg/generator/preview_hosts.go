@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// previewHostPrefix prefixes the hosts of a rule to form the preview hosts of its splits.
+const previewHostPrefix = "preview-"
+
+// ruleVirtualHost is a virtual host to create for a rule, serving either the hosts of
+// the rule or the preview hosts of one of its splits.
+type ruleVirtualHost struct {
+	name      string
+	domains   []string
+	routes    []*route.Route
+	tlsRoutes []*route.Route
+}
+
+// previewTags returns the tags of the splits to serve on preview hosts of their own, if
+// requested via annotations on the Ingress. These are the names of the services of the
+// splits of the rule's paths with multiple splits, i.e. of the revisions.
+func previewTags(ingress *v1alpha1.Ingress, rule v1alpha1.IngressRule) []string {
+	if !strings.EqualFold(pkgconfig.GetPreviewHosts(ingress.Annotations), "true") || rule.HTTP == nil {
+		return nil
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, httpPath := range rule.HTTP.Paths {
+		if len(httpPath.Splits) < 2 {
+			continue
+		}
+		for _, split := range httpPath.Splits {
+			if !seen[split.ServiceName] {
+				seen[split.ServiceName] = true
+				tags = append(tags, split.ServiceName)
+			}
+		}
+	}
+	return tags
+}
+
+// previewRule returns the rule serving the preview hosts of the split with the given tag.
+func previewRule(rule v1alpha1.IngressRule, tag string) v1alpha1.IngressRule {
+	preview := rule
+	preview.Hosts = make([]string, 0, len(rule.Hosts))
+	for _, host := range rule.Hosts {
+		preview.Hosts = append(preview.Hosts, previewHostPrefix+tag+"."+host)
+	}
+	return preview
+}
+
+// previewWeightedClusters returns the weighted clusters routing all requests of the path
+// to the split with the given tag. Paths without that split are routed as usual.
+func previewWeightedClusters(wrs []*route.WeightedCluster_ClusterWeight, splits []v1alpha1.IngressBackendSplit, tag string) []*route.WeightedCluster_ClusterWeight {
+	for i, split := range splits {
+		if split.ServiceName == tag && i < len(wrs) {
+			return []*route.WeightedCluster_ClusterWeight{envoy.NewWeightedCluster(wrs[i].Name, 100, split.AppendHeaders)}
+		}
+	}
+	return wrs
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestPreviewTags(t *testing.T) {
+	split := func(name string) v1alpha1.IngressBackendSplit {
+		return v1alpha1.IngressBackendSplit{IngressBackend: v1alpha1.IngressBackend{ServiceName: name}}
+	}
+	rule := v1alpha1.IngressRule{HTTP: &v1alpha1.HTTPIngressRuleValue{Paths: []v1alpha1.HTTPIngressPath{{
+		Splits: []v1alpha1.IngressBackendSplit{split("v1")},
+	}, {
+		Splits: []v1alpha1.IngressBackendSplit{split("v1"), split("v2")},
+	}, {
+		Splits: []v1alpha1.IngressBackendSplit{split("v3"), split("v2")},
+	}}}}
+
+	ingress := &v1alpha1.Ingress{}
+	assert.Assert(t, previewTags(ingress, rule) == nil)
+
+	ingress.Annotations = map[string]string{config.PreviewHostsAnnotationKey: "true"}
+	assert.DeepEqual(t, previewTags(ingress, rule), []string{"v1", "v2", "v3"})
+}
+
+func TestIngressTranslatorPreviewHosts(t *testing.T) {
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.PreviewHostsAnnotationKey: "true"}
+		ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+		ing.Spec.Rules[0].HTTP.Paths[0].RewriteHost = ""
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits = []v1alpha1.IngressBackendSplit{{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "servicename",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: 90,
+		}, {
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "canary",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent:       10,
+			AppendHeaders: map[string]string{"K-Revision": "canary"},
+		}}
+	})
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(
+		svc("servicens", "servicename"), eps("servicens", "servicename"),
+		svc("servicens", "canary"), eps("servicens", "canary"),
+	)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+
+	// The hosts of the rule split the requests as usual, the preview hosts route all of
+	// them to their split.
+	assert.Equal(t, len(got.externalVirtualHosts), 3)
+	assert.Equal(t, len(got.internalVirtualHosts), 3)
+	clusters := func(vhost *route.VirtualHost) []*route.WeightedCluster_ClusterWeight {
+		return vhost.Routes[0].GetRoute().GetWeightedClusters().Clusters
+	}
+
+	assert.DeepEqual(t, got.externalVirtualHosts[0].Domains, []string{"foo.example.com", "foo.example.com:*"})
+	assert.Equal(t, len(clusters(got.externalVirtualHosts[0])), 2)
+
+	assert.Equal(t, got.externalVirtualHosts[1].Name, "(testspace/testname).Rules[0].Preview[servicename]")
+	assert.DeepEqual(t, got.externalVirtualHosts[1].Domains,
+		[]string{"preview-servicename.foo.example.com", "preview-servicename.foo.example.com:*"})
+	assert.DeepEqual(t, clusters(got.externalVirtualHosts[1]), []*route.WeightedCluster_ClusterWeight{
+		envoy.NewWeightedCluster("servicens/servicename", 100, nil),
+	}, protocmp.Transform())
+
+	assert.DeepEqual(t, got.externalVirtualHosts[2].Domains,
+		[]string{"preview-canary.foo.example.com", "preview-canary.foo.example.com:*"})
+	assert.DeepEqual(t, clusters(got.externalVirtualHosts[2]), []*route.WeightedCluster_ClusterWeight{
+		envoy.NewWeightedCluster("servicens/canary", 100, map[string]string{"K-Revision": "canary"}),
+	}, protocmp.Transform())
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// redirectResponseCodes are the response codes of the redirects, by their status code.
+var redirectResponseCodes = map[int]route.RedirectAction_RedirectResponseCode{
+	301: route.RedirectAction_MOVED_PERMANENTLY,
+	302: route.RedirectAction_FOUND,
+	303: route.RedirectAction_SEE_OTHER,
+	307: route.RedirectAction_TEMPORARY_REDIRECT,
+	308: route.RedirectAction_PERMANENT_REDIRECT,
+}
+
+// redirectSpec is the redirect of the requests as specified in the redirect annotation.
+//
+// Example: {"responseCode": 308, "host": "www.example.com", "prefix": "/v2/", "stripQuery": true}
+type redirectSpec struct {
+	// ResponseCode is the status code of the redirects, 301 by default.
+	ResponseCode int `json:"responseCode,omitempty"`
+	// Scheme, Host and Port replace the respective parts of the URL of the requests.
+	Scheme string `json:"scheme,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Port   uint32 `json:"port,omitempty"`
+	// Path replaces the whole path of the requests, and Prefix the prefix matched by the
+	// path of the Ingress.
+	Path   string `json:"path,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// StripQuery removes the query string of the requests.
+	StripQuery bool `json:"stripQuery,omitempty"`
+}
+
+func (r redirectSpec) validate() error {
+	if _, ok := redirectResponseCodes[r.ResponseCode]; r.ResponseCode != 0 && !ok {
+		return fmt.Errorf("responseCode must be one of 301, 302, 303, 307 and 308, got %d", r.ResponseCode)
+	}
+	if r.Scheme != "" && r.Scheme != "http" && r.Scheme != "https" {
+		return fmt.Errorf(`scheme must be "http" or "https", got %q`, r.Scheme)
+	}
+	if r.Host != "" && len(validation.IsDNS1123Subdomain(r.Host)) != 0 {
+		return fmt.Errorf("host %q is not a valid DNS name", r.Host)
+	}
+	if r.Port > 65535 {
+		return fmt.Errorf("port %d is not a valid port", r.Port)
+	}
+	if r.Path != "" && r.Prefix != "" {
+		return errors.New("only one of path and prefix can be set")
+	}
+	if (r.Path != "" && !strings.HasPrefix(r.Path, "/")) || (r.Prefix != "" && !strings.HasPrefix(r.Prefix, "/")) {
+		return errors.New(`path and prefix must start with "/"`)
+	}
+	if r.Scheme == "" && r.Host == "" && r.Port == 0 && r.Path == "" && r.Prefix == "" {
+		return errors.New("at least one of scheme, host, port, path and prefix must be set")
+	}
+	return nil
+}
+
+// redirectFromAnnotations returns how the requests to the hosts of the Ingress are
+// redirected, as specified via annotations on it. Returns nil if they aren't, which
+// routes them to its backends.
+func redirectFromAnnotations(annotations map[string]string) (*envoy.Redirect, error) {
+	raw := pkgconfig.GetRedirect(annotations)
+	if raw == "" {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	var spec redirectSpec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.RedirectAnnotationKey, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.RedirectAnnotationKey, err)
+	}
+
+	return &envoy.Redirect{
+		ResponseCode: redirectResponseCodes[spec.ResponseCode],
+		Scheme:       spec.Scheme,
+		Host:         spec.Host,
+		Port:         spec.Port,
+		Path:         spec.Path,
+		Prefix:       spec.Prefix,
+		StripQuery:   spec.StripQuery,
+	}, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestRedirectFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       *envoy.Redirect
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "host",
+		annotation: `{"host": "www.example.com"}`,
+		want:       &envoy.Redirect{Host: "www.example.com"},
+	}, {
+		name:       "all fields",
+		annotation: `{"responseCode": 308, "scheme": "https", "host": "www.example.com", "port": 8443, "prefix": "/v2/", "stripQuery": true}`,
+		want: &envoy.Redirect{
+			ResponseCode: route.RedirectAction_PERMANENT_REDIRECT,
+			Scheme:       "https",
+			Host:         "www.example.com",
+			Port:         8443,
+			Prefix:       "/v2/",
+			StripQuery:   true,
+		},
+	}, {
+		name:       "path",
+		annotation: `{"responseCode": 302, "path": "/moved"}`,
+		want:       &envoy.Redirect{ResponseCode: route.RedirectAction_FOUND, Path: "/moved"},
+	}, {
+		name:       "invalid response code",
+		annotation: `{"responseCode": 200, "host": "www.example.com"}`,
+		wantErr:    true,
+	}, {
+		name:       "invalid scheme",
+		annotation: `{"scheme": "ftp"}`,
+		wantErr:    true,
+	}, {
+		name:       "invalid host",
+		annotation: `{"host": "www.example.com:8080"}`,
+		wantErr:    true,
+	}, {
+		name:       "invalid port",
+		annotation: `{"port": 70000}`,
+		wantErr:    true,
+	}, {
+		name:       "path and prefix",
+		annotation: `{"path": "/moved", "prefix": "/v2/"}`,
+		wantErr:    true,
+	}, {
+		name:       "relative path",
+		annotation: `{"path": "moved"}`,
+		wantErr:    true,
+	}, {
+		name:       "nothing redirected",
+		annotation: `{"responseCode": 308, "stripQuery": true}`,
+		wantErr:    true,
+	}, {
+		name:       "unknown field",
+		annotation: `{"host": "www.example.com", "code": 308}`,
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.RedirectAnnotationKey] = test.annotation
+			}
+
+			got, err := redirectFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorRedirect(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"), secret)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.RedirectAnnotationKey: `{"responseCode": 308, "host": "www.example.com"}`}
+		ing.Spec.HTTPOption = v1alpha1.HTTPOptionRedirected
+		ing.Spec.TLS = []v1alpha1.IngressTLS{{
+			Hosts:           []string{"foo.example.com"},
+			SecretNamespace: "secretns",
+			SecretName:      "secretname",
+		}}
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.externalVirtualHosts), 1)
+	assert.Equal(t, len(got.externalTLSVirtualHosts), 1)
+
+	// The plain HTTP requests are redirected to HTTPS as well.
+	redirect := got.externalVirtualHosts[0].Routes[0].GetRedirect()
+	assert.Equal(t, redirect.HostRedirect, "www.example.com")
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_PERMANENT_REDIRECT)
+	assert.Equal(t, redirect.GetSchemeRedirect(), "https")
+
+	redirect = got.externalTLSVirtualHosts[0].Routes[0].GetRedirect()
+	assert.Equal(t, redirect.HostRedirect, "www.example.com")
+	assert.Equal(t, redirect.ResponseCode, route.RedirectAction_PERMANENT_REDIRECT)
+	assert.Assert(t, redirect.SchemeRewriteSpecifier == nil)
+}
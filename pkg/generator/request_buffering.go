@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// requestBufferingOff streams the bodies of the requests to an Ingress without buffering.
+const requestBufferingOff = "off"
+
+// requestBuffering is the buffering of the bodies of the requests to an Ingress,
+// overriding the configured one.
+type requestBuffering struct {
+	// maxRequestBytes is the maximum size of the bodies, or 0 to stream them without
+	// buffering.
+	maxRequestBytes uint32
+}
+
+// requestBufferingFromAnnotations returns the buffering of the bodies of the requests to
+// the Ingress, as specified via annotations on it. Returns nil if none is specified, which
+// keeps the configured buffering.
+func requestBufferingFromAnnotations(annotations map[string]string) (*requestBuffering, error) {
+	raw := pkgconfig.GetMaxRequestBodyBytes(annotations)
+	if raw == "" {
+		return nil, nil
+	}
+	if raw == requestBufferingOff {
+		return &requestBuffering{}, nil
+	}
+
+	maxRequestBytes, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || maxRequestBytes == 0 {
+		return nil, fmt.Errorf("invalid %s annotation: %q is neither a positive number nor %q",
+			pkgconfig.MaxRequestBodyBytesAnnotationKey, raw, requestBufferingOff)
+	}
+	return &requestBuffering{maxRequestBytes: uint32(maxRequestBytes)}, nil
+}
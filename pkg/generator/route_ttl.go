@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// routeTTLFromAnnotations returns the TTL of the routes of the Ingress, as specified via
+// annotations on it, or 0 if there's none.
+func routeTTLFromAnnotations(annotations map[string]string) (time.Duration, error) {
+	rawTTL := pkgconfig.GetRouteTTL(annotations)
+	if rawTTL == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(rawTTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation: %w", pkgconfig.RouteTTLAnnotationKey, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid %s annotation: must be positive, was: %v", pkgconfig.RouteTTLAnnotationKey, ttl)
+	}
+	return ttl, nil
+}
+
+// hostTTLs returns the TTLs of the external hosts served by the shared listeners of the
+// given gateway fleet, keyed by host. Hosts also served by an ingress without a TTL
+// have none, hosts served by several ingresses with a TTL have the longest one.
+func (caches *Caches) hostTTLs(fleet string) map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	permanent := make(map[string]bool)
+	for _, translated := range caches.translatedIngresses {
+		if translated.gatewayFleet != fleet || translated.listenerPool != "" {
+			continue
+		}
+
+		vhosts := append(append([]*route.VirtualHost{}, translated.externalVirtualHosts...), translated.externalTLSVirtualHosts...)
+		for _, vhost := range vhosts {
+			for _, host := range baseHosts(vhost.Domains).UnsortedList() {
+				if translated.routeTTL == 0 {
+					permanent[host] = true
+				} else if translated.routeTTL > ttls[host] {
+					ttls[host] = translated.routeTTL
+				}
+			}
+		}
+	}
+
+	for host := range permanent {
+		delete(ttls, host)
+	}
+	return ttls
+}
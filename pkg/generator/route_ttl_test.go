@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	netconfig "knative.dev/networking/pkg/config"
+)
+
+func TestRouteTTLFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        time.Duration
+		wantErr     bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "TTL",
+		annotations: map[string]string{pkgconfig.RouteTTLAnnotationKey: "10m"},
+		want:        10 * time.Minute,
+	}, {
+		name:        "invalid TTL",
+		annotations: map[string]string{pkgconfig.RouteTTLAnnotationKey: "soon"},
+		wantErr:     true,
+	}, {
+		name:        "zero TTL",
+		annotations: map[string]string{pkgconfig.RouteTTLAnnotationKey: "0s"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := routeTTLFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.ErrorContains(t, err, pkgconfig.RouteTTLAnnotationKey)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got, test.want)
+		})
+	}
+}
+
+func TestSnapshotWithRouteTTL(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &pkgconfig.Kourier{VirtualHostDiscovery: true},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	translated := func(name string, ttl time.Duration, hosts ...string) *translatedIngress {
+		vhost := &route.VirtualHost{Name: name, Domains: hosts}
+		return &translatedIngress{
+			name:                 types.NamespacedName{Namespace: "ns", Name: name},
+			externalVirtualHosts: []*route.VirtualHost{vhost},
+			routeTTL:             ttl,
+		}
+	}
+	assert.NilError(t, caches.addTranslatedIngress(translated("acme", time.Hour, "acme.example.com", "shared.example.com"), false))
+	assert.NilError(t, caches.addTranslatedIngress(translated("maintenance", 2*time.Hour, "shared.example.com"), true))
+	assert.NilError(t, caches.addTranslatedIngress(translated("permanent", 0, "permanent.example.com"), false))
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	vhosts := snapshot.GetResourcesAndTTL(resource.VirtualHostType)
+	assert.Equal(t, len(vhosts), 3)
+	assert.Equal(t, *vhosts[externalRouteConfigName+"/acme.example.com"].TTL, time.Hour)
+	// Hosts served by several ingresses with a TTL are kept for the longest one.
+	assert.Equal(t, *vhosts[externalRouteConfigName+"/shared.example.com"].TTL, 2*time.Hour)
+	assert.Assert(t, vhosts[externalRouteConfigName+"/permanent.example.com"].TTL == nil)
+
+	// Hosts also served by an ingress without a TTL don't expire.
+	assert.NilError(t, caches.addTranslatedIngress(translated("shared", 0, "shared.example.com"), true))
+	snapshot, err = caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, snapshot.GetResourcesAndTTL(resource.VirtualHostType)[externalRouteConfigName+"/shared.example.com"].TTL == nil)
+}
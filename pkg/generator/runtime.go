@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strconv"
+
+	"knative.dev/net-kourier/pkg/config"
+)
+
+// globalDownstreamMaxConnectionsKey is the runtime key of the maximum number of
+// downstream connections of a gateway, across all of its listeners.
+const globalDownstreamMaxConnectionsKey = "overload.global_downstream_max_connections"
+
+// runtimeValues returns the values of the runtime layer served to the gateways, i.e.
+// the configured overrides along with the runtime keys backing other config keys. The
+// overrides take precedence.
+func runtimeValues(kourierConfig *config.Kourier) map[string]string {
+	values := make(map[string]string, len(kourierConfig.RuntimeOverrides)+1)
+	if kourierConfig.OverloadMaxDownstreamConnections != 0 {
+		values[globalDownstreamMaxConnectionsKey] = strconv.FormatUint(uint64(kourierConfig.OverloadMaxDownstreamConnections), 10)
+	}
+	for key, value := range kourierConfig.RuntimeOverrides {
+		values[key] = value
+	}
+	return values
+}
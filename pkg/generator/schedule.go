@@ -0,0 +1,332 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/http/header"
+)
+
+// day is the duration of a day, at which the schedule windows recur.
+const day = 24 * time.Hour
+
+// weekdays are the names of the days in the schedule windows, indexed by time.Weekday.
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// schedule is the backends the requests to an Ingress are routed to within recurring
+// windows of time, replacing the backends of all of its paths.
+type schedule struct {
+	windows  []scheduleWindow
+	backends []v1alpha1.IngressBackendSplit
+}
+
+// scheduleWindow is a window of time recurring on the given days of the week, in UTC.
+type scheduleWindow struct {
+	days [7]bool
+	// start and end are the offsets of the window from midnight. The window ends on the
+	// next day if end isn't after start.
+	start time.Duration
+	end   time.Duration
+}
+
+// scheduleFromAnnotations returns the schedule of the Ingress, as specified via
+// annotations on it. Returns nil if none is specified.
+func scheduleFromAnnotations(namespace string, annotations map[string]string) (*schedule, error) {
+	rawWindows := pkgconfig.GetScheduleWindows(annotations)
+	rawBackends := pkgconfig.GetScheduleBackends(annotations)
+	if rawWindows == "" && rawBackends == "" {
+		return nil, nil
+	}
+	if rawWindows == "" || rawBackends == "" {
+		return nil, fmt.Errorf("the %s and %s annotations must be specified together",
+			pkgconfig.ScheduleWindowsAnnotationKey, pkgconfig.ScheduleBackendsAnnotationKey)
+	}
+
+	s := &schedule{}
+	for _, rawWindow := range strings.Split(rawWindows, ";") {
+		window, err := parseScheduleWindow(strings.TrimSpace(rawWindow))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.ScheduleWindowsAnnotationKey, err)
+		}
+		s.windows = append(s.windows, window)
+	}
+
+	backends, err := parseScheduleBackends(namespace, rawBackends)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.ScheduleBackendsAnnotationKey, err)
+	}
+	s.backends = backends
+	return s, nil
+}
+
+// parseScheduleWindow parses a window like "Mon-Fri 22:00-06:00". The days are either
+// "*", or a comma separated list of days and ranges of days.
+func parseScheduleWindow(raw string) (scheduleWindow, error) {
+	window := scheduleWindow{}
+	fields := strings.Fields(raw)
+	if len(fields) != 2 {
+		return window, fmt.Errorf("%q is not of the form \"<days> <HH:MM>-<HH:MM>\"", raw)
+	}
+
+	if fields[0] == "*" {
+		for i := range window.days {
+			window.days[i] = true
+		}
+	} else {
+		for _, days := range strings.Split(fields[0], ",") {
+			first, last, isRange := strings.Cut(days, "-")
+			from, err := parseWeekday(first)
+			if err != nil {
+				return window, err
+			}
+			to := from
+			if isRange {
+				if to, err = parseWeekday(last); err != nil {
+					return window, err
+				}
+			}
+			for d := from; ; d = (d + 1) % 7 {
+				window.days[d] = true
+				if d == to {
+					break
+				}
+			}
+		}
+	}
+
+	rawStart, rawEnd, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return window, fmt.Errorf("%q is not a range of times", fields[1])
+	}
+	var err error
+	if window.start, err = parseTimeOfDay(rawStart); err != nil {
+		return window, err
+	}
+	if window.end, err = parseTimeOfDay(rawEnd); err != nil {
+		return window, err
+	}
+	if window.start == day {
+		return window, fmt.Errorf("%q must not start at 24:00", raw)
+	}
+	if window.start == window.end {
+		return window, fmt.Errorf("%q must not be empty", raw)
+	}
+	return window, nil
+}
+
+// parseWeekday parses the abbreviated name of a day, like "Mon".
+func parseWeekday(raw string) (time.Weekday, error) {
+	for d, name := range weekdays {
+		if strings.EqualFold(raw, name) {
+			return time.Weekday(d), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a day, like \"Mon\"", raw)
+}
+
+// parseTimeOfDay parses a time of day like "22:00" into its offset from midnight. "24:00"
+// is the end of the day.
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	rawHours, rawMinutes, ok := strings.Cut(raw, ":")
+	hours, hoursErr := strconv.Atoi(rawHours)
+	minutes, minutesErr := strconv.Atoi(rawMinutes)
+	if !ok || hoursErr != nil || minutesErr != nil || len(rawMinutes) != 2 ||
+		hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("%q is not a time of day, like \"22:00\"", raw)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// parseScheduleBackends parses a comma separated list of services of the given namespace,
+// with their port and, if there are several, the percentage of the requests routed to
+// them, like "blue:80=90,green:http=10".
+func parseScheduleBackends(namespace, raw string) ([]v1alpha1.IngressBackendSplit, error) {
+	rawBackends := strings.Split(raw, ",")
+	backends := make([]v1alpha1.IngressBackendSplit, 0, len(rawBackends))
+	total := 0
+	for _, rawBackend := range rawBackends {
+		rawBackend = strings.TrimSpace(rawBackend)
+		service, rawPercent, weighted := strings.Cut(rawBackend, "=")
+		name, port, ok := strings.Cut(service, ":")
+		if !ok || name == "" || port == "" {
+			return nil, fmt.Errorf("%q is not of the form \"<service>:<port>[=<percent>]\"", rawBackend)
+		}
+
+		percent := 100
+		if weighted {
+			var err error
+			if percent, err = strconv.Atoi(rawPercent); err != nil || percent < 0 || percent > 100 {
+				return nil, fmt.Errorf("%q is not a percentage", rawPercent)
+			}
+		} else if len(rawBackends) > 1 {
+			return nil, fmt.Errorf("%q must specify its percentage among several backends", rawBackend)
+		}
+		total += percent
+
+		backends = append(backends, v1alpha1.IngressBackendSplit{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: namespace,
+				ServiceName:      name,
+				ServicePort:      intstr.Parse(port),
+			},
+			Percent: percent,
+		})
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("the percentages must add up to 100, was: %d", total)
+	}
+	return backends, nil
+}
+
+// active returns whether the given time is within any of the windows of the schedule.
+func (s *schedule) active(now time.Time) bool {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, window := range s.windows {
+		// The window might have started on the day before.
+		for _, start := range []time.Time{midnight.AddDate(0, 0, -1), midnight} {
+			if !window.days[start.Weekday()] {
+				continue
+			}
+			from, to := window.bounds(start)
+			if !now.Before(from) && now.Before(to) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextChange returns the first start or end of any window after the given time, at which
+// the schedule might become active or inactive.
+func (s *schedule) nextChange(now time.Time) time.Time {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var next time.Time
+	for _, window := range s.windows {
+		// Every window recurs within a week, starting with the one which might have
+		// started on the day before.
+		for i := -1; i <= 7; i++ {
+			start := midnight.AddDate(0, 0, i)
+			if !window.days[start.Weekday()] {
+				continue
+			}
+			from, to := window.bounds(start)
+			for _, bound := range []time.Time{from, to} {
+				if bound.After(now) && (next.IsZero() || bound.Before(next)) {
+					next = bound
+				}
+			}
+		}
+	}
+	return next
+}
+
+// bounds returns the start and the end of the window on the day starting at the given
+// midnight.
+func (window scheduleWindow) bounds(midnight time.Time) (time.Time, time.Time) {
+	end := window.end
+	if end <= window.start {
+		end += day
+	}
+	return midnight.Add(window.start), midnight.Add(end)
+}
+
+// apply returns a copy of the Ingress routing the requests of all of its paths to the
+// backends of the schedule. The paths keep their host rewrite and the headers appended
+// by all of their backends, e.g. the namespace and revision headers of Knative. The
+// paths of the probes keep their backends, so that the Ingress can still be probed.
+func (s *schedule) apply(ingress *v1alpha1.Ingress) *v1alpha1.Ingress {
+	ingress = ingress.DeepCopy()
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			httpPath := &rule.HTTP.Paths[i]
+			if _, probe := httpPath.Headers[header.HashKey]; probe {
+				continue
+			}
+			appendHeaders := commonAppendHeaders(httpPath.Splits)
+			httpPath.Splits = make([]v1alpha1.IngressBackendSplit, len(s.backends))
+			for j, backend := range s.backends {
+				backend.AppendHeaders = appendHeaders
+				httpPath.Splits[j] = backend
+			}
+		}
+	}
+	return ingress
+}
+
+// commonAppendHeaders returns the headers appended with the same value by all of the
+// given splits, or nil if there are none.
+func commonAppendHeaders(splits []v1alpha1.IngressBackendSplit) map[string]string {
+	if len(splits) == 0 {
+		return nil
+	}
+	var common map[string]string
+	for name, value := range splits[0].AppendHeaders {
+		shared := true
+		for _, split := range splits[1:] {
+			if other, ok := split.AppendHeaders[name]; !ok || other != value {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			if common == nil {
+				common = make(map[string]string, len(splits[0].AppendHeaders))
+			}
+			common[name] = value
+		}
+	}
+	return common
+}
+
+// scheduledIngress returns the Ingress as it's translated at the given time: with the
+// backends of its schedule if it's within any of its windows, or as it is otherwise.
+func scheduledIngress(ingress *v1alpha1.Ingress, now time.Time) (*v1alpha1.Ingress, error) {
+	s, err := scheduleFromAnnotations(ingress.Namespace, ingress.Annotations)
+	if err != nil || s == nil || !s.active(now) {
+		return ingress, err
+	}
+	return s.apply(ingress), nil
+}
+
+// isScheduled returns whether the Ingress is within any of the windows of its schedule
+// at the given time.
+func isScheduled(ingress *v1alpha1.Ingress, now time.Time) bool {
+	s, err := scheduleFromAnnotations(ingress.Namespace, ingress.Annotations)
+	return err == nil && s != nil && s.active(now)
+}
+
+// NextScheduleChange returns the time after the given one at which the routing of the
+// Ingress changes according to its schedule, if it has one.
+func NextScheduleChange(ingress *v1alpha1.Ingress, now time.Time) (time.Time, bool) {
+	s, err := scheduleFromAnnotations(ingress.Namespace, ingress.Annotations)
+	if err != nil || s == nil {
+		return time.Time{}, false
+	}
+	return s.nextChange(now), true
+}
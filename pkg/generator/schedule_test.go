@@ -0,0 +1,347 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/http/header"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+// saturday is a Saturday at midnight.
+var saturday = time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC)
+
+func TestScheduleFromAnnotations(t *testing.T) {
+	allDays := [7]bool{true, true, true, true, true, true, true}
+	weekend := [7]bool{time.Saturday: true, time.Sunday: true}
+	maintenance := []v1alpha1.IngressBackendSplit{{
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceNamespace: "ns",
+			ServiceName:      "maintenance",
+			ServicePort:      intstr.FromInt(80),
+		},
+		Percent: 100,
+	}}
+
+	tests := []struct {
+		name     string
+		windows  string
+		backends string
+		want     *schedule
+		wantErr  bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:     "every day",
+		windows:  "* 02:00-04:00",
+		backends: "maintenance:80",
+		want: &schedule{
+			windows:  []scheduleWindow{{days: allDays, start: 2 * time.Hour, end: 4 * time.Hour}},
+			backends: maintenance,
+		},
+	}, {
+		name:     "several windows",
+		windows:  "Sat,sun 00:00-24:00; Fri-Mon 22:30-06:00",
+		backends: "maintenance:80",
+		want: &schedule{
+			windows: []scheduleWindow{
+				{days: weekend, start: 0, end: 24 * time.Hour},
+				{days: [7]bool{time.Friday: true, time.Saturday: true, time.Sunday: true, time.Monday: true}, start: 22*time.Hour + 30*time.Minute, end: 6 * time.Hour},
+			},
+			backends: maintenance,
+		},
+	}, {
+		name:     "weighted backends",
+		windows:  "Sat 02:00-04:00",
+		backends: "blue:80=90, green:http=10",
+		want: &schedule{
+			windows: []scheduleWindow{{days: [7]bool{time.Saturday: true}, start: 2 * time.Hour, end: 4 * time.Hour}},
+			backends: []v1alpha1.IngressBackendSplit{{
+				IngressBackend: v1alpha1.IngressBackend{ServiceNamespace: "ns", ServiceName: "blue", ServicePort: intstr.FromInt(80)},
+				Percent:        90,
+			}, {
+				IngressBackend: v1alpha1.IngressBackend{ServiceNamespace: "ns", ServiceName: "green", ServicePort: intstr.FromString("http")},
+				Percent:        10,
+			}},
+		},
+	}, {
+		name:    "windows without backends",
+		windows: "* 02:00-04:00",
+		wantErr: true,
+	}, {
+		name:     "backends without windows",
+		backends: "maintenance:80",
+		wantErr:  true,
+	}, {
+		name:     "invalid day",
+		windows:  "Someday 02:00-04:00",
+		backends: "maintenance:80",
+		wantErr:  true,
+	}, {
+		name:     "invalid time",
+		windows:  "* 2am-4am",
+		backends: "maintenance:80",
+		wantErr:  true,
+	}, {
+		name:     "empty window",
+		windows:  "* 02:00-02:00",
+		backends: "maintenance:80",
+		wantErr:  true,
+	}, {
+		name:     "window without days",
+		windows:  "02:00-04:00",
+		backends: "maintenance:80",
+		wantErr:  true,
+	}, {
+		name:     "backend without port",
+		windows:  "* 02:00-04:00",
+		backends: "maintenance",
+		wantErr:  true,
+	}, {
+		name:     "backends without percentages",
+		windows:  "* 02:00-04:00",
+		backends: "blue:80,green:80",
+		wantErr:  true,
+	}, {
+		name:     "percentages not adding up to 100",
+		windows:  "* 02:00-04:00",
+		backends: "blue:80=50,green:80=40",
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.windows != "" {
+				annotations[pkgconfig.ScheduleWindowsAnnotationKey] = test.windows
+			}
+			if test.backends != "" {
+				annotations[pkgconfig.ScheduleBackendsAnnotationKey] = test.backends
+			}
+
+			got, err := scheduleFromAnnotations("ns", annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(schedule{}, scheduleWindow{}))
+		})
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	s, err := scheduleFromAnnotations("ns", map[string]string{
+		pkgconfig.ScheduleWindowsAnnotationKey:  "Fri,Sat 22:00-02:00; Mon 10:00-24:00",
+		pkgconfig.ScheduleBackendsAnnotationKey: "maintenance:80",
+	})
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		want       bool
+		wantChange time.Time
+	}{{
+		name:       "before the window",
+		now:        saturday.Add(21 * time.Hour),
+		wantChange: saturday.Add(22 * time.Hour),
+	}, {
+		name:       "at the start of the window",
+		now:        saturday.Add(22 * time.Hour),
+		want:       true,
+		wantChange: saturday.Add(26 * time.Hour),
+	}, {
+		name:       "within the window started on the day before",
+		now:        saturday.Add(time.Hour),
+		want:       true,
+		wantChange: saturday.Add(2 * time.Hour),
+	}, {
+		name:       "at the end of the window",
+		now:        saturday.Add(2 * time.Hour),
+		wantChange: saturday.Add(22 * time.Hour),
+	}, {
+		name:       "after the window on Sunday",
+		now:        saturday.Add(26 * time.Hour),
+		wantChange: saturday.Add(2*day + 10*time.Hour),
+	}, {
+		name:       "within the window ending at midnight",
+		now:        saturday.Add(2*day + 23*time.Hour),
+		want:       true,
+		wantChange: saturday.Add(3 * day),
+	}, {
+		name:       "in another time zone",
+		now:        saturday.Add(22 * time.Hour).In(time.FixedZone("UTC+2", 2*60*60)),
+		want:       true,
+		wantChange: saturday.Add(26 * time.Hour),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, s.active(test.now), test.want)
+			assert.Assert(t, s.nextChange(test.now).Equal(test.wantChange), "got %v", s.nextChange(test.now))
+		})
+	}
+}
+
+func TestScheduleApply(t *testing.T) {
+	s := &schedule{
+		backends: []v1alpha1.IngressBackendSplit{{
+			IngressBackend: v1alpha1.IngressBackend{ServiceNamespace: "ns", ServiceName: "maintenance", ServicePort: intstr.FromInt(80)},
+			Percent:        100,
+		}},
+	}
+	in := ing("ns", "name", func(ing *v1alpha1.Ingress) {
+		httpPath := &ing.Spec.Rules[0].HTTP.Paths[0]
+		httpPath.RewriteHost = "rewritten.example.com"
+		httpPath.AppendHeaders = map[string]string{"path": "header"}
+		httpPath.Splits[0].Percent = 60
+		httpPath.Splits[0].AppendHeaders = map[string]string{
+			"Knative-Serving-Namespace": "ns",
+			"Knative-Serving-Revision":  "rev-1",
+		}
+		httpPath.Splits = append(httpPath.Splits, v1alpha1.IngressBackendSplit{
+			IngressBackend: httpPath.Splits[0].IngressBackend,
+			Percent:        40,
+			AppendHeaders: map[string]string{
+				"Knative-Serving-Namespace": "ns",
+				"Knative-Serving-Revision":  "rev-2",
+			},
+		})
+
+		probe := ing.Spec.Rules[0].HTTP.Paths[0].DeepCopy()
+		probe.Headers = map[string]v1alpha1.HeaderMatch{header.HashKey: {Exact: header.HashValueOverride}}
+		ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, *probe)
+	})
+
+	got := s.apply(in)
+	assert.DeepEqual(t, got.Spec.Rules[0].HTTP.Paths[0].Splits, []v1alpha1.IngressBackendSplit{{
+		IngressBackend: s.backends[0].IngressBackend,
+		Percent:        100,
+		// Only the headers appended by all of the backends are kept.
+		AppendHeaders: map[string]string{"Knative-Serving-Namespace": "ns"},
+	}})
+	assert.Equal(t, got.Spec.Rules[0].HTTP.Paths[0].RewriteHost, "rewritten.example.com")
+	assert.DeepEqual(t, got.Spec.Rules[0].HTTP.Paths[0].AppendHeaders, map[string]string{"path": "header"})
+	assert.Equal(t, got.Spec.Rules[0].HTTP.Paths[0].Path, "/test")
+
+	// The probes keep their backends.
+	assert.DeepEqual(t, got.Spec.Rules[0].HTTP.Paths[1], in.Spec.Rules[0].HTTP.Paths[1])
+
+	// The Ingress itself is unchanged.
+	assert.Equal(t, in.Spec.Rules[0].HTTP.Paths[0].Splits[0].ServiceName, "servicename")
+	assert.Assert(t, s.backends[0].AppendHeaders == nil)
+}
+
+func TestScheduleApplyKeepsAppendHeaders(t *testing.T) {
+	s := &schedule{
+		backends: []v1alpha1.IngressBackendSplit{{
+			IngressBackend: v1alpha1.IngressBackend{ServiceNamespace: "ns", ServiceName: "blue", ServicePort: intstr.FromInt(80)},
+			Percent:        90,
+		}, {
+			IngressBackend: v1alpha1.IngressBackend{ServiceNamespace: "ns", ServiceName: "green", ServicePort: intstr.FromInt(80)},
+			Percent:        10,
+		}},
+	}
+	headers := map[string]string{
+		"Knative-Serving-Namespace": "ns",
+		"Knative-Serving-Revision":  "rev-1",
+	}
+	in := ing("ns", "name", func(ing *v1alpha1.Ingress) {
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits[0].AppendHeaders = headers
+	})
+
+	got := s.apply(in)
+	for _, split := range got.Spec.Rules[0].HTTP.Paths[0].Splits {
+		assert.DeepEqual(t, split.AppendHeaders, headers)
+	}
+}
+
+func TestNextScheduleChange(t *testing.T) {
+	_, ok := NextScheduleChange(ing("ns", "name"), saturday)
+	assert.Assert(t, !ok)
+
+	next, ok := NextScheduleChange(ing("ns", "name", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{
+			pkgconfig.ScheduleWindowsAnnotationKey:  "* 02:00-04:00",
+			pkgconfig.ScheduleBackendsAnnotationKey: "maintenance:80",
+		}
+	}), saturday)
+	assert.Assert(t, ok)
+	assert.Assert(t, next.Equal(saturday.Add(2*time.Hour)))
+}
+
+func TestIngressTranslatorSchedule(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(
+		ns("testspace"),
+		svc("servicens", "servicename"), eps("servicens", "servicename"),
+		svc("testspace", "maintenance"), eps("testspace", "maintenance"),
+	)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Generation = 1
+		ing.Annotations = map[string]string{
+			pkgconfig.ScheduleWindowsAnnotationKey:  "Sat 02:00-04:00",
+			pkgconfig.ScheduleBackendsAnnotationKey: "maintenance:http",
+		}
+	})
+
+	// The translations are reused within and outside of the windows only.
+	for _, test := range []struct {
+		now         time.Time
+		wantCluster string
+	}{
+		{now: saturday.Add(time.Hour), wantCluster: "servicens/servicename"},
+		{now: saturday.Add(3 * time.Hour), wantCluster: "testspace/maintenance"},
+		{now: saturday.Add(3*time.Hour + time.Minute), wantCluster: "testspace/maintenance"},
+		{now: saturday.Add(5 * time.Hour), wantCluster: "servicens/servicename"},
+	} {
+		translator.now = func() time.Time { return test.now }
+		got, err := translator.translate(ctx, in, false)
+		assert.NilError(t, err)
+		assert.Equal(t, len(got.clusters), 1)
+		assert.Equal(t, got.clusters[0].Name, test.wantCluster)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// ErrSecretDecryption is returned when the private key of a TLS secret wrapped by an
+// external KMS can't be unwrapped.
+var ErrSecretDecryption = errors.New("failed to decrypt secret")
+
+// SecretDecrypter unwraps the private keys of the TLS secrets wrapped by the KMS of a
+// provider, so they don't have to be stored in plaintext.
+type SecretDecrypter interface {
+	// Decrypt returns the plaintext of the given ciphertext, wrapped by the given key of
+	// the KMS.
+	Decrypt(ctx context.Context, key string, ciphertext []byte) ([]byte, error)
+}
+
+var (
+	secretDecryptersMu sync.RWMutex
+	secretDecrypters   = make(map[string]SecretDecrypter)
+)
+
+// RegisterSecretDecrypter registers the decrypter of the given KMS provider, unwrapping
+// the private keys of the TLS secrets annotated with it. It's meant to be called on
+// startup, e.g. from the init function of the provider's package.
+func RegisterSecretDecrypter(provider string, decrypter SecretDecrypter) {
+	secretDecryptersMu.Lock()
+	defer secretDecryptersMu.Unlock()
+	secretDecrypters[provider] = decrypter
+}
+
+// tlsSecretData returns the certificate chain and the private key of the given TLS
+// secret. The private key is unwrapped by the decrypter of the KMS provider of the
+// secret's KMSProviderAnnotationKey annotation, if any.
+func tlsSecretData(ctx context.Context, secret *corev1.Secret) (certificateChain []byte, privateKey []byte, err error) {
+	certificateChain = secret.Data[certFieldInSecret]
+	privateKey = secret.Data[keyFieldInSecret]
+
+	provider := secret.Annotations[pkgconfig.KMSProviderAnnotationKey]
+	if provider == "" {
+		return certificateChain, privateKey, nil
+	}
+
+	secretDecryptersMu.RLock()
+	decrypter, ok := secretDecrypters[provider]
+	secretDecryptersMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w '%s/%s': no decrypter is registered for KMS provider %q",
+			ErrSecretDecryption, secret.Namespace, secret.Name, provider)
+	}
+
+	privateKey, err = decrypter.Decrypt(ctx, secret.Annotations[pkgconfig.KMSKeyAnnotationKey], privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w '%s/%s' via KMS provider %q: %v",
+			ErrSecretDecryption, secret.Namespace, secret.Name, provider, err)
+	}
+	return certificateChain, privateKey, nil
+}
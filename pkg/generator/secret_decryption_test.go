@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+// reversingDecrypter "decrypts" by reversing the ciphertext, failing for any other key
+// than its own.
+type reversingDecrypter struct {
+	key string
+}
+
+func (d reversingDecrypter) Decrypt(_ context.Context, key string, ciphertext []byte) ([]byte, error) {
+	if key != d.key {
+		return nil, errors.New("unknown key")
+	}
+	plaintext := append([]byte(nil), ciphertext...)
+	for i, j := 0, len(plaintext)-1; i < j; i, j = i+1, j-1 {
+		plaintext[i], plaintext[j] = plaintext[j], plaintext[i]
+	}
+	return plaintext, nil
+}
+
+func TestTLSSecretData(t *testing.T) {
+	RegisterSecretDecrypter("test-kms", reversingDecrypter{key: "projects/test/keys/tls"})
+
+	wrapped := func(provider, key string) *corev1.Secret {
+		s := secret.DeepCopy()
+		s.Annotations = map[string]string{
+			pkgconfig.KMSProviderAnnotationKey: provider,
+			pkgconfig.KMSKeyAnnotationKey:      key,
+		}
+		s.Data["tls.key"] = []byte("yek")
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		secret  *corev1.Secret
+		wantErr bool
+	}{{
+		name:   "plaintext",
+		secret: secret,
+	}, {
+		name:   "wrapped",
+		secret: wrapped("test-kms", "projects/test/keys/tls"),
+	}, {
+		name:    "unknown provider",
+		secret:  wrapped("other-kms", "projects/test/keys/tls"),
+		wantErr: true,
+	}, {
+		name:    "failed decryption",
+		secret:  wrapped("test-kms", "projects/test/keys/other"),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			certificateChain, key, err := tlsSecretData(context.Background(), test.secret)
+			if test.wantErr {
+				assert.Assert(t, errors.Is(err, ErrSecretDecryption), "got error %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, certificateChain, cert)
+			assert.DeepEqual(t, key, privateKey)
+		})
+	}
+}
+
+func TestIngressTranslatorWrappedTLSSecret(t *testing.T) {
+	RegisterSecretDecrypter("test-kms", reversingDecrypter{key: "projects/test/keys/tls"})
+
+	wrapped := secret.DeepCopy()
+	wrapped.Annotations = map[string]string{
+		pkgconfig.KMSProviderAnnotationKey: "test-kms",
+		pkgconfig.KMSKeyAnnotationKey:      "projects/test/keys/tls",
+	}
+	wrapped.Data["tls.key"] = []byte("yek")
+
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"), wrapped)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Spec.TLS = []v1alpha1.IngressTLS{{
+			Hosts:           []string{"foo.example.com"},
+			SecretNamespace: "secretns",
+			SecretName:      "secretname",
+		}}
+	}), false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got.sniMatches), 1)
+	assert.DeepEqual(t, got.sniMatches[0].CertificateChain, cert)
+	assert.DeepEqual(t, got.sniMatches[0].PrivateKey, privateKey)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+// newSnapshot creates a snapshot of the given resources, versioned by their content per
+// type. Resource types that didn't change keep their version and are thus not pushed to
+// the gateways again. With incremental (delta) xDS, only the changed resources are pushed.
+func newSnapshot(resources map[resource.Type][]cachetypes.Resource) (*cache.Snapshot, error) {
+	return newSnapshotWithTTLs(resources, nil)
+}
+
+// newSnapshotWithTTLs creates a snapshot like newSnapshot, whose resources with a name
+// in the given TTLs are dropped by the gateways after their TTL, unless they're pushed
+// again before.
+func newSnapshotWithTTLs(resources map[resource.Type][]cachetypes.Resource, ttls map[string]time.Duration) (*cache.Snapshot, error) {
+	snapshot := &cache.Snapshot{}
+	for typ, items := range resources {
+		index := cache.GetResponseType(typ)
+		if index == cachetypes.UnknownType {
+			return nil, fmt.Errorf("unknown resource type: %s", typ)
+		}
+
+		itemsWithTTL := make([]cachetypes.ResourceWithTTL, 0, len(items))
+		for _, item := range items {
+			itemWithTTL := cachetypes.ResourceWithTTL{Resource: item}
+			if ttl, ok := ttls[cache.GetResourceName(item)]; ok {
+				itemWithTTL.TTL = &ttl
+			}
+			itemsWithTTL = append(itemsWithTTL, itemWithTTL)
+		}
+
+		version, err := contentVersion(itemsWithTTL)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Resources[index] = cache.NewResourcesWithTTL(version, itemsWithTTL)
+	}
+	return snapshot, nil
+}
+
+// contentVersion returns a version of the given resources that only changes if their
+// content or TTL changes, regardless of their order.
+func contentVersion(items []cachetypes.ResourceWithTTL) (string, error) {
+	hashes := make(map[string]string, len(items))
+	for _, item := range items {
+		marshaled, err := cache.MarshalResource(item.Resource)
+		if err != nil {
+			return "", err
+		}
+		hash := cache.HashResource(marshaled)
+		if item.TTL != nil {
+			hash += "/" + item.TTL.String()
+		}
+		hashes[cache.GetResourceName(item.Resource)] = hash
+	}
+
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var content bytes.Buffer
+	for _, name := range names {
+		content.WriteString(name)
+		content.WriteByte(0)
+		content.WriteString(hashes[name])
+		content.WriteByte(0)
+	}
+	return cache.HashResource(content.Bytes()), nil
+}
+
+// SnapshotVersion returns the version of the given snapshot, which changes whenever the
+// version of any of its resource types does.
+func SnapshotVersion(snapshot *cache.Snapshot) string {
+	var content bytes.Buffer
+	for _, resources := range snapshot.Resources {
+		content.WriteString(resources.Version)
+		content.WriteByte(0)
+	}
+	return cache.HashResource(content.Bytes())
+}
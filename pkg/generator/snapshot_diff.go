@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// ResourceDiff lists the names of the resources of a kind added, removed or changed
+// between two snapshots, sorted.
+type ResourceDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty returns whether no resource was added, removed or changed.
+func (d ResourceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// SnapshotDiff is the difference between two snapshots, so that operators can audit
+// what a change actually pushed to the gateways.
+type SnapshotDiff struct {
+	Clusters ResourceDiff `json:"clusters"`
+	// VirtualHosts are named "<route config>/<virtual host>", or by their resource name
+	// with VHDS.
+	VirtualHosts ResourceDiff `json:"virtualHosts"`
+	// SNIMatches are the filter chains matching server names, named
+	// "<listener>/<server names>".
+	SNIMatches ResourceDiff `json:"sniMatches"`
+}
+
+// Empty returns whether the snapshots don't differ in any of the compared resources.
+func (d SnapshotDiff) Empty() bool {
+	return d.Clusters.Empty() && d.VirtualHosts.Empty() && d.SNIMatches.Empty()
+}
+
+// DiffSnapshots returns the difference between the snapshots before and after a change.
+// The snapshot before may be nil, e.g. for the first snapshot of a node ID.
+func DiffSnapshots(before, after cache.ResourceSnapshot) SnapshotDiff {
+	return SnapshotDiff{
+		Clusters:     diffResources(snapshotClusters(before), snapshotClusters(after)),
+		VirtualHosts: diffResources(snapshotVirtualHosts(before), snapshotVirtualHosts(after)),
+		SNIMatches:   diffResources(snapshotSNIMatches(before), snapshotSNIMatches(after)),
+	}
+}
+
+func diffResources(before, after map[string]proto.Message) ResourceDiff {
+	var diff ResourceDiff
+	for name, resource := range after {
+		if previous, ok := before[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		} else if !proto.Equal(previous, resource) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+func snapshotClusters(snapshot cache.ResourceSnapshot) map[string]proto.Message {
+	clusters := make(map[string]proto.Message)
+	if snapshot == nil {
+		return clusters
+	}
+	for name, cluster := range snapshot.GetResources(resource.ClusterType) {
+		clusters[name] = cluster
+	}
+	return clusters
+}
+
+func snapshotVirtualHosts(snapshot cache.ResourceSnapshot) map[string]proto.Message {
+	vhosts := make(map[string]proto.Message)
+	if snapshot == nil {
+		return vhosts
+	}
+	for _, res := range snapshot.GetResources(resource.RouteType) {
+		routeConfig := res.(*route.RouteConfiguration)
+		for _, vhost := range routeConfig.VirtualHosts {
+			vhosts[routeConfig.Name+"/"+vhost.Name] = vhost
+		}
+	}
+	for name, vhost := range snapshot.GetResources(resource.VirtualHostType) {
+		vhosts[name] = vhost
+	}
+	return vhosts
+}
+
+func snapshotSNIMatches(snapshot cache.ResourceSnapshot) map[string]proto.Message {
+	matches := make(map[string]proto.Message)
+	if snapshot == nil {
+		return matches
+	}
+	for _, res := range snapshot.GetResources(resource.ListenerType) {
+		l := res.(*listener.Listener)
+		for _, filterChain := range l.FilterChains {
+			if serverNames := filterChain.GetFilterChainMatch().GetServerNames(); len(serverNames) != 0 {
+				matches[l.Name+"/"+strings.Join(serverNames, ",")] = filterChain
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	snapshot := func(clusters []cachetypes.Resource, vhosts []*route.VirtualHost, serverNames ...[]string) *cache.Snapshot {
+		t.Helper()
+		filterChains := make([]*listener.FilterChain, 0, len(serverNames))
+		for _, names := range serverNames {
+			filterChains = append(filterChains, &listener.FilterChain{
+				FilterChainMatch: &listener.FilterChainMatch{ServerNames: names},
+			})
+		}
+		s, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			resource.ClusterType:  clusters,
+			resource.RouteType:    {envoy.NewRouteConfig("external", vhosts)},
+			resource.ListenerType: {&listener.Listener{Name: "https", FilterChains: filterChains}},
+		})
+		assert.NilError(t, err)
+		return s
+	}
+
+	c1 := envoy.NewCluster("c1", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	c2 := envoy.NewCluster("c2", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	c2Changed := envoy.NewCluster("c2", 10*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	foo := envoy.NewVirtualHost("foo", []string{"foo.example.com"}, nil)
+	bar := envoy.NewVirtualHost("bar", []string{"bar.example.com"}, nil)
+
+	before := snapshot([]cachetypes.Resource{c1, c2}, []*route.VirtualHost{foo}, []string{"foo.example.com"})
+	after := snapshot([]cachetypes.Resource{c2Changed}, []*route.VirtualHost{foo, bar}, []string{"foo.example.com"}, []string{"bar.example.com"})
+
+	assert.DeepEqual(t, DiffSnapshots(before, after), SnapshotDiff{
+		Clusters:     ResourceDiff{Removed: []string{"c1"}, Changed: []string{"c2"}},
+		VirtualHosts: ResourceDiff{Added: []string{"external/bar"}},
+		SNIMatches:   ResourceDiff{Added: []string{"https/bar.example.com"}},
+	})
+	assert.Assert(t, DiffSnapshots(after, after).Empty())
+
+	// Everything is added by the first snapshot.
+	first := DiffSnapshots(nil, before)
+	assert.DeepEqual(t, first.Clusters.Added, []string{"c1", "c2"})
+	assert.DeepEqual(t, first.VirtualHosts.Added, []string{"external/foo"})
+	assert.DeepEqual(t, first.SNIMatches.Added, []string{"https/foo.example.com"})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	c1 := envoy.NewCluster("c1", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	c2 := envoy.NewCluster("c2", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	c2Changed := envoy.NewCluster("c2", 10*time.Second, nil, false, nil, v3.Cluster_STATIC)
+
+	version := func(clusters ...cachetypes.Resource) string {
+		t.Helper()
+		snapshot, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			resource.ClusterType: clusters,
+			resource.RouteType:   {},
+		})
+		assert.NilError(t, err)
+		return snapshot.GetVersion(resource.ClusterType)
+	}
+
+	t.Run("stable across order", func(t *testing.T) {
+		assert.Equal(t, version(c1, c2), version(c2, c1))
+	})
+
+	t.Run("changes with content", func(t *testing.T) {
+		assert.Assert(t, version(c1, c2) != version(c1, c2Changed))
+		assert.Assert(t, version(c1, c2) != version(c1))
+	})
+
+	t.Run("unchanged types keep their version", func(t *testing.T) {
+		s1, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			resource.ClusterType: {c1},
+			resource.RouteType:   {},
+		})
+		assert.NilError(t, err)
+		s2, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			resource.ClusterType: {c1, c2},
+			resource.RouteType:   {},
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, s1.GetVersion(resource.RouteType), s2.GetVersion(resource.RouteType))
+	})
+
+	t.Run("changes with TTL", func(t *testing.T) {
+		withTTL := func(ttl time.Duration) string {
+			t.Helper()
+			snapshot, err := newSnapshotWithTTLs(map[resource.Type][]cachetypes.Resource{
+				resource.ClusterType: {c1},
+			}, map[string]time.Duration{"c1": ttl})
+			assert.NilError(t, err)
+			assert.Equal(t, *snapshot.GetResourcesAndTTL(resource.ClusterType)["c1"].TTL, ttl)
+			return snapshot.GetVersion(resource.ClusterType)
+		}
+		assert.Assert(t, withTTL(time.Minute) != version(c1))
+		assert.Assert(t, withTTL(time.Minute) != withTTL(time.Hour))
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			"unknown": {c1},
+		})
+		assert.ErrorContains(t, err, "unknown resource type")
+	})
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	c1 := envoy.NewCluster("c1", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+	c2 := envoy.NewCluster("c2", 5*time.Second, nil, false, nil, v3.Cluster_STATIC)
+
+	version := func(clusters ...cachetypes.Resource) string {
+		t.Helper()
+		snapshot, err := newSnapshot(map[resource.Type][]cachetypes.Resource{
+			resource.ClusterType: clusters,
+			resource.RouteType:   {},
+		})
+		assert.NilError(t, err)
+		return SnapshotVersion(snapshot)
+	}
+
+	assert.Equal(t, version(c1, c2), version(c2, c1))
+	assert.Assert(t, version(c1, c2) != version(c1))
+}
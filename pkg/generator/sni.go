@@ -17,6 +17,8 @@ limitations under the License.
 package generator
 
 import (
+	"strings"
+
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	envoy "knative.dev/net-kourier/pkg/envoy/api"
@@ -29,21 +31,38 @@ type dedupedSNIMatch struct {
 	hosts    sets.String
 }
 
+// sniMatchKey identifies SNIMatches that can be collapsed.
+type sniMatchKey struct {
+	certSource       types.NamespacedName
+	clientValidation string
+}
+
+func keyForSNIMatch(match *envoy.SNIMatch) sniMatchKey {
+	key := sniMatchKey{certSource: match.CertSource}
+	if match.ClientValidation != nil {
+		key.clientValidation = match.ClientValidation.CASource.String() + "|" +
+			strings.Join(match.ClientValidation.AllowedSANs, ",")
+	}
+	return key
+}
+
 // sniMatches is a collection of deduplicated sni matches that can be used to deduplicate
 // an existing list of sniMatches to avoid allocating a lot of configuration memory for
 // tls configurations that are essentially equal.
 // SNIMatches are deduplicated and collapsed by collapsing the list of hosts of all
-// matches that have the same certificate source (i.e. reference the same Secret).
-type sniMatches map[types.NamespacedName]*dedupedSNIMatch
+// matches that have the same certificate source (i.e. reference the same Secret) and
+// the same client certificate validation.
+type sniMatches map[sniMatchKey]*dedupedSNIMatch
 
 func (s sniMatches) consume(match *envoy.SNIMatch) {
-	state := s[match.CertSource]
+	key := keyForSNIMatch(match)
+	state := s[key]
 	if state == nil {
 		state = &dedupedSNIMatch{
 			sniMatch: match,
 			hosts:    sets.NewString(match.Hosts...),
 		}
-		s[match.CertSource] = state
+		s[key] = state
 		return
 	}
 
@@ -67,3 +86,16 @@ func (s sniMatches) list() []*envoy.SNIMatch {
 	}
 	return matches
 }
+
+// withoutClientValidation returns copies of the given SNIMatches that don't validate
+// client certificates, deduplicated and collapsed again.
+func withoutClientValidation(matches []*envoy.SNIMatch) []*envoy.SNIMatch {
+	snis := sniMatches{}
+	for _, match := range matches {
+		c := *match
+		c.Hosts = append([]string(nil), match.Hosts...)
+		c.ClientValidation = nil
+		snis.consume(&c)
+	}
+	return snis.list()
+}
@@ -113,3 +113,42 @@ func TestDeduplication(t *testing.T) {
 		})
 	}
 }
+
+func TestDeduplicationWithClientValidation(t *testing.T) {
+	s1 := types.NamespacedName{
+		Namespace: "secret-ns-1",
+		Name:      "secret-1",
+	}
+	validation := &envoy.ClientValidation{
+		CASource:       types.NamespacedName{Namespace: "secret-ns-1", Name: "ca"},
+		CACertificates: []byte("ca"),
+	}
+
+	matches := sniMatches{}
+	matches.consume(&envoy.SNIMatch{Hosts: []string{"foo"}, CertSource: s1})
+	matches.consume(&envoy.SNIMatch{Hosts: []string{"bar"}, CertSource: s1, ClientValidation: validation})
+	matches.consume(&envoy.SNIMatch{Hosts: []string{"baz"}, CertSource: s1, ClientValidation: validation})
+	got := matches.list()
+
+	// Matches with a different client validation must not be collapsed.
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].ClientValidation == nil
+	})
+	assert.DeepEqual(t, got, []*envoy.SNIMatch{{
+		Hosts:      []string{"foo"},
+		CertSource: s1,
+	}, {
+		Hosts:            []string{"bar", "baz"},
+		CertSource:       s1,
+		ClientValidation: validation,
+	}})
+
+	// Without client validation, all of them are collapsed again.
+	assert.DeepEqual(t, withoutClientValidation(got), []*envoy.SNIMatch{{
+		Hosts:      []string{"foo", "bar", "baz"},
+		CertSource: s1,
+	}})
+	// The original matches are unchanged.
+	assert.DeepEqual(t, got[0].Hosts, []string{"foo"})
+	assert.Assert(t, got[1].ClientValidation != nil)
+}
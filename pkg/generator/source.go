@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/tracker"
+)
+
+// ObjectGetter gives access to the objects referenced by Ingresses.
+type ObjectGetter interface {
+	Secret(ns, name string) (*corev1.Secret, error)
+	Endpoints(ns, name string) (*corev1.Endpoints, error)
+	Service(ns, name string) (*corev1.Service, error)
+	Namespace(name string) (*corev1.Namespace, error)
+	ConfigMaps(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error)
+}
+
+// Source is where Ingresses and the objects they reference come from. It allows
+// feeding the translator from informers, API clients, local files or alternative
+// resources alike.
+type Source interface {
+	ObjectGetter
+
+	// ListIngresses returns the Ingresses to seed the configuration with.
+	ListIngresses(ctx context.Context) ([]*v1alpha1.Ingress, error)
+}
+
+// NewIngressTranslatorFromSource creates an IngressTranslator which fetches the objects
+// referenced by Ingresses from the given getter. If the getter implements PodGetter,
+// the pods behind the endpoints are fetched from it as well.
+func NewIngressTranslatorFromSource(getter ObjectGetter, tracker tracker.Interface) IngressTranslator {
+	translator := NewIngressTranslator(getter.Secret, getter.Endpoints, getter.Service, getter.Namespace, getter.ConfigMaps, tracker)
+	if pods, ok := getter.(PodGetter); ok {
+		translator.podGetter = pods.Pod
+	}
+	return translator
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// sourceAddressDirect matches the source CIDRs against the address of the peer of
+	// the connection.
+	sourceAddressDirect = "direct"
+
+	// sourceAddressForwarded matches the source CIDRs against the address of the client
+	// forwarded by the trusted proxies in front of the gateways.
+	sourceAddressForwarded = "forwarded"
+)
+
+// sourceIPFilterFromAnnotations returns the sources the requests to the hosts of the
+// ingress are allowed from, as specified via annotations on it, or nil if they aren't
+// restricted. The restrictions are rejected unless source IP filters are enabled, and
+// the forwarded addresses unless they are forwarded by trusted proxies.
+func sourceIPFilterFromAnnotations(ingress *v1alpha1.Ingress, kourierConfig *pkgconfig.Kourier) (*envoy.SourceIPFilter, error) {
+	allowed, err := sourceCIDRs(ingress, pkgconfig.AllowedSourceCIDRsAnnotationKey, pkgconfig.GetAllowedSourceCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := sourceCIDRs(ingress, pkgconfig.DeniedSourceCIDRsAnnotationKey, pkgconfig.GetDeniedSourceCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	if !kourierConfig.SourceIPFilters {
+		return nil, fmt.Errorf("invalid %s or %s annotation: source IP filters are not enabled",
+			pkgconfig.AllowedSourceCIDRsAnnotationKey, pkgconfig.DeniedSourceCIDRsAnnotationKey)
+	}
+
+	filter := &envoy.SourceIPFilter{Allowed: allowed, Denied: denied}
+	switch address := pkgconfig.GetSourceAddress(ingress.Annotations); address {
+	case "", sourceAddressDirect:
+	case sourceAddressForwarded:
+		// Without trusted proxies, the forwarded address is whatever the client claims.
+		if kourierConfig.XffNumTrustedHops == 0 && kourierConfig.OriginalIPHeader == "" {
+			return nil, fmt.Errorf("invalid %s annotation: no trusted proxies are configured to forward the address",
+				pkgconfig.SourceAddressAnnotationKey)
+		}
+		filter.Forwarded = true
+	default:
+		return nil, fmt.Errorf("invalid %s annotation: %q is neither %q nor %q",
+			pkgconfig.SourceAddressAnnotationKey, address, sourceAddressDirect, sourceAddressForwarded)
+	}
+	return filter, nil
+}
+
+// sourceCIDRs returns the comma-separated CIDRs of the given annotation of the ingress.
+func sourceCIDRs(ingress *v1alpha1.Ingress, key string, get func(map[string]string) string) ([]string, error) {
+	value := get(ingress.Annotations)
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var cidrs []string
+	for _, cidr := range strings.Split(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", key, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
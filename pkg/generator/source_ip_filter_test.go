@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rbac "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestSourceIPFilterFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name             string
+		annotations      map[string]string
+		disabled         bool
+		untrusted        bool
+		originalIPHeader string
+		want             *envoy.SourceIPFilter
+		wantErr          bool
+	}{{
+		name: "no annotations",
+	}, {
+		name: "allowed and denied CIDRs",
+		annotations: map[string]string{
+			pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8, 192.168.0.0/16",
+			pkgconfig.DeniedSourceCIDRsAnnotationKey:  "10.0.1.0/24",
+		},
+		want: &envoy.SourceIPFilter{
+			Allowed: []string{"10.0.0.0/8", "192.168.0.0/16"},
+			Denied:  []string{"10.0.1.0/24"},
+		},
+	}, {
+		name: "forwarded address",
+		annotations: map[string]string{
+			pkgconfig.DeniedSourceCIDRsAnnotationKey: "203.0.113.0/24",
+			pkgconfig.SourceAddressAnnotationKey:     "forwarded",
+		},
+		want: &envoy.SourceIPFilter{
+			Denied:    []string{"203.0.113.0/24"},
+			Forwarded: true,
+		},
+	}, {
+		name: "forwarded address without trusted proxies",
+		annotations: map[string]string{
+			pkgconfig.DeniedSourceCIDRsAnnotationKey: "203.0.113.0/24",
+			pkgconfig.SourceAddressAnnotationKey:     "forwarded",
+		},
+		untrusted: true,
+		wantErr:   true,
+	}, {
+		name: "forwarded address from the original IP header",
+		annotations: map[string]string{
+			pkgconfig.DeniedSourceCIDRsAnnotationKey: "203.0.113.0/24",
+			pkgconfig.SourceAddressAnnotationKey:     "forwarded",
+		},
+		untrusted:        true,
+		originalIPHeader: "True-Client-IP",
+		want: &envoy.SourceIPFilter{
+			Denied:    []string{"203.0.113.0/24"},
+			Forwarded: true,
+		},
+	}, {
+		name: "invalid CIDR",
+		annotations: map[string]string{
+			pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8,10.0.0.1",
+		},
+		wantErr: true,
+	}, {
+		name: "invalid source address",
+		annotations: map[string]string{
+			pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8",
+			pkgconfig.SourceAddressAnnotationKey:      "proxied",
+		},
+		wantErr: true,
+	}, {
+		name: "not enabled",
+		annotations: map[string]string{
+			pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8",
+		},
+		disabled: true,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+				ing.Annotations = test.annotations
+			})
+			kourierConfig := &pkgconfig.Kourier{SourceIPFilters: !test.disabled, XffNumTrustedHops: 1}
+			if test.untrusted {
+				kourierConfig.XffNumTrustedHops = 0
+			}
+			kourierConfig.OriginalIPHeader = test.originalIPHeader
+			got, err := sourceIPFilterFromAnnotations(ingress, kourierConfig)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorSourceIPFilter(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.SourceIPFilters = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8"}
+	}), false)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got.externalVirtualHosts) != 0)
+
+	// Both the external and the internal hosts restrict the sources of their requests.
+	for _, vh := range append(got.externalVirtualHosts, got.internalVirtualHosts...) {
+		perRoute := &rbac.RBACPerRoute{}
+		assert.NilError(t, vh.TypedPerFilterConfig["kourier.source_ip_filter"].UnmarshalTo(perRoute))
+		assert.Assert(t, perRoute.Rbac.Rules != nil)
+	}
+
+	// The sources of the passed through connections can't be restricted.
+	_, err = translator.translateIngress(ctx, ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{
+			pkgconfig.TLSPassthroughAnnotationKey:     "true",
+			pkgconfig.AllowedSourceCIDRsAnnotationKey: "10.0.0.0/8",
+		}
+		ing.Spec.Rules[0].HTTP.Paths[0].Path = ""
+		ing.Spec.Rules[0].HTTP.Paths[0].Headers = nil
+	}), false)
+	assert.Assert(t, errors.Is(err, ErrInvalidTLSPassthrough), "got error %v", err)
+}
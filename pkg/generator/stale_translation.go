@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrStaleTranslation is an error produced when an ingress couldn't be translated due
+// to a transient error, so its last successful translation is served instead.
+var ErrStaleTranslation = errors.New("serving the last successful translation of the ingress")
+
+// isTransientError returns whether the given error is likely to go away by itself, such
+// as the API server timing out or being overloaded, as opposed to errors in the ingress
+// or the objects it refers to.
+func isTransientError(err error) bool {
+	if apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// keepStaleIngress keeps serving the current translation of the given ingress, if there
+// is one, and returns since when it's stale.
+func (caches *Caches) keepStaleIngress(name types.NamespacedName, now time.Time) (time.Time, bool) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	if _, ok := caches.translatedIngresses[name]; !ok {
+		return time.Time{}, false
+	}
+	since, ok := caches.staleSince[name]
+	if !ok {
+		since = now
+		caches.staleSince[name] = since
+	}
+	return since, true
+}
+
+// StaleIngresses returns the number of ingresses served from their last successful
+// translation, and since when the oldest of them is stale.
+func (caches *Caches) StaleIngresses() (int, time.Time) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+
+	var oldest time.Time
+	for _, since := range caches.staleSince {
+		if oldest.IsZero() || since.Before(oldest) {
+			oldest = since
+		}
+	}
+	return len(caches.staleSince), oldest
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIsTransientError(t *testing.T) {
+	services := schema.GroupResource{Resource: "services"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{{
+		name: "server timeout",
+		err:  apierrors.NewServerTimeout(services, "get", 1),
+		want: true,
+	}, {
+		name: "too many requests",
+		err:  apierrors.NewTooManyRequests("slow down", 1),
+		want: true,
+	}, {
+		name: "service unavailable",
+		err:  fmt.Errorf("failed to fetch service: %w", apierrors.NewServiceUnavailable("down")),
+		want: true,
+	}, {
+		name: "deadline exceeded",
+		err:  context.DeadlineExceeded,
+		want: true,
+	}, {
+		name: "not found",
+		err:  apierrors.NewNotFound(services, "servicename"),
+	}, {
+		name: "forbidden",
+		err:  apierrors.NewForbidden(services, "servicename", errors.New("no")),
+	}, {
+		name: "invalid ingress",
+		err:  ErrInvalidTLSPassthrough,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, isTransientError(test.err), test.want)
+		})
+	}
+}
+
+func TestUpdateInfoForIngressKeepsStaleTranslation(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	var serviceErr error
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			if serviceErr != nil {
+				return nil, serviceErr
+			}
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+	translator.now = func() time.Time { return now }
+
+	caches, err := NewCaches(ctx, kubeclient, false)
+	assert.NilError(t, err)
+	name := types.NamespacedName{Namespace: "testspace", Name: "testname"}
+
+	assert.NilError(t, UpdateInfoForIngress(ctx, caches, ing("testspace", "testname"), &translator, false))
+	translated := caches.translatedIngresses[name]
+	assert.Assert(t, translated != nil)
+
+	// Transient errors keep the last translation, and its staleness is tracked.
+	serviceErr = apierrors.NewServiceUnavailable("down")
+	err = UpdateInfoForIngress(ctx, caches, ing("testspace", "testname"), &translator, false)
+	assert.Assert(t, errors.Is(err, ErrStaleTranslation), "got error %v", err)
+	assert.Equal(t, caches.translatedIngresses[name], translated)
+
+	now = now.Add(time.Minute)
+	assert.Assert(t, errors.Is(UpdateInfoForIngress(ctx, caches, ing("testspace", "testname"), &translator, false), ErrStaleTranslation))
+	count, oldest := caches.StaleIngresses()
+	assert.Equal(t, count, 1)
+	assert.Equal(t, oldest, now.Add(-time.Minute))
+
+	// Other errors don't.
+	serviceErr = apierrors.NewForbidden(schema.GroupResource{Resource: "services"}, "servicename", errors.New("no"))
+	err = UpdateInfoForIngress(ctx, caches, ing("testspace", "testname"), &translator, false)
+	assert.Assert(t, err != nil && !errors.Is(err, ErrStaleTranslation), "got error %v", err)
+
+	// Successful translations replace the stale ones.
+	serviceErr = nil
+	assert.NilError(t, UpdateInfoForIngress(ctx, caches, ing("testspace", "testname"), &translator, false))
+	count, _ = caches.StaleIngresses()
+	assert.Equal(t, count, 0)
+
+	// Without a previous translation, there's nothing to fall back to.
+	serviceErr = apierrors.NewServiceUnavailable("down")
+	err = UpdateInfoForIngress(ctx, caches, ing("testspace", "other"), &translator, false)
+	assert.Assert(t, err != nil && !errors.Is(err, ErrStaleTranslation), "got error %v", err)
+}
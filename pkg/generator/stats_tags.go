@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// serviceLabelKey is the label of the Ingresses of Knative services naming them.
+	serviceLabelKey = "serving.knative.dev/service"
+
+	// The names of the tags the bootstrap config extracts from the stat prefixes of the
+	// routes, each followed by its value.
+	ingressNamespaceStatTag = "kingress_namespace"
+	ingressNameStatTag      = "kingress_name"
+	serviceStatTag          = "kservice"
+	revisionStatTag         = "krevision"
+	policyStatTag           = "kpolicy"
+)
+
+// The policies of the routes the gateways respond to themselves, named by the policy
+// tag of their stat prefix.
+const (
+	httpsRedirectPolicy  = "https_redirect"
+	hostRedirectPolicy   = "host_redirect"
+	redirectPolicy       = "redirect"
+	directResponsePolicy = "direct_response"
+)
+
+// routeStatPrefix returns the prefix of the stats of a route of the ingress, splitting
+// the requests across the given clusters. It names the ingress, its Knative service, and
+// the revision the requests are routed to, if all of them are routed to the same one.
+func routeStatPrefix(ingress *v1alpha1.Ingress, wrs []*route.WeightedCluster_ClusterWeight) string {
+	parts := ingressStatPrefixParts(ingress)
+	if revision := routedRevision(wrs); revision != "" {
+		parts = append(parts, revisionStatTag, statTagValue(revision))
+	}
+	return strings.Join(parts, ".")
+}
+
+// setPolicyStatPrefixes sets the stat prefix of the routes of the given virtual hosts
+// which the gateways respond to themselves, redirecting or responding directly, so that
+// the hits of each policy of the ingress are counted apart from its other routes.
+func setPolicyStatPrefixes(ingress *v1alpha1.Ingress, vhosts ...[]*route.VirtualHost) {
+	for _, hosts := range vhosts {
+		for _, vhost := range hosts {
+			for _, r := range vhost.Routes {
+				if policy := routePolicy(r); policy != "" {
+					r.StatPrefix = strings.Join(append(ingressStatPrefixParts(ingress), policyStatTag, policy), ".")
+				}
+			}
+		}
+	}
+}
+
+// routePolicy returns the policy of the given route if the gateways respond to it
+// themselves, or "" if it's routed upstream.
+func routePolicy(r *route.Route) string {
+	switch action := r.Action.(type) {
+	case *route.Route_Redirect:
+		switch {
+		case action.Redirect.HostRedirect != "":
+			return hostRedirectPolicy
+		case action.Redirect.GetHttpsRedirect():
+			return httpsRedirectPolicy
+		default:
+			return redirectPolicy
+		}
+	case *route.Route_DirectResponse:
+		return directResponsePolicy
+	default:
+		return ""
+	}
+}
+
+// ingressStatPrefixParts returns the tags of the stat prefixes naming the ingress and its
+// Knative service, each followed by its value.
+func ingressStatPrefixParts(ingress *v1alpha1.Ingress) []string {
+	parts := []string{
+		ingressNamespaceStatTag, statTagValue(ingress.Namespace),
+		ingressNameStatTag, statTagValue(ingress.Name),
+	}
+	if service := ingress.Labels[serviceLabelKey]; service != "" {
+		parts = append(parts, serviceStatTag, statTagValue(service))
+	}
+	return parts
+}
+
+// routedRevision returns the revision all the requests split across the given clusters
+// are routed to, or "" if they're routed to several ones or not to a revision.
+func routedRevision(wrs []*route.WeightedCluster_ClusterWeight) string {
+	var revision string
+	for _, wr := range wrs {
+		if wr.Weight.GetValue() == 0 {
+			continue
+		}
+		var name string
+		for _, header := range wr.RequestHeadersToAdd {
+			if strings.EqualFold(header.GetHeader().GetKey(), revisionHeaderName) {
+				name = header.GetHeader().GetValue()
+			}
+		}
+		if name == "" || (revision != "" && name != revision) {
+			return ""
+		}
+		revision = name
+	}
+	return revision
+}
+
+// statTagValue escapes the dots of the given value, as they separate the elements of
+// the stat names.
+func statTagValue(value string) string {
+	return strings.ReplaceAll(value, ".", "_")
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestRouteStatPrefix(t *testing.T) {
+	revision := func(name string, percent uint32) *route.WeightedCluster_ClusterWeight {
+		return envoy.NewWeightedCluster(name, percent, map[string]string{revisionHeaderName: name})
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		wrs    []*route.WeightedCluster_ClusterWeight
+		want   string
+	}{{
+		name: "plain ingress",
+		wrs:  []*route.WeightedCluster_ClusterWeight{envoy.NewWeightedCluster("servicens/servicename", 100, nil)},
+		want: "kingress_namespace.testspace.kingress_name.hello_example_com",
+	}, {
+		name:   "single revision",
+		labels: map[string]string{serviceLabelKey: "hello"},
+		wrs:    []*route.WeightedCluster_ClusterWeight{revision("hello-00001", 100)},
+		want:   "kingress_namespace.testspace.kingress_name.hello_example_com.kservice.hello.krevision.hello-00001",
+	}, {
+		name:   "split across revisions",
+		labels: map[string]string{serviceLabelKey: "hello"},
+		wrs:    []*route.WeightedCluster_ClusterWeight{revision("hello-00001", 90), revision("hello-00002", 10)},
+		want:   "kingress_namespace.testspace.kingress_name.hello_example_com.kservice.hello",
+	}, {
+		name:   "drained revision",
+		labels: map[string]string{serviceLabelKey: "hello"},
+		wrs:    []*route.WeightedCluster_ClusterWeight{revision("hello-00001", 0), revision("hello-00002", 100)},
+		want:   "kingress_namespace.testspace.kingress_name.hello_example_com.kservice.hello.krevision.hello-00002",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress := ing("testspace", "hello.example.com", func(ing *v1alpha1.Ingress) {
+				ing.Labels = test.labels
+			})
+			assert.Equal(t, routeStatPrefix(ingress, test.wrs), test.want)
+		})
+	}
+}
+
+func TestIngressTranslatorStatsTags(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.IngressStatsTags = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Labels = map[string]string{serviceLabelKey: "servicename"}
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits[0].AppendHeaders[revisionHeaderName] = "servicename-00001"
+	})
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, got.externalVirtualHosts[0].Routes[0].StatPrefix,
+		"kingress_namespace.testspace.kingress_name.testname.kservice.servicename.krevision.servicename-00001")
+
+	// The stats aren't prefixed unless configured.
+	got, err = translator.translateIngress((&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background()), in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, got.externalVirtualHosts[0].Routes[0].StatPrefix, "")
+}
+
+func TestSetPolicyStatPrefixes(t *testing.T) {
+	ingress := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "hello",
+			Labels:    map[string]string{serviceLabelKey: "hello"},
+		},
+	}
+	upstream := envoy.NewRoute("upstream", nil, "/", nil, 0, nil, "")
+	httpsRedirect := envoy.NewRedirectRoute("https", nil, "/")
+	hostRedirect := envoy.NewHostRedirectRoute("host", "hello.example.com", false)
+	pathRedirect := envoy.NewCustomRedirectRoute("path", nil, "/old", &envoy.Redirect{Path: "/new"})
+	vhost := envoy.NewVirtualHost("hello", []string{"hello.example.com"}, []*route.Route{upstream, httpsRedirect, pathRedirect})
+	envoy.RestrictToWebSockets(vhost, []string{"/"}, 0)
+	redirectVhost := envoy.NewVirtualHost("redirect", []string{"www.hello.example.com"}, []*route.Route{hostRedirect})
+
+	setPolicyStatPrefixes(ingress, []*route.VirtualHost{vhost}, []*route.VirtualHost{redirectVhost})
+
+	prefix := "kingress_namespace.ns.kingress_name.hello.kservice.hello.kpolicy."
+	assert.Equal(t, upstream.StatPrefix, "")
+	assert.Equal(t, httpsRedirect.StatPrefix, prefix+httpsRedirectPolicy)
+	assert.Equal(t, hostRedirect.StatPrefix, prefix+hostRedirectPolicy)
+	assert.Equal(t, pathRedirect.StatPrefix, prefix+redirectPolicy)
+	// The route rejecting the requests other than WebSocket upgrades follows the upstream one.
+	assert.Equal(t, vhost.Routes[1].StatPrefix, prefix+directResponsePolicy)
+}
+
+func TestIngressTranslatorPolicyRouteStats(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.PolicyRouteStats = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Spec.HTTPOption = v1alpha1.HTTPOptionRedirected
+	})
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, got.externalVirtualHosts[0].Routes[0].StatPrefix,
+		"kingress_namespace.testspace.kingress_name.testname.kpolicy.https_redirect")
+
+	// The routes aren't prefixed unless configured.
+	got, err = translator.translateIngress((&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background()), in, false)
+	assert.NilError(t, err)
+	assert.Equal(t, got.externalVirtualHosts[0].Routes[0].StatPrefix, "")
+}
@@ -27,6 +27,9 @@ import (
 	envoy "knative.dev/net-kourier/pkg/envoy/api"
 )
 
+// readyRouteName is the name of the route of the readiness probe of the gateways.
+const readyRouteName = "gateway_ready"
+
 // Generates an internal virtual host that signals that the Envoy instance has
 // been configured, this endpoint is used by the kubernetes readiness probe.
 func statusVHost() *route.VirtualHost {
@@ -54,7 +57,7 @@ func readyRoute() *route.Route {
 	cluster := envoy.NewWeightedCluster("service_stats", 100, nil)
 	var wrs []*route.WeightedCluster_ClusterWeight
 	wrs = append(wrs, cluster)
-	route := envoy.NewRoute("gateway_ready", nil, "/ready", wrs, 1*time.Second, nil, "")
+	route := envoy.NewRoute(readyRouteName, nil, "/ready", wrs, 1*time.Second, nil, "")
 
 	return route
 }
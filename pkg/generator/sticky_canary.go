@@ -0,0 +1,44 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"time"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// stickyCanaryTTLFromAnnotations returns the duration clients are pinned to the split
+// chosen for their first request, as specified via annotations on the Ingress. Returns
+// 0 if none is specified.
+func stickyCanaryTTLFromAnnotations(annotations map[string]string) (time.Duration, error) {
+	raw := pkgconfig.GetStickyCanaryTTL(annotations)
+	if raw == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation: %w", pkgconfig.StickyCanaryTTLAnnotationKey, err)
+	}
+	// The cookie's Max-Age is given in seconds.
+	if ttl < time.Second {
+		return 0, fmt.Errorf("invalid %s annotation: must be at least 1s, was: %v", pkgconfig.StickyCanaryTTLAnnotationKey, ttl)
+	}
+	return ttl, nil
+}
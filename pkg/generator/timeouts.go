@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"time"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// defaultUpstreamConnectTimeout is the timeout of establishing the connections to the
+// backends, unless configured otherwise.
+const defaultUpstreamConnectTimeout = 5 * time.Second
+
+// timeouts are the timeouts of the routes and backends of an Ingress, overriding the
+// configured ones. Each one is 0 if not overridden.
+type timeouts struct {
+	// idleTimeout is the idle timeout of the streams of the routes.
+	idleTimeout time.Duration
+	// maxStreamDuration is the maximum duration of the streams of the routes.
+	maxStreamDuration time.Duration
+	// upstreamConnectionIdleTimeout is the idle timeout of the connections to the
+	// backends.
+	upstreamConnectionIdleTimeout time.Duration
+	// upstreamConnectTimeout is the timeout of establishing the connections to the
+	// backends.
+	upstreamConnectTimeout time.Duration
+	// upstreamTCPKeepaliveTime is the time the connections to the backends are idle
+	// before TCP keepalive probes are sent.
+	upstreamTCPKeepaliveTime time.Duration
+}
+
+// timeoutsFromAnnotations returns the timeouts of the Ingress, as specified via
+// annotations on it.
+func timeoutsFromAnnotations(annotations map[string]string) (*timeouts, error) {
+	t := &timeouts{}
+	for _, timeout := range []struct {
+		key   string
+		raw   string
+		value *time.Duration
+	}{
+		{pkgconfig.IdleTimeoutAnnotationKey, pkgconfig.GetIdleTimeout(annotations), &t.idleTimeout},
+		{pkgconfig.MaxStreamDurationAnnotationKey, pkgconfig.GetMaxStreamDuration(annotations), &t.maxStreamDuration},
+		{pkgconfig.UpstreamConnectionIdleTimeoutAnnotationKey, pkgconfig.GetUpstreamConnectionIdleTimeout(annotations), &t.upstreamConnectionIdleTimeout},
+		{pkgconfig.UpstreamConnectTimeoutAnnotationKey, pkgconfig.GetUpstreamConnectTimeout(annotations), &t.upstreamConnectTimeout},
+		{pkgconfig.UpstreamTCPKeepaliveTimeAnnotationKey, pkgconfig.GetUpstreamTCPKeepaliveTime(annotations), &t.upstreamTCPKeepaliveTime},
+	} {
+		if timeout.raw == "" {
+			continue
+		}
+
+		value, err := time.ParseDuration(timeout.raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", timeout.key, err)
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation: must be positive, was: %v", timeout.key, value)
+		}
+		*timeout.value = value
+	}
+
+	// Envoy configures TCP keepalive in whole seconds.
+	if t.upstreamTCPKeepaliveTime%time.Second != 0 {
+		return nil, fmt.Errorf("invalid %s annotation: must be a number of whole seconds, was: %v",
+			pkgconfig.UpstreamTCPKeepaliveTimeAnnotationKey, t.upstreamTCPKeepaliveTime)
+	}
+	return t, nil
+}
+
+// connectTimeout returns the timeout of establishing the connections to the backends,
+// falling back to the configured one, or to defaultUpstreamConnectTimeout.
+func (t *timeouts) connectTimeout(kourierConfig *pkgconfig.Kourier) time.Duration {
+	if t.upstreamConnectTimeout != 0 {
+		return t.upstreamConnectTimeout
+	}
+	if kourierConfig.UpstreamConnectTimeout != 0 {
+		return kourierConfig.UpstreamConnectTimeout
+	}
+	return defaultUpstreamConnectTimeout
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestTimeoutsFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *timeouts
+		wantErr     bool
+	}{{
+		name: "no annotations",
+		want: &timeouts{},
+	}, {
+		name: "timeouts",
+		annotations: map[string]string{
+			pkgconfig.IdleTimeoutAnnotationKey:                   "1h",
+			pkgconfig.MaxStreamDurationAnnotationKey:             "2h",
+			pkgconfig.UpstreamConnectionIdleTimeoutAnnotationKey: "30s",
+			pkgconfig.UpstreamConnectTimeoutAnnotationKey:        "20s",
+			pkgconfig.UpstreamTCPKeepaliveTimeAnnotationKey:      "2m",
+		},
+		want: &timeouts{
+			idleTimeout:                   time.Hour,
+			maxStreamDuration:             2 * time.Hour,
+			upstreamConnectionIdleTimeout: 30 * time.Second,
+			upstreamConnectTimeout:        20 * time.Second,
+			upstreamTCPKeepaliveTime:      2 * time.Minute,
+		},
+	}, {
+		name:        "invalid duration",
+		annotations: map[string]string{pkgconfig.IdleTimeoutAnnotationKey: "an hour"},
+		wantErr:     true,
+	}, {
+		name:        "zero",
+		annotations: map[string]string{pkgconfig.MaxStreamDurationAnnotationKey: "0s"},
+		wantErr:     true,
+	}, {
+		name:        "negative",
+		annotations: map[string]string{pkgconfig.UpstreamConnectionIdleTimeoutAnnotationKey: "-1s"},
+		wantErr:     true,
+	}, {
+		name:        "fractional TCP keepalive time",
+		annotations: map[string]string{pkgconfig.UpstreamTCPKeepaliveTimeAnnotationKey: "90500ms"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := timeoutsFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(timeouts{}))
+		})
+	}
+}
+
+func TestTimeoutsConnectTimeout(t *testing.T) {
+	kourierConfig := &pkgconfig.Kourier{}
+	assert.Equal(t, (&timeouts{}).connectTimeout(kourierConfig), defaultUpstreamConnectTimeout)
+
+	kourierConfig.UpstreamConnectTimeout = 30 * time.Second
+	assert.Equal(t, (&timeouts{}).connectTimeout(kourierConfig), 30*time.Second)
+	assert.Equal(t, (&timeouts{upstreamConnectTimeout: time.Minute}).connectTimeout(kourierConfig), time.Minute)
+}
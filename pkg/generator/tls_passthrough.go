@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// IsTLSPassthrough returns whether the TLS connections to the external hosts of the
+// ingress are passed through to its backends, rather than terminated by the gateway.
+func IsTLSPassthrough(ingress *v1alpha1.Ingress) bool {
+	return strings.EqualFold(config.GetTLSPassthrough(ingress.Annotations), "true")
+}
+
+// validateTLSPassthrough returns an error if the ingress can't be served by passing its
+// TLS connections through. The connections can only be routed by their SNI, so every
+// rule must have a single path matching all requests.
+func validateTLSPassthrough(ingress *v1alpha1.Ingress) error {
+	if len(ingress.Spec.TLS) != 0 {
+		return fmt.Errorf("%w: the ingress has TLS settings, but its backends terminate TLS themselves", ErrInvalidTLSPassthrough)
+	}
+	if config.GetAllowedSourceCIDRs(ingress.Annotations) != "" || config.GetDeniedSourceCIDRs(ingress.Annotations) != "" {
+		return fmt.Errorf("%w: the sources of the passed through connections can't be restricted", ErrInvalidTLSPassthrough)
+	}
+	if pool := config.GetListenerPool(ingress.Annotations); pool != "" {
+		return fmt.Errorf("%w: the ingress is assigned to listener pool %q", ErrInvalidTLSPassthrough, pool)
+	}
+	for i, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil || len(rule.HTTP.Paths) != 1 {
+			return fmt.Errorf("%w: rule %d must have exactly one path", ErrInvalidTLSPassthrough, i)
+		}
+		httpPath := rule.HTTP.Paths[0]
+		if (httpPath.Path != "" && httpPath.Path != "/") || len(httpPath.Headers) != 0 {
+			return fmt.Errorf("%w: the path of rule %d can't match on the path or headers of requests", ErrInvalidTLSPassthrough, i)
+		}
+	}
+	return nil
+}
+
+// newPassthroughMatch creates the PassthroughMatch of the given hosts, splitting the
+// connections across the clusters of the given weighted clusters.
+func newPassthroughMatch(name string, hosts []string, weightedClusters []*route.WeightedCluster_ClusterWeight) *envoy.PassthroughMatch {
+	clusters := make([]*tcp.TcpProxy_WeightedCluster_ClusterWeight, 0, len(weightedClusters))
+	for _, weightedCluster := range weightedClusters {
+		clusters = append(clusters, &tcp.TcpProxy_WeightedCluster_ClusterWeight{
+			Name:   weightedCluster.Name,
+			Weight: weightedCluster.Weight.GetValue(),
+		})
+	}
+	return &envoy.PassthroughMatch{
+		Name:     name,
+		Hosts:    hosts,
+		Clusters: clusters,
+	}
+}
+
+// passthroughHosts returns the hosts of the given passthrough matches.
+func passthroughHosts(matches []*envoy.PassthroughMatch) []string {
+	var hosts []string
+	for _, match := range matches {
+		hosts = append(hosts, match.Hosts...)
+	}
+	return hosts
+}
+
+// addPassthroughFilterChains adds the filter chains of the given passthrough matches to
+// the external HTTPS listener, creating it if there's none yet.
+func addPassthroughFilterChains(listeners []cachetypes.Resource, matches []*envoy.PassthroughMatch, enableProxyProtocol bool) ([]cachetypes.Resource, error) {
+	if len(matches) == 0 {
+		return listeners, nil
+	}
+
+	name := envoy.CreateListenerName(config.HTTPSPortExternal)
+	for _, l := range listeners {
+		if l := l.(*v3.Listener); l.Name == name {
+			return listeners, envoy.AddPassthroughFilterChains(l, matches)
+		}
+	}
+
+	l, err := envoy.NewHTTPSListener(config.HTTPSPortExternal, nil, enableProxyProtocol)
+	if err != nil {
+		return nil, err
+	}
+	if err := envoy.AddPassthroughFilterChains(l, matches); err != nil {
+		return nil, err
+	}
+	return append(listeners, l), nil
+}
+
+// anyPassthroughHost returns whether any of the given domains is passed through by an
+// ingress other than the given one.
+func (caches *Caches) anyPassthroughHost(name types.NamespacedName, domains []string) bool {
+	hosts := baseHosts(domains)
+	for otherName, other := range caches.translatedIngresses {
+		if otherName != name && hosts.HasAny(passthroughHosts(other.passthroughMatches)...) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorTLSPassthrough(t *testing.T) {
+	passthrough := func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.TLSPassthroughAnnotationKey: "true"}
+		ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityClusterLocal
+		ing.Spec.Rules[0].HTTP.Paths[0].Path = ""
+		ing.Spec.Rules[0].HTTP.Paths[0].Headers = nil
+	}
+
+	tests := []struct {
+		name    string
+		in      *v1alpha1.Ingress
+		want    []*envoy.PassthroughMatch
+		wantErr bool
+	}{{
+		name: "passthrough",
+		in: ing("testspace", "testname", passthrough, func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+		}),
+		want: []*envoy.PassthroughMatch{{
+			Name:     "(testspace/testname).Rules[0]",
+			Hosts:    []string{"foo.example.com"},
+			Clusters: []*tcp.TcpProxy_WeightedCluster_ClusterWeight{{Name: "servicens/servicename", Weight: 100}},
+		}},
+	}, {
+		name: "cluster-local only",
+		in:   ing("testspace", "testname", passthrough),
+	}, {
+		name: "with TLS settings",
+		in: ing("testspace", "testname", passthrough, func(ing *v1alpha1.Ingress) {
+			ing.Spec.TLS = []v1alpha1.IngressTLS{{Hosts: []string{"foo.example.com"}, SecretNamespace: "secretns", SecretName: "secretname"}}
+		}),
+		wantErr: true,
+	}, {
+		name: "matching a path",
+		in: ing("testspace", "testname", passthrough, func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths[0].Path = "/api"
+		}),
+		wantErr: true,
+	}, {
+		name: "multiple paths",
+		in: ing("testspace", "testname", passthrough, func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, ing.Spec.Rules[0].HTTP.Paths[0])
+		}),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Network.InternalEncryption = true
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"))
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErr {
+				assert.Assert(t, errors.Is(err, ErrInvalidTLSPassthrough), "got error %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got.passthroughMatches, test.want, protocmp.Transform())
+
+			// The hosts aren't served via HTTP.
+			assert.Equal(t, len(got.internalVirtualHosts), 0)
+			assert.Equal(t, len(got.externalVirtualHosts), 0)
+
+			// The backends terminate TLS themselves, despite the internal encryption.
+			assert.Equal(t, len(got.clusters), 1)
+			assert.Assert(t, got.clusters[0].TransportSocket == nil)
+		})
+	}
+}
+
+func TestIngressTranslatorTLSPassthroughSplits(t *testing.T) {
+	in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.TLSPassthroughAnnotationKey: "true"}
+		ing.Spec.Rules[0].HTTP.Paths[0].Path = ""
+		ing.Spec.Rules[0].HTTP.Paths[0].Headers = nil
+		ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+		ing.Spec.Rules[0].HTTP.Paths[0].Splits = []v1alpha1.IngressBackendSplit{{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "servicename",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: 90,
+		}, {
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceNamespace: "servicens",
+				ServiceName:      "canary",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: 10,
+		}}
+	})
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(
+		svc("servicens", "servicename"), eps("servicens", "servicename"),
+		svc("servicens", "canary"), eps("servicens", "canary"),
+	)
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	got, err := translator.translateIngress(ctx, in, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got.passthroughMatches[0].Clusters, []*tcp.TcpProxy_WeightedCluster_ClusterWeight{
+		{Name: "servicens/servicename", Weight: 90},
+		{Name: "servicens/canary", Weight: 10},
+	}, protocmp.Transform())
+}
+
+func TestSnapshotWithTLSPassthrough(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	passthrough := &translatedIngress{
+		name: types.NamespacedName{Namespace: "ns", Name: "db"},
+		passthroughMatches: []*envoy.PassthroughMatch{{
+			Name:     "db",
+			Hosts:    []string{"db.example.com"},
+			Clusters: []*tcp.TcpProxy_WeightedCluster_ClusterWeight{{Name: "ns/db", Weight: 100}},
+		}},
+	}
+	assert.NilError(t, caches.addTranslatedIngress(passthrough, false))
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	// The external HTTPS listener is created for the passed through connections alone.
+	tlsListener := snapshot.GetResources(resource.ListenerType)[envoy.CreateListenerName(config.HTTPSPortExternal)].(*listener.Listener)
+	assert.Equal(t, len(tlsListener.FilterChains), 1)
+	assert.DeepEqual(t, tlsListener.FilterChains[0].FilterChainMatch.ServerNames, []string{"db.example.com"})
+
+	// The passed through hosts can't be served by other ingresses, even if shared hosts
+	// are merged.
+	other := func(name string) *translatedIngress {
+		return &translatedIngress{
+			name: types.NamespacedName{Namespace: "ns", Name: name},
+			internalVirtualHosts: []*route.VirtualHost{
+				envoy.NewVirtualHost(name, []string{"db.example.com", "db.example.com:*"},
+					[]*route.Route{envoy.NewRoute(name, nil, "/", nil, 0, nil, "")}),
+			},
+		}
+	}
+	assert.Error(t, caches.addTranslatedIngress(other("http"), true), ErrDomainConflict.Error())
+
+	caches.deleteTranslatedIngress("db", "ns")
+	assert.NilError(t, caches.addTranslatedIngress(other("http"), true))
+	assert.Error(t, caches.addTranslatedIngress(passthrough, true), ErrDomainConflict.Error())
+}
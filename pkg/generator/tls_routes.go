@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// sharesRoutes returns whether the given routes of the HTTPS listener are the routes of
+// the HTTP listener themselves, rather than diverging from them, e.g. as the HTTP
+// listener redirects to HTTPS.
+func sharesRoutes(routes, tlsRoutes []*route.Route) bool {
+	if len(tlsRoutes) == 0 || len(tlsRoutes) != len(routes) {
+		return false
+	}
+	for i := range routes {
+		if routes[i] != tlsRoutes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// unshareRoutes returns the given routes of the HTTPS listener, with a copy of the ones
+// shared with the HTTP listener.
+func unshareRoutes(routes, tlsRoutes []*route.Route) []*route.Route {
+	shared := make(map[*route.Route]bool, len(routes))
+	for _, r := range routes {
+		shared[r] = true
+	}
+
+	unshared := make([]*route.Route, 0, len(tlsRoutes))
+	for _, r := range tlsRoutes {
+		if shared[r] {
+			r = proto.Clone(r).(*route.Route)
+		}
+		unshared = append(unshared, r)
+	}
+	return unshared
+}
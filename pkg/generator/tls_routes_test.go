@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorSharedTLSRoutes(t *testing.T) {
+	withTLS := func(ing *v1alpha1.Ingress) {
+		ing.Spec.TLS = []v1alpha1.IngressTLS{{
+			Hosts:           []string{"foo.example.com"},
+			SecretNamespace: "secretns",
+			SecretName:      "secretname",
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		in         *v1alpha1.Ingress
+		wantShared bool
+	}{{
+		name:       "same routes",
+		in:         ing("testspace", "testname", withTLS),
+		wantShared: true,
+	}, {
+		name: "redirected to HTTPS",
+		in: ing("testspace", "testname", withTLS, func(ing *v1alpha1.Ingress) {
+			ing.Spec.HTTPOption = v1alpha1.HTTPOptionRedirected
+		}),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+			kubeclient := fake.NewSimpleClientset(ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"), secret)
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			assert.NilError(t, err)
+			assert.Equal(t, len(got.externalVirtualHosts), 1)
+			assert.Equal(t, len(got.externalTLSVirtualHosts), 1)
+			assert.Equal(t, got.externalTLSVirtualHosts[0] == got.externalVirtualHosts[0], test.wantShared)
+		})
+	}
+}
+
+func TestUnshareRoutes(t *testing.T) {
+	shared := envoy.NewRoute("shared", nil, "/", nil, 0, nil, "")
+	own := envoy.NewRoute("own", nil, "/", nil, 0, nil, "")
+	routes := []*route.Route{shared}
+
+	assert.Assert(t, sharesRoutes(routes, []*route.Route{shared}))
+	assert.Assert(t, !sharesRoutes(routes, []*route.Route{own}))
+	assert.Assert(t, !sharesRoutes(routes, nil))
+
+	got := unshareRoutes(routes, []*route.Route{shared, own})
+	assert.Equal(t, len(got), 2)
+	assert.Assert(t, got[0] != shared)
+	assert.DeepEqual(t, got[0], shared, protocmp.Transform())
+	assert.Assert(t, got[1] == own)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// transformSpec is the request transformation as specified in the transform annotation.
+// It is handed to the transformation Wasm module via route metadata.
+//
+// Example: {"pathTemplate": "/v2{path}", "removeJsonFields": ["internal"]}
+type transformSpec struct {
+	// PathTemplate rewrites the request path. "{path}" is replaced with the original path.
+	PathTemplate string `json:"pathTemplate,omitempty"`
+	// RemoveJSONFields lists the top-level fields removed from JSON request bodies.
+	RemoveJSONFields []string `json:"removeJsonFields,omitempty"`
+	// KeepJSONFields lists the only top-level fields kept in JSON request bodies.
+	KeepJSONFields []string `json:"keepJsonFields,omitempty"`
+}
+
+func (t transformSpec) validate() error {
+	if t.PathTemplate != "" && !strings.HasPrefix(t.PathTemplate, "/") && !strings.HasPrefix(t.PathTemplate, "{path}") {
+		return errors.New(`pathTemplate must start with "/" or "{path}"`)
+	}
+	if len(t.RemoveJSONFields) != 0 && len(t.KeepJSONFields) != 0 {
+		return errors.New("only one of removeJsonFields and keepJsonFields can be set")
+	}
+	return nil
+}
+
+// transformFromAnnotations returns the request transformation specified via
+// annotations on the Ingress, as route metadata. Returns nil if none is specified.
+func transformFromAnnotations(annotations map[string]string) (*structpb.Struct, error) {
+	raw := pkgconfig.GetTransform(annotations)
+	if raw == "" {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	var spec transformSpec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.TransformAnnotationKey, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.TransformAnnotationKey, err)
+	}
+
+	// Round-trip through JSON to drop unset fields.
+	normalized, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(normalized, &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
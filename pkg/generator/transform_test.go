@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestTransformFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       map[string]interface{}
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "path template and removed fields",
+		annotation: `{"pathTemplate": "/v2{path}", "removeJsonFields": ["internal"]}`,
+		want: map[string]interface{}{
+			"pathTemplate":     "/v2{path}",
+			"removeJsonFields": []interface{}{"internal"},
+		},
+	}, {
+		name:       "kept fields",
+		annotation: `{"keepJsonFields": ["name", "id"]}`,
+		want: map[string]interface{}{
+			"keepJsonFields": []interface{}{"name", "id"},
+		},
+	}, {
+		name:       "unknown field",
+		annotation: `{"foo": "bar"}`,
+		wantErr:    true,
+	}, {
+		name:       "relative path template",
+		annotation: `{"pathTemplate": "v2"}`,
+		wantErr:    true,
+	}, {
+		name:       "removed and kept fields",
+		annotation: `{"keepJsonFields": ["name"], "removeJsonFields": ["id"]}`,
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.TransformAnnotationKey] = test.annotation
+			}
+
+			got, err := transformFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+
+			if test.want == nil {
+				assert.Assert(t, got == nil)
+				return
+			}
+			want, err := structpb.NewStruct(test.want)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, want, protocmp.Transform())
+		})
+	}
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/tracker"
+)
+
+// translationCache caches the translations of Ingresses, so that resyncs don't translate
+// Ingresses again if neither they, the config nor any of the objects they were translated
+// from changed.
+type translationCache struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*cachedTranslation
+}
+
+type cachedTranslation struct {
+	key translationKey
+
+	// dependencies are the versions of the objects the Ingress was translated from.
+	dependencies map[dependency]string
+
+	// tracked are the references tracked during the translation. They have to be tracked
+	// again whenever the translation is reused, to keep the tracker's leases alive.
+	tracked []tracker.Reference
+
+	translated *translatedIngress
+}
+
+// translationKey identifies the state of an Ingress and of the config it was translated
+// with. Changes to the labels and annotations don't change the generation, so they're
+// part of the key as well, along with whether the Ingress is within a window of its
+// schedule.
+type translationKey struct {
+	generation      int64
+	metadataHash    string
+	config          *config.Config
+	extAuthzEnabled bool
+	scheduled       bool
+}
+
+// dependency is an object, or for ConfigMaps the list of objects matching a selector, an
+// Ingress was translated from.
+type dependency struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func newTranslationCache() *translationCache {
+	return &translationCache{
+		entries: make(map[types.NamespacedName]*cachedTranslation),
+	}
+}
+
+// translate translates the Ingress, reusing its previous translation if nothing relevant
+// changed since.
+func (translator *IngressTranslator) translate(ctx context.Context, ingress *v1alpha1.Ingress, extAuthzEnabled bool) (*translatedIngress, error) {
+	if translator.cache == nil || ingress.Generation == 0 {
+		// Without a generation, changes to the Ingress can't be told apart.
+		return translator.translateIngress(ctx, ingress, extAuthzEnabled)
+	}
+
+	// The config is immutable, it's replaced as a whole on changes. Without a config in
+	// the context, the defaults are created anew and never match.
+	name := types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name}
+	key := translationKey{
+		generation:      ingress.Generation,
+		metadataHash:    hashMaps(ingress.Labels, ingress.Annotations),
+		config:          config.FromContextOrDefaults(ctx),
+		extAuthzEnabled: extAuthzEnabled,
+		scheduled:       isScheduled(ingress, translator.now()),
+	}
+	if cached := translator.cache.get(name); cached != nil && cached.key == key && translator.upToDate(cached.dependencies) {
+		for _, ref := range cached.tracked {
+			if err := translator.tracker.TrackReference(ref, ingress); err != nil {
+				return nil, err
+			}
+		}
+		return cached.translated, nil
+	}
+
+	recorder := &dependencyRecorder{
+		Interface:    translator.tracker,
+		dependencies: make(map[dependency]string),
+	}
+	recording := *translator
+	recording.tracker = recorder
+	recording.secretGetter = func(ns, name string) (*corev1.Secret, error) {
+		secret, err := translator.secretGetter(ns, name)
+		recorder.record(dependency{kind: "Secret", namespace: ns, name: name}, secret, err)
+		return secret, err
+	}
+	recording.endpointsGetter = func(ns, name string) (*corev1.Endpoints, error) {
+		endpoints, err := translator.endpointsGetter(ns, name)
+		recorder.record(dependency{kind: "Endpoints", namespace: ns, name: name}, endpoints, err)
+		return endpoints, err
+	}
+	if translator.podGetter != nil {
+		// The health status of the endpoints is derived from the pods behind them.
+		recording.podGetter = func(ns, name string) (*corev1.Pod, error) {
+			pod, err := translator.podGetter(ns, name)
+			recorder.record(dependency{kind: "Pod", namespace: ns, name: name}, pod, err)
+			return pod, err
+		}
+	}
+	recording.serviceGetter = func(ns, name string) (*corev1.Service, error) {
+		service, err := translator.serviceGetter(ns, name)
+		recorder.record(dependency{kind: "Service", namespace: ns, name: name}, service, err)
+		return service, err
+	}
+	recording.namespaceGetter = func(name string) (*corev1.Namespace, error) {
+		namespace, err := translator.namespaceGetter(name)
+		recorder.record(dependency{kind: "Namespace", name: name}, namespace, err)
+		return namespace, err
+	}
+	recording.configMapsGetter = func(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+		configMaps, err := translator.configMapsGetter(ns, selector)
+		recorder.recordList(dependency{kind: "ConfigMap", namespace: ns, name: selector.String()}, configMaps, err)
+		return configMaps, err
+	}
+
+	translated, err := recording.translateIngress(ctx, ingress, extAuthzEnabled)
+	if err != nil || translated == nil || recorder.failed {
+		// Incomplete translations are retried rather than reused.
+		translator.cache.delete(name)
+		return translated, err
+	}
+
+	translator.cache.set(name, &cachedTranslation{
+		key:          key,
+		dependencies: recorder.dependencies,
+		tracked:      recorder.tracked,
+		translated:   translated,
+	})
+	return translated, nil
+}
+
+// ForgetIngress drops the cached translation of the given Ingress, e.g. because it was
+// deleted.
+func (translator *IngressTranslator) ForgetIngress(name types.NamespacedName) {
+	if translator.cache != nil {
+		translator.cache.delete(name)
+	}
+}
+
+// upToDate returns whether the given objects still have the given versions.
+func (translator *IngressTranslator) upToDate(dependencies map[dependency]string) bool {
+	for dep, version := range dependencies {
+		current, err := translator.currentVersion(dep)
+		if err != nil || current != version {
+			return false
+		}
+	}
+	return true
+}
+
+func (translator *IngressTranslator) currentVersion(dep dependency) (string, error) {
+	switch dep.kind {
+	case "Secret":
+		return objectVersion(translator.secretGetter(dep.namespace, dep.name))
+	case "Endpoints":
+		return objectVersion(translator.endpointsGetter(dep.namespace, dep.name))
+	case "Service":
+		return objectVersion(translator.serviceGetter(dep.namespace, dep.name))
+	case "Pod":
+		return objectVersion(translator.podGetter(dep.namespace, dep.name))
+	case "Namespace":
+		return objectVersion(translator.namespaceGetter(dep.name))
+	case "ConfigMap":
+		selector, err := labels.Parse(dep.name)
+		if err != nil {
+			return "", err
+		}
+		return listVersion(translator.configMapsGetter(dep.namespace, selector))
+	}
+	return "", fmt.Errorf("unknown dependency kind %q", dep.kind)
+}
+
+func (cache *translationCache) get(name types.NamespacedName) *cachedTranslation {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.entries[name]
+}
+
+func (cache *translationCache) set(name types.NamespacedName, entry *cachedTranslation) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries[name] = entry
+}
+
+func (cache *translationCache) delete(name types.NamespacedName) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.entries, name)
+}
+
+// dependencyRecorder records the objects fetched and the references tracked during a
+// translation.
+type dependencyRecorder struct {
+	tracker.Interface
+
+	dependencies map[dependency]string
+	tracked      []tracker.Reference
+
+	// failed is set if an object couldn't be fetched for another reason than not
+	// being found.
+	failed bool
+}
+
+func (recorder *dependencyRecorder) TrackReference(ref tracker.Reference, obj interface{}) error {
+	recorder.tracked = append(recorder.tracked, ref)
+	return recorder.Interface.TrackReference(ref, obj)
+}
+
+func (recorder *dependencyRecorder) record(dep dependency, obj versioned, err error) {
+	version, err := objectVersion(obj, err)
+	recorder.set(dep, version, err)
+}
+
+func (recorder *dependencyRecorder) recordList(dep dependency, configMaps []*corev1.ConfigMap, err error) {
+	version, err := listVersion(configMaps, err)
+	recorder.set(dep, version, err)
+}
+
+func (recorder *dependencyRecorder) set(dep dependency, version string, err error) {
+	if err != nil {
+		recorder.failed = true
+		return
+	}
+	recorder.dependencies[dep] = version
+}
+
+type versioned interface {
+	GetResourceVersion() string
+}
+
+// objectVersion returns the resource version of the object, or an empty version if it
+// wasn't found.
+func objectVersion(obj versioned, err error) (string, error) {
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return obj.GetResourceVersion(), nil
+}
+
+// listVersion returns a hash of the names and resource versions of the ConfigMaps.
+func listVersion(configMaps []*corev1.ConfigMap, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	versions := make(map[string]string, len(configMaps))
+	for _, cm := range configMaps {
+		versions[cm.Name] = cm.ResourceVersion
+	}
+	return hashMaps(versions), nil
+}
+
+// hashMaps returns a hash of the entries of the given maps.
+func hashMaps(maps ...map[string]string) string {
+	hash := sha256.New()
+	for _, m := range maps {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			hash.Write([]byte(k))
+			hash.Write([]byte{0})
+			hash.Write([]byte(m[k]))
+			hash.Write([]byte{0})
+		}
+		// Separate the maps, so that entries can't move from one to the other unnoticed.
+		hash.Write([]byte{1})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
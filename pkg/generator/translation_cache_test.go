@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestTranslateCached(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	endpoints := eps("servicens", "servicename", func(e *corev1.Endpoints) {
+		e.ResourceVersion = "1"
+	})
+	kubeclient := fake.NewSimpleClientset(ns("testns"), svc("servicens", "servicename"), endpoints)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	in := ing("testns", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Generation = 1
+	})
+	first, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+
+	// Nothing changed, so the translation is reused and the references are tracked again.
+	tracker := &pkgtest.FakeTracker{}
+	translator.tracker = tracker
+	got, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got == first)
+	assert.Assert(t, len(tracker.References()) != 0)
+
+	// Changes to the Ingress' generation, annotations and to the config are picked up.
+	in.Generation = 2
+	second, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, second != first)
+
+	in.Annotations = map[string]string{pkgconfig.StickyCanaryTTLAnnotationKey: "10m"}
+	third, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, third != second)
+
+	otherCtx := (&testConfigStore{config: cfg.DeepCopy()}).ToContext(context.Background())
+	fourth, err := translator.translate(otherCtx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, fourth != third)
+
+	// So are changes to the objects the Ingress was translated from.
+	endpoints.ResourceVersion = "2"
+	_, err = kubeclient.CoreV1().Endpoints("servicens").Update(ctx, endpoints, metav1.UpdateOptions{})
+	assert.NilError(t, err)
+	fifth, err := translator.translate(otherCtx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, fifth != fourth)
+
+	got, err = translator.translate(otherCtx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got == fifth)
+
+	// Forgotten translations are not reused.
+	translator.ForgetIngress(types.NamespacedName{Namespace: "testns", Name: "testname"})
+	got, err = translator.translate(otherCtx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got != fifth)
+}
+
+func TestTranslateCachedPods(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Kourier.EndpointHealthStatus = true
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+	endpoints := eps("servicens", "servicename", func(e *corev1.Endpoints) {
+		e.Subsets[0].Addresses[0].TargetRef = &corev1.ObjectReference{Kind: "Pod", Namespace: "servicens", Name: "pod"}
+	})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "servicens", Name: "pod", ResourceVersion: "1"},
+	}
+	kubeclient := fake.NewSimpleClientset(ns("testns"), svc("servicens", "servicename"), endpoints, pod)
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+	translator.podGetter = func(ns, name string) (*corev1.Pod, error) {
+		return kubeclient.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+	}
+
+	in := ing("testns", "testname", func(ing *v1alpha1.Ingress) {
+		ing.Generation = 1
+	})
+	first, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+
+	got, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got == first)
+
+	// The health status of the endpoints follows the pods behind them.
+	pod.ResourceVersion = "2"
+	_, err = kubeclient.CoreV1().Pods("servicens").Update(ctx, pod, metav1.UpdateOptions{})
+	assert.NilError(t, err)
+	got, err = translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got != first)
+}
+
+func TestTranslateWithoutGeneration(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	kubeclient := fake.NewSimpleClientset(svc("servicens", "servicename"), eps("servicens", "servicename"))
+
+	translator := NewIngressTranslator(
+		func(ns, name string) (*corev1.Secret, error) {
+			return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Endpoints, error) {
+			return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(ns, name string) (*corev1.Service, error) {
+			return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		},
+		func(name string) (*corev1.Namespace, error) {
+			return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+		configMapsGetter(ctx, kubeclient),
+		&pkgtest.FakeTracker{},
+	)
+
+	// Without a generation, the Ingress might have changed unnoticed.
+	in := ing("testns", "testname")
+	first, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	got, err := translator.translate(ctx, in, false)
+	assert.NilError(t, err)
+	assert.Assert(t, got != first)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/control-protocol/pkg/certificates"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	netconfig "knative.dev/networking/pkg/config"
+	"knative.dev/pkg/tracker"
+)
+
+// trustBundleLabels are the labels of the ConfigMaps holding trust bundles.
+var trustBundleLabels = map[string]string{pkgconfig.TrustBundleLabelKey: "true"}
+
+// upstreamCACertificates returns the CA certificates to verify upstreams with. These are
+// the CA of the internal serving certificate and the trust bundles of all labeled
+// ConfigMaps in the serving namespace. Both are tracked, so rotating CAs are picked up.
+func (translator *IngressTranslator) upstreamCACertificates(ingress *v1alpha1.Ingress) ([]byte, error) {
+	namespace := pkgconfig.ServingNamespace()
+
+	if err := trackSecret(translator.tracker, namespace, netconfig.ServingInternalCertName, ingress); err != nil {
+		return nil, err
+	}
+	if err := translator.tracker.TrackReference(tracker.Reference{
+		Kind:       "ConfigMap",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Selector:   &metav1.LabelSelector{MatchLabels: trustBundleLabels},
+	}, ingress); err != nil {
+		return nil, fmt.Errorf("could not track trust bundle references: %w", err)
+	}
+
+	var bundle bytes.Buffer
+	caSecret, err := translator.secretGetter(namespace, netconfig.ServingInternalCertName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to fetch activator CA secret: %w", err)
+	}
+	if err == nil {
+		appendPEM(&bundle, caSecret.Data[certificates.SecretCaCertKey])
+	}
+
+	configMaps, err := translator.configMapsGetter(namespace, labels.SelectorFromSet(trustBundleLabels))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trust bundles: %w", err)
+	}
+	// Sort to generate a stable configuration.
+	sort.Slice(configMaps, func(i, j int) bool {
+		return configMaps[i].Name < configMaps[j].Name
+	})
+	for _, cm := range configMaps {
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			appendPEM(&bundle, []byte(cm.Data[key]))
+		}
+	}
+
+	if bundle.Len() == 0 {
+		return nil, fmt.Errorf("no CA certificates found in secret '%s/%s' or trust bundles",
+			namespace, netconfig.ServingInternalCertName)
+	}
+	return bundle.Bytes(), nil
+}
+
+// appendPEM appends the given PEM data to the bundle, making sure that consecutive
+// blocks are separated by a newline.
+func appendPEM(bundle *bytes.Buffer, pem []byte) {
+	if len(bytes.TrimSpace(pem)) == 0 {
+		return
+	}
+	if b := bundle.Bytes(); len(b) != 0 && b[len(b)-1] != '\n' {
+		bundle.WriteByte('\n')
+	}
+	bundle.Write(pem)
+}
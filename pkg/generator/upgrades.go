@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+const (
+	// upgradesNone disallows all upgrades on the routes of an Ingress.
+	upgradesNone = "none"
+
+	// upgradeConnect is the upgrade type of CONNECT requests.
+	upgradeConnect = "CONNECT"
+
+	// upgradeWebSocket is the upgrade type of WebSockets.
+	upgradeWebSocket = "websocket"
+)
+
+// upgrades are the upgrades allowed on the routes of an Ingress, replacing the default.
+type upgrades struct {
+	types []string
+}
+
+// upgradesFromAnnotations returns the upgrades allowed on the routes of the Ingress, as
+// specified via annotations on it. Returns nil if none are specified, which keeps the
+// default.
+func upgradesFromAnnotations(annotations map[string]string) (*upgrades, error) {
+	raw := pkgconfig.GetUpgrades(annotations)
+	if raw == "" {
+		return nil, nil
+	}
+	if strings.TrimSpace(raw) == upgradesNone {
+		return &upgrades{}, nil
+	}
+
+	u := &upgrades{}
+	for _, upgradeType := range strings.Split(raw, ",") {
+		upgradeType = strings.TrimSpace(upgradeType)
+		if errs := validation.IsHTTPHeaderName(upgradeType); len(errs) != 0 || strings.EqualFold(upgradeType, upgradesNone) {
+			return nil, fmt.Errorf("invalid %s annotation: %q is not an upgrade type", pkgconfig.UpgradesAnnotationKey, upgradeType)
+		}
+		if strings.EqualFold(upgradeType, upgradeConnect) {
+			upgradeType = upgradeConnect
+		}
+		u.types = append(u.types, upgradeType)
+	}
+	return u, nil
+}
+
+// apply allows the upgrades on all routes of the VirtualHost. CONNECT requests are routed
+// like the requests of any path.
+func (u *upgrades) apply(vh *route.VirtualHost) {
+	for _, upgradeType := range u.types {
+		if upgradeType == upgradeConnect {
+			envoy.AddConnectRoutes(vh)
+			break
+		}
+	}
+	envoy.SetUpgrades(vh, u.types)
+}
+
+// allows returns whether the given upgrade type is allowed.
+func (u *upgrades) allows(upgradeType string) bool {
+	for _, allowed := range u.types {
+		if strings.EqualFold(allowed, upgradeType) {
+			return true
+		}
+	}
+	return false
+}
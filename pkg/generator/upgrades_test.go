@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestUpgradesFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       *upgrades
+		wantErr    bool
+	}{{
+		name: "no annotation",
+	}, {
+		name:       "none",
+		annotation: "none",
+		want:       &upgrades{},
+	}, {
+		name:       "upgrades",
+		annotation: "websocket, connect",
+		want:       &upgrades{types: []string{"websocket", "CONNECT"}},
+	}, {
+		name:       "none among others",
+		annotation: "websocket,none",
+		wantErr:    true,
+	}, {
+		name:       "empty upgrade type",
+		annotation: "websocket,",
+		wantErr:    true,
+	}, {
+		name:       "invalid upgrade type",
+		annotation: "web socket",
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.annotation != "" {
+				annotations[pkgconfig.UpgradesAnnotationKey] = test.annotation
+			}
+
+			got, err := upgradesFromAnnotations(annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(upgrades{}))
+		})
+	}
+}
+
+func TestUpgradesApply(t *testing.T) {
+	newVirtualHost := func() *route.VirtualHost {
+		return envoy.NewVirtualHost("test", []string{"foo"}, []*route.Route{
+			envoy.NewRoute("route", nil, "/", nil, 0, nil, ""),
+		})
+	}
+
+	vh := newVirtualHost()
+	(&upgrades{types: []string{"websocket"}}).apply(vh)
+	assert.Equal(t, len(vh.Routes), 1)
+	assert.Equal(t, vh.Routes[0].GetRoute().UpgradeConfigs[0].UpgradeType, "websocket")
+
+	vh = newVirtualHost()
+	(&upgrades{types: []string{"CONNECT"}}).apply(vh)
+	assert.Equal(t, len(vh.Routes), 2)
+	for _, r := range vh.Routes {
+		assert.Equal(t, r.GetRoute().UpgradeConfigs[0].UpgradeType, "CONNECT")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"k8s.io/apimachinery/pkg/types"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// upstreamClientCertificate returns the SDS secret carrying the client certificate to
+// present to upstreams, read from the given secret. The secret is tracked, so rotated
+// certificates are picked up. It returns nil if no secret is given.
+func (translator *IngressTranslator) upstreamClientCertificate(ingress *v1alpha1.Ingress, secretRef types.NamespacedName) (*tls.Secret, error) {
+	if secretRef.Name == "" {
+		return nil, nil
+	}
+
+	if err := trackSecret(translator.tracker, secretRef.Namespace, secretRef.Name, ingress); err != nil {
+		return nil, err
+	}
+
+	secret, err := translator.secretGetter(secretRef.Namespace, secretRef.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch upstream client certificate secret: %w", err)
+	}
+	certificateChain, privateKey := secret.Data[certFieldInSecret], secret.Data[keyFieldInSecret]
+	if len(certificateChain) == 0 || len(privateKey) == 0 {
+		return nil, fmt.Errorf("upstream client certificate secret '%s' needs both %q and %q fields",
+			secretRef, certFieldInSecret, keyFieldInSecret)
+	}
+
+	return envoy.NewSecret(envoy.SecretName(secretRef), certificateChain, privateKey), nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+// upstreamProtocol are the protocol options of the connections to the backends of an
+// Ingress, overriding the configured ones. Each one is left to the config if unset.
+type upstreamProtocol struct {
+	http2MaxConcurrentStreams        *uint32
+	http2InitialStreamWindowSize     *uint32
+	http2InitialConnectionWindowSize *uint32
+	http1EnableTrailers              *bool
+}
+
+// upstreamProtocolFromAnnotations returns the protocol options of the connections to the
+// backends of the Ingress, as specified via annotations on it.
+func upstreamProtocolFromAnnotations(annotations map[string]string) (*upstreamProtocol, error) {
+	p := &upstreamProtocol{}
+	for _, setting := range []struct {
+		key   string
+		raw   string
+		min   uint32
+		value **uint32
+	}{
+		{pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey, pkgconfig.GetUpstreamHTTP2MaxConcurrentStreams(annotations), 1, &p.http2MaxConcurrentStreams},
+		{pkgconfig.UpstreamHTTP2InitialStreamWindowSizeAnnotationKey, pkgconfig.GetUpstreamHTTP2InitialStreamWindowSize(annotations), pkgconfig.MinHTTP2WindowSize, &p.http2InitialStreamWindowSize},
+		{pkgconfig.UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey, pkgconfig.GetUpstreamHTTP2InitialConnectionWindowSize(annotations), pkgconfig.MinHTTP2WindowSize, &p.http2InitialConnectionWindowSize},
+	} {
+		if setting.raw == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(setting.raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", setting.key, err)
+		}
+		if value < uint64(setting.min) || value > pkgconfig.MaxHTTP2Setting {
+			return nil, fmt.Errorf("invalid %s annotation: must be between %d and %d, was: %d",
+				setting.key, setting.min, pkgconfig.MaxHTTP2Setting, value)
+		}
+		v := uint32(value)
+		*setting.value = &v
+	}
+
+	if raw := pkgconfig.GetUpstreamHTTP1EnableTrailers(annotations); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey, err)
+		}
+		p.http1EnableTrailers = &enabled
+	}
+	return p, nil
+}
+
+// apply returns a copy of the given config with the protocol options of the connections
+// to upstreams overridden.
+func (p *upstreamProtocol) apply(kourierConfig *pkgconfig.Kourier) *pkgconfig.Kourier {
+	if *p == (upstreamProtocol{}) {
+		return kourierConfig
+	}
+
+	c := *kourierConfig
+	if p.http2MaxConcurrentStreams != nil {
+		c.UpstreamHTTP2MaxConcurrentStreams = *p.http2MaxConcurrentStreams
+	}
+	if p.http2InitialStreamWindowSize != nil {
+		c.UpstreamHTTP2InitialStreamWindowSize = *p.http2InitialStreamWindowSize
+	}
+	if p.http2InitialConnectionWindowSize != nil {
+		c.UpstreamHTTP2InitialConnectionWindowSize = *p.http2InitialConnectionWindowSize
+	}
+	if p.http1EnableTrailers != nil {
+		c.UpstreamHTTP1EnableTrailers = *p.http1EnableTrailers
+	}
+	return &c
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+)
+
+func TestUpstreamProtocolFromAnnotations(t *testing.T) {
+	kourierConfig := &pkgconfig.Kourier{
+		UpstreamHTTP2MaxConcurrentStreams:    100,
+		UpstreamHTTP2InitialStreamWindowSize: 1048576,
+		UpstreamHTTP1EnableTrailers:          true,
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *pkgconfig.Kourier
+		wantErr     bool
+	}{{
+		name: "no annotations",
+		want: kourierConfig,
+	}, {
+		name: "overrides",
+		annotations: map[string]string{
+			pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey:        "1000",
+			pkgconfig.UpstreamHTTP2InitialConnectionWindowSizeAnnotationKey: "16777216",
+			pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey:              "false",
+		},
+		want: &pkgconfig.Kourier{
+			UpstreamHTTP2MaxConcurrentStreams:        1000,
+			UpstreamHTTP2InitialStreamWindowSize:     1048576,
+			UpstreamHTTP2InitialConnectionWindowSize: 16777216,
+		},
+	}, {
+		name:        "not a number",
+		annotations: map[string]string{pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "many"},
+		wantErr:     true,
+	}, {
+		name:        "no concurrent streams",
+		annotations: map[string]string{pkgconfig.UpstreamHTTP2MaxConcurrentStreamsAnnotationKey: "0"},
+		wantErr:     true,
+	}, {
+		name:        "window size too small",
+		annotations: map[string]string{pkgconfig.UpstreamHTTP2InitialStreamWindowSizeAnnotationKey: "1024"},
+		wantErr:     true,
+	}, {
+		name:        "invalid trailers",
+		annotations: map[string]string{pkgconfig.UpstreamHTTP1EnableTrailersAnnotationKey: "maybe"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := upstreamProtocolFromAnnotations(test.annotations)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got.apply(kourierConfig), test.want)
+		})
+	}
+
+	// The config itself isn't modified.
+	assert.Equal(t, kourierConfig.UpstreamHTTP2MaxConcurrentStreams, uint32(100))
+}
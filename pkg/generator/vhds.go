@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/util/sets"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// vhdsRouteConfigNames are the route configurations serving the external hosts, whose
+// virtual hosts are served via VHDS if enabled.
+var vhdsRouteConfigNames = sets.NewString(externalRouteConfigName, externalTLSRouteConfigName)
+
+// withVirtualHostDiscovery replaces the route configurations serving the external hosts
+// with ones requesting their virtual hosts on demand via VHDS. It returns the resulting
+// route configurations, the virtual hosts to serve via VHDS and the TTLs of the virtual
+// hosts of the hosts with a TTL, keyed by their resource name.
+func withVirtualHostDiscovery(routes []cachetypes.Resource, hostTTLs map[string]time.Duration) ([]cachetypes.Resource, []cachetypes.Resource, map[string]time.Duration) {
+	var vhosts []cachetypes.Resource
+	ttls := make(map[string]time.Duration)
+	for i, res := range routes {
+		routeConfig := res.(*route.RouteConfiguration)
+		if !vhdsRouteConfigNames.Has(routeConfig.Name) {
+			continue
+		}
+
+		for _, vhost := range virtualHostResources(routeConfig) {
+			vhost := vhost.(*route.VirtualHost)
+			if ttl, ok := hostTTLs[baseHost(vhost.Domains[0])]; ok {
+				ttls[vhost.Name] = ttl
+			}
+			vhosts = append(vhosts, vhost)
+		}
+		routes[i] = envoy.NewVHDSRouteConfig(routeConfig.Name)
+	}
+	return routes, vhosts, ttls
+}
+
+// virtualHostResources splits the virtual hosts of the route configuration into one
+// VHDS resource per host, as the gateways request them by the host of the requests. The
+// resource of a host also serves the host with any port.
+func virtualHostResources(routeConfig *route.RouteConfiguration) []cachetypes.Resource {
+	resources := make([]cachetypes.Resource, 0, len(routeConfig.VirtualHosts))
+	for _, vhost := range routeConfig.VirtualHosts {
+		domainsPerHost := make(map[string][]string, len(vhost.Domains))
+		for _, domain := range vhost.Domains {
+			host := baseHost(domain)
+			domainsPerHost[host] = append(domainsPerHost[host], domain)
+		}
+
+		for _, host := range sets.StringKeySet(domainsPerHost).List() {
+			// The virtual host is cached, so it must not be modified.
+			resource := proto.Clone(vhost).(*route.VirtualHost)
+			resource.Name = envoy.VirtualHostResourceName(routeConfig.Name, host)
+			resource.Domains = domainsPerHost[host]
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
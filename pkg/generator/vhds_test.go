@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	netconfig "knative.dev/networking/pkg/config"
+)
+
+func TestVirtualHostResources(t *testing.T) {
+	routes := []*route.Route{envoy.NewRoute("foo", nil, "/", nil, 0, nil, "")}
+	vhost := envoy.NewVirtualHost("foo", []string{"foo.example.com", "foo.example.com:*", "foo.ns.svc"}, routes)
+
+	got := virtualHostResources(envoy.NewRouteConfig(externalRouteConfigName, []*route.VirtualHost{vhost}))
+
+	assert.Equal(t, len(got), 2)
+	assert.DeepEqual(t, got[0], &route.VirtualHost{
+		Name:    externalRouteConfigName + "/foo.example.com",
+		Domains: []string{"foo.example.com", "foo.example.com:*"},
+		Routes:  routes,
+	}, protocmp.Transform())
+	assert.DeepEqual(t, got[1], &route.VirtualHost{
+		Name:    externalRouteConfigName + "/foo.ns.svc",
+		Domains: []string{"foo.ns.svc"},
+		Routes:  routes,
+	}, protocmp.Transform())
+
+	// The cached virtual host is left alone.
+	assert.Equal(t, vhost.Name, "foo")
+	assert.DeepEqual(t, vhost.Domains, []string{"foo.example.com", "foo.example.com:*", "foo.ns.svc"})
+}
+
+func TestSnapshotWithVirtualHostDiscovery(t *testing.T) {
+	testConfig := &rconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{VirtualHostDiscovery: true},
+	}
+	ctx := (&testConfigStore{config: testConfig}).ToContext(context.Background())
+
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	vhost := &route.VirtualHost{Name: "foo", Domains: []string{"foo.example.com"}}
+	assert.NilError(t, caches.addTranslatedIngress(&translatedIngress{
+		name:                 types.NamespacedName{Namespace: "ns", Name: "foo"},
+		externalVirtualHosts: []*route.VirtualHost{vhost},
+		internalVirtualHosts: []*route.VirtualHost{vhost},
+	}, false))
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	routes := snapshot.GetResources(resource.RouteType)
+	vhosts := snapshot.GetResources(resource.VirtualHostType)
+
+	// The external hosts are served on demand, the internal ones as usual.
+	assert.DeepEqual(t, routes[externalRouteConfigName], envoy.NewVHDSRouteConfig(externalRouteConfigName), protocmp.Transform())
+	assert.DeepEqual(t, routes[internalRouteConfigName].(*route.RouteConfiguration).VirtualHosts[0], vhost, protocmp.Transform())
+	assert.DeepEqual(t, vhosts[externalRouteConfigName+"/foo.example.com"], &route.VirtualHost{
+		Name:    externalRouteConfigName + "/foo.example.com",
+		Domains: []string{"foo.example.com"},
+	}, protocmp.Transform())
+	assert.Equal(t, len(vhosts), 1)
+}
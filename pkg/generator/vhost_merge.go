@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+// newRouteConfig creates a RouteConfiguration with the given virtual hosts, merging the
+// ones sharing a host as Envoy rejects duplicate domains. The routes of the virtual hosts
+// with enough routes matching a header are looked up in a matcher tree, if configured.
+func newRouteConfig(name string, vhosts []*route.VirtualHost, kourierConfig *config.Kourier) *route.RouteConfiguration {
+	merged := mergeVirtualHosts(vhosts)
+	if minRoutes := int(kourierConfig.HeaderMatcherTreeMinRoutes); minRoutes != 0 {
+		for i, vhost := range merged {
+			merged[i] = envoy.WithHeaderMatcherTree(vhost, minRoutes)
+		}
+	}
+	return envoy.NewRouteConfig(name, merged)
+}
+
+// withUnmatchedHost returns the given virtual hosts along with the catch-all one answering
+// the requests matching none of them, if their response is customized in the given config.
+func withUnmatchedHost(vhosts []*route.VirtualHost, kourierConfig *config.Kourier) []*route.VirtualHost {
+	unmatched := envoy.NewUnmatchedHostVirtualHost(kourierConfig)
+	if unmatched == nil {
+		return vhosts
+	}
+	// The given virtual hosts are shared, so they must not be appended to in place.
+	return append(vhosts[:len(vhosts):len(vhosts)], unmatched)
+}
+
+// mergeVirtualHosts merges the routes of the virtual hosts sharing a host into a single
+// virtual host per shared host. The virtual hosts not sharing any host are kept as is.
+//
+// The merged routes are ordered by precedence: longer paths first, then routes with
+// more header and query parameter matchers, then by their name.
+func mergeVirtualHosts(vhosts []*route.VirtualHost) []*route.VirtualHost {
+	hostCount := make(map[string]int)
+	for _, vhost := range vhosts {
+		for _, host := range baseHosts(vhost.Domains).UnsortedList() {
+			hostCount[host]++
+		}
+	}
+
+	merged := make([]*route.VirtualHost, 0, len(vhosts))
+	mergedByHost := make(map[string]*route.VirtualHost)
+	for _, vhost := range vhosts {
+		var shared, own []string
+		for _, domain := range vhost.Domains {
+			if hostCount[baseHost(domain)] > 1 {
+				shared = append(shared, domain)
+			} else {
+				own = append(own, domain)
+			}
+		}
+		if len(shared) == 0 {
+			merged = append(merged, vhost)
+			continue
+		}
+
+		// The virtual host is cached, so it must not be modified.
+		if len(own) != 0 {
+			rest := proto.Clone(vhost).(*route.VirtualHost)
+			rest.Domains = own
+			merged = append(merged, rest)
+		}
+
+		for _, domain := range shared {
+			host := baseHost(domain)
+			target := mergedByHost[host]
+			if target == nil {
+				target = &route.VirtualHost{Name: host}
+				mergedByHost[host] = target
+				merged = append(merged, target)
+			}
+			target.Domains = append(target.Domains, domain)
+		}
+
+		routes := routesWithVirtualHostConfig(vhost)
+		for _, host := range baseHosts(shared).List() {
+			target := mergedByHost[host]
+			target.Routes = append(target.Routes, routes...)
+			target.IncludeRequestAttemptCount = target.IncludeRequestAttemptCount || vhost.IncludeRequestAttemptCount
+		}
+	}
+
+	for _, vhost := range mergedByHost {
+		vhost.Domains = sets.NewString(vhost.Domains...).List()
+		sortRoutesByPrecedence(vhost.Routes)
+	}
+	return merged
+}
+
+// routesWithVirtualHostConfig returns the routes of the virtual host, carrying the
+// per-filter config of the virtual host themselves unless they override it.
+func routesWithVirtualHostConfig(vhost *route.VirtualHost) []*route.Route {
+	if len(vhost.TypedPerFilterConfig) == 0 {
+		return vhost.Routes
+	}
+
+	routes := make([]*route.Route, 0, len(vhost.Routes))
+	for _, r := range vhost.Routes {
+		r = proto.Clone(r).(*route.Route)
+		for name, config := range vhost.TypedPerFilterConfig {
+			if _, ok := r.TypedPerFilterConfig[name]; !ok {
+				if r.TypedPerFilterConfig == nil {
+					r.TypedPerFilterConfig = make(map[string]*anypb.Any, len(vhost.TypedPerFilterConfig))
+				}
+				r.TypedPerFilterConfig[name] = config
+			}
+		}
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+func sortRoutesByPrecedence(routes []*route.Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		pi, pj := routes[i].GetMatch().GetPrefix(), routes[j].GetMatch().GetPrefix()
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		mi, mj := matcherCount(routes[i]), matcherCount(routes[j])
+		if mi != mj {
+			return mi > mj
+		}
+		return routes[i].Name < routes[j].Name
+	})
+}
+
+func matcherCount(r *route.Route) int {
+	return len(r.GetMatch().GetHeaders()) + len(r.GetMatch().GetQueryParameters())
+}
+
+// hasConflictingRoutes returns whether the given virtual host of an ingress has a route
+// matching the same requests as a route of another ingress sharing one of its hosts.
+func (caches *Caches) hasConflictingRoutes(name types.NamespacedName, vhost *route.VirtualHost) bool {
+	hosts := baseHosts(vhost.Domains)
+	for otherName, other := range caches.translatedIngresses {
+		if otherName == name {
+			continue
+		}
+		for _, otherVHost := range other.internalVirtualHosts {
+			if !hosts.HasAny(baseHosts(otherVHost.Domains).UnsortedList()...) {
+				continue
+			}
+			for _, r := range vhost.Routes {
+				for _, otherRoute := range otherVHost.Routes {
+					if proto.Equal(r.GetMatch(), otherRoute.GetMatch()) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// baseHost returns the host of the domain, without the ":*" port wildcard.
+func baseHost(domain string) string {
+	return strings.TrimSuffix(domain, ":*")
+}
+
+func baseHosts(domains []string) sets.String {
+	hosts := make(sets.String, len(domains))
+	for _, domain := range domains {
+		hosts.Insert(baseHost(domain))
+	}
+	return hosts
+}
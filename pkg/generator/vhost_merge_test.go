@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+)
+
+func TestMergeVirtualHosts(t *testing.T) {
+	newRoute := func(name, path string) *route.Route {
+		return envoy.NewRoute(name, nil, path, nil, 0, nil, "")
+	}
+	foo := envoy.NewVirtualHost("foo", []string{"shared.example.com", "shared.example.com:*", "foo.example.com"},
+		[]*route.Route{newRoute("foo-root", "/")})
+	bar := envoy.NewVirtualHostWithExtAuthz("bar", map[string]string{"client": "kourier"},
+		[]string{"shared.example.com", "shared.example.com:*"},
+		[]*route.Route{newRoute("bar-api", "/api"), newRoute("bar-api-v2", "/api/v2")})
+	baz := envoy.NewVirtualHost("baz", []string{"baz.example.com"}, []*route.Route{newRoute("baz-root", "/")})
+
+	got := mergeVirtualHosts([]*route.VirtualHost{foo, bar, baz})
+
+	assert.Equal(t, len(got), 3)
+	assert.DeepEqual(t, got[0].Domains, []string{"foo.example.com"})
+	assert.DeepEqual(t, got[0].Routes, foo.Routes, protocmp.Transform())
+	assert.Equal(t, got[2], baz)
+
+	shared := got[1]
+	assert.Equal(t, shared.Name, "shared.example.com")
+	assert.DeepEqual(t, shared.Domains, []string{"shared.example.com", "shared.example.com:*"})
+	names := make([]string, 0, len(shared.Routes))
+	for _, r := range shared.Routes {
+		names = append(names, r.Name)
+	}
+	assert.DeepEqual(t, names, []string{"bar-api-v2", "bar-api", "foo-root"})
+
+	// The routes carry the per-filter config of their virtual host.
+	assert.Assert(t, shared.Routes[0].TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] != nil)
+	assert.Assert(t, shared.Routes[2].TypedPerFilterConfig == nil)
+
+	// The cached virtual hosts are left alone.
+	assert.DeepEqual(t, foo.Domains, []string{"shared.example.com", "shared.example.com:*", "foo.example.com"})
+	assert.Assert(t, bar.Routes[0].TypedPerFilterConfig == nil)
+}
+
+func TestAddIngressSharingHost(t *testing.T) {
+	caches, err := NewCaches(context.Background(), &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	translated := func(name, path string) *translatedIngress {
+		vhosts := []*route.VirtualHost{envoy.NewVirtualHost(name, []string{"shared.example.com"},
+			[]*route.Route{envoy.NewRoute(name, nil, path, nil, 0, nil, "")})}
+		return &translatedIngress{
+			name:                 types.NamespacedName{Namespace: "ns", Name: name},
+			internalVirtualHosts: vhosts,
+			externalVirtualHosts: vhosts,
+		}
+	}
+
+	assert.NilError(t, caches.addTranslatedIngress(translated("foo", "/"), true))
+
+	// Sharing the host is rejected unless enabled.
+	assert.Error(t, caches.addTranslatedIngress(translated("bar", "/api"), false), ErrDomainConflict.Error())
+	assert.NilError(t, caches.addTranslatedIngress(translated("bar", "/api"), true))
+
+	// Routes matching the same requests are still rejected.
+	assert.Error(t, caches.addTranslatedIngress(translated("baz", "/api"), true), ErrDomainConflict.Error())
+
+	// The host stays in use until all ingresses sharing it are gone.
+	caches.deleteTranslatedIngress("foo", "ns")
+	assert.Assert(t, caches.anyDomainInUse([]string{"shared.example.com"}))
+	caches.deleteTranslatedIngress("bar", "ns")
+	assert.Assert(t, !caches.anyDomainInUse([]string{"shared.example.com"}))
+}
+
+func TestNewRouteConfigWithHeaderMatcherTree(t *testing.T) {
+	routes := make([]*route.Route, 0, 3)
+	for _, tag := range []string{"blue", "green"} {
+		routes = append(routes, envoy.NewRoute(tag, []*route.HeaderMatcher{{
+			Name:                 "Knative-Serving-Tag",
+			HeaderMatchSpecifier: &route.HeaderMatcher_ExactMatch{ExactMatch: tag},
+		}}, "/", nil, 0, nil, ""))
+	}
+	routes = append(routes, envoy.NewRoute("default", nil, "/", nil, 0, nil, ""))
+	tagged := envoy.NewVirtualHost("tagged", []string{"hello.example.com"}, routes)
+	plain := envoy.NewVirtualHost("plain", []string{"world.example.com"}, routes[2:])
+
+	kourierConfig := pkgconfig.DefaultConfig()
+	routeConfig := newRouteConfig("test", []*route.VirtualHost{tagged, plain}, kourierConfig)
+	assert.Equal(t, len(routeConfig.VirtualHosts[0].Routes), 3)
+	assert.Assert(t, routeConfig.VirtualHosts[0].Matcher == nil)
+
+	// Only the virtual hosts with enough tagged routes are looked up in a matcher tree.
+	kourierConfig.HeaderMatcherTreeMinRoutes = 2
+	routeConfig = newRouteConfig("test", []*route.VirtualHost{tagged, plain}, kourierConfig)
+	assert.Equal(t, len(routeConfig.VirtualHosts[0].Routes), 0)
+	assert.Equal(t, len(routeConfig.VirtualHosts[0].Matcher.GetMatcherTree().GetExactMatchMap().Map), 2)
+	assert.Equal(t, routeConfig.VirtualHosts[1], plain)
+
+	// The cached virtual hosts aren't modified.
+	assert.Equal(t, len(tagged.Routes), 3)
+}
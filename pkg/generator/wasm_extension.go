@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// wasmModuleFieldInSecret is the field of the secret referenced by the
+	// WasmExtensionAnnotationKey annotation holding the Wasm module.
+	wasmModuleFieldInSecret = "module.wasm"
+
+	// wasmModulePathFieldInSecret is the field of the secret holding the path of the Wasm
+	// module on the gateways instead, e.g. of a volume mounted from an OCI image.
+	wasmModulePathFieldInSecret = "module-path"
+
+	// wasmConfigFieldInSecret is the field of the secret holding the configuration handed
+	// to the Wasm module.
+	wasmConfigFieldInSecret = "config"
+)
+
+// wasmExtension returns the Wasm extension requested by the annotations of the given
+// ingress, or nil if it doesn't request one or Wasm extensions aren't enabled. Its
+// domains are left to be filled in once the virtual hosts of the ingress are known.
+func (translator *IngressTranslator) wasmExtension(ctx context.Context, ingress *v1alpha1.Ingress) (*envoy.WasmExtension, error) {
+	secretName := pkgconfig.GetWasmExtension(ingress.Annotations)
+	if secretName == "" || !config.FromContextOrDefaults(ctx).Kourier.WasmExtensions {
+		return nil, nil
+	}
+
+	if err := trackSecret(translator.tracker, ingress.Namespace, secretName, ingress); err != nil {
+		return nil, err
+	}
+	secret, err := translator.secretGetter(ingress.Namespace, secretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Wasm extension secret: %w", err)
+	}
+
+	module := secret.Data[wasmModuleFieldInSecret]
+	modulePath := string(secret.Data[wasmModulePathFieldInSecret])
+	if (len(module) == 0) == (modulePath == "") {
+		return nil, fmt.Errorf("wasm extension secret '%s/%s' must have either a %q or a %q field",
+			ingress.Namespace, secretName, wasmModuleFieldInSecret, wasmModulePathFieldInSecret)
+	}
+	if modulePath != "" && (!filepath.IsAbs(modulePath) || filepath.Clean(modulePath) != modulePath) {
+		return nil, fmt.Errorf("wasm extension secret '%s/%s' has an invalid %q field: %q is not a clean absolute path",
+			ingress.Namespace, secretName, wasmModulePathFieldInSecret, modulePath)
+	}
+
+	return &envoy.WasmExtension{
+		Name:          "kourier.wasm." + ingress.Namespace + "." + ingress.Name,
+		Module:        module,
+		ModulePath:    modulePath,
+		Configuration: string(secret.Data[wasmConfigFieldInSecret]),
+	}, nil
+}
+
+// virtualHostDomains returns the sorted domains of the given virtual hosts.
+func virtualHostDomains(vhosts ...[]*route.VirtualHost) []string {
+	domains := sets.NewString()
+	for _, hosts := range vhosts {
+		for _, vhost := range hosts {
+			domains.Insert(vhost.Domains...)
+		}
+	}
+	return domains.List()
+}
+
+// addWasmExtensions adds the filters of the given Wasm extensions to the HTTP listeners.
+// They're sorted by name to generate a stable configuration.
+func addWasmExtensions(listeners []cachetypes.Resource, extensions []*envoy.WasmExtension) error {
+	sort.Slice(extensions, func(i, j int) bool {
+		return extensions[i].Name < extensions[j].Name
+	})
+	for _, l := range listeners {
+		if err := envoy.AddWasmExtensions(l.(*v3.Listener), extensions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	pkgtest "knative.dev/pkg/reconciler/testing"
+)
+
+func TestIngressTranslatorWasmExtension(t *testing.T) {
+	extended := func(ing *v1alpha1.Ingress) {
+		ing.Annotations = map[string]string{config.WasmExtensionAnnotationKey: "extension"}
+	}
+
+	tests := []struct {
+		name     string
+		in       *v1alpha1.Ingress
+		disabled bool
+		data     map[string][]byte
+		want     *envoy.WasmExtension
+		wantErr  bool
+	}{{
+		name: "inline module",
+		in:   ing("testspace", "testname", extended),
+		data: map[string][]byte{
+			wasmModuleFieldInSecret: []byte("\x00asm"),
+			wasmConfigFieldInSecret: []byte(`{"greeting":"hi"}`),
+		},
+		want: &envoy.WasmExtension{
+			Name:          "kourier.wasm.testspace.testname",
+			Domains:       []string{"foo.example.com", "foo.example.com:*"},
+			Module:        []byte("\x00asm"),
+			Configuration: `{"greeting":"hi"}`,
+		},
+	}, {
+		name: "module path",
+		in:   ing("testspace", "testname", extended),
+		data: map[string][]byte{wasmModulePathFieldInSecret: []byte("/wasm/module.wasm")},
+		want: &envoy.WasmExtension{
+			Name:       "kourier.wasm.testspace.testname",
+			Domains:    []string{"foo.example.com", "foo.example.com:*"},
+			ModulePath: "/wasm/module.wasm",
+		},
+	}, {
+		name: "not requested",
+		in:   ing("testspace", "testname"),
+		data: map[string][]byte{wasmModuleFieldInSecret: []byte("\x00asm")},
+	}, {
+		name:     "extensions disabled",
+		in:       ing("testspace", "testname", extended),
+		disabled: true,
+		data:     map[string][]byte{wasmModuleFieldInSecret: []byte("\x00asm")},
+	}, {
+		name:    "no module",
+		in:      ing("testspace", "testname", extended),
+		data:    map[string][]byte{wasmConfigFieldInSecret: []byte("{}")},
+		wantErr: true,
+	}, {
+		name: "both module and path",
+		in:   ing("testspace", "testname", extended),
+		data: map[string][]byte{
+			wasmModuleFieldInSecret:     []byte("\x00asm"),
+			wasmModulePathFieldInSecret: []byte("/wasm/module.wasm"),
+		},
+		wantErr: true,
+	}, {
+		name:    "relative path",
+		in:      ing("testspace", "testname", extended),
+		data:    map[string][]byte{wasmModulePathFieldInSecret: []byte("../module.wasm")},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig.DeepCopy()
+			cfg.Kourier.WasmExtensions = !test.disabled
+			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+
+			kubeclient := fake.NewSimpleClientset(
+				ns("testspace"), svc("servicens", "servicename"), eps("servicens", "servicename"),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "testspace", Name: "extension"},
+					Data:       test.data,
+				},
+			)
+			translator := NewIngressTranslator(
+				func(ns, name string) (*corev1.Secret, error) {
+					return kubeclient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Endpoints, error) {
+					return kubeclient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(ns, name string) (*corev1.Service, error) {
+					return kubeclient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+				},
+				func(name string) (*corev1.Namespace, error) {
+					return kubeclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+				},
+				configMapsGetter(ctx, kubeclient),
+				&pkgtest.FakeTracker{},
+			)
+
+			got, err := translator.translateIngress(ctx, test.in, false)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got.wasmExtension, test.want)
+		})
+	}
+}
+
+func TestSnapshotWithWasmExtensions(t *testing.T) {
+	ctx := (&testConfigStore{config: defaultConfig.DeepCopy()}).ToContext(context.Background())
+	caches, err := NewCaches(ctx, &fake.Clientset{}, false)
+	assert.NilError(t, err)
+
+	translated := func(name string) *translatedIngress {
+		domains := []string{name + ".example.com"}
+		return &translatedIngress{
+			name: types.NamespacedName{Namespace: "ns", Name: name},
+			internalVirtualHosts: []*route.VirtualHost{
+				envoy.NewVirtualHost(name, domains, []*route.Route{envoy.NewRoute(name, nil, "/", nil, 0, nil, "")}),
+			},
+			wasmExtension: &envoy.WasmExtension{Name: "kourier.wasm.ns." + name, Domains: domains, ModulePath: "/" + name + ".wasm"},
+		}
+	}
+	assert.NilError(t, caches.addTranslatedIngress(translated("b"), false))
+	assert.NilError(t, caches.addTranslatedIngress(translated("a"), false))
+
+	snapshot, err := caches.ToEnvoySnapshot(ctx)
+	assert.NilError(t, err)
+
+	// Every HTTP listener runs the extensions, sorted by name, in front of the router.
+	for _, res := range snapshot.GetResources(resource.ListenerType) {
+		l := res.(*listener.Listener)
+		manager := &hcm.HttpConnectionManager{}
+		assert.NilError(t, l.FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(manager))
+		filters := manager.HttpFilters
+		assert.Equal(t, filters[len(filters)-3].Name, "kourier.wasm.ns.a", l.Name)
+		assert.Equal(t, filters[len(filters)-2].Name, "kourier.wasm.ns.b", l.Name)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// defaultWebSocketIdleTimeout is the idle timeout of the WebSockets of the WebSocket-only
+// paths if none is specified. It exceeds the default stream idle timeout, as WebSockets
+// are commonly silent for a while.
+const defaultWebSocketIdleTimeout = time.Hour
+
+// webSocketOnly are the paths of an Ingress which only serve WebSocket upgrades, keeping
+// the plain requests off these streaming endpoints.
+type webSocketOnly struct {
+	paths       []string
+	idleTimeout time.Duration
+}
+
+// webSocketOnlyFromAnnotations returns the WebSocket-only paths of the Ingress, as
+// specified via annotations on it. Returns nil if there are none. The paths must be the
+// ones of the Ingress, and WebSockets must be allowed by the given upgrades.
+func webSocketOnlyFromAnnotations(ingress *v1alpha1.Ingress, upgrades *upgrades) (*webSocketOnly, error) {
+	rawPaths := pkgconfig.GetWebSocketOnlyPaths(ingress.Annotations)
+	rawTimeout := pkgconfig.GetWebSocketIdleTimeout(ingress.Annotations)
+	if rawPaths == "" {
+		if rawTimeout != "" {
+			return nil, fmt.Errorf("invalid %s annotation: requires the %s annotation",
+				pkgconfig.WebSocketIdleTimeoutAnnotationKey, pkgconfig.WebSocketOnlyPathsAnnotationKey)
+		}
+		return nil, nil
+	}
+
+	if upgrades != nil && !upgrades.allows(upgradeWebSocket) {
+		return nil, fmt.Errorf("invalid %s annotation: WebSockets aren't allowed by the %s annotation",
+			pkgconfig.WebSocketOnlyPathsAnnotationKey, pkgconfig.UpgradesAnnotationKey)
+	}
+
+	ingressPaths := sets.NewString()
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, httpPath := range rule.HTTP.Paths {
+			path := httpPath.Path
+			if path == "" {
+				path = "/"
+			}
+			ingressPaths.Insert(path)
+		}
+	}
+
+	w := &webSocketOnly{idleTimeout: defaultWebSocketIdleTimeout}
+	seen := sets.NewString()
+	for _, path := range strings.Split(rawPaths, ",") {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid %s annotation: %q is not an absolute path", pkgconfig.WebSocketOnlyPathsAnnotationKey, path)
+		}
+		if !ingressPaths.Has(path) {
+			return nil, fmt.Errorf("invalid %s annotation: %q is not a path of the ingress", pkgconfig.WebSocketOnlyPathsAnnotationKey, path)
+		}
+		if seen.Has(path) {
+			return nil, fmt.Errorf("invalid %s annotation: duplicate path %q", pkgconfig.WebSocketOnlyPathsAnnotationKey, path)
+		}
+		seen.Insert(path)
+		w.paths = append(w.paths, path)
+	}
+
+	if rawTimeout != "" {
+		idleTimeout, err := time.ParseDuration(rawTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", pkgconfig.WebSocketIdleTimeoutAnnotationKey, err)
+		}
+		if idleTimeout <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation: must be positive, was: %v", pkgconfig.WebSocketIdleTimeoutAnnotationKey, idleTimeout)
+		}
+		w.idleTimeout = idleTimeout
+	}
+	return w, nil
+}
+
+// apply restricts the WebSocket-only paths of the VirtualHost to WebSocket upgrades. It
+// must be applied after the idle timeout of the VirtualHost is set, which it overrides.
+func (w *webSocketOnly) apply(vh *route.VirtualHost) {
+	envoy.RestrictToWebSockets(vh, w.paths, w.idleTimeout)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	pkgconfig "knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestWebSocketOnlyFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		upgrades    *upgrades
+		want        *webSocketOnly
+		wantErr     bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "paths",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "/ws, /test"},
+		want:        &webSocketOnly{paths: []string{"/ws", "/test"}, idleTimeout: time.Hour},
+	}, {
+		name: "idle timeout",
+		annotations: map[string]string{
+			pkgconfig.WebSocketOnlyPathsAnnotationKey:   "/ws",
+			pkgconfig.WebSocketIdleTimeoutAnnotationKey: "30m",
+		},
+		want: &webSocketOnly{paths: []string{"/ws"}, idleTimeout: 30 * time.Minute},
+	}, {
+		name:        "allowed by upgrades",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "/ws"},
+		upgrades:    &upgrades{types: []string{"WebSocket", upgradeConnect}},
+		want:        &webSocketOnly{paths: []string{"/ws"}, idleTimeout: time.Hour},
+	}, {
+		name:        "disallowed by upgrades",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "/ws"},
+		upgrades:    &upgrades{},
+		wantErr:     true,
+	}, {
+		name:        "idle timeout without paths",
+		annotations: map[string]string{pkgconfig.WebSocketIdleTimeoutAnnotationKey: "30m"},
+		wantErr:     true,
+	}, {
+		name:        "relative path",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "ws"},
+		wantErr:     true,
+	}, {
+		name:        "unknown path",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "/events"},
+		wantErr:     true,
+	}, {
+		name:        "duplicate path",
+		annotations: map[string]string{pkgconfig.WebSocketOnlyPathsAnnotationKey: "/ws,/ws"},
+		wantErr:     true,
+	}, {
+		name: "zero idle timeout",
+		annotations: map[string]string{
+			pkgconfig.WebSocketOnlyPathsAnnotationKey:   "/ws",
+			pkgconfig.WebSocketIdleTimeoutAnnotationKey: "0s",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := ing("testspace", "testname", func(ing *v1alpha1.Ingress) {
+				ing.Annotations = test.annotations
+				ws := *ing.Spec.Rules[0].HTTP.Paths[0].DeepCopy()
+				ws.Path = "/ws"
+				ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, ws)
+			})
+			got, err := webSocketOnlyFromAnnotations(in, test.upgrades)
+			if test.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want, cmp.AllowUnexported(webSocketOnly{}))
+		})
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/net-kourier/pkg/generator"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	netconfig "knative.dev/networking/pkg/config"
+)
+
+// dryRunTypes are the types of the resources written by a dry run, keyed by their name in
+// the output. The secrets are only written by name, to keep their keys out of CI logs.
+var dryRunTypes = map[string]string{
+	"clusters":      resource.ClusterType,
+	"endpoints":     resource.EndpointType,
+	"listeners":     resource.ListenerType,
+	"routes":        resource.RouteType,
+	"virtual_hosts": resource.VirtualHostType,
+}
+
+// DryRunOptions configures a dry run.
+type DryRunOptions struct {
+	// Path is the file or directory to read the manifests from.
+	Path string
+	// Fallback provides the objects referenced by the Ingresses which aren't among the
+	// manifests, e.g. from a cluster. They must all be among the manifests if nil.
+	Fallback generator.ObjectGetter
+}
+
+// DryRun translates the Ingresses found at opts.Path and writes the resulting Envoy
+// resources to w as indented JSON, sorted by name, so that routing changes can be
+// reviewed before applying them. The gateway config is read from the config-kourier and
+// config-network ConfigMaps among the manifests, and defaulted if they're missing. Unlike
+// the local mode, Ingresses conflicting with others fail the dry run.
+func DryRun(ctx context.Context, opts DryRunOptions, w io.Writer) error {
+	setSystemNamespace()
+
+	objs, err := LoadObjects(opts.Path)
+	if err != nil {
+		return err
+	}
+	cfg, err := objs.gatewayConfig()
+	if err != nil {
+		return err
+	}
+	ctx = rconfig.ToContext(ctx, cfg)
+
+	var getter generator.ObjectGetter = objs
+	if opts.Fallback != nil {
+		getter = &fallbackGetter{primary: objs, fallback: opts.Fallback}
+	}
+	snapshot, err := snapshot(ctx, objs, getter, true)
+	if err != nil {
+		return err
+	}
+
+	out := make(map[string]interface{}, len(dryRunTypes)+1)
+	for name, typeURL := range dryRunTypes {
+		resources := snapshot.GetResources(typeURL)
+		names := make([]string, 0, len(resources))
+		for resourceName := range resources {
+			names = append(names, resourceName)
+		}
+		sort.Strings(names)
+
+		marshaled := make([]json.RawMessage, 0, len(names))
+		for _, resourceName := range names {
+			raw, err := protojson.Marshal(resources[resourceName])
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s %q: %w", name, resourceName, err)
+			}
+			marshaled = append(marshaled, raw)
+		}
+		out[name] = marshaled
+	}
+
+	secrets := make([]string, 0)
+	for name := range snapshot.GetResources(resource.SecretType) {
+		secrets = append(secrets, name)
+	}
+	sort.Strings(secrets)
+	out["secrets"] = secrets
+
+	// protojson randomizes its whitespace, which the encoder normalizes.
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// gatewayConfig returns the gateway config read from the config-kourier and
+// config-network ConfigMaps among the objects, defaulting the missing ones.
+func (objs *Objects) gatewayConfig() (*rconfig.Config, error) {
+	cfg := rconfig.FromContextOrDefaults(context.Background())
+	for _, cm := range objs.configMaps {
+		var err error
+		switch cm.Name {
+		case config.ConfigName:
+			cfg.Kourier, err = config.NewConfigFromConfigMap(cm)
+		case netconfig.ConfigMapName:
+			cfg.Network, err = netconfig.NewConfigFromMap(cm.Data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ConfigMap %q: %w", cm.Name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// fallbackGetter fetches the objects missing from the primary getter from the fallback.
+type fallbackGetter struct {
+	primary  *Objects
+	fallback generator.ObjectGetter
+}
+
+var _ generator.ObjectGetter = (*fallbackGetter)(nil)
+
+func (g *fallbackGetter) Secret(ns, name string) (*corev1.Secret, error) {
+	secret, err := g.primary.Secret(ns, name)
+	if apierrors.IsNotFound(err) {
+		return g.fallback.Secret(ns, name)
+	}
+	return secret, err
+}
+
+func (g *fallbackGetter) Endpoints(ns, name string) (*corev1.Endpoints, error) {
+	eps, err := g.primary.Endpoints(ns, name)
+	if apierrors.IsNotFound(err) {
+		return g.fallback.Endpoints(ns, name)
+	}
+	return eps, err
+}
+
+func (g *fallbackGetter) Service(ns, name string) (*corev1.Service, error) {
+	svc, err := g.primary.Service(ns, name)
+	if apierrors.IsNotFound(err) {
+		return g.fallback.Service(ns, name)
+	}
+	return svc, err
+}
+
+// Namespace prefers the Namespaces among the manifests, as missing ones are defaulted.
+func (g *fallbackGetter) Namespace(name string) (*corev1.Namespace, error) {
+	if ns, ok := g.primary.namespaces[name]; ok {
+		return ns, nil
+	}
+	ns, err := g.fallback.Namespace(name)
+	if apierrors.IsNotFound(err) {
+		return g.primary.Namespace(name)
+	}
+	return ns, err
+}
+
+// ConfigMaps returns the matching ConfigMaps of both getters, preferring the ones among
+// the manifests.
+func (g *fallbackGetter) ConfigMaps(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	cms, err := g.primary.ConfigMaps(ns, selector)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := g.fallback.ConfigMaps(ns, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, cm := range fallback {
+		if _, ok := g.primary.configMaps[namespacedName(cm.Namespace, cm.Name)]; !ok {
+			cms = append(cms, cm)
+		}
+	}
+	return cms, nil
+}
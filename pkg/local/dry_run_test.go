@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/reconciler/ingress"
+)
+
+// dryRunOutput is the part of the output of a dry run checked by the tests.
+type dryRunOutput struct {
+	Clusters []struct {
+		Name           string `json:"name"`
+		ConnectTimeout string `json:"connectTimeout"`
+	} `json:"clusters"`
+	Listeners []json.RawMessage `json:"listeners"`
+	Routes    []json.RawMessage `json:"routes"`
+	Secrets   []string          `json:"secrets"`
+}
+
+func dryRun(t *testing.T, opts DryRunOptions) (*dryRunOutput, []byte) {
+	t.Helper()
+	var out bytes.Buffer
+	assert.NilError(t, DryRun(context.Background(), opts, &out))
+	got := &dryRunOutput{}
+	assert.NilError(t, json.Unmarshal(out.Bytes(), got))
+	return got, out.Bytes()
+}
+
+func TestDryRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hello.yaml")
+	assert.NilError(t, os.WriteFile(file, []byte(manifests), 0600))
+
+	got, raw := dryRun(t, DryRunOptions{Path: file})
+	assert.Equal(t, len(got.Clusters), 1)
+	assert.Equal(t, got.Clusters[0].Name, "default/hello")
+	assert.Assert(t, len(got.Listeners) > 0)
+	assert.Assert(t, len(got.Routes) > 0)
+	assert.DeepEqual(t, got.Secrets, []string{})
+
+	// The output is stable, so that it can be diffed.
+	_, again := dryRun(t, DryRunOptions{Path: file})
+	assert.Equal(t, string(again), string(raw))
+}
+
+func TestDryRunGatewayConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "hello.yaml"), []byte(manifests), 0600))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-kourier
+  namespace: knative-serving
+data:
+  upstream-connect-timeout: 2s
+`), 0600))
+
+	got, _ := dryRun(t, DryRunOptions{Path: dir})
+	assert.Equal(t, got.Clusters[0].ConnectTimeout, "2s")
+}
+
+func TestDryRunFallback(t *testing.T) {
+	// The Ingress alone is read from the file, the objects it references are fetched
+	// from the fallback.
+	ingressOnly := manifests[:bytes.Index([]byte(manifests), []byte("---"))]
+	file := filepath.Join(t.TempDir(), "hello.yaml")
+	assert.NilError(t, os.WriteFile(file, []byte(ingressOnly), 0600))
+
+	// The paths of missing services aren't served, like in the cluster.
+	got, _ := dryRun(t, DryRunOptions{Path: file})
+	assert.Equal(t, len(got.Clusters), 0)
+
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hello"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hello"},
+			Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "127.0.0.1"}}}},
+		},
+	)
+	got, _ = dryRun(t, DryRunOptions{Path: file, Fallback: ingress.NewClientObjectGetter(ctx, kubeClient)})
+	assert.Equal(t, len(got.Clusters), 1)
+	assert.Equal(t, got.Clusters[0].Name, "default/hello")
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package local implements a development mode of Kourier which runs the control
+// plane without a Kubernetes cluster. Ingresses and the objects they reference are
+// read from local files and served to a locally spawned Envoy.
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/server"
+	"knative.dev/net-kourier/pkg/generator"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracker"
+)
+
+const (
+	nodeID       = "3scale-kourier-gateway"
+	pollInterval = 2 * time.Second
+)
+
+// Options configures the local mode.
+type Options struct {
+	// Path is the file or directory to read the manifests from.
+	Path string
+	// EnvoyBinary is the Envoy binary to spawn. No Envoy is spawned if empty.
+	EnvoyBinary string
+	// ManagementPort is the port the xDS server listens on.
+	ManagementPort uint
+	// DeltaXDS makes the spawned Envoy subscribe via incremental xDS rather than
+	// state-of-the-world xDS.
+	DeltaXDS bool
+}
+
+// Run serves the Envoy configuration generated from the manifests at opts.Path
+// until the context is done. The manifests are reloaded whenever they change.
+func Run(ctx context.Context, opts Options) error {
+	logger := logging.FromContext(ctx)
+	setSystemNamespace()
+
+	xdsServer := envoy.NewXdsServer(opts.ManagementPort, &xds.CallbackFuncs{})
+	lastModified, err := update(ctx, xdsServer, opts.Path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		logger.Info("Starting Management Server on Port ", opts.ManagementPort)
+		if err := xdsServer.RunManagementServer(); err != nil {
+			logger.Fatalw("Failed to serve XDS Server", zap.Error(err))
+		}
+	}()
+
+	if opts.EnvoyBinary != "" {
+		if err := startEnvoy(ctx, opts.EnvoyBinary, opts.ManagementPort, opts.DeltaXDS); err != nil {
+			return fmt.Errorf("failed to start envoy: %w", err)
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			modified, err := latestModification(opts.Path)
+			if err != nil {
+				logger.Warnw("Failed to check manifests for changes", zap.Error(err))
+				continue
+			}
+			if !modified.After(lastModified) {
+				continue
+			}
+			logger.Info("Manifests changed, updating config")
+			if lastModified, err = update(ctx, xdsServer, opts.Path); err != nil {
+				logger.Errorw("Failed to update config", zap.Error(err))
+				lastModified = modified
+			}
+		}
+	}
+}
+
+// update translates the manifests at path and pushes the resulting snapshot. It returns
+// the latest modification time of the manifests that were read.
+func update(ctx context.Context, xdsServer *envoy.XdsServer, path string) (time.Time, error) {
+	modified, err := latestModification(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	snapshot, err := Snapshot(ctx, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return modified, xdsServer.SetSnapshot(nodeID, snapshot)
+}
+
+// Snapshot translates all Ingresses found at path into an Envoy snapshot.
+func Snapshot(ctx context.Context, path string) (*cache.Snapshot, error) {
+	objs, err := LoadObjects(path)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot(ctx, objs, objs, false)
+}
+
+// snapshot translates the given Ingresses into an Envoy snapshot, fetching the objects
+// they reference from the given getter. Ingresses conflicting with others are only
+// skipped, unless strict.
+func snapshot(ctx context.Context, objs *Objects, getter generator.ObjectGetter, strict bool) (*cache.Snapshot, error) {
+	logger := logging.FromContext(ctx)
+
+	caches, err := generator.NewCaches(ctx, nil, config.ExternalAuthz.Enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	ingresses, err := objs.ListIngresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	translator := generator.NewIngressTranslatorFromSource(getter, tracker.New(func(types.NamespacedName) {}, time.Hour))
+	for _, ing := range ingresses {
+		ing.SetDefaults(ctx)
+		err := generator.UpdateInfoForIngress(ctx, caches, ing, &translator, config.ExternalAuthz.Enabled)
+		if errors.Is(err, generator.ErrDomainConflict) && !strict {
+			logger.Warnf("Ingress %s/%s rejected: %v", ing.Namespace, ing.Name, err)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to translate ingress %s/%s: %w", ing.Namespace, ing.Name, err)
+		}
+	}
+	logger.Infof("Translated %d ingresses", len(ingresses))
+
+	return caches.ToEnvoySnapshot(ctx)
+}
+
+// setSystemNamespace sets the system namespace, which is not relevant locally but is
+// required by some code paths.
+func setSystemNamespace() {
+	if os.Getenv(system.NamespaceEnvKey) == "" {
+		os.Setenv(system.NamespaceEnvKey, "knative-serving")
+	}
+}
+
+func latestModification(path string) (time.Time, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func startEnvoy(ctx context.Context, binary string, managementPort uint, deltaXDS bool) error {
+	logger := logging.FromContext(ctx)
+
+	dir, err := os.MkdirTemp("", "kourier-local")
+	if err != nil {
+		return err
+	}
+	bootstrapPath := filepath.Join(dir, "envoy-bootstrap.yaml")
+	apiType := "GRPC"
+	if deltaXDS {
+		apiType = "DELTA_GRPC"
+	}
+	if err := os.WriteFile(bootstrapPath, []byte(fmt.Sprintf(bootstrapTemplate, apiType, managementPort)), 0600); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, binary, "-c", bootstrapPath, "--log-level", "info")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	logger.Infof("Started Envoy (pid %d) with bootstrap %s", cmd.Process.Pid, bootstrapPath)
+
+	go func() {
+		defer os.RemoveAll(dir)
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			logger.Errorw("Envoy exited", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// bootstrapTemplate is a trimmed down version of the gateway bootstrap config
+// (config/200-bootstrap.yaml) pointing to the local management server.
+const bootstrapTemplate = `dynamic_resources:
+  ads_config:
+    transport_api_version: V3
+    api_type: %s
+    grpc_services:
+    - envoy_grpc: {cluster_name: xds_cluster}
+  cds_config:
+    resource_api_version: V3
+    ads: {}
+  lds_config:
+    resource_api_version: V3
+    ads: {}
+node:
+  cluster: kourier-knative
+  id: ` + nodeID + `
+static_resources:
+  clusters:
+  - name: service_stats
+    connect_timeout: 0.250s
+    type: static
+    load_assignment:
+      cluster_name: service_stats
+      endpoints:
+        lb_endpoints:
+          endpoint:
+            address:
+              socket_address: {address: 127.0.0.1, port_value: 9901}
+  - name: xds_cluster
+    typed_extension_protocol_options:
+      envoy.extensions.upstreams.http.v3.HttpProtocolOptions:
+        "@type": type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions
+        explicit_http_config:
+          http2_protocol_options: {}
+    connect_timeout: 1s
+    type: STATIC
+    load_assignment:
+      cluster_name: xds_cluster
+      endpoints:
+        lb_endpoints:
+          endpoint:
+            address:
+              socket_address: {address: 127.0.0.1, port_value: %d}
+admin:
+  address:
+    socket_address: {address: 127.0.0.1, port_value: 9901}
+layered_runtime:
+  layers:
+  - name: ` + config.RuntimeLayerName + `
+    rtds_layer:
+      name: ` + config.RuntimeLayerName + `
+      rtds_config:
+        resource_api_version: V3
+        ads: {}
+`
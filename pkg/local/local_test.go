@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"gotest.tools/v3/assert"
+
+	_ "knative.dev/pkg/system/testing"
+)
+
+const manifests = `apiVersion: networking.internal.knative.dev/v1alpha1
+kind: Ingress
+metadata:
+  name: hello
+  namespace: default
+spec:
+  rules:
+  - hosts: [hello.example.com]
+    visibility: ExternalIP
+    http:
+      paths:
+      - splits:
+        - serviceName: hello
+          serviceNamespace: default
+          servicePort: 80
+          percent: 100
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: hello
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+    targetPort: 8080
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: hello
+  namespace: default
+subsets:
+- addresses:
+  - ip: 127.0.0.1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestLoadObjects(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "hello.yaml"), []byte(manifests), 0600))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0600))
+
+	objs, err := LoadObjects(dir)
+	assert.NilError(t, err)
+	ingresses, err := objs.ListIngresses(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(ingresses), 1)
+	assert.Equal(t, ingresses[0].Name, "hello")
+
+	_, err = objs.Service("default", "hello")
+	assert.NilError(t, err)
+	_, err = objs.Endpoints("default", "hello")
+	assert.NilError(t, err)
+	_, err = objs.Secret("default", "hello")
+	assert.ErrorContains(t, err, "not found")
+	ns, err := objs.Namespace("default")
+	assert.NilError(t, err)
+	assert.Equal(t, ns.Name, "default")
+}
+
+func TestSnapshot(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hello.yaml")
+	assert.NilError(t, os.WriteFile(file, []byte(manifests), 0600))
+
+	snapshot, err := Snapshot(context.Background(), file)
+	assert.NilError(t, err)
+
+	clusters := snapshot.GetResources(resource.ClusterType)
+	_, ok := clusters["default/hello"]
+	assert.Assert(t, ok, "expected cluster for default/hello, got %v", clusters)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"knative.dev/net-kourier/pkg/generator"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// Objects is an in-memory set of the Kubernetes objects the translator needs to
+// generate the Envoy configuration, as read from local files.
+type Objects struct {
+	ingresses  []*v1alpha1.Ingress
+	services   map[types.NamespacedName]*corev1.Service
+	endpoints  map[types.NamespacedName]*corev1.Endpoints
+	secrets    map[types.NamespacedName]*corev1.Secret
+	namespaces map[string]*corev1.Namespace
+	configMaps map[types.NamespacedName]*corev1.ConfigMap
+}
+
+var _ generator.Source = (*Objects)(nil)
+
+func newObjects() *Objects {
+	return &Objects{
+		services:   make(map[types.NamespacedName]*corev1.Service),
+		endpoints:  make(map[types.NamespacedName]*corev1.Endpoints),
+		secrets:    make(map[types.NamespacedName]*corev1.Secret),
+		namespaces: make(map[string]*corev1.Namespace),
+		configMaps: make(map[types.NamespacedName]*corev1.ConfigMap),
+	}
+}
+
+// LoadObjects reads all YAML or JSON documents in the given file or directory.
+// Supported kinds are Ingress, Service, Endpoints, Secret, Namespace and ConfigMap,
+// all other kinds are ignored.
+func LoadObjects(path string) (*Objects, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := newObjects()
+	for _, file := range files {
+		if err := objs.loadFile(file); err != nil {
+			return nil, fmt.Errorf("failed to load %q: %w", file, err)
+		}
+	}
+	return objs, nil
+}
+
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (objs *Objects) loadFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+		if err := objs.add(raw.Raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (objs *Objects) add(raw []byte) error {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return err
+	}
+
+	switch typeMeta.Kind {
+	case "Ingress":
+		ing := &v1alpha1.Ingress{}
+		if err := json.Unmarshal(raw, ing); err != nil {
+			return err
+		}
+		objs.ingresses = append(objs.ingresses, ing)
+	case "Service":
+		svc := &corev1.Service{}
+		if err := json.Unmarshal(raw, svc); err != nil {
+			return err
+		}
+		objs.services[namespacedName(svc.Namespace, svc.Name)] = svc
+	case "Endpoints":
+		eps := &corev1.Endpoints{}
+		if err := json.Unmarshal(raw, eps); err != nil {
+			return err
+		}
+		objs.endpoints[namespacedName(eps.Namespace, eps.Name)] = eps
+	case "Secret":
+		secret := &corev1.Secret{}
+		if err := json.Unmarshal(raw, secret); err != nil {
+			return err
+		}
+		// Allow specifying plain text data for convenience, as kubectl does.
+		for k, v := range secret.StringData {
+			if secret.Data == nil {
+				secret.Data = make(map[string][]byte, len(secret.StringData))
+			}
+			secret.Data[k] = []byte(v)
+		}
+		objs.secrets[namespacedName(secret.Namespace, secret.Name)] = secret
+	case "Namespace":
+		ns := &corev1.Namespace{}
+		if err := json.Unmarshal(raw, ns); err != nil {
+			return err
+		}
+		objs.namespaces[ns.Name] = ns
+	case "ConfigMap":
+		cm := &corev1.ConfigMap{}
+		if err := json.Unmarshal(raw, cm); err != nil {
+			return err
+		}
+		objs.configMaps[namespacedName(cm.Namespace, cm.Name)] = cm
+	}
+	return nil
+}
+
+// ListIngresses returns all Ingresses that were read.
+func (objs *Objects) ListIngresses(context.Context) ([]*v1alpha1.Ingress, error) {
+	return objs.ingresses, nil
+}
+
+// Secret returns the Secret with the given namespace and name.
+func (objs *Objects) Secret(ns, name string) (*corev1.Secret, error) {
+	if secret, ok := objs.secrets[namespacedName(ns, name)]; ok {
+		return secret, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+}
+
+// Service returns the Service with the given namespace and name.
+func (objs *Objects) Service(ns, name string) (*corev1.Service, error) {
+	if svc, ok := objs.services[namespacedName(ns, name)]; ok {
+		return svc, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("services"), name)
+}
+
+// Endpoints returns the Endpoints with the given namespace and name.
+func (objs *Objects) Endpoints(ns, name string) (*corev1.Endpoints, error) {
+	if eps, ok := objs.endpoints[namespacedName(ns, name)]; ok {
+		return eps, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("endpoints"), name)
+}
+
+// Namespace returns the Namespace with the given name. Namespaces do not need to be
+// specified explicitly, an empty one is returned if it's missing.
+func (objs *Objects) Namespace(name string) (*corev1.Namespace, error) {
+	if ns, ok := objs.namespaces[name]; ok {
+		return ns, nil
+	}
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+}
+
+// ConfigMaps returns the ConfigMaps in the given namespace matching the selector.
+func (objs *Objects) ConfigMaps(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	var cms []*corev1.ConfigMap
+	for _, cm := range objs.configMaps {
+		if cm.Namespace == ns && selector.Matches(labels.Set(cm.Labels)) {
+			cms = append(cms, cm)
+		}
+	}
+	return cms, nil
+}
+
+func namespacedName(ns, name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: ns, Name: name}
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// overloadManagerSection is the top-level key of the overload manager in the
+	// bootstrap config.
+	overloadManagerSection = "overload_manager"
+
+	// generatedComment marks the overload manager generated by the controller, so that
+	// the ones written by hand are left alone unless the config sets one.
+	generatedComment = "# Generated from the overload keys of the config-kourier ConfigMap."
+
+	// clusterManagerSection is the top-level key of the cluster manager in the bootstrap
+	// config, which holds the config of the load reporting.
+	clusterManagerSection = "cluster_manager"
+
+	// loadReportingComment marks the cluster manager generated by the controller.
+	loadReportingComment = "# Generated from the load-reporting key of the config-kourier ConfigMap."
+
+	// statsConfigSection is the top-level key of the stats config in the bootstrap config,
+	// which holds the tags extracted from the stats names.
+	statsConfigSection = "stats_config"
+
+	// statsTagsComment marks the stats config generated by the controller.
+	statsTagsComment = "# Generated from the ingress-stats-tags and policy-route-stats keys of the config-kourier ConfigMap."
+
+	// layeredRuntimeSection is the top-level key of the runtime layers in the bootstrap
+	// config.
+	layeredRuntimeSection = "layered_runtime"
+
+	// runtimeLayerComment marks the runtime layers generated by the controller.
+	runtimeLayerComment = "# Generated from the runtime-overrides and overload-max-downstream-connections keys of the config-kourier ConfigMap."
+
+	// staticLayer is the static runtime layer of the gateways.
+	staticLayer = `    - name: static-layer
+      static_layer:
+        envoy.reloadable_features.override_request_timeout_by_gateway_timeout: false
+`
+)
+
+// loadStatsConfig is the cluster manager making the gateways report their load to the
+// controller via LRS.
+const loadStatsConfig = clusterManagerSection + `:
+  ` + loadReportingComment + `
+  load_stats_config:
+    transport_api_version: V3
+    api_type: GRPC
+    grpc_services:
+    - envoy_grpc: {cluster_name: xds_cluster}
+`
+
+// rtdsLayers are the runtime layers fetching the runtime layer served by the controller
+// via RTDS on top of the static layer.
+const rtdsLayers = layeredRuntimeSection + `:
+  ` + runtimeLayerComment + `
+  layers:
+` + staticLayer + `    - name: ` + config.RuntimeLayerName + `
+      rtds_layer:
+        name: ` + config.RuntimeLayerName + `
+        rtds_config:
+          resource_api_version: V3
+          ads: {}
+`
+
+// staticLayers are the runtime layers of the default bootstrap config, which the
+// generated ones are replaced with once they aren't configured anymore.
+const staticLayers = layeredRuntimeSection + `:
+  layers:
+` + staticLayer
+
+// generatedSection is a top-level section of the bootstrap config generated by the
+// controller from the config.
+type generatedSection struct {
+	// key is the top-level key of the section.
+	key string
+
+	// comment marks the section as generated, so that the ones written by hand are left
+	// alone unless the config sets one.
+	comment string
+
+	// render renders the section, including its comment, as configured in the given
+	// config. It returns "" if the section isn't configured.
+	render func(*config.Kourier) (string, error)
+
+	// fallback replaces the generated section once it isn't configured anymore. The
+	// section is removed if "".
+	fallback string
+}
+
+// generatedSections are the sections of the bootstrap config generated by the controller.
+var generatedSections = []generatedSection{{
+	key:     overloadManagerSection,
+	comment: generatedComment,
+	render:  overloadManager,
+}, {
+	key:     clusterManagerSection,
+	comment: loadReportingComment,
+	render:  loadReporting,
+}, {
+	key:     statsConfigSection,
+	comment: statsTagsComment,
+	render:  statsTags,
+}, {
+	key:      layeredRuntimeSection,
+	comment:  runtimeLayerComment,
+	render:   runtimeLayer,
+	fallback: staticLayers,
+}}
+
+// Reconciler keeps the generated sections of the bootstrap config of the gateways in
+// sync with the config.
+type Reconciler struct {
+	reconciler.LeaderAwareFuncs
+
+	kubeClient      kubeclient.Interface
+	configMapLister corev1listers.ConfigMapLister
+	configStore     *rconfig.Store
+}
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	ctx = r.configStore.ToContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid resource key %q: %w", key, err)
+	}
+
+	cm, err := r.configMapLister.ConfigMaps(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("Bootstrap ConfigMap %q not found, nothing to sync", key)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get bootstrap ConfigMap: %w", err)
+	}
+
+	existing, ok := cm.Data[config.BootstrapConfigMapKey]
+	if !ok {
+		return nil
+	}
+	bootstrap, err := setGeneratedSections(existing, rconfig.FromContextOrDefaults(ctx).Kourier)
+	if err != nil {
+		return err
+	}
+	if bootstrap == existing {
+		return nil
+	}
+
+	// Envoy only reads its bootstrap config on startup.
+	logger.Infof("Updating the generated sections of bootstrap ConfigMap %q, the gateways pick them up once restarted", key)
+	cm = cm.DeepCopy()
+	cm.Data[config.BootstrapConfigMapKey] = bootstrap
+	if _, err := r.kubeClient.CoreV1().ConfigMaps(ns).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update bootstrap ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// setGeneratedSections sets the generated sections of the given bootstrap config as
+// configured in the given config. The generated sections which aren't configured
+// anymore are replaced with their fallback, while the ones written by hand are left
+// alone.
+func setGeneratedSections(bootstrap string, kourierConfig *config.Kourier) (string, error) {
+	for _, generated := range generatedSections {
+		section, err := generated.render(kourierConfig)
+		if err != nil {
+			return "", fmt.Errorf("failed to render the %s section: %w", generated.key, err)
+		}
+		if section == "" {
+			if !strings.Contains(getSection(bootstrap, generated.key), generated.comment) {
+				continue
+			}
+			section = generated.fallback
+		}
+		bootstrap = setSection(bootstrap, generated.key, section)
+	}
+	return bootstrap, nil
+}
+
+// overloadManager renders the overload_manager section of the bootstrap config, as
+// configured in the given config. It returns "" if no overload manager is configured.
+func overloadManager(kourierConfig *config.Kourier) (string, error) {
+	if kourierConfig.OverloadMaxHeapSize == nil || kourierConfig.OverloadMaxHeapSize.IsZero() {
+		return "", nil
+	}
+
+	manager, err := envoy.NewOverloadManager(uint64(kourierConfig.OverloadMaxHeapSize.Value()),
+		kourierConfig.OverloadShrinkHeapThreshold, kourierConfig.OverloadStopAcceptingRequestsThreshold)
+	if err != nil {
+		return "", err
+	}
+	marshaled, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(manager)
+	if err != nil {
+		return "", err
+	}
+	section, err := yaml.JSONToYAML(marshaled)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(overloadManagerSection + ":\n")
+	b.WriteString("  " + generatedComment + "\n")
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(string(section), "\n"), "\n") {
+		b.WriteString("  " + line)
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// loadReporting renders the cluster_manager section of the bootstrap config, making the
+// gateways report their load if enabled in the given config. It returns "" otherwise.
+func loadReporting(kourierConfig *config.Kourier) (string, error) {
+	if !kourierConfig.LoadReporting {
+		return "", nil
+	}
+	return loadStatsConfig, nil
+}
+
+// runtimeLayer renders the layered_runtime section of the bootstrap config, fetching the
+// runtime layer served by the controller if the given config sets any of its values. It
+// returns "" otherwise.
+func runtimeLayer(kourierConfig *config.Kourier) (string, error) {
+	if len(kourierConfig.RuntimeOverrides) == 0 && kourierConfig.OverloadMaxDownstreamConnections == 0 {
+		return "", nil
+	}
+	return rtdsLayers, nil
+}
+
+// statsTags renders the stats_config section of the bootstrap config, extracting the
+// tags of the route stats prefixed by the Ingress they belong to, as enabled in the given
+// config. It returns "" if neither the Ingress nor the policy route stats are enabled.
+func statsTags(kourierConfig *config.Kourier) (string, error) {
+	var tags []string
+	if kourierConfig.IngressStatsTags || kourierConfig.PolicyRouteStats {
+		tags = append(tags, "kingress_namespace", "kingress_name", "kservice")
+	}
+	if kourierConfig.IngressStatsTags {
+		tags = append(tags, "krevision")
+	}
+	if kourierConfig.PolicyRouteStats {
+		tags = append(tags, "kpolicy")
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteString(statsConfigSection + ":\n")
+	b.WriteString("  " + statsTagsComment + "\n")
+	b.WriteString("  stats_tags:\n")
+	for _, tag := range tags {
+		b.WriteString("  - tag_name: " + tag + "\n")
+		b.WriteString(`    regex: '^vhost\..*(\.` + tag + `\.([^.]+))'` + "\n")
+	}
+	return b.String(), nil
+}
+
+// setSection replaces the top-level section with the given key of the given YAML
+// document with the given section, keeping the rest of the document as it is, including
+// its comments. The section is appended if the document has none yet, and removed if
+// the given one is empty.
+func setSection(document, key, section string) string {
+	lines := strings.SplitAfter(document, "\n")
+	start, end := sectionBounds(lines, key)
+	if start == -1 {
+		if section == "" {
+			return document
+		}
+		if document != "" && !strings.HasSuffix(document, "\n") {
+			document += "\n"
+		}
+		return document + section
+	}
+	return strings.Join(lines[:start], "") + section + strings.Join(lines[end:], "")
+}
+
+// getSection returns the top-level section with the given key of the given YAML
+// document, or "" if there's none.
+func getSection(document, key string) string {
+	lines := strings.SplitAfter(document, "\n")
+	start, end := sectionBounds(lines, key)
+	if start == -1 {
+		return ""
+	}
+	return strings.Join(lines[start:end], "")
+}
+
+// sectionBounds returns the range of the given lines holding the top-level section with
+// the given key, or -1 if there's none. The section ends at the next top-level key or
+// comment.
+func sectionBounds(lines []string, key string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		if start == -1 {
+			if strings.HasPrefix(line, key+":") {
+				start = i
+			}
+			continue
+		}
+		if line != "" && line != "\n" && !strings.HasPrefix(line, " ") {
+			return start, i
+		}
+	}
+	return start, len(lines)
+}
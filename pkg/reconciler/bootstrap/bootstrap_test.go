@@ -0,0 +1,291 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	overload "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"knative.dev/net-kourier/pkg/config"
+	"sigs.k8s.io/yaml"
+
+	// The typed config of the heap monitor needs to be resolvable.
+	_ "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
+)
+
+func TestSetSection(t *testing.T) {
+	const document = `node:
+  id: gateway
+# The runtime of the gateways.
+layered_runtime:
+  layers: []
+`
+	section := "overload_manager:\n  refresh_interval: 1s\n"
+
+	tests := []struct {
+		name     string
+		document string
+		section  string
+		want     string
+	}{{
+		name:     "append",
+		document: document,
+		section:  section,
+		want:     document + section,
+	}, {
+		name:     "append without trailing newline",
+		document: strings.TrimSuffix(document, "\n"),
+		section:  section,
+		want:     document + section,
+	}, {
+		name:     "replace in place",
+		document: "overload_manager:\n  refresh_interval: 5s\n\n  actions: []\n" + document,
+		section:  section,
+		want:     section + document,
+	}, {
+		name:     "remove",
+		document: document + section,
+		want:     document,
+	}, {
+		name:     "nothing to remove",
+		document: document,
+		want:     document,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := setSection(test.document, overloadManagerSection, test.section)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error("setSection (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestGetSection(t *testing.T) {
+	section := "overload_manager:\n  refresh_interval: 1s\n"
+	assert.Equal(t, getSection("node: {}\n"+section+"# Comment\nadmin: {}\n", overloadManagerSection), section)
+	assert.Equal(t, getSection("node: {}\n", overloadManagerSection), "")
+}
+
+func TestOverloadManager(t *testing.T) {
+	section, err := overloadManager(&config.Kourier{})
+	assert.NilError(t, err)
+	assert.Equal(t, section, "")
+
+	heap := resource.MustParse("1Gi")
+	section, err = overloadManager(&config.Kourier{
+		OverloadMaxHeapSize:         &heap,
+		OverloadShrinkHeapThreshold: 0.9,
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(section, "overload_manager:\n  "+generatedComment+"\n"), section)
+	assert.Equal(t, getSection(section, overloadManagerSection), section)
+
+	// The section is a valid overload manager.
+	var parsed map[string]interface{}
+	assert.NilError(t, yaml.Unmarshal([]byte(section), &parsed))
+	marshaled, err := yaml.Marshal(parsed[overloadManagerSection])
+	assert.NilError(t, err)
+	json, err := yaml.YAMLToJSON(marshaled)
+	assert.NilError(t, err)
+	manager := &overload.OverloadManager{}
+	assert.NilError(t, protojson.Unmarshal(json, manager))
+	assert.NilError(t, manager.Validate())
+	assert.Equal(t, len(manager.Actions), 2)
+	assert.Equal(t, manager.Actions[0].Triggers[0].GetThreshold().Value, 0.9)
+	assert.Equal(t, manager.Actions[1].Triggers[0].GetThreshold().Value, 0.98)
+}
+
+func TestSetGeneratedSections(t *testing.T) {
+	const document = "node:\n  id: gateway\n"
+	const handWritten = "cluster_manager:\n  local_cluster_name: local\n"
+
+	tests := []struct {
+		name     string
+		document string
+		config   *config.Kourier
+		want     string
+	}{{
+		name:     "nothing configured",
+		document: document,
+		config:   &config.Kourier{},
+		want:     document,
+	}, {
+		name:     "load reporting",
+		document: document,
+		config:   &config.Kourier{LoadReporting: true},
+		want:     document + loadStatsConfig,
+	}, {
+		name:     "load reporting disabled again",
+		document: document + loadStatsConfig,
+		config:   &config.Kourier{},
+		want:     document,
+	}, {
+		name:     "hand-written cluster manager",
+		document: handWritten + document,
+		config:   &config.Kourier{},
+		want:     handWritten + document,
+	}, {
+		name:     "hand-written cluster manager replaced",
+		document: handWritten + document,
+		config:   &config.Kourier{LoadReporting: true},
+		want:     loadStatsConfig + document,
+	}, {
+		name:     "runtime layer",
+		document: document + staticLayers,
+		config:   &config.Kourier{RuntimeOverrides: map[string]string{"key": "value"}},
+		want:     document + rtdsLayers,
+	}, {
+		name:     "runtime layer for the maximum downstream connections",
+		document: document + staticLayers,
+		config:   &config.Kourier{OverloadMaxDownstreamConnections: 50000},
+		want:     document + rtdsLayers,
+	}, {
+		name:     "runtime layer disabled again",
+		document: document + rtdsLayers,
+		config:   &config.Kourier{},
+		want:     document + staticLayers,
+	}, {
+		name:     "static runtime layer",
+		document: document + staticLayers,
+		config:   &config.Kourier{},
+		want:     document + staticLayers,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := setGeneratedSections(test.document, test.config)
+			assert.NilError(t, err)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error("setGeneratedSections (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestStatsTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *config.Kourier
+		want   []string
+	}{{
+		name:   "disabled",
+		config: &config.Kourier{},
+	}, {
+		name:   "ingress stats tags",
+		config: &config.Kourier{IngressStatsTags: true},
+		want:   []string{"kingress_namespace", "kingress_name", "kservice", "krevision"},
+	}, {
+		name:   "policy route stats",
+		config: &config.Kourier{PolicyRouteStats: true},
+		want:   []string{"kingress_namespace", "kingress_name", "kservice", "kpolicy"},
+	}, {
+		name:   "both",
+		config: &config.Kourier{IngressStatsTags: true, PolicyRouteStats: true},
+		want:   []string{"kingress_namespace", "kingress_name", "kservice", "krevision", "kpolicy"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			section, err := statsTags(test.config)
+			assert.NilError(t, err)
+			if test.want == nil {
+				assert.Equal(t, section, "")
+				return
+			}
+			assert.Equal(t, getSection(section, statsConfigSection), section)
+
+			var parsed struct {
+				StatsConfig struct {
+					StatsTags []struct {
+						TagName string `json:"tag_name"`
+						Regex   string `json:"regex"`
+					} `json:"stats_tags"`
+				} `json:"stats_config"`
+			}
+			assert.NilError(t, yaml.Unmarshal([]byte(section), &parsed))
+			var got []string
+			for _, tag := range parsed.StatsConfig.StatsTags {
+				got = append(got, tag.TagName)
+				// The regexes extract the value of the tag from the route stats.
+				match := regexp.MustCompile(tag.Regex).FindStringSubmatch("vhost.hello.route.kingress_namespace.default.kingress_name.hello.kservice.hello.krevision.hello-00001.kpolicy.redirect.upstream_rq_total")
+				assert.Assert(t, match != nil, tag.Regex)
+				assert.Assert(t, match[2] != "", tag.Regex)
+			}
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
+
+func TestRuntimeLayer(t *testing.T) {
+	section, err := runtimeLayer(&config.Kourier{RuntimeOverrides: map[string]string{"key": "value"}})
+	assert.NilError(t, err)
+	assert.Equal(t, getSection(section, layeredRuntimeSection), section)
+
+	// The runtime layer served by the controller overrides the static layer.
+	var parsed struct {
+		LayeredRuntime struct {
+			Layers []struct {
+				Name        string                 `json:"name"`
+				StaticLayer map[string]interface{} `json:"static_layer"`
+				RTDSLayer   struct {
+					Name string `json:"name"`
+				} `json:"rtds_layer"`
+			} `json:"layers"`
+		} `json:"layered_runtime"`
+	}
+	assert.NilError(t, yaml.Unmarshal([]byte(section), &parsed))
+	assert.Equal(t, len(parsed.LayeredRuntime.Layers), 2)
+	assert.Equal(t, parsed.LayeredRuntime.Layers[0].Name, "static-layer")
+	assert.Equal(t, len(parsed.LayeredRuntime.Layers[0].StaticLayer), 1)
+	assert.Equal(t, parsed.LayeredRuntime.Layers[1].RTDSLayer.Name, config.RuntimeLayerName)
+
+	// The fallback is the static layer alone.
+	assert.NilError(t, yaml.Unmarshal([]byte(staticLayers), &parsed))
+	assert.Equal(t, len(parsed.LayeredRuntime.Layers), 1)
+	assert.Equal(t, parsed.LayeredRuntime.Layers[0].Name, "static-layer")
+}
+
+func TestLoadReporting(t *testing.T) {
+	section, err := loadReporting(&config.Kourier{LoadReporting: true})
+	assert.NilError(t, err)
+	assert.Equal(t, getSection(section, clusterManagerSection), section)
+
+	// The gateways report their load to the controller.
+	var parsed struct {
+		ClusterManager struct {
+			LoadStatsConfig struct {
+				APIType      string `json:"api_type"`
+				GRPCServices []struct {
+					EnvoyGRPC struct {
+						ClusterName string `json:"cluster_name"`
+					} `json:"envoy_grpc"`
+				} `json:"grpc_services"`
+			} `json:"load_stats_config"`
+		} `json:"cluster_manager"`
+	}
+	assert.NilError(t, yaml.Unmarshal([]byte(section), &parsed))
+	assert.Equal(t, parsed.ClusterManager.LoadStatsConfig.APIType, "GRPC")
+	assert.Equal(t, parsed.ClusterManager.LoadStatsConfig.GRPCServices[0].EnvoyGRPC.ClusterName, "xds_cluster")
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	v1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+)
+
+// NewController creates a controller keeping the generated sections of the bootstrap
+// config of the gateways, e.g. the overload manager, in sync with the config.
+func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+
+	bootstrap := types.NamespacedName{
+		Namespace: config.GatewayNamespace(),
+		Name:      config.BootstrapConfigMapName,
+	}
+
+	// Only the bootstrap ConfigMap is watched, rather than all ConfigMaps.
+	configMapInformer := v1.NewFilteredConfigMapInformer(kubeclient.Get(ctx), bootstrap.Namespace,
+		controller.GetResyncPeriod(ctx), cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", bootstrap.Name).String()
+		})
+
+	r := &Reconciler{
+		kubeClient:      kubeclient.Get(ctx),
+		configMapLister: corev1listers.NewConfigMapLister(configMapInformer.GetIndexer()),
+	}
+	impl := controller.NewContext(ctx, r, controller.ControllerOptions{
+		WorkQueueName: "Bootstrap",
+		Logger:        logger,
+	})
+	r.LeaderAwareFuncs = reconciler.LeaderAwareFuncs{
+		PromoteFunc: func(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
+			enq(bkt, bootstrap)
+			return nil
+		},
+	}
+
+	enqueueBootstrap := func(interface{}) {
+		impl.EnqueueKey(bootstrap)
+	}
+
+	configStore := rconfig.NewStore(logger.Named("config-store"), func(string, interface{}) {
+		enqueueBootstrap(nil)
+	})
+	configStore.WatchConfigs(cmw)
+	r.configStore = configStore
+
+	// Restore the generated sections once the ConfigMap is applied again.
+	configMapInformer.AddEventHandler(controller.HandleAll(enqueueBootstrap))
+	go configMapInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), configMapInformer.HasSynced) {
+		logger.Fatal("Failed to sync the bootstrap ConfigMap informer")
+	}
+
+	return impl
+}
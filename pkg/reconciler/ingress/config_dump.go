@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	envoy "knative.dev/net-kourier/pkg/envoy/api"
+	"knative.dev/net-kourier/pkg/generator"
+)
+
+const (
+	// ConfigDumpPath is the path the snapshots last pushed to the gateways are served on,
+	// next to the inventory. The snapshot of a single node ID is served if given with the
+	// "node" query parameter.
+	ConfigDumpPath = "/config_dump"
+
+	// TranslationPath is the path the result of the translation of an Ingress is served
+	// on, next to the inventory. The Ingress is given with the "ingress" query parameter,
+	// as "namespace/name".
+	TranslationPath = "/translation"
+)
+
+// configDumpTypes are the types of the resources of the snapshots served, keyed by their
+// name in the dump. The secrets are only served by name, and the private keys inlined in
+// the other resources, e.g. in the listeners of the one-cert setups, are redacted.
+var configDumpTypes = map[string]string{
+	"clusters":      resource.ClusterType,
+	"endpoints":     resource.EndpointType,
+	"listeners":     resource.ListenerType,
+	"routes":        resource.RouteType,
+	"virtual_hosts": resource.VirtualHostType,
+	"runtimes":      resource.RuntimeType,
+}
+
+// snapshotDump is the dump of the snapshot of a node ID.
+type snapshotDump struct {
+	// Versions are the versions of the resources, keyed by the name of their type.
+	Versions map[string]string `json:"versions"`
+	// Resources are the resources, sorted by name, keyed by the name of their type.
+	Resources map[string][]json.RawMessage `json:"resources"`
+	// Secrets are the names of the secrets.
+	Secrets []string `json:"secrets"`
+}
+
+// translationDump is the dump of the translation of an Ingress.
+type translationDump struct {
+	Routes              int                        `json:"routes"`
+	Clusters            int                        `json:"clusters"`
+	SNIMatches          int                        `json:"sniMatches"`
+	AnnotationConflicts []string                   `json:"annotationConflicts,omitempty"`
+	Resources           map[string]json.RawMessage `json:"resources"`
+}
+
+// newConfigDumpHandler creates a handler serving the given snapshots as JSON, keyed by
+// node ID.
+func newConfigDumpHandler(logger *zap.SugaredLogger, snapshots func() map[string]envoycache.ResourceSnapshot) http.Handler {
+	return readOnlyHandler(func(w http.ResponseWriter, r *http.Request) {
+		all := snapshots()
+		if node := r.URL.Query().Get("node"); node != "" {
+			snapshot, ok := all[node]
+			if !ok {
+				http.Error(w, fmt.Sprintf("no snapshot for node %q", node), http.StatusNotFound)
+				return
+			}
+			all = map[string]envoycache.ResourceSnapshot{node: snapshot}
+		}
+
+		dumps := make(map[string]*snapshotDump, len(all))
+		for node, snapshot := range all {
+			dump, err := dumpSnapshot(snapshot)
+			if err != nil {
+				logger.Errorw("Failed to dump the snapshot of node "+node, zap.Error(err))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			dumps[node] = dump
+		}
+		writeJSON(logger, w, dumps)
+	})
+}
+
+// dumpSnapshot returns the dump of the given snapshot.
+func dumpSnapshot(snapshot envoycache.ResourceSnapshot) (*snapshotDump, error) {
+	dump := &snapshotDump{
+		Versions:  make(map[string]string, len(configDumpTypes)+1),
+		Resources: make(map[string][]json.RawMessage, len(configDumpTypes)),
+		Secrets:   make([]string, 0),
+	}
+	for name, typeURL := range configDumpTypes {
+		dump.Versions[name] = snapshot.GetVersion(typeURL)
+
+		resources := snapshot.GetResources(typeURL)
+		names := make([]string, 0, len(resources))
+		for resourceName := range resources {
+			names = append(names, resourceName)
+		}
+		sort.Strings(names)
+
+		dump.Resources[name] = make([]json.RawMessage, 0, len(names))
+		for _, resourceName := range names {
+			redacted, err := envoy.RedactSecrets(resources[resourceName])
+			if err != nil {
+				return nil, fmt.Errorf("failed to redact %s %q: %w", name, resourceName, err)
+			}
+			marshaled, err := protojson.Marshal(redacted)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s %q: %w", name, resourceName, err)
+			}
+			dump.Resources[name] = append(dump.Resources[name], marshaled)
+		}
+	}
+
+	dump.Versions["secrets"] = snapshot.GetVersion(resource.SecretType)
+	for name := range snapshot.GetResources(resource.SecretType) {
+		dump.Secrets = append(dump.Secrets, name)
+	}
+	sort.Strings(dump.Secrets)
+	return dump, nil
+}
+
+// newTranslationHandler creates a handler serving the resources generated for the given
+// Ingress as JSON.
+func newTranslationHandler(logger *zap.SugaredLogger, caches *generator.Caches) http.Handler {
+	return readOnlyHandler(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("ingress")
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || namespace == "" || name == "" {
+			http.Error(w, fmt.Sprintf("invalid ingress %q, must be namespace/name", key), http.StatusBadRequest)
+			return
+		}
+
+		ingress := types.NamespacedName{Namespace: namespace, Name: name}
+		resources, ok := caches.GeneratedResources(ingress)
+		if !ok {
+			http.Error(w, fmt.Sprintf("ingress %q is not translated", key), http.StatusNotFound)
+			return
+		}
+		data, ok, err := caches.GeneratedResourcesJSON(ingress)
+		if err != nil {
+			logger.Errorw("Failed to dump the translation of ingress "+ingress.String(), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !ok {
+			// The ingress was removed in the meantime.
+			http.Error(w, fmt.Sprintf("ingress %q is not translated", key), http.StatusNotFound)
+			return
+		}
+
+		dump := &translationDump{
+			Routes:              resources.Routes,
+			Clusters:            resources.Clusters,
+			SNIMatches:          resources.SNIMatches,
+			AnnotationConflicts: resources.AnnotationConflicts,
+			Resources:           make(map[string]json.RawMessage, len(data)),
+		}
+		for key, value := range data {
+			dump.Resources[key] = json.RawMessage(value)
+		}
+		writeJSON(logger, w, dump)
+	})
+}
+
+// readOnlyHandler only passes GET requests on to the given handler.
+func readOnlyHandler(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed: "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// writeJSON writes the given value as JSON.
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Errorw("Failed to write the dump", zap.Error(err))
+	}
+}
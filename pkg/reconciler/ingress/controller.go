@@ -18,28 +18,34 @@ package ingress
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"go.uber.org/zap"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	v1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"knative.dev/net-kourier/pkg/config"
 	envoy "knative.dev/net-kourier/pkg/envoy/server"
 	"knative.dev/net-kourier/pkg/generator"
 	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/net-kourier/pkg/status"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	networkingClientSet "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
 	knativeclient "knative.dev/networking/pkg/client/injection/client"
 	ingressinformer "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/ingress"
 	v1alpha1ingress "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
 	netconfig "knative.dev/networking/pkg/config"
-	"knative.dev/networking/pkg/status"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	endpointsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints"
 	nsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
@@ -54,10 +60,7 @@ import (
 )
 
 const (
-	gatewayLabelKey   = "app"
-	gatewayLabelValue = "3scale-kourier-gateway"
-
-	nodeID         = "3scale-kourier-gateway"
+	nodeID         = config.GatewayNodeID
 	managementPort = 18000
 
 	unknownWeightedClusterPrefix = "route: unknown weighted cluster '"
@@ -78,6 +81,12 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 	podInformer := podinformer.Get(ctx)
 	secretInformer := getSecretInformer(ctx)
 	namespaceInformer := nsinformer.Get(ctx)
+	trustBundleInformer := newTrustBundleInformer(ctx, kubernetesClient)
+
+	// The Endpoints of a pod are looked up on changes of its readiness.
+	if err := endpointsInformer.Informer().AddIndexers(cache.Indexers{endpointsPodIndex: endpointsPodIndexFunc}); err != nil {
+		logger.Fatalw("Failed to add the pod index of the endpoints", zap.Error(err))
+	}
 
 	// Create a new Cache, with the Readiness endpoint enabled, and the list of current Ingresses.
 	caches, err := generator.NewCaches(ctx, kubernetesClient, config.ExternalAuthz.Enabled)
@@ -86,11 +95,13 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 	}
 
 	r := &Reconciler{
+		kubeClient:      kubernetesClient,
 		caches:          caches,
 		extAuthz:        config.ExternalAuthz.Enabled,
 		namespaceLister: namespaceInformer.Lister(),
 	}
 
+	var configStore *rconfig.Store
 	impl := v1alpha1ingress.NewImpl(ctx, r, config.KourierIngressClassName, func(impl *controller.Impl) controller.Options {
 		configsToResync := []interface{}{
 			&netconfig.Config{},
@@ -99,7 +110,10 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		resync := configmap.TypeFilter(configsToResync...)(func(string, interface{}) {
 			impl.FilteredGlobalResync(isKourierIngress, ingressInformer.Informer())
 		})
-		configStore := rconfig.NewStore(logger.Named("config-store"), resync)
+		traceControlPath := configmap.TypeFilter(&config.Kourier{})(func(_ string, value interface{}) {
+			r.tracer.setEndpoint(logger, value.(*config.Kourier).ControlPathTracingEndpoint)
+		})
+		configStore = rconfig.NewStore(logger.Named("config-store"), resync, traceControlPath)
 		configStore.WatchConfigs(cmw)
 		return controller.Options{
 			ConfigStore:       configStore,
@@ -107,6 +121,16 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		}
 	})
 
+	r.snapshotDebouncer = newDebouncer(func() error {
+		err := r.pushEnvoyConfig(configStore.ToContext(ctx))
+		if err != nil {
+			// The Ingresses were reconciled already, so the push is retried rather
+			// than the reconciliation.
+			logger.Errorw("Failed to push the envoy config, retrying", zap.Error(err))
+		}
+		return err
+	})
+
 	r.resyncConflicts = func() {
 		impl.FilteredGlobalResync(func(obj interface{}) bool {
 			lbReady := obj.(*v1alpha1.Ingress).Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady).GetReason()
@@ -116,56 +140,79 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		}, ingressInformer.Informer())
 	}
 
+	// handleErrorDetail handles the rejection of a pushed snapshot by the gateway.
+	handleErrorDetail := func(typeURL string, errorDetail *rpcstatus.Status) {
+		if errorDetail == nil {
+			return
+		}
+		recordSnapshotNack(ctx, typeURL)
+		logger.Warnf("Error pushing snapshot to gateway: code: %v message %s", errorDetail.Code, errorDetail.Message)
+
+		// We know we can handle this error without a global resync.
+		if strings.HasPrefix(errorDetail.Message, unknownWeightedClusterPrefix) {
+			// The error message contains the service name as referenced by the ingress.
+			svc := strings.TrimPrefix(strings.TrimSuffix(errorDetail.Message, "'"), unknownWeightedClusterPrefix)
+			ns, name, err := cache.SplitMetaNamespaceKey(svc)
+			if err != nil {
+				logger.Errorw("Failed to parse service name from error", zap.Error(err))
+				return
+			}
+
+			logger.Infof("Triggering reconcile for all ingresses referencing %q", svc)
+			impl.Tracker.OnChanged(&corev1.Service{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Service",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns,
+					Name:      name,
+				},
+			})
+			return
+		}
+
+		// Fallback to a global resync of non-ready ingresses for every other error.
+		impl.FilteredGlobalResync(func(obj interface{}) bool {
+			return isKourierIngress(obj) && !obj.(*v1alpha1.Ingress).IsReady()
+		}, ingressInformer.Informer())
+	}
+
 	envoyXdsServer := envoy.NewXdsServer(
 		managementPort,
 		&xds.CallbackFuncs{
 			StreamRequestFunc: func(_ int64, req *v3.DiscoveryRequest) error {
-				if req.ErrorDetail == nil {
-					return nil
-				}
-				logger.Warnf("Error pushing snapshot to gateway: code: %v message %s", req.ErrorDetail.Code, req.ErrorDetail.Message)
-
-				// We know we can handle this error without a global resync.
-				if strings.HasPrefix(req.ErrorDetail.Message, unknownWeightedClusterPrefix) {
-					// The error message contains the service name as referenced by the ingress.
-					svc := strings.TrimPrefix(strings.TrimSuffix(req.ErrorDetail.Message, "'"), unknownWeightedClusterPrefix)
-					ns, name, err := cache.SplitMetaNamespaceKey(svc)
-					if err != nil {
-						logger.Errorw("Failed to parse service name from error", zap.Error(err))
-						return nil
-					}
-
-					logger.Infof("Triggering reconcile for all ingresses referencing %q", svc)
-					impl.Tracker.OnChanged(&corev1.Service{
-						TypeMeta: metav1.TypeMeta{
-							Kind:       "Service",
-							APIVersion: "v1",
-						},
-						ObjectMeta: metav1.ObjectMeta{
-							Namespace: ns,
-							Name:      name,
-						},
-					})
-					return nil
-				}
-
-				// Fallback to a global resync of non-ready ingresses for every other error.
-				impl.FilteredGlobalResync(func(obj interface{}) bool {
-					return isKourierIngress(obj) && !obj.(*v1alpha1.Ingress).IsReady()
-				}, ingressInformer.Informer())
-
+				handleErrorDetail(req.TypeUrl, req.ErrorDetail)
+				r.tracer.onRequest(req.Node.GetId(), req.TypeUrl, req.VersionInfo, req.ErrorDetail)
+				return nil
+			},
+			// Gateways using incremental xDS report rejections on the delta stream.
+			StreamDeltaRequestFunc: func(_ int64, req *v3.DeltaDiscoveryRequest) error {
+				handleErrorDetail(req.TypeUrl, req.ErrorDetail)
+				// The deltas carry no version, so only their rejections end the spans.
+				r.tracer.onRequest(req.Node.GetId(), req.TypeUrl, "", req.ErrorDetail)
 				return nil
 			},
 		},
 	)
 	r.xdsServer = envoyXdsServer
+	envoyXdsServer.EnableDraining(generator.DrainingSnapshot)
 
 	statusProber := status.NewProber(
 		logger.Named("status-manager"),
-		NewProbeTargetLister(logger, endpointsInformer.Lister(), namespaceInformer.Lister()),
+		NewProbeTargetLister(logger, endpointsInformer.Lister(), namespaceInformer.Lister(), r.caches.ExternalHosts),
 		func(ing *v1alpha1.Ingress) {
 			logger.Debugf("Ready callback triggered for ingress: %s/%s", ing.Namespace, ing.Name)
 			impl.EnqueueKey(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+		},
+		func() status.Options {
+			kourierConfig := configStore.Load().Kourier
+			return status.Options{
+				Concurrency: kourierConfig.StatusProbeConcurrency,
+				Timeout:     kourierConfig.StatusProbeTimeout,
+				BackoffBase: kourierConfig.StatusProbeBackoffBase,
+				BackoffMax:  kourierConfig.StatusProbeBackoffMax,
+			}
 		})
 	r.statusManager = statusProber
 	statusProber.Start(ctx.Done())
@@ -177,21 +224,18 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		impl.EnqueueKey(key)
 	})
 
-	ingressTranslator := generator.NewIngressTranslator(
-		func(ns, name string) (*corev1.Secret, error) {
-			return secretInformer.Lister().Secrets(ns).Get(name)
-		},
-		func(ns, name string) (*corev1.Endpoints, error) {
-			return endpointsInformer.Lister().Endpoints(ns).Get(name)
-		},
-		func(ns, name string) (*corev1.Service, error) {
-			return serviceInformer.Lister().Services(ns).Get(name)
-		},
-		func(name string) (*corev1.Namespace, error) {
-			return namespaceInformer.Lister().Get(name)
-		},
-		impl.Tracker)
+	source := &listerSource{
+		ingressLister:   ingressInformer.Lister(),
+		secretLister:    secretInformer.Lister(),
+		endpointsLister: endpointsInformer.Lister(),
+		serviceLister:   serviceInformer.Lister(),
+		namespaceLister: namespaceInformer.Lister(),
+		configMapLister: corev1listers.NewConfigMapLister(trustBundleInformer.GetIndexer()),
+		podLister:       podInformer.Lister(),
+	}
+	ingressTranslator := generator.NewIngressTranslatorFromSource(source, impl.Tracker)
 	r.ingressTranslator = &ingressTranslator
+	caches.SetPodGetter(source.Pod)
 
 	// Initialize the Envoy snapshot.
 	snapshot, err := r.caches.ToEnvoySnapshot(ctx)
@@ -203,29 +247,22 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		logger.Fatalw("Failed to set snapshot", zap.Error(err))
 	}
 
+	// The startup source uses clients instead of listers to correctly list all
+	// resources at startup.
+	startupSource := &clientSource{
+		ctx:           ctx,
+		kubeClient:    kubernetesClient,
+		knativeClient: knativeClient.NetworkingV1alpha1(),
+	}
+
 	// Get the current list of ingresses that are ready and seed the Envoy config with them.
-	ingressesToSync, err := getReadyIngresses(ctx, knativeClient.NetworkingV1alpha1())
+	ingressesToSync, err := startupSource.ListIngresses(ctx)
 	if err != nil {
 		logger.Fatalw("Failed to fetch ready ingresses", zap.Error(err))
 	}
 	logger.Infof("Priming the config with %d ingresses", len(ingressesToSync))
 
-	// The startup translator uses clients instead of listeners to correctly list all
-	// resources at startup.
-	startupTranslator := generator.NewIngressTranslator(
-		func(ns, name string) (*corev1.Secret, error) {
-			return kubernetesClient.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
-		},
-		func(ns, name string) (*corev1.Endpoints, error) {
-			return kubernetesClient.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
-		},
-		func(ns, name string) (*corev1.Service, error) {
-			return kubernetesClient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
-		},
-		func(name string) (*corev1.Namespace, error) {
-			return kubernetesClient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
-		},
-		impl.Tracker)
+	startupTranslator := generator.NewIngressTranslatorFromSource(startupSource, impl.Tracker)
 
 	for _, ingress := range ingressesToSync {
 		if err := generator.UpdateInfoForIngress(
@@ -246,6 +283,49 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		}
 	}()
 
+	// Publish the snapshots again which the gateways don't pick up, e.g. because their
+	// streams are wedged.
+	go envoyXdsServer.RunSnapshotWatchdog(ctx, func() time.Duration {
+		return configStore.Load().Kourier.SnapshotWatchdogDeadline
+	}, newRepublishedHandler(ctx, logger))
+
+	// Serve the inventory of the external hosts, e.g. for exposure audits, and the load
+	// of the clusters reported by the gateways. Forcing the re-translation of an Ingress
+	// is served too, for recovering from stale cached state, along with read-only dumps
+	// of the snapshots and translations for debugging. None of it is authenticated, so
+	// it's only served on the loopback interface, for kubectl exec and port-forward.
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle(InventoryPath, newInventoryHandler(logger, caches.Inventory))
+		mux.Handle(LoadsPath, newLoadsHandler(logger, envoyXdsServer.LoadStats().Loads))
+		mux.Handle(ConfigDumpPath, newConfigDumpHandler(logger, envoyXdsServer.Snapshots))
+		mux.Handle(TranslationPath, newTranslationHandler(logger, caches))
+		mux.Handle(RetranslatePath, newRetranslateHandler(logger, func(key types.NamespacedName) error {
+			ing, err := ingressInformer.Lister().Ingresses(key.Namespace).Get(key.Name)
+			if err != nil {
+				return err
+			}
+			if !isKourierIngress(ing) {
+				return errNotKourierIngress
+			}
+			// Drop the cached translation, so that the reconciliation translates the
+			// Ingress anew and pushes the result.
+			ingressTranslator.ForgetIngress(key)
+			impl.EnqueueKey(key)
+			return nil
+		}))
+		server := &http.Server{
+			Addr:              fmt.Sprintf("localhost:%d", InventoryPort),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		logger.Info("Starting Inventory Server on Port ", InventoryPort)
+		if err := server.ListenAndServe(); err != nil {
+			logger.Errorw("Failed to serve the inventory", zap.Error(err))
+		}
+	}()
+
 	// Ingresses need to be filtered by ingress class, so Kourier does not
 	// react to nor modify ingresses created by other gateways.
 	ingressInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
@@ -273,9 +353,19 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 	viaTracker := controller.EnsureTypeMeta(
 		impl.Tracker.OnChanged,
 		corev1.SchemeGroupVersion.WithKind("Endpoints"))
+	// Created or deleted endpoints are picked up by translating the ingresses again, as
+	// the translation of their clusters depends on their existence.
+	viaTrackerForgetting := func(obj interface{}) {
+		if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+			if ns, name, err := cache.SplitMetaNamespaceKey(key); err == nil {
+				caches.ForgetEndpoints(types.NamespacedName{Namespace: ns, Name: name})
+			}
+		}
+		viaTracker(obj)
+	}
 	endpointsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    viaTracker,
-		DeleteFunc: viaTracker,
+		AddFunc:    viaTrackerForgetting,
+		DeleteFunc: viaTrackerForgetting,
 		UpdateFunc: func(old interface{}, new interface{}) {
 			before := readyAddresses(old.(*corev1.Endpoints))
 			after := readyAddresses(new.(*corev1.Endpoints))
@@ -286,6 +376,17 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 				return
 			}
 
+			// Only the endpoints of the clusters changed, so update them in place rather
+			// than translating all ingresses referencing the service again.
+			if caches.UpdateEndpoints(new.(*corev1.Endpoints)) {
+				err := r.updateEnvoyConfig(configStore.ToContext(ctx))
+				if err == nil {
+					return
+				}
+				// Fall back to translating the ingresses again to retry.
+				logger.Errorw("Failed to update the endpoints in the envoy config", zap.Error(err))
+			}
+
 			viaTracker(new)
 		},
 	})
@@ -297,19 +398,68 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 		),
 	))
 
+	trustBundleInformer.AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(
+			impl.Tracker.OnChanged,
+			corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		),
+	))
+	go trustBundleInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), trustBundleInformer.HasSynced) {
+		logger.Fatal("Failed to sync the trust bundle informer")
+	}
+
 	podInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
-		FilterFunc: reconciler.LabelFilterFunc(gatewayLabelKey, gatewayLabelValue, false),
+		FilterFunc: reconciler.LabelFilterFunc(config.GatewayLabelKey, config.GatewayLabelValue, false),
 		Handler: cache.ResourceEventHandlerFuncs{
 			// Cancel probing when a Pod is deleted
 			DeleteFunc: func(obj interface{}) {
 				pod, ok := obj.(*corev1.Pod)
 				if ok {
 					statusProber.CancelPodProbing(pod)
+					// The pod is gone, so it doesn't need the draining config anymore.
+					envoyXdsServer.UndrainPod(pod.Status.PodIP)
 				}
 			},
 		},
 	})
 
+	// The gateway pods are drained as they terminate, so that they fail their readiness
+	// and close their connections before they exit.
+	podInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: reconciler.LabelFilterFunc(config.GatewayLabelKey, config.GatewayLabelValue, false),
+		Handler:    newPodDrainer(logger, envoyXdsServer.DrainPod),
+	})
+
+	// Changes of the readiness of pods are passed on right away, rather than once their
+	// Endpoints are updated, if the health status of the endpoints is passed on.
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old interface{}, new interface{}) {
+			pod := new.(*corev1.Pod)
+			if !configStore.Load().Kourier.EndpointHealthStatus ||
+				generator.PodHealthStatus(old.(*corev1.Pod)) == generator.PodHealthStatus(pod) {
+				return
+			}
+
+			endpoints, err := endpointsInformer.Informer().GetIndexer().ByIndex(endpointsPodIndex, pod.Namespace+"/"+pod.Name)
+			if err != nil {
+				logger.Errorw("Failed to get the endpoints of the pod", zap.Error(err))
+				return
+			}
+			updated := false
+			for _, eps := range endpoints {
+				if caches.UpdateEndpoints(eps.(*corev1.Endpoints)) {
+					updated = true
+				}
+			}
+			if updated {
+				if err := r.updateEnvoyConfig(configStore.ToContext(ctx)); err != nil {
+					logger.Errorw("Failed to update the endpoints in the envoy config", zap.Error(err))
+				}
+			}
+		},
+	})
+
 	return impl
 }
 
@@ -320,14 +470,23 @@ func getReadyIngresses(ctx context.Context, knativeClient networkingClientSet.Ne
 	}
 	ingressesToWarm := make([]*v1alpha1.Ingress, 0, len(ingresses.Items))
 	for i := range ingresses.Items {
-		ingress := &ingresses.Items[i]
+		ingressesToWarm = append(ingressesToWarm, &ingresses.Items[i])
+	}
+	return filterReadyIngresses(ingressesToWarm), nil
+}
+
+// filterReadyIngresses returns the Kourier ingresses that are configured and not
+// marked for deletion.
+func filterReadyIngresses(ingresses []*v1alpha1.Ingress) []*v1alpha1.Ingress {
+	ready := make([]*v1alpha1.Ingress, 0, len(ingresses))
+	for _, ingress := range ingresses {
 		if isKourierIngress(ingress) &&
 			ingress.GetDeletionTimestamp() == nil && // Ignore ingresses that are already marked for deletion.
 			ingress.GetStatus().GetCondition(v1alpha1.IngressConditionNetworkConfigured).IsTrue() {
-			ingressesToWarm = append(ingressesToWarm, ingress)
+			ready = append(ready, ingress)
 		}
 	}
-	return ingressesToWarm, nil
+	return ready
 }
 
 func readyAddresses(eps *corev1.Endpoints) sets.String {
@@ -350,6 +509,45 @@ func readyAddresses(eps *corev1.Endpoints) sets.String {
 	return ready
 }
 
+// endpointsPodIndex indexes the Endpoints by the namespace/name keys of the pods behind
+// their addresses, ready or not.
+const endpointsPodIndex = "pod"
+
+// endpointsPodIndexFunc returns the keys of the pods behind the addresses of the
+// Endpoints.
+func endpointsPodIndexFunc(obj interface{}) ([]string, error) {
+	eps, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	pods := sets.NewString()
+	for _, subset := range eps.Subsets {
+		for _, addresses := range [][]corev1.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+			for _, address := range addresses {
+				if ref := address.TargetRef; ref != nil && ref.Kind == "Pod" {
+					namespace := ref.Namespace
+					if namespace == "" {
+						namespace = eps.Namespace
+					}
+					pods.Insert(namespace + "/" + ref.Name)
+				}
+			}
+		}
+	}
+	return pods.List(), nil
+}
+
+// newTrustBundleInformer creates an informer for the trust bundle ConfigMaps in the
+// serving namespace. It's not taken from injection to avoid watching all ConfigMaps in
+// the cluster.
+func newTrustBundleInformer(ctx context.Context, kubeClient kubernetes.Interface) cache.SharedIndexInformer {
+	return v1.NewFilteredConfigMapInformer(kubeClient, config.ServingNamespace(), controller.GetResyncPeriod(ctx),
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(opts *metav1.ListOptions) {
+			opts.LabelSelector = config.TrustBundleLabelKey + "=true"
+		})
+}
+
 func getSecretInformer(ctx context.Context) v1.SecretInformer {
 	untyped := ctx.Value(filteredFactory.LabelKey{}) // This should always be not nil and have exactly one selector
 	return secretfilteredinformer.Get(ctx, untyped.([]string)[0])
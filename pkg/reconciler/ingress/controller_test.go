@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEndpointsPodIndexFunc(t *testing.T) {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{
+				IP:        "1.1.1.1",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: "ready"},
+			}, {
+				IP: "2.2.2.2",
+			}},
+			NotReadyAddresses: []corev1.EndpointAddress{{
+				IP:        "3.3.3.3",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "not-ready"},
+			}},
+		}, {
+			Addresses: []corev1.EndpointAddress{{
+				IP:        "1.1.1.1",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: "ready"},
+			}, {
+				IP:        "4.4.4.4",
+				TargetRef: &corev1.ObjectReference{Kind: "Node", Name: "node"},
+			}},
+		}},
+	}
+
+	pods, err := endpointsPodIndexFunc(endpoints)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pods, []string{"ns/not-ready", "ns/ready"})
+
+	_, err = endpointsPodIndexFunc(&corev1.Pod{})
+	assert.ErrorContains(t, err, "unexpected object type")
+}
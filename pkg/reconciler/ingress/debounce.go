@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sync"
+	"time"
+)
+
+// maxDebounceRetryDelay caps the delay of the retries of the failed calls.
+const maxDebounceRetryDelay = time.Minute
+
+// debouncer coalesces all the calls within a window into a single call of its function.
+// Failed calls are retried with an exponential backoff, starting at the window, until
+// one succeeds.
+type debouncer struct {
+	mu      sync.Mutex
+	pending bool
+	// failures is the number of consecutive failed calls.
+	failures int
+
+	// fnMu serializes the calls of the function.
+	fnMu sync.Mutex
+	fn   func() error
+}
+
+func newDebouncer(fn func() error) *debouncer {
+	return &debouncer{fn: fn}
+}
+
+// trigger calls the function once the given window elapsed, unless a call is already
+// pending. Triggers during the call schedule another one, so no trigger gets lost.
+func (d *debouncer) trigger(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.schedule(window, window)
+}
+
+// schedule calls the function after the given delay, unless a call is already pending.
+// d.mu must be held.
+func (d *debouncer) schedule(delay, window time.Duration) {
+	if d.pending {
+		return
+	}
+	d.pending = true
+	time.AfterFunc(delay, func() { d.call(window) })
+}
+
+// call calls the function, and schedules its retry if it fails.
+func (d *debouncer) call(window time.Duration) {
+	d.mu.Lock()
+	d.pending = false
+	d.mu.Unlock()
+
+	d.fnMu.Lock()
+	err := d.fn()
+	d.fnMu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		d.failures = 0
+		return
+	}
+	d.failures++
+	d.schedule(retryDelay(window, d.failures), window)
+}
+
+// retryDelay returns the delay of the retry after the given number of consecutive
+// failures, doubling the window for each of them up to maxDebounceRetryDelay.
+func retryDelay(window time.Duration, failures int) time.Duration {
+	delay := window
+	for i := 1; i < failures && delay < maxDebounceRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDebounceRetryDelay {
+		return maxDebounceRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	d := newDebouncer(func() error {
+		calls <- struct{}{}
+		return nil
+	})
+
+	// A burst of triggers is coalesced into a single call.
+	for i := 0; i < 5; i++ {
+		d.trigger(50 * time.Millisecond)
+	}
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the call")
+	}
+	select {
+	case <-calls:
+		t.Fatal("Got an unexpected second call")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Triggers after the call schedule another one.
+	d.trigger(10 * time.Millisecond)
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the second call")
+	}
+}
+
+func TestDebouncerRetriesFailedCalls(t *testing.T) {
+	var failures int32 = 2
+	calls := make(chan error, 10)
+	d := newDebouncer(func() error {
+		var err error
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			err = errors.New("push failed")
+		}
+		calls <- err
+		return err
+	})
+
+	// The failed calls are retried without another trigger, until one succeeds.
+	d.trigger(10 * time.Millisecond)
+	for i, wantErr := range []bool{true, true, false} {
+		select {
+		case err := <-calls:
+			if (err != nil) != wantErr {
+				t.Fatalf("Call %d returned error %v, want error: %v", i, err, wantErr)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for call %d", i)
+		}
+	}
+	select {
+	case <-calls:
+		t.Fatal("Got an unexpected call after the successful one")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 4, want: 8 * time.Second},
+		{failures: 100, want: maxDebounceRetryDelay},
+	}
+	for _, test := range tests {
+		if got := retryDelay(time.Second, test.failures); got != test.want {
+			t.Errorf("retryDelay(1s, %d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newPodDrainer creates a handler draining the gateway pods as they start terminating,
+// i.e. once their deletion is requested, which happens ahead of their preStop hook.
+// The handler is meant for pods matching the gateway label only.
+func newPodDrainer(logger *zap.SugaredLogger, drain func(ip string) error) cache.ResourceEventHandler {
+	drainIfTerminating := func(obj interface{}) {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.DeletionTimestamp == nil || pod.Status.PodIP == "" {
+			return
+		}
+		// Draining a pod more than once is a no-op.
+		if err := drain(pod.Status.PodIP); err != nil {
+			logger.Errorw("Failed to drain the gateway pod "+pod.Namespace+"/"+pod.Name, zap.Error(err))
+			return
+		}
+		logger.Info("Draining the gateway pod ", pod.Namespace+"/"+pod.Name)
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: drainIfTerminating,
+		UpdateFunc: func(_, new interface{}) {
+			drainIfTerminating(new)
+		},
+	}
+}
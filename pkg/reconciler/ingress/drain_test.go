@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodDrainer(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name   string
+		pod    *corev1.Pod
+		err    error
+		wantIP string
+	}{{
+		name: "terminating",
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway", DeletionTimestamp: &now},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+		},
+		wantIP: "10.0.0.1",
+	}, {
+		name: "running",
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway"},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+		},
+	}, {
+		name: "terminating without IP",
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway", DeletionTimestamp: &now},
+		},
+	}, {
+		name: "failure",
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway", DeletionTimestamp: &now},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+		},
+		err:    errors.New("boom"),
+		wantIP: "10.0.0.1",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			drainer := newPodDrainer(zap.NewNop().Sugar(), func(ip string) error {
+				got = ip
+				return test.err
+			})
+
+			drainer.OnUpdate(&corev1.Pod{}, test.pod)
+			assert.Equal(t, got, test.wantIP)
+
+			got = ""
+			drainer.OnAdd(test.pod)
+			assert.Equal(t, got, test.wantIP)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+
+	"knative.dev/net-kourier/pkg/config"
+	ingressconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmeta"
+	"knative.dev/pkg/network"
+)
+
+// gatewayFleetRef refers to the gateways an Ingress is served by.
+type gatewayFleetRef struct {
+	// name is the name of the gateway fleet, empty for the shared gateways.
+	name string
+	// nodeID is the xDS node ID of the gateways.
+	nodeID string
+
+	externalService string
+	internalService string
+}
+
+// gatewayFleet returns the gateway fleet the Ingress is assigned to by label or
+// annotation, or the shared gateways if none. It returns false if the fleet is not
+// configured.
+func gatewayFleet(ctx context.Context, ing *v1alpha1.Ingress) (gatewayFleetRef, bool) {
+	name := config.GetGatewayFleet(kmeta.UnionMaps(ing.Labels, ing.Annotations))
+	if name == "" {
+		return gatewayFleetRef{
+			nodeID:          config.GatewayNodeID,
+			externalService: config.ExternalServiceName,
+			internalService: config.InternalServiceName,
+		}, true
+	}
+
+	fleet, ok := ingressconfig.FromContextOrDefaults(ctx).Kourier.GatewayFleets[name]
+	return gatewayFleetRef{
+		name:            name,
+		nodeID:          fleet.NodeID,
+		externalService: fleet.ExternalService,
+		internalService: fleet.InternalService,
+	}, ok
+}
+
+// serviceHostnames returns the hostnames of the external and internal services of the
+// gateways.
+func (fleet gatewayFleetRef) serviceHostnames() (string, string) {
+	return network.GetServiceHostname(fleet.externalService, config.GatewayNamespace()),
+		network.GetServiceHostname(fleet.internalService, config.GatewayNamespace())
+}
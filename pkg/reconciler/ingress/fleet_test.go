@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/net-kourier/pkg/config"
+	ingressconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	netconfig "knative.dev/networking/pkg/config"
+)
+
+func TestGatewayFleet(t *testing.T) {
+	ctx := ingressconfig.ToContext(context.Background(), &ingressconfig.Config{
+		Network: &netconfig.Config{},
+		Kourier: &config.Kourier{
+			GatewayFleets: map[string]config.GatewayFleet{
+				"tenant": {NodeID: "kourier-tenant", ExternalService: "kourier-tenant", InternalService: "kourier-internal-tenant"},
+			},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        gatewayFleetRef
+		wantOK      bool
+	}{{
+		name:   "shared gateways",
+		want:   gatewayFleetRef{nodeID: config.GatewayNodeID, externalService: "kourier", internalService: "kourier-internal"},
+		wantOK: true,
+	}, {
+		name:   "fleet by label",
+		labels: map[string]string{config.GatewayFleetAnnotationKey: "tenant"},
+		want:   gatewayFleetRef{name: "tenant", nodeID: "kourier-tenant", externalService: "kourier-tenant", internalService: "kourier-internal-tenant"},
+		wantOK: true,
+	}, {
+		name:        "annotation takes precedence over label",
+		labels:      map[string]string{config.GatewayFleetAnnotationKey: "unknown"},
+		annotations: map[string]string{config.GatewayFleetAnnotationKey: "tenant"},
+		want:        gatewayFleetRef{name: "tenant", nodeID: "kourier-tenant", externalService: "kourier-tenant", internalService: "kourier-internal-tenant"},
+		wantOK:      true,
+	}, {
+		name:        "unknown fleet",
+		annotations: map[string]string{config.GatewayFleetAnnotationKey: "unknown"},
+		want:        gatewayFleetRef{name: "unknown"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := gatewayFleet(ctx, &v1alpha1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Labels: test.labels, Annotations: test.annotations},
+			})
+			assert.Equal(t, ok, test.wantOK)
+			assert.Equal(t, got, test.want)
+		})
+	}
+}
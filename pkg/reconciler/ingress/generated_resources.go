@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmeta"
+)
+
+// wantsGeneratedResources returns whether the Ingress opted in to having the resources
+// generated for it written to a ConfigMap, so that no ConfigMaps are written next to the
+// other Ingresses.
+func wantsGeneratedResources(ing *v1alpha1.Ingress) bool {
+	return strings.EqualFold(config.GetGeneratedResourcesConfigMap(ing.Annotations), "true")
+}
+
+// writeGeneratedResources writes the given resources generated for the Ingress to the
+// ConfigMap next to it, creating it if there's none yet. The ConfigMap is owned by the
+// Ingress, so that it's deleted along with it.
+func writeGeneratedResources(ctx context.Context, client kubernetes.Interface, ing *v1alpha1.Ingress, data map[string]string) error {
+	desired := newGeneratedResourcesConfigMap(ing, data)
+	configMaps := client.CoreV1().ConfigMaps(ing.Namespace)
+	existing, err := configMaps.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, desired, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(existing, ing) {
+		return fmt.Errorf("ConfigMap %s/%s is not owned by the Ingress", existing.Namespace, existing.Name)
+	}
+	if equality.Semantic.DeepEqual(existing.Data, desired.Data) &&
+		existing.Labels[config.GeneratedResourcesLabelKey] == ing.Name {
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels = kmeta.UnionMaps(updated.Labels, desired.Labels)
+	updated.Data = desired.Data
+	_, err = configMaps.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// newGeneratedResourcesConfigMap creates the ConfigMap holding the given resources
+// generated for the Ingress.
+func newGeneratedResourcesConfigMap(ing *v1alpha1.Ingress, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            config.GeneratedResourcesConfigMapName(ing.Name),
+			Namespace:       ing.Namespace,
+			Labels:          map[string]string{config.GeneratedResourcesLabelKey: ing.Name},
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(ing)},
+		},
+		Data: data,
+	}
+}
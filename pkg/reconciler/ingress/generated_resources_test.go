@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestWantsGeneratedResources(t *testing.T) {
+	ing := &v1alpha1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "hello"}}
+	assert.Assert(t, !wantsGeneratedResources(ing))
+
+	ing.Annotations = map[string]string{config.GeneratedResourcesConfigMapAnnotationKey: "false"}
+	assert.Assert(t, !wantsGeneratedResources(ing))
+
+	ing.Annotations = map[string]string{config.GeneratedResourcesConfigMapAnnotationKey: "true"}
+	assert.Assert(t, wantsGeneratedResources(ing))
+}
+
+func TestWriteGeneratedResources(t *testing.T) {
+	ctx := context.Background()
+	ing := &v1alpha1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "hello", UID: "uid"}}
+	client := fake.NewSimpleClientset()
+
+	get := func() *corev1.ConfigMap {
+		cm, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "hello-kourier-resources", metav1.GetOptions{})
+		assert.NilError(t, err)
+		return cm
+	}
+
+	assert.NilError(t, writeGeneratedResources(ctx, client, ing, map[string]string{"clusters.json": "[]"}))
+	cm := get()
+	assert.DeepEqual(t, cm.Data, map[string]string{"clusters.json": "[]"})
+	assert.Equal(t, cm.Labels[config.GeneratedResourcesLabelKey], "hello")
+	assert.Assert(t, metav1.IsControlledBy(cm, ing))
+
+	// The ConfigMap is updated along with the resources, keeping the labels of others.
+	cm.Labels["other"] = "kept"
+	_, err := client.CoreV1().ConfigMaps("ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, writeGeneratedResources(ctx, client, ing, map[string]string{"clusters.json": "[{}]"}))
+	cm = get()
+	assert.DeepEqual(t, cm.Data, map[string]string{"clusters.json": "[{}]"})
+	assert.Equal(t, cm.Labels["other"], "kept")
+
+	// ConfigMaps of the same name not owned by the Ingress are left alone.
+	other := &v1alpha1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "hello", UID: "other"}}
+	assert.ErrorContains(t, writeGeneratedResources(ctx, client, other, map[string]string{"clusters.json": "[]"}), "not owned")
+	assert.DeepEqual(t, get().Data, map[string]string{"clusters.json": "[{}]"})
+}
@@ -20,26 +20,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"knative.dev/net-kourier/pkg/config"
 	envoy "knative.dev/net-kourier/pkg/envoy/server"
 	"knative.dev/net-kourier/pkg/generator"
 	ingressconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/net-kourier/pkg/status"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
-	"knative.dev/networking/pkg/status"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 )
 
 const (
-	conflictReason      = "DomainConflict"
-	notReconciledReason = "ReconcileIngressFailed"
+	conflictReason              = "DomainConflict"
+	invalidListenerPortReason   = "InvalidListenerPort"
+	invalidListenerPoolReason   = "InvalidListenerPool"
+	invalidGatewayFleetReason   = "InvalidGatewayFleet"
+	invalidTLSPassthroughReason = "InvalidTLSPassthrough"
+	notReconciledReason         = "ReconcileIngressFailed"
 )
 
 type Reconciler struct {
+	kubeClient        kubernetes.Interface
 	xdsServer         *envoy.XdsServer
 	caches            *generator.Caches
 	statusManager     *status.Prober
@@ -50,6 +66,24 @@ type Reconciler struct {
 	// resyncConflicts triggers a filtered global resync to reenqueue all ingresses in
 	// a "Conflict" state.
 	resyncConflicts func()
+
+	// snapshotDebouncer coalesces the pushes of the Envoy config within the configured
+	// debounce window into a single push.
+	snapshotDebouncer *debouncer
+
+	// fleetNodeIDsMu guards fleetNodeIDs.
+	fleetNodeIDsMu sync.Mutex
+	// fleetNodeIDs are the node IDs of the gateway fleets snapshots were pushed for, to
+	// drop their snapshots once the fleets are removed.
+	fleetNodeIDs sets.String
+
+	// snapshotVersionsMu guards snapshotVersions.
+	snapshotVersionsMu sync.Mutex
+	// snapshotVersions are the versions of the snapshots last pushed, keyed by node ID.
+	snapshotVersions map[string]string
+
+	// tracer traces the propagation of the config of the Ingresses to the gateways.
+	tracer controlPathTracer
 }
 
 var _ ingress.Interface = (*Reconciler)(nil)
@@ -58,28 +92,73 @@ var _ ingress.Finalizer = (*Reconciler)(nil)
 var _ reconciler.OnDeletionInterface = (*Reconciler)(nil)
 
 func (r *Reconciler) ReconcileKind(ctx context.Context, ing *v1alpha1.Ingress) reconciler.Event {
+	ctx, span := r.tracer.startSpan(ctx, reconcileSpanName,
+		trace.StringAttribute("ingress.namespace", ing.Namespace),
+		trace.StringAttribute("ingress.name", ing.Name))
+	defer span.End()
+
 	ing.SetDefaults(ctx)
 	before := ing.DeepCopy()
 
-	if err := r.updateIngress(ctx, ing); errors.Is(err, generator.ErrDomainConflict) {
+	err := r.updateIngress(ctx, ing)
+	r.recordStaleTranslations(ctx)
+	if errors.Is(err, generator.ErrStaleTranslation) {
+		// The gateways keep serving the Ingress as it was last translated, so its status
+		// is left as is while retrying.
+		logging.FromContext(ctx).Warnw("Failed to translate the Ingress, serving its last translation", zap.Error(err))
+		return fmt.Errorf("failed to update ingress: %w", err)
+	} else if errors.Is(err, generator.ErrDomainConflict) {
 		// If we had an error due to a duplicated domain, we must mark the ingress as failed with a
 		// custom status. We don't want to return an error in this case as we want to update its status.
 		logging.FromContext(ctx).Info(err.Error())
 		ing.Status.MarkLoadBalancerFailed(conflictReason, "Ingress rejected: "+err.Error())
 		return nil
+	} else if errors.Is(err, generator.ErrInvalidListenerPort) {
+		// Changes to the namespace don't trigger a reconciliation, so keep retrying
+		// until the annotation is fixed.
+		ing.Status.MarkLoadBalancerFailed(invalidListenerPortReason, "Ingress rejected: "+err.Error())
+		return fmt.Errorf("failed to update ingress: %w", err)
+	} else if errors.Is(err, generator.ErrInvalidListenerPool) {
+		// Changes to the listener pools trigger a global resync, so there's no need to retry.
+		logging.FromContext(ctx).Info(err.Error())
+		ing.Status.MarkLoadBalancerFailed(invalidListenerPoolReason, "Ingress rejected: "+err.Error())
+		return nil
+	} else if errors.Is(err, generator.ErrInvalidTLSPassthrough) {
+		// The TLS passthrough only depends on the Ingress itself, so there's no need to retry.
+		logging.FromContext(ctx).Info(err.Error())
+		ing.Status.MarkLoadBalancerFailed(invalidTLSPassthroughReason, "Ingress rejected: "+err.Error())
+		return nil
 	} else if err != nil {
 		ing.Status.MarkIngressNotReady(notReconciledReason, err.Error())
 		return fmt.Errorf("failed to update ingress: %w", err)
 	}
 
+	fleet, ok := gatewayFleet(ctx, ing)
+	if !ok {
+		// Changes to the gateway fleets trigger a global resync, so there's no need to retry.
+		logging.FromContext(ctx).Infof("Ingress assigned to unknown gateway fleet %q", fleet.name)
+		ing.Status.MarkLoadBalancerFailed(invalidGatewayFleetReason,
+			fmt.Sprintf("Ingress rejected: gateway fleet %q is not configured", fleet.name))
+		return nil
+	}
+
+	r.setGeneratedResources(ctx, ing, fleet)
+	if ingressconfig.FromContextOrDefaults(ctx).Kourier.GeneratedResourcesConfigMaps && wantsGeneratedResources(ing) {
+		// The ConfigMap is merely for inspection, so failing to write it doesn't keep the
+		// Ingress from becoming ready.
+		if err := r.writeGeneratedResources(ctx, ing); err != nil {
+			logging.FromContext(ctx).Errorw("Failed to write the generated resources", zap.Error(err))
+		}
+	}
+
 	ing.Status.MarkNetworkConfigured()
-	if !ing.IsReady() || !isExpectedLoadBalancer(ing) {
+	if !ing.IsReady() || !isExpectedLoadBalancer(ing, fleet) {
 		ready, err := r.statusManager.IsReady(ctx, before)
 		if err != nil {
 			return fmt.Errorf("failed to probe Ingress: %w", err)
 		}
 		if ready {
-			external, internal := config.ServiceHostnames()
+			external, internal := fleet.serviceHostnames()
 
 			if ingressconfig.FromContextOrDefaults(ctx).Kourier.TrafficIsolation == config.IsolationIngressPort {
 				ns, err := r.namespaceLister.Get(ing.Namespace)
@@ -103,12 +182,76 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, ing *v1alpha1.Ingress) r
 		}
 	}
 
+	return requeueAtScheduleChange(ing)
+}
+
+// requeueAtScheduleChange requeues the Ingress at the next change of the routing of its
+// schedule, if it has one, to route its requests accordingly from then on.
+func requeueAtScheduleChange(ing *v1alpha1.Ingress) reconciler.Event {
+	now := time.Now()
+	if next, ok := generator.NextScheduleChange(ing, now); ok {
+		return controller.NewRequeueAfter(next.Sub(now))
+	}
 	return nil
 }
 
+// setGeneratedResources sets the status annotations of the Ingress telling the resources
+// generated for it, along with the version of the snapshot they were pushed in. The
+// version is unknown until the snapshot is pushed, which is deferred with a debounce
+// window configured.
+func (r *Reconciler) setGeneratedResources(ctx context.Context, ing *v1alpha1.Ingress, fleet gatewayFleetRef) {
+	resources, ok := r.caches.GeneratedResources(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+	if !ok {
+		return
+	}
+
+	var version string
+	if ingressconfig.FromContextOrDefaults(ctx).Kourier.SnapshotDebounceWindow <= 0 {
+		r.snapshotVersionsMu.Lock()
+		version = r.snapshotVersions[fleet.nodeID]
+		r.snapshotVersionsMu.Unlock()
+	}
+	setGeneratedResourcesAnnotations(ing, resources, version)
+}
+
+// writeGeneratedResources writes the resources generated for the Ingress to the ConfigMap
+// next to it, if it's in the caches.
+func (r *Reconciler) writeGeneratedResources(ctx context.Context, ing *v1alpha1.Ingress) error {
+	data, ok, err := r.caches.GeneratedResourcesJSON(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the generated resources: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	return writeGeneratedResources(ctx, r.kubeClient, ing, data)
+}
+
+// setGeneratedResourcesAnnotations sets the status annotations of the Ingress telling the
+// given resources and snapshot version. The version and conflicts annotations are dropped
+// if empty.
+func setGeneratedResourcesAnnotations(ing *v1alpha1.Ingress, resources generator.GeneratedResources, version string) {
+	annotations := kmeta.UnionMaps(ing.Status.Annotations, map[string]string{
+		config.RoutesStatusAnnotationKey:     strconv.Itoa(resources.Routes),
+		config.ClustersStatusAnnotationKey:   strconv.Itoa(resources.Clusters),
+		config.SNIMatchesStatusAnnotationKey: strconv.Itoa(resources.SNIMatches),
+	})
+	if version != "" {
+		annotations[config.SnapshotVersionStatusAnnotationKey] = version
+	} else {
+		delete(annotations, config.SnapshotVersionStatusAnnotationKey)
+	}
+	if len(resources.AnnotationConflicts) != 0 {
+		annotations[config.AnnotationConflictsStatusAnnotationKey] = strings.Join(resources.AnnotationConflicts, "; ")
+	} else {
+		delete(annotations, config.AnnotationConflictsStatusAnnotationKey)
+	}
+	ing.Status.Annotations = annotations
+}
+
 // isExpectedLoadBalancer verifies if expected Loadbalancer is set in status field.
-func isExpectedLoadBalancer(ing *v1alpha1.Ingress) bool {
-	external, internal := config.ServiceHostnames()
+func isExpectedLoadBalancer(ing *v1alpha1.Ingress, fleet gatewayFleetRef) bool {
+	external, internal := fleet.serviceHostnames()
 	if ing.Status.PublicLoadBalancer == nil || len(ing.Status.PublicLoadBalancer.Ingress) < 1 ||
 		ing.Status.PublicLoadBalancer.Ingress[0].DomainInternal != external {
 		return false
@@ -131,7 +274,7 @@ func (r *Reconciler) ObserveKind(ctx context.Context, ing *v1alpha1.Ingress) rec
 		return fmt.Errorf("failed to update ingress: %w", err)
 	}
 
-	return nil
+	return requeueAtScheduleChange(ing)
 }
 
 func (r *Reconciler) ObserveDeletion(ctx context.Context, key types.NamespacedName) error {
@@ -139,10 +282,12 @@ func (r *Reconciler) ObserveDeletion(ctx context.Context, key types.NamespacedNa
 	logger.Infof("Ingress deleted, updating config")
 
 	r.statusManager.CancelIngressProbingByKey(key)
+	r.ingressTranslator.ForgetIngress(key)
 
 	if err := r.caches.DeleteIngressInfo(ctx, key.Name, key.Namespace); err != nil {
 		return err
 	}
+	r.recordStaleTranslations(ctx)
 
 	if err := r.updateEnvoyConfig(ctx); err != nil {
 		return fmt.Errorf("failed updating envoy config: %w", err)
@@ -161,22 +306,95 @@ func (r *Reconciler) updateIngress(ctx context.Context, ingress *v1alpha1.Ingres
 	logger := logging.FromContext(ctx)
 	logger.Infof("Updating Ingress")
 
-	if err := generator.UpdateInfoForIngress(
-		ctx, r.caches, ingress, r.ingressTranslator, r.extAuthz); err != nil {
+	start := time.Now()
+	translateCtx, span := r.tracer.startSpan(ctx, translateSpanName)
+	err := generator.UpdateInfoForIngress(translateCtx, r.caches, ingress, r.ingressTranslator, r.extAuthz)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	span.End()
+	recordTranslation(ctx, start, err)
+	r.tracer.markReconciled(ctx)
+	if errors.Is(err, generator.ErrStaleTranslation) {
+		// Publish the last translation along with the changes of the other ingresses,
+		// and still fail the reconciliation to retry the translation.
+		if uerr := r.updateEnvoyConfig(ctx); uerr != nil {
+			return uerr
+		}
+		return err
+	} else if err != nil {
 		return err
 	}
 
 	return r.updateEnvoyConfig(ctx)
 }
 
+// updateEnvoyConfig pushes the Envoy config to the gateways. With a debounce window
+// configured, the push is deferred to coalesce it with the following ones.
 func (r *Reconciler) updateEnvoyConfig(ctx context.Context) error {
+	window := ingressconfig.FromContextOrDefaults(ctx).Kourier.SnapshotDebounceWindow
+	if window > 0 && r.snapshotDebouncer != nil {
+		r.snapshotDebouncer.trigger(window)
+		return nil
+	}
+	return r.pushEnvoyConfig(ctx)
+}
+
+func (r *Reconciler) pushEnvoyConfig(ctx context.Context) error {
 	logger := logging.FromContext(ctx)
 	logger.Debugf("Preparing Envoy Snapshot")
 
-	newSnapshot, err := r.caches.ToEnvoySnapshot(ctx)
+	ctx, span := r.tracer.startPush(ctx)
+	defer span.End()
+
+	start := time.Now()
+	snapshots, err := r.caches.ToEnvoySnapshots(ctx)
 	if err != nil {
 		return err
 	}
+	recordSnapshotBuild(ctx, start)
 
-	return r.xdsServer.SetSnapshot(nodeID, newSnapshot)
+	var previous map[string]envoycache.ResourceSnapshot
+	if ingressconfig.FromContextOrDefaults(ctx).Kourier.SnapshotDiffLogging {
+		previous = r.xdsServer.Snapshots()
+	}
+
+	versions := make(map[string]string, len(snapshots))
+	for id, snapshot := range snapshots {
+		versions[id] = generator.SnapshotVersion(snapshot)
+		setCtx, setSpan := r.tracer.startSpan(ctx, snapshotSetSpanName,
+			trace.StringAttribute("node", id), trace.StringAttribute("version", versions[id]))
+		err := r.xdsServer.SetSnapshot(id, snapshot)
+		setSpan.End()
+		if err != nil {
+			return err
+		}
+		r.tracer.awaitAck(setCtx, id, snapshot)
+		recordSnapshotPush(ctx, id, snapshot)
+
+		if previous != nil {
+			if diff := generator.DiffSnapshots(previous[id], snapshot); !diff.Empty() {
+				logger.Infow("Pushed snapshot changes", zap.String("node", id), zap.String("version", versions[id]), zap.Any("diff", diff))
+			}
+		}
+	}
+
+	r.snapshotVersionsMu.Lock()
+	r.snapshotVersions = versions
+	r.snapshotVersionsMu.Unlock()
+
+	r.clearRemovedFleets(sets.StringKeySet(snapshots))
+	return nil
+}
+
+// clearRemovedFleets drops the snapshots of the gateway fleets which are no longer
+// configured, so that their gateways don't keep serving the last config pushed to them.
+func (r *Reconciler) clearRemovedFleets(nodeIDs sets.String) {
+	r.fleetNodeIDsMu.Lock()
+	defer r.fleetNodeIDsMu.Unlock()
+
+	for _, id := range r.fleetNodeIDs.Difference(nodeIDs).UnsortedList() {
+		r.xdsServer.ClearSnapshot(id)
+	}
+	r.fleetNodeIDs = nodeIDs
 }
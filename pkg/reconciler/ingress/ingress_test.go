@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/net-kourier/pkg/generator"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestSetGeneratedResourcesAnnotations(t *testing.T) {
+	ing := &v1alpha1.Ingress{}
+	ing.Status.Annotations = map[string]string{"other": "kept"}
+
+	setGeneratedResourcesAnnotations(ing, generator.GeneratedResources{
+		Routes:              4,
+		Clusters:            2,
+		SNIMatches:          1,
+		AnnotationConflicts: []string{"a is ignored in favor of b", "c is ignored in favor of b"},
+	}, "v1")
+	assert.DeepEqual(t, ing.Status.Annotations, map[string]string{
+		"other":                                       "kept",
+		config.RoutesStatusAnnotationKey:              "4",
+		config.ClustersStatusAnnotationKey:            "2",
+		config.SNIMatchesStatusAnnotationKey:          "1",
+		config.SnapshotVersionStatusAnnotationKey:     "v1",
+		config.AnnotationConflictsStatusAnnotationKey: "a is ignored in favor of b; c is ignored in favor of b",
+	})
+
+	// The version is dropped while unknown, rather than telling a stale one, and so are
+	// the resolved conflicts.
+	setGeneratedResourcesAnnotations(ing, generator.GeneratedResources{Routes: 2, Clusters: 1}, "")
+	assert.DeepEqual(t, ing.Status.Annotations, map[string]string{
+		"other":                              "kept",
+		config.RoutesStatusAnnotationKey:     "2",
+		config.ClustersStatusAnnotationKey:   "1",
+		config.SNIMatchesStatusAnnotationKey: "0",
+	})
+}
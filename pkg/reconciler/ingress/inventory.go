@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"knative.dev/net-kourier/pkg/generator"
+)
+
+const (
+	// InventoryPort is the port the inventory of the external hosts is served on.
+	InventoryPort = 18001
+
+	// InventoryPath is the path the inventory of the external hosts is served on.
+	InventoryPath = "/inventory"
+)
+
+// newInventoryHandler creates a handler serving the inventory of the external hosts as
+// JSON or, with the "format=csv" query parameter, as CSV.
+func newInventoryHandler(logger *zap.SugaredLogger, inventory func() []generator.InventoryEntry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(inventory())
+		case "csv":
+			entries := inventory()
+			records := make([][]string, 0, len(entries)+1)
+			records = append(records, []string{"host", "path", "services", "tls", "ingress"})
+			for _, entry := range entries {
+				records = append(records, []string{
+					entry.Host, entry.Path, strings.Join(entry.Services, " "), strconv.FormatBool(entry.TLS), entry.Ingress,
+				})
+			}
+
+			w.Header().Set("Content-Type", "text/csv")
+			err = csv.NewWriter(w).WriteAll(records)
+		default:
+			http.Error(w, "unknown format: "+format, http.StatusBadRequest)
+		}
+		if err != nil {
+			logger.Errorw("Failed to write the inventory", zap.Error(err))
+		}
+	})
+}
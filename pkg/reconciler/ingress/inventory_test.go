@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+	"knative.dev/net-kourier/pkg/generator"
+)
+
+func TestInventoryHandler(t *testing.T) {
+	handler := newInventoryHandler(zap.NewNop().Sugar(), func() []generator.InventoryEntry {
+		return []generator.InventoryEntry{{
+			Host:     "foo.example.com",
+			Path:     "/",
+			Services: []string{"ns/v1", "ns/v2"},
+			TLS:      true,
+			Ingress:  "ns/foo",
+		}}
+	})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{{
+		name:       "json by default",
+		wantStatus: http.StatusOK,
+		wantBody:   `[{"host":"foo.example.com","path":"/","services":["ns/v1","ns/v2"],"tls":true,"ingress":"ns/foo"}]` + "\n",
+	}, {
+		name:       "csv",
+		query:      "?format=csv",
+		wantStatus: http.StatusOK,
+		wantBody:   "host,path,services,tls,ingress\nfoo.example.com,/,ns/v1 ns/v2,true,ns/foo\n",
+	}, {
+		name:       "unknown format",
+		query:      "?format=xml",
+		wantStatus: http.StatusBadRequest,
+		wantBody:   "unknown format: xml\n",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, InventoryPath+test.query, nil))
+
+			assert.Equal(t, rec.Code, test.wantStatus)
+			assert.Equal(t, rec.Body.String(), test.wantBody)
+		})
+	}
+}
@@ -23,19 +23,26 @@ import (
 	"strconv"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/net-kourier/pkg/generator"
 	ingressconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/net-kourier/pkg/status"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
-	"knative.dev/networking/pkg/status"
 )
 
-func NewProbeTargetLister(logger *zap.SugaredLogger, endpointsLister corev1listers.EndpointsLister, namespaceLister corev1listers.NamespaceLister) status.ProbeTargetLister {
+// NewProbeTargetLister creates a lister of the targets probing every host of the ingress,
+// including the external hosts generated for it besides the hosts of its rules, as
+// returned by externalHosts.
+func NewProbeTargetLister(logger *zap.SugaredLogger, endpointsLister corev1listers.EndpointsLister, namespaceLister corev1listers.NamespaceLister,
+	externalHosts func(types.NamespacedName) ([]string, bool)) status.ProbeTargetLister {
 	return &gatewayPodTargetLister{
 		logger:          logger,
 		endpointsLister: endpointsLister,
 		namespaceLister: namespaceLister,
+		externalHosts:   externalHosts,
 	}
 }
 
@@ -43,10 +50,20 @@ type gatewayPodTargetLister struct {
 	logger          *zap.SugaredLogger
 	endpointsLister corev1listers.EndpointsLister
 	namespaceLister corev1listers.NamespaceLister
+	externalHosts   func(types.NamespacedName) ([]string, bool)
 }
 
 func (l *gatewayPodTargetLister) ListProbeTargets(ctx context.Context, ing *v1alpha1.Ingress) ([]status.ProbeTarget, error) {
-	eps, err := l.endpointsLister.Endpoints(config.GatewayNamespace()).Get(config.InternalServiceName)
+	fleet, ok := gatewayFleet(ctx, ing)
+	if !ok {
+		return nil, fmt.Errorf("gateway fleet %q is not configured", fleet.name)
+	}
+	if generator.IsTLSPassthrough(ing) {
+		// The backends of passed through connections can't be probed via HTTP.
+		return nil, nil
+	}
+
+	eps, err := l.endpointsLister.Endpoints(config.GatewayNamespace()).Get(fleet.internalService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get internal service: %w", err)
 	}
@@ -65,29 +82,39 @@ func (l *gatewayPodTargetLister) ListProbeTargets(ctx context.Context, ing *v1al
 
 func (l *gatewayPodTargetLister) getIngressUrls(ctx context.Context, ing *v1alpha1.Ingress, gatewayIps []string) ([]status.ProbeTarget, error) {
 	ips := sets.NewString(gatewayIps...)
+	kourierConfig := ingressconfig.FromContextOrDefaults(ctx).Kourier
+
+	// externalTarget probes the given external hosts.
+	externalTarget := func(domains []string) status.ProbeTarget {
+		if len(ing.Spec.TLS) != 0 && !kourierConfig.DisableTLSTermination {
+			return status.ProbeTarget{
+				PodIPs:  ips,
+				PodPort: strconv.Itoa(int(probePort(kourierConfig.StatusProbeHTTPSPort, config.HTTPSPortProb))),
+				URLs:    domainsToURL(domains, "https"),
+			}
+		}
+		return status.ProbeTarget{
+			PodIPs:  ips,
+			PodPort: strconv.Itoa(int(probePort(kourierConfig.StatusProbeHTTPPort, config.HTTPPortProb))),
+			URLs:    domainsToURL(domains, "http"),
+		}
+	}
 
-	targets := make([]status.ProbeTarget, 0, len(ing.Spec.Rules))
+	targets := make([]status.ProbeTarget, 0, len(ing.Spec.Rules)+1)
+	ruleHosts := sets.NewString()
 	for _, rule := range ing.Spec.Rules {
 		var target status.ProbeTarget
 
 		domains := rule.Hosts
 		scheme := "http"
+		ruleHosts.Insert(domains...)
 
 		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
-			target = status.ProbeTarget{
-				PodIPs: ips,
-			}
-			if len(ing.Spec.TLS) != 0 {
-				target.PodPort = strconv.Itoa(int(config.HTTPSPortProb))
-				target.URLs = domainsToURL(domains, "https")
-			} else {
-				target.PodPort = strconv.Itoa(int(config.HTTPPortProb))
-				target.URLs = domainsToURL(domains, scheme)
-			}
+			target = externalTarget(domains)
 		} else {
 			podPort := strconv.Itoa(int(config.HTTPPortInternal))
 
-			if ingressconfig.FromContextOrDefaults(ctx).Kourier.TrafficIsolation == config.IsolationIngressPort {
+			if kourierConfig.TrafficIsolation == config.IsolationIngressPort {
 				ns, err := l.namespaceLister.Get(ing.Namespace)
 				if err != nil {
 					return nil, fmt.Errorf("failed to get the ingress namespace: %w", err)
@@ -96,6 +123,10 @@ func (l *gatewayPodTargetLister) getIngressUrls(ctx context.Context, ing *v1alph
 				if ns.Annotations != nil {
 					if value, ok := ns.Annotations[config.ListenerPortAnnotationKey]; ok {
 						podPort = value
+						// A dedicated listener with TLS serves HTTPS only.
+						if ns.Annotations[config.ListenerTLSSecretAnnotationKey] != "" {
+							scheme = "https"
+						}
 					}
 				}
 			}
@@ -110,9 +141,32 @@ func (l *gatewayPodTargetLister) getIngressUrls(ctx context.Context, ing *v1alph
 		targets = append(targets, target)
 
 	}
+
+	// The external hosts generated besides the hosts of the rules, e.g. to redirect, are
+	// probed as well, so that the ingress is only ready once all of them are served.
+	if l.externalHosts != nil {
+		generatedHosts, _ := l.externalHosts(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+		var extraHosts []string
+		for _, host := range generatedHosts {
+			if !ruleHosts.Has(host) {
+				extraHosts = append(extraHosts, host)
+			}
+		}
+		if len(extraHosts) != 0 {
+			targets = append(targets, externalTarget(extraHosts))
+		}
+	}
 	return targets, nil
 }
 
+// probePort returns the configured port, or the given default if it isn't configured.
+func probePort(configured, defaultPort uint32) uint32 {
+	if configured != 0 {
+		return configured
+	}
+	return defaultPort
+}
+
 func domainsToURL(domains []string, scheme string) []*url.URL {
 	urls := make([]*url.URL, 0, len(domains))
 	for _, domain := range domains {
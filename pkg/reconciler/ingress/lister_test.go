@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/net-kourier/pkg/config"
+	ingressconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/net-kourier/pkg/status"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	netconfig "knative.dev/networking/pkg/config"
+	"knative.dev/pkg/logging"
+)
+
+func TestGetIngressUrls(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ing"},
+		Spec: v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{"ing.example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+			}, {
+				Hosts:      []string{"ing.ns.svc.cluster.local"},
+				Visibility: v1alpha1.IngressVisibilityClusterLocal,
+			}},
+		},
+	}
+	externalHosts := func(name types.NamespacedName) ([]string, bool) {
+		assert.Equal(t, name, types.NamespacedName{Namespace: "ns", Name: "ing"})
+		return []string{"ing.example.com", "www.ing.example.com"}, true
+	}
+	urls := func(scheme string, hosts ...string) []*url.URL {
+		return domainsToURL(hosts, scheme)
+	}
+	ips := sets.NewString("10.0.0.1")
+
+	tests := []struct {
+		name   string
+		config *config.Kourier
+		tls    bool
+		want   []status.ProbeTarget
+	}{{
+		name:   "probe listeners",
+		config: &config.Kourier{},
+		want: []status.ProbeTarget{
+			{PodIPs: ips, PodPort: "8090", URLs: urls("http", "ing.example.com")},
+			{PodIPs: ips, PodPort: "8081", URLs: urls("http", "ing.ns.svc.cluster.local")},
+			{PodIPs: ips, PodPort: "8090", URLs: urls("http", "www.ing.example.com")},
+		},
+	}, {
+		name:   "probe listeners with TLS",
+		config: &config.Kourier{},
+		tls:    true,
+		want: []status.ProbeTarget{
+			{PodIPs: ips, PodPort: "9443", URLs: urls("https", "ing.example.com")},
+			{PodIPs: ips, PodPort: "8081", URLs: urls("http", "ing.ns.svc.cluster.local")},
+			{PodIPs: ips, PodPort: "9443", URLs: urls("https", "www.ing.example.com")},
+		},
+	}, {
+		name:   "configured ports",
+		config: &config.Kourier{StatusProbeHTTPPort: 8080, StatusProbeHTTPSPort: 8443},
+		tls:    true,
+		want: []status.ProbeTarget{
+			{PodIPs: ips, PodPort: "8443", URLs: urls("https", "ing.example.com")},
+			{PodIPs: ips, PodPort: "8081", URLs: urls("http", "ing.ns.svc.cluster.local")},
+			{PodIPs: ips, PodPort: "8443", URLs: urls("https", "www.ing.example.com")},
+		},
+	}, {
+		name:   "TLS termination disabled",
+		config: &config.Kourier{DisableTLSTermination: true},
+		tls:    true,
+		want: []status.ProbeTarget{
+			{PodIPs: ips, PodPort: "8090", URLs: urls("http", "ing.example.com")},
+			{PodIPs: ips, PodPort: "8081", URLs: urls("http", "ing.ns.svc.cluster.local")},
+			{PodIPs: ips, PodPort: "8090", URLs: urls("http", "www.ing.example.com")},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := ingressconfig.ToContext(context.Background(), &ingressconfig.Config{
+				Network: &netconfig.Config{},
+				Kourier: test.config,
+			})
+			ing := ing.DeepCopy()
+			if test.tls {
+				ing.Spec.TLS = []v1alpha1.IngressTLS{{Hosts: []string{"ing.example.com"}, SecretName: "secret"}}
+			}
+
+			lister := NewProbeTargetLister(logging.FromContext(ctx), nil, nil, externalHosts).(*gatewayPodTargetLister)
+			got, err := lister.getIngressUrls(ctx, ing, ips.List())
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, test.want)
+		})
+	}
+}
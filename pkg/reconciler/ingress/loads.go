@@ -0,0 +1,39 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	envoy "knative.dev/net-kourier/pkg/envoy/server"
+)
+
+// LoadsPath is the path the load of the clusters reported by the gateways is served on,
+// next to the inventory.
+const LoadsPath = "/loads"
+
+// newLoadsHandler creates a handler serving the load of the clusters as JSON.
+func newLoadsHandler(logger *zap.SugaredLogger, loads func() []envoy.ClusterLoad) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(loads()); err != nil {
+			logger.Errorw("Failed to write the loads", zap.Error(err))
+		}
+	})
+}
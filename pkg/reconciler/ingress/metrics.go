@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"time"
+
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"google.golang.org/protobuf/proto"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	// translationDurationM is the time taken to translate an Ingress and update the
+	// caches with it.
+	translationDurationM = stats.Float64(
+		"translation_duration_seconds",
+		"Time taken to translate an ingress into Envoy configuration",
+		stats.UnitSeconds)
+
+	// snapshotBuildDurationM is the time taken to build the snapshots of all the gateway
+	// fleets from the caches.
+	snapshotBuildDurationM = stats.Float64(
+		"snapshot_build_duration_seconds",
+		"Time taken to build the snapshots of the gateways",
+		stats.UnitSeconds)
+
+	// snapshotResourceCountM is the number of resources of each type in the snapshot of
+	// a node ID.
+	snapshotResourceCountM = stats.Int64(
+		"snapshot_resource_count",
+		"Number of resources in the snapshot of the gateways",
+		stats.UnitDimensionless)
+
+	// snapshotSizeM is the size of the resources in the snapshot of a node ID, encoded
+	// as protobuf.
+	snapshotSizeM = stats.Int64(
+		"snapshot_size_bytes",
+		"Size of the resources in the snapshot of the gateways",
+		stats.UnitBytes)
+
+	// snapshotPushCountM counts the snapshots pushed to the gateways of a node ID.
+	snapshotPushCountM = stats.Int64(
+		"snapshot_push_count",
+		"Number of snapshots pushed to the gateways",
+		stats.UnitDimensionless)
+
+	// snapshotNackCountM counts the resources rejected by the gateways.
+	snapshotNackCountM = stats.Int64(
+		"snapshot_nack_count",
+		"Number of responses rejected by the gateways",
+		stats.UnitDimensionless)
+
+	resultTagKey       = tag.MustNewKey("result")
+	resourceTypeTagKey = tag.MustNewKey("resource_type")
+
+	// snapshotResourceTypes are the types of the resources the snapshots are measured by.
+	snapshotResourceTypes = []string{
+		resource.ClusterType,
+		resource.EndpointType,
+		resource.ListenerType,
+		resource.RouteType,
+		resource.VirtualHostType,
+		resource.SecretType,
+	}
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: translationDurationM.Description(),
+		Measure:     translationDurationM,
+		Aggregation: view.Distribution(metrics.BucketsNBy10(0.0001, 6)...),
+		TagKeys:     []tag.Key{resultTagKey},
+	}, &view.View{
+		Description: snapshotBuildDurationM.Description(),
+		Measure:     snapshotBuildDurationM,
+		Aggregation: view.Distribution(metrics.BucketsNBy10(0.0001, 6)...),
+	}, &view.View{
+		Description: snapshotResourceCountM.Description(),
+		Measure:     snapshotResourceCountM,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{nodeIDTagKey, resourceTypeTagKey},
+	}, &view.View{
+		Description: snapshotSizeM.Description(),
+		Measure:     snapshotSizeM,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{nodeIDTagKey},
+	}, &view.View{
+		Description: snapshotPushCountM.Description(),
+		Measure:     snapshotPushCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{nodeIDTagKey},
+	}, &view.View{
+		Description: snapshotNackCountM.Description(),
+		Measure:     snapshotNackCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{resourceTypeTagKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// recordTranslation records the time taken to translate an Ingress since the given
+// start, tagged by whether it succeeded.
+func recordTranslation(ctx context.Context, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	ctx, tagErr := tag.New(ctx, tag.Upsert(resultTagKey, result))
+	if tagErr != nil {
+		return
+	}
+	metrics.Record(ctx, translationDurationM.M(time.Since(start).Seconds()))
+}
+
+// recordSnapshotBuild records the time taken to build the snapshots since the given
+// start.
+func recordSnapshotBuild(ctx context.Context, start time.Time) {
+	metrics.Record(ctx, snapshotBuildDurationM.M(time.Since(start).Seconds()))
+}
+
+// recordSnapshotPush counts the push of the given snapshot to the gateways of the given
+// node ID, and records its number of resources and size.
+func recordSnapshotPush(ctx context.Context, nodeID string, snapshot envoycache.ResourceSnapshot) {
+	ctx, err := tag.New(ctx, tag.Upsert(nodeIDTagKey, nodeID))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, snapshotPushCountM.M(1))
+
+	counts, size := snapshotSize(snapshot)
+	for _, typeURL := range snapshotResourceTypes {
+		typeCtx, err := tag.New(ctx, tag.Upsert(resourceTypeTagKey, typeURL))
+		if err != nil {
+			return
+		}
+		metrics.Record(typeCtx, snapshotResourceCountM.M(int64(counts[typeURL])))
+	}
+	metrics.Record(ctx, snapshotSizeM.M(int64(size)))
+}
+
+// snapshotSize returns the number of resources of each type in the given snapshot, and
+// their size encoded as protobuf.
+func snapshotSize(snapshot envoycache.ResourceSnapshot) (map[string]int, int) {
+	counts := make(map[string]int, len(snapshotResourceTypes))
+	var size int
+	for _, typeURL := range snapshotResourceTypes {
+		resources := snapshot.GetResources(typeURL)
+		for _, res := range resources {
+			size += proto.Size(res)
+		}
+		counts[typeURL] = len(resources)
+	}
+	return counts, size
+}
+
+// recordSnapshotNack counts a response of the given type rejected by the gateways.
+func recordSnapshotNack(ctx context.Context, typeURL string) {
+	ctx, err := tag.New(ctx, tag.Upsert(resourceTypeTagKey, typeURL))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, snapshotNackCountM.M(1))
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"gotest.tools/v3/assert"
+)
+
+func TestSnapshotSize(t *testing.T) {
+	clusters := []cachetypes.Resource{&v3.Cluster{Name: "ns/a"}, &v3.Cluster{Name: "ns/b"}}
+	snapshot, err := cache.NewSnapshot("1", map[resource.Type][]cachetypes.Resource{resource.ClusterType: clusters})
+	assert.NilError(t, err)
+
+	counts, size := snapshotSize(snapshot)
+	assert.Equal(t, counts[resource.ClusterType], 2)
+	assert.Equal(t, counts[resource.ListenerType], 0)
+	assert.Equal(t, size, proto.Size(clusters[0])+proto.Size(clusters[1]))
+}
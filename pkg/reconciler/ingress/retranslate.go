@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RetranslatePath is the path the re-translation of an Ingress is forced on, next to the
+// inventory. The Ingress is given with the "ingress" query parameter, as
+// "namespace/name".
+const RetranslatePath = "/retranslate"
+
+// errNotKourierIngress is returned when forcing the re-translation of an Ingress which
+// isn't handled by Kourier.
+var errNotKourierIngress = errors.New("ingress is not handled by kourier")
+
+// newRetranslateHandler creates a handler forcing the re-translation and republication
+// of the given Ingress, bypassing the cached translation, e.g. if it's suspected to be
+// stale.
+func newRetranslateHandler(logger *zap.SugaredLogger, retranslate func(types.NamespacedName) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed: "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := r.URL.Query().Get("ingress")
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || namespace == "" || name == "" {
+			http.Error(w, fmt.Sprintf("invalid ingress %q, must be namespace/name", key), http.StatusBadRequest)
+			return
+		}
+
+		ingress := types.NamespacedName{Namespace: namespace, Name: name}
+		if err := retranslate(ingress); apierrors.IsNotFound(err) || errors.Is(err, errNotKourierIngress) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Errorw("Failed to force the re-translation of ingress "+ingress.String(), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Forced the re-translation of ingress ", ingress.String())
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
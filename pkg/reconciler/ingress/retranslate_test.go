@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRetranslateHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		err         error
+		wantStatus  int
+		wantIngress types.NamespacedName
+	}{{
+		name:        "retranslated",
+		method:      http.MethodPost,
+		query:       "?ingress=ns/foo",
+		wantStatus:  http.StatusAccepted,
+		wantIngress: types.NamespacedName{Namespace: "ns", Name: "foo"},
+	}, {
+		name:       "not a POST",
+		method:     http.MethodGet,
+		query:      "?ingress=ns/foo",
+		wantStatus: http.StatusMethodNotAllowed,
+	}, {
+		name:       "without namespace",
+		method:     http.MethodPost,
+		query:      "?ingress=foo",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:        "not found",
+		method:      http.MethodPost,
+		query:       "?ingress=ns/foo",
+		err:         apierrors.NewNotFound(schema.GroupResource{Resource: "ingresses"}, "foo"),
+		wantStatus:  http.StatusNotFound,
+		wantIngress: types.NamespacedName{Namespace: "ns", Name: "foo"},
+	}, {
+		name:        "not a kourier ingress",
+		method:      http.MethodPost,
+		query:       "?ingress=ns/foo",
+		err:         errNotKourierIngress,
+		wantStatus:  http.StatusNotFound,
+		wantIngress: types.NamespacedName{Namespace: "ns", Name: "foo"},
+	}, {
+		name:        "failure",
+		method:      http.MethodPost,
+		query:       "?ingress=ns/foo",
+		err:         errors.New("boom"),
+		wantStatus:  http.StatusInternalServerError,
+		wantIngress: types.NamespacedName{Namespace: "ns", Name: "foo"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got types.NamespacedName
+			handler := newRetranslateHandler(zap.NewNop().Sugar(), func(key types.NamespacedName) error {
+				got = key
+				return test.err
+			})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(test.method, RetranslatePath+test.query, nil))
+
+			assert.Equal(t, rec.Code, test.wantStatus)
+			assert.Equal(t, got, test.wantIngress)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"knative.dev/net-kourier/pkg/generator"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingClientSet "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	networkinglisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+)
+
+// listerSource is a generator.Source backed by informers.
+type listerSource struct {
+	ingressLister   networkinglisters.IngressLister
+	secretLister    corev1listers.SecretLister
+	endpointsLister corev1listers.EndpointsLister
+	serviceLister   corev1listers.ServiceLister
+	namespaceLister corev1listers.NamespaceLister
+	configMapLister corev1listers.ConfigMapLister
+	podLister       corev1listers.PodLister
+}
+
+var _ generator.Source = (*listerSource)(nil)
+var _ generator.PodGetter = (*listerSource)(nil)
+
+func (s *listerSource) ListIngresses(context.Context) ([]*v1alpha1.Ingress, error) {
+	ingresses, err := s.ingressLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return filterReadyIngresses(ingresses), nil
+}
+
+func (s *listerSource) Secret(ns, name string) (*corev1.Secret, error) {
+	return s.secretLister.Secrets(ns).Get(name)
+}
+
+func (s *listerSource) Endpoints(ns, name string) (*corev1.Endpoints, error) {
+	return s.endpointsLister.Endpoints(ns).Get(name)
+}
+
+func (s *listerSource) Service(ns, name string) (*corev1.Service, error) {
+	return s.serviceLister.Services(ns).Get(name)
+}
+
+func (s *listerSource) Namespace(name string) (*corev1.Namespace, error) {
+	return s.namespaceLister.Get(name)
+}
+
+func (s *listerSource) ConfigMaps(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	return s.configMapLister.ConfigMaps(ns).List(selector)
+}
+
+func (s *listerSource) Pod(ns, name string) (*corev1.Pod, error) {
+	return s.podLister.Pods(ns).Get(name)
+}
+
+// clientSource is a generator.Source backed by API clients. It is used at startup
+// to correctly list all resources before the informers are synced. It doesn't fetch the
+// pods behind the endpoints, to not fetch every single one of them at startup.
+type clientSource struct {
+	ctx           context.Context
+	kubeClient    kubeclient.Interface
+	knativeClient networkingClientSet.NetworkingV1alpha1Interface
+}
+
+var _ generator.Source = (*clientSource)(nil)
+
+// NewClientObjectGetter creates a generator.ObjectGetter fetching the objects referenced
+// by Ingresses via the given client, e.g. to translate Ingresses outside of the
+// controller.
+func NewClientObjectGetter(ctx context.Context, kubeClient kubeclient.Interface) generator.ObjectGetter {
+	return &clientSource{ctx: ctx, kubeClient: kubeClient}
+}
+
+func (s *clientSource) ListIngresses(ctx context.Context) ([]*v1alpha1.Ingress, error) {
+	return getReadyIngresses(ctx, s.knativeClient)
+}
+
+func (s *clientSource) Secret(ns, name string) (*corev1.Secret, error) {
+	return s.kubeClient.CoreV1().Secrets(ns).Get(s.ctx, name, metav1.GetOptions{})
+}
+
+func (s *clientSource) Endpoints(ns, name string) (*corev1.Endpoints, error) {
+	return s.kubeClient.CoreV1().Endpoints(ns).Get(s.ctx, name, metav1.GetOptions{})
+}
+
+func (s *clientSource) Service(ns, name string) (*corev1.Service, error) {
+	return s.kubeClient.CoreV1().Services(ns).Get(s.ctx, name, metav1.GetOptions{})
+}
+
+func (s *clientSource) Namespace(name string) (*corev1.Namespace, error) {
+	return s.kubeClient.CoreV1().Namespaces().Get(s.ctx, name, metav1.GetOptions{})
+}
+
+func (s *clientSource) ConfigMaps(ns string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	list, err := s.kubeClient.CoreV1().ConfigMaps(ns).List(s.ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	cms := make([]*corev1.ConfigMap, 0, len(list.Items))
+	for i := range list.Items {
+		cms = append(cms, &list.Items[i])
+	}
+	return cms, nil
+}
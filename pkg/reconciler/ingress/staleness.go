@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	// staleTranslationCountM is the number of ingresses served from their last
+	// successful translation, as translating them failed due to transient errors.
+	staleTranslationCountM = stats.Int64(
+		"stale_translation_count",
+		"Number of ingresses served from their last successful translation",
+		stats.UnitDimensionless)
+
+	// staleTranslationAgeM is the age of the oldest of those translations.
+	staleTranslationAgeM = stats.Float64(
+		"stale_translation_age_seconds",
+		"Time since the oldest ingress served from its last successful translation failed to be translated",
+		stats.UnitSeconds)
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: staleTranslationCountM.Description(),
+		Measure:     staleTranslationCountM,
+		Aggregation: view.LastValue(),
+	}, &view.View{
+		Description: staleTranslationAgeM.Description(),
+		Measure:     staleTranslationAgeM,
+		Aggregation: view.LastValue(),
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// recordStaleTranslations records the number of ingresses served from their last
+// successful translation, and the age of the oldest of them.
+func (r *Reconciler) recordStaleTranslations(ctx context.Context) {
+	count, oldest := r.caches.StaleIngresses()
+	var age time.Duration
+	if count > 0 {
+		age = time.Since(oldest)
+	}
+	metrics.Record(ctx, staleTranslationCountM.M(int64(count)))
+	metrics.Record(ctx, staleTranslationAgeM.M(age.Seconds()))
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"sync"
+
+	"contrib.go.opencensus.io/exporter/ocagent"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+const (
+	// tracingServiceName is the name of the service the spans are exported for.
+	tracingServiceName = "net-kourier-controller"
+
+	reconcileSpanName    = "kourier.reconcile"
+	translateSpanName    = "kourier.translate"
+	snapshotPushSpanName = "kourier.snapshot_push"
+	snapshotSetSpanName  = "kourier.snapshot_set"
+	snapshotAckSpanName  = "kourier.snapshot_ack"
+)
+
+// ackResourceTypes are the types of the resources whose acknowledgement by the gateways
+// ends the span of the snapshot they're in.
+var ackResourceTypes = []string{
+	resource.ClusterType,
+	resource.ListenerType,
+	resource.RouteType,
+}
+
+// controlPathTracer traces the propagation of the config of the Ingresses to the
+// gateways: their reconciliation and translation, the push of the snapshots built from
+// them, and the acknowledgement of the snapshots by the gateways. Its zero value traces
+// nothing until it's given an endpoint.
+type controlPathTracer struct {
+	mu sync.Mutex
+
+	// endpoint is the address of the collector the spans are exported to. Nothing is
+	// traced if empty.
+	endpoint string
+	exporter *ocagent.Exporter
+
+	// reconciled are the spans of the reconciliations whose config awaits the next push.
+	reconciled []trace.SpanContext
+
+	// acks are the spans of the snapshots awaiting their acknowledgement, keyed by node ID.
+	acks map[string]*pendingAck
+	// ackedVersions are the versions of the resources last acknowledged by the gateways,
+	// keyed by node ID and type.
+	ackedVersions map[string]map[string]string
+}
+
+// pendingAck is the span of a snapshot awaiting its acknowledgement.
+type pendingAck struct {
+	span *trace.Span
+	// versions are the versions awaiting their acknowledgement, keyed by resource type.
+	versions map[string]string
+}
+
+// setEndpoint exports the spans to the collector at the given address from then on, or
+// stops tracing if empty.
+func (t *controlPathTracer) setEndpoint(logger *zap.SugaredLogger, endpoint string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if endpoint == t.endpoint {
+		return
+	}
+	if t.exporter != nil {
+		trace.UnregisterExporter(t.exporter)
+		if err := t.exporter.Stop(); err != nil {
+			logger.Warnw("Failed to stop the control path span exporter", zap.Error(err))
+		}
+		t.exporter = nil
+	}
+	t.endpoint = ""
+	t.endAcks(trace.Status{Code: trace.StatusCodeCancelled, Message: "tracing reconfigured"})
+	t.reconciled = nil
+	if endpoint == "" {
+		return
+	}
+
+	exporter, err := ocagent.NewExporter(
+		ocagent.WithInsecure(),
+		ocagent.WithAddress(endpoint),
+		ocagent.WithServiceName(tracingServiceName),
+	)
+	if err != nil {
+		logger.Errorw("Failed to create the control path span exporter", zap.Error(err))
+		return
+	}
+	trace.RegisterExporter(exporter)
+	t.exporter = exporter
+	t.endpoint = endpoint
+}
+
+// startSpan starts a span with the given name, recorded if tracing is enabled.
+func (t *controlPathTracer) startSpan(ctx context.Context, name string, attributes ...trace.Attribute) (context.Context, *trace.Span) {
+	t.mu.Lock()
+	sampler := trace.NeverSample()
+	if t.endpoint != "" {
+		sampler = trace.AlwaysSample()
+	}
+	t.mu.Unlock()
+
+	ctx, span := trace.StartSpan(ctx, name, trace.WithSampler(sampler))
+	span.AddAttributes(attributes...)
+	return ctx, span
+}
+
+// markReconciled records the span of the given context as a reconciliation whose config
+// is published by the next push.
+func (t *controlPathTracer) markReconciled(ctx context.Context) {
+	span := trace.FromContext(ctx)
+	if span == nil || !span.IsRecordingEvents() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reconciled = append(t.reconciled, span.SpanContext())
+}
+
+// startPush starts the span of a push of the snapshots, linked to the reconciliations
+// whose config it publishes.
+func (t *controlPathTracer) startPush(ctx context.Context) (context.Context, *trace.Span) {
+	ctx, span := t.startSpan(ctx, snapshotPushSpanName)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, reconciled := range t.reconciled {
+		// Pushes which aren't debounced are part of the trace of their reconciliation.
+		if reconciled.TraceID == span.SpanContext().TraceID {
+			continue
+		}
+		span.AddLink(trace.Link{
+			TraceID: reconciled.TraceID,
+			SpanID:  reconciled.SpanID,
+			Type:    trace.LinkTypeParent,
+		})
+	}
+	t.reconciled = nil
+	return ctx, span
+}
+
+// awaitAck starts the span of the given snapshot, pushed to the gateways of the given
+// node ID under the span of the given context, which ends once the gateways acknowledge
+// its resources. The span of the previous snapshot of the node is ended as superseded
+// if it's still awaiting.
+func (t *controlPathTracer) awaitAck(ctx context.Context, nodeID string, snapshot envoycache.ResourceSnapshot) {
+	if span := trace.FromContext(ctx); span == nil || !span.IsRecordingEvents() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if pending, ok := t.acks[nodeID]; ok {
+		pending.span.SetStatus(trace.Status{Code: trace.StatusCodeAborted, Message: "superseded by a newer snapshot"})
+		pending.span.End()
+		delete(t.acks, nodeID)
+	}
+
+	versions := make(map[string]string, len(ackResourceTypes))
+	for _, typeURL := range ackResourceTypes {
+		if version := snapshot.GetVersion(typeURL); version != "" && version != t.ackedVersions[nodeID][typeURL] {
+			versions[typeURL] = version
+		}
+	}
+	if len(versions) == 0 {
+		return
+	}
+
+	// The span of the context is only recorded with tracing enabled.
+	_, span := trace.StartSpan(ctx, snapshotAckSpanName, trace.WithSampler(trace.AlwaysSample()))
+	span.AddAttributes(trace.StringAttribute("node", nodeID))
+	if t.acks == nil {
+		t.acks = make(map[string]*pendingAck)
+	}
+	t.acks[nodeID] = &pendingAck{span: span, versions: versions}
+}
+
+// onRequest handles the acknowledgement of the resources of the given type and version
+// by the gateways of the given node ID, or their rejection with the given error detail.
+// The span of the pushed snapshot ends once all its resources are acknowledged, or as
+// soon as one is rejected.
+func (t *controlPathTracer) onRequest(nodeID, typeURL, version string, errorDetail *rpcstatus.Status) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.endpoint == "" {
+		return
+	}
+
+	pending, ok := t.acks[nodeID]
+	if errorDetail != nil {
+		if ok && pending.versions[typeURL] != "" {
+			pending.span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: errorDetail.Message})
+			pending.span.End()
+			delete(t.acks, nodeID)
+		}
+		return
+	}
+
+	if version == "" {
+		return
+	}
+	if t.ackedVersions == nil {
+		t.ackedVersions = make(map[string]map[string]string)
+	}
+	if t.ackedVersions[nodeID] == nil {
+		t.ackedVersions[nodeID] = make(map[string]string, len(ackResourceTypes))
+	}
+	t.ackedVersions[nodeID][typeURL] = version
+
+	if !ok || pending.versions[typeURL] != version {
+		return
+	}
+	pending.span.Annotate([]trace.Attribute{trace.StringAttribute("type", typeURL)}, "Acknowledged")
+	delete(pending.versions, typeURL)
+	if len(pending.versions) == 0 {
+		pending.span.End()
+		delete(t.acks, nodeID)
+	}
+}
+
+// endAcks ends the spans of the snapshots awaiting their acknowledgement with the given
+// status. The caller must hold the lock.
+func (t *controlPathTracer) endAcks(status trace.Status) {
+	for nodeID, pending := range t.acks {
+		pending.span.SetStatus(status)
+		pending.span.End()
+		delete(t.acks, nodeID)
+	}
+}
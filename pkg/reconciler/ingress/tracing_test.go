@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"go.opencensus.io/trace"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"gotest.tools/v3/assert"
+)
+
+// spanRecorder records the spans exported to it.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(span *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// named returns the spans recorded with the given name.
+func (r *spanRecorder) named(name string) []*trace.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var spans []*trace.SpanData
+	for _, span := range r.spans {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestControlPathTracer(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	t.Cleanup(func() { trace.UnregisterExporter(recorder) })
+
+	snapshot := func(version string) *envoycache.Snapshot {
+		s, err := envoycache.NewSnapshot(version, map[string][]types.Resource{
+			resource.ClusterType:  {},
+			resource.ListenerType: {},
+			resource.RouteType:    {},
+		})
+		assert.NilError(t, err)
+		return s
+	}
+	ack := func(tracer *controlPathTracer, typeURL, version string) {
+		tracer.onRequest("node", typeURL, version, nil)
+	}
+
+	tracer := &controlPathTracer{endpoint: "collector:55678"}
+
+	// The reconciliations are linked to the push publishing their config.
+	reconcileCtx, reconcile := tracer.startSpan(context.Background(), reconcileSpanName)
+	tracer.markReconciled(reconcileCtx)
+	reconcile.End()
+
+	pushCtx, push := tracer.startPush(context.Background())
+	setCtx, set := tracer.startSpan(pushCtx, snapshotSetSpanName)
+	set.End()
+	tracer.awaitAck(setCtx, "node", snapshot("1"))
+	push.End()
+
+	pushes := recorder.named(snapshotPushSpanName)
+	assert.Equal(t, len(pushes), 1)
+	assert.Equal(t, len(pushes[0].Links), 1)
+	assert.Equal(t, pushes[0].Links[0].SpanID, reconcile.SpanContext().SpanID)
+
+	// The snapshot is acknowledged once all its resources are, by the gateways of its node.
+	ack(tracer, resource.ClusterType, "1")
+	ack(tracer, resource.ListenerType, "1")
+	tracer.onRequest("other", resource.RouteType, "1", nil)
+	assert.Equal(t, len(recorder.named(snapshotAckSpanName)), 0)
+	ack(tracer, resource.RouteType, "1")
+	acks := recorder.named(snapshotAckSpanName)
+	assert.Equal(t, len(acks), 1)
+	assert.Equal(t, acks[0].ParentSpanID, set.SpanContext().SpanID)
+	assert.Equal(t, acks[0].Status.Code, int32(trace.StatusCodeOK))
+
+	// Snapshots without changes to acknowledge aren't awaited.
+	tracer.awaitAck(setCtx, "node", snapshot("1"))
+	assert.Equal(t, len(tracer.acks), 0)
+
+	// Newer snapshots supersede the ones awaiting their acknowledgement.
+	tracer.awaitAck(setCtx, "node", snapshot("2"))
+	tracer.awaitAck(setCtx, "node", snapshot("3"))
+	acks = recorder.named(snapshotAckSpanName)
+	assert.Equal(t, len(acks), 2)
+	assert.Equal(t, acks[1].Status.Code, int32(trace.StatusCodeAborted))
+
+	// Rejections end the spans right away.
+	tracer.onRequest("node", resource.ClusterType, "1", &rpcstatus.Status{Message: "invalid cluster"})
+	acks = recorder.named(snapshotAckSpanName)
+	assert.Equal(t, len(acks), 3)
+	assert.Equal(t, acks[2].Status.Code, int32(trace.StatusCodeInvalidArgument))
+	assert.Equal(t, acks[2].Status.Message, "invalid cluster")
+}
+
+func TestControlPathTracerDisabled(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	t.Cleanup(func() { trace.UnregisterExporter(recorder) })
+
+	tracer := &controlPathTracer{}
+	ctx, span := tracer.startSpan(context.Background(), reconcileSpanName)
+	tracer.markReconciled(ctx)
+	span.End()
+
+	snapshot, err := envoycache.NewSnapshot("1", map[string][]types.Resource{resource.ClusterType: {}})
+	assert.NilError(t, err)
+	tracer.awaitAck(ctx, "node", snapshot)
+	tracer.onRequest("node", resource.ClusterType, "1", nil)
+
+	assert.Equal(t, len(recorder.named(reconcileSpanName)), 0)
+	assert.Equal(t, len(tracer.reconciled), 0)
+	assert.Equal(t, len(tracer.acks), 0)
+}
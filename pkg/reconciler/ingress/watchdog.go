@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	// snapshotRepublishCountM counts the snapshots published again by the snapshot
+	// watchdog, as the gateways didn't pick them up.
+	snapshotRepublishCountM = stats.Int64(
+		"snapshot_republish_count",
+		"Number of snapshots published again because the gateways didn't pick them up in time",
+		stats.UnitDimensionless)
+
+	nodeIDTagKey = tag.MustNewKey("node_id")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: snapshotRepublishCountM.Description(),
+		Measure:     snapshotRepublishCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{nodeIDTagKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// newRepublishedHandler creates a handler logging and counting the snapshots published
+// again by the snapshot watchdog.
+func newRepublishedHandler(ctx context.Context, logger *zap.SugaredLogger) func(nodeID string, err error) {
+	return func(nodeID string, err error) {
+		if err != nil {
+			logger.Errorw("Failed to publish the stale snapshot of node "+nodeID+" again", zap.Error(err))
+			return
+		}
+		logger.Warnf("The gateways of node %s didn't pick up their snapshot in time, published it again", nodeID)
+
+		ctx, tagErr := tag.New(ctx, tag.Upsert(nodeIDTagKey, nodeID))
+		if tagErr != nil {
+			logger.Errorw("Failed to tag the snapshot republish count", zap.Error(tagErr))
+			return
+		}
+		metrics.Record(ctx, snapshotRepublishCountM.M(1))
+	}
+}
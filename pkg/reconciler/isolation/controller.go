@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package isolation
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	nsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
+	serviceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/service"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+)
+
+// NewController creates a controller keeping the ports of the gateway Service in sync
+// with the listener ports allocated for traffic isolation.
+func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+
+	serviceInformer := serviceinformer.Get(ctx)
+	namespaceInformer := nsinformer.Get(ctx)
+
+	gateway := types.NamespacedName{
+		Namespace: config.GatewayNamespace(),
+		Name:      config.ExternalServiceName,
+	}
+
+	r := &Reconciler{
+		kubeClient:      kubeclient.Get(ctx),
+		serviceLister:   serviceInformer.Lister(),
+		namespaceLister: namespaceInformer.Lister(),
+	}
+	impl := controller.NewContext(ctx, r, controller.ControllerOptions{
+		WorkQueueName: "IsolationPorts",
+		Logger:        logger,
+	})
+	r.LeaderAwareFuncs = reconciler.LeaderAwareFuncs{
+		PromoteFunc: func(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
+			enq(bkt, gateway)
+			return nil
+		},
+	}
+
+	enqueueGateway := func(interface{}) {
+		impl.EnqueueKey(gateway)
+	}
+
+	configStore := rconfig.NewStore(logger.Named("config-store"), func(string, interface{}) {
+		enqueueGateway(nil)
+	})
+	configStore.WatchConfigs(cmw)
+	r.configStore = configStore
+
+	// Any change to the namespaces might allocate or release a listener port.
+	namespaceInformer.Informer().AddEventHandler(controller.HandleAll(enqueueGateway))
+
+	serviceInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterWithNameAndNamespace(gateway.Namespace, gateway.Name),
+		Handler:    controller.HandleAll(enqueueGateway),
+	})
+
+	return impl
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package isolation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+)
+
+// portNamePrefix is the prefix of the names of the Service ports managed for isolation
+// listeners. The listener port is appended to it.
+const portNamePrefix = "isolation-"
+
+// Reconciler keeps the ports of the gateway Service in sync with the listener ports
+// allocated to namespaces for traffic isolation.
+type Reconciler struct {
+	reconciler.LeaderAwareFuncs
+
+	kubeClient      kubeclient.Interface
+	serviceLister   corev1listers.ServiceLister
+	namespaceLister corev1listers.NamespaceLister
+	configStore     *rconfig.Store
+}
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	ctx = r.configStore.ToContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid resource key %q: %w", key, err)
+	}
+
+	svc, err := r.serviceLister.Services(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("Gateway service %q not found, nothing to sync", key)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get gateway service: %w", err)
+	}
+
+	listenerPorts, err := r.allocatedListenerPorts(ctx)
+	if err != nil {
+		return err
+	}
+
+	ports := syncPorts(svc.Spec.Ports, listenerPorts)
+	if equality.Semantic.DeepEqual(ports, svc.Spec.Ports) {
+		return nil
+	}
+
+	logger.Infof("Updating the ports of gateway service %q for listener ports %v", key, listenerPorts)
+	svc = svc.DeepCopy()
+	svc.Spec.Ports = ports
+	if _, err := r.kubeClient.CoreV1().Services(ns).Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update gateway service: %w", err)
+	}
+	return nil
+}
+
+// allocatedListenerPorts returns the sorted listener ports assigned to namespaces. Invalid
+// ports are ignored, the ingresses of these namespaces are rejected anyway.
+func (r *Reconciler) allocatedListenerPorts(ctx context.Context) ([]uint32, error) {
+	if rconfig.FromContextOrDefaults(ctx).Kourier.TrafficIsolation != config.IsolationIngressPort {
+		return nil, nil
+	}
+
+	namespaces, err := r.namespaceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	seen := make(map[uint32]struct{}, len(namespaces))
+	ports := make([]uint32, 0, len(namespaces))
+	for _, ns := range namespaces {
+		value, ok := ns.Annotations[config.ListenerPortAnnotationKey]
+		if !ok {
+			continue
+		}
+		port, err := config.ParseListenerPort(value)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[port]; !ok {
+			seen[port] = struct{}{}
+			ports = append(ports, port)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports, nil
+}
+
+// syncPorts returns the given Service ports with the isolation ports replaced by the
+// given listener ports. Existing isolation ports are kept as is to retain their
+// node ports.
+func syncPorts(existing []corev1.ServicePort, listenerPorts []uint32) []corev1.ServicePort {
+	isolationPorts := make(map[string]corev1.ServicePort, len(existing))
+	ports := make([]corev1.ServicePort, 0, len(existing)+len(listenerPorts))
+	for _, port := range existing {
+		if strings.HasPrefix(port.Name, portNamePrefix) {
+			isolationPorts[port.Name] = port
+			continue
+		}
+		ports = append(ports, port)
+	}
+
+	for _, listenerPort := range listenerPorts {
+		name := portNamePrefix + strconv.FormatUint(uint64(listenerPort), 10)
+		if port, ok := isolationPorts[name]; ok {
+			ports = append(ports, port)
+			continue
+		}
+		ports = append(ports, corev1.ServicePort{
+			Name:       name,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(listenerPort),
+			TargetPort: intstr.FromInt(int(listenerPort)),
+		})
+	}
+	return ports
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package isolation
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSyncPorts(t *testing.T) {
+	http2 := corev1.ServicePort{
+		Name:       "http2",
+		Protocol:   corev1.ProtocolTCP,
+		Port:       80,
+		TargetPort: intstr.FromInt(8080),
+	}
+	isolationPort := func(port int32, nodePort int32) corev1.ServicePort {
+		return corev1.ServicePort{
+			Name:       "isolation-" + strconv.Itoa(int(port)),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+			NodePort:   nodePort,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		existing      []corev1.ServicePort
+		listenerPorts []uint32
+		want          []corev1.ServicePort
+	}{{
+		name:     "no listener ports",
+		existing: []corev1.ServicePort{http2},
+		want:     []corev1.ServicePort{http2},
+	}, {
+		name:          "add listener ports",
+		existing:      []corev1.ServicePort{http2},
+		listenerPorts: []uint32{7000, 7001},
+		want:          []corev1.ServicePort{http2, isolationPort(7000, 0), isolationPort(7001, 0)},
+	}, {
+		name:          "keep existing node ports",
+		existing:      []corev1.ServicePort{http2, isolationPort(7000, 31000)},
+		listenerPorts: []uint32{7000, 7001},
+		want:          []corev1.ServicePort{http2, isolationPort(7000, 31000), isolationPort(7001, 0)},
+	}, {
+		name:          "remove released listener ports",
+		existing:      []corev1.ServicePort{isolationPort(7000, 31000), http2, isolationPort(7001, 31001)},
+		listenerPorts: []uint32{7001},
+		want:          []corev1.ServicePort{http2, isolationPort(7001, 31001)},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := syncPorts(tt.existing, tt.listenerPorts)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("syncPorts() (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
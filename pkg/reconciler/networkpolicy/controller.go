@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkpolicy
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	ingressinformer "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/ingress"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+)
+
+// NewController creates a controller keeping the NetworkPolicy restricting the
+// namespaces that can reach the internal listeners of the gateways in sync with the
+// Ingresses.
+func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+
+	ingressInformer := ingressinformer.Get(ctx)
+
+	policy := types.NamespacedName{
+		Namespace: config.GatewayNamespace(),
+		Name:      config.InternalServiceName,
+	}
+
+	// Only the NetworkPolicy of the gateways is watched, so that the controller only
+	// needs access to the NetworkPolicies of their namespace.
+	networkPolicyInformer := networkinginformers.NewFilteredNetworkPolicyInformer(kubeclient.Get(ctx), policy.Namespace,
+		controller.GetResyncPeriod(ctx), cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", policy.Name).String()
+		})
+
+	r := &Reconciler{
+		kubeClient:          kubeclient.Get(ctx),
+		networkPolicyLister: networkinglisters.NewNetworkPolicyLister(networkPolicyInformer.GetIndexer()),
+		ingressLister:       ingressInformer.Lister(),
+	}
+	impl := controller.NewContext(ctx, r, controller.ControllerOptions{
+		WorkQueueName: "InternalNetworkPolicy",
+		Logger:        logger,
+	})
+	r.LeaderAwareFuncs = reconciler.LeaderAwareFuncs{
+		PromoteFunc: func(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
+			enq(bkt, policy)
+			return nil
+		},
+	}
+
+	enqueuePolicy := func(interface{}) {
+		impl.EnqueueKey(policy)
+	}
+
+	configStore := rconfig.NewStore(logger.Named("config-store"), func(string, interface{}) {
+		enqueuePolicy(nil)
+	})
+	configStore.WatchConfigs(cmw)
+	r.configStore = configStore
+
+	// Any change to the Ingresses might change the namespaces serving cluster-local hosts.
+	ingressInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: isKourierIngress,
+		Handler:    controller.HandleAll(enqueuePolicy),
+	})
+
+	// Restore the NetworkPolicy once it's changed or deleted.
+	networkPolicyInformer.AddEventHandler(controller.HandleAll(enqueuePolicy))
+	go networkPolicyInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), networkPolicyInformer.HasSynced) {
+		logger.Fatal("Failed to sync the NetworkPolicy informer")
+	}
+
+	return impl
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkpolicy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubeclient "k8s.io/client-go/kubernetes"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	rconfig "knative.dev/net-kourier/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	v1alpha1ingress "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
+	ingresslisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+	"knative.dev/pkg/system"
+)
+
+const (
+	// providerLabelKey and providerLabelValue label the NetworkPolicy as managed by
+	// Kourier. NetworkPolicies of the same name without them are left alone.
+	providerLabelKey   = "networking.knative.dev/ingress-provider"
+	providerLabelValue = "kourier"
+
+	// namespaceNameLabelKey is the label Kubernetes sets on every namespace to its name.
+	namespaceNameLabelKey = "kubernetes.io/metadata.name"
+
+	maxPort = 65535
+)
+
+var isKourierIngress = reconciler.AnnotationFilterFunc(
+	v1alpha1ingress.ClassAnnotationKey, config.KourierIngressClassName, false,
+)
+
+// internalPorts are the ports of the gateways' internal listeners.
+var internalPorts = []uint32{config.HTTPPortInternal, config.HTTPSPortInternal}
+
+// Reconciler keeps the NetworkPolicy restricting the namespaces that can reach the
+// internal listeners of the gateways in sync with the Ingresses.
+type Reconciler struct {
+	reconciler.LeaderAwareFuncs
+
+	kubeClient          kubeclient.Interface
+	networkPolicyLister networkinglisters.NetworkPolicyLister
+	ingressLister       ingresslisters.IngressLister
+	configStore         *rconfig.Store
+}
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	ctx = r.configStore.ToContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid resource key %q: %w", key, err)
+	}
+
+	existing, err := r.networkPolicyLister.NetworkPolicies(ns).Get(name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get network policy: %w", err)
+	}
+	managed := existing != nil && existing.Labels[providerLabelKey] == providerLabelValue
+	if existing != nil && !managed {
+		logger.Warnf("Network policy %q is not managed by Kourier, leaving it alone", key)
+		return nil
+	}
+
+	if !rconfig.FromContextOrDefaults(ctx).Kourier.InternalNetworkPolicy {
+		if !managed {
+			return nil
+		}
+		logger.Infof("Deleting network policy %q", key)
+		if err := r.kubeClient.NetworkingV1().NetworkPolicies(ns).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete network policy: %w", err)
+		}
+		return nil
+	}
+
+	namespaces, err := r.allowedNamespaces()
+	if err != nil {
+		return err
+	}
+	desired := makeNetworkPolicy(ns, name, namespaces)
+
+	if existing == nil {
+		logger.Infof("Creating network policy %q for namespaces %v", key, namespaces)
+		if _, err := r.kubeClient.NetworkingV1().NetworkPolicies(ns).Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create network policy: %w", err)
+		}
+		return nil
+	}
+	if equality.Semantic.DeepEqual(existing.Spec, desired.Spec) {
+		return nil
+	}
+
+	logger.Infof("Updating network policy %q for namespaces %v", key, namespaces)
+	policy := existing.DeepCopy()
+	policy.Spec = desired.Spec
+	if _, err := r.kubeClient.NetworkingV1().NetworkPolicies(ns).Update(ctx, policy, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update network policy: %w", err)
+	}
+	return nil
+}
+
+// allowedNamespaces returns the sorted namespaces allowed to reach the internal
+// listeners: the ones of Knative and the gateways, and the ones serving cluster-local
+// hosts via Kourier.
+func (r *Reconciler) allowedNamespaces() ([]string, error) {
+	ingresses, err := r.ingressLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	namespaces := sets.NewString(system.Namespace(), config.ServingNamespace(), config.GatewayNamespace())
+	for _, ingress := range ingresses {
+		if isKourierIngress(ingress) && hasClusterLocalRule(ingress) {
+			namespaces.Insert(ingress.Namespace)
+		}
+	}
+	return namespaces.List(), nil
+}
+
+// hasClusterLocalRule returns whether the ingress serves any cluster-local host.
+func hasClusterLocalRule(ingress *v1alpha1.Ingress) bool {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Visibility == v1alpha1.IngressVisibilityClusterLocal {
+			return true
+		}
+	}
+	return false
+}
+
+// makeNetworkPolicy creates the NetworkPolicy of the gateways, allowing only the given
+// namespaces and the ones labeled with InternalAccessLabelKey to reach their internal
+// listeners. All other ports can still be reached from anywhere.
+func makeNetworkPolicy(ns, name string, namespaces []string) *networkingv1.NetworkPolicy {
+	tcp := corev1.ProtocolTCP
+	port := func(from, to uint32) networkingv1.NetworkPolicyPort {
+		p := intstr.FromInt(int(from))
+		policyPort := networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &p}
+		if to > from {
+			endPort := int32(to)
+			policyPort.EndPort = &endPort
+		}
+		return policyPort
+	}
+
+	sorted := append([]uint32{}, internalPorts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var otherPorts, restrictedPorts []networkingv1.NetworkPolicyPort
+	from := uint32(1)
+	for _, internalPort := range sorted {
+		if internalPort > from {
+			otherPorts = append(otherPorts, port(from, internalPort-1))
+		}
+		restrictedPorts = append(restrictedPorts, port(internalPort, internalPort))
+		from = internalPort + 1
+	}
+	otherPorts = append(otherPorts, port(from, maxPort))
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    map[string]string{providerLabelKey: providerLabelValue},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{config.GatewayLabelKey: config.GatewayLabelValue},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: otherPorts,
+			}, {
+				Ports: restrictedPorts,
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{{
+							Key:      namespaceNameLabelKey,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   namespaces,
+						}},
+					},
+				}, {
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{config.InternalAccessLabelKey: "true"},
+					},
+				}},
+			}},
+		},
+	}
+}
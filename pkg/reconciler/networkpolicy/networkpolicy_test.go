@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkpolicy
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/net-kourier/pkg/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	v1alpha1ingress "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
+	ingresslisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+
+	_ "knative.dev/pkg/system/testing"
+)
+
+func TestMakeNetworkPolicyPorts(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port := func(from, to int) networkingv1.NetworkPolicyPort {
+		p := intstr.FromInt(from)
+		policyPort := networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &p}
+		if to != from {
+			endPort := int32(to)
+			policyPort.EndPort = &endPort
+		}
+		return policyPort
+	}
+
+	policy := makeNetworkPolicy("kourier-system", "kourier-internal", []string{"default"})
+
+	// The internal listeners are only reachable from the given namespaces, all other
+	// ports from anywhere.
+	if diff := cmp.Diff([]networkingv1.NetworkPolicyPort{
+		port(1, 8080), port(8082, 8443), port(8445, 65535),
+	}, policy.Spec.Ingress[0].Ports); diff != "" {
+		t.Errorf("Unrestricted ports (-want, +got) = %s", diff)
+	}
+	if len(policy.Spec.Ingress[0].From) != 0 {
+		t.Errorf("Unrestricted ports are only reachable from %v", policy.Spec.Ingress[0].From)
+	}
+	if diff := cmp.Diff([]networkingv1.NetworkPolicyPort{
+		port(8081, 8081), port(8444, 8444),
+	}, policy.Spec.Ingress[1].Ports); diff != "" {
+		t.Errorf("Restricted ports (-want, +got) = %s", diff)
+	}
+	if diff := cmp.Diff([]networkingv1.NetworkPolicyPeer{{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{"default"},
+			}},
+		},
+	}, {
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{config.InternalAccessLabelKey: "true"},
+		},
+	}}, policy.Spec.Ingress[1].From); diff != "" {
+		t.Errorf("Allowed peers (-want, +got) = %s", diff)
+	}
+}
+
+func TestAllowedNamespaces(t *testing.T) {
+	ingress := func(ns, class string, visibility v1alpha1.IngressVisibility) *v1alpha1.Ingress {
+		return &v1alpha1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   ns,
+				Name:        "ingress",
+				Annotations: map[string]string{v1alpha1ingress.ClassAnnotationKey: class},
+			},
+			Spec: v1alpha1.IngressSpec{Rules: []v1alpha1.IngressRule{{Visibility: visibility}}},
+		}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ing := range []*v1alpha1.Ingress{
+		ingress("local", config.KourierIngressClassName, v1alpha1.IngressVisibilityClusterLocal),
+		ingress("external", config.KourierIngressClassName, v1alpha1.IngressVisibilityExternalIP),
+		ingress("other-class", "istio.ingress.networking.knative.dev", v1alpha1.IngressVisibilityClusterLocal),
+	} {
+		if err := indexer.Add(ing); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Reconciler{ingressLister: ingresslisters.NewIngressLister(indexer)}
+	got, err := r.allowedNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"knative-testing", "local"}, got); diff != "" {
+		t.Errorf("allowedNamespaces() (-want, +got) = %s", diff)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// MaxConcurrency is the highest number of probing calls which can be issued
+	// simultaneously.
+	MaxConcurrency = 100
+
+	// The defaults of the options.
+	defaultConcurrency = 15
+	defaultTimeout     = 1 * time.Second
+	defaultBackoffBase = 50 * time.Millisecond
+	defaultBackoffMax  = 30 * time.Second
+
+	// initialDelay defines the delay before enqueuing a probing request the first time.
+	// It gives times for the change to propagate and prevents unnecessary retries.
+	initialDelay = 200 * time.Millisecond
+)
+
+// Options tune the probing. The defaults are used for the zero values.
+type Options struct {
+	// Concurrency is the number of probing calls which can be issued simultaneously, up
+	// to MaxConcurrency.
+	Concurrency int
+	// Timeout is the maximum amount of time a probing call waits for a response.
+	Timeout time.Duration
+	// BackoffBase and BackoffMax are the delays before retrying a failed probing call,
+	// doubled on every failure from the former up to the latter.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// withDefaults returns the options, with the defaults of the ones which aren't set.
+func (o Options) withDefaults() Options {
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultConcurrency
+	}
+	if o.Concurrency > MaxConcurrency {
+		o.Concurrency = MaxConcurrency
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.BackoffBase <= 0 {
+		o.BackoffBase = defaultBackoffBase
+	}
+	if o.BackoffMax <= 0 {
+		o.BackoffMax = defaultBackoffMax
+	}
+	return o
+}
+
+// backoffRateLimiter delays the retries of the failed probing calls exponentially, with
+// the backoff of the current options.
+type backoffRateLimiter struct {
+	options func() Options
+
+	mu       sync.Mutex
+	failures map[interface{}]int
+}
+
+var _ workqueue.RateLimiter = (*backoffRateLimiter)(nil)
+
+func newBackoffRateLimiter(options func() Options) *backoffRateLimiter {
+	return &backoffRateLimiter{
+		options:  options,
+		failures: make(map[interface{}]int),
+	}
+}
+
+// When returns the delay before retrying the given item.
+func (r *backoffRateLimiter) When(item interface{}) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	options := r.options().withDefaults()
+	failures := r.failures[item]
+	r.failures[item] = failures + 1
+
+	backoff := options.BackoffBase
+	for i := 0; i < failures && backoff < options.BackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > options.BackoffMax {
+		backoff = options.BackoffMax
+	}
+	return backoff
+}
+
+// NumRequeues returns the number of times the given item failed.
+func (r *backoffRateLimiter) NumRequeues(item interface{}) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.failures[item]
+}
+
+// Forget forgets the failures of the given item.
+func (r *backoffRateLimiter) Forget(item interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.failures, item)
+}
+
+// concurrencyLimiter limits the number of probing calls issued simultaneously to the
+// concurrency of the current options.
+type concurrencyLimiter struct {
+	options func() Options
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int
+}
+
+func newConcurrencyLimiter(options func() Options) *concurrencyLimiter {
+	l := &concurrencyLimiter{options: options}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits until another probing call can be issued.
+func (l *concurrencyLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.inFlight >= l.options().withDefaults().Concurrency {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+// release marks a probing call as done.
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.cond.Broadcast()
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestOptionsWithDefaults(t *testing.T) {
+	assert.DeepEqual(t, Options{}.withDefaults(), Options{
+		Concurrency: defaultConcurrency,
+		Timeout:     defaultTimeout,
+		BackoffBase: defaultBackoffBase,
+		BackoffMax:  defaultBackoffMax,
+	})
+
+	options := Options{Concurrency: 5, Timeout: time.Second, BackoffBase: time.Second, BackoffMax: time.Minute}
+	assert.DeepEqual(t, options.withDefaults(), options)
+
+	assert.Equal(t, Options{Concurrency: 1000}.withDefaults().Concurrency, MaxConcurrency)
+}
+
+func TestBackoffRateLimiter(t *testing.T) {
+	options := Options{BackoffBase: 100 * time.Millisecond, BackoffMax: time.Second}
+	limiter := newBackoffRateLimiter(func() Options { return options })
+
+	for _, want := range []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond,
+		time.Second, time.Second,
+	} {
+		assert.Equal(t, limiter.When("item"), want)
+	}
+	assert.Equal(t, limiter.NumRequeues("item"), 6)
+
+	// The other items are delayed independently.
+	assert.Equal(t, limiter.When("other"), 100*time.Millisecond)
+
+	limiter.Forget("item")
+	assert.Equal(t, limiter.NumRequeues("item"), 0)
+	assert.Equal(t, limiter.When("item"), 100*time.Millisecond)
+
+	// Changed options apply to the next retries.
+	options.BackoffBase = time.Second
+	options.BackoffMax = 2 * time.Second
+	assert.Equal(t, limiter.When("item"), 2*time.Second)
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	options := Options{Concurrency: 1}
+	limiter := newConcurrencyLimiter(func() Options { return options })
+
+	limiter.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		limiter.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the concurrency")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not acquired after the release")
+	}
+	limiter.release()
+}
@@ -1,5 +1,5 @@
 /*
-Copyright 2019 The Knative Authors.
+Copyright 2019 The Knative Authors
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -46,18 +46,6 @@ import (
 	"knative.dev/pkg/logging"
 )
 
-const (
-	// probeConcurrency defines how many probing calls can be issued simultaneously
-	probeConcurrency = 15
-	// probeTimeout defines the maximum amount of time a request will wait
-	probeTimeout = 1 * time.Second
-	// initialDelay defines the delay before enqueuing a probing request the first time.
-	// It gives times for the change to propagate and prevents unnecessary retries.
-	initialDelay = 200 * time.Millisecond
-)
-
-var dialContext = (&net.Dialer{Timeout: probeTimeout}).DialContext
-
 // ingressState represents the probing state of an Ingress
 type ingressState struct {
 	hash string
@@ -129,14 +117,18 @@ type Prober struct {
 
 	readyCallback func(*v1alpha1.Ingress)
 
-	probeConcurrency int
+	// options returns the current options of the probing.
+	options            func() Options
+	concurrencyLimiter *concurrencyLimiter
 }
 
-// NewProber creates a new instance of Prober
+// NewProber creates a new instance of Prober. The options are read on every probing
+// call, so that they can be changed while probing.
 func NewProber(
 	logger *zap.SugaredLogger,
 	targetLister ProbeTargetLister,
-	readyCallback func(*v1alpha1.Ingress)) *Prober {
+	readyCallback func(*v1alpha1.Ingress),
+	options func() Options) *Prober {
 	return &Prober{
 		logger:        logger,
 		ingressStates: make(map[types.NamespacedName]*ingressState),
@@ -144,14 +136,15 @@ func NewProber(
 		workQueue: workqueue.NewNamedRateLimitingQueue(
 			workqueue.NewMaxOfRateLimiter(
 				// Per item exponential backoff
-				workqueue.NewItemExponentialFailureRateLimiter(50*time.Millisecond, 30*time.Second),
+				newBackoffRateLimiter(options),
 				// Global rate limiter
 				&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(50), 100)},
 			),
 			"ProbingQueue"),
-		targetLister:     targetLister,
-		readyCallback:    readyCallback,
-		probeConcurrency: probeConcurrency,
+		targetLister:       targetLister,
+		readyCallback:      readyCallback,
+		options:            options,
+		concurrencyLimiter: newConcurrencyLimiter(options),
 	}
 }
 
@@ -281,8 +274,9 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 func (m *Prober) Start(done <-chan struct{}) chan struct{} {
 	var wg sync.WaitGroup
 
-	// Start the worker goroutines
-	for i := 0; i < m.probeConcurrency; i++ {
+	// Start the worker goroutines. The concurrency limiter lets as many of them probe
+	// simultaneously as currently configured.
+	for i := 0; i < MaxConcurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -361,6 +355,10 @@ func (m *Prober) processWorkItem() bool {
 	item.logger.Infof("Processing probe for %s, IP: %s:%s (depth: %d)",
 		item.url, item.podIP, item.podPort, m.workQueue.Len())
 
+	m.concurrencyLimiter.acquire()
+	defer m.concurrencyLimiter.release()
+	timeout := m.options().withDefaults().Timeout
+
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.TLSClientConfig = &tls.Config{
 		//nolint:gosec
@@ -373,13 +371,13 @@ func (m *Prober) processWorkItem() bool {
 		// because the HTTP client validates that the hostname (not the Host header) matches the server
 		// TLS certificate Common Name or Alternative Names. Therefore, http.Request.URL is set to the
 		// hostname and it is substituted it here with the target IP.
-		return dialContext(ctx, network, net.JoinHostPort(item.podIP, item.podPort))
+		return (&net.Dialer{Timeout: timeout}).DialContext(ctx, network, net.JoinHostPort(item.podIP, item.podPort))
 	}
 
 	probeURL := deepCopy(item.url)
 	probeURL.Path = path.Join(probeURL.Path, nethttp.HealthCheckPath)
 
-	ctx, cancel := context.WithTimeout(item.context, probeTimeout)
+	ctx, cancel := context.WithTimeout(item.context, timeout)
 	defer cancel()
 	ok, err := prober.Do(
 		ctx,
@@ -0,0 +1,209 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap/zaptest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/http/probe"
+	"knative.dev/networking/pkg/ingress"
+)
+
+var ingTemplate = &v1alpha1.Ingress{
+	ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "whatever",
+	},
+	Spec: v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts: []string{
+				"foo.bar.com",
+			},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP:       &v1alpha1.HTTPIngressRuleValue{},
+		}},
+	},
+}
+
+// The probing itself is the one of knative.dev/networking/pkg/status, so only the
+// options the fork adds on top of it are tested here.
+func TestProbeOptions(t *testing.T) {
+	// probeIngress probes the Ingress with the given hosts against the handler, and waits
+	// for it to be ready. The handler calls respond to respond with the hash of the
+	// Ingress, or the given hash if set.
+	probeIngress := func(t *testing.T, hosts []string, options Options, handler func(w http.ResponseWriter, r *http.Request, respond func(hash string))) {
+		ing := ingTemplate.DeepCopy()
+		ing.Spec.Rules[0].Hosts = hosts
+		hash, err := ingress.InsertProbe(ing.DeepCopy())
+		if err != nil {
+			t.Fatal("Failed to insert probe:", err)
+		}
+		probeHandler := probe.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, func(override string) {
+				if override != "" {
+					r.Header.Set(header.HashKey, override)
+				} else {
+					r.Header.Set(header.HashKey, hash)
+				}
+				probeHandler.ServeHTTP(w, r)
+			})
+		}))
+		defer ts.Close()
+		tsURL, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+		}
+
+		ready := make(chan *v1alpha1.Ingress)
+		prober := NewProber(
+			zaptest.NewLogger(t).Sugar(),
+			fakeProbeTargetLister{{
+				PodIPs:  sets.NewString(tsURL.Hostname()),
+				PodPort: tsURL.Port(),
+				URLs:    []*url.URL{tsURL},
+			}},
+			func(ing *v1alpha1.Ingress) {
+				ready <- ing
+			}, func() Options { return options })
+
+		done := make(chan struct{})
+		cancelled := prober.Start(done)
+		defer func() {
+			close(done)
+			<-cancelled
+		}()
+
+		if ok, err := prober.IsReady(context.Background(), ing); err != nil {
+			t.Fatal("IsReady failed:", err)
+		} else if ok {
+			t.Fatal("IsReady() returned true")
+		}
+
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for probing to succeed.")
+		}
+
+		if ok, err := prober.IsReady(context.Background(), ing); err != nil {
+			t.Fatal("IsReady failed:", err)
+		} else if !ok {
+			t.Fatal("IsReady() returned false")
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		var requests atomic.Int32
+		probeIngress(t, []string{"foo.bar.com"}, Options{},
+			func(w http.ResponseWriter, r *http.Request, respond func(string)) {
+				requests.Inc()
+				respond("")
+			})
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Requests = %d, want: 1", got)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// The first probe hangs until it times out, and the retry succeeds.
+		var requests atomic.Int32
+		probeIngress(t, []string{"foo.bar.com"}, Options{Timeout: 100 * time.Millisecond, BackoffBase: 10 * time.Millisecond},
+			func(w http.ResponseWriter, r *http.Request, respond func(string)) {
+				if requests.Inc() == 1 {
+					<-r.Context().Done()
+					return
+				}
+				respond("")
+			})
+		if got := requests.Load(); got != 2 {
+			t.Errorf("Requests = %d, want: 2", got)
+		}
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		// The first probe sees an outdated config, and the retry is delayed by the backoff.
+		const backoff = 300 * time.Millisecond
+		requests := make(chan time.Time, 2)
+		probeIngress(t, []string{"foo.bar.com"}, Options{BackoffBase: backoff},
+			func(w http.ResponseWriter, r *http.Request, respond func(string)) {
+				requests <- time.Now()
+				if len(requests) == 1 {
+					respond("not-the-hash-you-are-looking-for")
+					return
+				}
+				respond("")
+			})
+		first, second := <-requests, <-requests
+		if delay := second.Sub(first); delay < backoff {
+			t.Errorf("Retry delay = %v, want at least: %v", delay, backoff)
+		}
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		var inflight, maxInflight atomic.Int32
+		probeIngress(t, []string{"a.bar.com", "b.bar.com", "c.bar.com", "d.bar.com"}, Options{Concurrency: 1},
+			func(w http.ResponseWriter, r *http.Request, respond func(string)) {
+				n := inflight.Inc()
+				for max := maxInflight.Load(); n > max && !maxInflight.CAS(max, n); max = maxInflight.Load() {
+				}
+				time.Sleep(20 * time.Millisecond)
+				// Leave before responding, for the next probe not to race with this one.
+				inflight.Dec()
+				respond("")
+			})
+		if got := maxInflight.Load(); got != 1 {
+			t.Errorf("Max concurrent probes = %d, want: 1", got)
+		}
+	})
+}
+
+type fakeProbeTargetLister []ProbeTarget
+
+func (l fakeProbeTargetLister) ListProbeTargets(ctx context.Context, ing *v1alpha1.Ingress) ([]ProbeTarget, error) {
+	targets := []ProbeTarget{}
+	for _, target := range l {
+		newTarget := ProbeTarget{
+			PodIPs:  target.PodIPs,
+			PodPort: target.PodPort,
+			Port:    target.Port,
+		}
+		for _, url := range target.URLs {
+			for _, host := range ing.Spec.Rules[0].Hosts {
+				newURL := *url
+				newURL.Host = host
+				newTarget.URLs = append(newTarget.URLs, &newURL)
+			}
+		}
+		targets = append(targets, newTarget)
+	}
+	return targets, nil
+}